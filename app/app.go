@@ -2,28 +2,89 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"cloud.ru_test/internal/loadbalancer"
-	"cloud.ru_test/pkg/backend"
 
 	"cloud.ru_test/config"
+	"cloud.ru_test/internal/accessevents"
+	"cloud.ru_test/internal/admin"
+	"cloud.ru_test/internal/alerting"
+	"cloud.ru_test/internal/apikeys"
+	"cloud.ru_test/internal/discovery"
+	"cloud.ru_test/internal/election"
+	"cloud.ru_test/internal/gossip"
+	"cloud.ru_test/internal/healthcheck"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/internal/metrics/push"
+	"cloud.ru_test/internal/outlier"
+	"cloud.ru_test/internal/overload"
 	"cloud.ru_test/internal/ratelimit"
 	"cloud.ru_test/internal/transport"
+	"cloud.ru_test/internal/upgrade"
+	"cloud.ru_test/internal/usage"
+	"cloud.ru_test/pkg/geoip"
 	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+)
+
+// Таймауты запуска и остановки приложения
+const (
+	// upgradeTimeout время, за которое новый процесс должен приготовиться к работе при обновлении
+	upgradeTimeout = 30 * time.Second
+
+	// startTimeout время на запуск подсистем и применение первой конфигурации
+	startTimeout = 30 * time.Second
+
+	// shutdownTimeout время на завершение текущих запросов и остановку подсистем
+	shutdownTimeout = 30 * time.Second
 )
 
 type App struct {
 	configManager *config.ConfigManager
-	proxy         *transport.Proxy
+	listeners     []*listener
+	middlewares   *transport.Chain
+	router        *transport.Router
+	apiKeys       *apikeys.Manager
+	adminServer   *admin.Server
+	healthChecker *healthcheck.Checker
+	outlier       *outlier.Detector
+	overload      *overload.Limiter
+	geoIP         *geoip.Resolver
+	routingDebug  *transport.RoutingDebug
+	recorder      *transport.Recorder
+	pushers       []*push.Pusher
+	usage         *usage.Accountant
+	accessEvents  *accessevents.Publisher
+	alerter       *alerting.Alerter
+	discovery     *discovery.Manager
+	elector       *election.Elector
+	gossip        *gossip.Gossip
+	loadBalancer  loadbalancer.LoadBalancer
+	rateLimiter   ratelimit.RateLimiter
+	config        *config.Config
 	appLogger     *logger.CustomZapLogger
 	mu            sync.Mutex
 	port          string
+
+	// Подсистемы в порядке запуска; останавливаются в обратном порядке
+	lifecycle lifecycle
+
+	// Подписка на изменения конфигурации и признак завершения ее обработки
+	configCh  <-chan *config.Config
+	watchDone chan struct{}
+
+	// Приложение запущено и не начало остановку (/readyz)
+	ready atomic.Bool
 }
 
 func NewApp(configPath, port string) (*App, error) {
@@ -36,21 +97,186 @@ func NewApp(configPath, port string) (*App, error) {
 	app := &App{
 		configManager: configManager,
 		port:          port,
+		routingDebug:  transport.NewRoutingDebug(),
 	}
 
 	// Создаем логгер
-	app.appLogger = logger.NewCustomZapLogger((*logger.LoggerConfig)(configManager.GetConfig().Logger))
+	app.appLogger = logger.NewCustomZapLogger(loggerConfig(configManager.GetConfig().Logger))
 	app.appLogger.Info(fmt.Sprintf("Инициализация приложения (configPath: %s, port: %s)", configPath, port))
 
-	// Подписываемся на изменения конфигурации
-	configCh := configManager.Subscribe()
-	go app.watchConfig(configCh)
-	app.appLogger.Info("Запущено отслеживание изменений конфигурации")
+	// Журнал доступа и метрики запросов обрабатываются в фоне общим для всех прокси Recorder
+	app.recorder = transport.NewRecorder(configManager.GetConfig().AccessLog, app.appLogger)
+
+	// Подсистема запускается после тех, от которых зависит. При остановке приложение
+	// сначала перестает считаться готовым и применять конфигурацию, затем дожидается
+	// завершения запросов и только после этого закрывает балансировщик и rate limiter.
+	app.lifecycle.logger = app.appLogger
+	app.lifecycle.add("config manager", nil, app.closeConfigManager)
+	app.lifecycle.add("admin", app.startAdmin, app.stopAdmin)
+	app.lifecycle.add("metrics push", nil, app.stopMetricsPush)
+	app.lifecycle.add("usage", nil, app.stopUsage)
+	app.lifecycle.add("access events", nil, app.stopAccessEvents)
+	app.lifecycle.add("balancing", nil, app.stopBalancing)
+	app.lifecycle.add("alerting", nil, app.stopAlerting)
+	app.lifecycle.add("recorder", nil, app.recorder.Close)
+	app.lifecycle.add("listeners", app.listen, app.closeListeners)
+	app.lifecycle.add("config", app.watch, app.unwatch)
+	app.lifecycle.add("gossip", app.startGossip, app.stopGossip)
+	app.lifecycle.add("ha", app.startHA, app.stopHA)
+	app.lifecycle.add("readiness", app.markReady, app.markNotReady)
 
 	return app, nil
 }
 
-func (a *App) watchConfig(configCh <-chan *config.Config) {
+// Start запускает подсистемы приложения: административный сервер, listener'ы
+// и применение конфигурации. Первая конфигурация применяется до возврата из Start,
+// после чего приложение считается готовым. Если запуск не удался, уже запущенные
+// подсистемы останавливаются.
+func (a *App) Start(ctx context.Context) error {
+	return a.lifecycle.start(ctx)
+}
+
+// startAdmin запускает административный сервер на отдельном порту. Из рабочих процессов
+// его запускает только первый: порт администрирования не разделяется.
+func (a *App) startAdmin(ctx context.Context) error {
+	adminCfg := a.configManager.GetConfig().Admin
+	switch {
+	case adminCfg == nil:
+		a.appLogger.Info("Административное API отключено")
+	case workerID() > 1:
+		a.appLogger.Info(fmt.Sprintf("Административное API обслуживает рабочий процесс 1, текущий процесс: %d", workerID()))
+	default:
+		server := admin.NewServer(adminCfg, a, a.appLogger)
+		if err := server.Start(); err != nil {
+			return fmt.Errorf("failed to start admin server: %w", err)
+		}
+		a.adminServer = server
+		a.appLogger.Info(fmt.Sprintf("Административный сервер запущен на %s", adminCfg.Port))
+	}
+	return nil
+}
+
+// listen занимает адреса listener'ов. Адреса занимаются один раз: при реконфигурации
+// меняются только обработчики. В режиме HA listen адреса занимает только лидер.
+func (a *App) listen(ctx context.Context) error {
+	cfg := a.configManager.GetConfig()
+	listeners, err := startListeners(listenerConfigs(cfg, a.port), reusePort(cfg), !haListen(cfg), a.appLogger)
+	if err != nil {
+		return fmt.Errorf("failed to start proxy server: %w", err)
+	}
+	a.listeners = listeners
+	return nil
+}
+
+// haListen проверяет, что адреса listener'ов занимает только лидер HA
+func haListen(cfg *config.Config) bool {
+	return cfg.HA != nil && cfg.HA.Mode != "announce"
+}
+
+// startHA запускает участие в выборах лидера HA. Выборы начинаются после применения
+// первой конфигурации, чтобы новый лидер сразу мог обрабатывать запросы.
+func (a *App) startHA(ctx context.Context) error {
+	cfg := a.configManager.GetConfig()
+	if cfg.HA == nil {
+		return nil
+	}
+
+	var callbacks election.Callbacks
+	if haListen(cfg) {
+		callbacks.OnElected = func() error {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			return bindListeners(a.listeners, a.appLogger)
+		}
+		callbacks.OnDemoted = func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			releaseListeners(a.listeners, a.appLogger)
+		}
+	}
+	elector, err := election.New(cfg.HA, callbacks, a.appLogger)
+	if err != nil {
+		return fmt.Errorf("failed to create HA elector: %w", err)
+	}
+	elector.Start()
+	a.elector = elector
+	a.appLogger.Info(fmt.Sprintf("Режим HA: экземпляр %s участвует в выборах лидера через %s", elector.Identity(), cfg.HA.Backend))
+	return nil
+}
+
+// stopHA прекращает участие в выборах; лидер освобождает блокировку для резервного экземпляра
+func (a *App) stopHA(ctx context.Context) error {
+	if a.elector == nil {
+		return nil
+	}
+	return a.elector.Stop(ctx)
+}
+
+// startGossip запускает обмен состоянием с другими экземплярами. Обмен начинается после
+// применения первой конфигурации, когда созданы балансировщик и rate limiter.
+func (a *App) startGossip(ctx context.Context) error {
+	cfg := a.configManager.GetConfig()
+	if cfg.Gossip == nil {
+		return nil
+	}
+
+	g, err := gossip.New(cfg.Gossip, a, a.appLogger)
+	if err != nil {
+		return fmt.Errorf("failed to create gossip: %w", err)
+	}
+	if err := g.Start(); err != nil {
+		return fmt.Errorf("failed to start gossip: %w", err)
+	}
+	a.gossip = g
+	a.appLogger.Info(fmt.Sprintf("Обмен состоянием: экземпляр %s принимает сообщения на %s", g.Node(), g.Addr()))
+	return nil
+}
+
+// stopGossip прекращает обмен состоянием
+func (a *App) stopGossip(ctx context.Context) error {
+	if a.gossip == nil {
+		return nil
+	}
+	return a.gossip.Stop(ctx)
+}
+
+// watch применяет текущую конфигурацию и подписывается на ее изменения
+func (a *App) watch(ctx context.Context) error {
+	configCh := a.configManager.Subscribe()
+	select {
+	case cfg := <-configCh:
+		if err := a.reconfigure(cfg); err != nil {
+			a.configManager.Unsubscribe(configCh)
+			return fmt.Errorf("failed to apply config: %w", err)
+		}
+	case <-ctx.Done():
+		a.configManager.Unsubscribe(configCh)
+		return ctx.Err()
+	}
+
+	a.configCh = configCh
+	a.watchDone = make(chan struct{})
+	go a.watchConfig(configCh, a.watchDone)
+	a.appLogger.Info("Запущено отслеживание изменений конфигурации")
+	return nil
+}
+
+// markReady отмечает приложение готовым принимать трафик и сообщает об этом
+// предыдущему процессу при обновлении
+func (a *App) markReady(ctx context.Context) error {
+	a.ready.Store(true)
+	upgrade.Ready()
+	if a.elector != nil && haListen(a.configManager.GetConfig()) && !a.elector.IsLeader() {
+		a.appLogger.Info("Приложение запущено в резерве HA и займет адреса listener'ов, когда станет лидером")
+		return nil
+	}
+	a.appLogger.Info(fmt.Sprintf("Приложение запущено и готово к работе на %s", strings.Join(a.Addresses(), ", ")))
+	return nil
+}
+
+func (a *App) watchConfig(configCh <-chan *config.Config, done chan<- struct{}) {
+	defer close(done)
+
 	for cfg := range configCh {
 		a.appLogger.Info(fmt.Sprintf("Получена новая конфигурация (метод балансировки: %s)", cfg.LoadBalancer.Method))
 		if err := a.reconfigure(cfg); err != nil {
@@ -61,118 +287,859 @@ func (a *App) watchConfig(configCh <-chan *config.Config) {
 	}
 }
 
+// reconfigure применяет новую конфигурацию, пересоздавая только затронутые подсистемы:
+// например, изменение уровня логирования не пересоздает балансировщик, а изменение
+// списка бэкендов не сбрасывает пользовательские лимиты rate limiter.
 func (a *App) reconfigure(cfg *config.Config) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	a.appLogger.Info("Начало реконфигурации приложения")
+	diff := diffConfig(a.config, cfg)
+	a.appLogger.Info(fmt.Sprintf("Начало реконфигурации приложения (изменения: %s)", diff))
+
+	if a.config != nil && diff.logger {
+		loggerCfg := loggerConfig(cfg.Logger)
+		a.appLogger.SetLevel(loggerCfg.LogLevel)
+		a.appLogger.SetSampling(loggerCfg.DebugSampling, loggerCfg.Sampling)
+		a.appLogger.Info(fmt.Sprintf("Уровень логирования изменен на %s", cfg.Logger.LogLevel))
+		if !reflect.DeepEqual(a.config.Logger.Outputs, cfg.Logger.Outputs) {
+			if err := a.appLogger.SetOutputs(loggerCfg.Outputs); err != nil {
+				a.appLogger.Error(fmt.Sprintf("Не удалось открыть назначения записи логов, используются прежние: %v", err))
+			} else {
+				a.appLogger.Info("Назначения записи логов изменены")
+			}
+		}
+	}
+	if a.config != nil && diff.admin {
+		a.appLogger.Warn("Изменения секции admin будут применены после перезапуска приложения")
+	}
+	if a.config != nil && diff.process {
+		a.appLogger.Warn("Изменения секции process будут применены после перезапуска приложения")
+	}
+	if a.config != nil && diff.ha {
+		a.appLogger.Warn("Изменения секции ha будут применены после перезапуска приложения")
+	}
+	if a.config != nil && diff.gossip {
+		a.appLogger.Warn("Изменения секции gossip будут применены после перезапуска приложения")
+	}
+	listenerCfgs := listenerConfigs(cfg, a.port)
+	if a.config != nil && diff.listeners && !sameBindings(a.listeners, listenerCfgs) {
+		a.appLogger.Warn("Изменения адресов, TLS и slowClient секции listeners будут применены после перезапуска приложения")
+	}
+
+	// Подсистемы, созданные этой реконфигурацией, освобождаются, если она прервется ошибкой
+	// до переключения приложения на новую конфигурацию
+	var rollback []func()
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		for i := len(rollback) - 1; i >= 0; i-- {
+			rollback[i]()
+		}
+	}()
+
+	// Отправка метрик пересоздается целиком; новые приемники начинают работу после
+	// успешного применения конфигурации
+	var pushers []*push.Pusher
+	if diff.metricsPush {
+		for _, pushCfg := range cfg.MetricsPush {
+			pusher, err := push.New(workerTags(pushCfg), metrics.Default, a.appLogger)
+			if err != nil {
+				return fmt.Errorf("failed to create metrics push: %w", err)
+			}
+			pushers = append(pushers, pusher)
+			rollback = append(rollback, pusher.Stop)
+		}
+	}
 
-	// Создаем новые компоненты
-	lb, err := loadbalancer.New(cfg.LoadBalancer, a.appLogger)
+	var accountant *usage.Accountant
+	if diff.usage && cfg.Usage != nil {
+		newAccountant, err := usage.New(cfg.Usage, "", a.appLogger)
+		if err != nil {
+			return fmt.Errorf("failed to create usage accounting: %w", err)
+		}
+		accountant = newAccountant
+		rollback = append(rollback, newAccountant.Stop)
+	}
+
+	var publisher *accessevents.Publisher
+	if diff.accessEvents && cfg.AccessEvents != nil {
+		newPublisher, err := accessevents.New(cfg.AccessEvents, a.appLogger)
+		if err != nil {
+			return fmt.Errorf("failed to create access events publisher: %w", err)
+		}
+		publisher = newPublisher
+		rollback = append(rollback, newPublisher.Stop)
+	}
+
+	var alerter *alerting.Alerter
+	if diff.alerting && cfg.Alerting != nil {
+		newAlerter, err := alerting.New(cfg.Alerting, metrics.Default, a.appLogger)
+		if err != nil {
+			return fmt.Errorf("failed to create alerting: %w", err)
+		}
+		alerter = newAlerter
+		rollback = append(rollback, newAlerter.Stop)
+	}
+
+	// Источники обнаружения создаются до изменения остальных подсистем,
+	// чтобы ошибка в их настройках не оставила приложение в промежуточном состоянии
+	var newDiscovery *discovery.Manager
+	if diff.discovery && len(cfg.Discovery) > 0 {
+		manager, err := discovery.NewManager(cfg.Discovery, a.appLogger)
+		if err != nil {
+			return fmt.Errorf("failed to create discovery: %w", err)
+		}
+		newDiscovery = manager
+		rollback = append(rollback, manager.Stop)
+	}
+
+	// Базы GeoIP открываются заранее: ошибка чтения файла не должна прерывать реконфигурацию на середине
+	resolver := a.geoIP
+	if diff.geoIP {
+		resolver = nil
+		if cfg.GeoIP != nil {
+			newResolver, err := geoip.New(cfg.GeoIP, a.appLogger)
+			if err != nil {
+				return fmt.Errorf("failed to open GeoIP databases: %w", err)
+			}
+			resolver = newResolver
+			rollback = append(rollback, newResolver.Stop)
+		}
+	}
+
+	trusted, err := request.ParseTrustedProxies(cfg.TrustedProxies)
 	if err != nil {
-		return fmt.Errorf("failed to create load balancer: %w", err)
+		return fmt.Errorf("failed to parse trusted proxies: %w", err)
 	}
 
-	for _, backendCfg := range cfg.Backends {
-		lb.AddBackend(backend.NewFromConfig(backendCfg))
+	chain := a.middlewares
+	if diff.middlewares {
+		if err := transport.LoadPlugins(cfg.Plugins, a.appLogger); err != nil {
+			return fmt.Errorf("failed to load plugins: %w", err)
+		}
+		newChain, err := transport.NewChain(cfg.Middlewares, a.appLogger)
+		if err != nil {
+			return fmt.Errorf("failed to create middlewares: %w", err)
+		}
+		chain = newChain
 	}
 
-	a.appLogger.Info(fmt.Sprintf("Создан новый балансировщик нагрузки (метод: %s)", cfg.LoadBalancer.Method))
+	// Ключи API загружаются заново только при изменении их настроек, чтобы не сбрасывать
+	// ограничения частоты запросов ключей
+	keys := a.apiKeys
+	if diff.apiKeys {
+		keys = nil
+		if keysCfg := apiKeysConfig(cfg); keysCfg != nil {
+			newKeys, err := apikeys.New(keysCfg, a.appLogger)
+			if err != nil {
+				return fmt.Errorf("failed to load API keys: %w", err)
+			}
+			keys = newKeys
+			rollback = append(rollback, func() { newKeys.Close() })
+		}
+	}
 
-	rLim := ratelimit.NewTokenBucket(cfg.RateLimiter.TokenBucket.Rate, cfg.RateLimiter.TokenBucket.Burst)
-	a.appLogger.Info(fmt.Sprintf("Создан новый rate limiter (rate: %.2f, burst: %d)",
-		cfg.RateLimiter.TokenBucket.Rate,
-		cfg.RateLimiter.TokenBucket.Burst))
+	// Правила маршрутизации пересоздаются и при изменении плагинов, на которые могут ссылаться
+	// middleware маршрутов
+	router := a.router
+	if diff.routing || diff.middlewares {
+		router = nil
+		if cfg.Routing != nil {
+			newRouter, err := transport.NewRouter(cfg.Routing, keys, a.appLogger)
+			if err != nil {
+				return fmt.Errorf("failed to create routing rules: %w", err)
+			}
+			router = newRouter
+		}
+	}
 
-	// Создаем новый прокси
-	newProxy := transport.NewProxy(lb, rLim, a.appLogger)
-	a.appLogger.Info("Создан новый прокси-сервер")
+	// Собственные цепочки middleware listener'ов; nil — listener использует цепочку верхнего уровня
+	updated := make([]config.ListenerConfig, len(a.listeners))
+	chains := make([]*transport.Chain, len(a.listeners))
+	for i, l := range a.listeners {
+		updated[i] = l.update(listenerCfgs)
+		chains[i] = l.chain
+		if diff.middlewares || !reflect.DeepEqual(updated[i].Middlewares, l.cfg.Middlewares) {
+			chains[i] = nil
+			if len(updated[i].Middlewares) > 0 {
+				listenerChain, err := transport.NewChain(updated[i].Middlewares, a.appLogger)
+				if err != nil {
+					return fmt.Errorf("failed to create middlewares of listener %s: %w", l.cfg.Name, err)
+				}
+				chains[i] = listenerChain
+			}
+		}
+	}
 
-	// Если у нас уже есть прокси, gracefully останавливаем его
-	if a.proxy != nil {
-		a.appLogger.Info("Обнаружен работающий прокси, выполняем горячую замену")
+	// Балансировщик пересоздается только при смене метода или его параметров,
+	// изменения списка бэкендов применяются к работающему балансировщику
+	lb := a.loadBalancer
+	if diff.loadBalancer {
+		newLB, err := loadbalancer.New(cfg.LoadBalancer, a.appLogger)
+		if err != nil {
+			return fmt.Errorf("failed to create load balancer: %w", err)
+		}
+		lb = newLB
+		rollback = append(rollback, func() { newLB.Stop(context.Background()) })
+		a.appLogger.Info(fmt.Sprintf("Создан новый балансировщик нагрузки (метод: %s)", cfg.LoadBalancer.Method))
+	}
 
-		// Запускаем новый прокси
-		if err := newProxy.Start(a.port); err != nil {
-			return fmt.Errorf("failed to start new proxy: %w", err)
+	rLim := a.rateLimiter
+	if diff.rateLimiter {
+		newLimiter, err := ratelimit.New(cfg.RateLimiter)
+		if err != nil {
+			return fmt.Errorf("failed to create rate limiter: %w", err)
 		}
-		a.appLogger.Info("Новый прокси успешно запущен")
+		rLim = newLimiter
 
-		// Останавливаем старый прокси
-		if err := a.proxy.Stop(); err != nil {
-			a.appLogger.Error(fmt.Sprintf("Ошибка при остановке старого прокси: %v", err))
-		} else {
-			a.appLogger.Info("Старый прокси успешно остановлен")
+		switch {
+		case cfg.RateLimiter != nil && cfg.RateLimiter.Enabled && cfg.RateLimiter.TokenBucket != nil:
+			a.appLogger.Info(fmt.Sprintf("Создан новый rate limiter %s (rate: %.2f, burst: %d)",
+				cfg.RateLimiter.Type,
+				cfg.RateLimiter.TokenBucket.Rate,
+				cfg.RateLimiter.TokenBucket.Burst))
+		case cfg.RateLimiter != nil && cfg.RateLimiter.Enabled:
+			a.appLogger.Info(fmt.Sprintf("Создан новый rate limiter %s", cfg.RateLimiter.Type))
+		default:
+			a.appLogger.Info("Rate limiter отключен, запросы не ограничиваются")
+		}
+	}
+
+	// Дальше приложение переключается на новую конфигурацию, созданные подсистемы больше не освобождаются
+	committed = true
+
+	// Старые источники обнаружения удаляют свои бэкенды до синхронизации,
+	// иначе они будут перенесены в новый балансировщик как добавленные через API
+	if diff.discovery && a.discovery != nil {
+		a.discovery.Stop()
+		a.discovery = nil
+		a.appLogger.Info("Остановлено обнаружение бэкендов по предыдущей конфигурации")
+	}
+
+	if diff.loadBalancer || diff.backends {
+		var oldBackends []config.BackendConfig
+		if a.config != nil {
+			oldBackends = a.config.Backends
+		}
+		retired := syncBackends(lb, a.loadBalancer, oldBackends, cfg.Backends)
+		a.appLogger.Info(fmt.Sprintf("Список бэкендов синхронизирован (всего: %d)", len(lb.GetBackends())))
+
+		// Удаленные и пересозданные бэкенды закрываются сразу: текущие запросы к ним
+		// завершаются, а фоновое обновление статистики и простаивающие соединения не нужны
+		for _, b := range retired {
+			b.Close()
+		}
+		if len(retired) > 0 {
+			a.appLogger.Debug(fmt.Sprintf("Закрыто бэкендов предыдущей конфигурации: %d", len(retired)))
+		}
+	}
+
+	// Пользовательские лимиты, заданные через API, переносятся в новый rate limiter
+	if diff.rateLimiter && a.rateLimiter != nil {
+		overrides := a.rateLimiter.ListUserLimits()
+		for userID, limits := range overrides {
+			rLim.SetUserLimits(userID, limits.Rate, limits.Burst)
 		}
-	} else {
-		// Первый запуск
-		a.appLogger.Info("Выполняется первичный запуск прокси")
-		if err := newProxy.Start(a.port); err != nil {
-			return fmt.Errorf("failed to start proxy: %w", err)
+		a.appLogger.Debug(fmt.Sprintf("Перенесено пользовательских лимитов: %d", len(overrides)))
+	}
+
+	// Детектор выбросов привязан к балансировщику и получает результаты запросов от прокси
+	detector := a.outlier
+	if lb != a.loadBalancer || diff.outlier {
+		detector = nil
+		if cfg.OutlierDetection != nil {
+			detector = outlier.New(cfg.OutlierDetection, lb, a.appLogger)
+		}
+	}
+
+	// Ограничитель одновременных запросов пересоздается только при изменении его настроек,
+	// чтобы счетчики обрабатываемых запросов не сбрасывались
+	limiter := a.overload
+	if diff.overload {
+		limiter = nil
+		if cfg.Overload != nil {
+			limiter = overload.New(cfg.Overload, a.appLogger)
+		}
+	}
+
+	// Режим отладки переживает пересоздание прокси, меняются только заголовок и ключ подписи
+	a.routingDebug.Configure(cfg.RoutingDebug)
+	a.recorder.Configure(cfg.AccessLog)
+
+	// Создаем новые прокси и переключаем на них работающие серверы.
+	// Listener'ы не пересоздаются, поэтому порты не освобождаются ни на мгновение.
+	var replaced []*transport.Proxy
+	shared := lb != a.loadBalancer || rLim != a.rateLimiter || detector != a.outlier || limiter != a.overload || resolver != a.geoIP || chain != a.middlewares || router != a.router || diff.trusted
+	for i, l := range a.listeners {
+		if shared || chains[i] != l.chain {
+			listenerChain := chains[i]
+			if listenerChain == nil {
+				listenerChain = chain
+			}
+			newProxy := transport.NewProxy(lb, rLim, transport.Options{
+				ProbeHeader:    cfg.LoadBalancer.ProbeHeader,
+				VersionHeader:  cfg.LoadBalancer.VersionHeader,
+				Forwarding:     cfg.ForwardedHeaders,
+				Outlier:        detector,
+				Middlewares:    listenerChain,
+				Router:         router,
+				Overload:       limiter,
+				GeoIP:          resolver,
+				TrustedProxies: trusted,
+				RoutingDebug:   a.routingDebug,
+				Recorder:       a.recorder,
+			}, a.appLogger)
+			oldProxy := l.server.SetProxy(newProxy)
+			if oldProxy != nil {
+				a.appLogger.Info(fmt.Sprintf("Новый прокси подключен к listener'у %s, старый прокси завершает текущие запросы", l.cfg.Name))
+				replaced = append(replaced, oldProxy)
+			} else {
+				a.appLogger.Info(fmt.Sprintf("Прокси подключен к listener'у %s на %s", l.cfg.Name, l.cfg.Address))
+			}
+			l.proxy = newProxy
+		}
+		l.cfg = updated[i]
+		l.chain = chains[i]
+		l.server.SetRedirectHTTPS(l.httpsPort())
+		l.server.SetConnectionLimits(l.cfg.Connections)
+	}
+
+	if newDiscovery != nil {
+		newDiscovery.Start(lb)
+		a.discovery = newDiscovery
+	} else if a.discovery != nil && lb != a.loadBalancer {
+		a.discovery.SetLoadBalancer(lb)
+	}
+
+	// Проверки здоровья привязаны к балансировщику: запускаем проверки нового
+	// и останавливаем проверки старого
+	if lb != a.loadBalancer || diff.healthCheck {
+		checker := healthcheck.New(cfg.HealthCheck, lb, a.appLogger)
+		checker.Start()
+		if a.healthChecker != nil {
+			a.healthChecker.Stop()
+		}
+		a.healthChecker = checker
+	}
+
+	// Старый детектор возвращает исключенные им бэкенды в ротацию
+	if detector != a.outlier {
+		if a.outlier != nil {
+			a.outlier.Stop()
+		}
+		if detector != nil {
+			detector.Start()
 		}
-		a.appLogger.Info(fmt.Sprintf("Прокси успешно запущен на порту %s", a.port))
+		a.outlier = detector
 	}
 
-	a.proxy = newProxy
+	if keys != a.apiKeys {
+		if a.apiKeys != nil {
+			a.apiKeys.Stop()
+		}
+		if keys != nil {
+			keys.Start()
+		}
+		a.apiKeys = keys
+	}
+
+	if limiter != a.overload {
+		if a.overload != nil {
+			a.overload.Stop()
+		}
+		if limiter != nil {
+			limiter.Start()
+		}
+		a.overload = limiter
+	}
+
+	if resolver != a.geoIP {
+		if a.geoIP != nil {
+			a.geoIP.Stop()
+		}
+		if resolver != nil {
+			resolver.Start()
+		}
+		a.geoIP = resolver
+	}
+
+	// Замененные балансировщик и rate limiter освобождают ресурсы, когда старые прокси
+	// завершат запросы, которые их используют; бэкенды старого балансировщика уже
+	// перенесены в новый или закрыты при синхронизации
+	var release []func()
+	if oldLB := a.loadBalancer; oldLB != nil && lb != oldLB {
+		release = append(release, func() {
+			if err := oldLB.Stop(context.Background()); err != nil {
+				a.appLogger.Warn(fmt.Sprintf("Ошибка при остановке предыдущего балансировщика: %v", err))
+			}
+		})
+	}
+	if oldLimiter := a.rateLimiter; oldLimiter != nil && rLim != oldLimiter {
+		release = append(release, func() {
+			if err := oldLimiter.Close(); err != nil {
+				a.appLogger.Warn(fmt.Sprintf("Ошибка при закрытии предыдущего rate limiter'а: %v", err))
+			}
+		})
+	}
+	if len(replaced) > 0 || len(release) > 0 {
+		go a.drainProxies(replaced, release)
+	}
+
+	if diff.metricsPush {
+		for _, pusher := range a.pushers {
+			pusher.Stop()
+		}
+		for _, pusher := range pushers {
+			pusher.Start()
+		}
+		a.pushers = pushers
+		if len(pushers) > 0 {
+			a.appLogger.Info(fmt.Sprintf("Отправка метрик настроена: %d приемников", len(pushers)))
+		}
+	}
+
+	// Прежний учет выгружает итоги после переключения, чтобы не потерять запросы периода
+	if diff.usage {
+		a.recorder.SetUsage(accountant)
+		if a.usage != nil {
+			a.usage.Stop()
+		}
+		if accountant != nil {
+			accountant.Start()
+		}
+		a.usage = accountant
+	}
+
+	if diff.accessEvents {
+		if publisher != nil {
+			publisher.Start()
+		}
+		a.recorder.SetEvents(publisher)
+		if a.accessEvents != nil {
+			a.accessEvents.Stop()
+		}
+		a.accessEvents = publisher
+	}
+
+	if diff.alerting {
+		if a.alerter != nil {
+			a.alerter.Stop()
+		}
+		if alerter != nil {
+			alerter.SetLoadBalancer(lb)
+			alerter.Start()
+		}
+		a.alerter = alerter
+	} else if a.alerter != nil && lb != a.loadBalancer {
+		a.alerter.SetLoadBalancer(lb)
+	}
+
+	a.loadBalancer = lb
+	a.rateLimiter = rLim
+	a.middlewares = chain
+	a.router = router
+	a.config = cfg
 	a.appLogger.Info("Реконфигурация приложения успешно завершена")
 	return nil
 }
 
+// Addresses возвращает адреса, которые слушают listener'ы, с фактическими портами
+func (a *App) Addresses() []string {
+	addresses := make([]string, len(a.listeners))
+	for i, l := range a.listeners {
+		addresses[i] = l.server.Addr()
+	}
+	return addresses
+}
+
+// LoadBalancer возвращает текущий балансировщик
+func (a *App) LoadBalancer() loadbalancer.LoadBalancer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.loadBalancer
+}
+
+// RoutingDebug возвращает режим отладки маршрутизации
+func (a *App) RoutingDebug() *transport.RoutingDebug {
+	return a.routingDebug
+}
+
+// APIKeys возвращает ключи API маршрутов (nil, если они не настроены)
+func (a *App) APIKeys() *apikeys.Manager {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.apiKeys
+}
+
+// HealthChecker возвращает текущий health check
+func (a *App) HealthChecker() *healthcheck.Checker {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.healthChecker
+}
+
+// Config возвращает действующую конфигурацию
+func (a *App) Config() *config.Config {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.config
+}
+
+// ConfigError возвращает ошибку последней загрузки конфигурации
+func (a *App) ConfigError() error {
+	return a.configManager.GetLastError()
+}
+
+// UpdateConfig изменяет конфигурацию и сохраняет ее в файл
+func (a *App) UpdateConfig(updateFn func(*config.Config) error) error {
+	return a.configManager.Update(updateFn)
+}
+
+// ReloadConfig принудительно перечитывает конфигурацию.
+// Возвращает true, если конфигурация изменилась.
+func (a *App) ReloadConfig() (bool, error) {
+	changed, err := a.configManager.Reload()
+	if err != nil {
+		return false, err
+	}
+
+	if !changed {
+		a.appLogger.Info("Конфигурация перечитана, изменений нет")
+	}
+	return changed, nil
+}
+
+// ConfigHistory возвращает историю примененных конфигураций
+func (a *App) ConfigHistory() []config.ConfigVersion {
+	return a.configManager.History()
+}
+
+// RollbackConfig повторно применяет версию конфигурации из истории
+func (a *App) RollbackConfig(version int) (*config.ConfigVersion, error) {
+	applied, err := a.configManager.Rollback(version)
+	if err != nil {
+		return nil, err
+	}
+
+	a.appLogger.Warn(fmt.Sprintf("Выполнен откат конфигурации к версии %d (новая версия: %d)", version, applied.Version))
+	return applied, nil
+}
+
+// RateLimiter возвращает текущий rate limiter
+func (a *App) RateLimiter() ratelimit.RateLimiter {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.rateLimiter
+}
+
 func (a *App) Run() error {
-	a.appLogger.Info(fmt.Sprintf("Приложение запущено и готово к работе на порту %s", a.port))
+	startCtx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	err := a.Start(startCtx)
+	cancel()
+	if err != nil {
+		return err
+	}
 
 	// Создаем канал для сигналов
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP)
+	if upgrade.Signal != nil {
+		signal.Notify(sigChan, upgrade.Signal)
+	}
 
-	// Логируем полученный сигнал
-	sig := <-sigChan
+	// SIGHUP перечитывает конфигурацию, сигнал обновления передает сокеты новому
+	// процессу, остальные сигналы завершают работу
+	var sig os.Signal
+	for sig == nil {
+		switch received := <-sigChan; received {
+		case syscall.SIGHUP:
+			a.appLogger.Info("Получен сигнал SIGHUP, перечитываем конфигурацию")
+			if _, err := a.ReloadConfig(); err != nil {
+				a.appLogger.Error(fmt.Sprintf("Ошибка перезагрузки конфигурации: %v", err))
+			}
+		case upgrade.Signal:
+			if a.upgrade() {
+				sig = received
+			}
+		default:
+			sig = received
+		}
+	}
 	a.appLogger.Info(fmt.Sprintf("Получен сигнал завершения работы: %v", sig))
 
 	// Graceful shutdown с таймаутом
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
+	return a.Stop(shutdownCtx)
+}
 
-	// Создаем канал для отслеживания завершения
-	done := make(chan struct{})
-	go func() {
-		defer close(done)
+// Stop останавливает приложение в порядке, обратном запуску: /readyz начинает отвечать 503,
+// изменения конфигурации больше не применяются, listener'ы перестают принимать соединения
+// и дожидаются завершения текущих запросов, после чего останавливаются проверки здоровья,
+// закрываются балансировщик с бэкендами и rate limiter, административный сервер
+// и менеджер конфигурации. В конце на диск сбрасываются логи. Если ctx завершается раньше,
+// оставшиеся соединения закрываются принудительно и возвращается ошибка.
+func (a *App) Stop(ctx context.Context) error {
+	a.appLogger.Info("Начало graceful shutdown")
+	defer a.flushLogs()
 
-		a.mu.Lock()
-		defer a.mu.Unlock()
+	if err := a.lifecycle.stop(ctx); err != nil {
+		a.appLogger.Error(fmt.Sprintf("Graceful shutdown завершен с ошибками: %v", err))
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
 
-		a.appLogger.Info("Начало graceful shutdown")
+	a.appLogger.Info("Приложение успешно завершило работу")
+	return nil
+}
 
-		if a.proxy != nil {
-			a.appLogger.Info("Остановка прокси-сервера")
-			if err := a.proxy.Stop(); err != nil {
-				a.appLogger.Error(fmt.Sprintf("Ошибка при остановке прокси: %v", err))
-			} else {
-				a.appLogger.Info("Прокси-сервер успешно остановлен")
-			}
-		}
+// markNotReady снимает признак готовности: балансировщик перед прокси перестает
+// направлять на него новый трафик
+func (a *App) markNotReady(ctx context.Context) error {
+	a.ready.Store(false)
+	a.appLogger.Info("Приложение больше не готово принимать трафик")
+	return nil
+}
 
-		if err := a.configManager.Close(); err != nil {
-			a.appLogger.Error(fmt.Sprintf("Ошибка при закрытии менеджера конфигурации: %v", err))
-		} else {
-			a.appLogger.Info("Менеджер конфигурации успешно закрыт")
+// unwatch прекращает применять изменения конфигурации и дожидается завершения текущей реконфигурации
+func (a *App) unwatch(ctx context.Context) error {
+	a.configManager.Unsubscribe(a.configCh)
+	select {
+	case <-a.watchDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeListeners перестает принимать соединения и дожидается завершения текущих запросов
+func (a *App) closeListeners(ctx context.Context) error {
+	a.appLogger.Info("Остановка прокси-сервера")
+	if err := stopListeners(ctx, a.listeners, a.appLogger); err != nil {
+		return err
+	}
+	a.appLogger.Info("Прокси-сервер остановлен")
+	return nil
+}
+
+// closeConfigManager прекращает отслеживание источника конфигурации
+func (a *App) closeConfigManager(ctx context.Context) error {
+	if err := a.configManager.Close(); err != nil {
+		return fmt.Errorf("failed to close config manager: %w", err)
+	}
+	a.appLogger.Info("Менеджер конфигурации успешно закрыт")
+	return nil
+}
+
+// flushLogs сбрасывает логи на диск. Ошибка не проверяется: консоль в части окружений
+// не поддерживает Sync, а сообщить о ней уже некуда.
+func (a *App) flushLogs() {
+	a.appLogger.Sync()
+}
+
+// Ready сообщает, запущено ли приложение и не началась ли его остановка
+func (a *App) Ready() bool {
+	return a.ready.Load()
+}
+
+// upgrade передает сокеты новому экземпляру исполняемого файла. Возвращает true,
+// если новый процесс готов и текущему нужно завершить работу.
+func (a *App) upgrade() bool {
+	if id := workerID(); id > 0 {
+		a.appLogger.Warn(fmt.Sprintf("Рабочий процесс %d не выполняет обновление: в режиме рабочих процессов используется reusePort", id))
+		return false
+	}
+
+	a.appLogger.Info("Получен сигнал обновления, запускаем новый процесс")
+	ctx, cancel := context.WithTimeout(context.Background(), upgradeTimeout)
+	defer cancel()
+	if err := upgrade.Upgrade(ctx); err != nil {
+		a.appLogger.Error(fmt.Sprintf("Обновление не выполнено, текущий процесс продолжает работу: %v", err))
+		return false
+	}
+
+	a.appLogger.Info("Новый процесс принял сокеты, текущий процесс завершает обработку запросов")
+	return true
+}
+
+// drainProxies дожидается завершения запросов замененных прокси, после чего
+// освобождает подсистемы, которые использовали только они
+func (a *App) drainProxies(proxies []*transport.Proxy, release []func()) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, p := range proxies {
+		if err := p.Drain(ctx); err != nil {
+			a.appLogger.Warn(fmt.Sprintf("Старый прокси не завершил запросы вовремя: %v", err))
+			continue
 		}
+		a.appLogger.Debug("Старый прокси завершил обработку запросов")
+	}
+	for _, fn := range release {
+		fn()
+	}
+}
 
-		a.appLogger.Info("Graceful shutdown успешно завершен")
-	}()
+// stopMetricsPush останавливает отправку метрик, отправив значения счетчиков
+// после завершения запросов
+func (a *App) stopMetricsPush(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	// Ожидаем завершения с таймаутом
-	select {
-	case <-shutdownCtx.Done():
-		a.appLogger.Error("Превышен таймаут graceful shutdown, выполняется принудительное завершение")
-		return fmt.Errorf("graceful shutdown timeout exceeded")
-	case <-done:
-		a.appLogger.Info("Приложение успешно завершило работу")
+	for _, pusher := range a.pushers {
+		pusher.Stop()
+	}
+	a.pushers = nil
+	return nil
+}
+
+// stopUsage выгружает итоги учета потребления после обработки записей о запросах
+func (a *App) stopUsage(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.usage != nil {
+		a.recorder.SetUsage(nil)
+		a.usage.Stop()
+		a.usage = nil
+	}
+	return nil
+}
+
+// stopAccessEvents отправляет оставшиеся события о запросах после обработки записей
+func (a *App) stopAccessEvents(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.accessEvents != nil {
+		a.recorder.SetEvents(nil)
+		a.accessEvents.Stop()
+		a.accessEvents = nil
+	}
+	return nil
+}
+
+// stopAlerting останавливает проверку правил оповещений
+func (a *App) stopAlerting(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.alerter != nil {
+		a.alerter.Stop()
+		a.alerter = nil
+	}
+	return nil
+}
+
+// stopBalancing останавливает проверки здоровья и прочие подсистемы, привязанные
+// к балансировщику, затем закрывает балансировщик с бэкендами и rate limiter
+func (a *App) stopBalancing(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.healthChecker != nil {
+		a.healthChecker.Stop()
+	}
+	if a.outlier != nil {
+		a.outlier.Stop()
+	}
+	if a.overload != nil {
+		a.overload.Stop()
+	}
+	if a.geoIP != nil {
+		a.geoIP.Stop()
+	}
+	if a.discovery != nil {
+		a.discovery.Stop()
+	}
+	if a.apiKeys != nil {
+		a.apiKeys.Stop()
+	}
+
+	var errs []error
+	if a.loadBalancer != nil {
+		if err := a.loadBalancer.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop load balancer: %w", err))
+		}
+		for _, state := range a.loadBalancer.GetBackends() {
+			state.Backend.Close()
+		}
+	}
+	if a.rateLimiter != nil {
+		if err := a.rateLimiter.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close rate limiter: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stopAdmin останавливает административный сервер, если он запущен
+func (a *App) stopAdmin(ctx context.Context) error {
+	if a.adminServer == nil {
 		return nil
 	}
+
+	a.appLogger.Info("Остановка административного сервера")
+	if err := a.adminServer.Shutdown(ctx); err != nil {
+		return err
+	}
+	a.appLogger.Info("Административный сервер успешно остановлен")
+	return nil
+}
+
+// loggerConfig преобразует секцию logger конфигурации в настройки логгера
+func loggerConfig(cfg *config.LoggerConfig) *logger.LoggerConfig {
+	loggerCfg := &logger.LoggerConfig{
+		LogLevel:    cfg.LogLevel,
+		NodeIP:      cfg.NodeIP,
+		PodIP:       cfg.PodIP,
+		ServiceName: cfg.ServiceName,
+	}
+	loggerCfg.DebugSampling = sampling(cfg.DebugSampling)
+	loggerCfg.Sampling = sampling(cfg.Sampling)
+	for _, output := range cfg.Outputs {
+		loggerCfg.Outputs = append(loggerCfg.Outputs, logger.OutputConfig{
+			Type:       output.Type,
+			Encoding:   output.Encoding,
+			Path:       output.Path,
+			MaxSizeMB:  output.MaxSize,
+			MaxAge:     output.MaxAge,
+			MaxBackups: output.MaxBackups,
+			Compress:   output.Compress,
+			Network:    output.Network,
+			Address:    output.Address,
+			Tag:        output.Tag,
+		})
+	}
+	return loggerCfg
+}
+
+// sampling преобразует настройки выборки сообщений; без секции выборка отключена
+func sampling(cfg *config.LogSamplingConfig) logger.Sampling {
+	if cfg == nil {
+		return logger.Sampling{}
+	}
+	return logger.Sampling{Initial: cfg.Initial, Thereafter: cfg.Thereafter, PerMessage: cfg.PerMessage}
 }
 
 func Run(configPath, port string) error {
+	// Управляющий процесс не обрабатывает запросы, а запускает рабочие процессы
+	if workerID() == 0 {
+		configManager, err := config.NewConfigManager(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to create config manager: %w", err)
+		}
+		cfg := configManager.GetConfig()
+		configManager.Close()
+
+		if cfg.Process != nil && cfg.Process.Workers > 0 {
+			appLogger := logger.NewCustomZapLogger(loggerConfig(cfg.Logger))
+			return runWorkers(cfg.Process.Workers, appLogger)
+		}
+	}
+
 	app, err := NewApp(configPath, port)
 	if err != nil {
 		return fmt.Errorf("failed to create app: %w", err)
@@ -0,0 +1,316 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/internal/transport"
+	"cloud.ru_test/pkg/logger"
+)
+
+// TestMain проверяет, что после тестов пакета не остается запущенных горутин
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+// testConfig разбирает конфигурацию приложения для тестов
+func testConfig(t *testing.T, data string) *config.Config {
+	t.Helper()
+	cfg, err := config.Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// newTestApp создает приложение с listener'ом на свободном порту и применяет cfg
+// без менеджера конфигурации и административного API
+func newTestApp(t *testing.T, cfg *config.Config) *App {
+	t.Helper()
+	// Логи пишутся во временный каталог теста, а не в logs/app.log рядом с пакетом
+	loggerCfg := loggerConfig(cfg.Logger)
+	loggerCfg.Outputs = []logger.OutputConfig{{Type: logger.OutputFile, Path: filepath.Join(t.TempDir(), "app.log"), Encoding: logger.EncodingJSON}}
+	a := &App{
+		appLogger:    logger.NewCustomZapLogger(loggerCfg),
+		routingDebug: transport.NewRoutingDebug(),
+		port:         "127.0.0.1:0",
+	}
+	a.recorder = transport.NewRecorder(cfg.AccessLog, a.appLogger)
+
+	listeners, err := startListeners(listenerConfigs(cfg, a.port), false, true, a.appLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.listeners = listeners
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopListeners(ctx, a.listeners, a.appLogger)
+		a.stopBalancing(ctx)
+		a.recorder.Close(ctx)
+	})
+
+	if err := a.reconfigure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// closeRecorder rate limiter, отмечающий вызов Close
+type closeRecorder struct {
+	ratelimit.RateLimiter
+	closed atomic.Bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed.Store(true)
+	return r.RateLimiter.Close()
+}
+
+// slowBackend бэкенд, который отвечает на запросы /slow после закрытия release и сообщает
+// о каждом из них в started; проверки здоровья получают ответ сразу
+func slowBackend(t *testing.T) (url string, started <-chan struct{}, release chan<- struct{}) {
+	t.Helper()
+	startedCh, releaseCh := make(chan struct{}, 16), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			startedCh <- struct{}{}
+			select {
+			case <-releaseCh:
+			case <-r.Context().Done():
+			}
+		}
+		io.WriteString(w, "done")
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, startedCh, releaseCh
+}
+
+// get отправляет запрос listener'у и возвращает результат в канал
+func get(addr, path string) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			result <- err
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || string(body) != "done" {
+			err = fmt.Errorf("status %d, body %q", resp.StatusCode, body)
+		}
+		result <- err
+	}()
+	return result
+}
+
+const reloadConfig = `
+backends:
+  - id: slow
+    url: %s
+loadBalancer:
+  method: %s
+rateLimiter:
+  enabled: true
+  type: TokenBucket
+  tokenBucket:
+    rate: %d
+    burst: 1000
+logger:
+  logLevel: error
+  serviceName: test
+`
+
+func TestApp_ReloadWithRequestsInFlight(t *testing.T) {
+	url, started, release := slowBackend(t)
+	a := newTestApp(t, testConfig(t, fmt.Sprintf(reloadConfig, url, "RoundRobin", 1000)))
+	addr := a.Addresses()[0]
+
+	// Rate limiter, который заменит перезагрузка, отмечает свое закрытие
+	old := &closeRecorder{RateLimiter: a.rateLimiter}
+	a.rateLimiter = old
+
+	inFlight := get(addr, "/slow")
+	<-started
+
+	// Балансировщик и rate limiter пересоздаются, пока старый прокси обрабатывает запрос
+	if err := a.reconfigure(testConfig(t, fmt.Sprintf(reloadConfig, url, "LeastConnections", 500))); err != nil {
+		t.Fatal(err)
+	}
+	if old.closed.Load() {
+		t.Error("замененный rate limiter не должен закрываться до завершения запросов старого прокси")
+	}
+
+	// Новые запросы обрабатывает новый прокси
+	next := get(addr, "/slow")
+	<-started
+	close(release)
+	for _, result := range []<-chan error{inFlight, next} {
+		if err := <-result; err != nil {
+			t.Errorf("запрос во время перезагрузки должен завершаться успешно: %v", err)
+		}
+	}
+
+	for deadline := time.Now().Add(time.Second); !old.closed.Load(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("замененный rate limiter должен закрываться после завершения запросов старого прокси")
+		}
+	}
+}
+
+func TestApp_ReloadRacesWithRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "done")
+	}))
+	defer server.Close()
+	a := newTestApp(t, testConfig(t, fmt.Sprintf(reloadConfig, server.URL, "RoundRobin", 100000)))
+	addr := a.Addresses()[0]
+
+	// Запросы идут непрерывно, пока прокси многократно заменяется
+	stop := make(chan struct{})
+	failures := make(chan error, 1)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if err := <-get(addr, "/"); err != nil {
+					select {
+					case failures <- err:
+					default:
+					}
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		method := []string{"RoundRobin", "LeastConnections"}[i%2]
+		if err := a.reconfigure(testConfig(t, fmt.Sprintf(reloadConfig, server.URL, method, 100000+i))); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(stop)
+
+	select {
+	case err := <-failures:
+		t.Errorf("запросы во время перезагрузок должны обслуживаться: %v", err)
+	default:
+	}
+}
+
+const keysConfig = `
+backends:
+  - id: b1
+    url: %s
+loadBalancer:
+  method: RoundRobin
+routing:
+  rules:
+    - name: api
+      match:
+        pathPrefix: /
+%s
+logger:
+  logLevel: error
+  serviceName: test
+`
+
+func TestApp_FailedReconfigureKeepsState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	auth := `  auth:
+    apiKeys:
+      keys:
+        - name: ops
+          key: static-key-0123456`
+	a := newTestApp(t, testConfig(t, fmt.Sprintf(keysConfig, server.URL, auth)))
+	keys, router := a.apiKeys, a.router
+	if keys == nil {
+		t.Fatal("ключи API должны создаваться по конфигурации")
+	}
+
+	// Ошибка правил маршрутизации после того, как ключи API удалены из конфигурации
+	for name, cfg := range map[string]*config.Config{
+		"без ключей":       testConfig(t, fmt.Sprintf(keysConfig, server.URL, "")),
+		"с новыми ключами": testConfig(t, fmt.Sprintf(keysConfig, server.URL, strings.Replace(auth, "ops", "billing", 1))),
+	} {
+		cfg.Routing.Rules[0].Match.PathRegex = "("
+		if err := a.reconfigure(cfg); err == nil {
+			t.Errorf("%s: реконфигурация с некорректным правилом должна завершаться ошибкой", name)
+		}
+		if a.apiKeys != keys || a.router != router {
+			t.Errorf("%s: неудачная реконфигурация не должна менять ключи API и маршрутизацию", name)
+		}
+	}
+	if _, err := a.apiKeys.Authenticate("static-key-0123456"); err != nil {
+		t.Errorf("прежние ключи API должны продолжать работать: %v", err)
+	}
+}
+
+// failingConfig дополняет конфигурацию подсистемами, которые создаются до правил
+// маршрутизации; некорректное правило прерывает реконфигурацию после их создания
+const failingConfig = `
+metricsPush:
+  - type: statsd
+    address: 127.0.0.1:8125
+usage:
+  exports:
+    - type: webhook
+      url: http://127.0.0.1:1/usage
+accessEvents:
+  type: kafka
+  brokers: [127.0.0.1:9092]
+  topic: access
+alerting:
+  rules:
+    - name: errors
+      type: errorRate
+      threshold: 0.5
+  webhooks:
+    - type: slack
+      url: http://127.0.0.1:1/alerts
+discovery:
+  - type: file
+    file:
+      path: %s
+`
+
+func TestApp_FailedReconfigureNoLeaks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	a := newTestApp(t, testConfig(t, fmt.Sprintf(keysConfig, server.URL, "")))
+	lb, router := a.loadBalancer, a.router
+	ignore := goleak.IgnoreCurrent()
+
+	discovered := filepath.Join(t.TempDir(), "backends.yaml")
+	cfg := testConfig(t, strings.Replace(fmt.Sprintf(keysConfig, server.URL, ""), "RoundRobin", "LeastConnections", 1)+
+		fmt.Sprintf(failingConfig, discovered))
+	cfg.Routing.Rules[0].Match.PathRegex = "("
+	if err := a.reconfigure(cfg); err == nil {
+		t.Fatal("реконфигурация с некорректным правилом должна завершаться ошибкой")
+	}
+	if a.loadBalancer != lb || a.router != router || a.pushers != nil || a.usage != nil || a.accessEvents != nil || a.alerter != nil || a.discovery != nil {
+		t.Error("неудачная реконфигурация не должна менять подсистемы приложения")
+	}
+
+	// Созданные реконфигурацией подсистемы не оставляют запущенных горутин
+	goleak.VerifyNone(t, ignore)
+}
@@ -0,0 +1,236 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/pkg/backend"
+)
+
+// configDiff описывает, какие подсистемы затронуло изменение конфигурации
+type configDiff struct {
+	logger       bool
+	loadBalancer bool
+	backends     bool
+	discovery    bool
+	healthCheck  bool
+	outlier      bool
+	overload     bool
+	geoIP        bool
+	trusted      bool
+	rateLimiter  bool
+	middlewares  bool
+	routing      bool
+	apiKeys      bool
+	listeners    bool
+	process      bool
+	ha           bool
+	gossip       bool
+	admin        bool
+	metricsPush  bool
+	usage        bool
+	accessEvents bool
+	alerting     bool
+}
+
+// diffConfig сравнивает конфигурации. Если старой конфигурации нет, изменившимися считаются все подсистемы.
+func diffConfig(old, cfg *config.Config) configDiff {
+	if old == nil {
+		return configDiff{
+			logger:       true,
+			loadBalancer: true,
+			backends:     true,
+			discovery:    true,
+			healthCheck:  true,
+			outlier:      true,
+			overload:     true,
+			geoIP:        true,
+			trusted:      true,
+			rateLimiter:  true,
+			middlewares:  true,
+			routing:      true,
+			apiKeys:      true,
+			listeners:    true,
+			process:      true,
+			ha:           true,
+			gossip:       true,
+			admin:        true,
+			metricsPush:  true,
+			usage:        true,
+			accessEvents: true,
+			alerting:     true,
+		}
+	}
+
+	return configDiff{
+		logger:       !reflect.DeepEqual(old.Logger, cfg.Logger),
+		loadBalancer: !reflect.DeepEqual(old.LoadBalancer, cfg.LoadBalancer),
+		backends:     !reflect.DeepEqual(old.Backends, cfg.Backends),
+		discovery:    !reflect.DeepEqual(old.Discovery, cfg.Discovery),
+		healthCheck:  !reflect.DeepEqual(old.HealthCheck, cfg.HealthCheck),
+		outlier:      !reflect.DeepEqual(old.OutlierDetection, cfg.OutlierDetection),
+		overload:     !reflect.DeepEqual(old.Overload, cfg.Overload),
+		geoIP:        !reflect.DeepEqual(old.GeoIP, cfg.GeoIP),
+		trusted:      !reflect.DeepEqual(old.TrustedProxies, cfg.TrustedProxies) || !reflect.DeepEqual(old.ForwardedHeaders, cfg.ForwardedHeaders),
+		rateLimiter:  !reflect.DeepEqual(old.RateLimiter, cfg.RateLimiter),
+		middlewares:  !reflect.DeepEqual(old.Middlewares, cfg.Middlewares) || !reflect.DeepEqual(old.Plugins, cfg.Plugins),
+		routing:      !reflect.DeepEqual(old.Routing, cfg.Routing),
+		apiKeys:      !reflect.DeepEqual(apiKeysConfig(old), apiKeysConfig(cfg)),
+		listeners:    !reflect.DeepEqual(old.Listeners, cfg.Listeners),
+		process:      !reflect.DeepEqual(old.Process, cfg.Process),
+		ha:           !reflect.DeepEqual(old.HA, cfg.HA),
+		gossip:       !reflect.DeepEqual(old.Gossip, cfg.Gossip),
+		admin:        !reflect.DeepEqual(old.Admin, cfg.Admin),
+		metricsPush:  !reflect.DeepEqual(old.MetricsPush, cfg.MetricsPush),
+		usage:        !reflect.DeepEqual(old.Usage, cfg.Usage),
+		accessEvents: !reflect.DeepEqual(old.AccessEvents, cfg.AccessEvents),
+		alerting:     !reflect.DeepEqual(old.Alerting, cfg.Alerting),
+	}
+}
+
+// apiKeysConfig возвращает настройки ключей API маршрутов или nil
+func apiKeysConfig(cfg *config.Config) *config.APIKeyAuthConfig {
+	if cfg.Routing == nil || cfg.Routing.Auth == nil {
+		return nil
+	}
+	return cfg.Routing.Auth.APIKeys
+}
+
+// String перечисляет изменившиеся подсистемы
+func (d configDiff) String() string {
+	var changed []string
+	for _, subsystem := range []struct {
+		name    string
+		changed bool
+	}{
+		{"logger", d.logger},
+		{"loadBalancer", d.loadBalancer},
+		{"backends", d.backends},
+		{"discovery", d.discovery},
+		{"healthCheck", d.healthCheck},
+		{"outlierDetection", d.outlier},
+		{"overload", d.overload},
+		{"geoIP", d.geoIP},
+		{"trustedProxies", d.trusted},
+		{"rateLimiter", d.rateLimiter},
+		{"middlewares", d.middlewares},
+		{"routing", d.routing},
+		{"apiKeys", d.apiKeys},
+		{"listeners", d.listeners},
+		{"process", d.process},
+		{"ha", d.ha},
+		{"gossip", d.gossip},
+		{"admin", d.admin},
+		{"metricsPush", d.metricsPush},
+		{"usage", d.usage},
+		{"accessEvents", d.accessEvents},
+		{"alerting", d.alerting},
+	} {
+		if subsystem.changed {
+			changed = append(changed, subsystem.name)
+		}
+	}
+
+	if len(changed) == 0 {
+		return "нет изменений"
+	}
+	return strings.Join(changed, ", ")
+}
+
+// syncBackends приводит набор бэкендов балансировщика lb к конфигурации. Бэкенды,
+// чьи адрес и таймауты не изменились, переносятся из prev вместе со статистикой
+// и состоянием здоровья; вес и режим обслуживания меняются на месте. Бэкенды,
+// добавленные через административное API, сохраняются. Возвращает бэкенды prev,
+// которые не попали в lb: их нужно закрыть.
+func syncBackends(lb, prev loadbalancer.LoadBalancer, oldBackends, newBackends []config.BackendConfig) []backend.Backend {
+	var before []backend.Backend
+	if prev != nil {
+		for _, state := range prev.GetBackends() {
+			before = append(before, state.Backend)
+		}
+	}
+
+	oldByID := make(map[string]config.BackendConfig, len(oldBackends))
+	for _, bc := range oldBackends {
+		oldByID[bc.ID] = bc
+	}
+	newIDs := make(map[string]bool, len(newBackends))
+	for _, bc := range newBackends {
+		newIDs[bc.ID] = true
+	}
+
+	if prev != nil {
+		for _, state := range prev.GetBackends() {
+			id := state.Backend.ID()
+			_, configured := oldByID[id]
+			switch {
+			case configured && !newIDs[id] && lb == prev:
+				// Бэкенд удален из конфигурации
+				lb.RemoveBackend(state.Backend)
+			case !configured && !newIDs[id] && lb != prev:
+				// Бэкенд добавлен через административное API
+				lb.AddBackend(state.Backend)
+			}
+		}
+	}
+
+	for _, bc := range newBackends {
+		var existing backend.Backend
+		if prev != nil {
+			if state := prev.GetBackend(bc.ID); state != nil {
+				existing = state.Backend
+			}
+		}
+
+		oldCfg, known := oldByID[bc.ID]
+		if existing == nil || !known || !sameEndpoint(oldCfg, bc) {
+			if existing != nil && lb == prev {
+				lb.RemoveBackend(existing)
+			}
+			lb.AddBackend(backend.NewFromConfig(bc))
+			continue
+		}
+
+		if backendWeight(oldCfg) != backendWeight(bc) {
+			existing.SetWeight(backendWeight(bc))
+		}
+		if oldCfg.Maintenance != bc.Maintenance {
+			existing.SetMaintenance(bc.Maintenance)
+		}
+		if lb != prev {
+			lb.AddBackend(existing)
+		}
+	}
+
+	var retired []backend.Backend
+	for _, b := range before {
+		if current := lb.GetBackend(b.ID()); current == nil || current.Backend != b {
+			retired = append(retired, b)
+		}
+	}
+	return retired
+}
+
+// sameEndpoint проверяет, что бэкенд можно обновить на месте, не пересоздавая
+func sameEndpoint(a, b config.BackendConfig) bool {
+	return a.URL == b.URL &&
+		a.ConnectTimeout == b.ConnectTimeout &&
+		a.ReadTimeout == b.ReadTimeout &&
+		a.MaxConnections == b.MaxConnections &&
+		a.Host == b.Host &&
+		a.DNSRefreshInterval == b.DNSRefreshInterval &&
+		a.Protocol == b.Protocol &&
+		reflect.DeepEqual(a.AdaptiveConcurrency, b.AdaptiveConcurrency) &&
+		reflect.DeepEqual(a.Headers, b.Headers) &&
+		reflect.DeepEqual(a.HealthCheck, b.HealthCheck)
+}
+
+// backendWeight возвращает вес бэкенда из конфигурации (по умолчанию 1)
+func backendWeight(bc config.BackendConfig) float64 {
+	if bc.Weight == nil {
+		return 1.0
+	}
+	return *bc.Weight
+}
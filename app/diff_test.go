@@ -0,0 +1,156 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const listenerConfig = `
+listeners:
+  - name: web
+    address: 127.0.0.1:0
+    middlewares:
+      - name: headers
+        params:
+          set:
+            X-Listener: %s
+backends:
+%s
+loadBalancer:
+  method: RoundRobin
+rateLimiter:
+  enabled: true
+  type: TokenBucket
+  tokenBucket:
+    rate: 100
+    burst: 200
+logger:
+  logLevel: %s
+  serviceName: test
+`
+
+// listenerBackend бэкенд, который отвечает значением заголовка X-Listener, а на запросы
+// /slow — после закрытия release, сообщив о запросе в started
+func listenerBackend(t *testing.T) (url string, started <-chan struct{}, release chan<- struct{}) {
+	t.Helper()
+	startedCh, releaseCh := make(chan struct{}, 16), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			startedCh <- struct{}{}
+			select {
+			case <-releaseCh:
+			case <-r.Context().Done():
+			}
+		}
+		io.WriteString(w, r.Header.Get("X-Listener"))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, startedCh, releaseCh
+}
+
+// backendsYAML возвращает секцию backends с бэкендами по адресу url
+func backendsYAML(url string, ids ...string) string {
+	var section string
+	for _, id := range ids {
+		section += fmt.Sprintf("  - id: %s\n    url: %s\n", id, url)
+	}
+	return section
+}
+
+// fetchBody отправляет запрос listener'у и возвращает тело ответа в канал
+func fetchBody(addr, path string) <-chan string {
+	result := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		result <- string(body)
+	}()
+	return result
+}
+
+func TestReconfigure_LoggerOnly(t *testing.T) {
+	url, _, _ := listenerBackend(t)
+	a := newTestApp(t, testConfig(t, fmt.Sprintf(listenerConfig, "v1", backendsYAML(url, "b1"), "error")))
+	lb, limiter, proxy := a.loadBalancer, a.rateLimiter, a.listeners[0].proxy
+
+	cfg := testConfig(t, fmt.Sprintf(listenerConfig, "v1", backendsYAML(url, "b1"), "warn"))
+	if diff := diffConfig(a.config, cfg).String(); diff != "logger" {
+		t.Errorf("изменение уровня логирования должно затрагивать только logger, получено: %s", diff)
+	}
+	if err := a.reconfigure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if a.loadBalancer != lb || a.rateLimiter != limiter || a.listeners[0].proxy != proxy {
+		t.Error("изменение логгера не должно пересоздавать балансировщик, rate limiter и прокси")
+	}
+	if len(a.loadBalancer.GetBackends()) != 1 {
+		t.Errorf("бэкенды должны сохраниться, получено %d", len(a.loadBalancer.GetBackends()))
+	}
+}
+
+func TestReconfigure_BackendsOnly(t *testing.T) {
+	url, _, _ := listenerBackend(t)
+	a := newTestApp(t, testConfig(t, fmt.Sprintf(listenerConfig, "v1", backendsYAML(url, "b1"), "error")))
+	lb, limiter := a.loadBalancer, a.rateLimiter
+	limiter.SetUserLimits("partner", 5, 10)
+
+	cfg := testConfig(t, fmt.Sprintf(listenerConfig, "v1", backendsYAML(url, "b1", "b2"), "error"))
+	if diff := diffConfig(a.config, cfg).String(); diff != "backends" {
+		t.Errorf("добавление бэкенда должно затрагивать только backends, получено: %s", diff)
+	}
+	if err := a.reconfigure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if a.loadBalancer != lb {
+		t.Error("изменение списка бэкендов должно применяться к работающему балансировщику")
+	}
+	if a.rateLimiter != limiter {
+		t.Fatal("изменение списка бэкендов не должно пересоздавать rate limiter")
+	}
+	if limits := a.rateLimiter.GetUserLimits("partner"); limits == nil || limits.Rate != 5 || limits.Burst != 10 {
+		t.Errorf("лимиты клиента должны сохраниться: %+v", limits)
+	}
+	if len(a.loadBalancer.GetBackends()) != 2 {
+		t.Errorf("новый бэкенд должен добавиться в балансировщик, всего %d", len(a.loadBalancer.GetBackends()))
+	}
+}
+
+func TestReconfigure_Listeners(t *testing.T) {
+	url, started, release := listenerBackend(t)
+	a := newTestApp(t, testConfig(t, fmt.Sprintf(listenerConfig, "v1", backendsYAML(url, "b1"), "error")))
+	addr, proxy, lb := a.Addresses()[0], a.listeners[0].proxy, a.loadBalancer
+
+	inFlight := fetchBody(addr, "/slow")
+	<-started
+
+	cfg := testConfig(t, fmt.Sprintf(listenerConfig, "v2", backendsYAML(url, "b1"), "error"))
+	if diff := diffConfig(a.config, cfg).String(); diff != "listeners" {
+		t.Errorf("изменение middleware listener'а должно затрагивать только listeners, получено: %s", diff)
+	}
+	if err := a.reconfigure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if a.listeners[0].proxy == proxy || a.loadBalancer != lb {
+		t.Error("изменение middleware listener'а должно заменять его прокси, сохраняя балансировщик")
+	}
+	if a.Addresses()[0] != addr {
+		t.Errorf("адрес listener'а не должен меняться: %s, было %s", a.Addresses()[0], addr)
+	}
+
+	// Новые запросы проходят новую цепочку, а начатый запрос завершает старый прокси
+	if body := <-fetchBody(addr, "/"); body != "v2" {
+		t.Errorf("новый запрос должен обрабатываться новой цепочкой middleware: %q", body)
+	}
+	close(release)
+	if body := <-inFlight; body != "v1" {
+		t.Errorf("начатый запрос должен завершаться старым прокси: %q", body)
+	}
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"cloud.ru_test/pkg/logger"
+)
+
+// component подсистема приложения, которая запускается и останавливается вместе с ним.
+// start и stop могут быть nil, если на соответствующем этапе подсистеме нечего делать.
+type component struct {
+	name  string
+	start func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+}
+
+// lifecycle запускает компоненты в порядке добавления и останавливает в обратном:
+// компонент добавляется после тех, от которых зависит, поэтому запускается после
+// них и останавливается раньше
+type lifecycle struct {
+	mu         sync.Mutex
+	components []component
+
+	// Количество запущенных компонентов, от начала списка
+	started int
+
+	logger logger.Logger
+}
+
+// add добавляет компонент в конец порядка запуска
+func (l *lifecycle) add(name string, start, stop func(ctx context.Context) error) {
+	l.components = append(l.components, component{name: name, start: start, stop: stop})
+}
+
+// start запускает компоненты. Если компонент не запустился или ctx завершился,
+// уже запущенные компоненты останавливаются в обратном порядке.
+func (l *lifecycle) start(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.started < len(l.components) {
+		c := l.components[l.started]
+		err := ctx.Err()
+		if err == nil && c.start != nil {
+			err = c.start(ctx)
+		}
+		if err != nil {
+			l.logger.Error(fmt.Sprintf("Не удалось запустить компонент %s: %v", c.name, err))
+			l.stopStarted(ctx)
+			return fmt.Errorf("failed to start %s: %w", c.name, err)
+		}
+		l.started++
+		l.logger.Debug(fmt.Sprintf("Компонент %s запущен", c.name))
+	}
+	return nil
+}
+
+// stop останавливает запущенные компоненты в обратном порядке. Ошибка одного компонента
+// не прерывает остановку остальных: каждый получает возможность освободить ресурсы.
+func (l *lifecycle) stop(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stopStarted(ctx)
+}
+
+// stopStarted останавливает запущенные компоненты. Вызывается под блокировкой l.mu.
+func (l *lifecycle) stopStarted(ctx context.Context) error {
+	var errs []error
+	for l.started > 0 {
+		l.started--
+		c := l.components[l.started]
+		if c.stop == nil {
+			continue
+		}
+		if err := c.stop(ctx); err != nil {
+			l.logger.Error(fmt.Sprintf("Ошибка при остановке компонента %s: %v", c.name, err))
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+			continue
+		}
+		l.logger.Debug(fmt.Sprintf("Компонент %s остановлен", c.name))
+	}
+	return errors.Join(errs...)
+}
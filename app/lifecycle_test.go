@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestLifecycle_Order(t *testing.T) {
+	var events []string
+	l := &lifecycle{logger: logger.NewNop()}
+	for _, name := range []string{"a", "b", "c"} {
+		l.add(name, func(ctx context.Context) error {
+			events = append(events, "start "+name)
+			return nil
+		}, func(ctx context.Context) error {
+			events = append(events, "stop "+name)
+			return nil
+		})
+	}
+
+	if err := l.start(context.Background()); err != nil {
+		t.Fatalf("запуск не должен завершаться ошибкой: %v", err)
+	}
+	if err := l.stop(context.Background()); err != nil {
+		t.Fatalf("остановка не должна завершаться ошибкой: %v", err)
+	}
+	if err := l.stop(context.Background()); err != nil {
+		t.Fatalf("повторная остановка не должна завершаться ошибкой: %v", err)
+	}
+
+	want := []string{"start a", "start b", "start c", "stop c", "stop b", "stop a"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("компоненты должны останавливаться в обратном порядке: %v, ожидалось %v", events, want)
+	}
+}
+
+func TestLifecycle_StartFailure(t *testing.T) {
+	var events []string
+	failure := errors.New("bind failed")
+	l := &lifecycle{logger: logger.NewNop()}
+	l.add("a", nil, func(ctx context.Context) error {
+		events = append(events, "stop a")
+		return nil
+	})
+	l.add("b", func(ctx context.Context) error {
+		return failure
+	}, func(ctx context.Context) error {
+		events = append(events, "stop b")
+		return nil
+	})
+	l.add("c", func(ctx context.Context) error {
+		events = append(events, "start c")
+		return nil
+	}, nil)
+
+	if err := l.start(context.Background()); !errors.Is(err, failure) {
+		t.Fatalf("ожидалась ошибка запуска компонента b, получено %v", err)
+	}
+	// Незапущенный компонент не останавливается, следующие не запускаются
+	if want := []string{"stop a"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("при ошибке запуска должны останавливаться только запущенные компоненты: %v", events)
+	}
+}
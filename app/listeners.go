@@ -0,0 +1,175 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/transport"
+	"cloud.ru_test/pkg/logger"
+)
+
+// defaultListenerName имя listener'а на порту по умолчанию
+const defaultListenerName = "default"
+
+// listener сервер прокси на одном адресе со своей цепочкой middleware и своим прокси
+type listener struct {
+	cfg    config.ListenerConfig
+	server *transport.Server
+
+	// Собственная цепочка middleware; nil — используются middleware верхнего уровня
+	chain *transport.Chain
+	proxy *transport.Proxy
+}
+
+// listenerConfigs возвращает listener'ы конфигурации или единственный listener
+// на порту по умолчанию, если секция listeners не задана
+func listenerConfigs(cfg *config.Config, port string) []config.ListenerConfig {
+	if len(cfg.Listeners) > 0 {
+		return cfg.Listeners
+	}
+	return []config.ListenerConfig{{Name: defaultListenerName, Address: port}}
+}
+
+// startListeners создает серверы listener'ов и, если bind, занимает их адреса, при
+// reusePort — с SO_REUSEPORT. Если какой-либо адрес занять не удалось, уже запущенные
+// серверы останавливаются.
+func startListeners(cfgs []config.ListenerConfig, reusePort, bind bool, appLogger logger.Logger) ([]*listener, error) {
+	listeners := make([]*listener, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		l := &listener{cfg: cfg, server: transport.NewServer(appLogger)}
+		l.server.SetReusePort(reusePort)
+		l.server.SetSlowClient(cfg.SlowClient)
+		l.server.SetConnectionLimits(cfg.Connections)
+		l.server.SetSniff(cfg.Sniff)
+
+		if bind {
+			if err := l.bind(appLogger); err != nil {
+				stopListeners(context.Background(), listeners, appLogger)
+				return nil, err
+			}
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// bind занимает адрес listener'а
+func (l *listener) bind(appLogger logger.Logger) error {
+	var err error
+	if l.cfg.TLS != nil {
+		err = l.server.StartTLS(l.cfg.Address, l.cfg.TLS)
+	} else {
+		err = l.server.Start(l.cfg.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("listener %s: %w", l.cfg.Name, err)
+	}
+
+	appLogger.Info(fmt.Sprintf("Listener %s слушает %s (TLS: %t, sniff: %t)", l.cfg.Name, l.cfg.Address, l.cfg.TLS != nil, l.cfg.Sniff != nil))
+	return nil
+}
+
+// bindListeners занимает адреса listener'ов, созданных без привязки. Если какой-либо
+// адрес занять не удалось, уже занятые адреса освобождаются.
+func bindListeners(listeners []*listener, appLogger logger.Logger) error {
+	for i, l := range listeners {
+		if err := l.bind(appLogger); err != nil {
+			releaseListeners(listeners[:i], appLogger)
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseListeners освобождает адреса listener'ов. Открытые соединения обслуживаются
+// до их закрытия клиентом или остановки приложения.
+func releaseListeners(listeners []*listener, appLogger logger.Logger) {
+	for _, l := range listeners {
+		if err := l.server.Release(); err != nil {
+			appLogger.Error(fmt.Sprintf("Ошибка при освобождении адреса listener'а %s: %v", l.cfg.Name, err))
+			continue
+		}
+		appLogger.Info(fmt.Sprintf("Listener %s освободил адрес %s", l.cfg.Name, l.cfg.Address))
+	}
+}
+
+// stopListeners останавливает серверы listener'ов, дожидаясь завершения текущих запросов
+// до окончания ctx. Серверы останавливаются одновременно, чтобы ни один не принимал
+// новые соединения, пока другие завершают запросы.
+func stopListeners(ctx context.Context, listeners []*listener, appLogger logger.Logger) error {
+	errs := make([]error, len(listeners))
+	var wg sync.WaitGroup
+	for i, l := range listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.server.Shutdown(ctx); err != nil {
+				appLogger.Error(fmt.Sprintf("Ошибка при остановке listener'а %s: %v", l.cfg.Name, err))
+				errs[i] = fmt.Errorf("listener %s: %w", l.cfg.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// sameBindings проверяет, что listener'ы новой конфигурации занимают те же адреса
+// с теми же настройками TLS, определения протокола и защиты от медленных клиентов:
+// иные изменения требуют перезапуска приложения
+func sameBindings(listeners []*listener, cfgs []config.ListenerConfig) bool {
+	if len(listeners) != len(cfgs) {
+		return false
+	}
+	for i, l := range listeners {
+		if l.cfg.Name != cfgs[i].Name || l.cfg.Address != cfgs[i].Address || !reflect.DeepEqual(l.cfg.TLS, cfgs[i].TLS) ||
+			!reflect.DeepEqual(l.cfg.Sniff, cfgs[i].Sniff) || !reflect.DeepEqual(l.cfg.SlowClient, cfgs[i].SlowClient) {
+			return false
+		}
+	}
+	return true
+}
+
+// update возвращает настройки listener'а из новой конфигурации. Адрес, TLS, определение
+// протокола и защита от медленных клиентов работающего listener'а не меняются; listener,
+// удаленный из конфигурации, сохраняет прежние настройки.
+func (l *listener) update(cfgs []config.ListenerConfig) config.ListenerConfig {
+	for _, cfg := range cfgs {
+		if cfg.Name == l.cfg.Name {
+			cfg.Address = l.cfg.Address
+			cfg.TLS = l.cfg.TLS
+			cfg.Sniff = l.cfg.Sniff
+			cfg.SlowClient = l.cfg.SlowClient
+			return cfg
+		}
+	}
+	return l.cfg
+}
+
+// httpsPort возвращает порт перенаправления listener'а или 0, если перенаправление отключено
+func (l *listener) httpsPort() int {
+	if !l.cfg.RedirectToHTTPS {
+		return 0
+	}
+	if l.cfg.HTTPSPort == 0 {
+		// TLS listener с sniff перенаправляет на свой же порт
+		if l.cfg.TLS != nil && l.cfg.Sniff != nil {
+			addr := l.server.Addr()
+			if addr == "" {
+				addr = l.cfg.Address
+			}
+			if _, port, err := net.SplitHostPort(addr); err == nil {
+				if n, err := strconv.Atoi(port); err == nil {
+					return n
+				}
+			}
+		}
+		return 443
+	}
+	return l.cfg.HTTPSPort
+}
@@ -0,0 +1,196 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/upgrade"
+	"cloud.ru_test/pkg/logger"
+)
+
+// workerEnv переменная окружения с номером рабочего процесса (начиная с 1)
+const workerEnv = "LB_WORKER_ID"
+
+// Параметры управления рабочими процессами
+const (
+	workerRestartDelay = time.Second
+	workerStopTimeout  = 35 * time.Second
+)
+
+// workerID возвращает номер текущего рабочего процесса или 0, если процесс запущен напрямую
+func workerID() int {
+	id, err := strconv.Atoi(os.Getenv(workerEnv))
+	if err != nil || id < 0 {
+		return 0
+	}
+	return id
+}
+
+// reusePort проверяет, нужно ли занимать адреса с SO_REUSEPORT
+func reusePort(cfg *config.Config) bool {
+	return cfg.Process != nil && (cfg.Process.ReusePort || cfg.Process.Workers > 0)
+}
+
+// supervisor запускает рабочие процессы, перезапускает завершившиеся аварийно
+// и передает им сигналы
+type supervisor struct {
+	executable string
+	args       []string
+	logger     logger.Logger
+
+	mu       sync.Mutex
+	workers  map[int]*exec.Cmd
+	stopping bool
+	wg       sync.WaitGroup
+}
+
+// runWorkers запускает count рабочих процессов и управляет ими до сигнала завершения
+func runWorkers(count int, appLogger logger.Logger) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	s := &supervisor{
+		executable: executable,
+		args:       os.Args[1:],
+		logger:     appLogger,
+		workers:    make(map[int]*exec.Cmd, count),
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP)
+	if upgrade.Signal != nil {
+		signal.Notify(sigChan, upgrade.Signal)
+	}
+
+	for id := 1; id <= count; id++ {
+		if err := s.start(id); err != nil {
+			s.stop()
+			return err
+		}
+	}
+	appLogger.Info(fmt.Sprintf("Запущено рабочих процессов: %d (pid управляющего процесса: %d)", count, os.Getpid()))
+
+	// SIGHUP передается рабочим процессам: каждый перечитывает конфигурацию сам.
+	// Передача сокетов не поддерживается: новая версия занимает порты с SO_REUSEPORT.
+	sig := <-sigChan
+	for sig == syscall.SIGHUP || (upgrade.Signal != nil && sig == upgrade.Signal) {
+		if sig == syscall.SIGHUP {
+			appLogger.Info("Получен сигнал SIGHUP, передаем его рабочим процессам")
+			s.signal(syscall.SIGHUP)
+		} else {
+			appLogger.Warn("Передача сокетов не поддерживается в режиме рабочих процессов: запустите новую версию рядом, порты заняты с SO_REUSEPORT")
+		}
+		sig = <-sigChan
+	}
+	appLogger.Info(fmt.Sprintf("Получен сигнал завершения работы: %v", sig))
+
+	return s.stop()
+}
+
+// start запускает рабочий процесс с номером id
+func (s *supervisor) start(id int) error {
+	cmd := exec.Command(s.executable, s.args...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%d", workerEnv, id))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	setWorkerAttributes(cmd)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopping {
+		return nil
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start worker %d: %w", id, err)
+	}
+	s.workers[id] = cmd
+	s.logger.Info(fmt.Sprintf("Рабочий процесс %d запущен (pid: %d)", id, cmd.Process.Pid))
+
+	s.wg.Add(1)
+	go s.wait(id, cmd)
+	return nil
+}
+
+// wait дожидается завершения рабочего процесса и перезапускает его, если
+// управляющий процесс не завершает работу
+func (s *supervisor) wait(id int, cmd *exec.Cmd) {
+	defer s.wg.Done()
+	err := cmd.Wait()
+
+	s.mu.Lock()
+	stopping := s.stopping
+	delete(s.workers, id)
+	s.mu.Unlock()
+	if stopping {
+		return
+	}
+
+	s.logger.Error(fmt.Sprintf("Рабочий процесс %d (pid: %d) завершился: %v, перезапуск через %v", id, cmd.Process.Pid, err, workerRestartDelay))
+	time.Sleep(workerRestartDelay)
+	if err := s.start(id); err != nil {
+		s.logger.Error(fmt.Sprintf("Не удалось перезапустить рабочий процесс %d: %v", id, err))
+	}
+}
+
+// signal передает сигнал всем рабочим процессам
+func (s *supervisor) signal(sig os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, cmd := range s.workers {
+		if err := cmd.Process.Signal(sig); err != nil {
+			s.logger.Warn(fmt.Sprintf("Не удалось передать сигнал %v рабочему процессу %d: %v", sig, id, err))
+		}
+	}
+}
+
+// stop завершает рабочие процессы: каждый выполняет graceful shutdown,
+// а не успевшие за workerStopTimeout завершаются принудительно
+func (s *supervisor) stop() error {
+	s.mu.Lock()
+	s.stopping = true
+	s.mu.Unlock()
+	s.signal(syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.logger.Info("Рабочие процессы завершены")
+		return nil
+	case <-time.After(workerStopTimeout):
+		s.signal(syscall.SIGKILL)
+		<-done
+		return fmt.Errorf("workers did not stop within %v", workerStopTimeout)
+	}
+}
+
+// workerTags добавляет к метрикам рабочего процесса тег worker: Graphite и OTLP получают
+// накопленные значения, и без тега значения процессов заменяли бы друг друга
+func workerTags(cfg config.MetricsPushConfig) config.MetricsPushConfig {
+	id := workerID()
+	if id == 0 || cfg.Type == "statsd" || cfg.Type == "dogstatsd" {
+		return cfg
+	}
+	if _, ok := cfg.Tags["worker"]; ok {
+		return cfg
+	}
+	tags := map[string]string{"worker": strconv.Itoa(id)}
+	for name, value := range cfg.Tags {
+		tags[name] = value
+	}
+	cfg.Tags = tags
+	return cfg
+}
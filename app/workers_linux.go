@@ -0,0 +1,13 @@
+//go:build linux
+
+package app
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setWorkerAttributes завершает рабочий процесс, если управляющий процесс аварийно остановлен
+func setWorkerAttributes(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package app
+
+import "os/exec"
+
+// setWorkerAttributes не задает дополнительных атрибутов на этой платформе
+func setWorkerAttributes(cmd *exec.Cmd) {}
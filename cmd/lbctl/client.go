@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// client HTTP клиент административного API
+type client struct {
+	addr     string
+	token    string
+	user     string
+	password string
+	http     *http.Client
+}
+
+// newClient создает клиент административного API
+func newClient(addr, token, credentials string) *client {
+	c := &client{
+		addr:  strings.TrimRight(addr, "/"),
+		token: token,
+		http:  &http.Client{Timeout: 30 * time.Second},
+	}
+	if credentials != "" {
+		c.user, c.password, _ = strings.Cut(credentials, ":")
+	}
+	return c
+}
+
+// do выполняет запрос и возвращает тело ответа. Статусы 4xx/5xx превращаются в ошибку.
+func (c *client) do(method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	return c.doAllowing(method, path, body, contentType, 0)
+}
+
+// doDecode выполняет запрос и декодирует JSON ответ, считая статус allowed успешным
+func (c *client) doDecode(method, path string, body io.Reader, allowed int, result interface{}) error {
+	resp, err := c.doAllowing(method, path, body, "", allowed)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// doAllowing выполняет запрос. Статусы 4xx/5xx, кроме allowed, превращаются в ошибку.
+func (c *client) doAllowing(method, path string, body io.Reader, contentType string, allowed int) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.addr+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to admin API failed: %w", err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest && resp.StatusCode != allowed {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	return resp, nil
+}
+
+// doJSON отправляет запрос с телом в JSON и декодирует ответ в result (если он не nil)
+func (c *client) doJSON(method, path string, request, result interface{}) error {
+	var body io.Reader
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	resp, err := c.do(method, path, body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
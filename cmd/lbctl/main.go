@@ -0,0 +1,524 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/models"
+)
+
+const usage = `lbctl — клиент административного API балансировщика
+
+Использование:
+  lbctl [флаги] <команда> [аргументы]
+
+Команды:
+  backends                               список бэкендов со статистикой
+  backend add <id> <url> [weight]        добавить бэкенд
+  backend remove <id> [drain]            удалить бэкенд (drain, например 30s, — дождаться завершения соединений)
+  backend drain <id>                     вывести бэкенд на обслуживание
+  backend undrain <id>                   вернуть бэкенд в ротацию
+  backend weight <id> <weight>           изменить вес бэкенда
+  backend probe <id>                     выполнить внеочередную проверку бэкенда
+  ratelimit get <user>                   получить лимиты пользователя
+  ratelimit set <user> <rate> <burst>    создать или обновить лимиты пользователя
+  ratelimit delete <user>                удалить лимиты пользователя
+  ratelimit export [file]                выгрузить состояние rate limiter (по умолчанию в stdout)
+  ratelimit import <file|->              загрузить состояние rate limiter, выгруженное другим экземпляром
+  config show                            показать действующую конфигурацию
+  config validate <file>                 проверить файл конфигурации без применения
+  config reload                          перечитать конфигурацию
+  config history                         показать историю примененных конфигураций
+  config rollback <version>              откатить конфигурацию к версии из истории
+  debug status                           показать состояние отладки маршрутизации
+  debug on [duration]                    включить отладку маршрутизации для всех запросов
+  debug off                              отключить отладку маршрутизации
+  debug token [ttl]                      выпустить токен отладки маршрутизации для отдельных запросов
+  logs [lines]                           показать последние строки лога и следить за новыми
+  health                                 проверить готовность прокси
+
+Флаги:
+`
+
+// backendInfo состояние бэкенда в ответах административного API
+type backendInfo struct {
+	ID                string  `json:"id"`
+	URL               string  `json:"url"`
+	Weight            float64 `json:"weight"`
+	Alive             bool    `json:"alive"`
+	Maintenance       bool    `json:"maintenance"`
+	Ejected           bool    `json:"ejected"`
+	Draining          bool    `json:"draining"`
+	ActiveConnections int64   `json:"activeConnections"`
+	AvgResponseTimeMs int64   `json:"avgResponseTimeMs"`
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	SuccessRate       float64 `json:"successRate"`
+}
+
+// probeResult результат внеочередной проверки бэкенда
+type probeResult struct {
+	URL        string `json:"url"`
+	Healthy    bool   `json:"healthy"`
+	StatusCode int    `json:"statusCode"`
+	LatencyMs  int64  `json:"latencyMs"`
+	Body       string `json:"body"`
+	Error      string `json:"error"`
+}
+
+// validationResult результат проверки конфигурации
+type validationResult struct {
+	Valid  bool                `json:"valid"`
+	Error  string              `json:"error"`
+	Errors []config.FieldError `json:"errors"`
+}
+
+// configVersion версия конфигурации из истории
+type configVersion struct {
+	Version        int       `json:"version"`
+	AppliedAt      time.Time `json:"appliedAt"`
+	Source         string    `json:"source"`
+	RolledBackFrom int       `json:"rolledBackFrom"`
+}
+
+var (
+	addr    = flag.String("addr", envOrDefault("LBCTL_ADDR", "http://localhost:9090"), "адрес административного API")
+	token   = flag.String("token", os.Getenv("LBCTL_TOKEN"), "bearer токен")
+	user    = flag.String("user", os.Getenv("LBCTL_USER"), "учетные данные basic auth в формате user:password")
+	persist = flag.Bool("persist", false, "сохранить изменения бэкендов в файл конфигурации")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := newClient(*addr, *token, *user)
+	if err := run(c, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "lbctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run выполняет команду
+func run(c *client, args []string) error {
+	switch args[0] {
+	case "backends":
+		return listBackends(c)
+	case "backend":
+		return runBackend(c, args[1:])
+	case "ratelimit":
+		return runRateLimit(c, args[1:])
+	case "config":
+		return runConfig(c, args[1:])
+	case "debug":
+		return runDebug(c, args[1:])
+	case "logs":
+		lines := "50"
+		if len(args) > 1 {
+			lines = args[1]
+		}
+		return tailLogs(c, lines)
+	case "health":
+		return health(c)
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
+
+// listBackends выводит таблицу бэкендов
+func listBackends(c *client) error {
+	var backends []backendInfo
+	if err := c.doJSON("GET", "/admin/backends", nil, &backends); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tURL\tWEIGHT\tSTATE\tACTIVE\tRPS\tLATENCY\tSUCCESS")
+	for _, b := range backends {
+		state := "up"
+		switch {
+		case b.Maintenance:
+			state = "maintenance"
+		case !b.Alive:
+			state = "down"
+		case b.Draining:
+			state = "draining"
+		case b.Ejected:
+			state = "ejected"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\t%d\t%.1f\t%dms\t%.0f%%\n",
+			b.ID, b.URL, b.Weight, state, b.ActiveConnections, b.RequestsPerSecond, b.AvgResponseTimeMs, b.SuccessRate*100)
+	}
+	return w.Flush()
+}
+
+// runBackend выполняет команды управления бэкендом
+func runBackend(c *client, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: lbctl backend <add|remove|drain|undrain|weight|probe> <id> ...")
+	}
+	action, id := args[0], args[1]
+	path := "/admin/backends/" + url.PathEscape(id) + persistQuery()
+
+	switch action {
+	case "add":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: lbctl backend add <id> <url> [weight]")
+		}
+		request := map[string]interface{}{"id": id, "url": args[2]}
+		if len(args) > 3 {
+			weight, err := strconv.ParseFloat(args[3], 64)
+			if err != nil {
+				return fmt.Errorf("invalid weight: %w", err)
+			}
+			request["weight"] = weight
+		}
+		return c.doJSON("POST", "/admin/backends"+persistQuery(), request, nil)
+	case "remove":
+		if len(args) > 2 {
+			path = appendQuery(path, "drain="+url.QueryEscape(args[2]))
+		}
+		return c.doJSON("DELETE", path, nil, nil)
+	case "drain", "undrain":
+		return c.doJSON("PATCH", path, map[string]bool{"maintenance": action == "drain"}, nil)
+	case "weight":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: lbctl backend weight <id> <weight>")
+		}
+		weight, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return fmt.Errorf("invalid weight: %w", err)
+		}
+		return c.doJSON("PATCH", path, map[string]float64{"weight": weight}, nil)
+	case "probe":
+		var result probeResult
+		if err := c.doJSON("POST", "/admin/backends/"+url.PathEscape(id)+"/probe", nil, &result); err != nil {
+			return err
+		}
+		state := "healthy"
+		if !result.Healthy {
+			state = "unhealthy"
+		}
+		fmt.Printf("%s: %s (%s, %dms)\n", id, state, result.URL, result.LatencyMs)
+		if result.StatusCode != 0 {
+			fmt.Printf("status: %d\n", result.StatusCode)
+		}
+		if result.Error != "" {
+			fmt.Printf("error: %s\n", result.Error)
+		}
+		if result.Body != "" {
+			fmt.Printf("body: %s\n", result.Body)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown backend action: %s", action)
+	}
+}
+
+// runRateLimit выполняет команды управления лимитами пользователей
+func runRateLimit(c *client, args []string) error {
+	if len(args) > 0 && (args[0] == "export" || args[0] == "import") {
+		return transferRateLimitState(c, args[0], args[1:])
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("usage: lbctl ratelimit <get|set|delete> <user> ... | <export|import> [file]")
+	}
+	action, path := args[0], "/ratelimit/"+url.PathEscape(args[1])
+
+	switch action {
+	case "get":
+		var limits models.UserRateLimit
+		if err := c.doJSON("GET", path, nil, &limits); err != nil {
+			return err
+		}
+		fmt.Printf("rate: %.2f\nburst: %d\n", limits.Rate, limits.Burst)
+		return nil
+	case "set":
+		if len(args) < 4 {
+			return fmt.Errorf("usage: lbctl ratelimit set <user> <rate> <burst>")
+		}
+		rate, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return fmt.Errorf("invalid rate: %w", err)
+		}
+		burst, err := strconv.Atoi(args[3])
+		if err != nil {
+			return fmt.Errorf("invalid burst: %w", err)
+		}
+		limits := models.UserRateLimit{Rate: rate, Burst: burst}
+
+		// Создаем лимиты, а если они уже существуют — обновляем
+		if err := c.doJSON("POST", path, limits, nil); err == nil {
+			return nil
+		}
+		return c.doJSON("PUT", path, limits, nil)
+	case "delete":
+		return c.doJSON("DELETE", path, nil, nil)
+	default:
+		return fmt.Errorf("unknown ratelimit action: %s", action)
+	}
+}
+
+// transferRateLimitState выгружает состояние rate limiter в файл или загружает его из файла.
+// Файл "-" или его отсутствие при выгрузке означает стандартный поток.
+func transferRateLimitState(c *client, action string, args []string) error {
+	const path = "/admin/ratelimit/state"
+
+	if action == "export" {
+		resp, err := c.do("GET", path, nil, "")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		out := io.Writer(os.Stdout)
+		if len(args) > 0 && args[0] != "-" {
+			f, err := os.Create(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		_, err = io.Copy(out, resp.Body)
+		return err
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("usage: lbctl ratelimit import <file|->")
+	}
+	in := io.Reader(os.Stdin)
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	resp, err := c.do("PUT", path, in, "application/json")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// runConfig выполняет команды работы с конфигурацией
+func runConfig(c *client, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: lbctl config <show|validate|reload|history|rollback> ...")
+	}
+
+	switch args[0] {
+	case "show":
+		resp, err := c.do("GET", "/admin/config", nil, "")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, err = io.Copy(os.Stdout, resp.Body)
+		return err
+	case "validate":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: lbctl config validate <file>")
+		}
+		data, err := os.ReadFile(args[1])
+		if err != nil {
+			return fmt.Errorf("error reading config file: %w", err)
+		}
+		path := "/admin/config/validate"
+		if format := config.DetectFormat(args[1]); format != "" {
+			path += "?format=" + format
+		}
+		// Статус 422 означает, что конфигурация некорректна, а в теле перечислены проблемы
+		var result validationResult
+		if err := c.doDecode("POST", path, bytes.NewReader(data), http.StatusUnprocessableEntity, &result); err != nil {
+			return err
+		}
+		if result.Valid {
+			fmt.Println("config is valid")
+			return nil
+		}
+		for _, e := range result.Errors {
+			fmt.Println(e.Error())
+		}
+		if len(result.Errors) == 0 {
+			fmt.Println(result.Error)
+		}
+		return fmt.Errorf("config is invalid")
+	case "reload":
+		var result struct {
+			Changed bool `json:"changed"`
+			Version int  `json:"version"`
+		}
+		if err := c.doJSON("POST", "/admin/reload", nil, &result); err != nil {
+			return err
+		}
+		if result.Changed {
+			fmt.Printf("config reloaded (version %d)\n", result.Version)
+		} else {
+			fmt.Printf("config unchanged (version %d)\n", result.Version)
+		}
+		return nil
+	case "history":
+		var versions []configVersion
+		if err := c.doJSON("GET", "/admin/config/history", nil, &versions); err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tAPPLIED\tSOURCE")
+		for _, v := range versions {
+			source := v.Source
+			if v.RolledBackFrom != 0 {
+				source = fmt.Sprintf("%s (from %d)", source, v.RolledBackFrom)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", v.Version, v.AppliedAt.Format(time.RFC3339), source)
+		}
+		return w.Flush()
+	case "rollback":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: lbctl config rollback <version>")
+		}
+		var applied configVersion
+		if err := c.doJSON("POST", "/admin/config/rollback?version="+url.QueryEscape(args[1]), nil, &applied); err != nil {
+			return err
+		}
+		fmt.Printf("rolled back to version %s (new version %d)\n", args[1], applied.Version)
+		return nil
+	default:
+		return fmt.Errorf("unknown config action: %s", args[0])
+	}
+}
+
+// debugStatus состояние отладки маршрутизации
+type debugStatus struct {
+	Enabled       bool       `json:"enabled"`
+	Until         *time.Time `json:"until"`
+	Header        string     `json:"header"`
+	TokensEnabled bool       `json:"tokensEnabled"`
+}
+
+// runDebug выполняет команды отладки маршрутизации
+func runDebug(c *client, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: lbctl debug <status|on|off|token> ...")
+	}
+
+	var status debugStatus
+	switch args[0] {
+	case "status":
+		if err := c.doJSON("GET", "/admin/debug", nil, &status); err != nil {
+			return err
+		}
+	case "on", "off":
+		req := map[string]interface{}{"enabled": args[0] == "on"}
+		if args[0] == "on" && len(args) > 1 {
+			req["duration"] = args[1]
+		}
+		if err := c.doJSON("PUT", "/admin/debug", req, &status); err != nil {
+			return err
+		}
+	case "token":
+		req := map[string]string{}
+		if len(args) > 1 {
+			req["ttl"] = args[1]
+		}
+		var token struct {
+			Header    string    `json:"header"`
+			Token     string    `json:"token"`
+			ExpiresAt time.Time `json:"expiresAt"`
+		}
+		if err := c.doJSON("POST", "/admin/debug/token", req, &token); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %s\nexpires: %s\n", token.Header, token.Token, token.ExpiresAt.Local().Format(time.RFC3339))
+		return nil
+	default:
+		return fmt.Errorf("unknown debug action: %s", args[0])
+	}
+
+	switch {
+	case status.Enabled && status.Until != nil:
+		fmt.Printf("enabled for all requests until %s\n", status.Until.Local().Format(time.RFC3339))
+	case status.Enabled:
+		fmt.Println("enabled for all requests")
+	default:
+		fmt.Println("disabled for all requests")
+	}
+	if status.TokensEnabled {
+		fmt.Printf("tokens: header %s\n", status.Header)
+	} else {
+		fmt.Println("tokens: disabled (routingDebug.secret is not set)")
+	}
+	return nil
+}
+
+// tailLogs выводит последние строки лога и следит за новыми
+func tailLogs(c *client, lines string) error {
+	// У потокового ответа нет ограничения по времени
+	c.http.Timeout = 0
+
+	resp, err := c.do("GET", "/admin/logs?follow=true&lines="+url.QueryEscape(lines), nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(os.Stdout, resp.Body)
+	return err
+}
+
+// health выводит состояние готовности прокси
+func health(c *client) error {
+	resp, err := c.do("GET", "/readyz", nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(os.Stdout, resp.Body)
+	return err
+}
+
+// persistQuery возвращает параметр сохранения изменений в файл конфигурации
+func persistQuery() string {
+	if *persist {
+		return "?persist=true"
+	}
+	return ""
+}
+
+// appendQuery добавляет параметр к пути запроса
+func appendQuery(path, param string) string {
+	if strings.Contains(path, "?") {
+		return path + "&" + param
+	}
+	return path + "?" + param
+}
+
+// envOrDefault возвращает значение переменной окружения или значение по умолчанию
+func envOrDefault(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"cloud.ru_test/internal/loadgen"
+)
+
+const usage = `loadgen — нагрузочное тестирование балансировщика
+
+Использование:
+  loadgen [флаги]                       нагрузка на работающий прокси (-url)
+  loadgen -methods RoundRobin,...       сравнение алгоритмов балансировки: для каждого
+                                        алгоритма запускается встроенный прокси перед -backends
+
+Набор запросов (-mix) — запросы через запятую с необязательным весом:
+  "8:GET /,2:POST /api/orders"
+
+Флаги:
+`
+
+var (
+	target      = flag.String("url", "http://localhost:8080", "адрес прокси")
+	methods     = flag.String("methods", "", "алгоритмы балансировки через запятую (RoundRobin, WeightedRoundRobin, LeastConnections)")
+	backends    = flag.String("backends", "http://localhost:8081,http://localhost:8082,http://localhost:8083", "бэкенды встроенного прокси (по умолчанию — эхо серверы testserver)")
+	rps         = flag.Float64("rps", 0, "запросов в секунду (0 — без ограничения)")
+	concurrency = flag.Int("c", 50, "количество одновременных клиентов")
+	duration    = flag.Duration("d", 10*time.Second, "продолжительность нагрузки для каждой цели")
+	requests    = flag.Int("n", 0, "количество запросов для каждой цели (0 — ограничено только -d)")
+	mix         = flag.String("mix", "GET /", "набор запросов")
+	bodySize    = flag.Int("body", 0, "размер тела запросов POST, PUT и PATCH в байтах")
+	timeout     = flag.Duration("timeout", 10*time.Second, "таймаут запроса")
+	maxP99      = flag.Duration("max-p99", 0, "завершиться с ошибкой, если p99 задержки выше (0 — не проверять)")
+	maxErrors   = flag.Float64("max-errors", -1, "завершиться с ошибкой, если доля ошибок выше, например 0.01 (-1 — не проверять)")
+)
+
+// result результаты нагрузки на одну цель
+type result struct {
+	name   string
+	report *loadgen.Report
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "loadgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run подает нагрузку на каждую цель, выводит результаты и проверяет пороги
+func run() error {
+	requestMix, err := loadgen.ParseMix(*mix)
+	if err != nil {
+		return err
+	}
+	opts := loadgen.Options{
+		Rate:        *rps,
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Requests:    *requests,
+		Mix:         requestMix,
+		BodySize:    *bodySize,
+		Timeout:     *timeout,
+	}
+	if *requests > 0 {
+		opts.Duration = 0
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var results []result
+	if *methods == "" {
+		opts.URL = strings.TrimSuffix(*target, "/")
+		fmt.Fprintf(os.Stderr, "Нагрузка на %s...\n", opts.URL)
+		report, err := loadgen.Run(ctx, opts)
+		if err != nil {
+			return err
+		}
+		results = append(results, result{name: opts.URL, report: report})
+	} else {
+		backendURLs := strings.Split(*backends, ",")
+		for _, method := range strings.Split(*methods, ",") {
+			method = strings.TrimSpace(method)
+			report, err := runMethod(ctx, method, backendURLs, opts)
+			if err != nil {
+				return fmt.Errorf("%s: %w", method, err)
+			}
+			results = append(results, result{name: method, report: report})
+			if ctx.Err() != nil {
+				break
+			}
+		}
+	}
+
+	if err := printResults(results); err != nil {
+		return err
+	}
+	return checkThresholds(results)
+}
+
+// runMethod подает нагрузку на встроенный прокси с алгоритмом балансировки method
+func runMethod(ctx context.Context, method string, backendURLs []string, opts loadgen.Options) (*loadgen.Report, error) {
+	proxy, err := loadgen.StartProxy(method, backendURLs)
+	if err != nil {
+		return nil, err
+	}
+	defer proxy.Close()
+
+	fmt.Fprintf(os.Stderr, "Нагрузка на прокси с алгоритмом %s...\n", method)
+	opts.URL = proxy.URL
+	return loadgen.Run(ctx, opts)
+}
+
+// printResults выводит таблицу задержек и статусов ответов
+func printResults(results []result) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tREQUESTS\tRPS\tERRORS\tMEAN\tP50\tP90\tP99\tP99.9\tMAX\tSTATUSES")
+	for _, r := range results {
+		latency := r.report.Latency
+		fmt.Fprintf(w, "%s\t%d\t%.1f\t%.2f%%\t%v\t%v\t%v\t%v\t%v\t%v\t%s\n",
+			r.name, r.report.Requests+r.report.Errors, r.report.RPS(), r.report.ErrorRate()*100,
+			round(latency.Mean), round(latency.P50), round(latency.P90), round(latency.P99), round(latency.P999), round(latency.Max),
+			statuses(r.report))
+	}
+	return w.Flush()
+}
+
+// statuses возвращает количество ответов по статусам и ошибок соединения
+func statuses(report *loadgen.Report) string {
+	codes := make([]int, 0, len(report.Statuses))
+	for code := range report.Statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	parts := make([]string, 0, len(codes)+1)
+	for _, code := range codes {
+		parts = append(parts, fmt.Sprintf("%d=%d", code, report.Statuses[code]))
+	}
+	if report.Errors > 0 {
+		parts = append(parts, fmt.Sprintf("errors=%d", report.Errors))
+	}
+	return strings.Join(parts, " ")
+}
+
+// round округляет задержку для вывода
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
+
+// checkThresholds проверяет пороги -max-p99 и -max-errors
+func checkThresholds(results []result) error {
+	var violations []string
+	for _, r := range results {
+		if *maxP99 > 0 && r.report.Latency.P99 > *maxP99 {
+			violations = append(violations, fmt.Sprintf("%s: p99 %v > %v", r.name, round(r.report.Latency.P99), *maxP99))
+		}
+		if *maxErrors >= 0 && r.report.ErrorRate() > *maxErrors {
+			violations = append(violations, fmt.Sprintf("%s: error rate %.4f > %.4f", r.name, r.report.ErrorRate(), *maxErrors))
+		}
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("thresholds exceeded: %s", strings.Join(violations, "; "))
+	}
+	return nil
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"flag"
+
 	"cloud.ru_test/app"
 )
 
 func main() {
-	err := app.Run("config.yaml", ":8080")
+	// Путь к файлу конфигурации (yaml, json, toml), каталогу фрагментов
+	// или адрес внешнего источника (consul://host:8500/key, etcd://host:2379/key)
+	configPath := flag.String("config", "config.yaml", "путь к файлу или каталогу конфигурации, либо адрес consul:// или etcd://")
+	flag.Parse()
+
+	err := app.Run(*configPath, ":8080")
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"cloud.ru_test/pkg/capture"
+)
+
+const usage = `replay — воспроизведение запросов, записанных middleware capture
+
+Использование:
+  replay -target http://staging:8080 [флаги] <файл или каталог>...
+
+Флаги:
+`
+
+var (
+	target      = flag.String("target", "", "адрес, на который отправляются запросы (бэкенд или окружение)")
+	rps         = flag.Float64("rps", 0, "запросов в секунду (0 — без ограничения)")
+	concurrency = flag.Int("c", 10, "количество одновременных запросов")
+	prefix      = flag.String("prefix", "", "воспроизводить только запросы с путем, начинающимся с префикса")
+	host        = flag.Bool("host", false, "передавать записанный заголовок Host вместо адреса -target")
+	compare     = flag.Bool("compare", false, "сравнивать статусы ответов с записанными и завершаться с ошибкой при расхождениях")
+	dryRun      = flag.Bool("dry-run", false, "только вывести запросы, не отправляя их")
+	timeout     = flag.Duration("timeout", 30*time.Second, "таймаут запроса")
+)
+
+// summary результаты воспроизведения
+type summary struct {
+	mu         sync.Mutex
+	sent       int
+	skipped    int
+	errors     int
+	statuses   map[int]int
+	mismatches []string
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run воспроизводит записи из файлов аргументов и выводит результаты
+func run() error {
+	if *target == "" && !*dryRun {
+		return fmt.Errorf("-target is required")
+	}
+	if flag.NArg() == 0 {
+		return fmt.Errorf("no capture files specified")
+	}
+	if *concurrency <= 0 {
+		return fmt.Errorf("-c must be positive")
+	}
+
+	var files []string
+	for _, arg := range flag.Args() {
+		found, err := capture.Files(arg)
+		if err != nil {
+			return err
+		}
+		files = append(files, found...)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if *rps > 0 {
+		limiter = rate.NewLimiter(rate.Limit(*rps), 1)
+	}
+	client := &http.Client{
+		Timeout: *timeout,
+		// Редиректы возвращаются как есть, чтобы статусы совпадали с записанными
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	result := &summary{statuses: make(map[int]int)}
+	records := make(chan *capture.Record)
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rec := range records {
+				status, err := send(ctx, client, rec)
+				result.add(rec, status, err)
+			}
+		}()
+	}
+
+	var readErr error
+	for _, name := range files {
+		if readErr = readFile(ctx, name, limiter, records, result); readErr != nil {
+			break
+		}
+	}
+	close(records)
+	wg.Wait()
+
+	if readErr != nil && ctx.Err() == nil {
+		return readErr
+	}
+	if *dryRun {
+		return nil
+	}
+	if err := result.print(); err != nil {
+		return err
+	}
+	if *compare && len(result.mismatches) > 0 {
+		return fmt.Errorf("%d responses differ from captured status", len(result.mismatches))
+	}
+	return nil
+}
+
+// readFile передает записи файла обработчикам с ограничением скорости
+func readFile(ctx context.Context, name string, limiter *rate.Limiter, records chan<- *capture.Record, result *summary) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = capture.Read(file, func(rec *capture.Record) error {
+		if !strings.HasPrefix(rec.Path, *prefix) {
+			return nil
+		}
+		if rec.BodyTruncated {
+			result.skip()
+			return nil
+		}
+		if *dryRun {
+			fmt.Printf("%s %s%s\n", rec.Method, rec.Path, query(rec))
+			return nil
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+		select {
+		case records <- rec:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// send отправляет запрос записи на -target и возвращает статус ответа
+func send(ctx context.Context, client *http.Client, rec *capture.Record) (int, error) {
+	req, err := rec.NewRequest(*target)
+	if err != nil {
+		return 0, err
+	}
+	if *host && rec.Host != "" {
+		req.Host = rec.Host
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// query возвращает строку запроса записи для вывода
+func query(rec *capture.Record) string {
+	if rec.Query == "" {
+		return ""
+	}
+	return "?" + rec.Query
+}
+
+// add учитывает результат воспроизведения записи
+func (s *summary) add(rec *capture.Record, status int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.errors++
+		fmt.Fprintf(os.Stderr, "%s %s: %v\n", rec.Method, rec.Path, err)
+		return
+	}
+	s.sent++
+	s.statuses[status]++
+	if status != rec.Status {
+		s.mismatches = append(s.mismatches, fmt.Sprintf("%s %s%s: captured %d, got %d", rec.Method, rec.Path, query(rec), rec.Status, status))
+	}
+}
+
+// skip учитывает запрос, пропущенный из-за неполного тела
+func (s *summary) skip() {
+	s.mu.Lock()
+	s.skipped++
+	s.mu.Unlock()
+}
+
+// print выводит итоги и, при -compare, расхождения статусов
+func (s *summary) print() error {
+	if *compare {
+		for _, m := range s.mismatches {
+			fmt.Println(m)
+		}
+	}
+
+	codes := make([]int, 0, len(s.statuses))
+	for code := range s.statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	parts := make([]string, 0, len(codes))
+	for _, code := range codes {
+		parts = append(parts, fmt.Sprintf("%d=%d", code, s.statuses[code]))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SENT\tERRORS\tSKIPPED\tMISMATCHES\tSTATUSES")
+	fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%s\n", s.sent, s.errors, s.skipped, len(s.mismatches), strings.Join(parts, " "))
+	return w.Flush()
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"time"
+)
+
+// APIKeyAuthConfig ключи API клиентов: заданные в конфигурации и хранимые в хранилище,
+// которым управляет административное API
+type APIKeyAuthConfig struct {
+	// Заголовок с ключом (по умолчанию X-API-Key)
+	Header string `yaml:"header,omitempty"`
+
+	// Ключи клиентов, заданные в конфигурации; через административное API не изменяются
+	Keys []APIKeyConfig `yaml:"keys,omitempty"`
+
+	// Хранилище ключей, выпускаемых через административное API
+	Store *APIKeyStoreConfig `yaml:"store,omitempty"`
+}
+
+// APIKeyConfig ключ API клиента
+type APIKeyConfig struct {
+	// Имя клиента; становится пользователем запроса
+	Name string `yaml:"name"`
+
+	// Значение ключа
+	Key string `yaml:"key,omitempty"`
+
+	// SHA-256 ключа в hex вместо значения, чтобы ключ не хранился в конфигурации открыто
+	Hash string `yaml:"hash,omitempty"`
+
+	// Имена маршрутов, доступных по ключу (по умолчанию все маршруты с auth.type: apiKey)
+	Routes []string `yaml:"routes,omitempty"`
+
+	// Ограничение частоты запросов по ключу (по умолчанию не ограничивается)
+	RateLimit *APIKeyRateLimitConfig `yaml:"rateLimit,omitempty"`
+}
+
+// APIKeyRateLimitConfig ограничение частоты запросов по ключу
+type APIKeyRateLimitConfig struct {
+	// Запросов в секунду
+	Rate float64 `yaml:"rate"`
+
+	// Максимальный всплеск запросов
+	Burst int `yaml:"burst"`
+}
+
+// APIKeyStoreConfig хранилище ключей API. В хранилище записываются только SHA-256 ключей.
+type APIKeyStoreConfig struct {
+	// Тип хранилища: file, sqlite или redis
+	Type string `yaml:"type"`
+
+	// Путь к JSON файлу (file) или строка подключения к базе (sqlite)
+	Path string `yaml:"path,omitempty"`
+
+	// Имя драйвера database/sql для sqlite (по умолчанию sqlite — драйвер modernc.org/sqlite,
+	// входящий в прокси); другой драйвер должен быть подключен к сборке прокси
+	Driver string `yaml:"driver,omitempty"`
+
+	// Адрес Redis (host:port), пароль, номер базы и ключ хеша с ключами API (по умолчанию lb:apikeys)
+	Addr     string `yaml:"addr,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	DB       int    `yaml:"db,omitempty"`
+	Key      string `yaml:"key,omitempty"`
+
+	// Период перечитывания хранилища, чтобы ключи, выпущенные другими экземплярами,
+	// начали действовать (по умолчанию 10s)
+	RefreshInterval time.Duration `yaml:"refreshInterval,omitempty"`
+}
+
+// minAPIKeyLength минимальная длина ключа API, заданного в конфигурации
+const minAPIKeyLength = 16
+
+// validateInto проверяет ключи API; routes — имена маршрутов конфигурации
+func (a *APIKeyAuthConfig) validateInto(v *validator, field string, routes map[string]bool) {
+	if len(a.Keys) == 0 && a.Store == nil {
+		v.add(field, nil, "keys or store is required")
+	}
+
+	names := make(map[string]bool, len(a.Keys))
+	keys := make(map[string]bool, len(a.Keys))
+	for i, k := range a.Keys {
+		keyField := fmt.Sprintf("%s.keys[%d]", field, i)
+		if k.Name == "" {
+			v.add(keyField+".name", nil, "is required")
+		} else if names[k.Name] {
+			v.add(keyField+".name", k.Name, "duplicate key name")
+		}
+		names[k.Name] = true
+
+		switch {
+		case k.Key != "" && k.Hash != "":
+			v.add(keyField, nil, "only one of key and hash may be set")
+		case k.Key != "":
+			if len(k.Key) < minAPIKeyLength {
+				v.add(keyField+".key", nil, "must be at least %d characters", minAPIKeyLength)
+			} else if keys[k.Key] {
+				v.add(keyField+".key", nil, "duplicate key")
+			}
+			keys[k.Key] = true
+		case k.Hash != "":
+			if b, err := hex.DecodeString(k.Hash); err != nil || len(b) != 32 {
+				v.add(keyField+".hash", k.Hash, "must be a hex encoded SHA-256")
+			}
+		default:
+			v.add(keyField, nil, "key or hash is required")
+		}
+
+		for j, route := range k.Routes {
+			if !routes[route] {
+				v.add(fmt.Sprintf("%s.routes[%d]", keyField, j), route, "unknown route")
+			}
+		}
+		if rl := k.RateLimit; rl != nil && (rl.Rate <= 0 || rl.Burst <= 0) {
+			v.add(keyField+".rateLimit", nil, "rate and burst must be positive")
+		}
+	}
+
+	if s := a.Store; s != nil {
+		s.validateInto(v, field+".store")
+	}
+}
+
+// validateInto проверяет настройки хранилища ключей
+func (s *APIKeyStoreConfig) validateInto(v *validator, field string) {
+	switch s.Type {
+	case "file", "sqlite":
+		if s.Path == "" {
+			v.add(field+".path", nil, "is required for %s store", s.Type)
+		}
+	case "redis":
+		if _, _, err := net.SplitHostPort(s.Addr); err != nil {
+			v.add(field+".addr", s.Addr, "must be host:port")
+		}
+		if s.DB < 0 {
+			v.add(field+".db", s.DB, "must not be negative")
+		}
+	default:
+		v.add(field+".type", s.Type, "must be file, sqlite or redis")
+	}
+	if s.RefreshInterval < 0 {
+		v.add(field+".refreshInterval", s.RefreshInterval, "must not be negative")
+	}
+}
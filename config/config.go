@@ -1,8 +1,15 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -16,20 +23,183 @@ type Config struct {
 	// Список бэкендов
 	Backends []BackendConfig `yaml:"backends"`
 
+	// Источники динамического обнаружения бэкендов
+	Discovery []DiscoveryConfig `yaml:"discovery,omitempty"`
+
 	// Настройки rate limiter
 	RateLimiter *RateLimiterConfig `yaml:"rateLimiter,omitempty"`
 
+	// Дополнительные middleware конвейера обработки запросов
+	Middlewares []MiddlewareConfig `yaml:"middlewares,omitempty"`
+
+	// Внешние фильтры запросов из Go плагинов, подключаемые в секции middlewares
+	Plugins []PluginConfig `yaml:"plugins,omitempty"`
+
+	// Правила маршрутизации запросов по пути, методу, заголовкам и адресу клиента
+	Routing *RoutingConfig `yaml:"routing,omitempty"`
+
 	// Настройки логгера
 	Logger *LoggerConfig `yaml:"logger"`
+
+	// Настройки активной проверки здоровья бэкендов
+	HealthCheck *HealthCheckConfig `yaml:"healthCheck,omitempty"`
+
+	// Настройки пассивного обнаружения и исключения сбоящих бэкендов
+	OutlierDetection *OutlierDetectionConfig `yaml:"outlierDetection,omitempty"`
+
+	// Настройки защиты от перегрузки
+	Overload *OverloadConfig `yaml:"overload,omitempty"`
+
+	// Базы GeoIP для определения страны и автономной системы клиента
+	GeoIP *GeoIPConfig `yaml:"geoIP,omitempty"`
+
+	// Подсети (CIDR) или адреса прокси, от которых принимаются заголовки
+	// X-Forwarded-For и X-Real-IP с адресом клиента
+	TrustedProxies []string `yaml:"trustedProxies,omitempty"`
+
+	// Заголовки X-Forwarded-* и Forwarded в запросах к бэкендам
+	ForwardedHeaders *ForwardedHeadersConfig `yaml:"forwardedHeaders,omitempty"`
+
+	// Настройки отладки маршрутизации
+	RoutingDebug *RoutingDebugConfig `yaml:"routingDebug,omitempty"`
+
+	// Настройки журнала доступа и учета обработанных запросов
+	AccessLog *AccessLogConfig `yaml:"accessLog,omitempty"`
+
+	// Отправка метрик в системы мониторинга без Prometheus
+	MetricsPush []MetricsPushConfig `yaml:"metricsPush,omitempty"`
+
+	// Учет потребления по пользователям для биллинга
+	Usage *UsageConfig `yaml:"usage,omitempty"`
+
+	// Поток событий о запросах для систем аналитики
+	AccessEvents *AccessEventsConfig `yaml:"accessEvents,omitempty"`
+
+	// Оповещения о сбоях через webhook
+	Alerting *AlertingConfig `yaml:"alerting,omitempty"`
+
+	// Адреса, на которых прокси принимает соединения. Если список пуст,
+	// прокси слушает единственный порт по умолчанию (:8080).
+	Listeners []ListenerConfig `yaml:"listeners,omitempty"`
+
+	// Настройки SO_REUSEPORT и рабочих процессов
+	Process *ProcessConfig `yaml:"process,omitempty"`
+
+	// Режим активный/резервный: выбор лидера среди экземпляров прокси
+	HA *HAConfig `yaml:"ha,omitempty"`
+
+	// Обмен состоянием бэкендов и rate limiter'а между экземплярами прокси
+	Gossip *GossipConfig `yaml:"gossip,omitempty"`
+
+	// Настройки административного API
+	Admin *AdminConfig `yaml:"admin,omitempty"`
+
+	// Настройки истории примененных конфигураций
+	History *HistoryConfig `yaml:"history,omitempty"`
+
+	// В исходной конфигурации были подстановки ${ENV} или secret://
+	interpolated bool
+}
+
+// AdminConfig конфигурация административного API
+type AdminConfig struct {
+	// Адрес, на котором слушает административный сервер (например, ":9090")
+	Port string `yaml:"port"`
+
+	// Токен для доступа к API с правами администратора (устаревший способ, см. Auth)
+	Token string `yaml:"token,omitempty"`
+
+	// Настройки аутентификации и авторизации
+	Auth *AdminAuthConfig `yaml:"auth,omitempty"`
+
+	// Настройки TLS (в том числе mTLS)
+	TLS *AdminTLSConfig `yaml:"tls,omitempty"`
+}
+
+// AdminAuthConfig настройки доступа к административному API
+type AdminAuthConfig struct {
+	// Статические bearer токены
+	Tokens []AdminTokenConfig `yaml:"tokens,omitempty"`
+
+	// Пользователи для basic auth
+	Users []AdminUserConfig `yaml:"users,omitempty"`
+
+	// Клиентские сертификаты (mTLS), сопоставляемые по CommonName
+	ClientCerts []AdminClientCertConfig `yaml:"clientCerts,omitempty"`
+}
+
+// AdminTokenConfig bearer токен и его роль
+type AdminTokenConfig struct {
+	Token string `yaml:"token"`
+
+	// Роль: read (только чтение) или admin (чтение и изменение)
+	Role string `yaml:"role"`
+}
+
+// AdminUserConfig пользователь basic auth и его роль
+type AdminUserConfig struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Role     string `yaml:"role"`
+}
+
+// AdminClientCertConfig клиентский сертификат и его роль
+type AdminClientCertConfig struct {
+	CommonName string `yaml:"commonName"`
+	Role       string `yaml:"role"`
+}
+
+// AdminTLSConfig настройки TLS административного сервера
+type AdminTLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+
+	// CA для проверки клиентских сертификатов (включает mTLS)
+	ClientCAFile string `yaml:"clientCAFile,omitempty"`
+}
+
+// loadBalancerMethods методы балансировки, допустимые в loadBalancer.method
+var (
+	loadBalancerMethodsMu sync.RWMutex
+	loadBalancerMethods   = map[string]bool{"RoundRobin": true, "WeightedRoundRobin": true, "LeastConnections": true, "LeastLoad": true}
+)
+
+// RegisterLoadBalancerMethod разрешает в конфигурации метод балансировки с указанным именем
+func RegisterLoadBalancerMethod(name string) {
+	loadBalancerMethodsMu.Lock()
+	defer loadBalancerMethodsMu.Unlock()
+	loadBalancerMethods[name] = true
+}
+
+// rateLimiterTypes типы rate limiter, допустимые в rateLimiter.type
+var (
+	rateLimiterTypesMu sync.RWMutex
+	rateLimiterTypes   = map[string]bool{"TokenBucket": true}
+)
+
+// RegisterRateLimiterType разрешает в конфигурации тип rate limiter с указанным именем
+func RegisterRateLimiterType(name string) {
+	rateLimiterTypesMu.Lock()
+	defer rateLimiterTypesMu.Unlock()
+	rateLimiterTypes[name] = true
 }
 
 // LoadBalancerConfig конфигурация балансировщика
 type LoadBalancerConfig struct {
-	// Метод балансировки: RoundRobin, WeightedRoundRobin, LeastConnections
+	// Метод балансировки: RoundRobin, WeightedRoundRobin, LeastConnections, LeastLoad или метод,
+	// зарегистрированный loadbalancer.Register
 	Method string `yaml:"method"`
 
 	// Дополнительные параметры метода балансировки
 	Params map[string]interface{} `yaml:"params,omitempty"`
+
+	// Заголовок, которым пробный запрос направляется на бэкенд в режиме обслуживания
+	// (значение — ID бэкенда). Пустое значение отключает пробные запросы.
+	ProbeHeader string `yaml:"probeHeader,omitempty"`
+
+	// Заголовок, значением которого запрос направляется на бэкенды указанной версии
+	// (версию бэкенды сообщают в ответе на проверку здоровья). Пустое значение отключает выбор по версии.
+	VersionHeader string `yaml:"versionHeader,omitempty"`
 }
 
 // BackendConfig конфигурация бэкенда
@@ -51,6 +221,381 @@ type BackendConfig struct {
 
 	// Максимальное количество соединений
 	MaxConnections int `yaml:"maxConnections"`
+
+	// Режим обслуживания: бэкенд не получает трафик
+	Maintenance bool `yaml:"maintenance,omitempty"`
+
+	// Зона доступности (например, из топологии Kubernetes)
+	Zone string `yaml:"zone,omitempty"`
+
+	// Значение заголовка Host в запросах к бэкенду (по умолчанию сохраняется Host клиента)
+	Host string `yaml:"host,omitempty"`
+
+	// Заголовки, добавляемые к запросам к бэкенду (например, токен внутренней авторизации)
+	Headers map[string]string `yaml:"headers,omitempty"`
+
+	// Интервал повторного разрешения имени хоста бэкенда (по умолчанию 30s)
+	DNSRefreshInterval time.Duration `yaml:"dnsRefreshInterval,omitempty"`
+
+	// Адаптивный лимит одновременных запросов по наблюдаемой задержке
+	AdaptiveConcurrency *AdaptiveConcurrencyConfig `yaml:"adaptiveConcurrency,omitempty"`
+
+	// Протокол запросов к бэкенду: http (по умолчанию) или h2c — HTTP/2 без TLS,
+	// необходимый gRPC серверам. Для https бэкендов HTTP/2 согласуется через TLS.
+	Protocol string `yaml:"protocol,omitempty"`
+
+	// Способ проверки здоровья бэкенда (по умолчанию HTTP запрос healthCheck.path)
+	HealthCheck *BackendHealthCheckConfig `yaml:"healthCheck,omitempty"`
+
+	// Настройки TLS соединений с https бэкендом
+	TLS *BackendTLSConfig `yaml:"tls,omitempty"`
+}
+
+// BackendTLSConfig настройки TLS соединений с бэкендом
+type BackendTLSConfig struct {
+	// Число сессий TLS, сохраняемых для возобновления без полного рукопожатия
+	// (по умолчанию 64; -1 — сессии не сохраняются)
+	SessionCacheSize int `yaml:"sessionCacheSize,omitempty"`
+}
+
+// BackendHealthCheckConfig способ проверки здоровья бэкенда. Период и таймаут
+// проверок задаются общей секцией healthCheck.
+type BackendHealthCheckConfig struct {
+	// Тип проверки: http (по умолчанию), tcp (установка соединения), tls (TLS рукопожатие),
+	// grpc (протокол grpc.health.v1) или command (код завершения команды)
+	Type string `yaml:"type"`
+
+	// Путь HTTP проверки (по умолчанию healthCheck.path)
+	Path string `yaml:"path,omitempty"`
+
+	// Адрес host:port проверки tcp и tls (по умолчанию хост и порт URL бэкенда)
+	Address string `yaml:"address,omitempty"`
+
+	// Имя сервера для проверки сертификата tls (по умолчанию хост адреса)
+	ServerName string `yaml:"serverName,omitempty"`
+
+	// Не проверять сертификат бэкенда при проверке tls, например самоподписанный
+	InsecureSkipVerify bool `yaml:"insecureSkipVerify,omitempty"`
+
+	// Имя gRPC сервиса (по умолчанию пустое — состояние сервера в целом)
+	Service string `yaml:"service,omitempty"`
+
+	// Команда и аргументы проверки command. Бэкенд здоров, если команда завершилась
+	// с кодом 0; ID, URL и адрес бэкенда передаются в BACKEND_ID, BACKEND_URL и BACKEND_ADDRESS.
+	Command []string `yaml:"command,omitempty"`
+}
+
+// AdaptiveConcurrencyConfig настройки адаптивного лимита одновременных запросов к бэкенду.
+// Лимит подбирается по изменению задержки ответов и действует вместе с maxConnections.
+type AdaptiveConcurrencyConfig struct {
+	// Алгоритм: gradient (по умолчанию) или aimd
+	Algorithm string `yaml:"algorithm,omitempty"`
+
+	// Начальный лимит и его границы (по умолчанию 20, 1 и 1000)
+	InitialLimit int `yaml:"initialLimit,omitempty"`
+	MinLimit     int `yaml:"minLimit,omitempty"`
+	MaxLimit     int `yaml:"maxLimit,omitempty"`
+
+	// gradient: во сколько раз задержка может превышать базовую, прежде чем лимит начнет снижаться (по умолчанию 1.5)
+	Tolerance float64 `yaml:"tolerance,omitempty"`
+
+	// gradient: доля нового значения при сглаживании лимита (по умолчанию 0.2)
+	Smoothing float64 `yaml:"smoothing,omitempty"`
+
+	// aimd: ответы медленнее этого порога считаются признаком перегрузки (по умолчанию 1s)
+	LatencyThreshold time.Duration `yaml:"latencyThreshold,omitempty"`
+
+	// aimd: множитель лимита при перегрузке (по умолчанию 0.9)
+	BackoffRatio float64 `yaml:"backoffRatio,omitempty"`
+}
+
+// HealthCheckConfig настройки активной проверки здоровья бэкендов
+type HealthCheckConfig struct {
+	// Интервал между проверками
+	Interval time.Duration `yaml:"interval"`
+
+	// Таймаут одной проверки
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Путь, запрашиваемый у бэкенда
+	Path string `yaml:"path"`
+
+	// Максимальное число одновременных проверок (по умолчанию 16)
+	Concurrency int `yaml:"concurrency,omitempty"`
+}
+
+// OutlierDetectionConfig настройки обнаружения выбросов: бэкенды с серией ошибок или
+// долей успешных ответов заметно ниже остальных временно исключаются из ротации
+type OutlierDetectionConfig struct {
+	// Число ошибок подряд (5xx или ошибка соединения) для исключения (по умолчанию 5, 0 — по умолчанию)
+	Consecutive5xx int `yaml:"consecutive5xx,omitempty"`
+
+	// Интервал анализа доли успешных ответов и возврата бэкендов (по умолчанию 10s)
+	Interval time.Duration `yaml:"interval,omitempty"`
+
+	// Базовое время исключения, умножается на число исключений подряд (по умолчанию 30s)
+	BaseEjectionTime time.Duration `yaml:"baseEjectionTime,omitempty"`
+
+	// Максимальное время исключения (по умолчанию 300s)
+	MaxEjectionTime time.Duration `yaml:"maxEjectionTime,omitempty"`
+
+	// Максимальная доля исключенных бэкендов в процентах (по умолчанию 10, но хотя бы один бэкенд)
+	MaxEjectionPercent int `yaml:"maxEjectionPercent,omitempty"`
+
+	// Минимальное число бэкендов с достаточным числом запросов для анализа доли успешных ответов (по умолчанию 5)
+	SuccessRateMinimumHosts int `yaml:"successRateMinimumHosts,omitempty"`
+
+	// Минимальное число запросов к бэкенду за интервал для анализа доли успешных ответов (по умолчанию 100)
+	SuccessRateRequestVolume int `yaml:"successRateRequestVolume,omitempty"`
+
+	// Исключаются бэкенды с долей успешных ответов ниже mean - factor * stdev (по умолчанию 1.9)
+	SuccessRateStdevFactor float64 `yaml:"successRateStdevFactor,omitempty"`
+}
+
+// OverloadConfig настройки защиты от перегрузки: число одновременно обрабатываемых
+// запросов ограничивается, а при нехватке CPU запросы низкого приоритета сразу
+// получают 503, чтобы задержка остальных не росла
+type OverloadConfig struct {
+	// Максимум одновременно обрабатываемых запросов (0 — без ограничения)
+	MaxInFlight int `yaml:"maxInFlight,omitempty"`
+
+	// Часть maxInFlight, доступная только маршрутам с приоритетом high
+	ReservedInFlight int `yaml:"reservedInFlight,omitempty"`
+
+	// Доля загрузки CPU процесса (от GOMAXPROCS), выше которой отбрасываются запросы с приоритетом low (0 — не проверяется)
+	CPUThreshold float64 `yaml:"cpuThreshold,omitempty"`
+
+	// Задержка планировщика горутин, выше которой отбрасываются запросы с приоритетом low (0 — не проверяется)
+	SchedulerLagThreshold time.Duration `yaml:"schedulerLagThreshold,omitempty"`
+
+	// Маршруты с собственным лимитом и приоритетом; выбирается маршрут с самым длинным префиксом
+	Routes []OverloadRouteConfig `yaml:"routes,omitempty"`
+
+	// Приоритеты пользователей; приоритет пользователя важнее приоритета маршрута
+	Users []OverloadUserConfig `yaml:"users,omitempty"`
+
+	// Сколько запросов сверх maxInFlight может ждать освобождения места (0 — запросы сразу получают 503).
+	// Освободившееся место получает ожидающий запрос с наибольшим приоритетом.
+	QueueSize int `yaml:"queueSize,omitempty"`
+
+	// Максимальное время ожидания в очереди (по умолчанию 1s)
+	QueueTimeout time.Duration `yaml:"queueTimeout,omitempty"`
+}
+
+// OverloadUserConfig приоритет запросов пользователя. Запрос принимается до аутентификации,
+// поэтому пользователь определяется по адресу клиента с учетом доверенных прокси.
+type OverloadUserConfig struct {
+	// Идентификатор пользователя (IP адрес клиента)
+	UserID string `yaml:"userID"`
+
+	// Приоритет: high, normal или low
+	Priority string `yaml:"priority"`
+}
+
+// OverloadRouteConfig лимит и приоритет запросов с общим префиксом пути
+type OverloadRouteConfig struct {
+	// Префикс пути запроса
+	PathPrefix string `yaml:"pathPrefix"`
+
+	// Максимум одновременно обрабатываемых запросов маршрута (0 — без ограничения)
+	MaxInFlight int `yaml:"maxInFlight,omitempty"`
+
+	// Приоритет: high, normal (по умолчанию) или low
+	Priority string `yaml:"priority,omitempty"`
+}
+
+// GeoIPConfig базы GeoIP в формате MaxMind DB. Файлы баз перечитываются при изменении.
+type GeoIPConfig struct {
+	// База стран или городов (например, GeoLite2-Country.mmdb)
+	Database string `yaml:"database,omitempty"`
+
+	// База автономных систем (например, GeoLite2-ASN.mmdb)
+	ASNDatabase string `yaml:"asnDatabase,omitempty"`
+
+	// Правила направления клиентов к бэкендам зоны по стране или автономной системе
+	Zones []GeoZoneConfig `yaml:"zones,omitempty"`
+}
+
+// ForwardedHeadersConfig заголовки, которыми прокси сообщает бэкенду адрес клиента
+// и параметры исходного запроса
+type ForwardedHeadersConfig struct {
+	// append (по умолчанию) — заголовки от доверенных прокси сохраняются и дополняются;
+	// overwrite — прокси первый в цепочке, заголовки всегда формируются заново
+	Mode string `yaml:"mode,omitempty"`
+
+	// Добавлять заголовок Forwarded (RFC 7239)
+	Forwarded bool `yaml:"forwarded,omitempty"`
+
+	// Значение параметра by заголовка Forwarded, например _lb1 (пустое — не добавляется)
+	By string `yaml:"by,omitempty"`
+}
+
+// RoutingDebugConfig отладка маршрутизации: ответы на запросы с подписанным заголовком
+// дополняются заголовками с объяснением выбора бэкенда
+type RoutingDebugConfig struct {
+	// Заголовок с подписанным токеном отладки (по умолчанию X-LB-Debug)
+	Header string `yaml:"header,omitempty"`
+
+	// Ключ HMAC подписи токенов отладки; без него отладка включается только через административное API
+	Secret string `yaml:"secret,omitempty"`
+}
+
+// AccessLogConfig журнал доступа и асинхронный учет запросов. Запись в журнал, метрики
+// и статистика для обнаружения выбросов обрабатываются вне горутины запроса.
+type AccessLogConfig struct {
+	// Записывать каждый обработанный запрос в лог
+	Enabled bool `yaml:"enabled"`
+
+	// Размер очереди записей (по умолчанию 8192); при переполнении записи отбрасываются.
+	// Изменение применяется после перезапуска.
+	QueueSize int `yaml:"queueSize,omitempty"`
+
+	// Количество горутин, обрабатывающих записи (по умолчанию 2). Изменение применяется после перезапуска.
+	Workers int `yaml:"workers,omitempty"`
+}
+
+// MetricsPushConfig периодическая отправка счетчиков прокси во внешнюю систему
+type MetricsPushConfig struct {
+	// Протокол: statsd, dogstatsd, graphite или otlp
+	Type string `yaml:"type"`
+
+	// Адрес host:port сервера StatsD (UDP) или Graphite (TCP); для otlp — URL приемника
+	// метрик OTLP/HTTP, например http://collector:4318/v1/metrics
+	Address string `yaml:"address"`
+
+	// Период отправки (по умолчанию 10s)
+	Interval time.Duration `yaml:"interval,omitempty"`
+
+	// Префикс имен метрик, например myteam.lb.
+	Prefix string `yaml:"prefix,omitempty"`
+
+	// Теги, добавляемые ко всем метрикам (в otlp — атрибуты ресурса)
+	Tags map[string]string `yaml:"tags,omitempty"`
+
+	// Заголовки запросов к приемнику otlp, например с токеном
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// UsageConfig учет запросов, переданных байт и ошибок по пользователям (тенантам)
+// с периодической выгрузкой отчетов
+type UsageConfig struct {
+	// Период отчета (по умолчанию 1m)
+	Interval time.Duration `yaml:"interval,omitempty"`
+
+	// Заголовок запроса с идентификатором тенанта. Если не задан или отсутствует
+	// в запросе, тенантом считается пользователь запроса.
+	TenantHeader string `yaml:"tenantHeader,omitempty"`
+
+	// Назначения отчетов
+	Exports []UsageExportConfig `yaml:"exports"`
+}
+
+// UsageExportConfig назначение отчетов о потреблении
+type UsageExportConfig struct {
+	// Тип: file, webhook или kafka
+	Type string `yaml:"type"`
+
+	// Файл, в конец которого дописываются отчеты в формате JSON по одному в строке (file)
+	Path string `yaml:"path,omitempty"`
+
+	// URL, на который отправляется отчет POST запросом (webhook)
+	URL string `yaml:"url,omitempty"`
+
+	// Заголовки запроса, например с токеном (webhook)
+	Headers map[string]string `yaml:"headers,omitempty"`
+
+	// Адреса брокеров host:port и топик; каждый тенант отчета — отдельное сообщение (kafka)
+	Brokers []string `yaml:"brokers,omitempty"`
+	Topic   string   `yaml:"topic,omitempty"`
+}
+
+// AccessEventsConfig публикация записей о каждом обработанном запросе в брокер сообщений
+type AccessEventsConfig struct {
+	// Брокер: kafka или nsq
+	Type string `yaml:"type"`
+
+	// Адреса брокеров Kafka host:port
+	Brokers []string `yaml:"brokers,omitempty"`
+
+	// Адрес nsqd host:port (TCP)
+	Address string `yaml:"address,omitempty"`
+
+	// Топик событий
+	Topic string `yaml:"topic"`
+
+	// Максимальное количество событий в одной отправке (по умолчанию 500)
+	BatchSize int `yaml:"batchSize,omitempty"`
+
+	// Период отправки неполного пакета (по умолчанию 1s)
+	FlushInterval time.Duration `yaml:"flushInterval,omitempty"`
+
+	// Очередь событий, ожидающих отправки (по умолчанию 10000); при переполнении
+	// события отбрасываются, а не задерживают запросы
+	BufferSize int `yaml:"bufferSize,omitempty"`
+}
+
+// AlertingConfig правила оповещений и webhook'и, на которые они отправляются
+type AlertingConfig struct {
+	// Период проверки правил (по умолчанию 15s)
+	Interval time.Duration `yaml:"interval,omitempty"`
+
+	Rules    []AlertRuleConfig    `yaml:"rules"`
+	Webhooks []AlertWebhookConfig `yaml:"webhooks"`
+}
+
+// AlertRuleConfig правило оповещения
+type AlertRuleConfig struct {
+	// Имя правила в оповещениях
+	Name string `yaml:"name"`
+
+	// Тип: backendDown (бэкенд недоступен дольше for), errorRate (доля ответов 5xx
+	// за window больше threshold процентов) или rateLimited (отказов rate limiter'а
+	// в минуту больше threshold)
+	Type string `yaml:"type"`
+
+	// Бэкенд, к которому относится правило backendDown или errorRate (по умолчанию все)
+	Backend string `yaml:"backend,omitempty"`
+
+	// Время недоступности бэкенда до оповещения (backendDown, по умолчанию 0)
+	For time.Duration `yaml:"for,omitempty"`
+
+	// Порог срабатывания (errorRate, rateLimited)
+	Threshold float64 `yaml:"threshold,omitempty"`
+
+	// Окно подсчета (errorRate, по умолчанию 1m)
+	Window time.Duration `yaml:"window,omitempty"`
+
+	// Минимальное количество запросов за окно, при котором проверяется доля ошибок
+	// (errorRate, по умолчанию 10)
+	MinRequests int64 `yaml:"minRequests,omitempty"`
+}
+
+// AlertWebhookConfig получатель оповещений
+type AlertWebhookConfig struct {
+	// Формат: generic (JSON оповещения), slack (incoming webhook) или pagerduty (Events API v2)
+	Type string `yaml:"type"`
+
+	// URL webhook'а; для pagerduty по умолчанию https://events.pagerduty.com/v2/enqueue
+	URL string `yaml:"url,omitempty"`
+
+	// Ключ интеграции сервиса PagerDuty
+	RoutingKey string `yaml:"routingKey,omitempty"`
+
+	// Заголовки запроса, например с токеном (generic)
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// GeoZoneConfig правило направления клиентов к бэкендам зоны. Если в зоне нет
+// доступных бэкендов, запрос получает любой доступный бэкенд.
+type GeoZoneConfig struct {
+	// Коды стран ISO 3166-1 alpha-2
+	Countries []string `yaml:"countries,omitempty"`
+
+	// Номера автономных систем
+	ASNs []uint32 `yaml:"asns,omitempty"`
+
+	// Зона бэкендов (поле zone бэкенда)
+	Zone string `yaml:"zone"`
 }
 
 // RateLimiterConfig конфигурация rate limiter
@@ -58,11 +603,14 @@ type RateLimiterConfig struct {
 	// Включен ли rate limiter
 	Enabled bool `yaml:"enabled"`
 
-	// Тип rate limiter (пока поддерживается только TokenBucket)
+	// Тип rate limiter: TokenBucket или тип, зарегистрированный ratelimit.Register
 	Type string `yaml:"type"`
 
 	// Настройки для token bucket
 	TokenBucket *TokenBucketConfig `yaml:"tokenBucket,omitempty"`
+
+	// Параметры зарегистрированного типа rate limiter
+	Params map[string]interface{} `yaml:"params,omitempty"`
 }
 
 // TokenBucketConfig настройки для token bucket
@@ -96,86 +644,941 @@ type LoggerConfig struct {
 
 	// Имя сервиса
 	ServiceName string `yaml:"serviceName"`
+
+	// Выборочная запись отладочных сообщений, чтобы уровень debug можно было
+	// оставить включенным под нагрузкой
+	DebugSampling *LogSamplingConfig `yaml:"debugSampling,omitempty"`
+
+	// Выборочная запись сообщений уровней info и warn. Сообщения уровней error
+	// и fatal записываются всегда.
+	Sampling *LogSamplingConfig `yaml:"sampling,omitempty"`
+
+	// Назначения записи сообщений. Без секции сообщения выводятся в консоль
+	// и записываются в файл logs/app.log.
+	Outputs []LogOutputConfig `yaml:"outputs,omitempty"`
 }
 
-// LoadFromFile загружает конфигурацию из YAML файла
+// LogOutputConfig назначение записи сообщений
+type LogOutputConfig struct {
+	// Тип: stdout, stderr, file, syslog, journald
+	Type string `yaml:"type"`
+
+	// Формат: json или console (по умолчанию console для stdout и stderr, json для остальных)
+	Encoding string `yaml:"encoding,omitempty"`
+
+	// Путь к файлу (для file)
+	Path string `yaml:"path,omitempty"`
+
+	// Размер файла в мегабайтах, после которого он ротируется (0 — без ротации)
+	MaxSize int `yaml:"maxSize,omitempty"`
+
+	// Срок хранения архивных файлов (0 — без ограничения)
+	MaxAge time.Duration `yaml:"maxAge,omitempty"`
+
+	// Количество архивных файлов (0 — без ограничения)
+	MaxBackups int `yaml:"maxBackups,omitempty"`
+
+	// Сжимать архивные файлы gzip
+	Compress bool `yaml:"compress,omitempty"`
+
+	// Сеть (udp, tcp) и адрес удаленного syslog; без них сообщения передаются локальному syslog
+	Network string `yaml:"network,omitempty"`
+	Address string `yaml:"address,omitempty"`
+
+	// Идентификатор приложения в syslog и journald (по умолчанию serviceName)
+	Tag string `yaml:"tag,omitempty"`
+}
+
+// LogSamplingConfig настройки выборки отладочных сообщений: каждую секунду
+// записываются первые Initial сообщений, а затем каждое Thereafter-е
+type LogSamplingConfig struct {
+	Initial int `yaml:"initial"`
+
+	// 0 — сообщения сверх Initial отбрасываются
+	Thereafter int `yaml:"thereafter,omitempty"`
+
+	// Считать сообщения отдельно для каждого текста, чтобы частое сообщение
+	// не вытесняло редкие
+	PerMessage bool `yaml:"perMessage,omitempty"`
+}
+
+// validateInto проверяет настройки выборки, если они заданы
+func (s *LogSamplingConfig) validateInto(v *validator, field string) {
+	if s == nil {
+		return
+	}
+	if s.Initial <= 0 {
+		v.add(field+".initial", s.Initial, "must be positive")
+	}
+	if s.Thereafter < 0 {
+		v.add(field+".thereafter", s.Thereafter, "must not be negative")
+	}
+}
+
+// LoadFromFile загружает конфигурацию из файла. Формат (YAML, JSON или TOML)
+// определяется по расширению, файлы без известного расширения читаются как YAML.
 func LoadFromFile(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("error reading config file: %w", err)
 	}
 
+	format := DetectFormat(path)
+	if format == "" {
+		format = FormatYAML
+	}
+
+	return ParseFormat(data, format)
+}
+
+// Parse разбирает и проверяет конфигурацию в формате YAML. Неизвестные ключи считаются ошибкой.
+// Все найденные проблемы (включая неизвестные ключи) возвращаются сразу в *ValidationError.
+// Ссылки ${ENV}, ${ENV:-default} и secret://path в значениях подставляются при каждой загрузке.
+func Parse(data []byte) (*Config, error) {
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	v := &validator{}
+
+	// Подставляем ${ENV} и secret:// ссылки
+	data, interpolated, err := interpolateData(data, v)
+	if err != nil {
 		return nil, fmt.Errorf("error parsing config file: %w", err)
 	}
+	config.interpolated = interpolated
 
-	if err := config.validate(); err != nil {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && err != io.EOF {
+		// Ошибки типов и неизвестные ключи не мешают проверить остальную конфигурацию
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("error parsing config file: %w", err)
+		}
+		for _, message := range typeErr.Errors {
+			v.add("", nil, "%s", message)
+		}
+	}
+
+	config.validateInto(v)
+	if err := v.err(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
 	return &config, nil
 }
 
-// validate проверяет корректность конфигурации
+// redactedValue заменяет секреты при выводе конфигурации
+const redactedValue = "******"
+
+// Redacted возвращает копию конфигурации, в которой секреты заменены на заглушку
+func (c *Config) Redacted() *Config {
+	redacted := *c
+
+	if c.Admin != nil {
+		admin := *c.Admin
+		if admin.Token != "" {
+			admin.Token = redactedValue
+		}
+
+		if c.Admin.Auth != nil {
+			auth := AdminAuthConfig{
+				Tokens:      make([]AdminTokenConfig, len(c.Admin.Auth.Tokens)),
+				Users:       make([]AdminUserConfig, len(c.Admin.Auth.Users)),
+				ClientCerts: c.Admin.Auth.ClientCerts,
+			}
+			for i, t := range c.Admin.Auth.Tokens {
+				auth.Tokens[i] = AdminTokenConfig{Token: redactedValue, Role: t.Role}
+			}
+			for i, u := range c.Admin.Auth.Users {
+				auth.Users[i] = AdminUserConfig{Username: u.Username, Password: redactedValue, Role: u.Role}
+			}
+			admin.Auth = &auth
+		}
+
+		redacted.Admin = &admin
+	}
+
+	if c.RoutingDebug != nil && c.RoutingDebug.Secret != "" {
+		debug := *c.RoutingDebug
+		debug.Secret = redactedValue
+		redacted.RoutingDebug = &debug
+	}
+
+	// Значения заголовков бэкендов часто содержат токены
+	if len(c.Backends) > 0 {
+		redacted.Backends = make([]BackendConfig, len(c.Backends))
+		for i, b := range c.Backends {
+			if len(b.Headers) > 0 {
+				headers := make(map[string]string, len(b.Headers))
+				for name := range b.Headers {
+					headers[name] = redactedValue
+				}
+				b.Headers = headers
+			}
+			redacted.Backends[i] = b
+		}
+	}
+
+	// Заголовки приемников метрик обычно содержат токены
+	if len(c.MetricsPush) > 0 {
+		redacted.MetricsPush = make([]MetricsPushConfig, len(c.MetricsPush))
+		for i, m := range c.MetricsPush {
+			if len(m.Headers) > 0 {
+				headers := make(map[string]string, len(m.Headers))
+				for name := range m.Headers {
+					headers[name] = redactedValue
+				}
+				m.Headers = headers
+			}
+			redacted.MetricsPush[i] = m
+		}
+	}
+
+	if c.Usage != nil && len(c.Usage.Exports) > 0 {
+		usage := *c.Usage
+		usage.Exports = make([]UsageExportConfig, len(c.Usage.Exports))
+		for i, e := range c.Usage.Exports {
+			if len(e.Headers) > 0 {
+				headers := make(map[string]string, len(e.Headers))
+				for name := range e.Headers {
+					headers[name] = redactedValue
+				}
+				e.Headers = headers
+			}
+			usage.Exports[i] = e
+		}
+		redacted.Usage = &usage
+	}
+
+	// URL Slack webhook'ов и ключи PagerDuty сами являются секретами
+	if c.Alerting != nil && len(c.Alerting.Webhooks) > 0 {
+		alerting := *c.Alerting
+		alerting.Webhooks = make([]AlertWebhookConfig, len(c.Alerting.Webhooks))
+		for i, w := range c.Alerting.Webhooks {
+			if w.Type == "slack" {
+				w.URL = redactedValue
+			}
+			if w.RoutingKey != "" {
+				w.RoutingKey = redactedValue
+			}
+			if len(w.Headers) > 0 {
+				headers := make(map[string]string, len(w.Headers))
+				for name := range w.Headers {
+					headers[name] = redactedValue
+				}
+				w.Headers = headers
+			}
+			alerting.Webhooks[i] = w
+		}
+		redacted.Alerting = &alerting
+	}
+
+	// Параметры middleware с секретами (clientSecret, cookieSecret, token и т.п.)
+	if len(c.Middlewares) > 0 {
+		redacted.Middlewares = make([]MiddlewareConfig, len(c.Middlewares))
+		for i, m := range c.Middlewares {
+			m.Params = redactParams(m.Params)
+			redacted.Middlewares[i] = m
+		}
+	}
+
+	if len(c.Listeners) > 0 {
+		redacted.Listeners = make([]ListenerConfig, len(c.Listeners))
+		for i, l := range c.Listeners {
+			if len(l.Middlewares) > 0 {
+				middlewares := make([]MiddlewareConfig, len(l.Middlewares))
+				for j, m := range l.Middlewares {
+					m.Params = redactParams(m.Params)
+					middlewares[j] = m
+				}
+				l.Middlewares = middlewares
+			}
+			redacted.Listeners[i] = l
+		}
+	}
+
+	if c.Routing != nil {
+		routing := *c.Routing
+		routing.Rules = make([]RouteConfig, len(c.Routing.Rules))
+		for i, route := range c.Routing.Rules {
+			if len(route.Middlewares) > 0 {
+				middlewares := make([]MiddlewareConfig, len(route.Middlewares))
+				for j, m := range route.Middlewares {
+					m.Params = redactParams(m.Params)
+					middlewares[j] = m
+				}
+				route.Middlewares = middlewares
+			}
+			routing.Rules[i] = route
+		}
+		if c.Routing.Auth != nil && c.Routing.Auth.APIKeys != nil {
+			auth := *c.Routing.Auth
+			apiKeys := *c.Routing.Auth.APIKeys
+			apiKeys.Keys = make([]APIKeyConfig, len(c.Routing.Auth.APIKeys.Keys))
+			for i, k := range c.Routing.Auth.APIKeys.Keys {
+				if k.Key != "" {
+					k.Key = redactedValue
+				}
+				apiKeys.Keys[i] = k
+			}
+			if store := c.Routing.Auth.APIKeys.Store; store != nil && store.Password != "" {
+				redactedStore := *store
+				redactedStore.Password = redactedValue
+				apiKeys.Store = &redactedStore
+			}
+			auth.APIKeys = &apiKeys
+			routing.Auth = &auth
+		}
+		redacted.Routing = &routing
+	}
+
+	if len(c.Discovery) > 0 {
+		redacted.Discovery = make([]DiscoveryConfig, len(c.Discovery))
+		for i, d := range c.Discovery {
+			if d.Consul != nil && d.Consul.Token != "" {
+				consul := *d.Consul
+				consul.Token = redactedValue
+				d.Consul = &consul
+			}
+			redacted.Discovery[i] = d
+		}
+	}
+
+	if c.HA != nil && c.HA.Consul != nil && c.HA.Consul.Token != "" {
+		ha := *c.HA
+		consul := *c.HA.Consul
+		consul.Token = redactedValue
+		ha.Consul = &consul
+		redacted.HA = &ha
+	}
+
+	if c.Gossip != nil && c.Gossip.Secret != "" {
+		gossip := *c.Gossip
+		gossip.Secret = redactedValue
+		redacted.Gossip = &gossip
+	}
+
+	return &redacted
+}
+
+// validate проверяет корректность конфигурации и возвращает *ValidationError
+// со всеми найденными проблемами
 func (c *Config) validate() error {
+	v := &validator{}
+	c.validateInto(v)
+	return v.err()
+}
+
+// validateInto проверяет конфигурацию, накапливая ошибки в v
+func (c *Config) validateInto(v *validator) {
 	// Проверяем метод балансировки
-	switch c.LoadBalancer.Method {
-	case "RoundRobin", "WeightedRoundRobin", "LeastConnections":
-		// OK
-	default:
-		return fmt.Errorf("unsupported load balancing method: %s", c.LoadBalancer.Method)
+	loadBalancerMethodsMu.RLock()
+	supported := loadBalancerMethods[c.LoadBalancer.Method]
+	loadBalancerMethodsMu.RUnlock()
+	switch {
+	case c.LoadBalancer.Method == "":
+		v.add("loadBalancer.method", nil, "is required")
+	case !supported:
+		v.add("loadBalancer.method", c.LoadBalancer.Method, "unsupported load balancing method")
+	}
+
+	// Проверяем наличие бэкендов: статических или обнаруживаемых
+	if len(c.Backends) == 0 && len(c.Discovery) == 0 {
+		v.add("backends", nil, "no backends or discovery configured")
 	}
 
-	// Проверяем наличие бэкендов
-	if len(c.Backends) == 0 {
-		return fmt.Errorf("no backends configured")
+	pluginNames := make(map[string]bool, len(c.Plugins))
+	for i := range c.Plugins {
+		c.Plugins[i].validateInto(v, fmt.Sprintf("plugins[%d]", i))
+		if pluginNames[c.Plugins[i].Name] {
+			v.add(fmt.Sprintf("plugins[%d].name", i), c.Plugins[i].Name, "duplicate plugin name")
+		}
+		pluginNames[c.Plugins[i].Name] = true
+	}
+	for i := range c.Middlewares {
+		c.Middlewares[i].validateInto(v, fmt.Sprintf("middlewares[%d]", i), c.Plugins)
+	}
+	if c.Routing != nil {
+		c.Routing.validateInto(v, c.Plugins, c.Listeners)
+	}
+
+	for i := range c.Discovery {
+		c.Discovery[i].validateInto(v, fmt.Sprintf("discovery[%d]", i))
 	}
 
 	// Проверяем конфигурацию бэкендов
-	for _, b := range c.Backends {
-		if b.ID == "" {
-			return fmt.Errorf("backend ID is required")
+	validateBackends(v, "backends", c.Backends)
+
+	// Проверяем rate limiter
+	if c.RateLimiter != nil && c.RateLimiter.Enabled {
+		rateLimiterTypesMu.RLock()
+		supported := rateLimiterTypes[c.RateLimiter.Type]
+		rateLimiterTypesMu.RUnlock()
+		if !supported {
+			v.add("rateLimiter.type", c.RateLimiter.Type, "unsupported rate limiter type")
+		}
+		if c.RateLimiter.Type == "TokenBucket" {
+			if c.RateLimiter.TokenBucket == nil {
+				v.add("rateLimiter.tokenBucket", nil, "is required")
+			} else {
+				if c.RateLimiter.TokenBucket.Rate <= 0 {
+					v.add("rateLimiter.tokenBucket.rate", c.RateLimiter.TokenBucket.Rate, "must be positive")
+				}
+				if c.RateLimiter.TokenBucket.Burst <= 0 {
+					v.add("rateLimiter.tokenBucket.burst", c.RateLimiter.TokenBucket.Burst, "must be positive")
+				}
+			}
+		}
+	}
+
+	// Проверяем конфигурацию логгера
+	if c.Logger == nil {
+		v.add("logger", nil, "is required")
+	} else {
+		switch c.Logger.LogLevel {
+		case "debug", "info", "warn", "error", "fatal":
+			// OK
+		default:
+			v.add("logger.logLevel", c.Logger.LogLevel, "unsupported log level")
 		}
-		if b.URL == "" {
-			return fmt.Errorf("backend URL is required")
+
+		if c.Logger.ServiceName == "" {
+			v.add("logger.serviceName", nil, "is required")
 		}
-		if b.Weight != nil && *b.Weight <= 0 {
-			return fmt.Errorf("backend weight must be positive")
+
+		c.Logger.DebugSampling.validateInto(v, "logger.debugSampling")
+		c.Logger.Sampling.validateInto(v, "logger.sampling")
+
+		for i, output := range c.Logger.Outputs {
+			field := fmt.Sprintf("logger.outputs[%d]", i)
+			switch output.Type {
+			case "stdout", "stderr", "syslog", "journald":
+				// OK
+			case "file":
+				if output.Path == "" {
+					v.add(field+".path", nil, "is required")
+				}
+			default:
+				v.add(field+".type", output.Type, "unsupported log output type")
+			}
+			switch output.Encoding {
+			case "", "json", "console":
+				// OK
+			default:
+				v.add(field+".encoding", output.Encoding, "must be json or console")
+			}
+			if output.MaxSize < 0 || output.MaxAge < 0 || output.MaxBackups < 0 {
+				v.add(field, nil, "rotation settings must not be negative")
+			}
+			if (output.Network == "") != (output.Address == "") {
+				v.add(field+".address", output.Address, "network and address must be set together")
+			}
 		}
 	}
 
-	// Проверяем rate limiter
-	if c.RateLimiter != nil && c.RateLimiter.Enabled {
-		if c.RateLimiter.Type != "TokenBucket" {
-			return fmt.Errorf("unsupported rate limiter type: %s", c.RateLimiter.Type)
+	// Проверяем health check
+	if hc := c.HealthCheck; hc != nil {
+		if hc.Interval < 0 {
+			v.add("healthCheck.interval", hc.Interval, "must not be negative")
+		}
+		if hc.Timeout < 0 {
+			v.add("healthCheck.timeout", hc.Timeout, "must not be negative")
 		}
-		if c.RateLimiter.TokenBucket == nil {
-			return fmt.Errorf("token bucket configuration is required")
+		if hc.Interval > 0 && hc.Timeout > hc.Interval {
+			v.add("healthCheck.timeout", hc.Timeout, "must not exceed interval %v", hc.Interval)
 		}
-		if c.RateLimiter.TokenBucket.Rate <= 0 {
-			return fmt.Errorf("token bucket rate must be positive")
+		if hc.Path != "" && !strings.HasPrefix(hc.Path, "/") {
+			v.add("healthCheck.path", hc.Path, "must start with /")
 		}
-		if c.RateLimiter.TokenBucket.Burst <= 0 {
-			return fmt.Errorf("token bucket burst must be positive")
+		if hc.Concurrency < 0 {
+			v.add("healthCheck.concurrency", hc.Concurrency, "must not be negative")
 		}
 	}
 
-	// Проверяем конфигурацию логгера
-	if c.Logger == nil {
-		return fmt.Errorf("logger configuration is required")
+	// Проверяем обнаружение выбросов
+	if od := c.OutlierDetection; od != nil {
+		if od.Consecutive5xx < 0 {
+			v.add("outlierDetection.consecutive5xx", od.Consecutive5xx, "must not be negative")
+		}
+		if od.Interval < 0 {
+			v.add("outlierDetection.interval", od.Interval, "must not be negative")
+		}
+		if od.BaseEjectionTime < 0 {
+			v.add("outlierDetection.baseEjectionTime", od.BaseEjectionTime, "must not be negative")
+		}
+		if od.MaxEjectionTime < 0 {
+			v.add("outlierDetection.maxEjectionTime", od.MaxEjectionTime, "must not be negative")
+		}
+		if od.SuccessRateMinimumHosts < 0 {
+			v.add("outlierDetection.successRateMinimumHosts", od.SuccessRateMinimumHosts, "must not be negative")
+		}
+		if od.SuccessRateRequestVolume < 0 {
+			v.add("outlierDetection.successRateRequestVolume", od.SuccessRateRequestVolume, "must not be negative")
+		}
+		if od.MaxEjectionPercent < 0 || od.MaxEjectionPercent > 100 {
+			v.add("outlierDetection.maxEjectionPercent", od.MaxEjectionPercent, "must be between 0 and 100")
+		}
+		if od.SuccessRateStdevFactor < 0 {
+			v.add("outlierDetection.successRateStdevFactor", od.SuccessRateStdevFactor, "must not be negative")
+		}
+	}
+
+	// Проверяем защиту от перегрузки
+	if o := c.Overload; o != nil {
+		if o.MaxInFlight < 0 {
+			v.add("overload.maxInFlight", o.MaxInFlight, "must not be negative")
+		}
+		if o.ReservedInFlight < 0 || (o.ReservedInFlight > 0 && o.ReservedInFlight >= o.MaxInFlight) {
+			v.add("overload.reservedInFlight", o.ReservedInFlight, "must be between 0 and maxInFlight")
+		}
+		if o.CPUThreshold < 0 || o.CPUThreshold > 1 {
+			v.add("overload.cpuThreshold", o.CPUThreshold, "must be between 0 and 1")
+		}
+		if o.SchedulerLagThreshold < 0 {
+			v.add("overload.schedulerLagThreshold", o.SchedulerLagThreshold, "must not be negative")
+		}
+		for i, r := range o.Routes {
+			item := fmt.Sprintf("overload.routes[%d]", i)
+			if !strings.HasPrefix(r.PathPrefix, "/") {
+				v.add(item+".pathPrefix", r.PathPrefix, "must start with /")
+			}
+			if r.MaxInFlight < 0 {
+				v.add(item+".maxInFlight", r.MaxInFlight, "must not be negative")
+			}
+			switch r.Priority {
+			case "", "high", "normal", "low":
+			default:
+				v.add(item+".priority", r.Priority, "must be high, normal or low")
+			}
+		}
+		for i, u := range o.Users {
+			item := fmt.Sprintf("overload.users[%d]", i)
+			if u.UserID == "" {
+				v.add(item+".userID", nil, "is required")
+			}
+			switch u.Priority {
+			case "high", "normal", "low":
+			default:
+				v.add(item+".priority", u.Priority, "must be high, normal or low")
+			}
+		}
+		if o.QueueSize < 0 || (o.QueueSize > 0 && o.MaxInFlight == 0) {
+			v.add("overload.queueSize", o.QueueSize, "must not be negative and requires maxInFlight")
+		}
+		if o.QueueTimeout < 0 {
+			v.add("overload.queueTimeout", o.QueueTimeout, "must not be negative")
+		}
+	}
+
+	// Проверяем отправку метрик
+	for i, m := range c.MetricsPush {
+		item := fmt.Sprintf("metricsPush[%d]", i)
+		switch m.Type {
+		case "statsd", "dogstatsd", "graphite":
+			if _, port, err := net.SplitHostPort(m.Address); err != nil || port == "" {
+				v.add(item+".address", m.Address, "must be in host:port format")
+			}
+		case "otlp":
+			if u, err := url.Parse(m.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				v.add(item+".address", m.Address, "must be an http or https URL")
+			}
+		default:
+			v.add(item+".type", m.Type, "must be statsd, dogstatsd, graphite or otlp")
+		}
+		if m.Interval < 0 {
+			v.add(item+".interval", m.Interval, "must not be negative")
+		}
+		if len(m.Headers) > 0 && m.Type != "otlp" {
+			v.add(item+".headers", nil, "is supported only by otlp")
+		}
+	}
+
+	// Проверяем учет потребления
+	if c.Usage != nil {
+		if c.Usage.Interval < 0 {
+			v.add("usage.interval", c.Usage.Interval, "must not be negative")
+		}
+		if len(c.Usage.Exports) == 0 {
+			v.add("usage.exports", nil, "is required")
+		}
+		for i, e := range c.Usage.Exports {
+			item := fmt.Sprintf("usage.exports[%d]", i)
+			switch e.Type {
+			case "file":
+				if e.Path == "" {
+					v.add(item+".path", nil, "is required")
+				}
+			case "webhook":
+				if u, err := url.Parse(e.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					v.add(item+".url", e.URL, "must be an http or https URL")
+				}
+			case "kafka":
+				if len(e.Brokers) == 0 {
+					v.add(item+".brokers", nil, "is required")
+				}
+				for j, broker := range e.Brokers {
+					if _, port, err := net.SplitHostPort(broker); err != nil || port == "" {
+						v.add(fmt.Sprintf("%s.brokers[%d]", item, j), broker, "must be in host:port format")
+					}
+				}
+				if e.Topic == "" {
+					v.add(item+".topic", nil, "is required")
+				}
+			default:
+				v.add(item+".type", e.Type, "must be file, webhook or kafka")
+			}
+		}
+	}
+
+	// Проверяем публикацию событий о запросах
+	if e := c.AccessEvents; e != nil {
+		switch e.Type {
+		case "kafka":
+			if len(e.Brokers) == 0 {
+				v.add("accessEvents.brokers", nil, "is required")
+			}
+			for i, broker := range e.Brokers {
+				if _, port, err := net.SplitHostPort(broker); err != nil || port == "" {
+					v.add(fmt.Sprintf("accessEvents.brokers[%d]", i), broker, "must be in host:port format")
+				}
+			}
+		case "nsq":
+			if _, port, err := net.SplitHostPort(e.Address); err != nil || port == "" {
+				v.add("accessEvents.address", e.Address, "must be in host:port format")
+			}
+		default:
+			v.add("accessEvents.type", e.Type, "must be kafka or nsq")
+		}
+		if e.Topic == "" {
+			v.add("accessEvents.topic", nil, "is required")
+		}
+		if e.BatchSize < 0 {
+			v.add("accessEvents.batchSize", e.BatchSize, "must not be negative")
+		}
+		if e.FlushInterval < 0 {
+			v.add("accessEvents.flushInterval", e.FlushInterval, "must not be negative")
+		}
+		if e.BufferSize < 0 {
+			v.add("accessEvents.bufferSize", e.BufferSize, "must not be negative")
+		}
+	}
+
+	// Проверяем оповещения
+	if a := c.Alerting; a != nil {
+		if a.Interval < 0 {
+			v.add("alerting.interval", a.Interval, "must not be negative")
+		}
+		if len(a.Rules) == 0 {
+			v.add("alerting.rules", nil, "is required")
+		}
+		names := make(map[string]bool, len(a.Rules))
+		for i, rule := range a.Rules {
+			item := fmt.Sprintf("alerting.rules[%d]", i)
+			if rule.Name == "" {
+				v.add(item+".name", nil, "is required")
+			} else if names[rule.Name] {
+				v.add(item+".name", rule.Name, "must be unique")
+			}
+			names[rule.Name] = true
+			switch rule.Type {
+			case "backendDown":
+				if rule.For < 0 {
+					v.add(item+".for", rule.For, "must not be negative")
+				}
+			case "errorRate":
+				if rule.Threshold <= 0 || rule.Threshold > 100 {
+					v.add(item+".threshold", rule.Threshold, "must be a percentage in (0, 100]")
+				}
+				if rule.Window < 0 {
+					v.add(item+".window", rule.Window, "must not be negative")
+				}
+				if rule.MinRequests < 0 {
+					v.add(item+".minRequests", rule.MinRequests, "must not be negative")
+				}
+			case "rateLimited":
+				if rule.Threshold <= 0 {
+					v.add(item+".threshold", rule.Threshold, "must be positive")
+				}
+				if rule.Backend != "" {
+					v.add(item+".backend", rule.Backend, "is not supported by rateLimited rules")
+				}
+			default:
+				v.add(item+".type", rule.Type, "must be backendDown, errorRate or rateLimited")
+			}
+		}
+		if len(a.Webhooks) == 0 {
+			v.add("alerting.webhooks", nil, "is required")
+		}
+		for i, w := range a.Webhooks {
+			item := fmt.Sprintf("alerting.webhooks[%d]", i)
+			switch w.Type {
+			case "generic", "slack", "pagerduty":
+			default:
+				v.add(item+".type", w.Type, "must be generic, slack or pagerduty")
+			}
+			if w.URL == "" && w.Type != "pagerduty" {
+				v.add(item+".url", nil, "is required")
+			} else if u, err := url.Parse(w.URL); w.URL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+				v.add(item+".url", w.URL, "must be an http or https URL")
+			}
+			if w.Type == "pagerduty" && w.RoutingKey == "" {
+				v.add(item+".routingKey", nil, "is required")
+			}
+		}
+	}
+
+	// Проверяем журнал доступа
+	if al := c.AccessLog; al != nil {
+		if al.QueueSize < 0 {
+			v.add("accessLog.queueSize", al.QueueSize, "must not be negative")
+		}
+		if al.Workers < 0 {
+			v.add("accessLog.workers", al.Workers, "must not be negative")
+		}
+	}
+
+	// Проверяем GeoIP
+	if g := c.GeoIP; g != nil {
+		if g.Database == "" && g.ASNDatabase == "" {
+			v.add("geoIP.database", nil, "database or asnDatabase is required")
+		}
+		for i, z := range g.Zones {
+			item := fmt.Sprintf("geoIP.zones[%d]", i)
+			if z.Zone == "" {
+				v.add(item+".zone", nil, "is required")
+			}
+			if len(z.Countries) == 0 && len(z.ASNs) == 0 {
+				v.add(item+".countries", nil, "countries or asns is required")
+			}
+		}
+	}
+
+	// Проверяем доверенные прокси
+	for i, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			v.add(fmt.Sprintf("trustedProxies[%d]", i), proxy, "must be an IP address or CIDR network")
+		}
+	}
+
+	if f := c.ForwardedHeaders; f != nil {
+		switch f.Mode {
+		case "", "append", "overwrite":
+		default:
+			v.add("forwardedHeaders.mode", f.Mode, "must be append or overwrite")
+		}
+		if f.By != "" && !f.Forwarded {
+			v.add("forwardedHeaders.by", f.By, "requires forwarded: true")
+		}
+	}
+
+	// Проверяем настройки истории
+	if c.History != nil && c.History.Size < 0 {
+		v.add("history.size", c.History.Size, "must not be negative")
+	}
+
+	// Проверяем listener'ы прокси
+	validateListeners(v, c.Listeners, c.Plugins)
+	if c.Process != nil {
+		c.Process.validateInto(v, c.Listeners)
+	}
+	if c.HA != nil {
+		c.HA.validateInto(v, c.Process)
+	}
+	if c.Gossip != nil {
+		c.Gossip.validateInto(v, c.Process)
+	}
+
+	// Проверяем административное API
+	if c.Admin != nil {
+		c.Admin.validateInto(v)
+	}
+}
+
+// validateBackends проверяет список бэкендов: обязательные поля, уникальность ID и параметры подключения
+func validateBackends(v *validator, field string, backends []BackendConfig) {
+	seen := make(map[string]int, len(backends))
+	for i, b := range backends {
+		item := fmt.Sprintf("%s[%d]", field, i)
+
+		if b.ID == "" {
+			v.add(item+".id", nil, "is required")
+		} else if first, ok := seen[b.ID]; ok {
+			v.add(item+".id", b.ID, "duplicates %s[%d].id", field, first)
+		} else {
+			seen[b.ID] = i
+		}
+		validateBackend(v, item, b)
+	}
+}
+
+// ValidateBackend проверяет бэкенд, добавляемый в работающий балансировщик, по тем же
+// правилам, что и бэкенды конфигурации. Возвращает *ValidationError.
+func ValidateBackend(b BackendConfig) error {
+	v := &validator{}
+	if b.ID == "" {
+		v.add("backend.id", nil, "is required")
+	}
+	validateBackend(v, "backend", b)
+	return v.err()
+}
+
+// validateBackend проверяет адрес и параметры подключения бэкенда
+func validateBackend(v *validator, item string, b BackendConfig) {
+	v.checkURL(item+".url", b.URL)
+
+	if b.Weight != nil && *b.Weight <= 0 {
+		v.add(item+".weight", *b.Weight, "must be positive")
+	}
+	if b.ConnectTimeout < 0 {
+		v.add(item+".connectTimeout", b.ConnectTimeout, "must not be negative")
+	}
+	if b.ReadTimeout < 0 {
+		v.add(item+".readTimeout", b.ReadTimeout, "must not be negative")
+	}
+	if b.MaxConnections < 0 {
+		v.add(item+".maxConnections", b.MaxConnections, "must not be negative")
+	}
+	if b.DNSRefreshInterval < 0 {
+		v.add(item+".dnsRefreshInterval", b.DNSRefreshInterval, "must not be negative")
+	}
+	if b.AdaptiveConcurrency != nil {
+		b.AdaptiveConcurrency.validateInto(v, item+".adaptiveConcurrency")
+	}
+	validateBackendProtocol(v, item+".protocol", b.Protocol)
+	if b.HealthCheck != nil {
+		b.HealthCheck.validateInto(v, item+".healthCheck")
+	}
+	if b.TLS != nil && b.TLS.SessionCacheSize < -1 {
+		v.add(item+".tls.sessionCacheSize", b.TLS.SessionCacheSize, "must be -1 or greater")
 	}
 
-	switch c.Logger.LogLevel {
-	case "debug", "info", "warn", "error", "fatal":
-		// OK
+	for name := range b.Headers {
+		switch {
+		case name == "" || strings.ContainsAny(name, ": \t\r\n"):
+			v.add(item+".headers", name, "invalid header name")
+		case strings.EqualFold(name, "Host"):
+			v.add(item+".headers", name, "use host to override the Host header")
+		}
+	}
+}
+
+// validateBackendProtocol проверяет протокол запросов к бэкенду
+func validateBackendProtocol(v *validator, field, protocol string) {
+	switch protocol {
+	case "", "http", "h2c":
 	default:
-		return fmt.Errorf("unsupported log level: %s", c.Logger.LogLevel)
+		v.add(field, protocol, "must be http or h2c")
 	}
+}
 
-	if c.Logger.ServiceName == "" {
-		return fmt.Errorf("logger service name is required")
+// validateInto проверяет способ проверки здоровья бэкенда
+func (h *BackendHealthCheckConfig) validateInto(v *validator, field string) {
+	switch h.Type {
+	case "", "http":
+		if h.Path != "" && !strings.HasPrefix(h.Path, "/") {
+			v.add(field+".path", h.Path, "must start with /")
+		}
+	case "tcp", "tls":
+		if h.Address != "" {
+			if _, port, err := net.SplitHostPort(h.Address); err != nil || port == "" {
+				v.add(field+".address", h.Address, "must be in host:port format")
+			}
+		}
+	case "grpc":
+	case "command":
+		if len(h.Command) == 0 || h.Command[0] == "" {
+			v.add(field+".command", nil, "is required")
+		}
+	default:
+		v.add(field+".type", h.Type, "must be http, tcp, tls, grpc or command")
 	}
+}
 
-	return nil
+// validateInto проверяет настройки адаптивного лимита
+func (a *AdaptiveConcurrencyConfig) validateInto(v *validator, field string) {
+	switch a.Algorithm {
+	case "", "gradient", "aimd":
+	default:
+		v.add(field+".algorithm", a.Algorithm, "must be gradient or aimd")
+	}
+	if a.InitialLimit < 0 {
+		v.add(field+".initialLimit", a.InitialLimit, "must not be negative")
+	}
+	if a.MinLimit < 0 {
+		v.add(field+".minLimit", a.MinLimit, "must not be negative")
+	}
+	if a.MaxLimit < 0 || (a.MaxLimit > 0 && a.MaxLimit < a.MinLimit) {
+		v.add(field+".maxLimit", a.MaxLimit, "must not be less than minLimit")
+	}
+	if a.Tolerance != 0 && a.Tolerance < 1 {
+		v.add(field+".tolerance", a.Tolerance, "must be at least 1")
+	}
+	if a.Smoothing < 0 || a.Smoothing > 1 {
+		v.add(field+".smoothing", a.Smoothing, "must be between 0 and 1")
+	}
+	if a.LatencyThreshold < 0 {
+		v.add(field+".latencyThreshold", a.LatencyThreshold, "must not be negative")
+	}
+	if a.BackoffRatio < 0 || a.BackoffRatio >= 1 {
+		v.add(field+".backoffRatio", a.BackoffRatio, "must be between 0 and 1")
+	}
+}
+
+// validateInto проверяет настройки административного API. Значения секретов в ошибки не попадают.
+func (a *AdminConfig) validateInto(v *validator) {
+	if a.Port == "" {
+		v.add("admin.port", nil, "is required")
+	}
+
+	if a.TLS != nil {
+		if a.TLS.CertFile == "" || a.TLS.KeyFile == "" {
+			v.add("admin.tls", nil, "certFile and keyFile are required")
+		}
+	}
+
+	if a.Auth == nil {
+		return
+	}
+
+	for i, t := range a.Auth.Tokens {
+		field := fmt.Sprintf("admin.auth.tokens[%d]", i)
+		if t.Token == "" {
+			v.add(field+".token", nil, "must not be empty")
+		}
+		if !isValidAdminRole(t.Role) {
+			v.add(field+".role", t.Role, "unsupported admin role")
+		}
+	}
+
+	for i, u := range a.Auth.Users {
+		field := fmt.Sprintf("admin.auth.users[%d]", i)
+		if u.Username == "" || u.Password == "" {
+			v.add(field, nil, "username and password are required")
+		}
+		if !isValidAdminRole(u.Role) {
+			v.add(field+".role", u.Role, "unsupported admin role")
+		}
+	}
+
+	if len(a.Auth.ClientCerts) > 0 && (a.TLS == nil || a.TLS.ClientCAFile == "") {
+		v.add("admin.auth.clientCerts", nil, "client certificates require admin.tls.clientCAFile")
+	}
+	for i, c := range a.Auth.ClientCerts {
+		field := fmt.Sprintf("admin.auth.clientCerts[%d]", i)
+		if c.CommonName == "" {
+			v.add(field+".commonName", nil, "is required")
+		}
+		if !isValidAdminRole(c.Role) {
+			v.add(field+".role", c.Role, "unsupported admin role")
+		}
+	}
+}
+
+// isValidAdminRole проверяет, поддерживается ли роль административного API
+func isValidAdminRole(role string) bool {
+	switch role {
+	case "read", "admin":
+		return true
+	default:
+		return false
+	}
 }
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+loadBalancer:
+  method: RoundRobin
+backends:
+  - id: backend1
+    url: http://localhost:8081
+    headers:
+      X-Internal-Token: upstream-secret
+logger:
+  logLevel: info
+  serviceName: test
+admin:
+  port: ":9090"
+  token: legacy-secret
+  auth:
+    tokens:
+      - token: viewer-secret
+        role: read
+    users:
+      - username: ops
+        password: ops-secret
+        role: admin
+`
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("корректная конфигурация не должна возвращать ошибку: %v", err)
+	}
+	if len(cfg.Backends) != 1 || cfg.Backends[0].ID != "backend1" {
+		t.Errorf("неверный список бэкендов: %+v", cfg.Backends)
+	}
+
+	_, err = Parse([]byte(strings.Replace(testConfig, "RoundRobin", "Random", 1)))
+	if err == nil {
+		t.Error("неподдерживаемый метод балансировки должен возвращать ошибку")
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	cfg, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("ошибка разбора конфигурации: %v", err)
+	}
+
+	redacted := cfg.Redacted()
+	if redacted.Admin.Token != redactedValue ||
+		redacted.Admin.Auth.Tokens[0].Token != redactedValue ||
+		redacted.Admin.Auth.Users[0].Password != redactedValue {
+		t.Errorf("секреты должны быть скрыты: %+v", redacted.Admin)
+	}
+	if redacted.Backends[0].Headers["X-Internal-Token"] != redactedValue {
+		t.Errorf("значения заголовков бэкендов должны быть скрыты: %v", redacted.Backends[0].Headers)
+	}
+	if redacted.Admin.Auth.Users[0].Username != "ops" || redacted.Admin.Auth.Tokens[0].Role != "read" {
+		t.Error("несекретные поля должны сохраняться")
+	}
+
+	// Исходная конфигурация не должна изменяться
+	if cfg.Admin.Token != "legacy-secret" || cfg.Admin.Auth.Users[0].Password != "ops-secret" ||
+		cfg.Backends[0].Headers["X-Internal-Token"] != "upstream-secret" {
+		t.Error("исходная конфигурация не должна изменяться")
+	}
+}
+
+func TestParse_ReportsAllErrors(t *testing.T) {
+	data := `
+loadBalancer:
+  method: Random
+backends:
+  - id: backend1
+    url: localhost:8081
+  - id: backend1
+    url: http://localhost:8082
+    connectTimeout: -1s
+logger:
+  logLevel: info
+  serviceName: test
+  unknownKey: true
+`
+	_, err := Parse([]byte(data))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("ожидалась ошибка проверки, получено: %v", err)
+	}
+
+	fields := make(map[string]bool)
+	for _, e := range validationErr.Errors {
+		fields[e.Field] = true
+	}
+	for _, field := range []string{"loadBalancer.method", "backends[0].url", "backends[1].id", "backends[1].connectTimeout", ""} {
+		if !fields[field] {
+			t.Errorf("не найдена ошибка для поля %q: %v", field, err)
+		}
+	}
+}
+
+func TestParse_Listeners(t *testing.T) {
+	data := `
+loadBalancer:
+  method: RoundRobin
+backends:
+  - id: backend1
+    url: http://localhost:8081
+logger:
+  logLevel: info
+listeners:
+  - name: http
+    address: ":80"
+    redirectToHTTPS: true
+  - name: http
+    address: "80"
+  - name: https
+    address: ":443"
+    tls:
+      certFile: cert.pem
+    redirectToHTTPS: true
+    middlewares:
+      - name: unknown
+`
+	_, err := Parse([]byte(data))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("ожидалась ошибка проверки, получено: %v", err)
+	}
+
+	fields := make(map[string]bool)
+	for _, e := range validationErr.Errors {
+		fields[e.Field] = true
+	}
+	for _, field := range []string{"listeners[1].name", "listeners[1].address", "listeners[2].tls", "listeners[2].redirectToHTTPS", "listeners[2].middlewares[0].name"} {
+		if !fields[field] {
+			t.Errorf("не найдена ошибка для поля %q: %v", field, err)
+		}
+	}
+	if fields["listeners[0].name"] || fields["listeners[0].address"] {
+		t.Errorf("корректный listener не должен давать ошибок: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cloud.ru_test/internal/consulclient"
+)
+
+// ConsulSource читает конфигурацию из ключа Consul KV и отслеживает его
+// изменения с помощью блокирующих запросов
+type ConsulSource struct {
+	key    string
+	format string
+	client *consulclient.Client
+}
+
+// NewConsulSource создает источник конфигурации Consul KV. Если токен не задан,
+// используется переменная окружения CONSUL_HTTP_TOKEN.
+func NewConsulSource(endpoint, key, token, format string) *ConsulSource {
+	return &ConsulSource{
+		key:    key,
+		format: format,
+		client: consulclient.New(endpoint, token),
+	}
+}
+
+// Name возвращает описание источника
+func (s *ConsulSource) Name() string {
+	return fmt.Sprintf("consul %s/%s", s.client.Address(), s.key)
+}
+
+// Format возвращает формат хранимой конфигурации
+func (s *ConsulSource) Format() string {
+	return s.format
+}
+
+// Fetch возвращает текущее значение ключа
+func (s *ConsulSource) Fetch(ctx context.Context) ([]byte, error) {
+	data, _, err := s.get(ctx, 0)
+	return data, err
+}
+
+// Watch отслеживает изменения ключа
+func (s *ConsulSource) Watch(ctx context.Context, onChange func(data []byte)) error {
+	var index uint64
+	for {
+		data, newIndex, err := s.get(ctx, index)
+		if err != nil {
+			return err
+		}
+
+		// Индекс может уменьшиться, например после восстановления Consul из снапшота
+		if newIndex < index {
+			index = 0
+			continue
+		}
+
+		if newIndex != index {
+			index = newIndex
+			onChange(data)
+		}
+	}
+}
+
+// get читает значение ключа. При ненулевом index запрос блокируется до изменения ключа.
+func (s *ConsulSource) get(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	query := url.Values{}
+	query.Set("raw", "")
+
+	// Ответ на блокирующий запрос приходит не позже wait с небольшим запасом
+	ctx, cancel := context.WithTimeout(ctx, consulclient.WaitTime+30*time.Second)
+	defer cancel()
+
+	resp, err := s.client.Get(ctx, "/v1/kv/"+s.key, query, index)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, 0, fmt.Errorf("consul key %s not found", s.key)
+	default:
+		return nil, 0, consulclient.StatusError(resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading consul response: %w", err)
+	}
+
+	newIndex, err := consulclient.Index(resp)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return data, newIndex, nil
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// discoveryTypes встроенные и зарегистрированные типы источников обнаружения
+var (
+	discoveryTypesMu sync.RWMutex
+	discoveryTypes   = map[string]bool{
+		"file":       true,
+		"kubernetes": true,
+		"consul":     true,
+		"dns":        true,
+		"docker":     true,
+	}
+)
+
+// RegisterDiscoveryType разрешает в конфигурации дополнительный тип источника обнаружения.
+// Настройки такого источника передаются в params.
+func RegisterDiscoveryType(name string) {
+	discoveryTypesMu.Lock()
+	defer discoveryTypesMu.Unlock()
+	discoveryTypes[name] = true
+}
+
+// DiscoveryConfig настройки источника динамического обнаружения бэкендов
+type DiscoveryConfig struct {
+	// Тип источника: file, kubernetes, consul, dns, docker или зарегистрированный тип
+	Type string `yaml:"type"`
+
+	// Вес обнаруженных бэкендов (по умолчанию 1)
+	Weight *float64 `yaml:"weight,omitempty"`
+
+	// Параметры подключения к обнаруженным бэкендам
+	ConnectTimeout time.Duration `yaml:"connectTimeout,omitempty"`
+	ReadTimeout    time.Duration `yaml:"readTimeout,omitempty"`
+	MaxConnections int           `yaml:"maxConnections,omitempty"`
+
+	// Адаптивный лимит одновременных запросов к обнаруженным бэкендам
+	AdaptiveConcurrency *AdaptiveConcurrencyConfig `yaml:"adaptiveConcurrency,omitempty"`
+
+	// Протокол запросов к обнаруженным бэкендам: http (по умолчанию) или h2c
+	Protocol string `yaml:"protocol,omitempty"`
+
+	// Способ проверки здоровья обнаруженных бэкендов
+	HealthCheck *BackendHealthCheckConfig `yaml:"healthCheck,omitempty"`
+
+	// Настройки чтения бэкендов из отдельного файла
+	File *FileDiscoveryConfig `yaml:"file,omitempty"`
+
+	// Настройки обнаружения через Kubernetes EndpointSlices
+	Kubernetes *KubernetesDiscoveryConfig `yaml:"kubernetes,omitempty"`
+
+	// Настройки обнаружения через каталог Consul
+	Consul *ConsulDiscoveryConfig `yaml:"consul,omitempty"`
+
+	// Настройки обнаружения через DNS
+	DNS *DNSDiscoveryConfig `yaml:"dns,omitempty"`
+
+	// Настройки обнаружения контейнеров Docker по меткам
+	Docker *DockerDiscoveryConfig `yaml:"docker,omitempty"`
+
+	// Параметры зарегистрированных типов источников
+	Params map[string]interface{} `yaml:"params,omitempty"`
+}
+
+// FileDiscoveryConfig настройки чтения бэкендов из файла, отслеживаемого на изменения
+type FileDiscoveryConfig struct {
+	// Путь к файлу YAML или JSON с секцией backends
+	Path string `yaml:"path"`
+}
+
+// KubernetesDiscoveryConfig настройки обнаружения подов сервиса Kubernetes
+type KubernetesDiscoveryConfig struct {
+	// Имя сервиса
+	Service string `yaml:"service"`
+
+	// Пространство имен (по умолчанию пространство имен пода прокси или default)
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// Имя порта сервиса (по умолчанию первый порт)
+	Port string `yaml:"port,omitempty"`
+
+	// Схема подключения к подам: http или https
+	Scheme string `yaml:"scheme,omitempty"`
+
+	// Использовать только поды из перечисленных зон (по умолчанию все зоны)
+	Zones []string `yaml:"zones,omitempty"`
+
+	// Адрес API сервера (по умолчанию in-cluster адрес из KUBERNETES_SERVICE_HOST)
+	APIServer string `yaml:"apiServer,omitempty"`
+
+	// Файлы токена и CA сервисного аккаунта (по умолчанию из /var/run/secrets/kubernetes.io/serviceaccount)
+	TokenFile string `yaml:"tokenFile,omitempty"`
+	CAFile    string `yaml:"caFile,omitempty"`
+}
+
+// ConsulDiscoveryConfig настройки обнаружения экземпляров сервиса в Consul
+type ConsulDiscoveryConfig struct {
+	// Адрес агента Consul (по умолчанию CONSUL_HTTP_ADDR или http://127.0.0.1:8500)
+	Address string `yaml:"address,omitempty"`
+
+	// Имя сервиса
+	Service string `yaml:"service"`
+
+	// Использовать только экземпляры с указанным тегом
+	Tag string `yaml:"tag,omitempty"`
+
+	// Датацентр (по умолчанию датацентр агента)
+	Datacenter string `yaml:"datacenter,omitempty"`
+
+	// ACL токен (по умолчанию CONSUL_HTTP_TOKEN)
+	Token string `yaml:"token,omitempty"`
+
+	// Схема подключения к экземплярам: http или https
+	Scheme string `yaml:"scheme,omitempty"`
+
+	// Включать экземпляры, не прошедшие проверки здоровья Consul
+	IncludeUnhealthy bool `yaml:"includeUnhealthy,omitempty"`
+}
+
+// DNSDiscoveryConfig настройки обнаружения экземпляров через DNS записи SRV или A/AAAA
+type DNSDiscoveryConfig struct {
+	// Имя для разрешения, например payments.service.consul или _http._tcp.payments.local
+	Name string `yaml:"name"`
+
+	// Тип записи: SRV (по умолчанию) или A (A и AAAA)
+	RecordType string `yaml:"recordType,omitempty"`
+
+	// Порт экземпляров для записей A
+	Port int `yaml:"port,omitempty"`
+
+	// Схема подключения к экземплярам: http или https
+	Scheme string `yaml:"scheme,omitempty"`
+
+	// Интервал повторного разрешения (по умолчанию 30s)
+	RefreshInterval time.Duration `yaml:"refreshInterval,omitempty"`
+
+	// Адрес DNS сервера (host:port), по умолчанию системный резолвер
+	Resolver string `yaml:"resolver,omitempty"`
+}
+
+// DockerDiscoveryConfig настройки обнаружения контейнеров локального Docker по меткам
+type DockerDiscoveryConfig struct {
+	// Адрес Docker daemon: unix:///path или tcp://host:port (по умолчанию DOCKER_HOST или unix:///var/run/docker.sock)
+	Host string `yaml:"host,omitempty"`
+
+	// Префикс меток (по умолчанию lb): lb.enable, lb.port, lb.weight, lb.scheme
+	LabelPrefix string `yaml:"labelPrefix,omitempty"`
+
+	// Сеть, адрес в которой используется для подключения (по умолчанию первая сеть контейнера)
+	Network string `yaml:"network,omitempty"`
+}
+
+// validateInto проверяет настройки источника обнаружения
+func (d *DiscoveryConfig) validateInto(v *validator, field string) {
+	if d.Weight != nil && *d.Weight <= 0 {
+		v.add(field+".weight", *d.Weight, "must be positive")
+	}
+	if d.ConnectTimeout < 0 {
+		v.add(field+".connectTimeout", d.ConnectTimeout, "must not be negative")
+	}
+	if d.ReadTimeout < 0 {
+		v.add(field+".readTimeout", d.ReadTimeout, "must not be negative")
+	}
+	if d.MaxConnections < 0 {
+		v.add(field+".maxConnections", d.MaxConnections, "must not be negative")
+	}
+	if d.AdaptiveConcurrency != nil {
+		d.AdaptiveConcurrency.validateInto(v, field+".adaptiveConcurrency")
+	}
+	validateBackendProtocol(v, field+".protocol", d.Protocol)
+	if d.HealthCheck != nil {
+		d.HealthCheck.validateInto(v, field+".healthCheck")
+	}
+
+	switch d.Type {
+	case "file":
+		if d.File == nil || d.File.Path == "" {
+			v.add(field+".file.path", nil, "is required for file discovery")
+		}
+	case "kubernetes":
+		if d.Kubernetes == nil {
+			v.add(field+".kubernetes", nil, "is required for kubernetes discovery")
+			return
+		}
+		if d.Kubernetes.Service == "" {
+			v.add(field+".kubernetes.service", nil, "is required")
+		}
+		checkScheme(v, field+".kubernetes.scheme", d.Kubernetes.Scheme)
+	case "consul":
+		if d.Consul == nil {
+			v.add(field+".consul", nil, "is required for consul discovery")
+			return
+		}
+		if d.Consul.Service == "" {
+			v.add(field+".consul.service", nil, "is required")
+		}
+		checkScheme(v, field+".consul.scheme", d.Consul.Scheme)
+	case "dns":
+		if d.DNS == nil {
+			v.add(field+".dns", nil, "is required for dns discovery")
+			return
+		}
+		if d.DNS.Name == "" {
+			v.add(field+".dns.name", nil, "is required")
+		}
+		switch d.DNS.RecordType {
+		case "", "SRV":
+		case "A":
+			if d.DNS.Port <= 0 || d.DNS.Port > 65535 {
+				v.add(field+".dns.port", d.DNS.Port, "must be a valid port for A records")
+			}
+		default:
+			v.add(field+".dns.recordType", d.DNS.RecordType, "must be SRV or A")
+		}
+		if d.DNS.RefreshInterval < 0 {
+			v.add(field+".dns.refreshInterval", d.DNS.RefreshInterval, "must not be negative")
+		}
+		checkScheme(v, field+".dns.scheme", d.DNS.Scheme)
+	case "docker":
+		// Все параметры необязательны, секция может отсутствовать
+		if d.Docker != nil && d.Docker.Host != "" &&
+			!strings.HasPrefix(d.Docker.Host, "unix://") && !strings.HasPrefix(d.Docker.Host, "tcp://") {
+			v.add(field+".docker.host", d.Docker.Host, "must start with unix:// or tcp://")
+		}
+	case "":
+		v.add(field+".type", nil, "is required")
+	default:
+		discoveryTypesMu.RLock()
+		registered := discoveryTypes[d.Type]
+		discoveryTypesMu.RUnlock()
+		if !registered {
+			v.add(field+".type", d.Type, "unsupported discovery type")
+		}
+	}
+}
+
+// LoadBackends читает и проверяет список бэкендов из файла YAML или JSON вида
+// {backends: [...]}; используется источником обнаружения file
+func LoadBackends(path string) ([]BackendConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading backends file: %w", err)
+	}
+
+	var file struct {
+		Backends []BackendConfig `yaml:"backends"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("error parsing backends file: %w", err)
+	}
+
+	v := &validator{}
+	validateBackends(v, "backends", file.Backends)
+	if err := v.err(); err != nil {
+		return nil, fmt.Errorf("invalid backends file: %w", err)
+	}
+
+	return file.Backends, nil
+}
+
+// checkScheme проверяет схему подключения к обнаруженным бэкендам
+func checkScheme(v *validator, field, scheme string) {
+	switch scheme {
+	case "", "http", "https":
+	default:
+		v.add(field, scheme, "must be http or https")
+	}
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// EtcdSource читает конфигурацию из ключа etcd через HTTP/JSON шлюз API v3
+// и отслеживает его изменения с помощью watch
+type EtcdSource struct {
+	endpoint string
+	key      string
+	format   string
+	client   *http.Client
+}
+
+// etcdHeader заголовок ответа etcd
+type etcdHeader struct {
+	Revision int64 `json:"revision,string"`
+}
+
+// etcdKeyValue пара ключ-значение etcd (байтовые поля передаются в base64)
+type etcdKeyValue struct {
+	Value []byte `json:"value"`
+}
+
+// etcdRangeResponse ответ на запрос /v3/kv/range
+type etcdRangeResponse struct {
+	Header etcdHeader     `json:"header"`
+	Kvs    []etcdKeyValue `json:"kvs"`
+}
+
+// etcdWatchResponse сообщение потока /v3/watch
+type etcdWatchResponse struct {
+	Result *struct {
+		Canceled     bool   `json:"canceled"`
+		CancelReason string `json:"cancel_reason"`
+		Events       []struct {
+			Type string       `json:"type"`
+			Kv   etcdKeyValue `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewEtcdSource создает источник конфигурации etcd
+func NewEtcdSource(endpoint, key, format string) *EtcdSource {
+	return &EtcdSource{
+		endpoint: endpoint,
+		key:      key,
+		format:   format,
+		client:   &http.Client{},
+	}
+}
+
+// Name возвращает описание источника
+func (s *EtcdSource) Name() string {
+	return fmt.Sprintf("etcd %s%s", s.endpoint, s.key)
+}
+
+// Format возвращает формат хранимой конфигурации
+func (s *EtcdSource) Format() string {
+	return s.format
+}
+
+// Fetch возвращает текущее значение ключа
+func (s *EtcdSource) Fetch(ctx context.Context) ([]byte, error) {
+	data, _, err := s.get(ctx)
+	return data, err
+}
+
+// Watch отслеживает изменения ключа начиная с ревизии, следующей за прочитанной
+func (s *EtcdSource) Watch(ctx context.Context, onChange func(data []byte)) error {
+	data, revision, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+	onChange(data)
+
+	request := map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":            []byte(s.key),
+			"start_revision": revision + 1,
+		},
+	}
+	resp, err := s.post(ctx, "/v3/watch", request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message etcdWatchResponse
+		if err := decoder.Decode(&message); err != nil {
+			return fmt.Errorf("etcd watch stream closed: %w", err)
+		}
+
+		if message.Error != nil {
+			return fmt.Errorf("etcd watch error: %s", message.Error.Message)
+		}
+		if message.Result == nil {
+			continue
+		}
+		if message.Result.Canceled {
+			return fmt.Errorf("etcd watch canceled: %s", message.Result.CancelReason)
+		}
+
+		for _, event := range message.Result.Events {
+			if event.Type == "DELETE" {
+				return fmt.Errorf("etcd key %s was deleted", s.key)
+			}
+			onChange(event.Kv.Value)
+		}
+	}
+}
+
+// get читает значение ключа и текущую ревизию
+func (s *EtcdSource) get(ctx context.Context) ([]byte, int64, error) {
+	resp, err := s.post(ctx, "/v3/kv/range", map[string]interface{}{"key": []byte(s.key)})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	var result etcdRangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, 0, fmt.Errorf("error decoding etcd response: %w", err)
+	}
+	if len(result.Kvs) == 0 {
+		return nil, 0, fmt.Errorf("etcd key %s not found", s.key)
+	}
+
+	return result.Kvs[0].Value, result.Header.Revision, nil
+}
+
+// post отправляет JSON запрос к шлюзу etcd
+func (s *EtcdSource) post(ctx context.Context, path string, request interface{}) (*http.Response, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode etcd request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create etcd request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("etcd request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("etcd returned %s: %s", resp.Status, message)
+	}
+
+	return resp, nil
+}
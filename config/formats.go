@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Поддерживаемые форматы конфигурации
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// DetectFormat определяет формат конфигурации по расширению файла.
+// Возвращает пустую строку для неподдерживаемых расширений.
+func DetectFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	default:
+		return ""
+	}
+}
+
+// Load загружает конфигурацию из файла или из каталога фрагментов (conf.d)
+func Load(path string) (*Config, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %w", err)
+	}
+
+	if info.IsDir() {
+		return LoadFromDirectory(path)
+	}
+	return LoadFromFile(path)
+}
+
+// LoadFromDirectory загружает и объединяет все файлы конфигурации каталога в алфавитном порядке.
+// Словари объединяются рекурсивно (значения из более поздних файлов имеют приоритет),
+// списки (например, backends) дополняются.
+func LoadFromDirectory(dir string) (*Config, error) {
+	files, err := ListConfigFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no config files found in %s", dir)
+	}
+
+	merged := make(map[string]interface{})
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config file: %w", err)
+		}
+
+		fragment, err := decodeRaw(data, DetectFormat(file))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing config file %s: %w", filepath.Base(file), err)
+		}
+		mergeRaw(merged, fragment)
+	}
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("error merging config files: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// ListConfigFiles возвращает отсортированный список файлов конфигурации в каталоге
+func ListConfigFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config directory: %w", err)
+	}
+
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		// Скрытые файлы пропускаем: это могут быть временные файлы редакторов
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || DetectFormat(entry.Name()) == "" {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// ParseFormat разбирает и проверяет конфигурацию в указанном формате
+func ParseFormat(data []byte, format string) (*Config, error) {
+	switch format {
+	case FormatYAML, FormatJSON:
+		// JSON является подмножеством YAML
+		return Parse(data)
+	case FormatTOML:
+		raw, err := decodeRaw(data, format)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing config file: %w", err)
+		}
+		converted, err := yaml.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("error converting config: %w", err)
+		}
+		return Parse(converted)
+	default:
+		return nil, fmt.Errorf("unsupported config format: %q", format)
+	}
+}
+
+// decodeRaw разбирает фрагмент конфигурации в словарь без проверки структуры
+func decodeRaw(data []byte, format string) (map[string]interface{}, error) {
+	raw := make(map[string]interface{})
+
+	switch format {
+	case FormatYAML, FormatJSON:
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		normalizeTOML(raw)
+	default:
+		return nil, fmt.Errorf("unsupported config format: %q", format)
+	}
+
+	return raw, nil
+}
+
+// mergeRaw рекурсивно объединяет фрагмент src в dst
+func mergeRaw(dst, src map[string]interface{}) {
+	for key, value := range src {
+		existing, ok := dst[key]
+		if !ok {
+			dst[key] = value
+			continue
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if existingMap, ok := existing.(map[string]interface{}); ok {
+				mergeRaw(existingMap, v)
+				continue
+			}
+		case []interface{}:
+			if existingList, ok := existing.([]interface{}); ok {
+				dst[key] = append(existingList, v...)
+				continue
+			}
+		}
+
+		dst[key] = value
+	}
+}
+
+// normalizeTOML приводит массивы таблиц TOML ([]map[string]interface{}) к спискам,
+// которые возвращает YAML декодер, чтобы фрагменты разных форматов объединялись одинаково
+func normalizeTOML(raw map[string]interface{}) {
+	for key, value := range raw {
+		switch v := value.(type) {
+		case []map[string]interface{}:
+			list := make([]interface{}, len(v))
+			for i, item := range v {
+				normalizeTOML(item)
+				list[i] = item
+			}
+			raw[key] = list
+		case map[string]interface{}:
+			normalizeTOML(v)
+		}
+	}
+}
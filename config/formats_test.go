@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("ошибка записи файла %s: %v", name, err)
+	}
+}
+
+func TestLoadFromDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile(t, dir, "00-main.yaml", `
+loadBalancer:
+  method: RoundRobin
+logger:
+  logLevel: info
+  serviceName: test
+backends:
+  - id: backend1
+    url: http://localhost:8081
+    connectTimeout: 5s
+`)
+	writeFile(t, dir, "10-backends.toml", `
+[[backends]]
+id = "backend2"
+url = "http://localhost:8082"
+weight = 2.0
+connectTimeout = "3s"
+`)
+	writeFile(t, dir, "20-logger.json", `{"logger": {"logLevel": "debug"}}`)
+	writeFile(t, dir, "README.md", "не конфигурация")
+	writeFile(t, dir, ".00-main.yaml.swp", "временный файл редактора")
+
+	cfg, err := LoadFromDirectory(dir)
+	if err != nil {
+		t.Fatalf("ошибка загрузки каталога: %v", err)
+	}
+
+	if len(cfg.Backends) != 2 || cfg.Backends[0].ID != "backend1" || cfg.Backends[1].ID != "backend2" {
+		t.Fatalf("бэкенды из разных файлов должны объединяться: %+v", cfg.Backends)
+	}
+	if cfg.Backends[1].Weight == nil || *cfg.Backends[1].Weight != 2 || cfg.Backends[1].ConnectTimeout.Seconds() != 3 {
+		t.Errorf("неверные параметры бэкенда из TOML: %+v", cfg.Backends[1])
+	}
+	if cfg.Logger.LogLevel != "debug" || cfg.Logger.ServiceName != "test" {
+		t.Errorf("секции должны объединяться рекурсивно: %+v", cfg.Logger)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	json := `{"loadBalancer": {"method": "LeastConnections"}, "backends": [{"id": "b", "url": "http://b", "readTimeout": "1s"}], "logger": {"logLevel": "warn", "serviceName": "s"}}`
+	cfg, err := ParseFormat([]byte(json), FormatJSON)
+	if err != nil {
+		t.Fatalf("ошибка разбора JSON: %v", err)
+	}
+	if cfg.LoadBalancer.Method != "LeastConnections" || cfg.Backends[0].ReadTimeout.Seconds() != 1 {
+		t.Errorf("неверная конфигурация из JSON: %+v", cfg)
+	}
+
+	if _, err := ParseFormat([]byte(json), "ini"); err == nil {
+		t.Error("неподдерживаемый формат должен возвращать ошибку")
+	}
+}
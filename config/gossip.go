@@ -0,0 +1,85 @@
+package config
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+// GossipConfig обмен состоянием между экземплярами прокси. Экземпляры периодически
+// отправляют друг другу обнаруженные сбои бэкендов и число пропущенных запросов
+// клиентов, чтобы быстрее исключать недоступные бэкенды и соблюдать общий rate limit.
+// Изменения применяются после перезапуска.
+type GossipConfig struct {
+	// Адрес, на котором экземпляр принимает состояние других экземпляров, например ":7946"
+	Listen string `yaml:"listen"`
+
+	// Адреса других экземпляров (host:port)
+	Peers []string `yaml:"peers,omitempty"`
+
+	// Имя DNS, записи A/AAAA которого перечисляют экземпляры; порт берется из listen
+	DNSName string `yaml:"dnsName,omitempty"`
+
+	// Период отправки состояния (по умолчанию 1s)
+	Interval time.Duration `yaml:"interval,omitempty"`
+
+	// Общий ключ HMAC подписи сообщений; сообщения без верной подписи отклоняются
+	Secret string `yaml:"secret,omitempty"`
+
+	// Имя экземпляра в сообщениях (по умолчанию имя хоста и pid)
+	Node string `yaml:"node,omitempty"`
+
+	// Чем обмениваться: health и/или rateLimit (по умолчанию всем)
+	Share []string `yaml:"share,omitempty"`
+}
+
+// Shares проверяет, что экземпляры обмениваются указанным состоянием
+func (g *GossipConfig) Shares(kind string) bool {
+	if len(g.Share) == 0 {
+		return true
+	}
+	for _, share := range g.Share {
+		if share == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// validateInto проверяет настройки обмена состоянием
+func (g *GossipConfig) validateInto(v *validator, process *ProcessConfig) {
+	_, port, err := net.SplitHostPort(g.Listen)
+	if g.Listen == "" {
+		v.add("gossip.listen", nil, "is required")
+	} else if err != nil {
+		v.add("gossip.listen", g.Listen, "must be host:port")
+	}
+
+	if len(g.Peers) == 0 && g.DNSName == "" {
+		v.add("gossip.peers", nil, "peers or dnsName is required")
+	}
+	for i, peer := range g.Peers {
+		if _, _, err := net.SplitHostPort(peer); err != nil {
+			v.add("gossip.peers["+strconv.Itoa(i)+"]", peer, "must be host:port")
+		}
+	}
+	if g.DNSName != "" && port == "0" {
+		v.add("gossip.listen", g.Listen, "must have a fixed port with dnsName")
+	}
+
+	if g.Interval < 0 {
+		v.add("gossip.interval", g.Interval, "must not be negative")
+	}
+	for i, share := range g.Share {
+		switch share {
+		case "health", "rateLimit":
+		default:
+			v.add("gossip.share["+strconv.Itoa(i)+"]", share, "must be health or rateLimit")
+		}
+	}
+
+	// Рабочие процессы не могут занять один адрес приема сообщений
+	if process != nil && process.Workers > 0 {
+		v.add("gossip", nil, "can not be combined with process.workers")
+	}
+}
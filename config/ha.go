@@ -0,0 +1,130 @@
+package config
+
+import (
+	"time"
+)
+
+// HAConfig режим активный/резервный для пары экземпляров прокси. Экземпляры выбирают
+// лидера через блокировку во внешнем хранилище; при потере лидера блокировку
+// захватывает резервный экземпляр. Изменения применяются после перезапуска.
+type HAConfig struct {
+	// Хранилище блокировки: etcd, consul или kubernetes
+	Backend string `yaml:"backend"`
+
+	// Имя блокировки: ключ etcd или Consul KV, имя Lease в Kubernetes
+	Name string `yaml:"name"`
+
+	// Идентификатор экземпляра в блокировке (по умолчанию имя хоста и pid)
+	Identity string `yaml:"identity,omitempty"`
+
+	// Что делает лидер: listen (по умолчанию) — только лидер занимает адреса listener'ов;
+	// announce — listener'ы работают на обоих экземплярах, а лидер объявляет себя
+	// командами onElected и onDemoted, например добавляя VIP на интерфейс
+	Mode string `yaml:"mode,omitempty"`
+
+	// Время, через которое блокировка лидера, переставшего ее продлевать, освобождается (по умолчанию 15s)
+	LeaseDuration time.Duration `yaml:"leaseDuration,omitempty"`
+
+	// Интервал продления блокировки лидером и попыток захвата резервным экземпляром (по умолчанию leaseDuration/5)
+	RenewInterval time.Duration `yaml:"renewInterval,omitempty"`
+
+	// Команды, выполняемые при получении и потере лидерства: имя программы и аргументы
+	OnElected []string `yaml:"onElected,omitempty"`
+	OnDemoted []string `yaml:"onDemoted,omitempty"`
+
+	// Настройки хранилища
+	Etcd       *EtcdLockConfig       `yaml:"etcd,omitempty"`
+	Consul     *ConsulLockConfig     `yaml:"consul,omitempty"`
+	Kubernetes *KubernetesLockConfig `yaml:"kubernetes,omitempty"`
+}
+
+// EtcdLockConfig подключение к etcd через HTTP/JSON шлюз API v3
+type EtcdLockConfig struct {
+	// Адрес etcd, например http://127.0.0.1:2379
+	Endpoint string `yaml:"endpoint"`
+}
+
+// ConsulLockConfig подключение к агенту Consul
+type ConsulLockConfig struct {
+	// Адрес агента Consul (по умолчанию CONSUL_HTTP_ADDR или http://127.0.0.1:8500)
+	Address string `yaml:"address,omitempty"`
+
+	// ACL токен (по умолчанию CONSUL_HTTP_TOKEN)
+	Token string `yaml:"token,omitempty"`
+}
+
+// KubernetesLockConfig подключение к API серверу Kubernetes для блокировки через coordination.k8s.io Lease
+type KubernetesLockConfig struct {
+	// Пространство имен Lease (по умолчанию пространство имен пода прокси или default)
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// Адрес API сервера (по умолчанию in-cluster адрес из KUBERNETES_SERVICE_HOST)
+	APIServer string `yaml:"apiServer,omitempty"`
+
+	// Файлы токена и CA сервисного аккаунта (по умолчанию из /var/run/secrets/kubernetes.io/serviceaccount)
+	TokenFile string `yaml:"tokenFile,omitempty"`
+	CAFile    string `yaml:"caFile,omitempty"`
+}
+
+// Ограничения режима HA
+const (
+	// defaultHALeaseDuration время жизни блокировки по умолчанию, для проверки renewInterval
+	defaultHALeaseDuration = 15 * time.Second
+
+	// minConsulSessionTTL минимальный TTL сессии Consul
+	minConsulSessionTTL = 10 * time.Second
+)
+
+// validateInto проверяет настройки режима HA
+func (h *HAConfig) validateInto(v *validator, process *ProcessConfig) {
+	switch h.Backend {
+	case "etcd":
+		if h.Etcd == nil || h.Etcd.Endpoint == "" {
+			v.add("ha.etcd.endpoint", nil, "is required for etcd backend")
+		}
+		if h.LeaseDuration%time.Second != 0 {
+			v.add("ha.leaseDuration", h.LeaseDuration, "must be a whole number of seconds for etcd backend")
+		}
+	case "consul":
+		if h.LeaseDuration != 0 && h.LeaseDuration < minConsulSessionTTL {
+			v.add("ha.leaseDuration", h.LeaseDuration, "must be at least %v for consul backend", minConsulSessionTTL)
+		}
+	case "kubernetes":
+		if h.LeaseDuration%time.Second != 0 {
+			v.add("ha.leaseDuration", h.LeaseDuration, "must be a whole number of seconds for kubernetes backend")
+		}
+	case "":
+		v.add("ha.backend", nil, "is required")
+	default:
+		v.add("ha.backend", h.Backend, "must be etcd, consul or kubernetes")
+	}
+	if h.Name == "" {
+		v.add("ha.name", nil, "is required")
+	}
+
+	switch h.Mode {
+	case "", "listen":
+	case "announce":
+		if len(h.OnElected) == 0 {
+			v.add("ha.onElected", nil, "is required in announce mode")
+		}
+	default:
+		v.add("ha.mode", h.Mode, "must be listen or announce")
+	}
+
+	if h.LeaseDuration < 0 {
+		v.add("ha.leaseDuration", h.LeaseDuration, "must not be negative")
+	}
+	lease := h.LeaseDuration
+	if lease == 0 {
+		lease = defaultHALeaseDuration
+	}
+	if h.RenewInterval < 0 || 2*h.RenewInterval >= lease {
+		v.add("ha.renewInterval", h.RenewInterval, "must be less than half of leaseDuration")
+	}
+
+	// Каждый рабочий процесс участвовал бы в выборах самостоятельно
+	if process != nil && process.Workers > 0 {
+		v.add("ha", nil, "can not be combined with process.workers")
+	}
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultHistorySize количество хранимых версий конфигурации по умолчанию
+const defaultHistorySize = 10
+
+// Источники применения версии конфигурации
+const (
+	VersionSourceLoad     = "load"
+	VersionSourceRollback = "rollback"
+)
+
+// HistoryConfig настройки хранения истории примененных конфигураций
+type HistoryConfig struct {
+	// Количество хранимых версий (по умолчанию 10)
+	Size int `yaml:"size,omitempty"`
+
+	// Каталог для сохранения версий на диск, чтобы история переживала перезапуск
+	Dir string `yaml:"dir,omitempty"`
+}
+
+// ConfigVersion успешно примененная версия конфигурации
+type ConfigVersion struct {
+	// Порядковый номер версии
+	Version int `yaml:"version" json:"version"`
+
+	// Время применения
+	AppliedAt time.Time `yaml:"appliedAt" json:"appliedAt"`
+
+	// Источник: load (загрузка из файла) или rollback (откат)
+	Source string `yaml:"source" json:"source"`
+
+	// Версия, к которой выполнен откат
+	RolledBackFrom int `yaml:"rolledBackFrom,omitempty" json:"rolledBackFrom,omitempty"`
+
+	Config *Config `yaml:"config" json:"-"`
+}
+
+// History возвращает сохраненные версии конфигурации, начиная с самой новой
+func (m *ConfigManager) History() []ConfigVersion {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	versions := make([]ConfigVersion, len(m.history))
+	for i, v := range m.history {
+		versions[len(m.history)-1-i] = v
+	}
+	return versions
+}
+
+// GetVersion возвращает версию конфигурации по номеру или nil, если она не сохранилась
+func (m *ConfigManager) GetVersion(version int) *ConfigVersion {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for i := range m.history {
+		if m.history[i].Version == version {
+			v := m.history[i]
+			return &v
+		}
+	}
+	return nil
+}
+
+// Rollback повторно применяет сохраненную версию конфигурации. Файл конфигурации
+// не изменяется, поэтому следующее изменение файла заменит откаченную версию.
+func (m *ConfigManager) Rollback(version int) (*ConfigVersion, error) {
+	target := m.GetVersion(version)
+	if target == nil {
+		return nil, fmt.Errorf("config version %d not found", version)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	applied := m.apply(target.Config, VersionSourceRollback, version)
+	return &applied, nil
+}
+
+// apply делает конфигурацию действующей, записывает ее в историю и уведомляет подписчиков.
+// Вызывается под блокировкой m.mu.
+func (m *ConfigManager) apply(cfg *Config, source string, rolledBackFrom int) ConfigVersion {
+	// История с диска восстанавливается при первом применении, когда известны ее настройки
+	if m.nextVersion == 0 {
+		m.restoreHistory(cfg.History)
+	}
+
+	m.nextVersion++
+	version := ConfigVersion{
+		Version:        m.nextVersion,
+		AppliedAt:      time.Now(),
+		Source:         source,
+		RolledBackFrom: rolledBackFrom,
+		Config:         cfg,
+	}
+
+	m.history = append(m.history, version)
+	if size := historySize(cfg.History); len(m.history) > size {
+		m.history = append([]ConfigVersion(nil), m.history[len(m.history)-size:]...)
+	}
+
+	m.config = cfg
+	m.lastError = nil
+	m.persistVersion(version)
+
+	// Уведомляем подписчиков
+	for _, ch := range m.subscribers {
+		select {
+		case ch <- cfg:
+		default:
+			// Если канал заполнен, пропускаем
+		}
+	}
+
+	return version
+}
+
+// persistVersion сохраняет версию на диск и удаляет версии, вышедшие за пределы истории.
+// Версии со ссылками на переменные окружения и секреты на диск не записываются. Вызывается под блокировкой m.mu.
+func (m *ConfigManager) persistVersion(version ConfigVersion) {
+	settings := m.config.History
+	if settings == nil || settings.Dir == "" {
+		return
+	}
+	// Значения ${ENV} и secret:// уже подставлены и попали бы на диск открытым текстом,
+	// поэтому такие версии хранятся только в памяти
+	if version.Config.interpolated {
+		return
+	}
+
+	if err := writeVersion(settings.Dir, version); err != nil {
+		m.lastError = fmt.Errorf("failed to persist config history: %w", err)
+		return
+	}
+
+	files, err := listVersionFiles(settings.Dir)
+	if err != nil {
+		m.lastError = fmt.Errorf("failed to persist config history: %w", err)
+		return
+	}
+	for len(files) > historySize(settings) {
+		os.Remove(files[0])
+		files = files[1:]
+	}
+}
+
+// restoreHistory загружает сохраненные на диск версии. Поврежденные файлы пропускаются.
+func (m *ConfigManager) restoreHistory(settings *HistoryConfig) {
+	if settings == nil || settings.Dir == "" {
+		return
+	}
+
+	files, err := listVersionFiles(settings.Dir)
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		var version ConfigVersion
+		if err := yaml.Unmarshal(data, &version); err != nil || version.Config == nil {
+			continue
+		}
+		if err := version.Config.validate(); err != nil {
+			continue
+		}
+
+		m.history = append(m.history, version)
+		if version.Version > m.nextVersion {
+			m.nextVersion = version.Version
+		}
+	}
+
+	if size := historySize(settings); len(m.history) > size {
+		m.history = m.history[len(m.history)-size:]
+	}
+}
+
+// writeVersion записывает версию конфигурации в каталог истории
+func writeVersion(dir string, version ConfigVersion) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&version)
+	if err != nil {
+		return err
+	}
+
+	// Файл может содержать секреты, поэтому доступен только владельцу
+	return os.WriteFile(filepath.Join(dir, fmt.Sprintf("v%08d.yaml", version.Version)), data, 0600)
+}
+
+// listVersionFiles возвращает файлы версий в каталоге истории, начиная с самой старой
+func listVersionFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "v") || !strings.HasSuffix(name, ".yaml") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// historySize возвращает количество хранимых версий
+func historySize(settings *HistoryConfig) int {
+	if settings == nil || settings.Size <= 0 {
+		return defaultHistorySize
+	}
+	return settings.Size
+}
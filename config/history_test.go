@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const historyTestConfig = `
+loadBalancer:
+  method: %s
+backends:
+  - id: backend1
+    url: http://localhost:8081
+logger:
+  logLevel: info
+  serviceName: test
+history:
+  size: 2
+  dir: %s
+`
+
+func TestConfigManager_Rollback(t *testing.T) {
+	dir := t.TempDir()
+	historyDir := filepath.Join(dir, "history")
+	path := filepath.Join(dir, "config.yaml")
+
+	write := func(method string) {
+		data := []byte(fmt.Sprintf(historyTestConfig, method, historyDir))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("ошибка записи конфигурации: %v", err)
+		}
+	}
+
+	write("RoundRobin")
+	m, err := NewConfigManager(path)
+	if err != nil {
+		t.Fatalf("ошибка создания менеджера: %v", err)
+	}
+	// Отключаем отслеживание файла, чтобы версии применялись только явно
+	m.Close()
+
+	write("LeastConnections")
+	if _, err := m.loadConfig(); err != nil {
+		t.Fatalf("ошибка загрузки конфигурации: %v", err)
+	}
+
+	applied, err := m.Rollback(1)
+	if err != nil {
+		t.Fatalf("ошибка отката: %v", err)
+	}
+	if applied.Version != 3 || applied.RolledBackFrom != 1 || m.GetConfig().LoadBalancer.Method != "RoundRobin" {
+		t.Errorf("откат должен применить версию 1 как новую версию 3: %+v", applied)
+	}
+
+	history := m.History()
+	if len(history) != 2 || history[0].Version != 3 || history[1].Version != 2 {
+		t.Errorf("история должна быть ограничена двумя последними версиями: %+v", history)
+	}
+	if _, err := m.Rollback(1); err == nil {
+		t.Error("откат к удаленной из истории версии должен возвращать ошибку")
+	}
+
+	// История восстанавливается с диска при перезапуске
+	restarted, err := NewConfigManager(path)
+	if err != nil {
+		t.Fatalf("ошибка создания менеджера: %v", err)
+	}
+	restarted.Close()
+
+	history = restarted.History()
+	if len(history) != 2 || history[0].Version != 4 || history[1].Version != 3 {
+		t.Errorf("история должна продолжиться с сохраненных версий: %+v", history)
+	}
+}
+
+func TestConfigManager_HistoryWithoutSecrets(t *testing.T) {
+	dir := t.TempDir()
+	historyDir := filepath.Join(dir, "history")
+	secretFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(secretFile, []byte("s3cr3t-backend-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LB_TEST_HISTORY_HOST", "env-only-host.internal")
+
+	path := filepath.Join(dir, "config.yaml")
+	data := fmt.Sprintf(`
+loadBalancer:
+  method: RoundRobin
+backends:
+  - id: backend1
+    url: http://localhost:8081
+    host: ${LB_TEST_HISTORY_HOST}
+    headers:
+      X-Token: secret://%s
+logger:
+  logLevel: info
+  serviceName: test
+history:
+  dir: %s
+`, secretFile, historyDir)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewConfigManager(path)
+	if err != nil {
+		t.Fatalf("ошибка создания менеджера: %v", err)
+	}
+	m.Close()
+	if m.GetConfig().Backends[0].Headers["X-Token"] != "s3cr3t-backend-token" {
+		t.Fatal("секрет должен быть подставлен в конфигурацию")
+	}
+	if len(m.History()) != 1 {
+		t.Error("версия с подстановками должна храниться в памяти")
+	}
+
+	filepath.WalkDir(historyDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		content, _ := os.ReadFile(path)
+		if strings.Contains(string(content), "s3cr3t-backend-token") || strings.Contains(string(content), "env-only-host.internal") {
+			t.Errorf("значения секретов и переменных окружения записаны в %s", path)
+		}
+		return nil
+	})
+}
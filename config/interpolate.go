@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretPrefix префикс ссылки на файл с секретом: secret:///run/secrets/admin_token
+const secretPrefix = "secret://"
+
+// envPattern ссылка на переменную окружения: ${NAME} или ${NAME:-значение по умолчанию}
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolate подставляет переменные окружения и секреты в значения YAML документа.
+// Возвращает true, если была выполнена хотя бы одна подстановка.
+func interpolate(node *yaml.Node, v *validator) bool {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return interpolateScalar(node, v)
+	case yaml.MappingNode:
+		// Ключи не изменяем, подставляем только значения
+		changed := false
+		for i := 1; i < len(node.Content); i += 2 {
+			if interpolate(node.Content[i], v) {
+				changed = true
+			}
+		}
+		return changed
+	default:
+		changed := false
+		for _, child := range node.Content {
+			if interpolate(child, v) {
+				changed = true
+			}
+		}
+		return changed
+	}
+}
+
+// interpolateScalar выполняет подстановки в одном значении
+func interpolateScalar(node *yaml.Node, v *validator) bool {
+	if path, ok := strings.CutPrefix(node.Value, secretPrefix); ok {
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			v.add("", nil, "line %d: error reading secret %s: %v", node.Line, path, err)
+			return false
+		}
+
+		// Секрет всегда строка, даже если похож на число
+		node.Value = strings.TrimRight(string(secret), "\r\n")
+		node.Tag = "!!str"
+		node.Style = yaml.DoubleQuotedStyle
+		return true
+	}
+
+	if !strings.Contains(node.Value, "${") {
+		return false
+	}
+
+	node.Value = envPattern.ReplaceAllStringFunc(node.Value, func(ref string) string {
+		match := envPattern.FindStringSubmatch(ref)
+		if value, ok := os.LookupEnv(match[1]); ok {
+			return value
+		}
+		if strings.Contains(ref, ":-") {
+			return match[2]
+		}
+		v.add("", nil, "line %d: environment variable %s is not set", node.Line, match[1])
+		return ""
+	})
+
+	// Тип значения без кавычек определяется после подстановки: port: ${ADMIN_PORT} может быть числом
+	if node.Style == 0 && node.Tag == "!!str" {
+		node.Tag = ""
+	}
+
+	return true
+}
+
+// interpolateData выполняет подстановки в YAML документе и возвращает его заново сериализованным
+func interpolateData(data []byte, v *validator) ([]byte, bool, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, false, err
+	}
+
+	if !interpolate(&root, v) {
+		return data, false, nil
+	}
+
+	interpolated, err := yaml.Marshal(&root)
+	if err != nil {
+		return nil, false, fmt.Errorf("error encoding interpolated config: %w", err)
+	}
+	return interpolated, true, nil
+}
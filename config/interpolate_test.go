@@ -0,0 +1,59 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse_Interpolation(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "admin_token")
+	if err := os.WriteFile(secretFile, []byte("12345\n"), 0600); err != nil {
+		t.Fatalf("ошибка записи секрета: %v", err)
+	}
+
+	t.Setenv("LB_BACKEND_HOST", "10.0.0.5")
+	t.Setenv("LB_RATE", "50")
+
+	data := `
+loadBalancer:
+  method: RoundRobin
+backends:
+  - id: backend1
+    url: http://${LB_BACKEND_HOST}:${LB_BACKEND_PORT:-8081}
+rateLimiter:
+  enabled: true
+  type: TokenBucket
+  tokenBucket:
+    rate: ${LB_RATE}
+    burst: 100
+logger:
+  logLevel: info
+  serviceName: test
+admin:
+  port: ":9090"
+  token: secret://` + secretFile + `
+`
+	cfg, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("ошибка разбора конфигурации: %v", err)
+	}
+	if cfg.Backends[0].URL != "http://10.0.0.5:8081" {
+		t.Errorf("неверная подстановка переменных окружения: %s", cfg.Backends[0].URL)
+	}
+	if cfg.RateLimiter.TokenBucket.Rate != 50 {
+		t.Errorf("числовое значение должно подставляться без кавычек: %v", cfg.RateLimiter.TokenBucket.Rate)
+	}
+	if cfg.Admin.Token != "12345" {
+		t.Errorf("секрет должен читаться из файла как строка: %q", cfg.Admin.Token)
+	}
+	if !cfg.interpolated {
+		t.Error("конфигурация должна быть помечена как содержащая подстановки")
+	}
+
+	_, err = Parse([]byte(strings.Replace(data, "${LB_RATE}", "${LB_MISSING_RATE}", 1)))
+	if err == nil || !strings.Contains(err.Error(), "LB_MISSING_RATE") {
+		t.Errorf("незаданная переменная окружения должна возвращать ошибку: %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ListenerConfig адрес, на котором прокси принимает соединения, и его собственная таблица маршрутов
+type ListenerConfig struct {
+	// Уникальное имя listener'а, используется в логах
+	Name string `yaml:"name"`
+
+	// Адрес в формате host:port, :port или unix:/путь/к/сокету
+	Address string `yaml:"address"`
+
+	// Настройки TLS; без них listener принимает незашифрованные соединения
+	TLS *ListenerTLSConfig `yaml:"tls,omitempty"`
+
+	// Перенаправлять все запросы на HTTPS вместо проксирования. На TLS listener'е
+	// с sniff перенаправляются только запросы, пришедшие без TLS.
+	RedirectToHTTPS bool `yaml:"redirectToHTTPS,omitempty"`
+
+	// Порт HTTPS в адресе перенаправления (по умолчанию 443, на TLS listener'е с sniff —
+	// порт самого listener'а)
+	HTTPSPort int `yaml:"httpsPort,omitempty"`
+
+	// Определение протокола соединения по первым байтам: один порт принимает TLS и HTTP
+	// без TLS, а также соединения других протоколов, если задан TCP бэкенд
+	Sniff *ListenerSniffConfig `yaml:"sniff,omitempty"`
+
+	// Middleware конвейера этого listener'а вместо middleware верхнего уровня.
+	// Если список пуст, используются middleware верхнего уровня.
+	Middlewares []MiddlewareConfig `yaml:"middlewares,omitempty"`
+
+	// Защита от медленных клиентов; без секции действуют значения по умолчанию
+	SlowClient *SlowClientConfig `yaml:"slowClient,omitempty"`
+
+	// Лимиты открытых соединений
+	Connections *ConnectionLimitsConfig `yaml:"connections,omitempty"`
+}
+
+// ConnectionLimitsConfig лимиты открытых соединений listener'а. В отличие от rate limiter,
+// они защищают от потока соединений, по которым не приходят запросы.
+type ConnectionLimitsConfig struct {
+	// Всего открытых соединений (0 — без ограничения)
+	MaxConnections int `yaml:"maxConnections,omitempty"`
+
+	// Открытых соединений с одного IP адреса (0 — без ограничения)
+	MaxConnectionsPerIP int `yaml:"maxConnectionsPerIP,omitempty"`
+}
+
+// SlowClientConfig ограничения, не позволяющие медленным клиентам (slowloris) удерживать
+// соединения. Изменения применяются после перезапуска приложения.
+type SlowClientConfig struct {
+	// Время на получение заголовков запроса (по умолчанию 10s)
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout,omitempty"`
+
+	// Время на получение всего запроса вместе с телом (0 — без ограничения)
+	ReadTimeout time.Duration `yaml:"readTimeout,omitempty"`
+
+	// Время ожидания следующего запроса на keep-alive соединении (по умолчанию 2m)
+	IdleTimeout time.Duration `yaml:"idleTimeout,omitempty"`
+
+	// Максимальный размер строки запроса и заголовков (по умолчанию 64KB)
+	MaxHeaderBytes int `yaml:"maxHeaderBytes,omitempty"`
+
+	// Минимальная скорость передачи тела запроса в байтах в секунду (по умолчанию 240; -1 — не проверяется)
+	MinBodyRate int64 `yaml:"minBodyRate,omitempty"`
+
+	// Время от начала чтения тела, в течение которого скорость не проверяется (по умолчанию 5s)
+	MinBodyRateGrace time.Duration `yaml:"minBodyRateGrace,omitempty"`
+}
+
+// ListenerSniffConfig настройки определения протокола соединений listener'а
+type ListenerSniffConfig struct {
+	// Время ожидания первых байтов (по умолчанию 5s). Соединение, по которому за это время
+	// ничего не пришло, считается соединением протокола, где первым говорит сервер.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// Адрес host:port, которому передаются соединения, не похожие на TLS и HTTP;
+	// без него такие соединения закрываются
+	TCPBackend string `yaml:"tcpBackend,omitempty"`
+}
+
+// ListenerTLSConfig настройки TLS listener'а
+type ListenerTLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+
+	// CA для проверки клиентских сертификатов; клиенты без сертификата отклоняются
+	ClientCAFile string `yaml:"clientCAFile,omitempty"`
+
+	// Проверка клиентских сертификатов: require (по умолчанию) — соединения без сертификата
+	// отклоняются, optional — сертификат проверяется, если клиент его предъявил, а требуют
+	// его маршруты с auth.type: mtls
+	ClientAuth string `yaml:"clientAuth,omitempty"`
+
+	// Передача сведений о проверенном клиентском сертификате бэкендам (требует clientCAFile)
+	ForwardClientCert *ClientCertForwardingConfig `yaml:"forwardClientCert,omitempty"`
+}
+
+// Поля заголовка со сведениями о клиентском сертификате
+const (
+	ClientCertFieldHash    = "hash"
+	ClientCertFieldSubject = "subject"
+	ClientCertFieldURI     = "uri"
+	ClientCertFieldDNS     = "dns"
+	ClientCertFieldCert    = "cert"
+	ClientCertFieldChain   = "chain"
+)
+
+// ClientCertForwardingConfig заголовок со сведениями о клиентском сертификате в запросах
+// к бэкендам. Заголовок с тем же именем в запросах клиентов отбрасывается, а от доверенных
+// прокси — дополняется.
+type ClientCertForwardingConfig struct {
+	// Имя заголовка (по умолчанию X-Forwarded-Client-Cert)
+	Header string `yaml:"header,omitempty"`
+
+	// Передаваемые поля: hash, subject, uri, dns, cert, chain
+	// (по умолчанию hash, subject, uri и dns)
+	Fields []string `yaml:"fields,omitempty"`
+}
+
+// validateInto проверяет передачу сведений о клиентском сертификате
+func (c *ClientCertForwardingConfig) validateInto(v *validator, field string, verified bool) {
+	if !verified {
+		v.add(field, nil, "requires clientCAFile")
+	}
+	if c.Header != "" && strings.ContainsAny(c.Header, ": \t\r\n") {
+		v.add(field+".header", c.Header, "invalid header name")
+	}
+	for i, name := range c.Fields {
+		switch name {
+		case ClientCertFieldHash, ClientCertFieldSubject, ClientCertFieldURI, ClientCertFieldDNS, ClientCertFieldCert, ClientCertFieldChain:
+		default:
+			v.add(fmt.Sprintf("%s.fields[%d]", field, i), name, "must be one of hash, subject, uri, dns, cert, chain")
+		}
+	}
+}
+
+// ProcessConfig настройки привязки к портам и масштабирования на несколько процессов
+type ProcessConfig struct {
+	// Занимать адреса listener'ов с SO_REUSEPORT: несколько процессов могут слушать
+	// один порт, например старая и новая версия при обновлении без простоя
+	ReusePort bool `yaml:"reusePort,omitempty"`
+
+	// Число рабочих процессов, принимающих соединения на общих портах (включает reusePort).
+	// 0 — запросы обрабатывает единственный процесс.
+	Workers int `yaml:"workers,omitempty"`
+}
+
+// maxWorkers ограничение числа рабочих процессов
+const maxWorkers = 256
+
+// validateInto проверяет настройки процессов
+func (p *ProcessConfig) validateInto(v *validator, listeners []ListenerConfig) {
+	if p.Workers < 0 || p.Workers > maxWorkers {
+		v.add("process.workers", p.Workers, "must be between 0 and %d", maxWorkers)
+	}
+	if p.Workers == 0 && !p.ReusePort {
+		return
+	}
+	for i, l := range listeners {
+		if strings.HasPrefix(l.Address, "unix:") {
+			v.add(fmt.Sprintf("listeners[%d].address", i), l.Address, "unix socket can not be shared with reusePort or workers")
+		}
+	}
+}
+
+// validateListeners проверяет список listener'ов и их middleware
+func validateListeners(v *validator, listeners []ListenerConfig, plugins []PluginConfig) {
+	names := make(map[string]bool, len(listeners))
+	addresses := make(map[string]bool, len(listeners))
+	for i, l := range listeners {
+		field := fmt.Sprintf("listeners[%d]", i)
+
+		if l.Name == "" {
+			v.add(field+".name", nil, "is required")
+		} else if names[l.Name] {
+			v.add(field+".name", l.Name, "duplicate listener name")
+		}
+		names[l.Name] = true
+
+		if l.Address == "" {
+			v.add(field+".address", nil, "is required")
+		} else if path, ok := strings.CutPrefix(l.Address, "unix:"); ok {
+			if !strings.HasPrefix(path, "/") {
+				v.add(field+".address", l.Address, "unix socket path must be absolute")
+			} else if addresses[l.Address] {
+				v.add(field+".address", l.Address, "duplicate listener address")
+			}
+		} else if _, port, err := net.SplitHostPort(l.Address); err != nil || port == "" {
+			v.add(field+".address", l.Address, "must be in host:port format")
+		} else if addresses[l.Address] {
+			v.add(field+".address", l.Address, "duplicate listener address")
+		}
+		addresses[l.Address] = true
+
+		if l.TLS != nil && (l.TLS.CertFile == "" || l.TLS.KeyFile == "") {
+			v.add(field+".tls", nil, "certFile and keyFile are required")
+		}
+		if l.TLS != nil {
+			switch l.TLS.ClientAuth {
+			case "", "require":
+			case "optional":
+				if l.TLS.ClientCAFile == "" {
+					v.add(field+".tls.clientAuth", l.TLS.ClientAuth, "requires clientCAFile")
+				}
+			default:
+				v.add(field+".tls.clientAuth", l.TLS.ClientAuth, "must be require or optional")
+			}
+			if fc := l.TLS.ForwardClientCert; fc != nil {
+				fc.validateInto(v, field+".tls.forwardClientCert", l.TLS.ClientCAFile != "")
+			}
+		}
+		if l.RedirectToHTTPS && l.TLS != nil && l.Sniff == nil {
+			v.add(field+".redirectToHTTPS", true, "is not allowed on a TLS listener without sniff")
+		}
+		if l.HTTPSPort < 0 || l.HTTPSPort > 65535 {
+			v.add(field+".httpsPort", l.HTTPSPort, "must be a valid port number")
+		}
+
+		for j := range l.Middlewares {
+			l.Middlewares[j].validateInto(v, fmt.Sprintf("%s.middlewares[%d]", field, j), plugins)
+		}
+
+		if sn := l.Sniff; sn != nil {
+			if sn.Timeout < 0 {
+				v.add(field+".sniff.timeout", sn.Timeout, "must not be negative")
+			}
+			if sn.TCPBackend != "" {
+				if _, port, err := net.SplitHostPort(sn.TCPBackend); err != nil || port == "" {
+					v.add(field+".sniff.tcpBackend", sn.TCPBackend, "must be in host:port format")
+				}
+			}
+		}
+
+		if sc := l.SlowClient; sc != nil {
+			sc.validateInto(v, field+".slowClient")
+		}
+		if c := l.Connections; c != nil {
+			if c.MaxConnections < 0 {
+				v.add(field+".connections.maxConnections", c.MaxConnections, "must not be negative")
+			}
+			if c.MaxConnectionsPerIP < 0 {
+				v.add(field+".connections.maxConnectionsPerIP", c.MaxConnectionsPerIP, "must not be negative")
+			}
+		}
+	}
+}
+
+// validateInto проверяет ограничения для медленных клиентов
+func (sc *SlowClientConfig) validateInto(v *validator, field string) {
+	if sc.ReadHeaderTimeout < 0 {
+		v.add(field+".readHeaderTimeout", sc.ReadHeaderTimeout, "must not be negative")
+	}
+	if sc.ReadTimeout < 0 {
+		v.add(field+".readTimeout", sc.ReadTimeout, "must not be negative")
+	}
+	if sc.IdleTimeout < 0 {
+		v.add(field+".idleTimeout", sc.IdleTimeout, "must not be negative")
+	}
+	if sc.MaxHeaderBytes < 0 {
+		v.add(field+".maxHeaderBytes", sc.MaxHeaderBytes, "must not be negative")
+	}
+	if sc.MinBodyRate < -1 {
+		v.add(field+".minBodyRate", sc.MinBodyRate, "must be -1 or greater")
+	}
+	if sc.MinBodyRateGrace < 0 {
+		v.add(field+".minBodyRateGrace", sc.MinBodyRateGrace, "must not be negative")
+	}
+}
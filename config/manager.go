@@ -1,11 +1,18 @@
 package config
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
 )
 
 // ConfigManager управляет конфигурацией и поддерживает горячую перезагрузку
@@ -13,13 +20,37 @@ type ConfigManager struct {
 	mu          sync.RWMutex
 	config      *Config
 	configPath  string
-	subscribers []chan<- *Config
+	subscribers []chan *Config
 	lastError   error
 	watcher     *fsnotify.Watcher
+
+	// Внешний источник конфигурации (nil для файла или каталога)
+	source     ConfigSource
+	sourceData []byte
+	cancel     context.CancelFunc
+
+	// История успешно примененных версий, от старых к новым
+	history     []ConfigVersion
+	nextVersion int
 }
 
-// NewConfigManager создает новый менеджер конфигурации
+// Параметры работы с внешним источником конфигурации
+const (
+	sourceFetchTimeout = 10 * time.Second
+	sourceMaxBackoff   = 30 * time.Second
+)
+
+// NewConfigManager создает новый менеджер конфигурации. Путь может указывать на файл,
+// каталог фрагментов или внешний источник (consul://..., etcd://...).
 func NewConfigManager(configPath string) (*ConfigManager, error) {
+	if IsRemoteSource(configPath) {
+		source, err := NewSource(configPath)
+		if err != nil {
+			return nil, err
+		}
+		return NewConfigManagerFromSource(source)
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create watcher: %w", err)
@@ -27,12 +58,12 @@ func NewConfigManager(configPath string) (*ConfigManager, error) {
 
 	manager := &ConfigManager{
 		configPath:  configPath,
-		subscribers: make([]chan<- *Config, 0),
+		subscribers: make([]chan *Config, 0),
 		watcher:     watcher,
 	}
 
 	// Загружаем начальную конфигурацию
-	if err := manager.loadConfig(); err != nil {
+	if _, err := manager.loadConfig(); err != nil {
 		return nil, err
 	}
 
@@ -42,6 +73,36 @@ func NewConfigManager(configPath string) (*ConfigManager, error) {
 	return manager, nil
 }
 
+// NewConfigManagerFromSource создает менеджер, получающий конфигурацию из внешнего источника.
+// Изменения в источнике рассылаются подписчикам так же, как изменения файла.
+func NewConfigManagerFromSource(source ConfigSource) (*ConfigManager, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	manager := &ConfigManager{
+		subscribers: make([]chan *Config, 0),
+		source:      source,
+		cancel:      cancel,
+	}
+
+	// Загружаем начальную конфигурацию
+	fetchCtx, fetchCancel := context.WithTimeout(ctx, sourceFetchTimeout)
+	data, err := source.Fetch(fetchCtx)
+	fetchCancel()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to load config from %s: %w", source.Name(), err)
+	}
+	if err := manager.loadData(data); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Запускаем отслеживание изменений
+	go manager.watchSource(ctx)
+
+	return manager, nil
+}
+
 // Subscribe подписывает на изменения конфигурации
 func (m *ConfigManager) Subscribe() <-chan *Config {
 	m.mu.Lock()
@@ -58,6 +119,20 @@ func (m *ConfigManager) Subscribe() <-chan *Config {
 	return ch
 }
 
+// Unsubscribe отменяет подписку, полученную Subscribe, и закрывает ее канал
+func (m *ConfigManager) Unsubscribe(sub <-chan *Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, ch := range m.subscribers {
+		if ch == sub {
+			close(ch)
+			m.subscribers = append(m.subscribers[:i], m.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
 // GetConfig возвращает текущую конфигурацию
 func (m *ConfigManager) GetConfig() *Config {
 	m.mu.RLock()
@@ -83,40 +158,188 @@ func (m *ConfigManager) Close() error {
 	}
 	m.subscribers = nil
 
-	return m.watcher.Close()
+	if m.cancel != nil {
+		m.cancel()
+	}
+	if m.watcher != nil {
+		return m.watcher.Close()
+	}
+	return nil
 }
 
-// loadConfig загружает конфигурацию из файла
-func (m *ConfigManager) loadConfig() error {
-	newConfig, err := LoadFromFile(m.configPath)
+// Update изменяет конфигурацию и сохраняет ее в файл. Применение новой конфигурации
+// происходит через отслеживание изменений файла, как и при ручном редактировании.
+// Комментарии в исходном файле при этом не сохраняются.
+func (m *ConfigManager) Update(updateFn func(*Config) error) error {
+	m.mu.RLock()
+	current := m.config
+	m.mu.RUnlock()
+
+	if current == nil {
+		return fmt.Errorf("config is not loaded")
+	}
+
+	if m.source != nil {
+		return fmt.Errorf("config write-back is not supported for %s", m.source.Name())
+	}
+
+	// Иначе значения переменных окружения и секреты попадут в файл открытым текстом
+	if current.interpolated {
+		return fmt.Errorf("config write-back is not supported for configs with ${...} or secret:// references")
+	}
+
+	// Записывать изменения умеем только в одиночный YAML файл
+	if info, err := os.Stat(m.configPath); err != nil || info.IsDir() || DetectFormat(m.configPath) != FormatYAML {
+		return fmt.Errorf("config write-back is supported only for a single YAML file")
+	}
+
+	// Копируем конфигурацию, чтобы не изменять действующую
+	updated := *current
+	updated.Backends = append([]BackendConfig(nil), current.Backends...)
+
+	if err := updateFn(&updated); err != nil {
+		return err
+	}
+
+	if err := updated.validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	data, err := yaml.Marshal(&updated)
+	if err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+
+	if err := os.WriteFile(m.configPath, data, 0644); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+
+	return nil
+}
+
+// Reload принудительно перечитывает конфигурацию (по SIGHUP или запросу к API) там,
+// где события файловой системы ненадежны. Возвращает true, если конфигурация изменилась.
+func (m *ConfigManager) Reload() (bool, error) {
+	if m.source != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), sourceFetchTimeout)
+		defer cancel()
+
+		data, err := m.source.Fetch(ctx)
+		if err != nil {
+			return false, fmt.Errorf("failed to load config from %s: %w", m.source.Name(), err)
+		}
+
+		m.mu.RLock()
+		unchanged := bytes.Equal(m.sourceData, data)
+		m.mu.RUnlock()
+
+		return !unchanged, m.loadData(data)
+	}
+
+	return m.loadConfig()
+}
+
+// loadConfig загружает конфигурацию из файла. Конфигурация, не отличающаяся
+// от действующей, повторно не применяется. Возвращает true, если конфигурация изменилась.
+func (m *ConfigManager) loadConfig() (bool, error) {
+	newConfig, err := Load(m.configPath)
 	if err != nil {
 		m.mu.Lock()
 		m.lastError = err
 		m.mu.Unlock()
-		return fmt.Errorf("failed to load config: %w", err)
+		return false, fmt.Errorf("failed to load config: %w", err)
 	}
 
 	m.mu.Lock()
-	m.config = newConfig
-	m.lastError = nil
+	defer m.mu.Unlock()
 
-	// Уведомляем подписчиков
-	for _, ch := range m.subscribers {
-		select {
-		case ch <- newConfig:
-		default:
-			// Если канал заполнен, пропускаем
-		}
+	if m.config != nil && reflect.DeepEqual(m.config, newConfig) {
+		m.lastError = nil
+		return false, nil
+	}
+	m.apply(newConfig, VersionSourceLoad, 0)
+
+	return true, nil
+}
+
+// loadData применяет конфигурацию, полученную из внешнего источника.
+// Неизменившееся содержимое повторно не применяется.
+func (m *ConfigManager) loadData(data []byte) error {
+	m.mu.Lock()
+	unchanged := m.sourceData != nil && bytes.Equal(m.sourceData, data)
+	if unchanged {
+		// Источник снова доступен и отдает действующую конфигурацию
+		m.lastError = nil
+	}
+	m.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	newConfig, err := ParseFormat(data, m.source.Format())
+	if err != nil {
+		m.mu.Lock()
+		m.lastError = err
+		m.mu.Unlock()
+		return fmt.Errorf("failed to load config: %w", err)
 	}
+
+	m.mu.Lock()
+	m.sourceData = data
+	m.apply(newConfig, VersionSourceLoad, 0)
 	m.mu.Unlock()
 
 	return nil
 }
 
-// watchConfig отслеживает изменения в файле конфигурации
+// watchSource отслеживает изменения во внешнем источнике, переподключаясь
+// с экспоненциальной задержкой после ошибок
+func (m *ConfigManager) watchSource(ctx context.Context) {
+	backoff := time.Second
+	for {
+		err := m.source.Watch(ctx, func(data []byte) {
+			m.loadData(data)
+			backoff = time.Second
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		m.mu.Lock()
+		m.lastError = fmt.Errorf("config source %s: %w", m.source.Name(), err)
+		m.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > sourceMaxBackoff {
+			backoff = sourceMaxBackoff
+		}
+	}
+}
+
+// kubernetesDataDir символическая ссылка, которую Kubernetes атомарно подменяет
+// при обновлении смонтированного ConfigMap или Secret
+const kubernetesDataDir = "..data"
+
+// watchConfig отслеживает изменения конфигурации. Для одиночного файла отслеживается
+// содержащий его каталог: так обнаруживается замена файла через rename (редакторы, sed -i)
+// и подмена символических ссылок в смонтированных ConfigMap.
 func (m *ConfigManager) watchConfig() {
-	// Добавляем файл для отслеживания
-	if err := m.watcher.Add(m.configPath); err != nil {
+	info, err := os.Stat(m.configPath)
+	isDir := err == nil && info.IsDir()
+
+	watchPath := m.configPath
+	if !isDir {
+		watchPath = filepath.Dir(m.configPath)
+	}
+	configName := filepath.Base(m.configPath)
+
+	if err := m.watcher.Add(watchPath); err != nil {
 		m.mu.Lock()
 		m.lastError = fmt.Errorf("failed to watch config file: %w", err)
 		m.mu.Unlock()
@@ -134,7 +357,23 @@ func (m *ConfigManager) watchConfig() {
 				return
 			}
 
-			if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+			// Реагируем только на файлы конфигурации; в каталоге фрагментов — включая их удаление
+			name := filepath.Base(event.Name)
+			ops := fsnotify.Write | fsnotify.Create
+			switch {
+			case name == kubernetesDataDir:
+			case isDir:
+				if strings.HasPrefix(name, ".") || DetectFormat(name) == "" {
+					continue
+				}
+				ops |= fsnotify.Remove | fsnotify.Rename
+			default:
+				if name != configName {
+					continue
+				}
+			}
+
+			if event.Op&ops != 0 {
 				// Сбрасываем таймер если он уже запущен
 				if debounceTimer != nil {
 					debounceTimer.Stop()
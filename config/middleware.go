@@ -0,0 +1,123 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// middlewareTypes зарегистрированные middleware прокси
+var (
+	middlewareTypesMu sync.RWMutex
+	middlewareTypes   = make(map[string]bool)
+)
+
+// RegisterMiddlewareType разрешает в конфигурации middleware с указанным именем
+func RegisterMiddlewareType(name string) {
+	middlewareTypesMu.Lock()
+	defer middlewareTypesMu.Unlock()
+	middlewareTypes[name] = true
+}
+
+// MiddlewareConfig настройки middleware в конвейере обработки запросов
+type MiddlewareConfig struct {
+	// Имя зарегистрированного middleware
+	Name string `yaml:"name"`
+
+	// Параметры middleware
+	Params map[string]interface{} `yaml:"params,omitempty"`
+}
+
+// PluginConfig внешний фильтр запросов, загружаемый из Go плагина (.so)
+type PluginConfig struct {
+	// Имя, под которым фильтр подключается в секции middlewares
+	Name string `yaml:"name"`
+
+	// Путь к файлу плагина
+	Path string `yaml:"path"`
+
+	// Этап конвейера: auth, ratelimit или rewrite (по умолчанию rewrite)
+	Phase string `yaml:"phase,omitempty"`
+
+	// Передавать фильтру тело запроса
+	RequestBody bool `yaml:"requestBody,omitempty"`
+
+	// Максимальный размер тела запроса и ответа, передаваемого фильтру (по умолчанию 1MB)
+	MaxBodySize int64 `yaml:"maxBodySize,omitempty"`
+}
+
+// DecodeParams раскладывает параметры middleware в структуру out с yaml тегами.
+// Неизвестные параметры считаются ошибкой.
+func (m MiddlewareConfig) DecodeParams(out interface{}) error {
+	data, err := yaml.Marshal(m.Params)
+	if err != nil {
+		return fmt.Errorf("error encoding params of middleware %s: %w", m.Name, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("invalid params of middleware %s: %w", m.Name, err)
+	}
+	return nil
+}
+
+// redactParams возвращает копию параметров, в которой скрыты значения
+// с именами, похожими на секреты
+func redactParams(params map[string]interface{}) map[string]interface{} {
+	if params == nil {
+		return nil
+	}
+	redacted := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		name := strings.ToLower(key)
+		if strings.Contains(name, "secret") || strings.Contains(name, "token") ||
+			strings.Contains(name, "password") || strings.Contains(name, "key") {
+			value = redactedValue
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+// validateInto проверяет, что middleware зарегистрирован или объявлен в секции plugins
+func (m *MiddlewareConfig) validateInto(v *validator, field string, plugins []PluginConfig) {
+	if m.Name == "" {
+		v.add(field+".name", nil, "is required")
+		return
+	}
+
+	for _, p := range plugins {
+		if p.Name == m.Name {
+			return
+		}
+	}
+
+	middlewareTypesMu.RLock()
+	registered := middlewareTypes[m.Name]
+	middlewareTypesMu.RUnlock()
+	if !registered {
+		v.add(field+".name", m.Name, "unknown middleware")
+	}
+}
+
+// validateInto проверяет настройки плагина
+func (p *PluginConfig) validateInto(v *validator, field string) {
+	if p.Name == "" {
+		v.add(field+".name", nil, "is required")
+	}
+	if p.Path == "" {
+		v.add(field+".path", nil, "is required")
+	}
+	switch p.Phase {
+	case "", "auth", "ratelimit", "rewrite":
+	default:
+		v.add(field+".phase", p.Phase, "must be auth, ratelimit or rewrite")
+	}
+	if p.MaxBodySize < 0 {
+		v.add(field+".maxBodySize", p.MaxBodySize, "must not be negative")
+	}
+}
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RoutingConfig правила маршрутизации запросов. Запрос обрабатывает первое подходящее
+// правило; без секции все запросы обрабатываются одним маршрутом на всех бэкендах.
+type RoutingConfig struct {
+	// Порядок проверки правил: first — в порядке конфигурации (по умолчанию),
+	// priority — по убыванию priority, при равном приоритете в порядке конфигурации
+	Order string `yaml:"order,omitempty"`
+
+	// Правила маршрутизации
+	Rules []RouteConfig `yaml:"rules"`
+
+	// Способы аутентификации, которые маршруты требуют в auth
+	Auth *RouteAuthProvidersConfig `yaml:"auth,omitempty"`
+
+	// Маршруты TLS соединений по имени сервера (SNI), которые передаются бэкендам
+	// без расшифровки
+	Passthrough []PassthroughRouteConfig `yaml:"passthrough,omitempty"`
+}
+
+// PassthroughRouteConfig маршрут TLS соединений для бэкендов, которые сами завершают TLS
+// (например, сервисов с mTLS). Соединения принимаются TLS listener'ами вместе с обычными.
+type PassthroughRouteConfig struct {
+	// Уникальное имя маршрута, используется в логах и метриках
+	Name string `yaml:"name"`
+
+	// Имена серверов из SNI: точные или вида *.example.com (один уровень поддомена)
+	ServerNames []string `yaml:"serverNames"`
+
+	// ID бэкендов маршрута (по умолчанию все бэкенды)
+	Backends []string `yaml:"backends,omitempty"`
+
+	// Порт TLS на бэкендах (по умолчанию порт из адреса бэкенда)
+	Port int `yaml:"port,omitempty"`
+}
+
+// RouteAuthProvidersConfig способы аутентификации клиентов маршрутов
+type RouteAuthProvidersConfig struct {
+	// Ключи API для маршрутов с auth.type: apiKey
+	APIKeys *APIKeyAuthConfig `yaml:"apiKeys,omitempty"`
+
+	// Проверка JWT для маршрутов с auth.type: jwt
+	JWT *JWTAuthConfig `yaml:"jwt,omitempty"`
+}
+
+// JWTAuthConfig проверка JWT из заголовка Authorization: Bearer
+type JWTAuthConfig struct {
+	// Адрес набора ключей подписи (JWKS) издателя
+	JWKSURL string `yaml:"jwksURL"`
+
+	// Ожидаемые утверждения iss и aud (пустые — не проверяются)
+	Issuer   string `yaml:"issuer,omitempty"`
+	Audience string `yaml:"audience,omitempty"`
+}
+
+// RouteAuthConfig аутентификация, которую требует маршрут
+type RouteAuthConfig struct {
+	// Способ: none (по умолчанию), apiKey, jwt или mtls
+	Type string `yaml:"type"`
+
+	// Scope, которые должны быть в утверждении scope или scp токена (для jwt)
+	Scopes []string `yaml:"scopes,omitempty"`
+
+	// Допустимые CN или DNS имена SAN клиентского сертификата (для mtls; по умолчанию любой
+	// сертификат, подписанный tls.clientCAFile listener'а)
+	ClientNames []string `yaml:"clientNames,omitempty"`
+}
+
+// RouteConfig правило маршрутизации: условия запроса и маршрут, которым он обрабатывается
+type RouteConfig struct {
+	// Уникальное имя маршрута, используется в логах, метриках и отладке маршрутизации
+	Name string `yaml:"name"`
+
+	// Приоритет правила при order: priority
+	Priority int `yaml:"priority,omitempty"`
+
+	// Условия, которым должен удовлетворять запрос; пустые условия подходят любому запросу
+	Match RouteMatchConfig `yaml:"match,omitempty"`
+
+	// ID бэкендов маршрута (по умолчанию все бэкенды)
+	Backends []string `yaml:"backends,omitempty"`
+
+	// Middleware маршрута; добавляются к middleware listener'а или верхнего уровня
+	Middlewares []MiddlewareConfig `yaml:"middlewares,omitempty"`
+
+	// Аутентификация клиентов маршрута (по умолчанию не требуется)
+	Auth *RouteAuthConfig `yaml:"auth,omitempty"`
+}
+
+// RouteMatchConfig условия правила маршрутизации; запрос должен удовлетворять всем заданным
+type RouteMatchConfig struct {
+	// Путь запроса: точное значение, префикс или регулярное выражение; задается одно из трех
+	Path       string `yaml:"path,omitempty"`
+	PathPrefix string `yaml:"pathPrefix,omitempty"`
+	PathRegex  string `yaml:"pathRegex,omitempty"`
+
+	// Методы запроса (по умолчанию все)
+	Methods []string `yaml:"methods,omitempty"`
+
+	// Значения заголовков и параметров query; "*" — заголовок или параметр с любым значением
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Query   map[string]string `yaml:"query,omitempty"`
+
+	// IP адреса и подсети CIDR клиентов
+	ClientIPs []string `yaml:"clientIPs,omitempty"`
+
+	// Доля подходящих запросов в процентах, которую забирает правило (по умолчанию 100).
+	// Остальные запросы проверяются следующими правилами, например для канареечного выпуска.
+	Weight *float64 `yaml:"weight,omitempty"`
+}
+
+// validateInto проверяет правила маршрутизации
+func (r *RoutingConfig) validateInto(v *validator, plugins []PluginConfig, listeners []ListenerConfig) {
+	switch r.Order {
+	case "", "first", "priority":
+	default:
+		v.add("routing.order", r.Order, "must be first or priority")
+	}
+	if len(r.Rules) == 0 && len(r.Passthrough) == 0 {
+		v.add("routing.rules", nil, "at least one rule is required")
+	}
+
+	names := make(map[string]bool, len(r.Rules))
+	for i, route := range r.Rules {
+		field := fmt.Sprintf("routing.rules[%d]", i)
+		if route.Name == "" {
+			v.add(field+".name", nil, "is required")
+		} else if names[route.Name] {
+			v.add(field+".name", route.Name, "duplicate route name")
+		}
+		names[route.Name] = true
+
+		route.Match.validateInto(v, field+".match")
+		for j, id := range route.Backends {
+			if id == "" {
+				v.add(fmt.Sprintf("%s.backends[%d]", field, j), nil, "must not be empty")
+			}
+		}
+		for j := range route.Middlewares {
+			route.Middlewares[j].validateInto(v, fmt.Sprintf("%s.middlewares[%d]", field, j), plugins)
+		}
+		if route.Auth != nil {
+			r.validateAuth(v, field+".auth", route.Auth, listeners)
+		}
+	}
+
+	if r.Auth != nil {
+		r.Auth.validateInto(v, names)
+	}
+	r.validatePassthrough(v, names, listeners)
+}
+
+// validatePassthrough проверяет маршруты TLS соединений; names — имена правил маршрутизации
+func (r *RoutingConfig) validatePassthrough(v *validator, names map[string]bool, listeners []ListenerConfig) {
+	if len(r.Passthrough) == 0 {
+		return
+	}
+	tls := false
+	for _, l := range listeners {
+		tls = tls || l.TLS != nil
+	}
+	if !tls {
+		v.add("routing.passthrough", nil, "requires a TLS listener")
+	}
+
+	serverNames := make(map[string]bool)
+	for i, route := range r.Passthrough {
+		field := fmt.Sprintf("routing.passthrough[%d]", i)
+		if route.Name == "" {
+			v.add(field+".name", nil, "is required")
+		} else if names[route.Name] {
+			v.add(field+".name", route.Name, "duplicate route name")
+		}
+		names[route.Name] = true
+
+		if len(route.ServerNames) == 0 {
+			v.add(field+".serverNames", nil, "at least one server name is required")
+		}
+		for j, name := range route.ServerNames {
+			name = strings.ToLower(name)
+			host := strings.TrimPrefix(name, "*.")
+			if host == "" || strings.ContainsAny(host, "*/: ") {
+				v.add(fmt.Sprintf("%s.serverNames[%d]", field, j), name, "must be a host name or *.domain")
+			} else if serverNames[name] {
+				v.add(fmt.Sprintf("%s.serverNames[%d]", field, j), name, "duplicate server name")
+			}
+			serverNames[name] = true
+		}
+		for j, id := range route.Backends {
+			if id == "" {
+				v.add(fmt.Sprintf("%s.backends[%d]", field, j), nil, "must not be empty")
+			}
+		}
+		if route.Port < 0 || route.Port > 65535 {
+			v.add(field+".port", route.Port, "must be a valid port number")
+		}
+	}
+}
+
+// validateAuth проверяет, что способ аутентификации маршрута настроен
+func (r *RoutingConfig) validateAuth(v *validator, field string, auth *RouteAuthConfig, listeners []ListenerConfig) {
+	switch auth.Type {
+	case "none":
+	case "apiKey":
+		if r.Auth == nil || r.Auth.APIKeys == nil {
+			v.add(field+".type", auth.Type, "requires routing.auth.apiKeys")
+		}
+	case "jwt":
+		if r.Auth == nil || r.Auth.JWT == nil {
+			v.add(field+".type", auth.Type, "requires routing.auth.jwt")
+		}
+	case "mtls":
+		verified := false
+		for _, l := range listeners {
+			verified = verified || (l.TLS != nil && l.TLS.ClientCAFile != "")
+		}
+		if !verified {
+			v.add(field+".type", auth.Type, "requires a listener with tls.clientCAFile")
+		}
+	default:
+		v.add(field+".type", auth.Type, "must be none, apiKey, jwt or mtls")
+	}
+	if len(auth.Scopes) > 0 && auth.Type != "jwt" {
+		v.add(field+".scopes", auth.Scopes, "can only be used with jwt")
+	}
+	if len(auth.ClientNames) > 0 && auth.Type != "mtls" {
+		v.add(field+".clientNames", auth.ClientNames, "can only be used with mtls")
+	}
+}
+
+// validateInto проверяет способы аутентификации; routes — имена маршрутов
+func (a *RouteAuthProvidersConfig) validateInto(v *validator, routes map[string]bool) {
+	if a.APIKeys != nil {
+		a.APIKeys.validateInto(v, "routing.auth.apiKeys", routes)
+	}
+	if a.JWT != nil {
+		if u, err := url.Parse(a.JWT.JWKSURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.add("routing.auth.jwt.jwksURL", a.JWT.JWKSURL, "must be an http(s) URL")
+		}
+	}
+}
+
+// validateInto проверяет условия правила
+func (m *RouteMatchConfig) validateInto(v *validator, field string) {
+	paths := 0
+	for _, path := range []string{m.Path, m.PathPrefix, m.PathRegex} {
+		if path != "" {
+			paths++
+		}
+	}
+	if paths > 1 {
+		v.add(field, nil, "only one of path, pathPrefix and pathRegex may be set")
+	}
+	if m.Path != "" && !strings.HasPrefix(m.Path, "/") {
+		v.add(field+".path", m.Path, "must start with /")
+	}
+	if m.PathPrefix != "" && !strings.HasPrefix(m.PathPrefix, "/") {
+		v.add(field+".pathPrefix", m.PathPrefix, "must start with /")
+	}
+	if m.PathRegex != "" {
+		if _, err := regexp.Compile(m.PathRegex); err != nil {
+			v.add(field+".pathRegex", m.PathRegex, "invalid regular expression: %v", err)
+		}
+	}
+
+	for i, method := range m.Methods {
+		if method == "" || strings.ToUpper(method) != method {
+			v.add(fmt.Sprintf("%s.methods[%d]", field, i), method, "must be an upper case HTTP method")
+		}
+	}
+	for name := range m.Headers {
+		if http.CanonicalHeaderKey(name) == "" {
+			v.add(field+".headers", name, "header name must not be empty")
+		}
+	}
+	for i, ip := range m.ClientIPs {
+		if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+			v.add(fmt.Sprintf("%s.clientIPs[%d]", field, i), ip, "must be an IP address or CIDR network")
+		}
+	}
+	if m.Weight != nil && (*m.Weight <= 0 || *m.Weight > 100) {
+		v.add(field+".weight", *m.Weight, "must be greater than 0 and at most 100")
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ConfigSource внешний источник конфигурации (например, KV хранилище)
+type ConfigSource interface {
+	// Name возвращает описание источника для логов и ошибок
+	Name() string
+
+	// Format возвращает формат хранимой конфигурации (yaml, json, toml)
+	Format() string
+
+	// Fetch возвращает текущее содержимое конфигурации
+	Fetch(ctx context.Context) ([]byte, error)
+
+	// Watch отслеживает изменения конфигурации и вызывает onChange с новым содержимым,
+	// в том числе сразу после запуска. Блокируется до ошибки или отмены ctx.
+	Watch(ctx context.Context, onChange func(data []byte)) error
+}
+
+// IsRemoteSource проверяет, указывает ли путь на внешний источник конфигурации
+func IsRemoteSource(path string) bool {
+	return strings.HasPrefix(path, "consul://") || strings.HasPrefix(path, "etcd://")
+}
+
+// NewSource создает источник конфигурации по адресу вида:
+//
+//	consul://host:8500/path/to/key?token=...&scheme=https
+//	etcd://host:2379/path/to/key?scheme=https
+//
+// Формат определяется по расширению ключа (по умолчанию YAML) или параметром format.
+func NewSource(uri string) (ConfigSource, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config source: %w", err)
+	}
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("config source must include host and key: %s", uri)
+	}
+
+	query := u.Query()
+	scheme := query.Get("scheme")
+	if scheme == "" {
+		scheme = "http"
+	}
+	endpoint := scheme + "://" + u.Host
+
+	format := query.Get("format")
+	if format == "" {
+		format = DetectFormat(u.Path)
+	}
+	if format == "" {
+		format = FormatYAML
+	}
+
+	switch u.Scheme {
+	case "consul":
+		return NewConsulSource(endpoint, strings.TrimPrefix(u.Path, "/"), query.Get("token"), format), nil
+	case "etcd":
+		return NewEtcdSource(endpoint, u.Path, format), nil
+	default:
+		return nil, fmt.Errorf("unsupported config source: %s", u.Scheme)
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const sourceTestConfig = `
+loadBalancer:
+  method: %s
+backends:
+  - id: backend1
+    url: http://localhost:8081
+logger:
+  logLevel: info
+  serviceName: test
+`
+
+// fakeConsul эмулирует блокирующие запросы Consul KV для одного ключа
+type fakeConsul struct {
+	mu      sync.Mutex
+	index   int
+	value   string
+	changed chan struct{}
+}
+
+func (f *fakeConsul) set(value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.index++
+	f.value = value
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	changed := f.changed
+	blocking := r.URL.Query().Get("index") == strconv.Itoa(f.index)
+	f.mu.Unlock()
+
+	if blocking {
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("X-Consul-Index", strconv.Itoa(f.index))
+	w.Write([]byte(f.value))
+}
+
+func TestConfigManager_ConsulSource(t *testing.T) {
+	consul := &fakeConsul{changed: make(chan struct{})}
+	consul.set(fmt.Sprintf(sourceTestConfig, "RoundRobin"))
+
+	server := httptest.NewServer(consul)
+	defer server.Close()
+
+	source, err := NewSource("consul://" + server.Listener.Addr().String() + "/lb/config")
+	if err != nil {
+		t.Fatalf("ошибка создания источника: %v", err)
+	}
+
+	m, err := NewConfigManagerFromSource(source)
+	if err != nil {
+		t.Fatalf("ошибка создания менеджера: %v", err)
+	}
+	defer m.Close()
+
+	updates := m.Subscribe()
+	if cfg := <-updates; cfg.LoadBalancer.Method != "RoundRobin" {
+		t.Fatalf("неверная начальная конфигурация: %s", cfg.LoadBalancer.Method)
+	}
+
+	consul.set(fmt.Sprintf(sourceTestConfig, "LeastConnections"))
+
+	select {
+	case cfg := <-updates:
+		if cfg.LoadBalancer.Method != "LeastConnections" {
+			t.Errorf("неверная обновленная конфигурация: %s", cfg.LoadBalancer.Method)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("изменение ключа не доставлено подписчику")
+	}
+
+	if err := m.Update(func(*Config) error { return nil }); err == nil {
+		t.Error("запись конфигурации во внешний источник не поддерживается")
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	source, err := NewSource("etcd://localhost:2379/lb/config.json?scheme=https")
+	if err != nil {
+		t.Fatalf("ошибка создания источника: %v", err)
+	}
+	if source.Format() != FormatJSON || source.Name() != "etcd https://localhost:2379/lb/config.json" {
+		t.Errorf("неверные параметры источника: %s (%s)", source.Name(), source.Format())
+	}
+
+	if _, err := NewSource("consul://localhost:8500"); err == nil {
+		t.Error("адрес без ключа должен возвращать ошибку")
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// FieldError описывает одну проблему конфигурации
+type FieldError struct {
+	// Путь к полю, например backends[1].url
+	Field string `json:"field,omitempty"`
+
+	// Значение поля (не заполняется для секретов)
+	Value interface{} `json:"value,omitempty"`
+
+	// Причина ошибки
+	Reason string `json:"reason"`
+}
+
+// Error возвращает описание проблемы
+func (e FieldError) Error() string {
+	var sb strings.Builder
+	if e.Field != "" {
+		sb.WriteString(e.Field)
+		sb.WriteString(": ")
+	}
+	sb.WriteString(e.Reason)
+	if e.Value != nil {
+		fmt.Fprintf(&sb, " (got %v)", e.Value)
+	}
+	return sb.String()
+}
+
+// ValidationError содержит все найденные проблемы конфигурации
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Error перечисляет все проблемы конфигурации
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	messages := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		messages[i] = fieldErr.Error()
+	}
+	return fmt.Sprintf("%d problems: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// validator накапливает ошибки проверки конфигурации
+type validator struct {
+	errors []FieldError
+}
+
+// add добавляет ошибку для поля
+func (v *validator) add(field string, value interface{}, reason string, args ...interface{}) {
+	v.errors = append(v.errors, FieldError{
+		Field:  field,
+		Value:  value,
+		Reason: fmt.Sprintf(reason, args...),
+	})
+}
+
+// err возвращает *ValidationError или nil, если ошибок нет
+func (v *validator) err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errors}
+}
+
+// checkURL проверяет, что адрес бэкенда является абсолютным http(s) URL
+// или абсолютным путем unix сокета (unix:///run/app.sock)
+func (v *validator) checkURL(field, raw string) {
+	if raw == "" {
+		v.add(field, nil, "is required")
+		return
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		v.add(field, raw, "invalid URL: %v", err)
+		return
+	}
+	if u.Scheme == "unix" {
+		if u.Host != "" || !strings.HasPrefix(u.Path, "/") || u.RawQuery != "" {
+			v.add(field, raw, "unix socket URL must be unix:///absolute/path")
+		}
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		v.add(field, raw, "URL scheme must be http, https or unix")
+	}
+	if u.Host == "" {
+		v.add(field, raw, "URL host is required")
+	}
+}
@@ -0,0 +1,220 @@
+// Package accessevents публикует записи об обработанных запросах в брокер сообщений
+// (Kafka или NSQ), чтобы системы аналитики получали данные о трафике в реальном времени
+package accessevents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Параметры публикации по умолчанию
+const (
+	defaultBatchSize     = 500
+	defaultFlushInterval = time.Second
+	defaultBufferSize    = 10000
+	publishTimeout       = 10 * time.Second
+)
+
+var (
+	// published счетчик опубликованных событий
+	published = metrics.Default.Counter("lb_access_events_published_total", "Access events published to the message broker")
+
+	// dropped счетчик событий, отброшенных при переполнении очереди
+	dropped = metrics.Default.Counter("lb_access_events_dropped_total", "Access events dropped because the publishing queue was full")
+
+	// publishErrors счетчик неудачных отправок пакетов событий
+	publishErrors = metrics.Default.Counter("lb_access_events_errors_total", "Failed access event batch publishes")
+)
+
+// Event запись об обработанном запросе
+type Event struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestID,omitempty"`
+	Client    string    `json:"client,omitempty"`
+	UserID    string    `json:"userID,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	BytesIn   int64     `json:"bytesIn"`
+	BytesOut  int64     `json:"bytesOut"`
+
+	// Длительность обработки в миллисекундах
+	Duration float64 `json:"durationMs"`
+	Backend  string  `json:"backend,omitempty"`
+}
+
+// sink отправляет пакет сообщений в топик брокера
+type sink interface {
+	publish(ctx context.Context, topic string, messages [][]byte) error
+	close() error
+}
+
+// Publisher собирает события в пакеты и отправляет их в брокер из отдельной горутины.
+// Пока пакет не удается отправить, новые события копятся в очереди, а при ее
+// переполнении отбрасываются: медленный брокер не замедляет обработку запросов.
+type Publisher struct {
+	typ           string
+	topic         string
+	batchSize     int
+	flushInterval time.Duration
+	sink          sink
+	logger        logger.Logger
+
+	queue   chan Event
+	started atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// New создает публикацию событий по настройкам cfg
+func New(cfg *config.AccessEventsConfig, appLogger logger.Logger) (*Publisher, error) {
+	p := &Publisher{
+		typ:           cfg.Type,
+		topic:         cfg.Topic,
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		logger:        appLogger,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	switch cfg.Type {
+	case "kafka":
+		p.sink = newKafkaSink(cfg.Brokers)
+	case "nsq":
+		p.sink = newNSQSink(cfg.Address)
+	default:
+		return nil, fmt.Errorf("accessEvents: unsupported type %q", cfg.Type)
+	}
+	if p.batchSize == 0 {
+		p.batchSize = defaultBatchSize
+	}
+	if p.flushInterval == 0 {
+		p.flushInterval = defaultFlushInterval
+	}
+	bufferSize := cfg.BufferSize
+	if bufferSize == 0 {
+		bufferSize = defaultBufferSize
+	}
+	p.queue = make(chan Event, bufferSize)
+	return p, nil
+}
+
+// Publish ставит событие в очередь на отправку, не блокируясь
+func (p *Publisher) Publish(e Event) {
+	select {
+	case p.queue <- e:
+	default:
+		dropped.Inc()
+	}
+}
+
+// Start запускает отправку событий
+func (p *Publisher) Start() {
+	if p.started.CompareAndSwap(false, true) {
+		go p.run()
+	}
+}
+
+// Stop отправляет события из очереди и останавливает публикацию. Незапущенная
+// публикация только закрывает соединение с брокером.
+func (p *Publisher) Stop() {
+	p.once.Do(func() {
+		close(p.stop)
+		if p.started.CompareAndSwap(false, true) {
+			p.sink.close()
+			close(p.done)
+		}
+	})
+	<-p.done
+}
+
+func (p *Publisher) run() {
+	defer close(p.done)
+	defer p.sink.close()
+
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+
+	var batch []Event
+	// Пакет не отправлен: очередь не читается до повторной попытки по таймеру
+	failed := false
+	for {
+		var queue <-chan Event
+		if !failed && len(batch) < p.batchSize {
+			queue = p.queue
+		}
+
+		select {
+		case e := <-queue:
+			batch = append(batch, e)
+			if len(batch) >= p.batchSize {
+				failed = !p.flush(batch)
+				if !failed {
+					batch = batch[:0]
+				}
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				failed = !p.flush(batch)
+				if !failed {
+					batch = batch[:0]
+				}
+			}
+		case <-p.stop:
+			p.drain(batch)
+			return
+		}
+	}
+}
+
+// drain при остановке делает одну попытку отправить текущий пакет и события из очереди
+func (p *Publisher) drain(batch []Event) {
+	for {
+		select {
+		case e := <-p.queue:
+			batch = append(batch, e)
+			if len(batch) < p.batchSize {
+				continue
+			}
+		default:
+		}
+		if len(batch) > 0 && !p.flush(batch) {
+			return
+		}
+		if len(p.queue) == 0 {
+			return
+		}
+		batch = batch[:0]
+	}
+}
+
+// flush отправляет пакет и сообщает об успехе
+func (p *Publisher) flush(batch []Event) bool {
+	messages := make([][]byte, 0, len(batch))
+	for _, e := range batch {
+		data, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		messages = append(messages, data)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	if err := p.sink.publish(ctx, p.topic, messages); err != nil {
+		publishErrors.Inc()
+		p.logger.Warn(fmt.Sprintf("Не удалось отправить %d событий о запросах в %s: %v", len(batch), p.typ, err))
+		return false
+	}
+	published.Add(int64(len(messages)))
+	return true
+}
@@ -0,0 +1,196 @@
+package accessevents
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+// fakeNSQD принимает MPUB, перед подтверждением отправляя heartbeat
+func fakeNSQD(t *testing.T) (string, <-chan []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	batches := make(chan []string, 10)
+	frame := func(conn net.Conn, data string) {
+		buf := binary.BigEndian.AppendUint32(nil, uint32(4+len(data)))
+		buf = binary.BigEndian.AppendUint32(buf, nsqFrameResponse)
+		conn.Write(append(buf, data...))
+	}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		magic := make([]byte, 4)
+		if _, err := io.ReadFull(r, magic); err != nil || string(magic) != "  V2" {
+			t.Errorf("неверное приветствие протокола: %q", magic)
+			return
+		}
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if line == "NOP\n" {
+				continue
+			}
+			if line != "MPUB access\n" {
+				t.Errorf("неожиданная команда: %q", line)
+				return
+			}
+			var header [8]byte
+			io.ReadFull(r, header[:])
+			var batch []string
+			for n := binary.BigEndian.Uint32(header[4:]); n > 0; n-- {
+				var size [4]byte
+				io.ReadFull(r, size[:])
+				m := make([]byte, binary.BigEndian.Uint32(size[:]))
+				io.ReadFull(r, m)
+				batch = append(batch, string(m))
+			}
+			batches <- batch
+			frame(conn, "_heartbeat_")
+			frame(conn, "OK")
+		}
+	}()
+	return ln.Addr().String(), batches
+}
+
+func TestPublisher_NSQ(t *testing.T) {
+	addr, batches := fakeNSQD(t)
+	p, err := New(&config.AccessEventsConfig{Type: "nsq", Address: addr, Topic: "access", BatchSize: 2, FlushInterval: time.Hour}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Start()
+
+	// Полный пакет отправляется сразу, остаток — при остановке
+	for _, path := range []string{"/a", "/b", "/c"} {
+		p.Publish(Event{Method: "GET", Path: path, Status: 200})
+	}
+	batch := <-batches
+	if len(batch) != 2 {
+		t.Fatalf("ожидался пакет из 2 событий, получено %d", len(batch))
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(batch[0]), &e); err != nil || e.Path != "/a" {
+		t.Errorf("неверное событие: %s", batch[0])
+	}
+
+	p.Stop()
+	select {
+	case batch := <-batches:
+		if len(batch) != 1 || !strings.Contains(batch[0], `"/c"`) {
+			t.Errorf("при остановке должно быть отправлено оставшееся событие: %v", batch)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("события из очереди не отправлены при остановке")
+	}
+}
+
+// failingSink не принимает пакеты, пока не разрешено
+type failingSink struct {
+	mu       sync.Mutex
+	ok       bool
+	attempts int
+	received int
+	closed   bool
+}
+
+func (s *failingSink) publish(ctx context.Context, topic string, messages [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts++
+	if !s.ok {
+		return errors.New("broker unavailable")
+	}
+	s.received += len(messages)
+	return nil
+}
+
+func (s *failingSink) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func TestPublisher_Backpressure(t *testing.T) {
+	sink := &failingSink{}
+	p := &Publisher{
+		typ: "test", topic: "access", batchSize: 2, flushInterval: 10 * time.Millisecond,
+		sink: sink, logger: logger.NewNop(),
+		queue: make(chan Event, 3), stop: make(chan struct{}), done: make(chan struct{}),
+	}
+	p.Start()
+
+	// Пакет из 2 событий не отправлен, очередь на 3 события заполняется, остальные отбрасываются
+	before := dropped.Value()
+	for i := 0; i < 2; i++ {
+		p.Publish(Event{Path: "/"})
+	}
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		p.Publish(Event{Path: "/"})
+	}
+	if got := dropped.Value() - before; got != 2 {
+		t.Errorf("ожидалось 2 отброшенных события, получено %d", got)
+	}
+
+	sink.mu.Lock()
+	sink.ok = true
+	sink.mu.Unlock()
+	p.Stop()
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.attempts < 2 {
+		t.Errorf("неотправленный пакет должен повторяться, попыток: %d", sink.attempts)
+	}
+	if sink.received != 5 {
+		t.Errorf("после восстановления брокера должны быть отправлены 5 событий, отправлено %d", sink.received)
+	}
+}
+
+func TestPublisher_StopWithoutStart(t *testing.T) {
+	sink := &failingSink{}
+	p := &Publisher{
+		typ: "test", topic: "access", batchSize: 2, flushInterval: 10 * time.Millisecond,
+		sink: sink, logger: logger.NewNop(),
+		queue: make(chan Event, 3), stop: make(chan struct{}), done: make(chan struct{}),
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		p.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("остановка незапущенной публикации не должна блокироваться")
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if !sink.closed {
+		t.Error("остановка незапущенной публикации должна закрывать соединение с брокером")
+	}
+}
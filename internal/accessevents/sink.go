@@ -0,0 +1,138 @@
+package accessevents
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"cloud.ru_test/internal/kafka"
+)
+
+// kafkaSink отправляет события в Kafka без ключа: пакет целиком попадает в один раздел,
+// следующий пакет — в следующий
+type kafkaSink struct {
+	producer *kafka.Producer
+}
+
+func newKafkaSink(brokers []string) *kafkaSink {
+	return &kafkaSink{producer: kafka.NewProducer(brokers, "lb-access-events")}
+}
+
+func (s *kafkaSink) publish(ctx context.Context, topic string, messages [][]byte) error {
+	batch := make([]kafka.Message, len(messages))
+	for i, m := range messages {
+		batch[i].Value = m
+	}
+	return s.producer.Produce(ctx, topic, batch)
+}
+
+func (s *kafkaSink) close() error {
+	return s.producer.Close()
+}
+
+// Кадры ответов nsqd
+const (
+	nsqFrameResponse = 0
+	nsqFrameError    = 1
+
+	nsqDialTimeout = 5 * time.Second
+	nsqMaxFrame    = 1 << 20
+)
+
+// nsqSink отправляет события в nsqd командой MPUB протокола V2. Соединение
+// сохраняется между отправками и устанавливается заново после ошибки.
+type nsqSink struct {
+	address string
+	conn    net.Conn
+	reader  *bufio.Reader
+}
+
+func newNSQSink(address string) *nsqSink {
+	return &nsqSink{address: address}
+}
+
+func (s *nsqSink) publish(ctx context.Context, topic string, messages [][]byte) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	if s.conn == nil {
+		dialer := net.Dialer{Timeout: nsqDialTimeout}
+		conn, err := dialer.DialContext(ctx, "tcp", s.address)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.Write([]byte("  V2")); err != nil {
+			conn.Close()
+			return err
+		}
+		s.conn, s.reader = conn, bufio.NewReader(conn)
+	}
+
+	if err := s.mpub(ctx, topic, messages); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return fmt.Errorf("nsq: %s: %w", s.address, err)
+	}
+	return nil
+}
+
+// mpub отправляет MPUB и дожидается подтверждения, отвечая на heartbeat nsqd
+func (s *nsqSink) mpub(ctx context.Context, topic string, messages [][]byte) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetDeadline(deadline)
+	}
+
+	size := 4
+	for _, m := range messages {
+		size += 4 + len(m)
+	}
+	cmd := make([]byte, 0, len(topic)+14+size)
+	cmd = append(cmd, "MPUB "+topic+"\n"...)
+	cmd = binary.BigEndian.AppendUint32(cmd, uint32(size))
+	cmd = binary.BigEndian.AppendUint32(cmd, uint32(len(messages)))
+	for _, m := range messages {
+		cmd = binary.BigEndian.AppendUint32(cmd, uint32(len(m)))
+		cmd = append(cmd, m...)
+	}
+	if _, err := s.conn.Write(cmd); err != nil {
+		return err
+	}
+
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(s.reader, header[:]); err != nil {
+			return err
+		}
+		n := binary.BigEndian.Uint32(header[:4])
+		if n < 4 || n > nsqMaxFrame {
+			return errors.New("malformed frame")
+		}
+		data := make([]byte, n-4)
+		if _, err := io.ReadFull(s.reader, data); err != nil {
+			return err
+		}
+
+		switch frameType := binary.BigEndian.Uint32(header[4:]); {
+		case frameType == nsqFrameResponse && string(data) == "_heartbeat_":
+			if _, err := s.conn.Write([]byte("NOP\n")); err != nil {
+				return err
+			}
+		case frameType == nsqFrameResponse:
+			return nil
+		case frameType == nsqFrameError:
+			return fmt.Errorf("nsqd error: %s", data)
+		}
+	}
+}
+
+func (s *nsqSink) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
@@ -0,0 +1,214 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.ru_test/internal/apikeys"
+)
+
+// apiKeyInfo ключ API в ответах API; значение ключа возвращается только при выпуске
+type apiKeyInfo struct {
+	ID        string             `json:"id"`
+	Name      string             `json:"name"`
+	Source    string             `json:"source"`
+	Prefix    string             `json:"prefix,omitempty"`
+	Routes    []string           `json:"routes,omitempty"`
+	RateLimit *apikeys.RateLimit `json:"rateLimit,omitempty"`
+	ExpiresAt *time.Time         `json:"expiresAt,omitempty"`
+	Disabled  bool               `json:"disabled"`
+	CreatedAt *time.Time         `json:"createdAt,omitempty"`
+	Key       string             `json:"key,omitempty"`
+}
+
+// apiKeyRequest тело запроса на выпуск и изменение ключа
+type apiKeyRequest struct {
+	Name      string             `json:"name"`
+	Routes    []string           `json:"routes,omitempty"`
+	RateLimit *apikeys.RateLimit `json:"rateLimit,omitempty"`
+	ExpiresAt *time.Time         `json:"expiresAt,omitempty"`
+	Disabled  bool               `json:"disabled"`
+}
+
+// newAPIKeyInfo собирает описание ключа для ответа
+func newAPIKeyInfo(k apikeys.Key, secret string) apiKeyInfo {
+	info := apiKeyInfo{
+		ID:        k.ID,
+		Name:      k.Name,
+		Source:    "store",
+		Prefix:    k.Prefix,
+		Routes:    k.Routes,
+		RateLimit: k.RateLimit,
+		ExpiresAt: k.ExpiresAt,
+		Disabled:  k.Disabled,
+		Key:       secret,
+	}
+	if k.Static {
+		info.Source = "config"
+	}
+	if !k.CreatedAt.IsZero() {
+		info.CreatedAt = &k.CreatedAt
+	}
+	return info
+}
+
+// handleAPIKeys обрабатывает список ключей API и выпуск нового
+func (s *Server) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys := s.provider.APIKeys()
+	if keys == nil {
+		http.Error(w, "API keys are not configured", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		list := keys.List()
+		response := make([]apiKeyInfo, 0, len(list))
+		for _, k := range list {
+			response = append(response, newAPIKeyInfo(k, ""))
+		}
+		s.writeJSON(w, http.StatusOK, response)
+	case http.MethodPost:
+		req, ok := s.decodeAPIKeyRequest(w, r)
+		if !ok {
+			return
+		}
+		k, secret, err := keys.Create(r.Context(), req.key())
+		if err != nil {
+			s.apiKeyError(w, err)
+			return
+		}
+		s.logger.Info(fmt.Sprintf("Выпущен ключ API %s (%s)", k.ID, k.Name))
+		s.writeJSON(w, http.StatusCreated, newAPIKeyInfo(k, secret))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAPIKey обрабатывает операции с отдельным ключом: /admin/apikeys/{id}
+// и /admin/apikeys/{id}/rotate
+func (s *Server) handleAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/admin/apikeys/")
+	id, action, _ := strings.Cut(id, "/")
+	if id == "" || (action != "" && action != "rotate") {
+		http.Error(w, "Invalid URL format. Use /admin/apikeys/{id} or /admin/apikeys/{id}/rotate", http.StatusBadRequest)
+		return
+	}
+
+	keys := s.provider.APIKeys()
+	if keys == nil {
+		http.Error(w, "API keys are not configured", http.StatusNotFound)
+		return
+	}
+
+	if action == "rotate" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		k, secret, err := keys.Rotate(r.Context(), id)
+		if err != nil {
+			s.apiKeyError(w, err)
+			return
+		}
+		s.logger.Info(fmt.Sprintf("Ключ API %s заменен", id))
+		s.writeJSON(w, http.StatusOK, newAPIKeyInfo(k, secret))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		k, ok := keys.Get(id)
+		if !ok {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, newAPIKeyInfo(k, ""))
+	case http.MethodPut:
+		req, ok := s.decodeAPIKeyRequest(w, r)
+		if !ok {
+			return
+		}
+		k, err := keys.Update(r.Context(), id, func(k *apikeys.Key) {
+			updated := req.key()
+			k.Name, k.Routes, k.RateLimit, k.ExpiresAt, k.Disabled = updated.Name, updated.Routes, updated.RateLimit, updated.ExpiresAt, updated.Disabled
+		})
+		if err != nil {
+			s.apiKeyError(w, err)
+			return
+		}
+		s.logger.Info(fmt.Sprintf("Ключ API %s изменен", id))
+		s.writeJSON(w, http.StatusOK, newAPIKeyInfo(k, ""))
+	case http.MethodDelete:
+		if err := keys.Delete(r.Context(), id); err != nil {
+			s.apiKeyError(w, err)
+			return
+		}
+		s.logger.Info(fmt.Sprintf("Ключ API %s удален", id))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// key возвращает параметры ключа из запроса
+func (req apiKeyRequest) key() apikeys.Key {
+	return apikeys.Key{
+		Name:      req.Name,
+		Routes:    req.Routes,
+		RateLimit: req.RateLimit,
+		ExpiresAt: req.ExpiresAt,
+		Disabled:  req.Disabled,
+	}
+}
+
+// decodeAPIKeyRequest читает и проверяет параметры ключа; при ошибке отвечает 400
+func (s *Server) decodeAPIKeyRequest(w http.ResponseWriter, r *http.Request) (apiKeyRequest, bool) {
+	var req apiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return req, false
+	}
+	if req.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return req, false
+	}
+	if rl := req.RateLimit; rl != nil && (rl.Rate <= 0 || rl.Burst <= 0) {
+		http.Error(w, "Rate and burst must be positive", http.StatusBadRequest)
+		return req, false
+	}
+
+	// Маршруты ключа должны существовать в текущей конфигурации
+	routes := make(map[string]bool)
+	if cfg := s.provider.Config(); cfg != nil && cfg.Routing != nil {
+		for _, rule := range cfg.Routing.Rules {
+			routes[rule.Name] = true
+		}
+	}
+	for _, route := range req.Routes {
+		if !routes[route] {
+			http.Error(w, fmt.Sprintf("Unknown route %q", route), http.StatusBadRequest)
+			return req, false
+		}
+	}
+	return req, true
+}
+
+// apiKeyError отвечает на ошибку операции с ключом
+func (s *Server) apiKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, apikeys.ErrNotFound):
+		http.Error(w, "API key not found", http.StatusNotFound)
+	case errors.Is(err, apikeys.ErrReadOnly):
+		http.Error(w, "API key is defined in the configuration and can not be changed", http.StatusConflict)
+	case errors.Is(err, apikeys.ErrNoStore):
+		http.Error(w, "API key store is not configured", http.StatusConflict)
+	default:
+		s.logger.Error(fmt.Sprintf("Ошибка операции с ключом API: %v", err))
+		http.Error(w, "API key store error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,151 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cloud.ru_test/config"
+)
+
+// Role определяет уровень доступа к административному API
+type Role int
+
+const (
+	// RoleNone доступ запрещен
+	RoleNone Role = iota
+	// RoleRead только чтение (GET, HEAD)
+	RoleRead
+	// RoleAdmin чтение и изменение
+	RoleAdmin
+)
+
+// parseRole преобразует роль из конфигурации
+func parseRole(role string) Role {
+	switch role {
+	case "read":
+		return RoleRead
+	case "admin":
+		return RoleAdmin
+	default:
+		return RoleNone
+	}
+}
+
+// requiredRole возвращает роль, необходимую для выполнения запроса
+func requiredRole(r *http.Request) Role {
+	// Профили занимают процессор и раскрывают содержимое памяти процесса
+	if strings.HasPrefix(r.URL.Path, "/debug/") {
+		return RoleAdmin
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return RoleRead
+	default:
+		return RoleAdmin
+	}
+}
+
+// credential секрет с назначенной ролью
+type credential struct {
+	secret string
+	role   Role
+}
+
+// authenticator определяет роль клиента по токену, basic auth или клиентскому сертификату
+type authenticator struct {
+	tokens      []credential
+	users       map[string]credential
+	clientCerts map[string]Role
+}
+
+// newAuthenticator создает аутентификатор из конфигурации.
+// Возвращает nil, если ни один способ аутентификации не настроен.
+func newAuthenticator(cfg *config.AdminConfig) *authenticator {
+	a := &authenticator{
+		users:       make(map[string]credential),
+		clientCerts: make(map[string]Role),
+	}
+
+	if cfg.Token != "" {
+		a.tokens = append(a.tokens, credential{secret: cfg.Token, role: RoleAdmin})
+	}
+
+	if cfg.Auth != nil {
+		for _, t := range cfg.Auth.Tokens {
+			a.tokens = append(a.tokens, credential{secret: t.Token, role: parseRole(t.Role)})
+		}
+		for _, u := range cfg.Auth.Users {
+			a.users[u.Username] = credential{secret: u.Password, role: parseRole(u.Role)}
+		}
+		for _, c := range cfg.Auth.ClientCerts {
+			a.clientCerts[c.CommonName] = parseRole(c.Role)
+		}
+	}
+
+	if len(a.tokens) == 0 && len(a.users) == 0 && len(a.clientCerts) == 0 {
+		return nil
+	}
+
+	return a
+}
+
+// authenticate возвращает роль клиента и его идентификатор для логов
+func (a *authenticator) authenticate(r *http.Request) (Role, string) {
+	// Клиентский сертификат уже проверен TLS стеком, остается сопоставить CommonName
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		cn := r.TLS.PeerCertificates[0].Subject.CommonName
+		if role, ok := a.clientCerts[cn]; ok {
+			return role, "cert:" + cn
+		}
+	}
+
+	if username, password, ok := r.BasicAuth(); ok {
+		if cred, exists := a.users[username]; exists && secureEqual(cred.secret, password) {
+			return cred.role, "user:" + username
+		}
+		return RoleNone, "user:" + username
+	}
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		for _, cred := range a.tokens {
+			if secureEqual(cred.secret, token) {
+				return cred.role, "token"
+			}
+		}
+		return RoleNone, "token"
+	}
+
+	return RoleNone, "anonymous"
+}
+
+// secureEqual сравнивает секреты за постоянное время
+func secureEqual(expected, actual string) bool {
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
+}
+
+// withAuth проверяет права клиента, если аутентификация настроена
+func (s *Server) withAuth(next http.Handler) http.Handler {
+	if s.auth == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, identity := s.auth.authenticate(r)
+		if role == RoleNone {
+			s.logger.Debug(fmt.Sprintf("Отклонен неавторизованный запрос к административному API: %s %s от %s (%s)", r.Method, r.URL.Path, r.RemoteAddr, identity))
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if role < requiredRole(r) {
+			s.logger.Debug(fmt.Sprintf("Недостаточно прав для %s %s (%s)", r.Method, r.URL.Path, identity))
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
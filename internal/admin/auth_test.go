@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.ru_test/config"
+)
+
+func TestAuthenticator_Disabled(t *testing.T) {
+	if a := newAuthenticator(&config.AdminConfig{Port: ":9090"}); a != nil {
+		t.Error("аутентификатор должен быть отключен, если способы аутентификации не настроены")
+	}
+}
+
+func TestAuthenticator_Roles(t *testing.T) {
+	a := newAuthenticator(&config.AdminConfig{
+		Port:  ":9090",
+		Token: "legacy",
+		Auth: &config.AdminAuthConfig{
+			Tokens:      []config.AdminTokenConfig{{Token: "viewer", Role: "read"}},
+			Users:       []config.AdminUserConfig{{Username: "ops", Password: "secret", Role: "admin"}},
+			ClientCerts: []config.AdminClientCertConfig{{CommonName: "monitoring", Role: "read"}},
+		},
+	})
+
+	tests := []struct {
+		name  string
+		setup func(r *http.Request)
+		want  Role
+	}{
+		{"без учетных данных", func(r *http.Request) {}, RoleNone},
+		{"устаревший токен", func(r *http.Request) { r.Header.Set("Authorization", "Bearer legacy") }, RoleAdmin},
+		{"токен только для чтения", func(r *http.Request) { r.Header.Set("Authorization", "Bearer viewer") }, RoleRead},
+		{"неверный токен", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, RoleNone},
+		{"basic auth", func(r *http.Request) { r.SetBasicAuth("ops", "secret") }, RoleAdmin},
+		{"неверный пароль", func(r *http.Request) { r.SetBasicAuth("ops", "wrong") }, RoleNone},
+		{"клиентский сертификат", func(r *http.Request) {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "monitoring"}}}}
+		}, RoleRead},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/ratelimit/user", nil)
+		tt.setup(r)
+		if got, _ := a.authenticate(r); got != tt.want {
+			t.Errorf("%s: неверная роль: got=%v, want=%v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRequiredRole(t *testing.T) {
+	if requiredRole(httptest.NewRequest("GET", "/ratelimit/user", nil)) != RoleRead {
+		t.Error("GET должен требовать роль read")
+	}
+	if requiredRole(httptest.NewRequest("DELETE", "/ratelimit/user", nil)) != RoleAdmin {
+		t.Error("DELETE должен требовать роль admin")
+	}
+	if requiredRole(httptest.NewRequest("GET", "/debug/pprof/heap", nil)) != RoleAdmin {
+		t.Error("профилирование должно требовать роль admin")
+	}
+}
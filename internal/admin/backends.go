@@ -0,0 +1,368 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/pkg/backend"
+)
+
+// drainPollInterval период проверки активных соединений при выводе бэкенда
+const drainPollInterval = 100 * time.Millisecond
+
+// backendInfo состояние бэкенда в ответах API
+type backendInfo struct {
+	ID                string  `json:"id"`
+	URL               string  `json:"url"`
+	Zone              string  `json:"zone,omitempty"`
+	Weight            float64 `json:"weight"`
+	Alive             bool    `json:"alive"`
+	Maintenance       bool    `json:"maintenance"`
+	Ejected           bool    `json:"ejected,omitempty"`
+	ActiveConnections int64   `json:"activeConnections"`
+	MaxConnections    int     `json:"maxConnections,omitempty"`
+	ConcurrencyLimit  int     `json:"concurrencyLimit,omitempty"`
+	AvgResponseTimeMs int64   `json:"avgResponseTimeMs"`
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	SuccessRate       float64 `json:"successRate"`
+
+	// Ответы по классам статусов за последнюю минуту
+	Responses responseCounts `json:"responses"`
+
+	// Сведения, которые бэкенд сообщил в ответе на проверку здоровья
+	Draining     bool     `json:"draining,omitempty"`
+	Version      string   `json:"version,omitempty"`
+	ReportedLoad *float64 `json:"reportedLoad,omitempty"`
+}
+
+// responseCounts количество ответов бэкенда по классам статусов
+type responseCounts struct {
+	Status2xx int64 `json:"2xx"`
+	Status3xx int64 `json:"3xx"`
+	Status4xx int64 `json:"4xx"`
+	Status5xx int64 `json:"5xx"`
+	Errors    int64 `json:"errors"`
+	Canceled  int64 `json:"canceled"`
+}
+
+// backendRequest тело запроса на добавление бэкенда
+type backendRequest struct {
+	ID          string   `json:"id"`
+	URL         string   `json:"url"`
+	Weight      *float64 `json:"weight,omitempty"`
+	Maintenance bool     `json:"maintenance"`
+}
+
+// backendPatch тело запроса на изменение бэкенда
+type backendPatch struct {
+	Weight      *float64 `json:"weight,omitempty"`
+	Maintenance *bool    `json:"maintenance,omitempty"`
+}
+
+// newBackendInfo собирает состояние бэкенда для ответа
+func newBackendInfo(b backend.Backend) backendInfo {
+	stats := b.GetLoadStats()
+	metadata := b.HealthMetadata()
+	return backendInfo{
+		ID:                b.ID(),
+		URL:               b.URL(),
+		Zone:              b.Zone(),
+		Weight:            b.Weight(),
+		Alive:             b.IsAlive(),
+		Maintenance:       b.InMaintenance(),
+		Ejected:           b.IsEjected(),
+		ActiveConnections: stats.ActiveConnections,
+		MaxConnections:    b.MaxConnections(),
+		ConcurrencyLimit:  stats.ConcurrencyLimit,
+		AvgResponseTimeMs: stats.AvgResponseTime.Milliseconds(),
+		RequestsPerSecond: stats.RequestsPerSecond,
+		SuccessRate:       stats.SuccessRate,
+		Responses:         responseCounts(stats.Responses),
+		Draining:          metadata.Draining,
+		Version:           metadata.Version,
+		ReportedLoad:      metadata.Load,
+	}
+}
+
+// handleBackends обрабатывает список бэкендов и добавление нового
+func (s *Server) handleBackends(w http.ResponseWriter, r *http.Request) {
+	lb := s.provider.LoadBalancer()
+	if lb == nil {
+		http.Error(w, "Load balancer is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		backends := lb.GetBackends()
+		response := make([]backendInfo, 0, len(backends))
+		for _, state := range backends {
+			response = append(response, newBackendInfo(state.Backend))
+		}
+		s.writeJSON(w, http.StatusOK, response)
+	case http.MethodPost:
+		s.addBackend(w, r, lb)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBackend обрабатывает операции с отдельным бэкендом: /admin/backends/{id}
+func (s *Server) handleBackend(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/admin/backends/")
+	id, action, _ := strings.Cut(id, "/")
+	if id == "" || (action != "" && action != "probe") {
+		http.Error(w, "Invalid URL format. Use /admin/backends/{id} or /admin/backends/{id}/probe", http.StatusBadRequest)
+		return
+	}
+
+	lb := s.provider.LoadBalancer()
+	if lb == nil {
+		http.Error(w, "Load balancer is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	state := lb.GetBackend(id)
+	if state == nil {
+		http.Error(w, "Backend not found", http.StatusNotFound)
+		return
+	}
+
+	if action == "probe" {
+		s.probeBackend(w, r, state.Backend)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeJSON(w, http.StatusOK, newBackendInfo(state.Backend))
+	case http.MethodPatch, http.MethodPut:
+		s.updateBackend(w, r, state.Backend)
+	case http.MethodDelete:
+		s.removeBackend(w, r, lb, state.Backend)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// probeResponse результат внеочередной проверки бэкенда
+type probeResponse struct {
+	ID         string `json:"id"`
+	URL        string `json:"url"`
+	Healthy    bool   `json:"healthy"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	LatencyMs  int64  `json:"latencyMs"`
+	Body       string `json:"body,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// probeBackend выполняет внеочередную проверку бэкенда: POST /admin/backends/{id}/probe.
+// Проверка идет напрямую к бэкенду, минуя прокси, и не меняет его состояние.
+func (s *Server) probeBackend(w http.ResponseWriter, r *http.Request, b backend.Backend) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	checker := s.provider.HealthChecker()
+	if checker == nil {
+		http.Error(w, "Health check is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	result := checker.Probe(b)
+	response := probeResponse{
+		ID:         b.ID(),
+		URL:        result.URL,
+		Healthy:    result.Healthy,
+		StatusCode: result.StatusCode,
+		LatencyMs:  result.Latency.Milliseconds(),
+		Body:       result.Body,
+	}
+	if result.Err != nil {
+		response.Error = result.Err.Error()
+	}
+	s.logger.Info(fmt.Sprintf("Внеочередная проверка бэкенда %s: healthy=%t, статус: %d, время: %v",
+		b.ID(), result.Healthy, result.StatusCode, result.Latency))
+
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+// addBackend добавляет бэкенд в работающий балансировщик
+func (s *Server) addBackend(w http.ResponseWriter, r *http.Request, lb loadbalancer.LoadBalancer) {
+	var req backendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Debug(fmt.Sprintf("Ошибка декодирования тела запроса: %v", err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	backendCfg := config.BackendConfig{
+		ID:          req.ID,
+		URL:         req.URL,
+		Weight:      req.Weight,
+		Maintenance: req.Maintenance,
+	}
+	if err := config.ValidateBackend(backendCfg); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid backend: %v", err), http.StatusBadRequest)
+		return
+	}
+	if lb.GetBackend(req.ID) != nil {
+		http.Error(w, "Backend already exists", http.StatusConflict)
+		return
+	}
+
+	if isPersistRequested(r) {
+		err := s.provider.UpdateConfig(func(cfg *config.Config) error {
+			cfg.Backends = append(cfg.Backends, backendCfg)
+			return nil
+		})
+		if err != nil {
+			s.logger.Error(fmt.Sprintf("Ошибка сохранения бэкенда %s в конфигурацию: %v", req.ID, err))
+			http.Error(w, fmt.Sprintf("Failed to persist config: %v", err), persistStatus(err))
+			return
+		}
+	}
+
+	b := backend.NewFromConfig(backendCfg)
+	lb.AddBackend(b)
+	s.logger.Info(fmt.Sprintf("Через административное API добавлен бэкенд %s (%s)", req.ID, req.URL))
+
+	s.writeJSON(w, http.StatusCreated, newBackendInfo(b))
+}
+
+// updateBackend изменяет вес или режим обслуживания бэкенда
+func (s *Server) updateBackend(w http.ResponseWriter, r *http.Request, b backend.Backend) {
+	var patch backendPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		s.logger.Debug(fmt.Sprintf("Ошибка декодирования тела запроса: %v", err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := config.ValidateBackend(config.BackendConfig{ID: b.ID(), URL: b.URL(), Weight: patch.Weight}); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid backend: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if isPersistRequested(r) {
+		err := s.provider.UpdateConfig(func(cfg *config.Config) error {
+			for i := range cfg.Backends {
+				if cfg.Backends[i].ID != b.ID() {
+					continue
+				}
+				if patch.Weight != nil {
+					cfg.Backends[i].Weight = patch.Weight
+				}
+				if patch.Maintenance != nil {
+					cfg.Backends[i].Maintenance = *patch.Maintenance
+				}
+				return nil
+			}
+			return fmt.Errorf("backend %s is not present in config file", b.ID())
+		})
+		if err != nil {
+			s.logger.Error(fmt.Sprintf("Ошибка сохранения бэкенда %s в конфигурацию: %v", b.ID(), err))
+			http.Error(w, fmt.Sprintf("Failed to persist config: %v", err), persistStatus(err))
+			return
+		}
+	}
+
+	if patch.Weight != nil {
+		b.SetWeight(*patch.Weight)
+		s.logger.Info(fmt.Sprintf("Вес бэкенда %s изменен на %.2f", b.ID(), *patch.Weight))
+	}
+	if patch.Maintenance != nil {
+		b.SetMaintenance(*patch.Maintenance)
+		s.logger.Info(fmt.Sprintf("Режим обслуживания бэкенда %s: %t", b.ID(), *patch.Maintenance))
+	}
+
+	s.writeJSON(w, http.StatusOK, newBackendInfo(b))
+}
+
+// removeBackend удаляет бэкенд. С параметром drain бэкенд сначала перестает получать
+// новые запросы, а удаляется после завершения активных соединений или по истечении таймаута.
+func (s *Server) removeBackend(w http.ResponseWriter, r *http.Request, lb loadbalancer.LoadBalancer, b backend.Backend) {
+	var drainTimeout time.Duration
+	if drain := r.URL.Query().Get("drain"); drain != "" {
+		timeout, err := time.ParseDuration(drain)
+		if err != nil || timeout < 0 {
+			http.Error(w, "Invalid drain duration", http.StatusBadRequest)
+			return
+		}
+		drainTimeout = timeout
+	}
+	persist := isPersistRequested(r)
+
+	remove := func() {
+		lb.RemoveBackend(b)
+		b.Close()
+		s.logger.Info(fmt.Sprintf("Через административное API удален бэкенд %s", b.ID()))
+
+		if !persist {
+			return
+		}
+		err := s.provider.UpdateConfig(func(cfg *config.Config) error {
+			backends := cfg.Backends[:0]
+			for _, backendCfg := range cfg.Backends {
+				if backendCfg.ID != b.ID() {
+					backends = append(backends, backendCfg)
+				}
+			}
+			cfg.Backends = backends
+			return nil
+		})
+		if err != nil {
+			s.logger.Error(fmt.Sprintf("Ошибка удаления бэкенда %s из конфигурации: %v", b.ID(), err))
+		}
+	}
+
+	if drainTimeout == 0 {
+		remove()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	b.SetMaintenance(true)
+	s.logger.Info(fmt.Sprintf("Начат вывод бэкенда %s (таймаут: %v)", b.ID(), drainTimeout))
+
+	go func() {
+		deadline := time.Now().Add(drainTimeout)
+		for b.GetLoadStats().ActiveConnections > 0 && time.Now().Before(deadline) {
+			time.Sleep(drainPollInterval)
+		}
+		remove()
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// persistStatus возвращает статус ответа на ошибку сохранения: 400, если изменение
+// не проходит проверку конфигурации (например, ID бэкенда уже есть в файле)
+func persistStatus(err error) int {
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// isPersistRequested проверяет, нужно ли сохранить изменение в файл конфигурации
+func isPersistRequested(r *http.Request) bool {
+	return r.URL.Query().Get("persist") == "true"
+}
+
+// writeJSON отправляет ответ в формате JSON
+func (s *Server) writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		s.logger.Error(fmt.Sprintf("Ошибка кодирования ответа: %v", err))
+	}
+}
@@ -0,0 +1,215 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/healthcheck"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestBackends_Add(t *testing.T) {
+	url := upstream(t)
+	provider := newTestProvider(t, testConfig(t, url, "b1"))
+	s := newTestServer(provider)
+
+	tests := []struct {
+		name  string
+		token string
+		body  string
+		want  int
+	}{
+		{"без токена", "", `{"id": "b2", "url": "` + url + `"}`, http.StatusUnauthorized},
+		{"роль read", readToken, `{"id": "b2", "url": "` + url + `"}`, http.StatusForbidden},
+		{"некорректный JSON", adminToken, `{"id":`, http.StatusBadRequest},
+		{"без ID", adminToken, `{"url": "` + url + `"}`, http.StatusBadRequest},
+		{"без адреса", adminToken, `{"id": "b2"}`, http.StatusBadRequest},
+		{"неподдерживаемая схема", adminToken, `{"id": "b2", "url": "ftp://127.0.0.1:21"}`, http.StatusBadRequest},
+		{"адрес без хоста", adminToken, `{"id": "b2", "url": "http://"}`, http.StatusBadRequest},
+		{"нулевой вес", adminToken, `{"id": "b2", "url": "` + url + `", "weight": 0}`, http.StatusBadRequest},
+		{"отрицательный вес", adminToken, `{"id": "b2", "url": "` + url + `", "weight": -1}`, http.StatusBadRequest},
+		{"существующий ID", adminToken, `{"id": "b1", "url": "` + url + `"}`, http.StatusConflict},
+		{"корректный бэкенд", adminToken, `{"id": "b2", "url": "` + url + `", "weight": 2}`, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		if status, body := serve(s, http.MethodPost, "/admin/backends", tt.token, tt.body); status != tt.want {
+			t.Errorf("%s: статус %d, ожидался %d: %s", tt.name, status, tt.want, body)
+		}
+	}
+
+	state := provider.lb.GetBackend("b2")
+	if state == nil || state.Backend.Weight() != 2 {
+		t.Fatal("корректный бэкенд должен добавляться в балансировщик с заданным весом")
+	}
+	if len(provider.lb.GetBackends()) != 2 {
+		t.Errorf("некорректные бэкенды не должны добавляться в балансировщик, всего %d", len(provider.lb.GetBackends()))
+	}
+	if len(provider.Config().Backends) != 1 {
+		t.Error("без persist=true конфигурация не должна меняться")
+	}
+}
+
+func TestBackends_Update(t *testing.T) {
+	provider := newTestProvider(t, testConfig(t, upstream(t), "b1"))
+	s := newTestServer(provider)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+		body   string
+		want   int
+	}{
+		{"роль read", http.MethodPatch, "/admin/backends/b1", readToken, `{"weight": 3}`, http.StatusForbidden},
+		{"неизвестный бэкенд", http.MethodPatch, "/admin/backends/b9", adminToken, `{"weight": 3}`, http.StatusNotFound},
+		{"некорректный JSON", http.MethodPatch, "/admin/backends/b1", adminToken, `{"weight":`, http.StatusBadRequest},
+		{"нулевой вес", http.MethodPatch, "/admin/backends/b1", adminToken, `{"weight": 0}`, http.StatusBadRequest},
+		{"вес и обслуживание", http.MethodPatch, "/admin/backends/b1", adminToken, `{"weight": 3, "maintenance": true}`, http.StatusOK},
+		{"чтение ролью read", http.MethodGet, "/admin/backends/b1", readToken, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if status, body := serve(s, tt.method, tt.target, tt.token, tt.body); status != tt.want {
+			t.Errorf("%s: статус %d, ожидался %d: %s", tt.name, status, tt.want, body)
+		}
+	}
+
+	b := provider.lb.GetBackend("b1").Backend
+	if b.Weight() != 3 || !b.InMaintenance() {
+		t.Errorf("изменения должны применяться к бэкенду: вес %v, обслуживание %t", b.Weight(), b.InMaintenance())
+	}
+}
+
+func TestBackends_RemoveWithDrain(t *testing.T) {
+	provider := newTestProvider(t, testConfig(t, upstream(t), "b1", "b2"))
+	s := newTestServer(provider)
+
+	if status, _ := serve(s, http.MethodDelete, "/admin/backends/b1?drain=abc", adminToken, ""); status != http.StatusBadRequest {
+		t.Errorf("некорректная длительность вывода должна отклоняться, статус %d", status)
+	}
+	if status, _ := serve(s, http.MethodDelete, "/admin/backends/b1?drain=1s", readToken, ""); status != http.StatusForbidden {
+		t.Errorf("удаление должно требовать роль admin, статус %d", status)
+	}
+
+	// Бэкенд без активных соединений выводится из ротации и удаляется
+	if status, body := serve(s, http.MethodDelete, "/admin/backends/b1?drain=5s", adminToken, ""); status != http.StatusAccepted {
+		t.Fatalf("вывод бэкенда должен начинаться асинхронно, статус %d: %s", status, body)
+	}
+	for deadline := time.Now().Add(2 * time.Second); provider.lb.GetBackend("b1") != nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("выведенный бэкенд должен удаляться из балансировщика")
+		}
+	}
+
+	// Без drain бэкенд удаляется сразу
+	if status, _ := serve(s, http.MethodDelete, "/admin/backends/b2", adminToken, ""); status != http.StatusNoContent {
+		t.Errorf("удаление без вывода должно завершаться сразу, статус %d", status)
+	}
+	if len(provider.lb.GetBackends()) != 0 {
+		t.Errorf("удаленные бэкенды не должны оставаться в балансировщике: %d", len(provider.lb.GetBackends()))
+	}
+	if len(provider.Config().Backends) != 2 {
+		t.Error("без persist=true конфигурация не должна меняться")
+	}
+}
+
+func TestBackends_Persist(t *testing.T) {
+	url := upstream(t)
+	provider := newTestProvider(t, testConfig(t, url, "b1", "b2"))
+	s := newTestServer(provider)
+
+	// Бэкенд, удаленный без persist, остается в конфигурации: повторное добавление
+	// с тем же ID не проходит ее проверку
+	if status, _ := serve(s, http.MethodDelete, "/admin/backends/b2", adminToken, ""); status != http.StatusNoContent {
+		t.Fatalf("удаление бэкенда: статус %d", status)
+	}
+	if status, body := serve(s, http.MethodPost, "/admin/backends?persist=true", adminToken, `{"id": "b2", "url": "`+url+`"}`); status != http.StatusBadRequest {
+		t.Errorf("ID, который уже есть в конфигурации, должен отклоняться: статус %d: %s", status, body)
+	}
+	if provider.lb.GetBackend("b2") != nil {
+		t.Error("отклоненный бэкенд не должен добавляться в балансировщик")
+	}
+
+	if status, body := serve(s, http.MethodPost, "/admin/backends?persist=true", adminToken, `{"id": "b3", "url": "`+url+`"}`); status != http.StatusCreated {
+		t.Fatalf("добавление с persist=true: статус %d: %s", status, body)
+	}
+	if status, body := serve(s, http.MethodPatch, "/admin/backends/b1?persist=true", adminToken, `{"weight": 4}`); status != http.StatusOK {
+		t.Fatalf("изменение с persist=true: статус %d: %s", status, body)
+	}
+	if status, _ := serve(s, http.MethodDelete, "/admin/backends/b1?persist=true", adminToken, ""); status != http.StatusNoContent {
+		t.Fatalf("удаление с persist=true: статус %d", status)
+	}
+
+	backends := provider.Config().Backends
+	if len(backends) != 2 || backends[0].ID != "b2" || backends[1].ID != "b3" {
+		t.Errorf("изменения с persist=true должны сохраняться в конфигурации: %+v", backends)
+	}
+
+	// Изменение без persist=true применяется только к работающему бэкенду
+	if status, _ := serve(s, http.MethodPatch, "/admin/backends/b3?persist=false", adminToken, `{"weight": 2}`); status != http.StatusOK {
+		t.Errorf("изменение без persist: статус %d", status)
+	}
+	if backends := provider.Config().Backends; backends[1].Weight != nil {
+		t.Errorf("изменение без persist=true не должно сохраняться: %v", *backends[1].Weight)
+	}
+}
+
+func TestBackends_Probe(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	cfg := testConfig(t, upstream(t), "healthy")
+	cfg.Backends = append(cfg.Backends, config.BackendConfig{ID: "failing", URL: failing.URL})
+	provider := newTestProvider(t, cfg)
+	s := newTestServer(provider)
+
+	// Без проверок здоровья внеочередная проверка недоступна
+	if status, _ := serve(s, http.MethodPost, "/admin/backends/healthy/probe", adminToken, ""); status != http.StatusServiceUnavailable {
+		t.Errorf("без health check ожидался статус 503, получен %d", status)
+	}
+	provider.checker = healthcheck.New(nil, provider.lb, logger.NewNop())
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		token      string
+		want       int
+		healthy    bool
+		statusCode int
+	}{
+		{"роль read", http.MethodPost, "/admin/backends/healthy/probe", readToken, http.StatusForbidden, false, 0},
+		{"метод GET", http.MethodGet, "/admin/backends/healthy/probe", readToken, http.StatusMethodNotAllowed, false, 0},
+		{"неизвестное действие", http.MethodPost, "/admin/backends/healthy/check", adminToken, http.StatusBadRequest, false, 0},
+		{"неизвестный бэкенд", http.MethodPost, "/admin/backends/unknown/probe", adminToken, http.StatusNotFound, false, 0},
+		{"здоровый бэкенд", http.MethodPost, "/admin/backends/healthy/probe", adminToken, http.StatusOK, true, http.StatusOK},
+		{"неисправный бэкенд", http.MethodPost, "/admin/backends/failing/probe", adminToken, http.StatusOK, false, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		status, body := serve(s, tt.method, tt.target, tt.token, "")
+		if status != tt.want {
+			t.Errorf("%s: статус %d, ожидался %d: %s", tt.name, status, tt.want, body)
+			continue
+		}
+		if status != http.StatusOK {
+			continue
+		}
+		var result probeResponse
+		if err := json.Unmarshal([]byte(body), &result); err != nil {
+			t.Fatalf("%s: неверный JSON ответа: %v", tt.name, err)
+		}
+		if result.Healthy != tt.healthy || result.StatusCode != tt.statusCode {
+			t.Errorf("%s: неверный результат проверки: %+v", tt.name, result)
+		}
+	}
+
+	// Проверка не меняет состояние бэкенда
+	if !provider.lb.GetBackend("failing").Backend.IsAlive() {
+		t.Error("внеочередная проверка не должна менять состояние бэкенда")
+	}
+}
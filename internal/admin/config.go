@@ -0,0 +1,181 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"cloud.ru_test/config"
+)
+
+// maxConfigSize ограничивает размер проверяемой конфигурации
+const maxConfigSize = 1 << 20
+
+// validationResponse результат проверки конфигурации
+type validationResponse struct {
+	Valid  bool                `json:"valid"`
+	Error  string              `json:"error,omitempty"`
+	Errors []config.FieldError `json:"errors,omitempty"`
+}
+
+// handleConfig возвращает действующую конфигурацию в формате YAML со скрытыми секретами
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := s.provider.Config()
+	if cfg == nil {
+		s.logger.Debug("Запрошена конфигурация до ее применения")
+		http.Error(w, "Configuration is not applied yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	s.writeConfig(w, cfg)
+}
+
+// handleConfigValidate проверяет переданную конфигурацию, не применяя ее.
+// Формат передается параметром format (yaml, json, toml), по умолчанию YAML.
+func (s *Server) handleConfigValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigSize))
+	if err != nil {
+		s.logger.Debug(fmt.Sprintf("Ошибка чтения конфигурации для проверки: %v", err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = config.FormatYAML
+	}
+
+	response := validationResponse{Valid: true}
+	status := http.StatusOK
+	if _, err := config.ParseFormat(data, format); err != nil {
+		s.logger.Debug(fmt.Sprintf("Проверка конфигурации не пройдена: %v", err))
+		response = validationResponse{Valid: false, Error: err.Error()}
+
+		// Для ошибок проверки перечисляем все проблемы по отдельности
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			response.Errors = validationErr.Errors
+		}
+		status = http.StatusUnprocessableEntity
+	}
+
+	s.writeJSON(w, status, response)
+}
+
+// reloadResponse результат перезагрузки конфигурации
+type reloadResponse struct {
+	Changed bool `json:"changed"`
+	Version int  `json:"version"`
+}
+
+// handleReload перечитывает конфигурацию: POST /admin/reload
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	changed, err := s.provider.ReloadConfig()
+	if err != nil {
+		s.logger.Warn(fmt.Sprintf("Ошибка перезагрузки конфигурации через API: %v", err))
+
+		// Некорректная конфигурация не применяется, продолжает работать предыдущая
+		status := http.StatusInternalServerError
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			status = http.StatusUnprocessableEntity
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	response := reloadResponse{Changed: changed}
+	if history := s.provider.ConfigHistory(); len(history) > 0 {
+		response.Version = history[0].Version
+	}
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+// handleConfigHistory возвращает список сохраненных версий конфигурации, начиная с самой новой
+func (s *Server) handleConfigHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, s.provider.ConfigHistory())
+}
+
+// handleConfigVersion возвращает версию конфигурации из истории: /admin/config/history/{version}
+func (s *Server) handleConfigVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	version, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/admin/config/history/"))
+	if err != nil {
+		http.Error(w, "Invalid URL format. Use /admin/config/history/{version}", http.StatusBadRequest)
+		return
+	}
+
+	for _, v := range s.provider.ConfigHistory() {
+		if v.Version == version {
+			s.writeConfig(w, v.Config)
+			return
+		}
+	}
+
+	http.Error(w, "Config version not found", http.StatusNotFound)
+}
+
+// handleConfigRollback повторно применяет версию конфигурации: POST /admin/config/rollback?version=N
+func (s *Server) handleConfigRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	version, err := strconv.Atoi(r.URL.Query().Get("version"))
+	if err != nil {
+		http.Error(w, "Query parameter version is required", http.StatusBadRequest)
+		return
+	}
+
+	applied, err := s.provider.RollbackConfig(version)
+	if err != nil {
+		s.logger.Warn(fmt.Sprintf("Ошибка отката конфигурации к версии %d: %v", version, err))
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, applied)
+}
+
+// writeConfig отправляет конфигурацию в формате YAML со скрытыми секретами
+func (s *Server) writeConfig(w http.ResponseWriter, cfg *config.Config) {
+	data, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("Ошибка сериализации конфигурации: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(data)
+}
@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+	provider := newTestProvider(t, testConfig(t, upstream(t), "b1"))
+	current := provider.Config()
+	s := newTestServer(provider)
+
+	valid := "loadBalancer:\n  method: RoundRobin\nlogger:\n  logLevel: info\n  serviceName: test\nbackends:\n  - id: b2\n    url: http://127.0.0.1:8082\n"
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+		body   string
+		want   int
+		valid  bool
+		fields []string
+	}{
+		{"без токена", http.MethodPost, "/admin/config/validate", "", valid, http.StatusUnauthorized, false, nil},
+		{"роль read", http.MethodPost, "/admin/config/validate", readToken, valid, http.StatusForbidden, false, nil},
+		{"метод GET", http.MethodGet, "/admin/config/validate", adminToken, "", http.StatusMethodNotAllowed, false, nil},
+		{"корректный YAML", http.MethodPost, "/admin/config/validate", adminToken, valid, http.StatusOK, true, nil},
+		{"корректный JSON", http.MethodPost, "/admin/config/validate?format=json", adminToken,
+			`{"loadBalancer": {"method": "RoundRobin"}, "logger": {"logLevel": "info", "serviceName": "test"}, "backends": [{"id": "b2", "url": "http://127.0.0.1:8082"}]}`,
+			http.StatusOK, true, nil},
+		{"корректный TOML", http.MethodPost, "/admin/config/validate?format=toml", adminToken,
+			"[loadBalancer]\nmethod = \"RoundRobin\"\n\n[logger]\nlogLevel = \"info\"\nserviceName = \"test\"\n\n[[backends]]\nid = \"b2\"\nurl = \"http://127.0.0.1:8082\"\n",
+			http.StatusOK, true, nil},
+		{"ошибки проверки", http.MethodPost, "/admin/config/validate", adminToken,
+			strings.Replace(strings.Replace(valid, "RoundRobin", "", 1), "http://127.0.0.1:8082", "ftp://host", 1),
+			http.StatusUnprocessableEntity, false, []string{"loadBalancer.method", "backends[0].url"}},
+		{"некорректный YAML", http.MethodPost, "/admin/config/validate", adminToken, "backends: [", http.StatusUnprocessableEntity, false, nil},
+		{"неподдерживаемый формат", http.MethodPost, "/admin/config/validate?format=ini", adminToken, valid, http.StatusUnprocessableEntity, false, nil},
+		{"слишком большое тело", http.MethodPost, "/admin/config/validate", adminToken, strings.Repeat("#", maxConfigSize+1), http.StatusBadRequest, false, nil},
+	}
+
+	for _, tt := range tests {
+		status, body := serve(s, tt.method, tt.target, tt.token, tt.body)
+		if status != tt.want {
+			t.Errorf("%s: статус %d, ожидался %d: %s", tt.name, status, tt.want, body)
+			continue
+		}
+		if status != http.StatusOK && status != http.StatusUnprocessableEntity {
+			continue
+		}
+
+		var response validationResponse
+		if err := json.Unmarshal([]byte(body), &response); err != nil {
+			t.Fatalf("%s: неверный JSON ответа: %v", tt.name, err)
+		}
+		if response.Valid != tt.valid || (!tt.valid && response.Error == "") {
+			t.Errorf("%s: неверный результат проверки: %+v", tt.name, response)
+		}
+		if len(response.Errors) != len(tt.fields) {
+			t.Errorf("%s: ожидались ошибки полей %v, получено %+v", tt.name, tt.fields, response.Errors)
+			continue
+		}
+		for i, field := range tt.fields {
+			if response.Errors[i].Field != field {
+				t.Errorf("%s: ошибка поля %s, ожидалось %s", tt.name, response.Errors[i].Field, field)
+			}
+		}
+	}
+
+	if provider.Config() != current {
+		t.Error("проверка не должна применять конфигурацию")
+	}
+}
@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Время действия токена отладки маршрутизации
+const (
+	defaultDebugTokenTTL = 15 * time.Minute
+	maxDebugTokenTTL     = 24 * time.Hour
+)
+
+// debugRequest тело запроса PUT /admin/debug
+type debugRequest struct {
+	Enabled bool `json:"enabled"`
+
+	// Длительность режима, например "10m" (пустая — до явного отключения)
+	Duration string `json:"duration,omitempty"`
+}
+
+// debugTokenRequest тело запроса POST /admin/debug/token
+type debugTokenRequest struct {
+	// Время действия токена (по умолчанию 15m, не больше 24h)
+	TTL string `json:"ttl,omitempty"`
+}
+
+// debugTokenResponse выпущенный токен отладки маршрутизации
+type debugTokenResponse struct {
+	Header    string    `json:"header"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// handleDebug показывает и переключает отладку маршрутизации для всех запросов
+func (s *Server) handleDebug(w http.ResponseWriter, r *http.Request) {
+	debug := s.provider.RoutingDebug()
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeJSON(w, http.StatusOK, debug.Status())
+	case http.MethodPut:
+		var req debugRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.logger.Debug(fmt.Sprintf("Ошибка декодирования тела запроса: %v", err))
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		var duration time.Duration
+		if req.Duration != "" {
+			var err error
+			if duration, err = time.ParseDuration(req.Duration); err != nil || duration <= 0 {
+				http.Error(w, "Invalid duration", http.StatusBadRequest)
+				return
+			}
+		}
+
+		debug.SetEnabled(req.Enabled, duration)
+		if req.Enabled {
+			s.logger.Warn(fmt.Sprintf("Через административное API включена отладка маршрутизации для всех запросов (длительность: %s)", req.Duration))
+		} else {
+			s.logger.Info("Через административное API отключена отладка маршрутизации для всех запросов")
+		}
+		s.writeJSON(w, http.StatusOK, debug.Status())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDebugToken выпускает токен, включающий отладку маршрутизации для запросов с ним
+func (s *Server) handleDebugToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req debugTokenRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.logger.Debug(fmt.Sprintf("Ошибка декодирования тела запроса: %v", err))
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	ttl := defaultDebugTokenTTL
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 || ttl > maxDebugTokenTTL {
+			http.Error(w, fmt.Sprintf("Invalid ttl: must be a positive duration up to %s", maxDebugTokenTTL), http.StatusBadRequest)
+			return
+		}
+	}
+
+	debug := s.provider.RoutingDebug()
+	token, expiresAt, err := debug.Token(ttl)
+	if err != nil {
+		http.Error(w, "Routing debug secret is not configured", http.StatusConflict)
+		return
+	}
+	s.logger.Info(fmt.Sprintf("Выпущен токен отладки маршрутизации до %s", expiresAt.Format(time.RFC3339)))
+
+	s.writeJSON(w, http.StatusCreated, debugTokenResponse{
+		Header:    debug.Status().Header,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+}
@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// readinessResponse ответ эндпоинта /readyz
+type readinessResponse struct {
+	Status          string `json:"status"`
+	Reason          string `json:"reason,omitempty"`
+	BackendsTotal   int    `json:"backendsTotal"`
+	BackendsHealthy int    `json:"backendsHealthy"`
+	ConfigError     string `json:"configError,omitempty"`
+}
+
+// handleHealthz сообщает, что процесс жив (liveness probe)
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, `{"status": "ok"}`)
+}
+
+// handleReadyz сообщает, готов ли прокси принимать трафик (readiness probe):
+// приложение запущено и не останавливается, конфигурация применена и есть хотя бы
+// один здоровый бэкенд
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	response := readinessResponse{Status: "ready"}
+
+	// Ошибка последней перезагрузки не делает прокси неготовым: продолжает работать предыдущая конфигурация
+	if err := s.provider.ConfigError(); err != nil {
+		response.ConfigError = err.Error()
+	}
+
+	lb := s.provider.LoadBalancer()
+	switch {
+	case !s.provider.Ready():
+		response.Status = "not ready"
+		response.Reason = "application is starting or shutting down"
+	case lb == nil:
+		response.Status = "not ready"
+		response.Reason = "configuration is not applied yet"
+	default:
+		response.BackendsTotal = len(lb.GetBackends())
+		response.BackendsHealthy = len(lb.GetAliveBackends())
+		if response.BackendsHealthy == 0 {
+			response.Status = "not ready"
+			response.Reason = "no healthy backends"
+		}
+	}
+
+	status := http.StatusOK
+	if response.Status != "ready" {
+		s.logger.Debug(fmt.Sprintf("Прокси не готов: %s", response.Reason))
+		status = http.StatusServiceUnavailable
+	}
+
+	s.writeJSON(w, status, response)
+}
@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestHealthz(t *testing.T) {
+	s := newTestServer(&fakeProvider{})
+
+	// Проба доступна без аутентификации и не зависит от состояния приложения
+	for _, token := range []string{"", readToken, adminToken} {
+		if status, body := serve(s, http.MethodGet, "/healthz", token, ""); status != http.StatusOK || body != `{"status": "ok"}` {
+			t.Errorf("токен %q: статус %d, тело %s", token, status, body)
+		}
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	url := upstream(t)
+
+	tests := []struct {
+		name     string
+		provider func(t *testing.T) *fakeProvider
+		want     int
+		response readinessResponse
+	}{
+		{
+			name:     "приложение готово",
+			provider: func(t *testing.T) *fakeProvider { return newTestProvider(t, testConfig(t, url, "b1", "b2")) },
+			want:     http.StatusOK,
+			response: readinessResponse{Status: "ready", BackendsTotal: 2, BackendsHealthy: 2},
+		},
+		{
+			name: "приложение запускается или останавливается",
+			provider: func(t *testing.T) *fakeProvider {
+				p := newTestProvider(t, testConfig(t, url, "b1"))
+				p.ready = false
+				return p
+			},
+			want:     http.StatusServiceUnavailable,
+			response: readinessResponse{Status: "not ready", Reason: "application is starting or shutting down"},
+		},
+		{
+			name:     "конфигурация не применена",
+			provider: func(t *testing.T) *fakeProvider { return &fakeProvider{ready: true, configErr: errors.New("broken")} },
+			want:     http.StatusServiceUnavailable,
+			response: readinessResponse{Status: "not ready", Reason: "configuration is not applied yet", ConfigError: "broken"},
+		},
+		{
+			name: "нет здоровых бэкендов",
+			provider: func(t *testing.T) *fakeProvider {
+				p := newTestProvider(t, testConfig(t, url, "b1"))
+				p.lb.GetBackend("b1").Backend.SetAlive(false)
+				return p
+			},
+			want:     http.StatusServiceUnavailable,
+			response: readinessResponse{Status: "not ready", Reason: "no healthy backends", BackendsTotal: 1},
+		},
+		{
+			name: "ошибка перезагрузки при работающей конфигурации",
+			provider: func(t *testing.T) *fakeProvider {
+				p := newTestProvider(t, testConfig(t, url, "b1"))
+				p.configErr = errors.New("broken")
+				return p
+			},
+			want:     http.StatusOK,
+			response: readinessResponse{Status: "ready", BackendsTotal: 1, BackendsHealthy: 1, ConfigError: "broken"},
+		},
+	}
+
+	for _, tt := range tests {
+		s := newTestServer(tt.provider(t))
+		status, body := serve(s, http.MethodGet, "/readyz", "", "")
+		if status != tt.want {
+			t.Errorf("%s: статус %d, ожидался %d", tt.name, status, tt.want)
+		}
+		var response readinessResponse
+		if err := json.Unmarshal([]byte(body), &response); err != nil {
+			t.Fatalf("%s: неверный JSON ответа: %v", tt.name, err)
+		}
+		if response != tt.response {
+			t.Errorf("%s: ответ %+v, ожидался %+v", tt.name, response, tt.response)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package admin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"cloud.ru_test/pkg/logger"
+)
+
+const (
+	// defaultTailLines количество последних строк лога по умолчанию
+	defaultTailLines = 50
+
+	// followPollInterval период проверки новых строк в режиме follow
+	followPollInterval = 500 * time.Millisecond
+)
+
+// handleLogs отдает последние строки лога приложения, а с параметром follow=true
+// продолжает передавать новые строки, пока клиент не отключится
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	lines := defaultTailLines
+	if value := r.URL.Query().Get("lines"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid lines parameter", http.StatusBadRequest)
+			return
+		}
+		lines = n
+	}
+
+	path := logger.LogFilePath
+	if l, ok := s.logger.(interface{ LogFile() string }); ok {
+		path = l.LogFile()
+	}
+	if path == "" {
+		http.Error(w, "Log file is not configured", http.StatusNotFound)
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("Ошибка открытия файла логов: %v", err))
+		http.Error(w, "Log file is not available", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	// Читаем файл целиком, запоминая последние строки
+	tail := make([]string, 0, lines)
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		if lines == 0 {
+			continue
+		}
+		if len(tail) == lines {
+			tail = tail[1:]
+		}
+		tail = append(tail, line)
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	for _, line := range tail {
+		io.WriteString(w, line)
+	}
+
+	if r.URL.Query().Get("follow") != "true" {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(followPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					// Незавершенную строку дочитаем на следующей итерации
+					if len(line) > 0 {
+						file.Seek(-int64(len(line)), io.SeekCurrent)
+						reader.Reset(file)
+					}
+					break
+				}
+				io.WriteString(w, line)
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package admin
+
+import (
+	"net/http"
+
+	"cloud.ru_test/internal/metrics"
+)
+
+// handleMetrics выводит счетчики прокси в текстовом формате Prometheus: GET /admin/metrics
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.Default.WritePrometheus(w)
+}
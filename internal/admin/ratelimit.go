@@ -0,0 +1,209 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/models"
+)
+
+// handleRateLimit обрабатывает CRUD операции для rate limit пользователей
+func (s *Server) handleRateLimit(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug(fmt.Sprintf("Получен запрос к API rate limit: %s %s", r.Method, r.URL.Path))
+
+	// Извлекаем userID из URL
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 3 {
+		s.logger.Debug("Некорректный формат URL для rate limit API")
+		http.Error(w, "Invalid URL format. Use /ratelimit/{userID}", http.StatusBadRequest)
+		return
+	}
+	userID := parts[2]
+	s.logger.Debug(fmt.Sprintf("Обработка rate limit для пользователя: %s", userID))
+
+	limiter := s.provider.RateLimiter()
+	if limiter == nil {
+		s.logger.Debug("Rate limiter еще не инициализирован")
+		http.Error(w, "Rate limiter is not available", http.StatusServiceUnavailable)
+		return
+	}
+	if _, disabled := limiter.(*ratelimit.NoopRateLimiter); disabled {
+		s.logger.Debug("Запрос к API rate limit при отключенном rate limiter")
+		http.Error(w, "Rate limiter is disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.getRateLimit(w, limiter, userID)
+	case http.MethodPost:
+		s.createRateLimit(w, r, limiter, userID)
+	case http.MethodPut:
+		s.updateRateLimit(w, r, limiter, userID)
+	case http.MethodDelete:
+		s.deleteRateLimit(w, limiter, userID)
+	default:
+		s.logger.Debug(fmt.Sprintf("Неподдерживаемый метод %s для rate limit API", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getRateLimit возвращает текущие настройки rate limit для пользователя
+func (s *Server) getRateLimit(w http.ResponseWriter, limiter ratelimit.RateLimiter, userID string) {
+	s.logger.Debug(fmt.Sprintf("Получение настроек rate limit для пользователя %s", userID))
+
+	limits := limiter.GetUserLimits(userID)
+	if limits == nil {
+		s.logger.Debug(fmt.Sprintf("Настройки rate limit не найдены для пользователя %s", userID))
+		http.Error(w, "User limits not found", http.StatusNotFound)
+		return
+	}
+	s.logger.Debug(fmt.Sprintf("Найдены настройки rate limit для %s: rate=%.2f, burst=%d", userID, limits.Rate, limits.Burst))
+
+	response := models.UserRateLimit{
+		Rate:  limits.Rate,
+		Burst: limits.Burst,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		s.logger.Error(fmt.Sprintf("Ошибка кодирования ответа: %v", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	} else {
+		s.logger.Debug(fmt.Sprintf("Успешно отправлены настройки rate limit для %s", userID))
+	}
+}
+
+// createRateLimit создает новые настройки rate limit для пользователя
+func (s *Server) createRateLimit(w http.ResponseWriter, r *http.Request, limiter ratelimit.RateLimiter, userID string) {
+	s.logger.Debug(fmt.Sprintf("Создание новых настроек rate limit для пользователя %s", userID))
+
+	var limits models.UserRateLimit
+	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+		s.logger.Debug(fmt.Sprintf("Ошибка декодирования тела запроса: %v", err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Валидация
+	if limits.Rate <= 0 || limits.Burst <= 0 {
+		s.logger.Debug(fmt.Sprintf("Некорректные значения rate/burst: rate=%.2f, burst=%d", limits.Rate, limits.Burst))
+		http.Error(w, "Rate and burst must be positive", http.StatusBadRequest)
+		return
+	}
+
+	// Проверяем, существуют ли уже лимиты
+	if existing := limiter.GetUserLimits(userID); existing != nil {
+		s.logger.Debug(fmt.Sprintf("Rate limit уже существует для пользователя %s", userID))
+		http.Error(w, "Rate limits already exist for this user", http.StatusConflict)
+		return
+	}
+
+	limiter.SetUserLimits(userID, limits.Rate, limits.Burst)
+	s.logger.Debug(fmt.Sprintf("Успешно созданы настройки rate limit для %s: rate=%.2f, burst=%d", userID, limits.Rate, limits.Burst))
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// updateRateLimit обновляет настройки rate limit для пользователя
+func (s *Server) updateRateLimit(w http.ResponseWriter, r *http.Request, limiter ratelimit.RateLimiter, userID string) {
+	s.logger.Debug(fmt.Sprintf("Обновление настроек rate limit для пользователя %s", userID))
+
+	var limits models.UserRateLimit
+	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+		s.logger.Debug(fmt.Sprintf("Ошибка декодирования тела запроса: %v", err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Валидация
+	if limits.Rate <= 0 || limits.Burst <= 0 {
+		s.logger.Debug(fmt.Sprintf("Некорректные значения rate/burst: rate=%.2f, burst=%d", limits.Rate, limits.Burst))
+		http.Error(w, "Rate and burst must be positive", http.StatusBadRequest)
+		return
+	}
+
+	// Проверяем существование пользователя
+	if existing := limiter.GetUserLimits(userID); existing == nil {
+		s.logger.Debug(fmt.Sprintf("Настройки rate limit не найдены для пользователя %s", userID))
+		http.Error(w, "User limits not found", http.StatusNotFound)
+		return
+	}
+
+	limiter.UpdateUserLimits(userID, func(ul *ratelimit.UserLimits) {
+		ul.Rate = limits.Rate
+		ul.Burst = limits.Burst
+	})
+	s.logger.Debug(fmt.Sprintf("Успешно обновлены настройки rate limit для %s: rate=%.2f, burst=%d", userID, limits.Rate, limits.Burst))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// deleteRateLimit удаляет настройки rate limit для пользователя
+func (s *Server) deleteRateLimit(w http.ResponseWriter, limiter ratelimit.RateLimiter, userID string) {
+	s.logger.Debug(fmt.Sprintf("Удаление настроек rate limit для пользователя %s", userID))
+
+	// Проверяем существование пользователя
+	if existing := limiter.GetUserLimits(userID); existing == nil {
+		s.logger.Debug(fmt.Sprintf("Настройки rate limit не найдены для пользователя %s", userID))
+		http.Error(w, "User limits not found", http.StatusNotFound)
+		return
+	}
+
+	limiter.DeleteUserLimits(userID)
+	s.logger.Debug(fmt.Sprintf("Успешно удалены настройки rate limit для пользователя %s", userID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// maxRateLimitStateSize максимальный размер загружаемого состояния rate limiter'а
+const maxRateLimitStateSize = 64 << 20
+
+// handleRateLimitState выгружает (GET) и загружает (PUT) состояние rate limiter'а:
+// GET /admin/ratelimit/state и PUT /admin/ratelimit/state. Состояние переносится
+// на новый экземпляр при обновлении, чтобы клиенты не получили полный burst заново.
+func (s *Server) handleRateLimitState(w http.ResponseWriter, r *http.Request) {
+	limiter := s.provider.RateLimiter()
+	if limiter == nil {
+		http.Error(w, "Rate limiter is not available", http.StatusServiceUnavailable)
+		return
+	}
+	if _, disabled := limiter.(*ratelimit.NoopRateLimiter); disabled {
+		http.Error(w, "Rate limiter is disabled", http.StatusServiceUnavailable)
+		return
+	}
+	transfer, ok := limiter.(ratelimit.StateTransfer)
+	if !ok {
+		http.Error(w, "Rate limiter does not support state transfer", http.StatusNotImplemented)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		state := transfer.ExportState()
+		s.logger.Info(fmt.Sprintf("Выгружено состояние rate limiter: лимитов %d, корзин %d", len(state.Overrides), len(state.Tokens)))
+		s.writeJSON(w, http.StatusOK, state)
+
+	case http.MethodPut:
+		var state ratelimit.State
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRateLimitStateSize)).Decode(&state); err != nil {
+			s.logger.Debug(fmt.Sprintf("Ошибка декодирования состояния rate limiter: %v", err))
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := transfer.ImportState(state); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.logger.Info(fmt.Sprintf("Загружено состояние rate limiter от %s: лимитов %d, корзин %d",
+			state.Time.Format(time.RFC3339), len(state.Overrides), len(state.Tokens)))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
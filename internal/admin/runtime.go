@@ -0,0 +1,132 @@
+package admin
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"time"
+)
+
+// startTime время запуска процесса
+var startTime = time.Now()
+
+// runtimeResponse состояние процесса: горутины, память, сборка мусора, файловые дескрипторы
+type runtimeResponse struct {
+	GoVersion     string  `json:"goVersion"`
+	PID           int     `json:"pid"`
+	UptimeSeconds float64 `json:"uptimeSeconds"`
+	NumCPU        int     `json:"numCPU"`
+	GOMAXPROCS    int     `json:"gomaxprocs"`
+	Goroutines    int     `json:"goroutines"`
+
+	// Открытые файловые дескрипторы (-1, если платформа не позволяет их посчитать)
+	OpenFDs int `json:"openFDs"`
+
+	Heap     heapStats             `json:"heap"`
+	GC       gcStats               `json:"gc"`
+	Backends []backendRuntimeStats `json:"backends"`
+}
+
+// heapStats память процесса в байтах
+type heapStats struct {
+	Alloc    uint64 `json:"alloc"`
+	InUse    uint64 `json:"inUse"`
+	Idle     uint64 `json:"idle"`
+	Released uint64 `json:"released"`
+	Objects  uint64 `json:"objects"`
+	Sys      uint64 `json:"sys"`
+}
+
+// gcStats статистика сборщика мусора
+type gcStats struct {
+	NumGC        uint32    `json:"numGC"`
+	PauseTotalMs float64   `json:"pauseTotalMs"`
+	LastPauseMs  float64   `json:"lastPauseMs"`
+	LastGC       time.Time `json:"lastGC,omitempty"`
+	NextGCBytes  uint64    `json:"nextGCBytes"`
+	CPUFraction  float64   `json:"cpuFraction"`
+}
+
+// backendRuntimeStats соединения бэкенда
+type backendRuntimeStats struct {
+	ID                string `json:"id"`
+	ActiveConnections int64  `json:"activeConnections"`
+	MaxConnections    int    `json:"maxConnections,omitempty"`
+}
+
+// handleRuntime возвращает состояние процесса: GET /admin/runtime
+func (s *Server) handleRuntime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	response := runtimeResponse{
+		GoVersion:     runtime.Version(),
+		PID:           os.Getpid(),
+		UptimeSeconds: time.Since(startTime).Seconds(),
+		NumCPU:        runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		Goroutines:    runtime.NumGoroutine(),
+		OpenFDs:       openFDs(),
+		Heap: heapStats{
+			Alloc:    mem.HeapAlloc,
+			InUse:    mem.HeapInuse,
+			Idle:     mem.HeapIdle,
+			Released: mem.HeapReleased,
+			Objects:  mem.HeapObjects,
+			Sys:      mem.Sys,
+		},
+		GC: gcStats{
+			NumGC:        mem.NumGC,
+			PauseTotalMs: float64(mem.PauseTotalNs) / float64(time.Millisecond),
+			NextGCBytes:  mem.NextGC,
+			CPUFraction:  mem.GCCPUFraction,
+		},
+		Backends: make([]backendRuntimeStats, 0),
+	}
+	if mem.NumGC > 0 {
+		response.GC.LastPauseMs = float64(mem.PauseNs[(mem.NumGC+255)%256]) / float64(time.Millisecond)
+		response.GC.LastGC = time.Unix(0, int64(mem.LastGC))
+	}
+
+	if lb := s.provider.LoadBalancer(); lb != nil {
+		for _, state := range lb.GetBackends() {
+			response.Backends = append(response.Backends, backendRuntimeStats{
+				ID:                state.Backend.ID(),
+				ActiveConnections: state.Backend.GetLoadStats().ActiveConnections,
+				MaxConnections:    state.Backend.MaxConnections(),
+			})
+		}
+	}
+
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+// openFDs возвращает количество открытых файловых дескрипторов процесса
+func openFDs() int {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		if entries, err := os.ReadDir(dir); err == nil {
+			// Дескриптор самого каталога, открытый ReadDir, не учитывается
+			return len(entries) - 1
+		}
+	}
+	return -1
+}
+
+// registerDiagnostics подключает профилирование net/http/pprof (/debug/pprof/)
+// и переменные expvar (/debug/vars) к административному API
+func (s *Server) registerDiagnostics() {
+	s.mux.HandleFunc("/admin/runtime", s.handleRuntime)
+	s.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	s.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	s.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	s.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	s.mux.Handle("/debug/vars", expvar.Handler())
+}
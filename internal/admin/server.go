@@ -0,0 +1,192 @@
+package admin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/apikeys"
+	"cloud.ru_test/internal/healthcheck"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/internal/transport"
+	"cloud.ru_test/internal/upgrade"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Provider предоставляет административному API доступ к текущим компонентам приложения.
+// Компоненты пересоздаются при реконфигурации, поэтому сервер не хранит их у себя.
+type Provider interface {
+	// RateLimiter возвращает текущий rate limiter
+	RateLimiter() ratelimit.RateLimiter
+
+	// LoadBalancer возвращает текущий балансировщик (nil, пока конфигурация не применена)
+	LoadBalancer() loadbalancer.LoadBalancer
+
+	// Config возвращает действующую конфигурацию (nil, пока конфигурация не применена)
+	Config() *config.Config
+
+	// ConfigError возвращает ошибку последней загрузки конфигурации
+	ConfigError() error
+
+	// UpdateConfig изменяет конфигурацию и сохраняет ее в файл
+	UpdateConfig(updateFn func(*config.Config) error) error
+
+	// ReloadConfig принудительно перечитывает конфигурацию и сообщает, изменилась ли она
+	ReloadConfig() (bool, error)
+
+	// ConfigHistory возвращает историю примененных конфигураций, начиная с самой новой
+	ConfigHistory() []config.ConfigVersion
+
+	// RollbackConfig повторно применяет версию конфигурации из истории
+	RollbackConfig(version int) (*config.ConfigVersion, error)
+
+	// HealthChecker возвращает текущий health check (nil, пока конфигурация не применена)
+	HealthChecker() *healthcheck.Checker
+
+	// APIKeys возвращает ключи API маршрутов (nil, если они не настроены)
+	APIKeys() *apikeys.Manager
+
+	// RoutingDebug возвращает режим отладки маршрутизации
+	RoutingDebug() *transport.RoutingDebug
+
+	// Ready сообщает, запущено ли приложение и не началась ли его остановка
+	Ready() bool
+}
+
+// Server административный HTTP сервер, работающий на отдельном порту
+type Server struct {
+	provider Provider
+	server   *http.Server
+	mux      *http.ServeMux
+	auth     *authenticator
+	tls      *config.AdminTLSConfig
+	logger   logger.Logger
+}
+
+// NewServer создает новый административный сервер
+func NewServer(cfg *config.AdminConfig, provider Provider, appLogger logger.Logger) *Server {
+	s := &Server{
+		provider: provider,
+		mux:      http.NewServeMux(),
+		auth:     newAuthenticator(cfg),
+		tls:      cfg.TLS,
+		logger:   appLogger,
+	}
+
+	s.mux.HandleFunc("/ratelimit/", s.handleRateLimit)
+	s.mux.HandleFunc("/admin/ratelimit/state", s.handleRateLimitState)
+	s.mux.HandleFunc("/admin/config", s.handleConfig)
+	s.mux.HandleFunc("/admin/config/validate", s.handleConfigValidate)
+	s.mux.HandleFunc("/admin/config/history", s.handleConfigHistory)
+	s.mux.HandleFunc("/admin/config/history/", s.handleConfigVersion)
+	s.mux.HandleFunc("/admin/config/rollback", s.handleConfigRollback)
+	s.mux.HandleFunc("/admin/reload", s.handleReload)
+	s.mux.HandleFunc("/admin/backends", s.handleBackends)
+	s.mux.HandleFunc("/admin/backends/", s.handleBackend)
+	s.mux.HandleFunc("/admin/apikeys", s.handleAPIKeys)
+	s.mux.HandleFunc("/admin/apikeys/", s.handleAPIKey)
+	s.mux.HandleFunc("/admin/logs", s.handleLogs)
+	s.mux.HandleFunc("/admin/status", s.handleStatus)
+	s.mux.HandleFunc("/admin/metrics", s.handleMetrics)
+	s.mux.HandleFunc("/admin/debug", s.handleDebug)
+	s.mux.HandleFunc("/admin/debug/token", s.handleDebugToken)
+	s.registerDiagnostics()
+	s.mux.Handle("/admin/dashboard/", dashboardHandler())
+	s.mux.Handle("/admin/dashboard", http.RedirectHandler("/admin/dashboard/", http.StatusMovedPermanently))
+
+	// Пробы доступны без аутентификации, чтобы их мог вызывать kubelet
+	probes := http.NewServeMux()
+	probes.HandleFunc("/healthz", s.handleHealthz)
+	probes.HandleFunc("/readyz", s.handleReadyz)
+	probes.Handle("/", s.withAuth(s.mux))
+
+	s.server = &http.Server{
+		Addr:    cfg.Port,
+		Handler: probes,
+	}
+
+	return s
+}
+
+// Start начинает прослушивание порта и обслуживание запросов в отдельной горутине
+func (s *Server) Start() error {
+	s.logger.Debug(fmt.Sprintf("Запуск административного сервера на %s", s.server.Addr))
+
+	if s.tls != nil {
+		tlsConfig, err := s.buildTLSConfig()
+		if err != nil {
+			return err
+		}
+		s.server.TLSConfig = tlsConfig
+	}
+
+	listener, err := upgrade.Listen(net.ListenConfig{}, "tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on admin port: %w", err)
+	}
+
+	go func() {
+		var err error
+		if s.tls != nil {
+			err = s.server.ServeTLS(listener, s.tls.CertFile, s.tls.KeyFile)
+		} else {
+			err = s.server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			s.logger.Error(fmt.Sprintf("Ошибка административного сервера: %v", err))
+		}
+	}()
+
+	return nil
+}
+
+// Stop останавливает административный сервер
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return s.Shutdown(ctx)
+}
+
+// Shutdown останавливает административный сервер, дожидаясь завершения текущих запросов до окончания ctx
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.logger.Debug("Остановка административного сервера")
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.logger.Error(fmt.Sprintf("Ошибка при остановке административного сервера: %v", err))
+		return err
+	}
+
+	return nil
+}
+
+// buildTLSConfig настраивает TLS и, если задан CA, проверку клиентских сертификатов
+func (s *Server) buildTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if s.tls.ClientCAFile != "" {
+		caPEM, err := os.ReadFile(s.tls.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading admin client CA: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in admin client CA file")
+		}
+
+		// Сертификат не обязателен: клиент может использовать токен или basic auth
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
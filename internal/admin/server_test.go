@@ -0,0 +1,145 @@
+package admin
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/apikeys"
+	"cloud.ru_test/internal/healthcheck"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/internal/transport"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Токены административного API в тестах
+const (
+	readToken  = "viewer-token"
+	adminToken = "admin-token"
+)
+
+// fakeProvider компоненты приложения для тестов административного API.
+// UpdateConfig проверяет измененную конфигурацию так же, как менеджер конфигурации,
+// и запоминает ее вместо записи в файл.
+type fakeProvider struct {
+	lb        loadbalancer.LoadBalancer
+	checker   *healthcheck.Checker
+	configErr error
+	ready     bool
+
+	mu  sync.Mutex
+	cfg *config.Config
+}
+
+func (p *fakeProvider) RateLimiter() ratelimit.RateLimiter      { return ratelimit.NewNoopRateLimiter() }
+func (p *fakeProvider) LoadBalancer() loadbalancer.LoadBalancer { return p.lb }
+func (p *fakeProvider) ConfigError() error                      { return p.configErr }
+func (p *fakeProvider) ReloadConfig() (bool, error)             { return false, nil }
+func (p *fakeProvider) ConfigHistory() []config.ConfigVersion   { return nil }
+func (p *fakeProvider) HealthChecker() *healthcheck.Checker     { return p.checker }
+func (p *fakeProvider) APIKeys() *apikeys.Manager               { return nil }
+func (p *fakeProvider) RoutingDebug() *transport.RoutingDebug   { return transport.NewRoutingDebug() }
+func (p *fakeProvider) Ready() bool                             { return p.ready }
+func (p *fakeProvider) RollbackConfig(int) (*config.ConfigVersion, error) {
+	return nil, fmt.Errorf("history is empty")
+}
+
+func (p *fakeProvider) Config() *config.Config {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cfg
+}
+
+func (p *fakeProvider) UpdateConfig(updateFn func(*config.Config) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	updated := *p.cfg
+	updated.Backends = append([]config.BackendConfig(nil), p.cfg.Backends...)
+	if err := updateFn(&updated); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(&updated)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Parse(data)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	p.cfg = cfg
+	return nil
+}
+
+// testConfig конфигурация с бэкендами по адресу url
+func testConfig(t *testing.T, url string, ids ...string) *config.Config {
+	t.Helper()
+	data := "loadBalancer:\n  method: RoundRobin\nlogger:\n  logLevel: error\n  serviceName: test\nbackends:\n"
+	for _, id := range ids {
+		data += fmt.Sprintf("  - id: %s\n    url: %s\n", id, url)
+	}
+	cfg, err := config.Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// newTestProvider создает провайдер с балансировщиком, в котором бэкенды конфигурации cfg.
+// Бэкенды, оставшиеся в балансировщике, закрываются по завершении теста.
+func newTestProvider(t *testing.T, cfg *config.Config) *fakeProvider {
+	t.Helper()
+	lb, err := loadbalancer.New(cfg.LoadBalancer, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, backendCfg := range cfg.Backends {
+		lb.AddBackend(backend.NewFromConfig(backendCfg))
+	}
+	t.Cleanup(func() {
+		for _, state := range lb.GetBackends() {
+			state.Backend.Close()
+		}
+	})
+	return &fakeProvider{lb: lb, cfg: cfg, ready: true}
+}
+
+// newTestServer создает административный сервер с токенами ролей read и admin
+func newTestServer(provider Provider) *Server {
+	return NewServer(&config.AdminConfig{
+		Port: "127.0.0.1:0",
+		Auth: &config.AdminAuthConfig{Tokens: []config.AdminTokenConfig{
+			{Token: readToken, Role: "read"},
+			{Token: adminToken, Role: "admin"},
+		}},
+	}, provider, logger.NewNop())
+}
+
+// serve выполняет запрос к серверу с токеном token (без токена, если он пустой)
+func serve(s *Server, method, target, token, body string) (int, string) {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(w, r)
+	data, _ := io.ReadAll(w.Result().Body)
+	return w.Code, string(data)
+}
+
+// upstream сервер бэкенда, отвечающий 200 на любые запросы
+func upstream(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
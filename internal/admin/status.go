@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// statusResponse сводное состояние прокси для дашборда
+type statusResponse struct {
+	Method      string            `json:"method"`
+	Backends    []backendInfo     `json:"backends"`
+	RateLimiter rateLimiterStatus `json:"rateLimiter"`
+	ConfigError string            `json:"configError,omitempty"`
+}
+
+// rateLimiterStatus состояние rate limiter для дашборда
+type rateLimiterStatus struct {
+	Enabled       bool                       `json:"enabled"`
+	Type          string                     `json:"type,omitempty"`
+	Rate          float64                    `json:"rate"`
+	Burst         int                        `json:"burst"`
+	UserOverrides map[string]userLimitStatus `json:"userOverrides"`
+}
+
+// userLimitStatus пользовательские лимиты в ответе
+type userLimitStatus struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// handleStatus возвращает сводное состояние прокси
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := statusResponse{
+		Backends: make([]backendInfo, 0),
+		RateLimiter: rateLimiterStatus{
+			UserOverrides: make(map[string]userLimitStatus),
+		},
+	}
+
+	if err := s.provider.ConfigError(); err != nil {
+		response.ConfigError = err.Error()
+	}
+
+	if cfg := s.provider.Config(); cfg != nil {
+		response.Method = cfg.LoadBalancer.Method
+		if rl := cfg.RateLimiter; rl != nil {
+			response.RateLimiter.Enabled = rl.Enabled
+			response.RateLimiter.Type = rl.Type
+			if rl.TokenBucket != nil {
+				response.RateLimiter.Rate = rl.TokenBucket.Rate
+				response.RateLimiter.Burst = rl.TokenBucket.Burst
+			}
+		}
+	}
+
+	if lb := s.provider.LoadBalancer(); lb != nil {
+		for _, state := range lb.GetBackends() {
+			response.Backends = append(response.Backends, newBackendInfo(state.Backend))
+		}
+	}
+
+	if limiter := s.provider.RateLimiter(); limiter != nil {
+		for userID, limits := range limiter.ListUserLimits() {
+			response.RateLimiter.UserOverrides[userID] = userLimitStatus{Rate: limits.Rate, Burst: limits.Burst}
+		}
+	}
+
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+// dashboardHandler отдает встроенный дашборд
+func dashboardHandler() http.Handler {
+	files, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		// Каталог встроен при сборке, ошибка возможна только при опечатке в пути
+		panic(err)
+	}
+	return http.StripPrefix("/admin/dashboard/", http.FileServer(http.FS(files)))
+}
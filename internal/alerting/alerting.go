@@ -0,0 +1,363 @@
+// Package alerting проверяет правила оповещений (недоступность бэкендов, доля ошибок,
+// отказы rate limiter'а) и отправляет оповещения о срабатывании и восстановлении на webhook'и.
+// Небольшим установкам это заменяет отдельную систему мониторинга.
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Параметры проверки правил по умолчанию
+const (
+	defaultInterval    = 15 * time.Second
+	defaultWindow      = time.Minute
+	defaultMinRequests = 10
+	notifyTimeout      = 10 * time.Second
+
+	// Окно подсчета отказов rate limiter'а
+	rateLimitedWindow = time.Minute
+)
+
+// Счетчики прокси, по которым проверяются правила
+const (
+	requestsMetric    = "lb_requests_total"
+	rateLimitedMetric = "lb_ratelimit_rejected_total"
+)
+
+// Состояния оповещения
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// notifications счетчик отправленных оповещений по типам webhook'ов и результатам
+var notifications = metrics.Default.Counter("lb_alert_notifications_total", "Alert notifications sent by webhook type and result", "type", "result")
+
+// Alert оповещение о срабатывании правила или о восстановлении
+type Alert struct {
+	Rule      string     `json:"rule"`
+	Type      string     `json:"type"`
+	Status    string     `json:"status"`
+	Backend   string     `json:"backend,omitempty"`
+	Value     float64    `json:"value"`
+	Threshold float64    `json:"threshold,omitempty"`
+	Message   string     `json:"message"`
+	StartsAt  time.Time  `json:"startsAt"`
+	EndsAt    *time.Time `json:"endsAt,omitempty"`
+
+	// Идентификатор оповещения: правило и, для backendDown, бэкенд
+	Key string `json:"key"`
+}
+
+// snapshot значения счетчиков прокси в момент проверки
+type snapshot struct {
+	at          time.Time
+	requests    map[string]int64
+	errors      map[string]int64
+	rateLimited int64
+}
+
+// Alerter периодически проверяет правила и оповещает webhook'и об изменении их состояния.
+// Оповещение отправляется один раз при срабатывании правила и один раз при восстановлении.
+type Alerter struct {
+	interval  time.Duration
+	rules     []config.AlertRuleConfig
+	notifiers []notifier
+	registry  *metrics.Registry
+	logger    logger.Logger
+
+	mu sync.Mutex
+	lb loadbalancer.LoadBalancer
+
+	// Состояние проверок; используется только горутиной проверки
+	downSince map[string]time.Time
+	history   []snapshot
+	firing    map[string]*Alert
+
+	started atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// New создает проверку правил cfg по счетчикам реестра registry
+func New(cfg *config.AlertingConfig, registry *metrics.Registry, appLogger logger.Logger) (*Alerter, error) {
+	a := &Alerter{
+		interval:  cfg.Interval,
+		rules:     cfg.Rules,
+		registry:  registry,
+		logger:    appLogger,
+		downSince: make(map[string]time.Time),
+		firing:    make(map[string]*Alert),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if a.interval == 0 {
+		a.interval = defaultInterval
+	}
+	for _, w := range cfg.Webhooks {
+		n, err := newNotifier(w)
+		if err != nil {
+			return nil, err
+		}
+		a.notifiers = append(a.notifiers, n)
+	}
+	return a, nil
+}
+
+// SetLoadBalancer задает балансировщик, бэкенды которого проверяют правила backendDown
+func (a *Alerter) SetLoadBalancer(lb loadbalancer.LoadBalancer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lb = lb
+}
+
+// Start запускает периодическую проверку правил
+func (a *Alerter) Start() {
+	if a.started.CompareAndSwap(false, true) {
+		go a.run()
+	}
+}
+
+// Stop останавливает проверку. Оповещения о восстановлении при остановке не отправляются.
+func (a *Alerter) Stop() {
+	a.once.Do(func() {
+		close(a.stop)
+		if a.started.CompareAndSwap(false, true) {
+			close(a.done)
+		}
+	})
+	<-a.done
+}
+
+func (a *Alerter) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	a.evaluate(time.Now())
+	for {
+		select {
+		case <-ticker.C:
+			a.evaluate(time.Now())
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// evaluate проверяет правила и отправляет оповещения об изменившихся состояниях
+func (a *Alerter) evaluate(now time.Time) {
+	a.record(now)
+	a.trackBackends(now)
+
+	active := make(map[string]*Alert)
+	for _, rule := range a.rules {
+		for _, alert := range a.check(rule, now) {
+			active[alert.Key] = alert
+		}
+	}
+
+	for key, alert := range active {
+		if _, ok := a.firing[key]; ok {
+			continue
+		}
+		alert.Status = StatusFiring
+		alert.StartsAt = now
+		a.firing[key] = alert
+		a.logger.Warn(fmt.Sprintf("Сработало правило оповещения %s: %s", alert.Rule, alert.Message))
+		a.notify(alert)
+	}
+	for key, alert := range a.firing {
+		if _, ok := active[key]; ok {
+			continue
+		}
+		delete(a.firing, key)
+		resolved := *alert
+		resolved.Status = StatusResolved
+		resolved.EndsAt = &now
+		resolved.Message = "Resolved: " + alert.Message
+		a.logger.Info(fmt.Sprintf("Правило оповещения %s больше не срабатывает: %s", alert.Rule, alert.Message))
+		a.notify(&resolved)
+	}
+}
+
+// record сохраняет значения счетчиков, отбрасывая те, что старше окон всех правил
+func (a *Alerter) record(now time.Time) {
+	s := snapshot{at: now, requests: make(map[string]int64), errors: make(map[string]int64)}
+	for _, sample := range a.registry.Samples() {
+		switch sample.Name {
+		case requestsMetric:
+			var backendID, code string
+			for _, l := range sample.Labels {
+				switch l.Name {
+				case "backend":
+					backendID = l.Value
+				case "code":
+					code = l.Value
+				}
+			}
+			s.requests[backendID] += sample.Value
+			if code == "5xx" {
+				s.errors[backendID] += sample.Value
+			}
+		case rateLimitedMetric:
+			s.rateLimited += sample.Value
+		}
+	}
+	a.history = append(a.history, s)
+
+	keep := rateLimitedWindow
+	for _, rule := range a.rules {
+		if window := ruleWindow(rule); window > keep {
+			keep = window
+		}
+	}
+	keep += a.interval
+	for len(a.history) > 1 && now.Sub(a.history[0].at) > keep {
+		a.history = a.history[1:]
+	}
+}
+
+// base возвращает самый поздний снимок не позже now-window или самый ранний из сохраненных
+func (a *Alerter) base(now time.Time, window time.Duration) snapshot {
+	base := a.history[0]
+	for _, s := range a.history {
+		if now.Sub(s.at) < window {
+			break
+		}
+		base = s
+	}
+	return base
+}
+
+// trackBackends запоминает, с какого момента недоступен каждый бэкенд. Бэкенды
+// на обслуживании не считаются недоступными.
+func (a *Alerter) trackBackends(now time.Time) {
+	a.mu.Lock()
+	lb := a.lb
+	a.mu.Unlock()
+
+	seen := make(map[string]bool)
+	if lb != nil {
+		for _, state := range lb.GetBackends() {
+			b := state.Backend
+			if b.IsAlive() || b.InMaintenance() {
+				continue
+			}
+			seen[b.ID()] = true
+			if _, ok := a.downSince[b.ID()]; !ok {
+				a.downSince[b.ID()] = now
+			}
+		}
+	}
+	for id := range a.downSince {
+		if !seen[id] {
+			delete(a.downSince, id)
+		}
+	}
+}
+
+// check возвращает оповещения, условия которых выполняются для правила
+func (a *Alerter) check(rule config.AlertRuleConfig, now time.Time) []*Alert {
+	switch rule.Type {
+	case "backendDown":
+		var alerts []*Alert
+		for id, since := range a.downSince {
+			down := now.Sub(since)
+			if (rule.Backend != "" && id != rule.Backend) || down < rule.For {
+				continue
+			}
+			alerts = append(alerts, &Alert{
+				Rule: rule.Name, Type: rule.Type, Backend: id, Key: rule.Name + "/" + id,
+				Value:   down.Seconds(),
+				Message: fmt.Sprintf("backend %s is down for %s", id, down.Truncate(time.Second)),
+			})
+		}
+		return alerts
+
+	case "errorRate":
+		current := a.history[len(a.history)-1]
+		base := a.base(now, ruleWindow(rule))
+		var requests, errors int64
+		for id := range current.requests {
+			if rule.Backend == "" || id == rule.Backend {
+				requests += current.requests[id] - base.requests[id]
+				errors += current.errors[id] - base.errors[id]
+			}
+		}
+		minRequests := rule.MinRequests
+		if minRequests == 0 {
+			minRequests = defaultMinRequests
+		}
+		if requests < minRequests {
+			return nil
+		}
+		rate := float64(errors) / float64(requests) * 100
+		if rate <= rule.Threshold {
+			return nil
+		}
+		scope := "all backends"
+		if rule.Backend != "" {
+			scope = "backend " + rule.Backend
+		}
+		return []*Alert{{
+			Rule: rule.Name, Type: rule.Type, Backend: rule.Backend, Key: rule.Name,
+			Value: rate, Threshold: rule.Threshold,
+			Message: fmt.Sprintf("error rate of %s is %.1f%% (%d of %d requests), threshold %.1f%%", scope, rate, errors, requests, rule.Threshold),
+		}}
+
+	case "rateLimited":
+		current := a.history[len(a.history)-1]
+		base := a.base(now, rateLimitedWindow)
+		elapsed := current.at.Sub(base.at)
+		if elapsed <= 0 {
+			return nil
+		}
+		perMinute := float64(current.rateLimited-base.rateLimited) / elapsed.Minutes()
+		if perMinute <= rule.Threshold {
+			return nil
+		}
+		return []*Alert{{
+			Rule: rule.Name, Type: rule.Type, Key: rule.Name,
+			Value: perMinute, Threshold: rule.Threshold,
+			Message: fmt.Sprintf("rate limiter rejects %.0f requests per minute, threshold %.0f", perMinute, rule.Threshold),
+		}}
+	}
+	return nil
+}
+
+// ruleWindow возвращает окно подсчета правила errorRate
+func ruleWindow(rule config.AlertRuleConfig) time.Duration {
+	if rule.Type != "errorRate" {
+		return 0
+	}
+	if rule.Window == 0 {
+		return defaultWindow
+	}
+	return rule.Window
+}
+
+// notify отправляет оповещение всем webhook'ам; ошибка отправки не повторяется
+func (a *Alerter) notify(alert *Alert) {
+	for _, n := range a.notifiers {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		err := n.notify(ctx, alert)
+		cancel()
+		if err != nil {
+			notifications.Inc(n.kind(), "error")
+			a.logger.Error(fmt.Sprintf("Не удалось отправить оповещение %s в %s: %v", alert.Rule, n.kind(), err))
+			continue
+		}
+		notifications.Inc(n.kind(), "success")
+	}
+}
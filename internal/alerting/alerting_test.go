@@ -0,0 +1,168 @@
+package alerting
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+// receiver сохраняет тела полученных оповещений
+func receiver(t *testing.T) (*httptest.Server, chan map[string]interface{}) {
+	received := make(chan map[string]interface{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("неверный JSON оповещения: %v", err)
+		}
+		received <- body
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func expect(t *testing.T, received chan map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	select {
+	case body := <-received:
+		return body
+	default:
+		t.Fatal("оповещение не отправлено")
+		return nil
+	}
+}
+
+func expectNone(t *testing.T, received chan map[string]interface{}) {
+	t.Helper()
+	select {
+	case body := <-received:
+		t.Fatalf("неожиданное оповещение: %v", body)
+	default:
+	}
+}
+
+func TestAlerter_ErrorRate(t *testing.T) {
+	server, received := receiver(t)
+	registry := metrics.NewRegistry()
+	requests := registry.Counter(requestsMetric, "Requests", "backend", "code")
+
+	a, err := New(&config.AlertingConfig{
+		Rules:    []config.AlertRuleConfig{{Name: "errors", Type: "errorRate", Threshold: 20, Window: time.Minute}},
+		Webhooks: []config.AlertWebhookConfig{{Type: "generic", URL: server.URL}},
+	}, registry, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	requests.Add(100, "b1", "5xx")
+	a.evaluate(start)
+	expectNone(t, received)
+
+	// Ошибки, накопленные до начала окна, не учитываются
+	requests.Add(15, "b1", "2xx")
+	requests.Add(5, "b1", "5xx")
+	a.evaluate(start.Add(15 * time.Second))
+	alert := expect(t, received)
+	if alert["status"] != StatusFiring || alert["rule"] != "errors" || alert["value"] != 25.0 {
+		t.Errorf("неверное оповещение: %v", alert)
+	}
+
+	// Повторная проверка с тем же состоянием не отправляет оповещение
+	a.evaluate(start.Add(30 * time.Second))
+	expectNone(t, received)
+
+	// После окна без ошибок правило восстанавливается
+	requests.Add(50, "b1", "2xx")
+	a.evaluate(start.Add(2 * time.Minute))
+	if alert := expect(t, received); alert["status"] != StatusResolved || alert["endsAt"] == nil {
+		t.Errorf("ожидалось оповещение о восстановлении: %v", alert)
+	}
+}
+
+func TestAlerter_BackendDown(t *testing.T) {
+	server, received := receiver(t)
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backend.NewBackendWithOptions("b1", "http://127.0.0.1:1", 1, backend.Options{})
+	defer b.Close()
+	lb.AddBackend(b)
+
+	a, err := New(&config.AlertingConfig{
+		Rules:    []config.AlertRuleConfig{{Name: "down", Type: "backendDown", For: 30 * time.Second}},
+		Webhooks: []config.AlertWebhookConfig{{Type: "pagerduty", URL: server.URL, RoutingKey: "key"}},
+	}, metrics.NewRegistry(), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetLoadBalancer(lb)
+
+	start := time.Now()
+	b.SetAlive(false)
+	a.evaluate(start)
+	a.evaluate(start.Add(20 * time.Second))
+	expectNone(t, received)
+
+	a.evaluate(start.Add(31 * time.Second))
+	event := expect(t, received)
+	if event["event_action"] != "trigger" || event["routing_key"] != "key" || event["dedup_key"] != "down/b1" {
+		t.Errorf("неверное событие PagerDuty: %v", event)
+	}
+
+	b.SetAlive(true)
+	a.evaluate(start.Add(45 * time.Second))
+	if event := expect(t, received); event["event_action"] != "resolve" || event["dedup_key"] != "down/b1" {
+		t.Errorf("ожидалось событие resolve: %v", event)
+	}
+}
+
+func TestAlerter_RateLimited(t *testing.T) {
+	server, received := receiver(t)
+	registry := metrics.NewRegistry()
+	rejected := registry.Counter(rateLimitedMetric, "Rejected")
+
+	a, err := New(&config.AlertingConfig{
+		Rules:    []config.AlertRuleConfig{{Name: "limited", Type: "rateLimited", Threshold: 100}},
+		Webhooks: []config.AlertWebhookConfig{{Type: "slack", URL: server.URL}},
+	}, registry, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	a.evaluate(start)
+	rejected.Add(60)
+	a.evaluate(start.Add(30 * time.Second))
+	if msg := expect(t, received)["text"]; msg == nil {
+		t.Error("сообщение Slack должно содержать text")
+	}
+}
+
+func TestAlerter_StopWithoutStart(t *testing.T) {
+	a, err := New(&config.AlertingConfig{
+		Rules: []config.AlertRuleConfig{{Name: "limited", Type: "rateLimited", Threshold: 100}},
+	}, metrics.NewRegistry(), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		a.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("остановка незапущенной проверки не должна блокироваться")
+	}
+}
@@ -0,0 +1,102 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"cloud.ru_test/config"
+)
+
+// defaultPagerDutyURL адрес Events API v2 PagerDuty
+const defaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// notifier отправляет оповещение получателю
+type notifier interface {
+	notify(ctx context.Context, alert *Alert) error
+	kind() string
+}
+
+// webhook отправляет оповещение POST запросом в формате получателя
+type webhook struct {
+	cfg    config.AlertWebhookConfig
+	source string
+	client *http.Client
+}
+
+func newNotifier(cfg config.AlertWebhookConfig) (notifier, error) {
+	switch cfg.Type {
+	case "generic", "slack":
+	case "pagerduty":
+		if cfg.URL == "" {
+			cfg.URL = defaultPagerDutyURL
+		}
+	default:
+		return nil, fmt.Errorf("alerting: unsupported webhook type %q", cfg.Type)
+	}
+	source, _ := os.Hostname()
+	return &webhook{cfg: cfg, source: source, client: &http.Client{}}, nil
+}
+
+func (w *webhook) kind() string {
+	return w.cfg.Type
+}
+
+func (w *webhook) notify(ctx context.Context, alert *Alert) error {
+	body, err := json.Marshal(w.payload(alert))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// payload возвращает тело запроса в формате получателя
+func (w *webhook) payload(alert *Alert) interface{} {
+	switch w.cfg.Type {
+	case "slack":
+		icon := ":red_circle:"
+		if alert.Status == StatusResolved {
+			icon = ":large_green_circle:"
+		}
+		return map[string]string{"text": fmt.Sprintf("%s [%s] %s: %s", icon, alert.Status, alert.Rule, alert.Message)}
+
+	case "pagerduty":
+		action := "trigger"
+		if alert.Status == StatusResolved {
+			action = "resolve"
+		}
+		return map[string]interface{}{
+			"routing_key":  w.cfg.RoutingKey,
+			"event_action": action,
+			"dedup_key":    alert.Key,
+			"payload": map[string]interface{}{
+				"summary":        alert.Rule + ": " + alert.Message,
+				"source":         w.source,
+				"severity":       "critical",
+				"custom_details": alert,
+			},
+		}
+	}
+	return alert
+}
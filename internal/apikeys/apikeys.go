@@ -0,0 +1,430 @@
+// Package apikeys управляет ключами API клиентов: ключами из конфигурации и ключами,
+// которые выпускаются через административное API и хранятся в файле, базе SQLite или Redis.
+// Хранятся только SHA-256 ключей; проверка ключа выполняется по индексу в памяти,
+// который периодически перечитывается из хранилища.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Параметры по умолчанию
+const (
+	defaultRefreshInterval = 10 * time.Second
+
+	// Префикс выпускаемых ключей: по нему ключ легко найти в логах и репозиториях
+	secretPrefix = "lbk_"
+
+	// Число случайных байт выпускаемого ключа
+	secretBytes = 24
+
+	// Число первых символов ключа, которые сохраняются для его опознания
+	visiblePrefix = 8
+
+	// Время на операцию с хранилищем при перечитывании
+	storeTimeout = 5 * time.Second
+)
+
+// Ошибки проверки и изменения ключей
+var (
+	ErrUnknownKey = errors.New("unknown API key")
+	ErrDisabled   = errors.New("API key is disabled")
+	ErrExpired    = errors.New("API key has expired")
+	ErrNotFound   = errors.New("API key not found")
+	ErrReadOnly   = errors.New("API key is defined in the configuration")
+	ErrNoStore    = errors.New("API key store is not configured")
+)
+
+// Метрики ключей API
+var (
+	keysGauge   = metrics.Default.Gauge("lb_apikeys", "API keys by source", "source")
+	storeErrors = metrics.Default.Counter("lb_apikey_store_errors_total", "Failed API key store operations", "operation")
+)
+
+// RateLimit ограничение частоты запросов по ключу
+type RateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Key ключ API. Значение ключа не хранится: по нему вычисляется Hash.
+type Key struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// SHA-256 ключа в hex
+	Hash string `json:"hash"`
+
+	// Первые символы ключа для его опознания
+	Prefix string `json:"prefix,omitempty"`
+
+	// Имена доступных маршрутов; пустой список — все маршруты
+	Routes []string `json:"routes,omitempty"`
+
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Disabled  bool       `json:"disabled,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+
+	// Ключ задан в конфигурации и не изменяется через API
+	Static bool `json:"-"`
+}
+
+// AllowsRoute проверяет, что ключ дает доступ к маршруту
+func (k *Key) AllowsRoute(route string) bool {
+	if len(k.Routes) == 0 {
+		return true
+	}
+	for _, r := range k.Routes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+// HashKey возвращает SHA-256 ключа в hex
+func HashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// limiter ограничитель частоты запросов ключа и лимит, с которым он создан
+type limiter struct {
+	limit   RateLimit
+	limiter *rate.Limiter
+}
+
+// Manager проверяет ключи API и изменяет ключи хранилища
+type Manager struct {
+	store   Store
+	static  []*Key
+	refresh time.Duration
+	logger  logger.Logger
+
+	// Индексы ключей по хешу и ID; заменяются целиком при перечитывании хранилища
+	mu     sync.RWMutex
+	byHash map[string]*Key
+	byID   map[string]*Key
+
+	limitersMu sync.Mutex
+	limiters   map[string]*limiter
+
+	started atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// New создает менеджер ключей и загружает ключи из хранилища
+func New(cfg *config.APIKeyAuthConfig, appLogger logger.Logger) (*Manager, error) {
+	m := &Manager{
+		refresh:  defaultRefreshInterval,
+		logger:   appLogger,
+		limiters: make(map[string]*limiter),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, k := range cfg.Keys {
+		key := &Key{ID: k.Name, Name: k.Name, Hash: k.Hash, Routes: k.Routes, Static: true}
+		if k.Key != "" {
+			key.Hash = HashKey(k.Key)
+		}
+		if k.RateLimit != nil {
+			key.RateLimit = &RateLimit{Rate: k.RateLimit.Rate, Burst: k.RateLimit.Burst}
+		}
+		m.static = append(m.static, key)
+	}
+
+	if cfg.Store != nil {
+		store, err := NewStore(cfg.Store)
+		if err != nil {
+			return nil, err
+		}
+		m.store = store
+		if cfg.Store.RefreshInterval > 0 {
+			m.refresh = cfg.Store.RefreshInterval
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := m.Reload(ctx); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Start запускает перечитывание хранилища
+func (m *Manager) Start() {
+	if m.store != nil && m.started.CompareAndSwap(false, true) {
+		go m.run()
+	}
+}
+
+// Stop останавливает перечитывание хранилища и закрывает его
+func (m *Manager) Stop() {
+	m.once.Do(func() {
+		close(m.stop)
+		if m.started.Load() {
+			<-m.done
+		}
+		m.Close()
+	})
+}
+
+// Close закрывает хранилище
+func (m *Manager) Close() {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.Close(); err != nil {
+		m.logger.Warn(fmt.Sprintf("Ошибка при закрытии хранилища ключей API: %v", err))
+	}
+}
+
+func (m *Manager) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+			if err := m.Reload(ctx); err != nil {
+				m.logger.Warn(fmt.Sprintf("Не удалось перечитать ключи API, используются прежние: %v", err))
+			}
+			cancel()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Reload перечитывает ключи хранилища
+func (m *Manager) Reload(ctx context.Context) error {
+	var stored []Key
+	if m.store != nil {
+		var err error
+		if stored, err = m.store.List(ctx); err != nil {
+			storeErrors.Inc("list")
+			return fmt.Errorf("failed to list API keys: %w", err)
+		}
+	}
+
+	byHash := make(map[string]*Key, len(m.static)+len(stored))
+	byID := make(map[string]*Key, len(m.static)+len(stored))
+	for i := range stored {
+		k := &stored[i]
+		byHash[k.Hash] = k
+		byID[k.ID] = k
+	}
+	// Ключи конфигурации важнее ключей хранилища с тем же значением
+	for _, k := range m.static {
+		byHash[k.Hash] = k
+		byID[k.ID] = k
+	}
+
+	m.mu.Lock()
+	m.byHash = byHash
+	m.byID = byID
+	m.mu.Unlock()
+
+	// Ограничители удаленных ключей больше не нужны
+	m.limitersMu.Lock()
+	for id := range m.limiters {
+		if _, ok := byID[id]; !ok {
+			delete(m.limiters, id)
+		}
+	}
+	m.limitersMu.Unlock()
+
+	keysGauge.Set(int64(len(m.static)), "config")
+	keysGauge.Set(int64(len(stored)), "store")
+	return nil
+}
+
+// Authenticate находит ключ по его значению
+func (m *Manager) Authenticate(secret string) (*Key, error) {
+	m.mu.RLock()
+	k, ok := m.byHash[HashKey(secret)]
+	m.mu.RUnlock()
+
+	switch {
+	case !ok:
+		return nil, ErrUnknownKey
+	case k.Disabled:
+		return k, ErrDisabled
+	case k.ExpiresAt != nil && !time.Now().Before(*k.ExpiresAt):
+		return k, ErrExpired
+	}
+	return k, nil
+}
+
+// Allow проверяет ограничение частоты запросов ключа
+func (m *Manager) Allow(k *Key) bool {
+	if k.RateLimit == nil {
+		return true
+	}
+
+	m.limitersMu.Lock()
+	l, ok := m.limiters[k.ID]
+	if !ok || l.limit != *k.RateLimit {
+		l = &limiter{limit: *k.RateLimit, limiter: rate.NewLimiter(rate.Limit(k.RateLimit.Rate), k.RateLimit.Burst)}
+		m.limiters[k.ID] = l
+	}
+	m.limitersMu.Unlock()
+
+	return l.limiter.Allow()
+}
+
+// List возвращает все ключи, упорядоченные по времени создания
+func (m *Manager) List() []Key {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]Key, 0, len(m.byID))
+	for _, k := range m.byID {
+		keys = append(keys, *k)
+	}
+	sortKeys(keys)
+	return keys
+}
+
+// Get возвращает ключ по ID
+func (m *Manager) Get(id string) (Key, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	k, ok := m.byID[id]
+	if !ok {
+		return Key{}, false
+	}
+	return *k, true
+}
+
+// Create выпускает ключ с параметрами k и возвращает его вместе со значением ключа.
+// Значение возвращается только при выпуске: хранилище содержит лишь его хеш.
+func (m *Manager) Create(ctx context.Context, k Key) (Key, string, error) {
+	if m.store == nil {
+		return Key{}, "", ErrNoStore
+	}
+
+	id, err := randomString(8, hex.EncodeToString)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret, err := randomString(secretBytes, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret = secretPrefix + secret
+
+	k.ID = id
+	k.Hash = HashKey(secret)
+	k.Prefix = secret[:visiblePrefix]
+	k.CreatedAt = time.Now().UTC()
+	if err := m.put(ctx, k); err != nil {
+		return Key{}, "", err
+	}
+	return k, secret, nil
+}
+
+// Rotate заменяет значение ключа, сохраняя остальные параметры, и возвращает новое значение
+func (m *Manager) Rotate(ctx context.Context, id string) (Key, string, error) {
+	k, err := m.stored(id)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret, err := randomString(secretBytes, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret = secretPrefix + secret
+
+	k.Hash = HashKey(secret)
+	k.Prefix = secret[:visiblePrefix]
+	if err := m.put(ctx, k); err != nil {
+		return Key{}, "", err
+	}
+	return k, secret, nil
+}
+
+// Update изменяет параметры ключа хранилища
+func (m *Manager) Update(ctx context.Context, id string, updateFn func(*Key)) (Key, error) {
+	k, err := m.stored(id)
+	if err != nil {
+		return Key{}, err
+	}
+	hash, prefix, createdAt := k.Hash, k.Prefix, k.CreatedAt
+	updateFn(&k)
+
+	// ID, значение и время выпуска не изменяются
+	k.ID, k.Hash, k.Prefix, k.CreatedAt = id, hash, prefix, createdAt
+	if err := m.put(ctx, k); err != nil {
+		return Key{}, err
+	}
+	return k, nil
+}
+
+// Delete удаляет ключ из хранилища
+func (m *Manager) Delete(ctx context.Context, id string) error {
+	if _, err := m.stored(id); err != nil {
+		return err
+	}
+	if err := m.store.Delete(ctx, id); err != nil {
+		storeErrors.Inc("delete")
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	return m.Reload(ctx)
+}
+
+// stored возвращает ключ хранилища, который можно изменить через API
+func (m *Manager) stored(id string) (Key, error) {
+	if m.store == nil {
+		return Key{}, ErrNoStore
+	}
+	k, ok := m.Get(id)
+	switch {
+	case !ok:
+		return Key{}, ErrNotFound
+	case k.Static:
+		return Key{}, ErrReadOnly
+	}
+	return k, nil
+}
+
+// put сохраняет ключ и перечитывает хранилище, чтобы изменение сразу начало действовать
+func (m *Manager) put(ctx context.Context, k Key) error {
+	if err := m.store.Put(ctx, k); err != nil {
+		storeErrors.Inc("put")
+		return fmt.Errorf("failed to save API key: %w", err)
+	}
+	return m.Reload(ctx)
+}
+
+// randomString возвращает n случайных байт в кодировке encode
+func randomString(n int, encode func([]byte) string) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return encode(b), nil
+}
@@ -0,0 +1,288 @@
+package apikeys
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestManager_FileStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	cfg := &config.APIKeyAuthConfig{
+		Keys:  []config.APIKeyConfig{{Name: "ops", Key: "static-key-0123456"}},
+		Store: &config.APIKeyStoreConfig{Type: "file", Path: path},
+	}
+	m, err := New(cfg, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	k, secret, err := m.Create(ctx, Key{Name: "billing", Routes: []string{"billing"}})
+	if err != nil {
+		t.Fatalf("ключ должен выпускаться: %v", err)
+	}
+	if !strings.HasPrefix(secret, secretPrefix) || k.Prefix != secret[:visiblePrefix] || k.Hash != HashKey(secret) {
+		t.Errorf("неожиданные значение и хеш ключа: %q, %+v", secret, k)
+	}
+	if got, err := m.Authenticate(secret); err != nil || got.Name != "billing" {
+		t.Errorf("выпущенный ключ должен проходить проверку: %v", err)
+	}
+	if _, err := m.Authenticate("static-key-0123456"); err != nil {
+		t.Errorf("ключ конфигурации должен проходить проверку: %v", err)
+	}
+	if _, err := m.Authenticate("unknown"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("ожидалась ошибка ErrUnknownKey, получена %v", err)
+	}
+
+	// Другой экземпляр с тем же файлом видит выпущенный ключ
+	other, err := New(&config.APIKeyAuthConfig{Store: cfg.Store}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Stop()
+	if _, err := other.Authenticate(secret); err != nil {
+		t.Errorf("ключ должен читаться из файла: %v", err)
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	if _, err := m.Update(ctx, k.ID, func(k *Key) { k.ExpiresAt = &expired; k.Hash = "changed" }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Authenticate(secret); !errors.Is(err, ErrExpired) {
+		t.Errorf("ожидалась ошибка ErrExpired, получена %v", err)
+	}
+	if _, err := m.Update(ctx, k.ID, func(k *Key) { k.ExpiresAt = nil; k.Disabled = true }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Authenticate(secret); !errors.Is(err, ErrDisabled) {
+		t.Errorf("ожидалась ошибка ErrDisabled, получена %v", err)
+	}
+
+	rotated, newSecret, err := m.Rotate(ctx, k.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Authenticate(secret); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("прежнее значение ключа не должно действовать после замены: %v", err)
+	}
+	if rotated.ID != k.ID || !rotated.Disabled || rotated.Hash != HashKey(newSecret) {
+		t.Errorf("замена должна сохранять параметры ключа: %+v", rotated)
+	}
+
+	if _, err := m.Update(ctx, "ops", func(k *Key) {}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("ключ конфигурации не должен изменяться через API: %v", err)
+	}
+	if err := m.Delete(ctx, k.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete(ctx, k.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ожидалась ошибка ErrNotFound, получена %v", err)
+	}
+	if list := m.List(); len(list) != 1 || list[0].ID != "ops" {
+		t.Errorf("должен остаться только ключ конфигурации: %+v", list)
+	}
+}
+
+func TestManager_RateLimit(t *testing.T) {
+	m, err := New(&config.APIKeyAuthConfig{Keys: []config.APIKeyConfig{
+		{Name: "limited", Key: "limited-key-0123456", RateLimit: &config.APIKeyRateLimitConfig{Rate: 0.001, Burst: 2}},
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := m.Authenticate("limited-key-0123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Allow(k) || !m.Allow(k) || m.Allow(k) {
+		t.Error("ключ должен пропускать не больше burst запросов")
+	}
+	if _, _, err := m.Create(context.Background(), Key{Name: "new"}); !errors.Is(err, ErrNoStore) {
+		t.Errorf("без хранилища ключи не выпускаются: %v", err)
+	}
+}
+
+// fakeRedis отвечает на команды хеша как Redis с паролем
+type fakeRedis struct {
+	listener net.Listener
+	password string
+
+	mu     sync.Mutex
+	hashes map[string]map[string]string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{listener: listener, password: password, hashes: make(map[string]map[string]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authenticated := f.password == ""
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+
+		var response string
+		f.mu.Lock()
+		switch {
+		case args[0] == "AUTH":
+			authenticated = args[1] == f.password
+			response = "+OK\r\n"
+			if !authenticated {
+				response = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			response = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "SELECT":
+			response = "+OK\r\n"
+		case args[0] == "HSET":
+			if f.hashes[args[1]] == nil {
+				f.hashes[args[1]] = make(map[string]string)
+			}
+			f.hashes[args[1]][args[2]] = args[3]
+			response = ":1\r\n"
+		case args[0] == "HDEL":
+			_, ok := f.hashes[args[1]][args[2]]
+			delete(f.hashes[args[1]], args[2])
+			response = ":0\r\n"
+			if ok {
+				response = ":1\r\n"
+			}
+		case args[0] == "HGETALL":
+			var b strings.Builder
+			fmt.Fprintf(&b, "*%d\r\n", 2*len(f.hashes[args[1]]))
+			for field, value := range f.hashes[args[1]] {
+				fmt.Fprintf(&b, "$%d\r\n%s\r\n$%d\r\n%s\r\n", len(field), field, len(value), value)
+			}
+			response = b.String()
+		default:
+			response = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+
+		if _, err := conn.Write([]byte(response)); err != nil {
+			return
+		}
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeRedis(t, "secret")
+
+	wrong := NewRedisStore(f.listener.Addr().String(), "wrong", 0, defaultRedisKey)
+	if _, err := wrong.List(ctx); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("ожидалась ошибка аутентификации, получена %v", err)
+	}
+
+	m, err := New(&config.APIKeyAuthConfig{Store: &config.APIKeyStoreConfig{
+		Type: "redis", Addr: f.listener.Addr().String(), Password: "secret", DB: 1,
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	k, secret, err := m.Create(ctx, Key{Name: "partner", RateLimit: &RateLimit{Rate: 10, Burst: 20}})
+	if err != nil {
+		t.Fatalf("ключ должен сохраняться в Redis: %v", err)
+	}
+	f.mu.Lock()
+	stored := f.hashes[defaultRedisKey][k.ID]
+	f.mu.Unlock()
+	if stored == "" || strings.Contains(stored, secret) {
+		t.Errorf("в Redis должен храниться только хеш ключа: %q", stored)
+	}
+
+	// Новое соединение после разрыва
+	m.store.(*RedisStore).conn.Close()
+	if _, err := m.store.List(ctx); err == nil {
+		t.Error("команда на закрытом соединении должна завершаться ошибкой")
+	}
+	if got, err := m.Authenticate(secret); err != nil || got.RateLimit.Burst != 20 {
+		t.Errorf("ключ должен проходить проверку: %v", err)
+	}
+	if err := m.Delete(ctx, k.ID); err != nil {
+		t.Fatalf("ключ должен удаляться: %v", err)
+	}
+	if err := m.Reload(ctx); err != nil {
+		t.Fatalf("после разрыва соединение должно открываться заново: %v", err)
+	}
+	if _, err := m.Authenticate(secret); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("удаленный ключ не должен действовать: %v", err)
+	}
+}
+
+func TestSQLStore(t *testing.T) {
+	ctx := context.Background()
+	store := &config.APIKeyStoreConfig{Type: "sqlite", Path: filepath.Join(t.TempDir(), "keys.db")}
+	m, err := New(&config.APIKeyAuthConfig{Store: store}, logger.NewNop())
+	if err != nil {
+		t.Fatalf("хранилище sqlite должно открываться встроенным драйвером: %v", err)
+	}
+	defer m.Stop()
+
+	k, secret, err := m.Create(ctx, Key{Name: "partner", Routes: []string{"partners"}})
+	if err != nil {
+		t.Fatalf("ключ должен сохраняться в базу: %v", err)
+	}
+
+	// Другой экземпляр с той же базой видит выпущенный ключ
+	other, err := New(&config.APIKeyAuthConfig{Store: store}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Stop()
+	if got, err := other.Authenticate(secret); err != nil || got.Name != "partner" || got.Routes[0] != "partners" {
+		t.Errorf("ключ должен читаться из базы: %+v, %v", got, err)
+	}
+
+	if err := m.Delete(ctx, k.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete(ctx, k.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ожидалась ошибка ErrNotFound, получена %v", err)
+	}
+	if err := other.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Authenticate(secret); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("удаленный ключ не должен действовать: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package apikeys
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileData содержимое файла хранилища
+type fileData struct {
+	Keys []Key `json:"keys"`
+}
+
+// FileStore хранит ключи в JSON файле. Файл перезаписывается целиком через временный
+// файл, поэтому при сбое записи остается прежняя версия. Подходит для одного экземпляра
+// или общего тома, изменяемого одним экземпляром.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStore создает хранилище в файле path; файл создается при первой записи
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// List возвращает ключи из файла
+func (s *FileStore) List(ctx context.Context) ([]Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+// Put сохраняет ключ в файл
+func (s *FileStore) Put(ctx context.Context, k Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.read()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range keys {
+		if keys[i].ID == k.ID {
+			keys[i] = k
+			replaced = true
+		}
+	}
+	if !replaced {
+		keys = append(keys, k)
+	}
+	return s.write(keys)
+}
+
+// Delete удаляет ключ из файла
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.read()
+	if err != nil {
+		return err
+	}
+	for i := range keys {
+		if keys[i].ID == id {
+			return s.write(append(keys[:i], keys[i+1:]...))
+		}
+	}
+	return ErrNotFound
+}
+
+// Close ничего не делает: файл открывается только на время операций
+func (s *FileStore) Close() error {
+	return nil
+}
+
+// read читает ключи; отсутствующий файл — пустое хранилище
+func (s *FileStore) read() ([]Key, error) {
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var data fileData
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("invalid API key file %s: %w", s.path, err)
+	}
+	return data.Keys, nil
+}
+
+// write атомарно заменяет файл
+func (s *FileStore) write(keys []Key) error {
+	content, err := json.MarshalIndent(fileData{Keys: keys}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
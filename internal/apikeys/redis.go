@@ -0,0 +1,241 @@
+package apikeys
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Параметры хранилища в Redis
+const (
+	defaultRedisKey  = "lb:apikeys"
+	redisDialTimeout = 5 * time.Second
+
+	// Время на команду, если у контекста нет срока
+	redisTimeout = 5 * time.Second
+
+	// Максимальный размер строки и число элементов массива в ответе Redis
+	maxRedisBulk  = 16 << 20
+	maxRedisArray = 1 << 20
+)
+
+// redisError ошибка, которую вернул Redis
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// RedisStore хранит ключи в хеше Redis: поле — ID ключа, значение — JSON документ.
+// Хранилище общее для всех экземпляров, подключенных к одному Redis. Команды
+// выполняются по одному соединению, которое переоткрывается после ошибки.
+type RedisStore struct {
+	addr     string
+	password string
+	db       int
+	key      string
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewRedisStore создает хранилище в хеше key базы db Redis по адресу addr.
+// Соединение открывается при первой команде.
+func NewRedisStore(addr, password string, db int, key string) *RedisStore {
+	return &RedisStore{addr: addr, password: password, db: db, key: key}
+}
+
+// List возвращает ключи из хеша
+func (s *RedisStore) List(ctx context.Context) ([]Key, error) {
+	reply, err := s.do(ctx, "HGETALL", s.key)
+	if err != nil {
+		return nil, err
+	}
+	fields, ok := reply.([]interface{})
+	if !ok || len(fields)%2 != 0 {
+		return nil, fmt.Errorf("redis: unexpected HGETALL reply")
+	}
+
+	keys := make([]Key, 0, len(fields)/2)
+	for i := 1; i < len(fields); i += 2 {
+		data, _ := fields[i].(string)
+		var k Key
+		if err := json.Unmarshal([]byte(data), &k); err != nil {
+			return nil, fmt.Errorf("invalid API key record: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// Put сохраняет ключ в хеш
+func (s *RedisStore) Put(ctx context.Context, k Key) error {
+	data, err := json.Marshal(k)
+	if err != nil {
+		return err
+	}
+	_, err = s.do(ctx, "HSET", s.key, k.ID, string(data))
+	return err
+}
+
+// Delete удаляет ключ из хеша
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	reply, err := s.do(ctx, "HDEL", s.key, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := reply.(int64); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Close закрывает соединение
+func (s *RedisStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// do выполняет команду и возвращает ответ: string, int64, []interface{} или nil
+func (s *RedisStore) do(ctx context.Context, args ...string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	s.conn.SetDeadline(deadline)
+
+	reply, err := s.command(args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// После сетевой ошибки или ошибки протокола состояние соединения неизвестно
+		s.conn.Close()
+		s.conn = nil
+	}
+	return reply, err
+}
+
+// connect открывает соединение, проходит аутентификацию и выбирает базу
+func (s *RedisStore) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: redisDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	s.conn = conn
+	s.r = bufio.NewReader(conn)
+
+	conn.SetDeadline(time.Now().Add(redisTimeout))
+	if s.password != "" {
+		if _, err := s.command("AUTH", s.password); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	if s.db != 0 {
+		if _, err := s.command("SELECT", strconv.Itoa(s.db)); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// command отправляет команду массивом bulk строк и читает ответ
+func (s *RedisStore) command(args ...string) (interface{}, error) {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := s.conn.Write(buf); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return readReply(s.r)
+}
+
+// readReply читает ответ в протоколе RESP2
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, value := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return value, nil
+	case '-':
+		return nil, redisError(value)
+	case ':':
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed integer %q", value)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(value)
+		if err != nil || n > maxRedisBulk {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", value)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(value)
+		if err != nil || n > maxRedisArray {
+			return nil, fmt.Errorf("redis: malformed array length %q", value)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply type %q", kind)
+	}
+}
@@ -0,0 +1,98 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	// Драйвер SQLite по умолчанию, без cgo
+	_ "modernc.org/sqlite"
+)
+
+// defaultSQLDriver имя драйвера SQLite по умолчанию (modernc.org/sqlite); драйвер
+// mattn/go-sqlite3 регистрируется под именем sqlite3
+const defaultSQLDriver = "sqlite"
+
+// Запросы к таблице ключей. Ключ хранится JSON документом, чтобы новые поля
+// не требовали миграций.
+const (
+	sqlCreateTable = `CREATE TABLE IF NOT EXISTS api_keys (id TEXT PRIMARY KEY, data TEXT NOT NULL)`
+	sqlList        = `SELECT data FROM api_keys`
+	sqlPut         = `INSERT INTO api_keys (id, data) VALUES (?, ?) ON CONFLICT(id) DO UPDATE SET data = excluded.data`
+	sqlDelete      = `DELETE FROM api_keys WHERE id = ?`
+)
+
+// SQLStore хранит ключи в таблице api_keys базы SQLite через database/sql. В прокси
+// входит драйвер modernc.org/sqlite; другой драйвер подключают к сборке импортом его
+// пакета. Несколько экземпляров могут использовать одну базу на общем томе.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore открывает базу dsn драйвером driver и создает таблицу ключей
+func NewSQLStore(driver, dsn string) (*SQLStore, error) {
+	if !slices.Contains(sql.Drivers(), driver) {
+		return nil, fmt.Errorf("sql driver %q is not registered: import it into the proxy build", driver)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open API key database: %w", err)
+	}
+	if _, err := db.Exec(sqlCreateTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create API key table: %w", err)
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// List возвращает ключи из таблицы
+func (s *SQLStore) List(ctx context.Context) ([]Key, error) {
+	rows, err := s.db.QueryContext(ctx, sqlList)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []Key
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var k Key
+		if err := json.Unmarshal([]byte(data), &k); err != nil {
+			return nil, fmt.Errorf("invalid API key record: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// Put сохраняет ключ в таблицу
+func (s *SQLStore) Put(ctx context.Context, k Key) error {
+	data, err := json.Marshal(k)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, sqlPut, k.ID, string(data))
+	return err
+}
+
+// Delete удаляет ключ из таблицы
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, sqlDelete, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Close закрывает базу
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
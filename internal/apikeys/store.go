@@ -0,0 +1,57 @@
+package apikeys
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"cloud.ru_test/config"
+)
+
+// Store хранилище ключей API. Методы вызываются одновременно из перечитывания
+// и административного API.
+type Store interface {
+	// List возвращает все ключи хранилища
+	List(ctx context.Context) ([]Key, error)
+
+	// Put сохраняет ключ, заменяя ключ с тем же ID
+	Put(ctx context.Context, k Key) error
+
+	// Delete удаляет ключ; ErrNotFound — ключа нет в хранилище
+	Delete(ctx context.Context, id string) error
+
+	// Close освобождает ресурсы хранилища
+	Close() error
+}
+
+// NewStore создает хранилище по конфигурации
+func NewStore(cfg *config.APIKeyStoreConfig) (Store, error) {
+	switch cfg.Type {
+	case "file":
+		return NewFileStore(cfg.Path), nil
+	case "sqlite":
+		driver := cfg.Driver
+		if driver == "" {
+			driver = defaultSQLDriver
+		}
+		return NewSQLStore(driver, cfg.Path)
+	case "redis":
+		key := cfg.Key
+		if key == "" {
+			key = defaultRedisKey
+		}
+		return NewRedisStore(cfg.Addr, cfg.Password, cfg.DB, key), nil
+	default:
+		return nil, fmt.Errorf("unsupported API key store type: %s", cfg.Type)
+	}
+}
+
+// sortKeys упорядочивает ключи по времени создания, ключи конфигурации — первыми
+func sortKeys(keys []Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+}
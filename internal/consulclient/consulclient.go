@@ -0,0 +1,92 @@
+// Package consulclient содержит минимальный клиент HTTP API Consul: адрес агента и токен
+// берутся из CONSUL_HTTP_ADDR и CONSUL_HTTP_TOKEN, если не заданы явно
+package consulclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAddress адрес локального агента Consul
+const DefaultAddress = "http://127.0.0.1:8500"
+
+// WaitTime максимальное время блокирующего запроса к Consul
+const WaitTime = 5 * time.Minute
+
+// Client выполняет запросы к агенту Consul с токеном ACL
+type Client struct {
+	address string
+	token   string
+	client  *http.Client
+}
+
+// New создает клиент агента по адресу address (host:port или URL)
+func New(address, token string) *Client {
+	if address == "" {
+		address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if address == "" {
+		address = DefaultAddress
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	if token == "" {
+		token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	return &Client{address: strings.TrimRight(address, "/"), token: token, client: &http.Client{}}
+}
+
+// Address возвращает адрес агента
+func (c *Client) Address() string {
+	return c.address
+}
+
+// Do выполняет запрос к пути path агента
+func (c *Client) Do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.address+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consul request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("consul request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// Get выполняет GET запрос. При ненулевом index запрос блокируется, пока индекс
+// данных не изменится, но не дольше WaitTime.
+func (c *Client) Get(ctx context.Context, path string, query url.Values, index uint64) (*http.Response, error) {
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", WaitTime.String())
+	}
+	return c.Do(ctx, http.MethodGet, path+"?"+query.Encode(), nil)
+}
+
+// Index возвращает индекс данных из заголовка X-Consul-Index ответа
+func Index(resp *http.Response) (uint64, error) {
+	index, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid X-Consul-Index header: %w", err)
+	}
+	return index, nil
+}
+
+// StatusError возвращает ошибку с неожиданным статусом ответа и началом его тела
+func StatusError(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("consul returned %s: %s", resp.Status, message)
+}
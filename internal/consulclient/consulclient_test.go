@@ -0,0 +1,49 @@
+package consulclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestNew_Defaults(t *testing.T) {
+	t.Setenv("CONSUL_HTTP_ADDR", "")
+	t.Setenv("CONSUL_HTTP_TOKEN", "")
+	if c := New("", ""); c.Address() != DefaultAddress {
+		t.Errorf("по умолчанию должен использоваться локальный агент: %s", c.Address())
+	}
+	if c := New("consul:8500/", ""); c.Address() != "http://consul:8500" {
+		t.Errorf("адрес без схемы должен дополняться http: %s", c.Address())
+	}
+
+	t.Setenv("CONSUL_HTTP_ADDR", "https://consul.example.com")
+	t.Setenv("CONSUL_HTTP_TOKEN", "env-token")
+	if c := New("", ""); c.Address() != "https://consul.example.com" || c.token != "env-token" {
+		t.Errorf("адрес и токен должны браться из окружения: %s %s", c.Address(), c.token)
+	}
+}
+
+func TestClient_Get(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != "token" {
+			t.Errorf("запрос должен передавать токен: %q", r.Header.Get("X-Consul-Token"))
+		}
+		query := r.URL.Query()
+		if query.Get("index") != "7" || query.Get("wait") != WaitTime.String() || query.Get("dc") != "dc1" {
+			t.Errorf("неверные параметры блокирующего запроса: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("X-Consul-Index", "8")
+	}))
+	defer server.Close()
+
+	resp, err := New(server.URL, "token").Get(context.Background(), "/v1/kv/key", url.Values{"dc": {"dc1"}}, 7)
+	if err != nil {
+		t.Fatalf("ошибка запроса: %v", err)
+	}
+	defer resp.Body.Close()
+	if index, err := Index(resp); err != nil || index != 8 {
+		t.Errorf("неверный индекс: %d %v", index, err)
+	}
+}
@@ -0,0 +1,90 @@
+package discovery
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"cloud.ru_test/config"
+)
+
+// Composite объединяет несколько источников обнаружения в один. При совпадении
+// ID или URL бэкенда у нескольких источников используется бэкенд первого из них.
+type Composite struct {
+	sources []Discovery
+}
+
+// NewComposite создает составной источник. Порядок источников задает их приоритет.
+func NewComposite(sources ...Discovery) *Composite {
+	return &Composite{sources: sources}
+}
+
+// Name возвращает описание источника
+func (c *Composite) Name() string {
+	names := make([]string, len(c.sources))
+	for i, source := range c.sources {
+		names[i] = source.Name()
+	}
+	return "composite(" + strings.Join(names, ", ") + ")"
+}
+
+// Watch отслеживает все источники и после каждого изменения любого из них
+// передает объединенный набор бэкендов
+func (c *Composite) Watch(ctx context.Context) <-chan []config.BackendConfig {
+	type sourceUpdate struct {
+		index    int
+		backends []config.BackendConfig
+	}
+
+	updates := make(chan sourceUpdate)
+	var wg sync.WaitGroup
+	for i, source := range c.sources {
+		wg.Add(1)
+		go func(i int, ch <-chan []config.BackendConfig) {
+			defer wg.Done()
+			for backends := range ch {
+				select {
+				case updates <- sourceUpdate{index: i, backends: backends}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(i, source.Watch(ctx))
+	}
+	go func() {
+		wg.Wait()
+		close(updates)
+	}()
+
+	out := make(chan []config.BackendConfig, 1)
+	go func() {
+		defer close(out)
+
+		latest := make([][]config.BackendConfig, len(c.sources))
+		for update := range updates {
+			latest[update.index] = update.backends
+			publish(out, merge(latest))
+		}
+	}()
+
+	return out
+}
+
+// merge объединяет наборы бэкендов, отбрасывая повторы по ID и URL
+func merge(sets [][]config.BackendConfig) []config.BackendConfig {
+	ids := make(map[string]bool)
+	urls := make(map[string]bool)
+
+	var merged []config.BackendConfig
+	for _, set := range sets {
+		for _, bc := range set {
+			if ids[bc.ID] || urls[bc.URL] {
+				continue
+			}
+			ids[bc.ID] = true
+			urls[bc.URL] = true
+			merged = append(merged, bc)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,64 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+)
+
+// staticDiscovery источник с заранее заданными наборами бэкендов
+type staticDiscovery struct {
+	sets chan []config.BackendConfig
+}
+
+func (s *staticDiscovery) Name() string { return "static" }
+
+func (s *staticDiscovery) Watch(ctx context.Context) <-chan []config.BackendConfig {
+	return s.sets
+}
+
+func TestComposite_MergesAndDeduplicates(t *testing.T) {
+	file := &staticDiscovery{sets: make(chan []config.BackendConfig, 1)}
+	k8s := &staticDiscovery{sets: make(chan []config.BackendConfig, 1)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	updates := NewComposite(file, k8s).Watch(ctx)
+
+	file.sets <- []config.BackendConfig{{ID: "api-1", URL: "http://10.0.0.1:8080"}}
+	waitBackends(t, updates, "api-1")
+
+	// Экземпляр с тем же URL из второго источника отбрасывается
+	k8s.sets <- []config.BackendConfig{
+		{ID: "k8s:10.0.0.1:8080", URL: "http://10.0.0.1:8080"},
+		{ID: "k8s:10.0.0.2:8080", URL: "http://10.0.0.2:8080"},
+	}
+	waitBackends(t, updates, "api-1", "k8s:10.0.0.2:8080")
+
+	// После закрытия всех источников закрывается и объединенный канал
+	close(file.sets)
+	close(k8s.sets)
+	for range updates {
+	}
+}
+
+// waitBackends проверяет ID бэкендов в очередном объединенном наборе
+func waitBackends(t *testing.T, updates <-chan []config.BackendConfig, ids ...string) {
+	t.Helper()
+
+	select {
+	case backends := <-updates:
+		if len(backends) != len(ids) {
+			t.Fatalf("ожидалось %v, получено %+v", ids, backends)
+		}
+		for i, id := range ids {
+			if backends[i].ID != id {
+				t.Errorf("ожидался бэкенд %s на позиции %d, получен %s", id, i, backends[i].ID)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("объединенный набор не получен")
+	}
+}
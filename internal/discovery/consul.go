@@ -0,0 +1,143 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/consulclient"
+)
+
+// consulServiceEntry элемент ответа /v1/health/service/{service}
+type consulServiceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string            `json:"Address"`
+		Port    int               `json:"Port"`
+		Meta    map[string]string `json:"Meta"`
+		Weights struct {
+			Passing int `json:"Passing"`
+		} `json:"Weights"`
+	} `json:"Service"`
+}
+
+// ConsulProvider обнаруживает экземпляры сервиса через health API Consul
+// с помощью блокирующих запросов
+type ConsulProvider struct {
+	cfg    config.ConsulDiscoveryConfig
+	client *consulclient.Client
+}
+
+// NewConsulProvider создает источник обнаружения Consul
+func NewConsulProvider(cfg *config.ConsulDiscoveryConfig) (*ConsulProvider, error) {
+	if cfg == nil || cfg.Service == "" {
+		return nil, fmt.Errorf("consul service is required")
+	}
+
+	p := &ConsulProvider{cfg: *cfg, client: consulclient.New(cfg.Address, cfg.Token)}
+	if p.cfg.Scheme == "" {
+		p.cfg.Scheme = "http"
+	}
+
+	return p, nil
+}
+
+// Name возвращает описание источника
+func (p *ConsulProvider) Name() string {
+	return fmt.Sprintf("consul %s", p.cfg.Service)
+}
+
+// Watch отслеживает экземпляры сервиса
+func (p *ConsulProvider) Watch(ctx context.Context, update func(targets []Target)) error {
+	var index uint64
+	for {
+		entries, newIndex, err := p.get(ctx, index)
+		if err != nil {
+			return err
+		}
+
+		// Индекс может уменьшиться, например после восстановления Consul из снапшота
+		if newIndex < index {
+			index = 0
+			continue
+		}
+		if newIndex == index {
+			continue
+		}
+		index = newIndex
+
+		update(p.targets(entries))
+	}
+}
+
+// targets преобразует экземпляры Consul в обнаруженные бэкенды
+func (p *ConsulProvider) targets(entries []consulServiceEntry) []Target {
+	targets := make([]Target, 0, len(entries))
+	for _, entry := range entries {
+		// Если у сервиса не задан адрес, используется адрес узла
+		address := entry.Service.Address
+		if address == "" {
+			address = entry.Node.Address
+		}
+		hostPort := net.JoinHostPort(address, strconv.Itoa(entry.Service.Port))
+
+		targets = append(targets, Target{
+			ID:     "consul:" + hostPort,
+			URL:    p.cfg.Scheme + "://" + hostPort,
+			Zone:   entry.Service.Meta["zone"],
+			Weight: float64(entry.Service.Weights.Passing),
+		})
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].ID < targets[j].ID })
+	return targets
+}
+
+// get запрашивает экземпляры сервиса. При ненулевом index запрос блокируется до изменений.
+func (p *ConsulProvider) get(ctx context.Context, index uint64) ([]consulServiceEntry, uint64, error) {
+	query := url.Values{}
+	if !p.cfg.IncludeUnhealthy {
+		query.Set("passing", "true")
+	}
+	if p.cfg.Tag != "" {
+		query.Set("tag", p.cfg.Tag)
+	}
+	if p.cfg.Datacenter != "" {
+		query.Set("dc", p.cfg.Datacenter)
+	}
+
+	// Ответ на блокирующий запрос приходит не позже wait с небольшим запасом
+	ctx, cancel := context.WithTimeout(ctx, consulclient.WaitTime+30*time.Second)
+	defer cancel()
+
+	resp, err := p.client.Get(ctx, "/v1/health/service/"+url.PathEscape(p.cfg.Service), query, index)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, consulclient.StatusError(resp)
+	}
+
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("error decoding consul response: %w", err)
+	}
+
+	newIndex, err := consulclient.Index(resp)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, newIndex, nil
+}
@@ -0,0 +1,76 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+)
+
+func TestConsulProvider_Watch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/health/service/payments" || r.URL.Query().Get("passing") != "true" || r.URL.Query().Get("tag") != "v2" {
+			t.Errorf("неверный запрос: %s", r.URL.String())
+		}
+		if r.Header.Get("X-Consul-Token") != "secret" {
+			t.Errorf("токен не передан")
+		}
+
+		// Первый запрос возвращает два экземпляра, блокирующий — один
+		if r.URL.Query().Get("index") == "" {
+			w.Header().Set("X-Consul-Index", "10")
+			fmt.Fprint(w, `[
+				{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "", "Port": 8080, "Meta": {"zone": "zone-a"}, "Weights": {"Passing": 3}}},
+				{"Node": {"Address": "10.0.0.9"}, "Service": {"Address": "10.0.0.2", "Port": 8080}}
+			]`)
+			return
+		}
+		if r.URL.Query().Get("index") == "10" {
+			w.Header().Set("X-Consul-Index", "11")
+			fmt.Fprint(w, `[{"Node": {"Address": "10.0.0.1"}, "Service": {"Port": 8080}}]`)
+			return
+		}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	provider, err := NewConsulProvider(&config.ConsulDiscoveryConfig{
+		Address: server.URL,
+		Service: "payments",
+		Tag:     "v2",
+		Token:   "secret",
+	})
+	if err != nil {
+		t.Fatalf("ошибка создания источника: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	updates := make(chan []Target, 2)
+	go provider.Watch(ctx, func(targets []Target) { updates <- targets })
+
+	targets := <-updates
+	if len(targets) != 2 {
+		t.Fatalf("ожидалось 2 экземпляра, получено %v", targets)
+	}
+	if targets[0].ID != "consul:10.0.0.1:8080" || targets[0].Weight != 3 || targets[0].Zone != "zone-a" {
+		t.Errorf("неверный экземпляр без адреса сервиса: %+v", targets[0])
+	}
+	if targets[1].URL != "http://10.0.0.2:8080" {
+		t.Errorf("ожидался адрес сервиса, получено %s", targets[1].URL)
+	}
+
+	select {
+	case targets = <-updates:
+		if len(targets) != 1 || targets[0].ID != "consul:10.0.0.1:8080" {
+			t.Errorf("ожидался один экземпляр после изменения, получено %v", targets)
+		}
+	case <-ctx.Done():
+		t.Fatal("изменение не получено")
+	}
+}
@@ -0,0 +1,217 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Target обнаруженный экземпляр сервиса
+type Target struct {
+	// Уникальный идентификатор, используется как ID бэкенда
+	ID string
+
+	// URL экземпляра
+	URL string
+
+	// Зона доступности (пустая строка, если неизвестна)
+	Zone string
+
+	// Вес экземпляра (0 — вес из настроек источника)
+	Weight float64
+}
+
+// Provider источник обнаружения экземпляров сервиса. Провайдер подключается
+// к Discovery через FromProvider, который берет на себя повторные подключения.
+type Provider interface {
+	// Name возвращает описание источника для логов
+	Name() string
+
+	// Watch отслеживает экземпляры сервиса и вызывает update с полным актуальным
+	// набором при каждом изменении. Блокируется до ошибки или отмены ctx.
+	Watch(ctx context.Context, update func(targets []Target)) error
+}
+
+// Discovery источник бэкендов для балансировщика
+type Discovery interface {
+	// Name возвращает описание источника для логов
+	Name() string
+
+	// Watch возвращает канал с полным актуальным набором бэкендов после каждого изменения.
+	// Канал закрывается после отмены ctx. Если получатель не успевает читать,
+	// промежуточные наборы пропускаются и он получает последний.
+	Watch(ctx context.Context) <-chan []config.BackendConfig
+}
+
+// publish отправляет актуальный набор бэкендов, заменяя еще не прочитанный предыдущий.
+// Канал должен иметь буфер размера 1 и единственного отправителя.
+func publish(ch chan []config.BackendConfig, backends []config.BackendConfig) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- backends
+}
+
+// Manager синхронизирует бэкенды, обнаруженные источниками, с балансировщиком.
+// Наборы всех источников объединяются через Composite. Бэкенды из секции backends
+// и добавленные через API им не затрагиваются.
+type Manager struct {
+	sources []Discovery
+	lb      loadbalancer.LoadBalancer
+	logger  logger.Logger
+
+	// Зарегистрированные менеджером бэкенды и их конфигурация
+	backends map[string]backend.Backend
+	configs  map[string]config.BackendConfig
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewManager создает менеджер обнаружения для перечисленных источников
+func NewManager(cfgs []config.DiscoveryConfig, appLogger logger.Logger) (*Manager, error) {
+	m := &Manager{
+		logger:   appLogger,
+		backends: make(map[string]backend.Backend),
+		configs:  make(map[string]config.BackendConfig),
+	}
+
+	for _, cfg := range cfgs {
+		source, err := New(cfg, appLogger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create discovery provider %s: %w", cfg.Type, err)
+		}
+		m.sources = append(m.sources, source)
+	}
+
+	return m, nil
+}
+
+// Start запускает отслеживание всех источников. Обнаруженные бэкенды регистрируются в lb.
+func (m *Manager) Start(lb loadbalancer.LoadBalancer) {
+	m.mu.Lock()
+	m.lb = lb
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+
+	for _, source := range m.sources {
+		m.logger.Info(fmt.Sprintf("Запуск обнаружения бэкендов: %s", source.Name()))
+	}
+
+	updates := NewComposite(m.sources...).Watch(ctx)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		for backends := range updates {
+			m.apply(backends)
+		}
+	}()
+}
+
+// SetLoadBalancer переключает менеджер на новый балансировщик и регистрирует
+// в нем все обнаруженные бэкенды, которых там еще нет
+func (m *Manager) SetLoadBalancer(lb loadbalancer.LoadBalancer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lb = lb
+	for id, b := range m.backends {
+		if lb.GetBackend(id) == nil {
+			lb.AddBackend(b)
+		}
+	}
+}
+
+// Stop останавливает отслеживание и удаляет обнаруженные бэкенды из балансировщика
+func (m *Manager) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lb == nil {
+		return
+	}
+	for id, b := range m.backends {
+		m.lb.RemoveBackend(b)
+		b.Close()
+		delete(m.backends, id)
+		delete(m.configs, id)
+	}
+}
+
+// apply приводит набор обнаруженных бэкендов в балансировщике к переданному
+func (m *Manager) apply(backends []config.BackendConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool, len(backends))
+	for _, bc := range backends {
+		seen[bc.ID] = true
+
+		current, known := m.configs[bc.ID]
+		if known && reflect.DeepEqual(current, bc) {
+			continue
+		}
+
+		if existing := m.backends[bc.ID]; existing != nil {
+			// Изменился только вес: обновляем на месте, сохраняя статистику
+			if sameExceptWeight(current, bc) {
+				existing.SetWeight(backendWeight(bc))
+				m.configs[bc.ID] = bc
+				continue
+			}
+			m.lb.RemoveBackend(existing)
+			existing.Close()
+		} else if m.lb.GetBackend(bc.ID) != nil {
+			// Бэкенд с таким ID задан в конфигурации или добавлен через API
+			m.logger.Debug(fmt.Sprintf("Обнаруженный бэкенд %s пропущен: бэкенд с таким ID уже существует", bc.ID))
+			continue
+		}
+
+		b := backend.NewFromConfig(bc)
+		m.lb.AddBackend(b)
+		m.backends[bc.ID] = b
+		m.configs[bc.ID] = bc
+		m.logger.Info(fmt.Sprintf("Обнаружен бэкенд %s (%s)", bc.ID, bc.URL))
+	}
+
+	for id, b := range m.backends {
+		if seen[id] {
+			continue
+		}
+		m.lb.RemoveBackend(b)
+		b.Close()
+		delete(m.backends, id)
+		delete(m.configs, id)
+		m.logger.Info(fmt.Sprintf("Бэкенд %s больше не обнаруживается и удален", id))
+	}
+}
+
+// sameExceptWeight проверяет, что конфигурации бэкенда отличаются не более чем весом
+func sameExceptWeight(a, b config.BackendConfig) bool {
+	a.Weight, b.Weight = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+// backendWeight возвращает вес бэкенда с учетом значения по умолчанию
+func backendWeight(bc config.BackendConfig) float64 {
+	if bc.Weight == nil {
+		return 1
+	}
+	return *bc.Weight
+}
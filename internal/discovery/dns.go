@@ -0,0 +1,120 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.ru_test/config"
+)
+
+// defaultDNSRefreshInterval интервал повторного разрешения по умолчанию
+const defaultDNSRefreshInterval = 30 * time.Second
+
+// DNSProvider обнаруживает экземпляры сервиса, периодически разрешая записи SRV или A/AAAA
+type DNSProvider struct {
+	cfg      config.DNSDiscoveryConfig
+	resolver *net.Resolver
+}
+
+// NewDNSProvider создает источник обнаружения DNS
+func NewDNSProvider(cfg *config.DNSDiscoveryConfig) (*DNSProvider, error) {
+	if cfg == nil || cfg.Name == "" {
+		return nil, fmt.Errorf("dns name is required")
+	}
+
+	p := &DNSProvider{cfg: *cfg, resolver: net.DefaultResolver}
+	if p.cfg.RecordType == "" {
+		p.cfg.RecordType = "SRV"
+	}
+	if p.cfg.RefreshInterval <= 0 {
+		p.cfg.RefreshInterval = defaultDNSRefreshInterval
+	}
+	if p.cfg.Scheme == "" {
+		p.cfg.Scheme = "http"
+	}
+
+	// Запросы к указанному DNS серверу вместо системного резолвера
+	if p.cfg.Resolver != "" {
+		server := p.cfg.Resolver
+		p.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	return p, nil
+}
+
+// Name возвращает описание источника
+func (p *DNSProvider) Name() string {
+	return fmt.Sprintf("dns %s %s", p.cfg.RecordType, p.cfg.Name)
+}
+
+// Watch разрешает имя сразу и затем с заданным интервалом
+func (p *DNSProvider) Watch(ctx context.Context, update func(targets []Target)) error {
+	ticker := time.NewTicker(p.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		targets, err := p.resolve(ctx)
+		if err != nil {
+			return err
+		}
+		update(targets)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// resolve разрешает имя в набор экземпляров
+func (p *DNSProvider) resolve(ctx context.Context) ([]Target, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var targets []Target
+	switch p.cfg.RecordType {
+	case "SRV":
+		// Имя запрашивается напрямую: payments.service.consul или _http._tcp.payments.local
+		_, records, err := p.resolver.LookupSRV(ctx, "", "", p.cfg.Name)
+		if err != nil {
+			return nil, fmt.Errorf("SRV lookup %s failed: %w", p.cfg.Name, err)
+		}
+		for _, record := range records {
+			host := strings.TrimSuffix(record.Target, ".")
+			targets = append(targets, p.target(host, int(record.Port), float64(record.Weight)))
+		}
+	default:
+		addresses, err := p.resolver.LookupIPAddr(ctx, p.cfg.Name)
+		if err != nil {
+			return nil, fmt.Errorf("lookup %s failed: %w", p.cfg.Name, err)
+		}
+		for _, address := range addresses {
+			targets = append(targets, p.target(address.IP.String(), p.cfg.Port, 0))
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].ID < targets[j].ID })
+	return targets, nil
+}
+
+// target создает экземпляр по адресу и порту
+func (p *DNSProvider) target(host string, port int, weight float64) Target {
+	hostPort := net.JoinHostPort(host, strconv.Itoa(port))
+	return Target{
+		ID:     "dns:" + hostPort,
+		URL:    p.cfg.Scheme + "://" + hostPort,
+		Weight: weight,
+	}
+}
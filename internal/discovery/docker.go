@@ -0,0 +1,233 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.ru_test/config"
+)
+
+// defaultDockerHost адрес Docker daemon по умолчанию
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// dockerContainer подмножество полей ответа /containers/json
+type dockerContainer struct {
+	ID              string            `json:"Id"`
+	Names           []string          `json:"Names"`
+	Labels          map[string]string `json:"Labels"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+	Ports []struct {
+		PrivatePort int    `json:"PrivatePort"`
+		Type        string `json:"Type"`
+	} `json:"Ports"`
+}
+
+// DockerProvider обнаруживает запущенные контейнеры локального Docker по меткам,
+// например lb.enable=true, lb.port=8080, lb.weight=2
+type DockerProvider struct {
+	cfg     config.DockerDiscoveryConfig
+	baseURL string
+	client  *http.Client
+}
+
+// NewDockerProvider создает источник обнаружения Docker
+func NewDockerProvider(cfg *config.DockerDiscoveryConfig) (*DockerProvider, error) {
+	p := &DockerProvider{}
+	if cfg != nil {
+		p.cfg = *cfg
+	}
+	if p.cfg.Host == "" {
+		p.cfg.Host = os.Getenv("DOCKER_HOST")
+	}
+	if p.cfg.Host == "" {
+		p.cfg.Host = defaultDockerHost
+	}
+	if p.cfg.LabelPrefix == "" {
+		p.cfg.LabelPrefix = "lb"
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	switch {
+	case strings.HasPrefix(p.cfg.Host, "unix://"):
+		// Запросы идут через unix сокет, имя хоста в URL не используется
+		socket := strings.TrimPrefix(p.cfg.Host, "unix://")
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		p.baseURL = "http://docker"
+	case strings.HasPrefix(p.cfg.Host, "tcp://"):
+		p.baseURL = "http://" + strings.TrimPrefix(p.cfg.Host, "tcp://")
+	default:
+		return nil, fmt.Errorf("unsupported docker host: %s", p.cfg.Host)
+	}
+	p.client = &http.Client{Transport: transport}
+
+	return p, nil
+}
+
+// Name возвращает описание источника
+func (p *DockerProvider) Name() string {
+	return fmt.Sprintf("docker %s", p.cfg.Host)
+}
+
+// Watch получает список контейнеров и обновляет его при каждом событии запуска или остановки контейнера
+func (p *DockerProvider) Watch(ctx context.Context, update func(targets []Target)) error {
+	// Подписываемся на события до получения списка, чтобы не пропустить изменения между ними
+	filters, _ := json.Marshal(map[string][]string{
+		"type":  {"container"},
+		"event": {"start", "die", "stop", "pause", "unpause"},
+	})
+	events, err := p.get(ctx, "/events", url.Values{"filters": {string(filters)}})
+	if err != nil {
+		return err
+	}
+	defer events.Close()
+
+	targets, err := p.list(ctx)
+	if err != nil {
+		return err
+	}
+	update(targets)
+
+	decoder := json.NewDecoder(events)
+	for {
+		var event json.RawMessage
+		if err := decoder.Decode(&event); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("docker events stream: %w", err)
+		}
+
+		targets, err := p.list(ctx)
+		if err != nil {
+			return err
+		}
+		update(targets)
+	}
+}
+
+// list возвращает запущенные контейнеры с меткой <prefix>.enable=true
+func (p *DockerProvider) list(ctx context.Context) ([]Target, error) {
+	filters, _ := json.Marshal(map[string][]string{
+		"label":  {p.cfg.LabelPrefix + ".enable=true"},
+		"status": {"running"},
+	})
+	body, err := p.get(ctx, "/containers/json", url.Values{"filters": {string(filters)}})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var containers []dockerContainer
+	if err := json.NewDecoder(body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("error decoding docker containers: %w", err)
+	}
+
+	targets := make([]Target, 0, len(containers))
+	for _, container := range containers {
+		if target, ok := p.target(container); ok {
+			targets = append(targets, target)
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].ID < targets[j].ID })
+	return targets, nil
+}
+
+// target преобразует контейнер в обнаруженный бэкенд. Контейнеры без адреса
+// (например, в сети host) или без однозначного порта пропускаются.
+func (p *DockerProvider) target(container dockerContainer) (Target, bool) {
+	label := func(name string) string {
+		return container.Labels[p.cfg.LabelPrefix+"."+name]
+	}
+
+	address := ""
+	if p.cfg.Network != "" {
+		address = container.NetworkSettings.Networks[p.cfg.Network].IPAddress
+	} else {
+		networks := make([]string, 0, len(container.NetworkSettings.Networks))
+		for name := range container.NetworkSettings.Networks {
+			networks = append(networks, name)
+		}
+		sort.Strings(networks)
+		for _, name := range networks {
+			if ip := container.NetworkSettings.Networks[name].IPAddress; ip != "" {
+				address = ip
+				break
+			}
+		}
+	}
+	if address == "" {
+		return Target{}, false
+	}
+
+	// Без метки порта используется единственный открытый TCP порт контейнера
+	port, _ := strconv.Atoi(label("port"))
+	if port == 0 {
+		for _, containerPort := range container.Ports {
+			if containerPort.Type != "tcp" {
+				continue
+			}
+			if port != 0 && port != containerPort.PrivatePort {
+				return Target{}, false
+			}
+			port = containerPort.PrivatePort
+		}
+	}
+	if port <= 0 || port > 65535 {
+		return Target{}, false
+	}
+
+	scheme := label("scheme")
+	if scheme != "https" {
+		scheme = "http"
+	}
+	weight, _ := strconv.ParseFloat(label("weight"), 64)
+
+	name := container.ID
+	if len(container.Names) > 0 {
+		name = strings.TrimPrefix(container.Names[0], "/")
+	}
+
+	return Target{
+		ID:     "docker:" + name,
+		URL:    scheme + "://" + net.JoinHostPort(address, strconv.Itoa(port)),
+		Weight: weight,
+	}, true
+}
+
+// get выполняет запрос к Docker Engine API и возвращает тело успешного ответа
+func (p *DockerProvider) get(ctx context.Context, path string, query url.Values) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("docker returned %s: %s", resp.Status, message)
+	}
+
+	return resp.Body, nil
+}
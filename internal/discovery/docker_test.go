@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+)
+
+func TestDockerProvider_Watch(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("ошибка создания сокета: %v", err)
+	}
+
+	events := make(chan struct{})
+	listed := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/json":
+			if r.URL.Query().Get("filters") != `{"label":["lb.enable=true"],"status":["running"]}` {
+				t.Errorf("неверный фильтр: %s", r.URL.Query().Get("filters"))
+			}
+			listed++
+			if listed > 1 {
+				fmt.Fprint(w, `[]`)
+				return
+			}
+			fmt.Fprint(w, `[
+				{"Id": "a1", "Names": ["/web-1"], "Labels": {"lb.enable": "true", "lb.weight": "2"},
+				 "NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.2"}}},
+				 "Ports": [{"PrivatePort": 8080, "Type": "tcp"}]},
+				{"Id": "b2", "Names": ["/web-2"], "Labels": {"lb.enable": "true"},
+				 "NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.3"}}},
+				 "Ports": [{"PrivatePort": 80, "Type": "tcp"}, {"PrivatePort": 443, "Type": "tcp"}]}
+			]`)
+		case "/events":
+			w.(http.Flusher).Flush()
+			<-events
+			fmt.Fprint(w, `{"Type": "container", "Action": "die", "id": "a1"}`+"\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			t.Errorf("неожиданный запрос: %s", r.URL.Path)
+		}
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	provider, err := NewDockerProvider(&config.DockerDiscoveryConfig{Host: "unix://" + socket})
+	if err != nil {
+		t.Fatalf("ошибка создания источника: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	updates := make(chan []Target, 2)
+	go provider.Watch(ctx, func(targets []Target) { updates <- targets })
+
+	// Контейнер с несколькими портами без метки lb.port пропускается
+	targets := <-updates
+	if len(targets) != 1 || targets[0].ID != "docker:web-1" || targets[0].URL != "http://172.17.0.2:8080" || targets[0].Weight != 2 {
+		t.Fatalf("неверные экземпляры: %+v", targets)
+	}
+
+	close(events)
+	select {
+	case targets = <-updates:
+		if len(targets) != 0 {
+			t.Errorf("ожидалось удаление контейнера, получено %v", targets)
+		}
+	case <-ctx.Done():
+		t.Fatal("изменение не получено")
+	}
+}
@@ -0,0 +1,98 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+// fileDebounceDelay задержка перед перечитыванием файла после серии событий
+const fileDebounceDelay = 100 * time.Millisecond
+
+// FileDiscovery читает бэкенды из отдельного файла и перечитывает его при изменениях.
+// Некорректный файл не применяется, продолжает действовать предыдущий список.
+type FileDiscovery struct {
+	path   string
+	logger logger.Logger
+}
+
+// NewFileDiscovery создает источник бэкендов из файла
+func NewFileDiscovery(cfg *config.FileDiscoveryConfig, appLogger logger.Logger) (*FileDiscovery, error) {
+	if cfg == nil || cfg.Path == "" {
+		return nil, fmt.Errorf("file path is required")
+	}
+	return &FileDiscovery{path: cfg.Path, logger: appLogger}, nil
+}
+
+// Name возвращает описание источника
+func (f *FileDiscovery) Name() string {
+	return fmt.Sprintf("file %s", f.path)
+}
+
+// Watch читает файл и отслеживает его изменения
+func (f *FileDiscovery) Watch(ctx context.Context) <-chan []config.BackendConfig {
+	out := make(chan []config.BackendConfig, 1)
+
+	go func() {
+		defer close(out)
+
+		load := func() {
+			backends, err := config.LoadBackends(f.path)
+			if err != nil {
+				f.logger.Warn(fmt.Sprintf("Ошибка чтения бэкендов из %s: %v, используется предыдущий список", f.path, err))
+				return
+			}
+			publish(out, backends)
+		}
+		load()
+
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			f.logger.Error(fmt.Sprintf("Не удалось отслеживать файл бэкендов %s: %v", f.path, err))
+			return
+		}
+		defer watcher.Close()
+
+		// Отслеживается каталог: так обнаруживается замена файла через rename
+		// и подмена символических ссылок в смонтированных ConfigMap
+		if err := watcher.Add(filepath.Dir(f.path)); err != nil {
+			f.logger.Error(fmt.Sprintf("Не удалось отслеживать файл бэкендов %s: %v", f.path, err))
+			return
+		}
+
+		debounce := time.NewTimer(fileDebounceDelay)
+		debounce.Stop()
+		defer debounce.Stop()
+
+		name := filepath.Base(f.path)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				base := filepath.Base(event.Name)
+				if (base == name || base == "..data") && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					debounce.Reset(fileDebounceDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				f.logger.Warn(fmt.Sprintf("Ошибка отслеживания файла бэкендов %s: %v", f.path, err))
+			case <-debounce.C:
+				load()
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,214 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/k8sclient"
+)
+
+// watchTimeoutSeconds время, после которого API сервер закрывает watch; затем watch возобновляется
+const watchTimeoutSeconds = 300
+
+// endpointSlice подмножество полей discovery.k8s.io/v1 EndpointSlice
+type endpointSlice struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Endpoints []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+		Zone string `json:"zone"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name string `json:"name"`
+		Port int    `json:"port"`
+	} `json:"ports"`
+}
+
+// endpointSliceList ответ на запрос списка EndpointSlice
+type endpointSliceList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []endpointSlice `json:"items"`
+}
+
+// watchEvent событие потока watch
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// KubernetesProvider обнаруживает готовые поды сервиса через EndpointSlices
+type KubernetesProvider struct {
+	cfg    config.KubernetesDiscoveryConfig
+	client *k8sclient.Client
+}
+
+// NewKubernetesProvider создает источник обнаружения Kubernetes. Незаданные адрес API сервера,
+// пространство имен и учетные данные берутся из окружения пода.
+func NewKubernetesProvider(cfg *config.KubernetesDiscoveryConfig) (*KubernetesProvider, error) {
+	if cfg == nil || cfg.Service == "" {
+		return nil, fmt.Errorf("kubernetes service is required")
+	}
+
+	client, err := k8sclient.New(k8sclient.Config{
+		APIServer: cfg.APIServer,
+		Namespace: cfg.Namespace,
+		TokenFile: cfg.TokenFile,
+		CAFile:    cfg.CAFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &KubernetesProvider{cfg: *cfg, client: client}
+	p.cfg.Namespace = client.Namespace()
+	if p.cfg.Scheme == "" {
+		p.cfg.Scheme = "http"
+	}
+
+	return p, nil
+}
+
+// Name возвращает описание источника
+func (p *KubernetesProvider) Name() string {
+	return fmt.Sprintf("kubernetes %s/%s", p.cfg.Namespace, p.cfg.Service)
+}
+
+// Watch получает список EndpointSlices сервиса и отслеживает их изменения
+func (p *KubernetesProvider) Watch(ctx context.Context, update func(targets []Target)) error {
+	var list endpointSliceList
+	if err := p.get(ctx, url.Values{}, func(body io.Reader) error {
+		return json.NewDecoder(body).Decode(&list)
+	}); err != nil {
+		return err
+	}
+
+	slices := make(map[string]endpointSlice, len(list.Items))
+	for _, slice := range list.Items {
+		slices[slice.Metadata.Name] = slice
+	}
+	update(p.targets(slices))
+
+	resourceVersion := list.Metadata.ResourceVersion
+	for {
+		query := url.Values{}
+		query.Set("watch", "true")
+		query.Set("resourceVersion", resourceVersion)
+		query.Set("allowWatchBookmarks", "true")
+		query.Set("timeoutSeconds", strconv.Itoa(watchTimeoutSeconds))
+
+		err := p.get(ctx, query, func(body io.Reader) error {
+			decoder := json.NewDecoder(body)
+			for {
+				var event watchEvent
+				if err := decoder.Decode(&event); err != nil {
+					if err == io.EOF {
+						return nil
+					}
+					return fmt.Errorf("kubernetes watch stream: %w", err)
+				}
+
+				if event.Type == "ERROR" {
+					// Например, 410 Gone: версия устарела, нужно заново получить список
+					return fmt.Errorf("kubernetes watch error: %s", event.Object)
+				}
+
+				var slice endpointSlice
+				if err := json.Unmarshal(event.Object, &slice); err != nil {
+					return fmt.Errorf("error decoding EndpointSlice: %w", err)
+				}
+				resourceVersion = slice.Metadata.ResourceVersion
+
+				switch event.Type {
+				case "ADDED", "MODIFIED":
+					slices[slice.Metadata.Name] = slice
+				case "DELETED":
+					delete(slices, slice.Metadata.Name)
+				default:
+					// BOOKMARK только обновляет версию
+					continue
+				}
+				update(p.targets(slices))
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// targets собирает готовые экземпляры из EndpointSlices
+func (p *KubernetesProvider) targets(slices map[string]endpointSlice) []Target {
+	zones := make(map[string]bool, len(p.cfg.Zones))
+	for _, zone := range p.cfg.Zones {
+		zones[zone] = true
+	}
+
+	var targets []Target
+	for _, slice := range slices {
+		port := 0
+		for _, slicePort := range slice.Ports {
+			if p.cfg.Port == "" || slicePort.Name == p.cfg.Port {
+				port = slicePort.Port
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+
+		for _, endpoint := range slice.Endpoints {
+			// Трафик получают только готовые поды; отсутствие условия означает готовность
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			if len(zones) > 0 && !zones[endpoint.Zone] {
+				continue
+			}
+
+			for _, address := range endpoint.Addresses {
+				hostPort := net.JoinHostPort(address, strconv.Itoa(port))
+				targets = append(targets, Target{
+					ID:   "k8s:" + hostPort,
+					URL:  p.cfg.Scheme + "://" + hostPort,
+					Zone: endpoint.Zone,
+				})
+			}
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].ID < targets[j].ID })
+	return targets
+}
+
+// get выполняет запрос к EndpointSlices сервиса и передает тело ответа в handle
+func (p *KubernetesProvider) get(ctx context.Context, query url.Values, handle func(body io.Reader) error) error {
+	query.Set("labelSelector", "kubernetes.io/service-name="+p.cfg.Service)
+	path := fmt.Sprintf("/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices?%s", url.PathEscape(p.cfg.Namespace), query.Encode())
+
+	resp, err := p.client.Do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return k8sclient.StatusError(resp)
+	}
+
+	return handle(resp.Body)
+}
@@ -0,0 +1,72 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+)
+
+const endpointSliceJSON = `{
+	"metadata": {"name": "%s", "resourceVersion": "%s"},
+	"endpoints": [
+		{"addresses": ["10.0.0.1"], "conditions": {"ready": true}, "zone": "zone-a"},
+		{"addresses": ["10.0.0.2"], "conditions": {"ready": false}, "zone": "zone-a"},
+		{"addresses": ["10.0.0.3"], "conditions": {"ready": true}, "zone": "zone-b"}
+	],
+	"ports": [{"name": "metrics", "port": 9100}, {"name": "http", "port": 8080}]
+}`
+
+func TestKubernetesProvider_Watch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("labelSelector") != "kubernetes.io/service-name=payments" {
+			t.Errorf("неверный селектор: %s", r.URL.RawQuery)
+		}
+
+		if r.URL.Query().Get("watch") != "true" {
+			fmt.Fprintf(w, `{"metadata": {"resourceVersion": "1"}, "items": [%s]}`, fmt.Sprintf(endpointSliceJSON, "payments-abc", "1"))
+			return
+		}
+
+		// Слайс удаляется, после чего поток остается открытым до отмены запроса
+		fmt.Fprintf(w, `{"type": "DELETED", "object": %s}`+"\n", fmt.Sprintf(endpointSliceJSON, "payments-abc", "2"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	provider, err := NewKubernetesProvider(&config.KubernetesDiscoveryConfig{
+		Service:   "payments",
+		Namespace: "shop",
+		Port:      "http",
+		Zones:     []string{"zone-a"},
+		APIServer: server.URL,
+	})
+	if err != nil {
+		t.Fatalf("ошибка создания источника: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	updates := make(chan []Target, 2)
+	go provider.Watch(ctx, func(targets []Target) { updates <- targets })
+
+	targets := <-updates
+	if len(targets) != 1 || targets[0].URL != "http://10.0.0.1:8080" || targets[0].Zone != "zone-a" {
+		t.Errorf("должен обнаруживаться только готовый под из зоны zone-a на порту http: %+v", targets)
+	}
+
+	select {
+	case targets := <-updates:
+		if len(targets) != 0 {
+			t.Errorf("после удаления слайса экземпляров быть не должно: %+v", targets)
+		}
+	case <-ctx.Done():
+		t.Fatal("событие watch не доставлено")
+	}
+}
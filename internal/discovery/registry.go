@@ -0,0 +1,156 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+// maxBackoff максимальная задержка перед повторным подключением к источнику
+const maxBackoff = 30 * time.Second
+
+// Factory создает источник обнаружения по конфигурации
+type Factory func(cfg config.DiscoveryConfig, appLogger logger.Logger) (Discovery, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+func init() {
+	Register("file", func(cfg config.DiscoveryConfig, appLogger logger.Logger) (Discovery, error) {
+		return NewFileDiscovery(cfg.File, appLogger)
+	})
+	registerProvider("kubernetes", func(cfg config.DiscoveryConfig) (Provider, error) {
+		return NewKubernetesProvider(cfg.Kubernetes)
+	})
+	registerProvider("consul", func(cfg config.DiscoveryConfig) (Provider, error) {
+		return NewConsulProvider(cfg.Consul)
+	})
+	registerProvider("dns", func(cfg config.DiscoveryConfig) (Provider, error) {
+		return NewDNSProvider(cfg.DNS)
+	})
+	registerProvider("docker", func(cfg config.DiscoveryConfig) (Provider, error) {
+		return NewDockerProvider(cfg.Docker)
+	})
+}
+
+// Register регистрирует тип источника обнаружения. Тип становится допустимым
+// в секции discovery конфигурации, его настройки передаются в params.
+func Register(typ string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[typ] = factory
+	config.RegisterDiscoveryType(typ)
+}
+
+// registerProvider регистрирует тип источника, реализованный как Provider
+func registerProvider(typ string, newProvider func(cfg config.DiscoveryConfig) (Provider, error)) {
+	Register(typ, func(cfg config.DiscoveryConfig, appLogger logger.Logger) (Discovery, error) {
+		provider, err := newProvider(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return FromProvider(provider, cfg, appLogger), nil
+	})
+}
+
+// New создает источник обнаружения зарегистрированного типа
+func New(cfg config.DiscoveryConfig, appLogger logger.Logger) (Discovery, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.Type]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported discovery type: %s", cfg.Type)
+	}
+	return factory(cfg, appLogger)
+}
+
+// providerDiscovery адаптер Provider к Discovery
+type providerDiscovery struct {
+	provider Provider
+	cfg      config.DiscoveryConfig
+	logger   logger.Logger
+}
+
+// FromProvider создает Discovery из провайдера. Экземпляры преобразуются в бэкенды
+// с параметрами подключения из cfg. После ошибок провайдер перезапускается
+// с экспоненциальной задержкой, ранее обнаруженные бэкенды при этом сохраняются.
+func FromProvider(provider Provider, cfg config.DiscoveryConfig, appLogger logger.Logger) Discovery {
+	return &providerDiscovery{provider: provider, cfg: cfg, logger: appLogger}
+}
+
+// Name возвращает описание источника
+func (d *providerDiscovery) Name() string {
+	return d.provider.Name()
+}
+
+// Watch запускает провайдер и передает обнаруженные бэкенды в канал
+func (d *providerDiscovery) Watch(ctx context.Context) <-chan []config.BackendConfig {
+	out := make(chan []config.BackendConfig, 1)
+
+	go func() {
+		defer close(out)
+
+		backoff := time.Second
+		for {
+			err := d.provider.Watch(ctx, func(targets []Target) {
+				publish(out, d.backends(targets))
+				backoff = time.Second
+			})
+			if ctx.Err() != nil {
+				return
+			}
+
+			d.logger.Warn(fmt.Sprintf("Ошибка обнаружения бэкендов (%s): %v, повтор через %v", d.provider.Name(), err, backoff))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}()
+
+	return out
+}
+
+// backends собирает конфигурацию бэкендов из обнаруженных экземпляров и настроек источника
+func (d *providerDiscovery) backends(targets []Target) []config.BackendConfig {
+	backends := make([]config.BackendConfig, 0, len(targets))
+	for _, target := range targets {
+		weight := 1.0
+		switch {
+		case target.Weight > 0:
+			weight = target.Weight
+		case d.cfg.Weight != nil:
+			weight = *d.cfg.Weight
+		}
+
+		backends = append(backends, config.BackendConfig{
+			ID:             target.ID,
+			URL:            target.URL,
+			Weight:         &weight,
+			Zone:           target.Zone,
+			ConnectTimeout: d.cfg.ConnectTimeout,
+			ReadTimeout:    d.cfg.ReadTimeout,
+			MaxConnections: d.cfg.MaxConnections,
+
+			AdaptiveConcurrency: d.cfg.AdaptiveConcurrency,
+			Protocol:            d.cfg.Protocol,
+			HealthCheck:         d.cfg.HealthCheck,
+		})
+	}
+	return backends
+}
@@ -0,0 +1,120 @@
+package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDistribution(t *testing.T) {
+	t.Run("RoundRobin", func(t *testing.T) {
+		backends := NewBackends(t, 3)
+		h := Start(t, Config("RoundRobin", backends, ""))
+
+		counts := h.Distribution("/", 30)
+		for _, b := range backends {
+			if counts[b.ID] != 10 {
+				t.Errorf("запросы должны распределяться поровну: %v", counts)
+				break
+			}
+		}
+	})
+
+	t.Run("WeightedRoundRobin", func(t *testing.T) {
+		backends := NewBackends(t, 2)
+		backends[0].Weight, backends[1].Weight = 3, 1
+		h := Start(t, Config("WeightedRoundRobin", backends, ""))
+
+		// Доли весов выдерживаются на цикле из 1000 запросов
+		counts := h.Distribution("/", 1000)
+		if counts["backend1"] < 740 || counts["backend1"] > 760 || counts["backend1"]+counts["backend2"] != 1000 {
+			t.Errorf("запросы должны распределяться пропорционально весам 3:1: %v", counts)
+		}
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	backends := NewBackends(t, 1)
+	h := Start(t, Config("RoundRobin", backends, `
+rateLimiter:
+  enabled: true
+  type: TokenBucket
+  tokenBucket:
+    rate: 0.001
+    burst: 3
+`))
+
+	for i := 0; i < 3; i++ {
+		if status, _ := h.Get("/"); status != http.StatusOK {
+			t.Fatalf("запрос %d в пределах burst должен пройти, получен %d", i+1, status)
+		}
+	}
+	if status, _ := h.Get("/"); status != http.StatusTooManyRequests {
+		t.Errorf("запрос сверх burst должен получить 429, получен %d", status)
+	}
+	if backends[0].Requests() != 3 {
+		t.Errorf("отклоненные запросы не должны доходить до бэкенда, получено %d", backends[0].Requests())
+	}
+}
+
+func TestHealthCheckEjection(t *testing.T) {
+	backends := NewBackends(t, 3)
+	h := Start(t, Config("RoundRobin", backends, `
+healthCheck:
+  interval: 50ms
+  timeout: 40ms
+  path: /health
+`))
+
+	backends[1].SetHealthy(false)
+	if !Eventually(waitTimeout, func() bool { return !h.Alive("backend2") }) {
+		t.Fatal("бэкенд с неуспешными проверками должен быть исключен")
+	}
+	if counts := h.Distribution("/", 20); counts["backend2"] != 0 || counts["backend1"] != 10 || counts["backend3"] != 10 {
+		t.Errorf("исключенный бэкенд не должен получать запросы: %v", counts)
+	}
+
+	backends[1].SetHealthy(true)
+	if !Eventually(waitTimeout, func() bool { return h.Alive("backend2") }) {
+		t.Fatal("бэкенд должен вернуться после успешной проверки")
+	}
+	if counts := h.Distribution("/", 30); counts["backend2"] != 10 {
+		t.Errorf("вернувшийся бэкенд должен снова получать запросы: %v", counts)
+	}
+}
+
+func TestHotReload(t *testing.T) {
+	backends := NewBackends(t, 3)
+	h := Start(t, Config("WeightedRoundRobin", backends[:2], ""))
+
+	if counts := h.Distribution("/", 10); counts["backend3"] != 0 {
+		t.Fatalf("до перезагрузки запросы должны получать только backend1 и backend2: %v", counts)
+	}
+
+	// Бэкенд заменяется, метод балансировки меняется, включается rate limit
+	h.Reload(Config("RoundRobin", backends[1:], `
+rateLimiter:
+  enabled: true
+  type: TokenBucket
+  tokenBucket:
+    rate: 0.001
+    burst: 20
+`))
+	if method := h.App.Config().LoadBalancer.Method; method != "RoundRobin" {
+		t.Errorf("метод балансировки должен смениться, действует %s", method)
+	}
+	if counts := h.Distribution("/", 20); counts["backend1"] != 0 || counts["backend2"] != 10 || counts["backend3"] != 10 {
+		t.Errorf("после перезагрузки запросы должны получать только новые бэкенды: %v", counts)
+	}
+	if status, _ := h.Get("/"); status != http.StatusTooManyRequests {
+		t.Errorf("после перезагрузки должен действовать rate limit, получен %d", status)
+	}
+
+	// Ошибочная конфигурация не применяется, прокси продолжает работать по прежней
+	previous := h.App.Config()
+	h.writeConfig("loadBalancer: [")
+	time.Sleep(300 * time.Millisecond)
+	if h.App.Config() != previous || h.App.ConfigError() == nil {
+		t.Error("ошибочная конфигурация не должна применяться")
+	}
+}
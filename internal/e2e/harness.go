@@ -0,0 +1,247 @@
+// Package e2e запускает приложение целиком в процессе теста: с конфигурацией из временного
+// файла, listener'ом на свободном порту и бэкендами на httptest серверах. Используется
+// интеграционными тестами балансировки, rate limit, проверок здоровья и горячей перезагрузки.
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.ru_test/app"
+)
+
+// Параметры ожидания состояния приложения
+const (
+	waitTimeout  = 5 * time.Second
+	pollInterval = 10 * time.Millisecond
+)
+
+// Backend бэкенд на httptest сервере, считающий полученные запросы. Отвечает 200 с
+// идентификатором в теле и заголовке X-Backend; здоровье и статус ответов меняются тестом.
+type Backend struct {
+	ID  string
+	URL string
+
+	// Вес бэкенда в конфигурации; 0 — вес не указывается
+	Weight float64
+
+	server   *httptest.Server
+	requests atomic.Int64
+	status   atomic.Int32
+	healthy  atomic.Bool
+}
+
+// NewBackends запускает n бэкендов с идентификаторами backend1 … backendN.
+// Серверы останавливаются по завершении теста.
+func NewBackends(t testing.TB, n int) []*Backend {
+	t.Helper()
+	backends := make([]*Backend, n)
+	for i := range backends {
+		b := &Backend{ID: fmt.Sprintf("backend%d", i+1)}
+		b.status.Store(http.StatusOK)
+		b.healthy.Store(true)
+		b.server = httptest.NewServer(http.HandlerFunc(b.serveHTTP))
+		b.URL = b.server.URL
+		t.Cleanup(b.server.Close)
+		backends[i] = b
+	}
+	return backends
+}
+
+func (b *Backend) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/health" {
+		if !b.healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		return
+	}
+
+	b.requests.Add(1)
+	w.Header().Set("X-Backend", b.ID)
+	w.WriteHeader(int(b.status.Load()))
+	io.WriteString(w, b.ID)
+}
+
+// Requests возвращает количество запросов, полученных бэкендом (без проверок здоровья)
+func (b *Backend) Requests() int64 {
+	return b.requests.Load()
+}
+
+// SetHealthy задает ответ бэкенда на проверку здоровья /health: 200 или 503
+func (b *Backend) SetHealthy(healthy bool) {
+	b.healthy.Store(healthy)
+}
+
+// SetStatus задает статус ответов бэкенда на запросы
+func (b *Backend) SetStatus(status int) {
+	b.status.Store(int32(status))
+}
+
+// Config возвращает конфигурацию с методом балансировки method, бэкендами backends
+// и дополнительными секциями extra в формате YAML
+func Config(method string, backends []*Backend, extra string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "loadBalancer:\n  method: %s\n", method)
+	sb.WriteString("logger:\n  logLevel: error\n  serviceName: e2e\n")
+	sb.WriteString("backends:\n")
+	for _, b := range backends {
+		fmt.Fprintf(&sb, "  - id: %s\n    url: %s\n", b.ID, b.URL)
+		if b.Weight > 0 {
+			fmt.Fprintf(&sb, "    weight: %g\n", b.Weight)
+		}
+	}
+	sb.WriteString(extra)
+	return sb.String()
+}
+
+// Harness приложение, запущенное в процессе теста
+type Harness struct {
+	App *app.App
+
+	// Адрес прокси, например http://127.0.0.1:41234
+	URL string
+
+	t          testing.TB
+	configPath string
+	client     *http.Client
+}
+
+// Start запускает приложение с конфигурацией cfg и дожидается ее применения.
+// Приложение останавливается по завершении теста.
+func Start(t testing.TB, cfg string) *Harness {
+	t.Helper()
+
+	// Файл логов по умолчанию logs/app.log создается во временном каталоге теста
+	t.Chdir(t.TempDir())
+
+	h := &Harness{
+		t:          t,
+		configPath: filepath.Join(t.TempDir(), "config.yaml"),
+		client:     &http.Client{Timeout: waitTimeout},
+	}
+	h.writeConfig(cfg)
+
+	a, err := app.NewApp(h.configPath, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+	h.App = a
+
+	// Start возвращается, когда конфигурация применена
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	if err := a.Start(ctx); err != nil {
+		t.Fatalf("failed to start app: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+		defer cancel()
+		if err := a.Stop(ctx); err != nil {
+			t.Errorf("failed to shut down app: %v", err)
+		}
+	})
+
+	h.URL = "http://" + a.Addresses()[0]
+	return h
+}
+
+// Reload записывает в файл новую конфигурацию и дожидается ее применения
+// отслеживанием изменений файла
+func (h *Harness) Reload(cfg string) {
+	h.t.Helper()
+
+	previous := h.App.Config()
+	h.writeConfig(cfg)
+	if !Eventually(waitTimeout, func() bool { return h.App.Config() != previous }) {
+		h.t.Fatalf("config was not reloaded: %v", h.App.ConfigError())
+	}
+}
+
+// writeConfig атомарно заменяет файл конфигурации, чтобы отслеживание изменений
+// не прочитало файл частично записанным
+func (h *Harness) writeConfig(cfg string) {
+	h.t.Helper()
+
+	tmp := h.configPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(cfg), 0o600); err != nil {
+		h.t.Fatal(err)
+	}
+	if err := os.Rename(tmp, h.configPath); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// Alive возвращает состояние бэкенда id в балансировщике приложения
+func (h *Harness) Alive(id string) bool {
+	for _, state := range h.App.LoadBalancer().GetBackends() {
+		if state.Backend.ID() == id {
+			return state.Backend.IsAlive()
+		}
+	}
+	return false
+}
+
+// Do отправляет запрос в прокси и возвращает статус и тело ответа
+func (h *Harness) Do(req *http.Request) (int, string) {
+	h.t.Helper()
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		h.t.Fatalf("%s %s: %v", req.Method, req.URL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatalf("%s %s: %v", req.Method, req.URL, err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// Get отправляет в прокси запрос GET path
+func (h *Harness) Get(path string) (int, string) {
+	h.t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, h.URL+path, nil)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return h.Do(req)
+}
+
+// Distribution отправляет n запросов GET path и возвращает количество ответов каждого бэкенда
+func (h *Harness) Distribution(path string, n int) map[string]int {
+	h.t.Helper()
+
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		status, body := h.Get(path)
+		if status != http.StatusOK {
+			h.t.Fatalf("GET %s: unexpected status %d: %s", path, status, body)
+		}
+		counts[body]++
+	}
+	return counts
+}
+
+// Eventually проверяет условие, пока оно не выполнится или не истечет timeout
+func Eventually(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if cond() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(pollInterval)
+	}
+}
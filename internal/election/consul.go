@@ -0,0 +1,127 @@
+package election
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/consulclient"
+)
+
+// consulLock блокировка на ключе Consul KV, захваченная сессией экземпляра. Когда лидер
+// перестает продлевать сессию, Consul аннулирует ее и удаляет ключ.
+type consulLock struct {
+	key      string
+	identity string
+	ttl      time.Duration
+	client   *consulclient.Client
+
+	// Текущая сессия экземпляра; пустая — сессия не создана или аннулирована
+	session string
+}
+
+// newConsulLock создает блокировку на ключе Consul KV
+func newConsulLock(cfg *config.ConsulLockConfig, key, identity string, leaseDuration time.Duration) (*consulLock, error) {
+	var address, token string
+	if cfg != nil {
+		address, token = cfg.Address, cfg.Token
+	}
+	return &consulLock{
+		key:      strings.TrimPrefix(key, "/"),
+		identity: identity,
+		ttl:      leaseDuration,
+		client:   consulclient.New(address, token),
+	}, nil
+}
+
+// Acquire продлевает сессию экземпляра, при необходимости создавая новую, и захватывает
+// ею ключ. Повторный захват ключа той же сессией успешен.
+func (l *consulLock) Acquire(ctx context.Context) (bool, error) {
+	if l.session != "" {
+		status, err := l.put(ctx, "/v1/session/renew/"+l.session, nil, nil)
+		if err != nil {
+			return false, err
+		}
+		if status == http.StatusNotFound {
+			// Сессия аннулирована, ключ освобожден вместе с ней
+			l.session = ""
+		}
+	}
+	if l.session == "" {
+		request := map[string]string{
+			"Name":      "lb-ha " + l.identity,
+			"TTL":       l.ttl.String(),
+			"Behavior":  "delete",
+			"LockDelay": "0s",
+		}
+		var created struct {
+			ID string `json:"ID"`
+		}
+		if _, err := l.put(ctx, "/v1/session/create", request, &created); err != nil {
+			return false, err
+		}
+		if created.ID == "" {
+			return false, fmt.Errorf("consul did not create a session")
+		}
+		l.session = created.ID
+	}
+
+	var acquired bool
+	path := "/v1/kv/" + l.key + "?acquire=" + url.QueryEscape(l.session)
+	if _, err := l.put(ctx, path, []byte(l.identity), &acquired); err != nil {
+		return false, err
+	}
+	return acquired, nil
+}
+
+// Release аннулирует сессию экземпляра; ключ удаляется вместе с ней
+func (l *consulLock) Release(ctx context.Context) error {
+	if l.session == "" {
+		return nil
+	}
+	_, err := l.put(ctx, "/v1/session/destroy/"+l.session, nil, nil)
+	l.session = ""
+	return err
+}
+
+// put отправляет PUT запрос к агенту Consul. Тело []byte передается как есть, остальные
+// значения кодируются в JSON. Ответ 404 не считается ошибкой и возвращается в status.
+func (l *consulLock) put(ctx context.Context, path string, body, result interface{}) (int, error) {
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(body)
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode consul request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	resp, err := l.client.Do(ctx, http.MethodPut, path, reader)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return resp.StatusCode, nil
+	case resp.StatusCode != http.StatusOK:
+		return resp.StatusCode, consulclient.StatusError(resp)
+	case result != nil:
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return resp.StatusCode, fmt.Errorf("error decoding consul response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
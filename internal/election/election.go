@@ -0,0 +1,248 @@
+// Package election выбирает лидера среди экземпляров прокси, работающих парой
+// активный/резервный, с помощью блокировки в etcd, Consul или Kubernetes
+package election
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// defaultLeaseDuration время жизни блокировки по умолчанию
+const defaultLeaseDuration = 15 * time.Second
+
+// Метрики выбора лидера
+var (
+	leaderGauge  = metrics.Default.Gauge("lb_ha_leader", "Whether this instance is the HA leader (1) or standby (0)")
+	transitions  = metrics.Default.Counter("lb_ha_transitions_total", "HA leadership changes of this instance", "state")
+	lockFailures = metrics.Default.Counter("lb_ha_lock_errors_total", "Failed attempts to acquire or renew the HA lock", "backend")
+)
+
+// Lock блокировка во внешнем хранилище, которой владеет не более одного экземпляра
+type Lock interface {
+	// Acquire захватывает свободную блокировку или продлевает уже захваченную.
+	// Возвращает true, если блокировка принадлежит экземпляру.
+	Acquire(ctx context.Context) (bool, error)
+
+	// Release освобождает блокировку, если она принадлежит экземпляру
+	Release(ctx context.Context) error
+}
+
+// Callbacks действия при смене лидерства
+type Callbacks struct {
+	// OnElected вызывается, когда экземпляр стал лидером. Ошибка означает, что экземпляр
+	// не может работать лидером: блокировка освобождается для другого экземпляра.
+	OnElected func() error
+
+	// OnDemoted вызывается, когда экземпляр перестал быть лидером
+	OnDemoted func()
+}
+
+// Elector периодически захватывает или продлевает блокировку и сообщает о смене лидерства.
+// Лидер, которому не удается продлить блокировку, слагает полномочия до истечения ее
+// срока, чтобы два экземпляра не работали лидерами одновременно.
+type Elector struct {
+	lock          Lock
+	backend       string
+	identity      string
+	leaseDuration time.Duration
+	renewInterval time.Duration
+	onElected     []string
+	onDemoted     []string
+	callbacks     Callbacks
+	logger        logger.Logger
+
+	leader atomic.Bool
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New создает участника выборов с блокировкой в хранилище из настроек
+func New(cfg *config.HAConfig, callbacks Callbacks, appLogger logger.Logger) (*Elector, error) {
+	e := &Elector{
+		backend:       cfg.Backend,
+		identity:      cfg.Identity,
+		leaseDuration: cfg.LeaseDuration,
+		renewInterval: cfg.RenewInterval,
+		onElected:     cfg.OnElected,
+		onDemoted:     cfg.OnDemoted,
+		callbacks:     callbacks,
+		logger:        appLogger,
+	}
+	if e.identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("election: failed to get hostname: %w", err)
+		}
+		e.identity = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if e.leaseDuration == 0 {
+		e.leaseDuration = defaultLeaseDuration
+	}
+	if e.renewInterval == 0 {
+		e.renewInterval = e.leaseDuration / 5
+	}
+
+	var err error
+	switch cfg.Backend {
+	case "etcd":
+		e.lock, err = newEtcdLock(cfg.Etcd, cfg.Name, e.identity, e.leaseDuration)
+	case "consul":
+		e.lock, err = newConsulLock(cfg.Consul, cfg.Name, e.identity, e.leaseDuration)
+	case "kubernetes":
+		e.lock, err = newKubernetesLock(cfg.Kubernetes, cfg.Name, e.identity, e.leaseDuration)
+	default:
+		err = fmt.Errorf("unsupported backend %q", cfg.Backend)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("election: %w", err)
+	}
+	return e, nil
+}
+
+// Identity возвращает идентификатор экземпляра в блокировке
+func (e *Elector) Identity() string {
+	return e.identity
+}
+
+// IsLeader сообщает, является ли экземпляр лидером
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Start запускает участие в выборах в отдельной горутине
+func (e *Elector) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.done = make(chan struct{})
+	leaderGauge.Set(0)
+	go e.run(ctx)
+}
+
+// Stop прекращает участие в выборах. Лидер слагает полномочия и освобождает блокировку,
+// чтобы резервный экземпляр захватил ее, не дожидаясь истечения срока.
+func (e *Elector) Stop(ctx context.Context) error {
+	e.cancel()
+	<-e.done
+
+	if e.leader.Load() {
+		e.demote("экземпляр останавливается")
+	}
+	if err := e.lock.Release(ctx); err != nil {
+		return fmt.Errorf("election: failed to release lock: %w", err)
+	}
+	return nil
+}
+
+func (e *Elector) run(ctx context.Context) {
+	defer close(e.done)
+
+	// Время последнего успешного продления и время, до которого экземпляр не претендует
+	// на лидерство после отказа от него
+	var renewed, resignedUntil time.Time
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+		timer.Reset(e.renewInterval)
+		if time.Now().Before(resignedUntil) {
+			continue
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, e.renewInterval)
+		held, err := e.lock.Acquire(attemptCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		switch {
+		case err != nil:
+			lockFailures.Inc(e.backend)
+			e.logger.Warn(fmt.Sprintf("Ошибка блокировки HA в %s: %v", e.backend, err))
+			// Следующая попытка может завершиться уже после истечения срока блокировки
+			if e.leader.Load() && time.Since(renewed)+2*e.renewInterval >= e.leaseDuration {
+				e.demote("не удалось продлить блокировку")
+			}
+		case held:
+			renewed = time.Now()
+			if !e.leader.Load() && !e.elect() {
+				if err := e.lock.Release(ctx); err != nil {
+					e.logger.Warn(fmt.Sprintf("Не удалось освободить блокировку HA: %v", err))
+				}
+				resignedUntil = time.Now().Add(e.leaseDuration)
+			}
+		case e.leader.Load():
+			e.demote("блокировку захватил другой экземпляр")
+		}
+	}
+}
+
+// elect делает экземпляр лидером. Если лидер не может приступить к работе,
+// он сразу слагает полномочия и elect возвращает false.
+func (e *Elector) elect() bool {
+	e.leader.Store(true)
+	leaderGauge.Set(1)
+	transitions.Inc("leader")
+	e.logger.Info(fmt.Sprintf("Экземпляр %s стал лидером HA", e.identity))
+
+	if e.callbacks.OnElected != nil {
+		if err := e.callbacks.OnElected(); err != nil {
+			e.logger.Error(fmt.Sprintf("Лидер не может приступить к работе: %v", err))
+			e.demote("ошибка запуска лидера")
+			return false
+		}
+	}
+	if err := e.runCommand(e.onElected); err != nil {
+		e.logger.Error(fmt.Sprintf("Ошибка команды onElected: %v", err))
+		e.demote("ошибка команды onElected")
+		return false
+	}
+	return true
+}
+
+// demote переводит лидера в резерв
+func (e *Elector) demote(reason string) {
+	e.leader.Store(false)
+	leaderGauge.Set(0)
+	transitions.Inc("follower")
+	e.logger.Warn(fmt.Sprintf("Экземпляр %s больше не лидер HA: %s", e.identity, reason))
+
+	if e.callbacks.OnDemoted != nil {
+		e.callbacks.OnDemoted()
+	}
+	if err := e.runCommand(e.onDemoted); err != nil {
+		e.logger.Error(fmt.Sprintf("Ошибка команды onDemoted: %v", err))
+	}
+}
+
+// runCommand выполняет команду смены лидерства. В окружении команды LB_HA_IDENTITY —
+// идентификатор экземпляра, LB_HA_LEADER — true или false.
+func (e *Elector) runCommand(command []string) error {
+	if len(command) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.leaseDuration)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), "LB_HA_IDENTITY="+e.identity, fmt.Sprintf("LB_HA_LEADER=%t", e.leader.Load()))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", command[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,370 @@
+package election
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+// fakeLock блокировка, результат захвата которой задает тест
+type fakeLock struct {
+	mu       sync.Mutex
+	held     bool
+	err      error
+	released int
+}
+
+func (l *fakeLock) Acquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held, l.err
+}
+
+func (l *fakeLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.released++
+	return nil
+}
+
+func (l *fakeLock) set(held bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.held, l.err = held, err
+}
+
+// eventually ждет выполнения условия
+func eventually(t *testing.T, condition func() bool, message string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestElector(t *testing.T) {
+	lock := &fakeLock{held: true}
+	var elected, demoted atomic.Int32
+	e := &Elector{
+		lock:          lock,
+		backend:       "fake",
+		identity:      "lb-1",
+		leaseDuration: 100 * time.Millisecond,
+		renewInterval: 10 * time.Millisecond,
+		callbacks: Callbacks{
+			OnElected: func() error { elected.Add(1); return nil },
+			OnDemoted: func() { demoted.Add(1) },
+		},
+		logger: logger.NewNop(),
+	}
+	e.Start()
+
+	eventually(t, e.IsLeader, "экземпляр должен стать лидером")
+
+	// Блокировку захватил другой экземпляр
+	lock.set(false, nil)
+	eventually(t, func() bool { return !e.IsLeader() }, "лидер должен сложить полномочия")
+
+	// Лидер, не сумевший продлить блокировку, слагает полномочия до истечения ее срока
+	lock.set(true, nil)
+	eventually(t, e.IsLeader, "экземпляр должен снова стать лидером")
+	lock.set(false, errors.New("etcd unavailable"))
+	eventually(t, func() bool { return !e.IsLeader() }, "лидер без продления должен сложить полномочия")
+
+	lock.set(true, nil)
+	eventually(t, e.IsLeader, "экземпляр должен снова стать лидером")
+	if err := e.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if e.IsLeader() || elected.Load() != 3 || demoted.Load() != 3 || lock.released != 1 {
+		t.Errorf("неверные переходы: избран %d, смещен %d, освобождений %d", elected.Load(), demoted.Load(), lock.released)
+	}
+}
+
+func TestElector_ElectedFailure(t *testing.T) {
+	lock := &fakeLock{held: true}
+	var attempts atomic.Int32
+	e := &Elector{
+		lock:          lock,
+		backend:       "fake",
+		leaseDuration: time.Hour,
+		renewInterval: 10 * time.Millisecond,
+		callbacks: Callbacks{
+			OnElected: func() error { attempts.Add(1); return errors.New("address in use") },
+		},
+		logger: logger.NewNop(),
+	}
+	e.Start()
+	eventually(t, func() bool { return attempts.Load() == 1 }, "лидер должен попытаться приступить к работе")
+	time.Sleep(50 * time.Millisecond)
+	e.Stop(context.Background())
+
+	// После отказа экземпляр освобождает блокировку и не претендует на нее до истечения срока
+	if e.IsLeader() || attempts.Load() != 1 || lock.released != 2 {
+		t.Errorf("экземпляр должен отказаться от лидерства: попыток %d, освобождений %d", attempts.Load(), lock.released)
+	}
+}
+
+func TestKubernetesLock(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		stored  *lease
+		version int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("токен не передан")
+		}
+		const collection = "/apis/coordination.k8s.io/v1/namespaces/lb/leases"
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == collection+"/lb-leader":
+			if stored == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(stored)
+		case r.Method == http.MethodPost && r.URL.Path == collection:
+			if stored != nil {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			stored = &lease{}
+			json.NewDecoder(r.Body).Decode(stored)
+			version++
+			stored.Metadata.ResourceVersion = fmt.Sprint(version)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut && r.URL.Path == collection+"/lb-leader":
+			var updated lease
+			json.NewDecoder(r.Body).Decode(&updated)
+			if updated.Metadata.ResourceVersion != stored.Metadata.ResourceVersion {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			version++
+			updated.Metadata.ResourceVersion = fmt.Sprint(version)
+			stored = &updated
+		default:
+			t.Errorf("неожиданный запрос %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	tokenFile := t.TempDir() + "/token"
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	newLock := func(identity string) *kubernetesLock {
+		l, err := newKubernetesLock(&config.KubernetesLockConfig{Namespace: "lb", APIServer: server.URL, TokenFile: tokenFile}, "lb-leader", identity, 15*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+	a, b := newLock("lb-a"), newLock("lb-b")
+	ctx := context.Background()
+
+	acquire := func(l *kubernetesLock, want bool) {
+		t.Helper()
+		if held, err := l.Acquire(ctx); err != nil || held != want {
+			t.Fatalf("%s: захват %t (%v), ожидалось %t", l.identity, held, err, want)
+		}
+	}
+	acquire(a, true)
+	acquire(b, false)
+	acquire(a, true)
+	if stored.Spec.HolderIdentity != "lb-a" || stored.Spec.LeaseDurationSeconds != 15 {
+		t.Errorf("неверное состояние Lease: %+v", stored.Spec)
+	}
+
+	// Лидер перестал продлевать блокировку: резервный экземпляр захватывает ее, когда
+	// с последнего увиденного продления проходит срок блокировки
+	acquire(b, false)
+	b.observedAt = b.observedAt.Add(-16 * time.Second)
+	acquire(b, true)
+	acquire(a, false)
+	if stored.Spec.HolderIdentity != "lb-b" || stored.Spec.LeaseTransitions != 1 {
+		t.Errorf("неверное состояние Lease после перехода: %+v", stored.Spec)
+	}
+
+	// Освобожденная блокировка захватывается сразу
+	if err := b.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	acquire(a, true)
+}
+
+func TestConsulLock(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		sessions = map[string]bool{}
+		holder   string
+		next     int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/v1/session/create":
+			var request map[string]string
+			json.NewDecoder(r.Body).Decode(&request)
+			if request["TTL"] != "15s" || request["Behavior"] != "delete" {
+				t.Errorf("неверные параметры сессии: %v", request)
+			}
+			next++
+			id := fmt.Sprintf("session-%d", next)
+			sessions[id] = true
+			fmt.Fprintf(w, `{"ID": %q}`, id)
+		case strings.HasPrefix(r.URL.Path, "/v1/session/renew/"):
+			if !sessions[strings.TrimPrefix(r.URL.Path, "/v1/session/renew/")] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case strings.HasPrefix(r.URL.Path, "/v1/session/destroy/"):
+			id := strings.TrimPrefix(r.URL.Path, "/v1/session/destroy/")
+			delete(sessions, id)
+			if holder == id {
+				holder = ""
+			}
+		case r.URL.Path == "/v1/kv/ha/lb-leader":
+			session := r.URL.Query().Get("acquire")
+			if holder == "" {
+				holder = session
+			}
+			fmt.Fprint(w, holder == session)
+		default:
+			t.Errorf("неожиданный запрос %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	a, _ := newConsulLock(&config.ConsulLockConfig{Address: server.URL}, "ha/lb-leader", "lb-a", 15*time.Second)
+	b, _ := newConsulLock(&config.ConsulLockConfig{Address: server.URL}, "ha/lb-leader", "lb-b", 15*time.Second)
+	ctx := context.Background()
+
+	for _, step := range []struct {
+		lock *consulLock
+		want bool
+	}{{a, true}, {b, false}, {a, true}} {
+		if held, err := step.lock.Acquire(ctx); err != nil || held != step.want {
+			t.Fatalf("%s: захват %t (%v), ожидалось %t", step.lock.identity, held, err, step.want)
+		}
+	}
+
+	// Сессию лидера аннулировал Consul: лидер создает новую и захватывает ключ заново
+	mu.Lock()
+	delete(sessions, a.session)
+	holder = ""
+	mu.Unlock()
+	if held, err := a.Acquire(ctx); err != nil || !held || a.session != "session-3" {
+		t.Fatalf("лидер должен захватить ключ новой сессией: %t (%v), сессия %s", held, err, a.session)
+	}
+
+	if err := a.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := b.Acquire(ctx); err != nil || !held {
+		t.Errorf("освобожденный ключ должен захватываться: %t (%v)", held, err)
+	}
+}
+
+func TestEtcdLock(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		leases = map[string]bool{}
+		owner  string
+		next   int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var request map[string]json.RawMessage
+		json.NewDecoder(r.Body).Decode(&request)
+		var id string
+		json.Unmarshal(request["ID"], &id)
+
+		switch r.URL.Path {
+		case "/v3/lease/grant":
+			next++
+			id = fmt.Sprint(next)
+			leases[id] = true
+			fmt.Fprintf(w, `{"ID": %q, "TTL": "15"}`, id)
+		case "/v3/lease/keepalive":
+			if leases[id] {
+				fmt.Fprintf(w, `{"result": {"ID": %q, "TTL": "15"}}`, id)
+			} else {
+				fmt.Fprintf(w, `{"result": {"ID": %q}}`, id)
+			}
+		case "/v3/lease/revoke":
+			delete(leases, id)
+			if owner == id {
+				owner = ""
+			}
+		case "/v3/kv/txn":
+			var txn struct {
+				Success []struct {
+					RequestPut struct {
+						Lease string `json:"lease"`
+					} `json:"request_put"`
+				} `json:"success"`
+			}
+			json.Unmarshal(request["success"], &txn.Success)
+			if owner == "" {
+				owner = txn.Success[0].RequestPut.Lease
+				fmt.Fprint(w, `{"succeeded": true}`)
+				return
+			}
+			fmt.Fprintf(w, `{"responses": [{"response_range": {"kvs": [{"lease": %q}]}}]}`, owner)
+		default:
+			t.Errorf("неожиданный запрос %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	a, _ := newEtcdLock(&config.EtcdLockConfig{Endpoint: server.URL}, "/lb/leader", "lb-a", 15*time.Second)
+	b, _ := newEtcdLock(&config.EtcdLockConfig{Endpoint: server.URL}, "/lb/leader", "lb-b", 15*time.Second)
+	ctx := context.Background()
+
+	for _, step := range []struct {
+		lock *etcdLock
+		want bool
+	}{{a, true}, {b, false}, {a, true}} {
+		if held, err := step.lock.Acquire(ctx); err != nil || held != step.want {
+			t.Fatalf("%s: захват %t (%v), ожидалось %t", step.lock.identity, held, err, step.want)
+		}
+	}
+	if a.lease != 1 {
+		t.Errorf("лидер должен продлевать свой lease, получен %d", a.lease)
+	}
+
+	// Lease лидера истек: ключ удален, резервный экземпляр захватывает блокировку
+	mu.Lock()
+	delete(leases, "1")
+	owner = ""
+	mu.Unlock()
+	if held, err := b.Acquire(ctx); err != nil || !held {
+		t.Fatalf("резервный экземпляр должен захватить блокировку: %t (%v)", held, err)
+	}
+	if held, err := a.Acquire(ctx); err != nil || held || a.lease != 3 {
+		t.Errorf("прежний лидер должен получить новый lease и не захватить блокировку: %t (%v), lease %d", held, err, a.lease)
+	}
+
+	if err := b.Release(ctx); err != nil || b.lease != 0 {
+		t.Errorf("lease должен быть отозван: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package election
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.ru_test/config"
+)
+
+// etcdLock блокировка на ключе etcd, привязанном к lease. Ключ создается, только если
+// его нет; когда лидер перестает продлевать lease, etcd удаляет ключ вместе с ним.
+// Запросы отправляются через HTTP/JSON шлюз API v3.
+type etcdLock struct {
+	endpoint string
+	key      string
+	identity string
+	ttl      int64
+	client   *http.Client
+
+	// Текущий lease экземпляра; 0 — lease не выдан или истек
+	lease int64
+}
+
+// etcdLeaseResponse ответ на запросы /v3/lease/grant и /v3/lease/keepalive
+type etcdLeaseResponse struct {
+	ID  int64 `json:"ID,string"`
+	TTL int64 `json:"TTL,string"`
+}
+
+// etcdTxnResponse ответ на запрос /v3/kv/txn
+type etcdTxnResponse struct {
+	Succeeded bool `json:"succeeded"`
+	Responses []struct {
+		ResponseRange struct {
+			Kvs []struct {
+				Lease int64 `json:"lease,string"`
+			} `json:"kvs"`
+		} `json:"response_range"`
+	} `json:"responses"`
+}
+
+// newEtcdLock создает блокировку на ключе etcd
+func newEtcdLock(cfg *config.EtcdLockConfig, key, identity string, leaseDuration time.Duration) (*etcdLock, error) {
+	if cfg == nil || cfg.Endpoint == "" {
+		return nil, fmt.Errorf("etcd endpoint is required")
+	}
+	return &etcdLock{
+		endpoint: strings.TrimRight(cfg.Endpoint, "/"),
+		key:      key,
+		identity: identity,
+		ttl:      int64(leaseDuration / time.Second),
+		client:   &http.Client{},
+	}, nil
+}
+
+// Acquire продлевает lease экземпляра, при необходимости получая новый, и создает ключ,
+// если его нет. Блокировка принадлежит экземпляру, если ключ привязан к его lease.
+func (l *etcdLock) Acquire(ctx context.Context) (bool, error) {
+	if l.lease != 0 {
+		var keepalive struct {
+			Result etcdLeaseResponse `json:"result"`
+		}
+		if err := l.post(ctx, "/v3/lease/keepalive", map[string]interface{}{"ID": strconv.FormatInt(l.lease, 10)}, &keepalive); err != nil {
+			return false, err
+		}
+		if keepalive.Result.TTL <= 0 {
+			// Lease истек, ключ удален вместе с ним
+			l.lease = 0
+		}
+	}
+	if l.lease == 0 {
+		var grant etcdLeaseResponse
+		if err := l.post(ctx, "/v3/lease/grant", map[string]interface{}{"TTL": strconv.FormatInt(l.ttl, 10)}, &grant); err != nil {
+			return false, err
+		}
+		l.lease = grant.ID
+	}
+
+	key := []byte(l.key)
+	txn := map[string]interface{}{
+		"compare": []interface{}{map[string]interface{}{
+			"key": key, "target": "CREATE", "result": "EQUAL", "create_revision": "0",
+		}},
+		"success": []interface{}{map[string]interface{}{
+			"request_put": map[string]interface{}{"key": key, "value": []byte(l.identity), "lease": strconv.FormatInt(l.lease, 10)},
+		}},
+		"failure": []interface{}{map[string]interface{}{
+			"request_range": map[string]interface{}{"key": key},
+		}},
+	}
+	var result etcdTxnResponse
+	if err := l.post(ctx, "/v3/kv/txn", txn, &result); err != nil {
+		return false, err
+	}
+	if result.Succeeded {
+		return true, nil
+	}
+	if len(result.Responses) == 0 || len(result.Responses[0].ResponseRange.Kvs) == 0 {
+		return false, nil
+	}
+	return result.Responses[0].ResponseRange.Kvs[0].Lease == l.lease, nil
+}
+
+// Release отзывает lease экземпляра; ключ блокировки удаляется вместе с ним
+func (l *etcdLock) Release(ctx context.Context) error {
+	if l.lease == 0 {
+		return nil
+	}
+	err := l.post(ctx, "/v3/lease/revoke", map[string]interface{}{"ID": strconv.FormatInt(l.lease, 10)}, nil)
+	l.lease = 0
+	return err
+}
+
+// post отправляет JSON запрос к шлюзу etcd и декодирует ответ в result
+func (l *etcdLock) post(ctx context.Context, path string, request, result interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode etcd request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create etcd request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("etcd request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("etcd returned %s: %s", resp.Status, message)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("error decoding etcd response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package election
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/k8sclient"
+)
+
+// microTimeLayout формат времени MicroTime в объектах Kubernetes
+const microTimeLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+// lease подмножество полей coordination.k8s.io/v1 Lease
+type lease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec leaseSpec `json:"spec"`
+}
+
+// leaseSpec состояние блокировки в Lease
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+}
+
+// kubernetesLock блокировка на объекте Lease. Часы экземпляров могут расходиться, поэтому
+// срок блокировки другого экземпляра отсчитывается от момента, когда этот экземпляр в
+// последний раз увидел изменение Lease, а не от записанного в нем времени продления.
+type kubernetesLock struct {
+	path     string
+	name     string
+	identity string
+	duration time.Duration
+	client   *k8sclient.Client
+
+	// Последнее увиденное состояние Lease и момент, когда оно было увидено
+	observed   leaseSpec
+	observedAt time.Time
+}
+
+// newKubernetesLock создает блокировку на Lease. Незаданные адрес API сервера, пространство
+// имен и учетные данные берутся из окружения пода.
+func newKubernetesLock(cfg *config.KubernetesLockConfig, name, identity string, leaseDuration time.Duration) (*kubernetesLock, error) {
+	if cfg == nil {
+		cfg = &config.KubernetesLockConfig{}
+	}
+	client, err := k8sclient.New(k8sclient.Config{
+		APIServer: cfg.APIServer,
+		Namespace: cfg.Namespace,
+		TokenFile: cfg.TokenFile,
+		CAFile:    cfg.CAFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &kubernetesLock{
+		path:     fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases", url.PathEscape(client.Namespace())),
+		name:     name,
+		identity: identity,
+		duration: leaseDuration,
+		client:   client,
+	}, nil
+}
+
+// Acquire создает Lease, если его нет, продлевает собственную блокировку или захватывает
+// блокировку, срок которой истек. Одновременные изменения Lease разрешаются по
+// resourceVersion: из двух экземпляров запись удается только одному.
+func (l *kubernetesLock) Acquire(ctx context.Context) (bool, error) {
+	now := time.Now()
+	current, found, err := l.get(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		created := lease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+		created.Metadata.Name = l.name
+		created.Metadata.Namespace = l.client.Namespace()
+		created.Spec = l.spec(leaseSpec{}, now)
+		return l.write(ctx, http.MethodPost, l.path, created)
+	}
+
+	if current.Spec != l.observed {
+		l.observed, l.observedAt = current.Spec, now
+	}
+	holder := current.Spec.HolderIdentity
+	expires := l.observedAt.Add(time.Duration(current.Spec.LeaseDurationSeconds) * time.Second)
+	if holder != "" && holder != l.identity && now.Before(expires) {
+		return false, nil
+	}
+
+	current.Spec = l.spec(current.Spec, now)
+	return l.write(ctx, http.MethodPut, l.path+"/"+url.PathEscape(l.name), current)
+}
+
+// spec возвращает состояние Lease, в котором блокировка принадлежит экземпляру
+func (l *kubernetesLock) spec(prev leaseSpec, now time.Time) leaseSpec {
+	spec := prev
+	if prev.HolderIdentity != l.identity {
+		spec.HolderIdentity = l.identity
+		spec.AcquireTime = now.UTC().Format(microTimeLayout)
+		if prev.HolderIdentity != "" {
+			spec.LeaseTransitions++
+		}
+	}
+	spec.RenewTime = now.UTC().Format(microTimeLayout)
+	spec.LeaseDurationSeconds = int(l.duration / time.Second)
+	return spec
+}
+
+// Release освобождает Lease, если блокировка принадлежит экземпляру
+func (l *kubernetesLock) Release(ctx context.Context) error {
+	current, found, err := l.get(ctx)
+	if err != nil || !found || current.Spec.HolderIdentity != l.identity {
+		return err
+	}
+	current.Spec.HolderIdentity = ""
+	current.Spec.LeaseDurationSeconds = 1
+	current.Spec.RenewTime = time.Now().UTC().Format(microTimeLayout)
+	_, err = l.write(ctx, http.MethodPut, l.path+"/"+url.PathEscape(l.name), current)
+	return err
+}
+
+// get читает Lease; found — false, если его нет
+func (l *kubernetesLock) get(ctx context.Context) (current lease, found bool, err error) {
+	resp, err := l.client.Do(ctx, http.MethodGet, l.path+"/"+url.PathEscape(l.name), nil)
+	if err != nil {
+		return current, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return current, false, nil
+	default:
+		return current, false, k8sclient.StatusError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&current); err != nil {
+		return current, false, fmt.Errorf("error decoding Lease: %w", err)
+	}
+	return current, true, nil
+}
+
+// write создает или обновляет Lease. Конфликт версий означает, что Lease успел изменить
+// другой экземпляр, и блокировка не захвачена.
+func (l *kubernetesLock) write(ctx context.Context, method, path string, updated lease) (bool, error) {
+	body, err := json.Marshal(updated)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode Lease: %w", err)
+	}
+	resp, err := l.client.Do(ctx, method, path, body)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		l.observed, l.observedAt = updated.Spec, time.Now()
+		return true, nil
+	case http.StatusConflict:
+		return false, nil
+	default:
+		return false, k8sclient.StatusError(resp)
+	}
+}
@@ -0,0 +1,417 @@
+// Package gossip обменивается состоянием между экземплярами прокси: сбоями бэкендов,
+// обнаруженными проверками здоровья, и числом пропущенных запросов клиентов. Экземпляры
+// быстрее исключают недоступные бэкенды и соблюдают общий для всех экземпляров rate limit.
+package gossip
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Параметры обмена
+const (
+	defaultInterval = time.Second
+
+	// path путь, на который экземпляры отправляют состояние
+	path = "/gossip"
+
+	// signatureHeader заголовок с HMAC подписью тела сообщения
+	signatureHeader = "X-LB-Gossip-Signature"
+
+	// maxMessageSize ограничение размера принимаемого сообщения
+	maxMessageSize = 4 << 20
+)
+
+// Метрики обмена состоянием
+var (
+	messages      = metrics.Default.Counter("lb_gossip_messages_total", "Gossip messages sent to and accepted from peers", "direction")
+	sendErrors    = metrics.Default.Counter("lb_gossip_send_errors_total", "Failed gossip message deliveries to peers")
+	rejected      = metrics.Default.Counter("lb_gossip_rejected_total", "Gossip messages rejected because of an invalid signature or body")
+	peersGauge    = metrics.Default.Gauge("lb_gossip_peers", "Peers gossip messages are sent to")
+	backendsDown  = metrics.Default.Counter("lb_gossip_backends_marked_down_total", "Backends marked down after a failure report from a peer")
+	usageReceived = metrics.Default.Counter("lb_gossip_usage_requests_total", "Requests admitted by peers and charged to local rate limits")
+)
+
+// Source подсистемы экземпляра, состоянием которых он обменивается. Балансировщик и
+// rate limiter пересоздаются при реконфигурации, поэтому запрашиваются при каждом обмене.
+type Source interface {
+	LoadBalancer() loadbalancer.LoadBalancer
+	RateLimiter() ratelimit.RateLimiter
+}
+
+// message состояние, которое экземпляр отправляет другим экземплярам
+type message struct {
+	Node string `json:"node"`
+
+	// Бэкенды, которые проверки здоровья отправителя считают недоступными, и время
+	// обнаружения сбоя по часам отправителя. Время только отличает один сбой от другого
+	// и не сравнивается с часами получателя.
+	Down map[string]time.Time `json:"down,omitempty"`
+
+	// Запросы, пропущенные отправителем с предыдущего сообщения, по ключам клиентов
+	Usage map[string]int64 `json:"usage,omitempty"`
+}
+
+// Gossip периодически рассылает состояние экземпляра другим экземплярам и применяет
+// полученное от них. Другие экземпляры сообщают только о сбоях: получатель сразу
+// исключает бэкенд, а возвращает его собственная проверка здоровья. Так бэкенд,
+// недоступный лишь с одного экземпляра, не исключается на остальных дольше одной проверки.
+type Gossip struct {
+	listen      string
+	peers       []string
+	dnsName     string
+	interval    time.Duration
+	secret      []byte
+	node        string
+	shareHealth bool
+	shareUsage  bool
+	source      Source
+	logger      logger.Logger
+	client      *http.Client
+	server      *http.Server
+	lookupHost  func(ctx context.Context, host string) ([]string, error)
+
+	mu sync.Mutex
+	// Последнее увиденное состояние бэкендов
+	alive map[string]bool
+	// Сбои, обнаруженные проверками здоровья экземпляра, и время их обнаружения
+	down map[string]time.Time
+	// Бэкенды, исключенные по сообщению другого экземпляра
+	remoteDown map[string]bool
+	// Уже примененные сбои по экземплярам: экземпляр -> бэкенд -> время обнаружения
+	applied map[string]map[string]time.Time
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// New создает обмен состоянием экземпляра по настройкам cfg
+func New(cfg *config.GossipConfig, source Source, appLogger logger.Logger) (*Gossip, error) {
+	g := &Gossip{
+		listen:      cfg.Listen,
+		peers:       cfg.Peers,
+		dnsName:     cfg.DNSName,
+		interval:    cfg.Interval,
+		secret:      []byte(cfg.Secret),
+		node:        cfg.Node,
+		shareHealth: cfg.Shares("health"),
+		shareUsage:  cfg.Shares("rateLimit"),
+		source:      source,
+		logger:      appLogger,
+		lookupHost:  net.DefaultResolver.LookupHost,
+		alive:       make(map[string]bool),
+		down:        make(map[string]time.Time),
+		remoteDown:  make(map[string]bool),
+		applied:     make(map[string]map[string]time.Time),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if g.interval == 0 {
+		g.interval = defaultInterval
+	}
+	if g.node == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("gossip: failed to get hostname: %w", err)
+		}
+		g.node = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	g.client = &http.Client{Timeout: g.interval}
+	return g, nil
+}
+
+// Node возвращает имя экземпляра в сообщениях
+func (g *Gossip) Node() string {
+	return g.node
+}
+
+// Start занимает адрес приема сообщений и запускает рассылку состояния
+func (g *Gossip) Start() error {
+	listener, err := net.Listen("tcp", g.listen)
+	if err != nil {
+		return fmt.Errorf("gossip: failed to listen on %s: %w", g.listen, err)
+	}
+	g.listen = listener.Addr().String()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, g.handle)
+	g.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := g.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			g.logger.Error(fmt.Sprintf("Ошибка приема сообщений обмена состоянием: %v", err))
+		}
+	}()
+
+	go g.run()
+	return nil
+}
+
+// Addr возвращает адрес приема сообщений
+func (g *Gossip) Addr() string {
+	return g.listen
+}
+
+// Stop останавливает рассылку и прием сообщений
+func (g *Gossip) Stop(ctx context.Context) error {
+	g.once.Do(func() { close(g.stop) })
+	<-g.done
+	if g.server == nil {
+		return nil
+	}
+	return g.server.Shutdown(ctx)
+}
+
+func (g *Gossip) run() {
+	defer close(g.done)
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.round()
+		case <-g.stop:
+			return
+		}
+	}
+}
+
+// round отправляет текущее состояние всем экземплярам
+func (g *Gossip) round() {
+	ctx, cancel := context.WithTimeout(context.Background(), g.interval)
+	defer cancel()
+
+	msg := message{Node: g.node}
+	if g.shareHealth {
+		msg.Down = g.observe()
+	}
+	if g.shareUsage {
+		if sharing, ok := g.source.RateLimiter().(ratelimit.UsageSharing); ok {
+			msg.Usage = sharing.TakeUsage()
+		}
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		g.logger.Error(fmt.Sprintf("Не удалось закодировать сообщение обмена состоянием: %v", err))
+		return
+	}
+
+	peers := g.resolvePeers(ctx)
+	peersGauge.Set(int64(len(peers)))
+	var wg sync.WaitGroup
+	for _, peer := range peers {
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+			if err := g.send(ctx, peer, body); err != nil {
+				sendErrors.Inc()
+				g.logger.Debug(fmt.Sprintf("Не удалось отправить состояние экземпляру %s: %v", peer, err))
+				return
+			}
+			messages.Inc("sent")
+		}(peer)
+	}
+	wg.Wait()
+}
+
+// observe сравнивает состояние бэкендов с предыдущим обменом и возвращает сбои, которые
+// обнаружили проверки здоровья экземпляра. Сбой, о котором сообщил другой экземпляр,
+// не пересылается дальше.
+func (g *Gossip) observe() map[string]time.Time {
+	now := time.Now()
+	lb := g.source.LoadBalancer()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	seen := make(map[string]bool)
+	if lb != nil {
+		for _, state := range lb.GetBackends() {
+			id, alive := state.Backend.ID(), state.Backend.IsAlive()
+			seen[id] = true
+			prev, known := g.alive[id]
+			g.alive[id] = alive
+
+			switch {
+			case alive:
+				delete(g.down, id)
+				delete(g.remoteDown, id)
+			case g.remoteDown[id]:
+			case !known || prev:
+				g.down[id] = now
+			}
+		}
+	}
+	for id := range g.alive {
+		if !seen[id] {
+			delete(g.alive, id)
+			delete(g.down, id)
+			delete(g.remoteDown, id)
+		}
+	}
+
+	down := make(map[string]time.Time, len(g.down))
+	for id, since := range g.down {
+		down[id] = since
+	}
+	return down
+}
+
+// resolvePeers возвращает адреса экземпляров из списка и записей DNS
+func (g *Gossip) resolvePeers(ctx context.Context) []string {
+	peers := append([]string(nil), g.peers...)
+	if g.dnsName == "" {
+		return peers
+	}
+
+	_, port, _ := net.SplitHostPort(g.listen)
+	hosts, err := g.lookupHost(ctx, g.dnsName)
+	if err != nil {
+		g.logger.Warn(fmt.Sprintf("Не удалось получить адреса экземпляров из %s: %v", g.dnsName, err))
+		return peers
+	}
+	for _, host := range hosts {
+		peers = append(peers, net.JoinHostPort(host, port))
+	}
+	return peers
+}
+
+// send отправляет сообщение экземпляру
+func (g *Gossip) send(ctx context.Context, peer string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+peer+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(g.secret) > 0 {
+		req.Header.Set(signatureHeader, g.sign(body))
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("peer returned %s", resp.Status)
+	}
+	return nil
+}
+
+// sign возвращает HMAC подпись тела сообщения
+func (g *Gossip) sign(body []byte) string {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write([]byte("lb-gossip:"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// handle принимает сообщение другого экземпляра
+func (g *Gossip) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, "failed to read message", http.StatusBadRequest)
+		return
+	}
+	if len(g.secret) > 0 && !hmac.Equal([]byte(r.Header.Get(signatureHeader)), []byte(g.sign(body))) {
+		rejected.Inc()
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil || msg.Node == "" {
+		rejected.Inc()
+		http.Error(w, "invalid message", http.StatusBadRequest)
+		return
+	}
+
+	if msg.Node != g.node {
+		messages.Inc("received")
+		g.apply(msg)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apply применяет состояние другого экземпляра. Каждый сбой применяется один раз:
+// если проверка здоровья экземпляра вернула бэкенд, повторное сообщение о том же сбое
+// его не исключает.
+func (g *Gossip) apply(msg message) {
+	if g.shareHealth {
+		g.applyDown(msg.Node, msg.Down)
+	}
+	if g.shareUsage && len(msg.Usage) > 0 {
+		if sharing, ok := g.source.RateLimiter().(ratelimit.UsageSharing); ok {
+			sharing.AddUsage(msg.Usage)
+			for _, n := range msg.Usage {
+				usageReceived.Add(n)
+			}
+		}
+	}
+}
+
+// applyDown исключает бэкенды, о сбое которых сообщил экземпляр node
+func (g *Gossip) applyDown(node string, down map[string]time.Time) {
+	lb := g.source.LoadBalancer()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	applied := g.applied[node]
+	if len(down) == 0 {
+		delete(g.applied, node)
+		return
+	}
+	if applied == nil {
+		applied = make(map[string]time.Time)
+		g.applied[node] = applied
+	}
+	for id := range applied {
+		if _, ok := down[id]; !ok {
+			delete(applied, id)
+		}
+	}
+	if lb == nil {
+		return
+	}
+
+	for _, state := range lb.GetBackends() {
+		id := state.Backend.ID()
+		since, ok := down[id]
+		if !ok || applied[id].Equal(since) {
+			continue
+		}
+		applied[id] = since
+		if !state.Backend.IsAlive() {
+			continue
+		}
+
+		state.Backend.SetAlive(false)
+		g.remoteDown[id] = true
+		backendsDown.Inc()
+		g.logger.Warn(fmt.Sprintf("Бэкенд %s исключен по сообщению экземпляра %s о сбое", id, node))
+	}
+}
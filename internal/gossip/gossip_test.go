@@ -0,0 +1,164 @@
+package gossip
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+// instance экземпляр прокси с одним бэкендом и rate limiter'ом
+type instance struct {
+	lb      loadbalancer.LoadBalancer
+	rl      *ratelimit.TokenBucket
+	backend *backend.BaseBackend
+	gossip  *Gossip
+}
+
+func (i *instance) LoadBalancer() loadbalancer.LoadBalancer { return i.lb }
+func (i *instance) RateLimiter() ratelimit.RateLimiter      { return i.rl }
+
+// newInstance запускает обмен состоянием экземпляра; рассылку тест выполняет сам
+func newInstance(t *testing.T, cfg config.GossipConfig) *instance {
+	t.Helper()
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := &instance{
+		lb:      lb,
+		rl:      ratelimit.NewTokenBucket(1, 10),
+		backend: backend.NewBackendWithOptions("b1", "http://127.0.0.1:1", 1, backend.Options{}),
+	}
+	t.Cleanup(func() { i.backend.Close() })
+	lb.AddBackend(i.backend)
+
+	cfg.Listen = "127.0.0.1:0"
+	cfg.Interval = time.Hour
+	i.gossip, err = New(&cfg, i, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.gossip.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { i.gossip.Stop(context.Background()) })
+	return i
+}
+
+// pair создает два экземпляра, отправляющих состояние друг другу
+func pair(t *testing.T, a, b config.GossipConfig) (*instance, *instance) {
+	first, second := newInstance(t, a), newInstance(t, b)
+	first.gossip.peers = []string{second.gossip.Addr()}
+	second.gossip.peers = []string{first.gossip.Addr()}
+	return first, second
+}
+
+func TestGossip_Health(t *testing.T) {
+	a, b := pair(t, config.GossipConfig{Node: "a"}, config.GossipConfig{Node: "b"})
+
+	a.gossip.round()
+	if !b.backend.IsAlive() {
+		t.Fatal("бэкенд не должен исключаться без сбоя")
+	}
+
+	// Проверка здоровья экземпляра a обнаружила сбой
+	a.backend.SetAlive(false)
+	a.gossip.round()
+	if b.backend.IsAlive() {
+		t.Fatal("бэкенд должен быть исключен по сообщению о сбое")
+	}
+
+	// Экземпляр b не пересылает чужой сбой обратно
+	b.gossip.round()
+	if msg := b.gossip.observe(); len(msg) != 0 {
+		t.Errorf("сбой другого экземпляра не должен пересылаться: %v", msg)
+	}
+
+	// Проверка здоровья экземпляра b вернула бэкенд: повторное сообщение о том же сбое его не исключает
+	b.backend.SetAlive(true)
+	a.gossip.round()
+	if !b.backend.IsAlive() {
+		t.Error("тот же сбой не должен применяться повторно")
+	}
+
+	// Новый сбой после восстановления применяется
+	a.backend.SetAlive(true)
+	a.gossip.round()
+	a.backend.SetAlive(false)
+	a.gossip.round()
+	if b.backend.IsAlive() {
+		t.Error("новый сбой должен исключать бэкенд")
+	}
+}
+
+func TestGossip_Usage(t *testing.T) {
+	a, b := pair(t, config.GossipConfig{Node: "a"}, config.GossipConfig{Node: "b"})
+
+	// Первая рассылка включает учет запросов
+	a.gossip.round()
+	for n := 0; n < 10; n++ {
+		if !a.rl.Allow("client") {
+			t.Fatalf("запрос %d должен быть пропущен", n)
+		}
+	}
+	a.gossip.round()
+
+	if b.rl.Allow("client") {
+		t.Error("лимит клиента должен быть израсходован запросами другого экземпляра")
+	}
+	if !b.rl.Allow("other") {
+		t.Error("лимит другого клиента не должен расходоваться")
+	}
+}
+
+func TestGossip_Share(t *testing.T) {
+	a, b := pair(t, config.GossipConfig{Node: "a"}, config.GossipConfig{Node: "b", Share: []string{"rateLimit"}})
+
+	a.backend.SetAlive(false)
+	a.gossip.round()
+	if !b.backend.IsAlive() {
+		t.Error("экземпляр без обмена health не должен применять сбои")
+	}
+}
+
+func TestGossip_Signature(t *testing.T) {
+	a, b := pair(t, config.GossipConfig{Node: "a", Secret: "one"}, config.GossipConfig{Node: "b", Secret: "two"})
+
+	a.backend.SetAlive(false)
+	a.gossip.round()
+	if !b.backend.IsAlive() {
+		t.Error("сообщение с неверной подписью должно отклоняться")
+	}
+
+	b.gossip.secret = []byte("one")
+	a.gossip.round()
+	if b.backend.IsAlive() {
+		t.Error("сообщение с верной подписью должно применяться")
+	}
+}
+
+func TestGossip_DNSPeers(t *testing.T) {
+	a := newInstance(t, config.GossipConfig{Node: "a", Peers: []string{"10.0.0.1:7946"}})
+	a.gossip.dnsName = "proxies.local"
+	a.gossip.listen = "0.0.0.0:7946"
+	a.gossip.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"10.0.0.2", "fd00::3"}, nil
+	}
+
+	peers := a.gossip.resolvePeers(context.Background())
+	want := []string{"10.0.0.1:7946", "10.0.0.2:7946", "[fd00::3]:7946"}
+	if len(peers) != len(want) {
+		t.Fatalf("ожидалось %v, получено %v", want, peers)
+	}
+	for n := range want {
+		if peers[n] != want[n] {
+			t.Errorf("ожидалось %v, получено %v", want, peers)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/workerpool"
+)
+
+const (
+	defaultInterval    = 10 * time.Second
+	defaultTimeout     = 2 * time.Second
+	defaultPath        = "/health"
+	defaultConcurrency = 16
+)
+
+// Checker периодически проверяет доступность бэкендов балансировщика
+type Checker struct {
+	lb       loadbalancer.LoadBalancer
+	interval time.Duration
+	path     string
+	client   *http.Client
+	logger   logger.Logger
+
+	// Проверки выполняются на пуле: при сотнях бэкендов число одновременных
+	// соединений ограничено concurrency
+	concurrency int
+	pool        *workerpool.WorkerPool
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New создает новый Checker. Незаданные параметры заменяются значениями по умолчанию.
+func New(cfg *config.HealthCheckConfig, lb loadbalancer.LoadBalancer, appLogger logger.Logger) *Checker {
+	interval, timeout, path, concurrency := defaultInterval, defaultTimeout, defaultPath, defaultConcurrency
+	if cfg != nil {
+		if cfg.Interval > 0 {
+			interval = cfg.Interval
+		}
+		if cfg.Timeout > 0 {
+			timeout = cfg.Timeout
+		}
+		if cfg.Path != "" {
+			path = cfg.Path
+		}
+		if cfg.Concurrency > 0 {
+			concurrency = cfg.Concurrency
+		}
+	}
+
+	return &Checker{
+		lb:       lb,
+		interval: interval,
+		path:     path,
+		client:   &http.Client{Timeout: timeout},
+		logger:   appLogger,
+		stopCh:   make(chan struct{}),
+
+		concurrency: concurrency,
+	}
+}
+
+// Start запускает периодические проверки в отдельной горутине
+func (c *Checker) Start() {
+	c.logger.Debug(fmt.Sprintf("Запуск health check (интервал: %v, путь: %s, одновременных проверок: %d)", c.interval, c.path, c.concurrency))
+
+	c.pool = workerpool.New(workerpool.Options{
+		Workers: c.concurrency,
+		PanicHandler: func(recovered interface{}, stack []byte) {
+			c.logger.Error(fmt.Sprintf("Паника при проверке бэкенда: %v", recovered), "stack", string(stack))
+		},
+	})
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		c.CheckAll()
+		for {
+			select {
+			case <-ticker.C:
+				c.CheckAll()
+			case <-c.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop останавливает проверки и дожидается завершения горутин
+func (c *Checker) Stop() {
+	close(c.stopCh)
+	c.wg.Wait()
+	if c.pool != nil {
+		c.pool.Shutdown(context.Background())
+	}
+	c.logger.Debug("Health check остановлен")
+}
+
+// CheckAll проверяет все бэкенды балансировщика, одновременно не более concurrency.
+// До Start проверки выполняются по очереди.
+func (c *Checker) CheckAll() {
+	var wg sync.WaitGroup
+	for _, state := range c.lb.GetBackends() {
+		b := state.Backend
+		if c.pool == nil {
+			c.update(b, c.Probe(b))
+			continue
+		}
+
+		wg.Add(1)
+		err := c.pool.Submit(context.Background(), func() {
+			defer wg.Done()
+			c.update(b, c.Probe(b))
+		})
+		if err != nil {
+			// Пул остановлен вместе с Checker
+			wg.Done()
+			break
+		}
+	}
+	wg.Wait()
+}
+
+// maxProbeBodyExcerpt максимальный размер фрагмента тела ответа в результате проверки
+const maxProbeBodyExcerpt = 512
+
+// ProbeResult подробный результат проверки бэкенда
+type ProbeResult struct {
+	// Бэкенд прошел проверку
+	Healthy bool
+
+	// URL проверки
+	URL string
+
+	// Код ответа (0, если ответ не получен)
+	StatusCode int
+
+	// Время от отправки запроса до получения тела ответа
+	Latency time.Duration
+
+	// Начало тела ответа
+	Body string
+
+	// Сведения о бэкенде из JSON тела ответа (nil, если бэкенд их не сообщает)
+	Metadata *backend.HealthMetadata
+
+	// Ошибка соединения или неуспешный код ответа
+	Err error
+}
+
+// Check выполняет одну проверку бэкенда
+func (c *Checker) Check(b backend.Backend) error {
+	return c.Probe(b).Err
+}
+
+// Probe выполняет проверку бэкенда способом из его конфигурации и возвращает подробный
+// результат. Состояние бэкенда не изменяется.
+func (c *Checker) Probe(b backend.Backend) ProbeResult {
+	ctx, cancel := context.WithTimeout(context.Background(), c.client.Timeout)
+	defer cancel()
+
+	hc, protocol := probeSettings(b)
+	switch hc.Type {
+	case "tcp":
+		return probeTCP(ctx, b, hc)
+	case "tls":
+		return probeTLS(ctx, b, hc)
+	case "grpc":
+		return probeGRPC(ctx, b, hc, protocol)
+	case "command":
+		return probeCommand(ctx, b, hc)
+	}
+
+	path := c.path
+	if hc.Path != "" {
+		path = hc.Path
+	}
+	return c.probeHTTP(ctx, b, path)
+}
+
+// probeHTTP запрашивает путь path у бэкенда. Успешным считается ответ со статусом 2xx или 3xx.
+func (c *Checker) probeHTTP(ctx context.Context, b backend.Backend, path string) ProbeResult {
+	result := ProbeResult{URL: b.URL() + path}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, backend.RequestURL(b.URL(), path), nil)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to create health check request: %w", err)
+		return result
+	}
+
+	client := c.client
+	if socket := backend.SocketPath(b.URL()); socket != "" {
+		// Соединение с сокетом бэкенда не переиспользуется между проверками
+		transport := backend.NewUnixTransport(socket, &net.Dialer{})
+		transport.DisableKeepAlives = true
+		client = &http.Client{Timeout: c.client.Timeout, Transport: transport}
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Latency = time.Since(start)
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxMetadataSize))
+	io.Copy(io.Discard, resp.Body)
+	result.Latency = time.Since(start)
+	result.StatusCode = resp.StatusCode
+	result.Body = string(body)
+	if len(body) > maxProbeBodyExcerpt {
+		result.Body = string(body[:maxProbeBodyExcerpt])
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		result.Err = fmt.Errorf("unhealthy status code: %d", resp.StatusCode)
+		return result
+	}
+
+	result.Metadata = parseMetadata(resp.Header.Get("Content-Type"), body)
+	result.Healthy = true
+	return result
+}
+
+// update обновляет состояние бэкенда по результату проверки и логирует изменения
+func (c *Checker) update(b backend.Backend, result ProbeResult) {
+	if result.Err == nil {
+		c.updateMetadata(b, result.Metadata)
+	}
+
+	alive := result.Err == nil
+	if b.IsAlive() == alive {
+		return
+	}
+	err := result.Err
+
+	b.SetAlive(alive)
+	if alive {
+		c.logger.Info(fmt.Sprintf("Бэкенд %s снова доступен", b.ID()))
+	} else {
+		c.logger.Warn(fmt.Sprintf("Бэкенд %s недоступен: %v", b.ID(), err))
+	}
+}
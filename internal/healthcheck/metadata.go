@@ -0,0 +1,71 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+
+	"cloud.ru_test/pkg/backend"
+)
+
+// maxMetadataSize максимальный размер тела ответа, из которого читаются сведения о бэкенде
+const maxMetadataSize = 64 << 10
+
+// healthResponse JSON тело ответа на проверку здоровья. Все поля необязательны:
+//
+//	{"status": "ok", "load": 0.42, "version": "1.8.0", "draining": false}
+type healthResponse struct {
+	// Состояние бэкенда; значение draining равносильно "draining": true
+	Status string `json:"status"`
+
+	// Загрузка от 0 до 1
+	Load *float64 `json:"load"`
+
+	// Версия приложения
+	Version string `json:"version"`
+
+	// Бэкенд завершает работу
+	Draining bool `json:"draining"`
+}
+
+// parseMetadata извлекает сведения о бэкенде из JSON ответа на проверку здоровья.
+// Для ответов в другом формате возвращает nil.
+func parseMetadata(contentType string, body []byte) *backend.HealthMetadata {
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "application/json" {
+		return nil
+	}
+	var health healthResponse
+	if err := json.Unmarshal(body, &health); err != nil {
+		return nil
+	}
+
+	metadata := &backend.HealthMetadata{
+		Version:  health.Version,
+		Draining: health.Draining || health.Status == "draining",
+	}
+	if health.Load != nil {
+		load := min(max(*health.Load, 0), 1)
+		metadata.Load = &load
+	}
+	return metadata
+}
+
+// updateMetadata сохраняет сведения из успешной проверки. Если бэкенд перестал
+// их сообщать, прежние сведения сбрасываются.
+func (c *Checker) updateMetadata(b backend.Backend, metadata *backend.HealthMetadata) {
+	if metadata == nil {
+		metadata = &backend.HealthMetadata{}
+	}
+	prev := b.HealthMetadata()
+	b.SetHealthMetadata(*metadata)
+
+	switch {
+	case metadata.Draining && !prev.Draining:
+		c.logger.Info(fmt.Sprintf("Бэкенд %s завершает работу и выводится из ротации", b.ID()))
+	case !metadata.Draining && prev.Draining:
+		c.logger.Info(fmt.Sprintf("Бэкенд %s возвращается в ротацию", b.ID()))
+	}
+	if metadata.Version != prev.Version && metadata.Version != "" {
+		c.logger.Info(fmt.Sprintf("Версия бэкенда %s: %s", b.ID(), metadata.Version))
+	}
+}
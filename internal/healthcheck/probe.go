@@ -0,0 +1,275 @@
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/backend"
+)
+
+// configuredBackend бэкенд со способом проверки из конфигурации. Бэкенды, созданные
+// без конфигурации (например, плагинами), проверяются HTTP запросом.
+type configuredBackend interface {
+	HealthCheck() *config.BackendHealthCheckConfig
+	Protocol() string
+}
+
+// probeSettings возвращает способ проверки бэкенда и протокол запросов к нему
+func probeSettings(b backend.Backend) (*config.BackendHealthCheckConfig, string) {
+	if cb, ok := b.(configuredBackend); ok && cb.HealthCheck() != nil {
+		return cb.HealthCheck(), cb.Protocol()
+	}
+	return &config.BackendHealthCheckConfig{}, "http"
+}
+
+// probeAddress возвращает сеть и адрес проверки tcp и tls: заданный в проверке
+// или хост и порт URL бэкенда (порт по умолчанию — по схеме)
+func probeAddress(b backend.Backend, hc *config.BackendHealthCheckConfig) (string, string, error) {
+	if hc.Address != "" {
+		return "tcp", hc.Address, nil
+	}
+	if socket := backend.SocketPath(b.URL()); socket != "" {
+		return "unix", socket, nil
+	}
+	u, err := url.Parse(b.URL())
+	if err != nil {
+		return "", "", fmt.Errorf("invalid backend url: %w", err)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return "tcp", net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// probeTCP проверяет, что бэкенд принимает соединения
+func probeTCP(ctx context.Context, b backend.Backend, hc *config.BackendHealthCheckConfig) ProbeResult {
+	network, addr, err := probeAddress(b, hc)
+	result := ProbeResult{URL: network + "://" + addr}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, addr)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	conn.Close()
+	result.Healthy = true
+	return result
+}
+
+// probeTLS проверяет, что бэкенд завершает TLS рукопожатие с действительным сертификатом
+func probeTLS(ctx context.Context, b backend.Backend, hc *config.BackendHealthCheckConfig) ProbeResult {
+	network, addr, err := probeAddress(b, hc)
+	result := ProbeResult{URL: "tls://" + addr}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	serverName := hc.ServerName
+	if serverName == "" {
+		serverName, _, _ = net.SplitHostPort(addr)
+	}
+
+	start := time.Now()
+	var dialer net.Dialer
+	raw, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		result.Latency = time.Since(start)
+		result.Err = err
+		return result
+	}
+	conn := tls.Client(raw, &tls.Config{ServerName: serverName, InsecureSkipVerify: hc.InsecureSkipVerify})
+	defer conn.Close()
+	err = conn.HandshakeContext(ctx)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Err = fmt.Errorf("tls handshake failed: %w", err)
+		return result
+	}
+
+	state := conn.ConnectionState()
+	result.Body = tls.VersionName(state.Version)
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		result.Body += fmt.Sprintf(", certificate %q expires %s", cert.Subject.CommonName, cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+	result.Healthy = true
+	return result
+}
+
+// Состояния сервиса в ответе grpc.health.v1.Health/Check
+var grpcServingStatus = map[uint64]string{
+	0: "UNKNOWN",
+	1: "SERVING",
+	2: "NOT_SERVING",
+	3: "SERVICE_UNKNOWN",
+}
+
+// grpcServing состояние здорового сервиса
+const grpcServing = 1
+
+// probeGRPC выполняет запрос grpc.health.v1.Health/Check. Бэкенд здоров, если сервис
+// в состоянии SERVING.
+func probeGRPC(ctx context.Context, b backend.Backend, hc *config.BackendHealthCheckConfig, protocol string) ProbeResult {
+	const path = "/grpc.health.v1.Health/Check"
+	result := ProbeResult{URL: b.URL() + path}
+
+	// HealthCheckRequest{service = 1} в кадре gRPC без сжатия
+	var msg []byte
+	if hc.Service != "" {
+		msg = append([]byte{0x0a}, binary.AppendUvarint(nil, uint64(len(hc.Service)))...)
+		msg = append(msg, hc.Service...)
+	}
+	frame := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg)))
+	frame = append(frame, msg...)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, backend.RequestURL(b.URL(), path), bytes.NewReader(frame))
+	if err != nil {
+		result.Err = fmt.Errorf("failed to create health check request: %w", err)
+		return result
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	var transport *http.Transport
+	if socket := backend.SocketPath(b.URL()); socket != "" {
+		transport = backend.NewUnixTransport(socket, &net.Dialer{})
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: hc.InsecureSkipVerify}
+	}
+	transport.DisableKeepAlives = true
+	if protocol == "h2c" || req.URL.Scheme == "http" {
+		// gRPC без TLS работает только поверх HTTP/2 без согласования
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+	defer transport.CloseIdleConnections()
+
+	start := time.Now()
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		result.Latency = time.Since(start)
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBodyExcerpt))
+	result.Latency = time.Since(start)
+	result.StatusCode = resp.StatusCode
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	// Ответ без сообщения передает статус в заголовках, а не в трейлерах
+	status := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	switch {
+	case resp.StatusCode != http.StatusOK:
+		result.Err = fmt.Errorf("unhealthy status code: %d", resp.StatusCode)
+		return result
+	case status != "0":
+		result.Err = fmt.Errorf("grpc status %s: %s", status, message)
+		return result
+	}
+
+	serving, err := grpcHealthStatus(body)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Body = grpcServingStatus[serving]
+	if serving != grpcServing {
+		result.Err = fmt.Errorf("service is %s", result.Body)
+		return result
+	}
+	result.Healthy = true
+	return result
+}
+
+// grpcHealthStatus возвращает поле status (1) сообщения HealthCheckResponse из кадра gRPC.
+// Пустое сообщение означает статус по умолчанию UNKNOWN.
+func grpcHealthStatus(frame []byte) (uint64, error) {
+	if len(frame) < 5 || frame[0] != 0 {
+		return 0, errors.New("malformed grpc health response")
+	}
+	msg := frame[5:]
+	if n := binary.BigEndian.Uint32(frame[1:5]); int(n) <= len(msg) {
+		msg = msg[:n]
+	}
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return 0, errors.New("malformed grpc health response")
+		}
+		msg = msg[n:]
+		value, n := binary.Uvarint(msg)
+		if tag&7 != 0 || n <= 0 {
+			return 0, errors.New("unexpected field in grpc health response")
+		}
+		msg = msg[n:]
+		if tag>>3 == 1 {
+			return value, nil
+		}
+	}
+	return 0, nil
+}
+
+// probeCommand выполняет команду проверки. Бэкенд здоров, если команда завершилась
+// с кодом 0 до истечения таймаута проверки.
+func probeCommand(ctx context.Context, b backend.Backend, hc *config.BackendHealthCheckConfig) ProbeResult {
+	result := ProbeResult{URL: "exec://" + hc.Command[0]}
+	_, addr, _ := probeAddress(b, hc)
+
+	cmd := exec.CommandContext(ctx, hc.Command[0], hc.Command[1:]...)
+	cmd.Env = append(os.Environ(), "BACKEND_ID="+b.ID(), "BACKEND_URL="+b.URL(), "BACKEND_ADDRESS="+addr)
+	// Дочерние процессы команды не должны задерживать проверку после таймаута
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	result.Latency = time.Since(start)
+	if len(output) > maxProbeBodyExcerpt {
+		output = output[:maxProbeBodyExcerpt]
+	}
+	result.Body = string(output)
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		result.Err = fmt.Errorf("command timed out: %w", ctx.Err())
+	case errors.As(err, &exitErr):
+		result.Err = fmt.Errorf("command exited with code %d", exitErr.ExitCode())
+	case err != nil:
+		result.Err = fmt.Errorf("command failed: %w", err)
+	default:
+		result.Healthy = true
+	}
+	return result
+}
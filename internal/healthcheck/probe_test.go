@@ -0,0 +1,156 @@
+package healthcheck
+
+import (
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+func newChecker() *Checker {
+	return New(&config.HealthCheckConfig{}, nil, logger.NewNop())
+}
+
+func newBackend(t *testing.T, url string, hc *config.BackendHealthCheckConfig) backend.Backend {
+	b := backend.NewBackendWithOptions("b1", url, 1, backend.Options{HealthCheck: hc})
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func TestCheckAll_Metadata(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newBackend(t, server.URL, nil)
+	lb.AddBackend(b)
+	c := New(&config.HealthCheckConfig{}, lb, logger.NewNop())
+
+	body = `{"status": "ok", "load": 1.5, "version": "1.8.0", "draining": true}`
+	c.CheckAll()
+	metadata := b.HealthMetadata()
+	if !b.IsAlive() || !b.IsDraining() || metadata.Version != "1.8.0" || metadata.Load == nil || *metadata.Load != 1 {
+		t.Fatalf("неверные сведения из проверки: %+v", metadata)
+	}
+	if len(lb.GetAliveBackends()) != 0 {
+		t.Error("бэкенд, завершающий работу, не должен получать трафик")
+	}
+
+	// Бэкенд, переставший сообщать сведения, возвращается в ротацию
+	body = `ok`
+	c.CheckAll()
+	if b.IsDraining() || b.HealthMetadata().Version != "" {
+		t.Errorf("сведения должны сбрасываться: %+v", b.HealthMetadata())
+	}
+}
+
+func TestProbe_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+
+	c := newChecker()
+	b := newBackend(t, "http://"+addr, &config.BackendHealthCheckConfig{Type: "tcp"})
+	if result := c.Probe(b); !result.Healthy {
+		t.Fatalf("бэкенд, принимающий соединения, должен быть здоров: %v", result.Err)
+	}
+
+	listener.Close()
+	if result := c.Probe(b); result.Healthy || result.Err == nil {
+		t.Error("бэкенд, не принимающий соединения, должен быть нездоров")
+	}
+}
+
+func TestProbe_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	c := newChecker()
+	b := newBackend(t, server.URL, &config.BackendHealthCheckConfig{Type: "tls"})
+	if result := c.Probe(b); result.Healthy {
+		t.Error("сертификат, не прошедший проверку, должен делать бэкенд нездоровым")
+	}
+
+	b = newBackend(t, server.URL, &config.BackendHealthCheckConfig{Type: "tls", InsecureSkipVerify: true})
+	result := c.Probe(b)
+	if !result.Healthy {
+		t.Fatalf("бэкенд должен быть здоров: %v", result.Err)
+	}
+	if !strings.HasPrefix(result.Body, "TLS 1.3") {
+		t.Errorf("результат должен содержать версию TLS: %q", result.Body)
+	}
+}
+
+// grpcHealthServer отвечает на grpc.health.v1.Health/Check состоянием status по h2c
+func grpcHealthServer(t *testing.T, status byte) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/grpc.health.v1.Health/Check" || r.ProtoMajor != 2 {
+			t.Errorf("неверный запрос проверки: %s %s", r.Proto, r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		msg := []byte{0x08, status}
+		w.Write(binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg))))
+		w.Write(msg)
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProbe_GRPC(t *testing.T) {
+	c := newChecker()
+
+	b := newBackend(t, grpcHealthServer(t, 1).URL, &config.BackendHealthCheckConfig{Type: "grpc"})
+	if result := c.Probe(b); !result.Healthy || result.Body != "SERVING" {
+		t.Fatalf("сервис в состоянии SERVING должен быть здоров: %+v", result)
+	}
+
+	b = newBackend(t, grpcHealthServer(t, 2).URL, &config.BackendHealthCheckConfig{Type: "grpc"})
+	if result := c.Probe(b); result.Healthy || result.Body != "NOT_SERVING" {
+		t.Errorf("сервис в состоянии NOT_SERVING должен быть нездоров: %+v", result)
+	}
+}
+
+func TestProbe_Command(t *testing.T) {
+	c := newChecker()
+
+	b := newBackend(t, "http://127.0.0.1:8080", &config.BackendHealthCheckConfig{
+		Type:    "command",
+		Command: []string{"sh", "-c", `test "$BACKEND_ADDRESS" = 127.0.0.1:8080`},
+	})
+	if result := c.Probe(b); !result.Healthy {
+		t.Errorf("команда с кодом 0 должна делать бэкенд здоровым: %v", result.Err)
+	}
+
+	b = newBackend(t, "http://127.0.0.1:8080", &config.BackendHealthCheckConfig{
+		Type:    "command",
+		Command: []string{"sh", "-c", "echo overloaded; exit 3"},
+	})
+	result := c.Probe(b)
+	if result.Healthy || result.Err == nil || !strings.Contains(result.Err.Error(), "code 3") {
+		t.Errorf("команда с ненулевым кодом должна делать бэкенд нездоровым: %+v", result)
+	}
+	if strings.TrimSpace(result.Body) != "overloaded" {
+		t.Errorf("результат должен содержать вывод команды: %q", result.Body)
+	}
+}
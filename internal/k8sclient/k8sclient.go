@@ -0,0 +1,136 @@
+// Package k8sclient содержит минимальный клиент API сервера Kubernetes: адрес сервера,
+// пространство имен и учетные данные сервисного аккаунта берутся из окружения пода,
+// если не заданы явно
+package k8sclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ServiceAccountDir каталог с учетными данными сервисного аккаунта пода
+const ServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Config настройки подключения к API серверу
+type Config struct {
+	// Адрес API сервера (по умолчанию из KUBERNETES_SERVICE_HOST и KUBERNETES_SERVICE_PORT)
+	APIServer string
+
+	// Пространство имен (по умолчанию пространство имен пода или default)
+	Namespace string
+
+	// Файл токена (по умолчанию токен сервисного аккаунта)
+	TokenFile string
+
+	// Файл CA API сервера (по умолчанию CA сервисного аккаунта)
+	CAFile string
+}
+
+// Client выполняет запросы к API серверу с токеном сервисного аккаунта
+type Client struct {
+	apiServer string
+	namespace string
+	tokenFile string
+	client    *http.Client
+}
+
+// New создает клиент API сервера
+func New(cfg Config) (*Client, error) {
+	c := &Client{
+		apiServer: strings.TrimRight(cfg.APIServer, "/"),
+		namespace: cfg.Namespace,
+		tokenFile: cfg.TokenFile,
+	}
+
+	if c.namespace == "" {
+		c.namespace = "default"
+		if namespace, err := os.ReadFile(ServiceAccountDir + "/namespace"); err == nil {
+			c.namespace = strings.TrimSpace(string(namespace))
+		}
+	}
+
+	if c.apiServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("kubernetes apiServer is not set and proxy is not running in a cluster")
+		}
+		c.apiServer = "https://" + net.JoinHostPort(host, port)
+	}
+
+	if c.tokenFile == "" {
+		if _, err := os.Stat(ServiceAccountDir + "/token"); err == nil {
+			c.tokenFile = ServiceAccountDir + "/token"
+		}
+	}
+
+	caFile := cfg.CAFile
+	if caFile == "" {
+		if _, err := os.Stat(ServiceAccountDir + "/ca.crt"); err == nil {
+			caFile = ServiceAccountDir + "/ca.crt"
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading kubernetes CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in kubernetes CA file")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	c.client = &http.Client{Transport: transport}
+
+	return c, nil
+}
+
+// Namespace возвращает пространство имен клиента
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// Do выполняет запрос к пути path API сервера. Тело, если оно задано, передается как JSON.
+func (c *Client) Do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.apiServer+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// Токен сервисного аккаунта периодически обновляется, поэтому читаем его при каждом запросе
+	if c.tokenFile != "" {
+		token, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading kubernetes token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// StatusError возвращает ошибку с неожиданным статусом ответа и началом его тела
+func StatusError(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("kubernetes API returned %s: %s", resp.Status, message)
+}
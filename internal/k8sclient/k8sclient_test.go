@@ -0,0 +1,52 @@
+package k8sclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClient_Do(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("запрос должен передавать токен сервисного аккаунта: %q", r.Header.Get("Authorization"))
+		}
+		if r.URL.Path != "/apis/test" {
+			t.Errorf("неверный путь: %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("forbidden"))
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := New(Config{APIServer: server.URL + "/", Namespace: "lb", TokenFile: tokenFile})
+	if err != nil {
+		t.Fatalf("ошибка создания клиента: %v", err)
+	}
+	if client.Namespace() != "lb" {
+		t.Errorf("неверное пространство имен: %s", client.Namespace())
+	}
+
+	resp, err := client.Do(context.Background(), http.MethodGet, "/apis/test", nil)
+	if err != nil {
+		t.Fatalf("ошибка запроса: %v", err)
+	}
+	defer resp.Body.Close()
+	if err := StatusError(resp); err == nil || err.Error() != "kubernetes API returned 403 Forbidden: forbidden" {
+		t.Errorf("неверная ошибка статуса: %v", err)
+	}
+}
+
+func TestNew_OutsideCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if _, err := New(Config{}); err == nil {
+		t.Error("без apiServer вне кластера клиент не должен создаваться")
+	}
+}
@@ -0,0 +1,295 @@
+// Package kafka содержит минимальный производитель сообщений Kafka: запрос метаданных
+// кластера и отправка пакетов записей без сжатия лидерам разделов
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Параметры запросов к брокерам
+const (
+	dialTimeout = 5 * time.Second
+
+	// Время, за которое брокер должен записать сообщения
+	produceTimeout = 5 * time.Second
+
+	// Подтверждение записи лидером раздела
+	acksLeader = 1
+
+	// Максимальный размер ответа брокера
+	maxResponseSize = 16 << 20
+)
+
+// Message сообщение топика. Сообщения с одинаковым ключом попадают в один раздел,
+// сообщения без ключа распределяются по разделам по очереди.
+type Message struct {
+	Key   []byte
+	Value []byte
+}
+
+// Producer отправляет сообщения в топики Kafka. Соединения с брокерами и метаданные
+// топиков сохраняются между вызовами. Отправка выполняется с подтверждением лидера
+// раздела (acks=1); при ошибке часть сообщений может быть уже записана.
+type Producer struct {
+	brokers  []string
+	clientID string
+
+	mu          sync.Mutex
+	conns       map[string]net.Conn
+	leaders     map[string][]string
+	correlation int32
+	next        int
+}
+
+// NewProducer создает производитель с адресами брокеров host:port для начального
+// запроса метаданных
+func NewProducer(brokers []string, clientID string) *Producer {
+	return &Producer{
+		brokers:  brokers,
+		clientID: clientID,
+		conns:    make(map[string]net.Conn),
+		leaders:  make(map[string][]string),
+	}
+}
+
+// Produce записывает сообщения в топик. Вызовы выполняются последовательно.
+func (p *Producer) Produce(ctx context.Context, topic string, messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	leaders, err := p.partitions(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	// Сообщения без ключа за один вызов уходят в один раздел, чтобы не дробить пакет
+	p.next++
+	byPartition := make(map[int][]Message)
+	for _, m := range messages {
+		partition := p.next % len(leaders)
+		if m.Key != nil {
+			partition = int(crc32.ChecksumIEEE(m.Key) % uint32(len(leaders)))
+		}
+		byPartition[partition] = append(byPartition[partition], m)
+	}
+
+	byLeader := make(map[string][]int)
+	for partition := range byPartition {
+		leader := leaders[partition]
+		if leader == "" {
+			delete(p.leaders, topic)
+			return fmt.Errorf("kafka: partition %d of %s: %w", partition, topic, errLeaderNotAvailable)
+		}
+		byLeader[leader] = append(byLeader[leader], partition)
+	}
+
+	now := time.Now()
+	for leader, partitions := range byLeader {
+		if err := p.produce(ctx, leader, topic, partitions, byPartition, now); err != nil {
+			var be brokerError
+			if !errors.As(err, &be) || be.stale() {
+				delete(p.leaders, topic)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// produce отправляет сообщения разделов partitions лидеру
+func (p *Producer) produce(ctx context.Context, leader, topic string, partitions []int, messages map[int][]Message, now time.Time) error {
+	var req encoder
+	req.int16(-1) // transactional_id
+	req.int16(acksLeader)
+	req.int32(int32(produceTimeout / time.Millisecond))
+	req.int32(1)
+	req.string(topic)
+	req.int32(int32(len(partitions)))
+	for _, partition := range partitions {
+		batch := recordBatch(messages[partition], now)
+		req.int32(int32(partition))
+		req.int32(int32(len(batch)))
+		req.buf = append(req.buf, batch...)
+	}
+
+	resp, err := p.roundTrip(ctx, leader, apiProduce, produceVersion, req.buf)
+	if err != nil {
+		return err
+	}
+	for topics := resp.arrayLen(); topics > 0; topics-- {
+		resp.string()
+		for n := resp.arrayLen(); n > 0; n-- {
+			partition := resp.int32()
+			code := resp.int16()
+			resp.int64() // base_offset
+			resp.int64() // log_append_time_ms
+			if code != 0 && resp.err == nil {
+				return fmt.Errorf("kafka: partition %d of %s: %w", partition, topic, brokerError(code))
+			}
+		}
+	}
+	return resp.err
+}
+
+// partitions возвращает адреса лидеров разделов топика, запрашивая метаданные,
+// если их нет в кэше
+func (p *Producer) partitions(ctx context.Context, topic string) ([]string, error) {
+	if leaders, ok := p.leaders[topic]; ok {
+		return leaders, nil
+	}
+
+	var req encoder
+	req.int32(1)
+	req.string(topic)
+	req.int8(0) // allow_auto_topic_creation
+
+	var errs []error
+	for _, broker := range p.brokers {
+		resp, err := p.roundTrip(ctx, broker, apiMetadata, metadataVersion, req.buf)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		leaders, err := parseMetadata(resp, topic)
+		if err != nil {
+			return nil, err
+		}
+		p.leaders[topic] = leaders
+		return leaders, nil
+	}
+	return nil, fmt.Errorf("kafka: no broker available: %w", errors.Join(errs...))
+}
+
+// parseMetadata возвращает адреса лидеров разделов топика из ответа Metadata
+func parseMetadata(resp *decoder, topic string) ([]string, error) {
+	resp.int32() // throttle_time_ms
+	brokers := make(map[int32]string)
+	for n := resp.arrayLen(); n > 0; n-- {
+		id := resp.int32()
+		host := resp.string()
+		port := resp.int32()
+		resp.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	resp.string() // cluster_id
+	resp.int32()  // controller_id
+
+	var leaders []string
+	for topics := resp.arrayLen(); topics > 0; topics-- {
+		code := resp.int16()
+		name := resp.string()
+		resp.int8() // is_internal
+		partitions := make(map[int32]string)
+		for n := resp.arrayLen(); n > 0; n-- {
+			resp.int16() // error_code
+			index := resp.int32()
+			partitions[index] = brokers[resp.int32()]
+			for replicas := resp.arrayLen(); replicas > 0; replicas-- {
+				resp.int32()
+			}
+			for isr := resp.arrayLen(); isr > 0; isr-- {
+				resp.int32()
+			}
+		}
+		if name != topic {
+			continue
+		}
+		if code != 0 {
+			return nil, fmt.Errorf("kafka: topic %s: %w", topic, brokerError(code))
+		}
+		leaders = make([]string, len(partitions))
+		for index, leader := range partitions {
+			if int(index) < len(leaders) {
+				leaders[index] = leader
+			}
+		}
+	}
+	if resp.err != nil {
+		return nil, resp.err
+	}
+	if len(leaders) == 0 {
+		return nil, fmt.Errorf("kafka: topic %s: %w", topic, errUnknownTopicOrPartition)
+	}
+	return leaders, nil
+}
+
+// roundTrip отправляет запрос брокеру и возвращает тело ответа без заголовка.
+// После ошибки соединение закрывается и при следующем запросе устанавливается заново.
+func (p *Producer) roundTrip(ctx context.Context, addr string, apiKey, apiVersion int16, body []byte) (*decoder, error) {
+	conn, ok := p.conns[addr]
+	if !ok {
+		dialer := net.Dialer{Timeout: dialTimeout}
+		var err error
+		if conn, err = dialer.DialContext(ctx, "tcp", addr); err != nil {
+			return nil, err
+		}
+		p.conns[addr] = conn
+	}
+
+	resp, err := p.exchange(ctx, conn, apiKey, apiVersion, body)
+	if err != nil {
+		conn.Close()
+		delete(p.conns, addr)
+		return nil, fmt.Errorf("kafka: %s: %w", addr, err)
+	}
+	return resp, nil
+}
+
+func (p *Producer) exchange(ctx context.Context, conn net.Conn, apiKey, apiVersion int16, body []byte) (*decoder, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(produceTimeout + dialTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	p.correlation++
+	var req encoder
+	req.int32(0) // размер запроса
+	req.header(apiKey, apiVersion, p.correlation, p.clientID)
+	req.buf = append(req.buf, body...)
+	binary.BigEndian.PutUint32(req.buf, uint32(len(req.buf)-4))
+	if _, err := conn.Write(req.buf); err != nil {
+		return nil, err
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n < 4 || n > maxResponseSize {
+		return nil, errShortResponse
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	resp := &decoder{buf: buf}
+	if id := resp.int32(); id != p.correlation {
+		return nil, fmt.Errorf("unexpected correlation id %d", id)
+	}
+	return resp, nil
+}
+
+// Close закрывает соединения с брокерами
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, conn := range p.conns {
+		conn.Close()
+		delete(p.conns, addr)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBroker отвечает на Metadata и Produce как единственный брокер кластера
+// и сохраняет значения записанных сообщений по разделам
+type fakeBroker struct {
+	t          *testing.T
+	ln         net.Listener
+	partitions int
+
+	mu       sync.Mutex
+	received map[int32][]string
+}
+
+func newFakeBroker(t *testing.T, partitions int) *fakeBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{t: t, ln: ln, partitions: partitions, received: make(map[int32][]string)}
+	go b.serve()
+	t.Cleanup(func() { ln.Close() })
+	return b
+}
+
+func (b *fakeBroker) serve() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+func (b *fakeBroker) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		buf := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		req := &decoder{buf: buf}
+		apiKey := req.int16()
+		req.int16()
+		correlation := req.int32()
+		req.string()
+
+		var resp encoder
+		resp.int32(0)
+		resp.int32(correlation)
+		switch apiKey {
+		case apiMetadata:
+			b.metadata(&resp)
+		case apiProduce:
+			b.produce(req, &resp)
+		}
+		binary.BigEndian.PutUint32(resp.buf, uint32(len(resp.buf)-4))
+		conn.Write(resp.buf)
+	}
+}
+
+func (b *fakeBroker) metadata(resp *encoder) {
+	host, port, _ := net.SplitHostPort(b.ln.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	resp.int32(0) // throttle_time_ms
+	resp.int32(1)
+	resp.int32(1) // node_id
+	resp.string(host)
+	resp.int32(int32(portNum))
+	resp.int16(-1) // rack
+	resp.int16(-1) // cluster_id
+	resp.int32(1)  // controller_id
+	resp.int32(1)
+	resp.int16(0)
+	resp.string("events")
+	resp.int8(0)
+	resp.int32(int32(b.partitions))
+	for i := 0; i < b.partitions; i++ {
+		resp.int16(0)
+		resp.int32(int32(i))
+		resp.int32(1) // leader_id
+		resp.int32(0)
+		resp.int32(0)
+	}
+}
+
+func (b *fakeBroker) produce(req *decoder, resp *encoder) {
+	req.string() // transactional_id
+	if acks := req.int16(); acks != acksLeader {
+		b.t.Errorf("acks = %d, ожидалось %d", acks, acksLeader)
+	}
+	req.int32()
+	req.arrayLen()
+	topic := req.string()
+
+	resp.int32(1)
+	resp.string(topic)
+	n := req.arrayLen()
+	resp.int32(int32(n))
+	for ; n > 0; n-- {
+		partition := req.int32()
+		batch := &decoder{buf: req.take(int(req.int32()))}
+		b.records(partition, batch)
+		resp.int32(partition)
+		resp.int16(0)
+		resp.int64(0)
+		resp.int64(-1)
+	}
+	resp.int32(0) // throttle_time_ms
+}
+
+// records разбирает пакет записей, проверяя контрольную сумму
+func (b *fakeBroker) records(partition int32, batch *decoder) {
+	batch.int64()
+	batch.int32()
+	batch.int32()
+	if magic := batch.int8(); magic != 2 {
+		b.t.Errorf("magic = %d, ожидалось 2", magic)
+	}
+	crc := uint32(batch.int32())
+	if sum := crc32.Checksum(batch.buf, castagnoli); sum != crc {
+		b.t.Errorf("неверная контрольная сумма пакета: %x, ожидалось %x", crc, sum)
+	}
+	batch.take(2 + 4 + 8 + 8 + 8 + 2 + 4)
+	count := batch.int32()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := int32(0); i < count; i++ {
+		varint := func() int64 {
+			v, n := binary.Varint(batch.buf)
+			batch.take(n)
+			return v
+		}
+		varint() // length
+		batch.int8()
+		varint()
+		varint()
+		if keyLen := varint(); keyLen > 0 {
+			batch.take(int(keyLen))
+		}
+		value := batch.take(int(varint()))
+		varint() // headers
+		b.received[partition] = append(b.received[partition], string(value))
+	}
+	if batch.err != nil {
+		b.t.Errorf("неверный пакет записей: %v", batch.err)
+	}
+}
+
+func TestProducer_Produce(t *testing.T) {
+	broker := newFakeBroker(t, 2)
+	p := NewProducer([]string{broker.ln.Addr().String()}, "test")
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Сообщения с одним ключом попадают в один раздел
+	messages := []Message{
+		{Key: []byte("alice"), Value: []byte("1")},
+		{Key: []byte("alice"), Value: []byte("2")},
+		{Value: []byte("3")},
+	}
+	if err := p.Produce(ctx, "events", messages); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Produce(ctx, "events", []Message{{Key: []byte("alice"), Value: []byte("4")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	alice := int32(crc32.ChecksumIEEE([]byte("alice")) % 2)
+	got := broker.received[alice]
+	if len(got) < 3 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("сообщения с ключом должны попасть в раздел %d по порядку: %v", alice, broker.received)
+	}
+	if len(broker.received[0])+len(broker.received[1]) != 4 {
+		t.Errorf("записаны не все сообщения: %v", broker.received)
+	}
+}
+
+func TestProducer_UnknownTopic(t *testing.T) {
+	broker := newFakeBroker(t, 1)
+	p := NewProducer([]string{broker.ln.Addr().String()}, "test")
+	defer p.Close()
+
+	if err := p.Produce(context.Background(), "missing", []Message{{Value: []byte("x")}}); err == nil {
+		t.Error("запись в неизвестный топик должна завершаться ошибкой")
+	}
+}
@@ -0,0 +1,192 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+)
+
+// Версии запросов протокола Kafka, поддерживаемые брокерами 1.0 и новее
+const (
+	apiProduce  = 0
+	apiMetadata = 3
+
+	produceVersion  = 3
+	metadataVersion = 4
+)
+
+// castagnoli таблица CRC32C для контрольной суммы пакета записей
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// encoder сериализует поля запроса в порядке и кодировке протокола Kafka
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) int8(v int8)   { e.buf = append(e.buf, byte(v)) }
+func (e *encoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *encoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *encoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+func (e *encoder) varint(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// varbytes записывает байты с длиной varint; nil записывается как -1
+func (e *encoder) varbytes(b []byte) {
+	if b == nil {
+		e.varint(-1)
+		return
+	}
+	e.varint(int64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// errShortResponse ответ брокера короче, чем следует из его полей
+var errShortResponse = errors.New("kafka: malformed response")
+
+// decoder читает поля ответа; первая ошибка сохраняется, последующие чтения возвращают нули
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil || n < 0 || len(d.buf) < n {
+		d.err = errShortResponse
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) int8() int8 {
+	if b := d.take(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *decoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string читает строку; nullable строка длины -1 возвращается пустой
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+// arrayLen читает длину массива; null массив имеет длину 0
+func (d *decoder) arrayLen() int {
+	n := d.int32()
+	if n < 0 {
+		return 0
+	}
+	if int(n) > len(d.buf) {
+		d.err = errShortResponse
+		return 0
+	}
+	return int(n)
+}
+
+// header записывает заголовок запроса (версия 1)
+func (e *encoder) header(apiKey, apiVersion int16, correlationID int32, clientID string) {
+	e.int16(apiKey)
+	e.int16(apiVersion)
+	e.int32(correlationID)
+	e.string(clientID)
+}
+
+// recordBatch кодирует сообщения пакетом записей (magic 2) без сжатия
+func recordBatch(messages []Message, now time.Time) []byte {
+	first := now.UnixMilli()
+
+	var records encoder
+	for i, m := range messages {
+		var r encoder
+		r.int8(0) // attributes
+		r.varint(0)
+		r.varint(int64(i))
+		r.varbytes(m.Key)
+		r.varbytes(m.Value)
+		r.varint(0) // headers
+		records.varint(int64(len(r.buf)))
+		records.buf = append(records.buf, r.buf...)
+	}
+
+	// Поля после crc, по которым считается контрольная сумма
+	var tail encoder
+	tail.int16(0) // attributes: без сжатия, CreateTime
+	tail.int32(int32(len(messages) - 1))
+	tail.int64(first)
+	tail.int64(first)
+	tail.int64(-1) // producerId
+	tail.int16(-1) // producerEpoch
+	tail.int32(-1) // baseSequence
+	tail.int32(int32(len(messages)))
+	tail.buf = append(tail.buf, records.buf...)
+
+	var batch encoder
+	batch.int64(0) // baseOffset
+	batch.int32(int32(4 + 1 + 4 + len(tail.buf)))
+	batch.int32(-1) // partitionLeaderEpoch
+	batch.int8(2)   // magic
+	batch.int32(int32(crc32.Checksum(tail.buf, castagnoli)))
+	batch.buf = append(batch.buf, tail.buf...)
+	return batch.buf
+}
+
+// brokerError ошибка, возвращенная брокером в коде ответа
+type brokerError int16
+
+// Коды ошибок, после которых нужно обновить метаданные кластера
+const (
+	errUnknownTopicOrPartition brokerError = 3
+	errLeaderNotAvailable      brokerError = 5
+	errNotLeaderForPartition   brokerError = 6
+)
+
+func (e brokerError) Error() string {
+	switch e {
+	case errUnknownTopicOrPartition:
+		return "kafka: unknown topic or partition"
+	case errLeaderNotAvailable:
+		return "kafka: leader not available"
+	case errNotLeaderForPartition:
+		return "kafka: broker is not the leader for partition"
+	}
+	return fmt.Sprintf("kafka: broker error code %d", int16(e))
+}
+
+// stale сообщает, что ошибка вызвана устаревшими метаданными
+func (e brokerError) stale() bool {
+	return e == errUnknownTopicOrPartition || e == errLeaderNotAvailable || e == errNotLeaderForPartition
+}
@@ -15,7 +15,7 @@ type LeastConn struct {
 }
 
 // NewLeastConn создает новый балансировщик по наименьшему количеству соединений
-func NewLeastConn(logger *logger.CustomZapLogger) *LeastConn {
+func NewLeastConn(logger logger.Logger) *LeastConn {
 	return &LeastConn{
 		BaseLoadBalancer: base.NewBaseLoadBalancer(logger),
 	}
@@ -23,22 +23,21 @@ func NewLeastConn(logger *logger.CustomZapLogger) *LeastConn {
 
 // Invoke выбирает бэкенд с наименьшим количеством активных соединений
 func (l *LeastConn) Invoke(request request.Request) backend.Backend {
-	backends := l.GetBackends()
+	backends := l.GetAvailableBackends(request)
 	if len(backends) == 0 {
 		l.Logger().Error("нет доступных бэкендов")
 		return nil
 	}
 
-	var selected *backend.Backend
+	var selected backend.Backend
 	minConn := int64(math.MaxInt64)
 
 	// Находим бэкенд с минимальным количеством соединений
 	for _, b := range backends {
-		connections := b.Stats.ActiveConnections
+		connections := b.Backend.ActiveConnections()
 		if connections < minConn {
 			minConn = connections
-			backend := b.Backend
-			selected = &backend
+			selected = b.Backend
 		}
 	}
 
@@ -47,5 +46,8 @@ func (l *LeastConn) Invoke(request request.Request) backend.Backend {
 		return nil
 	}
 
-	return *selected
+	if base.Tracing(request) {
+		base.Explain(request, "LeastConnections: %d active connections, the fewest of %d available", minConn, len(backends))
+	}
+	return selected
 }
@@ -15,7 +15,7 @@ type LeastConnections struct {
 }
 
 // New создает новый Least Connections балансировщик
-func New(logger *logger.CustomZapLogger) *LeastConnections {
+func New(logger logger.Logger) *LeastConnections {
 	return &LeastConnections{
 		BaseLoadBalancer: base.NewBaseLoadBalancer(logger),
 	}
@@ -27,7 +27,7 @@ func (lc *LeastConnections) Start() error {
 
 // Invoke выбирает бэкенд с наименьшим количеством активных соединений
 func (lc *LeastConnections) Invoke(req request.Request) backend.Backend {
-	backends := lc.GetBackends()
+	backends := lc.GetAvailableBackends(req)
 	if len(backends) == 0 {
 		lc.Logger().Warn("нет доступных бэкендов")
 		return nil
@@ -38,7 +38,7 @@ func (lc *LeastConnections) Invoke(req request.Request) backend.Backend {
 
 	// Находим бэкенд с минимальным количеством соединений
 	for _, state := range backends {
-		activeConn := state.Stats.ActiveConnections
+		activeConn := state.Backend.ActiveConnections()
 		if activeConn < minConn {
 			minConn = activeConn
 			selected = state
@@ -51,10 +51,15 @@ func (lc *LeastConnections) Invoke(req request.Request) backend.Backend {
 		selected = backends[0]
 	}
 
+	if base.Tracing(req) {
+		base.Explain(req, "LeastConnections: %d active connections, the fewest of %d available", minConn, len(backends))
+	}
 	lc.IncActiveConnections(selected.Backend.ID())
-	lc.Logger().Debug(fmt.Sprintf("выбран бэкенд",
-		"id", selected.Backend.ID(),
-		"activeConnections", selected.Stats.ActiveConnections))
+	if lc.Logger().DebugEnabled() {
+		lc.Logger().Debug(fmt.Sprintf("выбран бэкенд: id=%s, activeConnections=%d",
+			selected.Backend.ID(),
+			minConn))
+	}
 
 	return selected.Backend
 }
@@ -0,0 +1,61 @@
+package leastload
+
+import (
+	"math"
+
+	"cloud.ru_test/internal/loadbalancer/base"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+)
+
+// minCapacity нижняя граница свободной доли бэкенда: полностью загруженный по своей
+// оценке бэкенд выбирается, только если остальные загружены во много раз сильнее
+const minCapacity = 0.01
+
+// LeastLoad выбирает бэкенд с учетом загрузки, которую бэкенды сообщают в ответе
+// на проверку здоровья. Сообщенная загрузка обновляется раз в интервал проверок,
+// поэтому выбор по ней одной направлял бы весь трафик на один бэкенд до следующей
+// проверки. Вместо этого активные соединения бэкенда делятся на его свободную долю
+// (1 - загрузка): чем выше загрузка, тем меньше соединений получает бэкенд.
+// Бэкенды, не сообщающие загрузку, считаются свободными, и выбор совпадает с LeastConnections.
+type LeastLoad struct {
+	*base.BaseLoadBalancer
+}
+
+// New создает балансировщик по сообщенной загрузке
+func New(logger logger.Logger) *LeastLoad {
+	return &LeastLoad{
+		BaseLoadBalancer: base.NewBaseLoadBalancer(logger),
+	}
+}
+
+// Invoke выбирает бэкенд с наименьшим числом соединений на единицу свободной доли
+func (l *LeastLoad) Invoke(req request.Request) backend.Backend {
+	backends := l.GetAvailableBackends(req)
+	if len(backends) == 0 {
+		l.Logger().Error("нет доступных бэкендов")
+		return nil
+	}
+
+	var selected backend.Backend
+	var selectedLoad float64
+	minScore := math.Inf(1)
+	for _, state := range backends {
+		load := 0.0
+		if reported := state.Backend.HealthMetadata().Load; reported != nil {
+			load = *reported
+		}
+		score := float64(state.Backend.ActiveConnections()+1) / math.Max(1-load, minCapacity)
+		if score < minScore {
+			minScore = score
+			selected = state.Backend
+			selectedLoad = load
+		}
+	}
+
+	if base.Tracing(req) {
+		base.Explain(req, "LeastLoad: reported load %.2f, score %.2f, the lowest of %d available", selectedLoad, minScore, len(backends))
+	}
+	return selected
+}
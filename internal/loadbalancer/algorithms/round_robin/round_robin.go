@@ -16,7 +16,7 @@ type RoundRobin struct {
 }
 
 // New создает новый балансировщик Round Robin
-func New(logger *logger.CustomZapLogger) *RoundRobin {
+func New(logger logger.Logger) *RoundRobin {
 	return &RoundRobin{
 		BaseLoadBalancer: base.NewBaseLoadBalancer(logger),
 		current:          0,
@@ -25,13 +25,17 @@ func New(logger *logger.CustomZapLogger) *RoundRobin {
 
 // Invoke выбирает следующий бэкенд для запроса
 func (r *RoundRobin) Invoke(request request.Request) backend.Backend {
-	backends := r.GetBackends()
+	backends := r.GetAvailableBackends(request)
 	if len(backends) == 0 {
 		r.Logger().Error("нет доступных бэкендов")
 		return nil
 	}
 
 	// Атомарно увеличиваем счетчик и берем остаток от деления
-	next := atomic.AddUint64(&r.current, 1) % uint64(len(backends))
+	counter := atomic.AddUint64(&r.current, 1)
+	next := counter % uint64(len(backends))
+	if base.Tracing(request) {
+		base.Explain(request, "RoundRobin: counter=%d, index %d of %d available", counter, next, len(backends))
+	}
 	return backends[next].Backend
 }
@@ -1,7 +1,6 @@
 package weighted
 
 import (
-	"sync"
 	"sync/atomic"
 
 	"cloud.ru_test/internal/loadbalancer/base"
@@ -13,12 +12,11 @@ import (
 // WeightedRoundRobin реализует алгоритм взвешенного Round Robin
 type WeightedRoundRobin struct {
 	*base.BaseLoadBalancer
-	current     uint64
-	weightMutex sync.RWMutex
+	current uint64
 }
 
 // New создает новый взвешенный балансировщик
-func New(logger *logger.CustomZapLogger) *WeightedRoundRobin {
+func New(logger logger.Logger) *WeightedRoundRobin {
 	return &WeightedRoundRobin{
 		BaseLoadBalancer: base.NewBaseLoadBalancer(logger),
 		current:          0,
@@ -27,28 +25,18 @@ func New(logger *logger.CustomZapLogger) *WeightedRoundRobin {
 
 // AddBackend переопределяет метод базового балансировщика для установки веса
 func (w *WeightedRoundRobin) AddBackend(b backend.Backend) {
-	w.weightMutex.Lock()
-	defer w.weightMutex.Unlock()
+	// Бэкенды без веса получают вес по умолчанию до того, как станут доступны для выбора
+	if b.Weight() <= 0 {
+		b.SetWeight(1.0)
+	}
 
 	// Вызываем базовую реализацию
 	w.BaseLoadBalancer.AddBackend(b)
-
-	// Устанавливаем вес из конфигурации бэкенда
-	if state := w.GetBackend(b.ID()); state != nil {
-		weight := b.Weight()
-		if weight <= 0 {
-			weight = 1.0 // Дефолтный вес
-		}
-		state.Weight = weight
-	}
 }
 
 // Invoke выбирает следующий бэкенд для запроса с учетом весов
 func (w *WeightedRoundRobin) Invoke(request request.Request) backend.Backend {
-	w.weightMutex.RLock()
-	defer w.weightMutex.RUnlock()
-
-	backends := w.GetBackends()
+	backends := w.GetAvailableBackends(request)
 	if len(backends) == 0 {
 		w.Logger().Error("нет доступных бэкендов")
 		return nil
@@ -57,7 +45,7 @@ func (w *WeightedRoundRobin) Invoke(request request.Request) backend.Backend {
 	// Вычисляем общий вес
 	var totalWeight float64
 	for _, b := range backends {
-		totalWeight += b.Weight
+		totalWeight += b.Backend.Weight()
 	}
 
 	// Атомарно увеличиваем счетчик
@@ -68,12 +56,19 @@ func (w *WeightedRoundRobin) Invoke(request request.Request) backend.Backend {
 	target := float64(next%uint64(1000)) / 1000.0 * totalWeight
 
 	for _, b := range backends {
-		accumWeight += b.Weight
+		accumWeight += b.Backend.Weight()
 		if accumWeight >= target {
+			if base.Tracing(request) {
+				base.Explain(request, "WeightedRoundRobin: target=%.2f of total weight %.2f, cumulative weight %.2f (backend weight %.2f)",
+					target, totalWeight, accumWeight, b.Backend.Weight())
+			}
 			return b.Backend
 		}
 	}
 
 	// На случай ошибок округления возвращаем последний бэкенд
+	if base.Tracing(request) {
+		base.Explain(request, "WeightedRoundRobin: target=%.2f of total weight %.2f, last backend after rounding", target, totalWeight)
+	}
 	return backends[len(backends)-1].Backend
 }
@@ -3,19 +3,24 @@ package loadbalancer
 import (
 	"cloud.ru_test/config"
 	"cloud.ru_test/internal/loadbalancer/algorithms/leastconn"
+	"cloud.ru_test/internal/loadbalancer/algorithms/leastload"
 	roundrobin "cloud.ru_test/internal/loadbalancer/algorithms/round_robin"
 	"cloud.ru_test/internal/loadbalancer/algorithms/weighted"
 	"cloud.ru_test/internal/loadbalancer/base"
 	"cloud.ru_test/pkg/backend"
 	"cloud.ru_test/pkg/logger"
 	"cloud.ru_test/pkg/request"
+	"context"
 	"fmt"
+	"sync"
 )
 
 // LoadBalancer определяет интерфейс балансировщика нагрузки
 type LoadBalancer interface {
 	// Start запускает балансировщик
 	Start() error
+	// Stop останавливает фоновые задачи балансировщика, не закрывая бэкенды
+	Stop(ctx context.Context) error
 	// AddBackend добавляет новый бэкенд
 	AddBackend(backend backend.Backend)
 	// RemoveBackend удаляет бэкенд
@@ -26,6 +31,8 @@ type LoadBalancer interface {
 	GetBackend(id string) *base.BackendState
 	// GetBackends возвращает список всех бэкендов
 	GetBackends() []*base.BackendState
+	// GetAliveBackends возвращает список бэкендов, прошедших проверку здоровья
+	GetAliveBackends() []*base.BackendState
 	// IncActiveConnections увеличивает счетчик активных соединений
 	IncActiveConnections(id string)
 	// DecActiveConnections уменьшает счетчик активных соединений
@@ -34,18 +41,49 @@ type LoadBalancer interface {
 	UpdateResponseTime(id string, responseTime int64)
 }
 
-// New создает новый балансировщик на основе конфигурации
-func New(cfg config.LoadBalancerConfig, appLogger *logger.CustomZapLogger) (LoadBalancer, error) {
-	switch cfg.Method {
-	case "RoundRobin":
+// Factory создает балансировщик по настройкам секции loadBalancer
+type Factory func(cfg config.LoadBalancerConfig, appLogger logger.Logger) (LoadBalancer, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+func init() {
+	Register("RoundRobin", func(_ config.LoadBalancerConfig, appLogger logger.Logger) (LoadBalancer, error) {
 		return roundrobin.New(appLogger), nil
-	case "WeightedRoundRobin":
+	})
+	Register("WeightedRoundRobin", func(_ config.LoadBalancerConfig, appLogger logger.Logger) (LoadBalancer, error) {
 		return weighted.New(appLogger), nil
-	case "LeastConnections":
+	})
+	Register("LeastConnections", func(_ config.LoadBalancerConfig, appLogger logger.Logger) (LoadBalancer, error) {
 		return leastconn.NewLeastConn(appLogger), nil
-	default:
+	})
+	Register("LeastLoad", func(_ config.LoadBalancerConfig, appLogger logger.Logger) (LoadBalancer, error) {
+		return leastload.New(appLogger), nil
+	})
+}
+
+// Register регистрирует алгоритм балансировки. Имя становится допустимым значением
+// loadBalancer.method конфигурации, параметры передаются в loadBalancer.params.
+// Регистрация под существующим именем заменяет алгоритм.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	factories[name] = factory
+	config.RegisterLoadBalancerMethod(name)
+}
+
+// New создает новый балансировщик на основе конфигурации
+func New(cfg config.LoadBalancerConfig, appLogger logger.Logger) (LoadBalancer, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[cfg.Method]
+	factoriesMu.RUnlock()
+	if !ok {
 		err := fmt.Errorf("неподдерживаемый метод балансировки: %s", cfg.Method)
 		appLogger.Error(err.Error())
 		return nil, err
 	}
+	return factory(cfg, appLogger)
 }
@@ -0,0 +1,171 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/goleak"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer/base"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+)
+
+// TestMain проверяет, что после тестов пакета не остается запущенных горутин
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+// newBalancer создает балансировщик method с count бэкендами, которые закрываются по завершении теста
+func newBalancer(tb testing.TB, method string, count int) LoadBalancer {
+	lb, err := New(config.LoadBalancerConfig{Method: method}, logger.NewNop())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	addBackends(tb, lb, count)
+	return lb
+}
+
+// addBackends добавляет в балансировщик count бэкендов и закрывает их по завершении теста
+func addBackends(tb testing.TB, lb LoadBalancer, count int) {
+	for i := 0; i < count; i++ {
+		b := backend.NewBackend(fmt.Sprintf("backend%d", i), fmt.Sprintf("http://127.0.0.1:%d", 8081+i), 1)
+		tb.Cleanup(func() { b.Close() })
+		lb.AddBackend(b)
+	}
+}
+
+func TestRoundRobin_Invoke(t *testing.T) {
+	lb := newBalancer(t, "RoundRobin", 3)
+	req := request.NewRequest(httptest.NewRequest("GET", "/", nil), nil)
+
+	// Бэкенды выбираются по очереди
+	counts := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		counts[lb.Invoke(req).ID()]++
+	}
+	for id, count := range counts {
+		if count != 10 {
+			t.Errorf("бэкенд %s выбран %d раз из 30, ожидалось 10", id, count)
+		}
+	}
+
+	// Недоступный бэкенд не выбирается, а удаленный исчезает из выбора
+	lb.GetBackend("backend0").Backend.SetAlive(false)
+	lb.RemoveBackend(lb.GetBackend("backend1").Backend)
+	for i := 0; i < 10; i++ {
+		if id := lb.Invoke(req).ID(); id != "backend2" {
+			t.Fatalf("выбран бэкенд %s, ожидался единственный доступный backend2", id)
+		}
+	}
+}
+
+func TestLeastLoad_Invoke(t *testing.T) {
+	lb := newBalancer(t, "LeastLoad", 3)
+	req := request.NewRequest(httptest.NewRequest("GET", "/", nil), nil)
+	load := func(v float64) *float64 { return &v }
+
+	lb.GetBackend("backend0").Backend.SetHealthMetadata(backend.HealthMetadata{Load: load(0.9)})
+	lb.GetBackend("backend1").Backend.SetHealthMetadata(backend.HealthMetadata{Load: load(0.2)})
+	lb.GetBackend("backend2").Backend.SetHealthMetadata(backend.HealthMetadata{Load: load(0), Draining: true})
+	if id := lb.Invoke(req).ID(); id != "backend1" {
+		t.Errorf("выбран бэкенд %s, ожидался наименее загруженный из незавершающих работу backend1", id)
+	}
+}
+
+func TestBackendVersion(t *testing.T) {
+	lb := newBalancer(t, "RoundRobin", 3)
+	lb.GetBackend("backend0").Backend.SetHealthMetadata(backend.HealthMetadata{Version: "2.0.0"})
+
+	canary := request.NewRequest(httptest.NewRequest("GET", "/", nil), nil)
+	request.Set(canary, request.KeyBackendVersion, "2.0.0")
+	for i := 0; i < 5; i++ {
+		if id := lb.Invoke(canary).ID(); id != "backend0" {
+			t.Fatalf("запрос версии 2.0.0 направлен на %s", id)
+		}
+	}
+
+	// Если бэкендов запрошенной версии нет, выбор идет среди всех
+	other := request.NewRequest(httptest.NewRequest("GET", "/", nil), nil)
+	request.Set(other, request.KeyBackendVersion, "3.0.0")
+	counts := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		counts[lb.Invoke(other).ID()]++
+	}
+	if len(counts) != 3 {
+		t.Errorf("при отсутствии версии 3.0.0 должны выбираться все бэкенды: %v", counts)
+	}
+}
+
+// preferred выбирает бэкенд, заданный параметром prefer, а если он недоступен — первый доступный
+type preferred struct {
+	*base.BaseLoadBalancer
+	id string
+}
+
+func (p *preferred) Invoke(req request.Request) backend.Backend {
+	backends := p.GetAvailableBackends(req)
+	if len(backends) == 0 {
+		return nil
+	}
+	for _, state := range backends {
+		if state.Backend.ID() == p.id {
+			return state.Backend
+		}
+	}
+	return backends[0].Backend
+}
+
+func TestRegister(t *testing.T) {
+	Register("Preferred", func(cfg config.LoadBalancerConfig, appLogger logger.Logger) (LoadBalancer, error) {
+		id, _ := cfg.Params["prefer"].(string)
+		if id == "" {
+			return nil, fmt.Errorf("prefer param is required")
+		}
+		return &preferred{BaseLoadBalancer: base.NewBaseLoadBalancer(appLogger), id: id}, nil
+	})
+
+	if _, err := New(config.LoadBalancerConfig{Method: "Preferred"}, logger.NewNop()); err == nil {
+		t.Error("ошибка фабрики должна возвращаться из New")
+	}
+	lb, err := New(config.LoadBalancerConfig{Method: "Preferred", Params: map[string]interface{}{"prefer": "backend1"}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addBackends(t, lb, 3)
+	req := request.NewRequest(httptest.NewRequest("GET", "/", nil), nil)
+	if id := lb.Invoke(req).ID(); id != "backend1" {
+		t.Errorf("выбран бэкенд %s, ожидался backend1 из параметров алгоритма", id)
+	}
+
+	// Зарегистрированный метод допустим в конфигурации
+	if _, err := config.Parse([]byte("loadBalancer:\n  method: Preferred\n  params:\n    prefer: backend1\n" +
+		"logger:\n  logLevel: info\n  serviceName: test\n" +
+		"backends:\n  - id: backend1\n    url: http://127.0.0.1:8081\n")); err != nil {
+		t.Errorf("зарегистрированный метод должен проходить проверку конфигурации: %v", err)
+	}
+	if _, err := New(config.LoadBalancerConfig{Method: "Random"}, logger.NewNop()); err == nil {
+		t.Error("незарегистрированный метод должен отклоняться")
+	}
+}
+
+func benchmarkInvoke(b *testing.B, method string) {
+	lb := newBalancer(b, method, 10)
+	b.ReportAllocs()
+	b.SetParallelism(16)
+	b.RunParallel(func(pb *testing.PB) {
+		req := request.NewRequest(httptest.NewRequest("GET", "/", nil), nil)
+		for pb.Next() {
+			if lb.Invoke(req) == nil {
+				b.Fatal("бэкенд не выбран")
+			}
+		}
+	})
+}
+
+func BenchmarkInvoke_RoundRobin(b *testing.B)       { benchmarkInvoke(b, "RoundRobin") }
+func BenchmarkInvoke_LeastConnections(b *testing.B) { benchmarkInvoke(b, "LeastConnections") }
+func BenchmarkInvoke_LeastLoad(b *testing.B)        { benchmarkInvoke(b, "LeastLoad") }
@@ -1,14 +1,42 @@
 package base
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 
 	"cloud.ru_test/pkg/backend"
 	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
 )
 
+// Ключи значений запроса, которые балансировщик заполняет в режиме отладки маршрутизации
+var (
+	// KeyCandidates состояние бэкендов на момент выбора и причины, по которым они не участвовали в выборе
+	KeyCandidates = request.NewKey[string]("loadbalancer.candidates")
+
+	// KeyDecision алгоритм и его состояние, по которым выбран бэкенд
+	KeyDecision = request.NewKey[string]("loadbalancer.decision")
+)
+
+// Tracing сообщает, что для запроса включена отладка маршрутизации
+func Tracing(req request.Request) bool {
+	debug, _ := request.Get(req, request.KeyDebug)
+	return debug
+}
+
+// Explain сохраняет объяснение выбора бэкенда, если для запроса включена отладка маршрутизации.
+// Аргументы размещаются в памяти еще до вызова, поэтому на горячем пути вызов
+// стоит выполнять только после проверки Tracing.
+func Explain(req request.Request, format string, args ...interface{}) {
+	if Tracing(req) {
+		request.Set(req, KeyDecision, fmt.Sprintf(format, args...))
+	}
+}
+
 // Stats хранит статистику бэкенда
 type Stats struct {
 	ActiveConnections int64
@@ -21,22 +49,43 @@ type Stats struct {
 type BackendState struct {
 	Backend backend.Backend
 	Stats   Stats
-	Weight  float64
 }
 
 // BaseLoadBalancer содержит общую функциональность для всех алгоритмов
 type BaseLoadBalancer struct {
-	backends map[string]*BackendState
-	mu       sync.RWMutex
-	logger   *logger.CustomZapLogger
+	// Текущий набор бэкендов. Выбор бэкенда читает его без блокировок; при добавлении
+	// и удалении бэкендов набор пересобирается и заменяется целиком.
+	backends atomic.Pointer[backendSet]
+
+	// Сериализует изменения набора бэкендов
+	mu     sync.Mutex
+	logger logger.Logger
 }
 
-// NewBaseLoadBalancer создает новый базовый балансировщик
-func NewBaseLoadBalancer(logger *logger.CustomZapLogger) *BaseLoadBalancer {
-	return &BaseLoadBalancer{
-		backends: make(map[string]*BackendState),
-		logger:   logger,
+// backendSet неизменяемый набор бэкендов балансировщика
+type backendSet struct {
+	// Бэкенды, упорядоченные по ID
+	list []*BackendState
+	byID map[string]*BackendState
+}
+
+// newBackendSet собирает набор из бэкендов byID
+func newBackendSet(byID map[string]*BackendState) *backendSet {
+	list := make([]*BackendState, 0, len(byID))
+	for _, state := range byID {
+		list = append(list, state)
 	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Backend.ID() < list[j].Backend.ID()
+	})
+	return &backendSet{list: list, byID: byID}
+}
+
+// NewBaseLoadBalancer создает новый базовый балансировщик
+func NewBaseLoadBalancer(logger logger.Logger) *BaseLoadBalancer {
+	b := &BaseLoadBalancer{logger: logger}
+	b.backends.Store(newBackendSet(map[string]*BackendState{}))
+	return b
 }
 
 func (b *BaseLoadBalancer) Start() error {
@@ -44,6 +93,27 @@ func (b *BaseLoadBalancer) Start() error {
 	return nil
 }
 
+// Stop останавливает балансировщик. Бэкенды не закрываются: при замене балансировщика
+// они переходят в новый, а закрывает их владелец (приложение или прокси).
+func (b *BaseLoadBalancer) Stop(ctx context.Context) error {
+	b.logger.Debug("Остановка базового балансировщика нагрузки")
+	return nil
+}
+
+// update заменяет набор бэкендов результатом изменения копии текущего набора
+func (b *BaseLoadBalancer) update(change func(byID map[string]*BackendState)) *backendSet {
+	current := b.backends.Load()
+	byID := make(map[string]*BackendState, len(current.byID)+1)
+	for id, state := range current.byID {
+		byID[id] = state
+	}
+	change(byID)
+
+	set := newBackendSet(byID)
+	b.backends.Store(set)
+	return set
+}
+
 func (b *BaseLoadBalancer) AddBackend(backend backend.Backend) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -52,12 +122,14 @@ func (b *BaseLoadBalancer) AddBackend(backend backend.Backend) {
 		backend.ID(),
 		backend.Weight()))
 
-	b.backends[backend.ID()] = &BackendState{
-		Backend: backend,
-	}
+	set := b.update(func(byID map[string]*BackendState) {
+		byID[backend.ID()] = &BackendState{
+			Backend: backend,
+		}
+	})
 	b.logger.Debug(fmt.Sprintf("Бэкенд %s успешно добавлен. Всего бэкендов: %d",
 		backend.ID(),
-		len(b.backends)))
+		len(set.list)))
 }
 
 func (b *BaseLoadBalancer) RemoveBackend(backend backend.Backend) {
@@ -66,32 +138,35 @@ func (b *BaseLoadBalancer) RemoveBackend(backend backend.Backend) {
 
 	b.logger.Debug(fmt.Sprintf("Удаление бэкенда: id=%s", backend.ID()))
 
-	if _, exists := b.backends[backend.ID()]; exists {
-		delete(b.backends, backend.ID())
+	if _, exists := b.backends.Load().byID[backend.ID()]; exists {
+		set := b.update(func(byID map[string]*BackendState) {
+			delete(byID, backend.ID())
+		})
 		b.logger.Debug(fmt.Sprintf("Бэкенд %s успешно удален. Осталось бэкендов: %d",
 			backend.ID(),
-			len(b.backends)))
+			len(set.list)))
 	} else {
 		b.logger.Debug(fmt.Sprintf("Попытка удаления несуществующего бэкенда: %s", backend.ID()))
 	}
 }
 
 func (b *BaseLoadBalancer) GetBackend(id string) *BackendState {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-
-	state := b.backends[id]
+	state := b.backends.Load().byID[id]
 	if state == nil {
-		b.logger.Debug(fmt.Sprintf("Запрошен несуществующий бэкенд: %s", id))
+		if b.logger.DebugEnabled() {
+			b.logger.Debug(fmt.Sprintf("Запрошен несуществующий бэкенд: %s", id))
+		}
 		return nil
 	}
 
-	b.logger.Debug(fmt.Sprintf("Получен бэкенд %s: активных соединений=%d, всего запросов=%d, ошибок=%d, время ответа=%dms",
-		id,
-		state.Stats.ActiveConnections,
-		state.Stats.TotalRequests,
-		state.Stats.FailedRequests,
-		state.Stats.ResponseTime))
+	if b.logger.DebugEnabled() {
+		b.logger.Debug(fmt.Sprintf("Получен бэкенд %s: активных соединений=%d, всего запросов=%d, ошибок=%d, время ответа=%dms",
+			id,
+			atomic.LoadInt64(&state.Stats.ActiveConnections),
+			atomic.LoadUint64(&state.Stats.TotalRequests),
+			atomic.LoadUint64(&state.Stats.FailedRequests),
+			atomic.LoadInt64(&state.Stats.ResponseTime)))
+	}
 
 	return state
 }
@@ -99,8 +174,10 @@ func (b *BaseLoadBalancer) GetBackend(id string) *BackendState {
 func (b *BaseLoadBalancer) IncActiveConnections(id string) {
 	if state := b.GetBackend(id); state != nil {
 		newCount := atomic.AddInt64(&state.Stats.ActiveConnections, 1)
-		b.logger.Debug(fmt.Sprintf("Увеличено количество активных соединений для бэкенда %s: %d", id, newCount))
-	} else {
+		if b.logger.DebugEnabled() {
+			b.logger.Debug(fmt.Sprintf("Увеличено количество активных соединений для бэкенда %s: %d", id, newCount))
+		}
+	} else if b.logger.DebugEnabled() {
 		b.logger.Debug(fmt.Sprintf("Попытка увеличить количество соединений для несуществующего бэкенда: %s", id))
 	}
 }
@@ -108,8 +185,10 @@ func (b *BaseLoadBalancer) IncActiveConnections(id string) {
 func (b *BaseLoadBalancer) DecActiveConnections(id string) {
 	if state := b.GetBackend(id); state != nil {
 		newCount := atomic.AddInt64(&state.Stats.ActiveConnections, -1)
-		b.logger.Debug(fmt.Sprintf("Уменьшено количество активных соединений для бэкенда %s: %d", id, newCount))
-	} else {
+		if b.logger.DebugEnabled() {
+			b.logger.Debug(fmt.Sprintf("Уменьшено количество активных соединений для бэкенда %s: %d", id, newCount))
+		}
+	} else if b.logger.DebugEnabled() {
 		b.logger.Debug(fmt.Sprintf("Попытка уменьшить количество соединений для несуществующего бэкенда: %s", id))
 	}
 }
@@ -118,35 +197,209 @@ func (b *BaseLoadBalancer) UpdateResponseTime(id string, responseTime int64) {
 	if state := b.GetBackend(id); state != nil {
 		oldTime := atomic.LoadInt64(&state.Stats.ResponseTime)
 		atomic.StoreInt64(&state.Stats.ResponseTime, responseTime)
-		b.logger.Debug(fmt.Sprintf("Обновлено время ответа для бэкенда %s: %dms -> %dms", id, oldTime, responseTime))
-	} else {
+		if b.logger.DebugEnabled() {
+			b.logger.Debug(fmt.Sprintf("Обновлено время ответа для бэкенда %s: %dms -> %dms", id, oldTime, responseTime))
+		}
+	} else if b.logger.DebugEnabled() {
 		b.logger.Debug(fmt.Sprintf("Попытка обновить время ответа для несуществующего бэкенда: %s", id))
 	}
 }
 
+// GetBackends возвращает копию списка всех бэкендов, упорядоченного по ID
 func (b *BaseLoadBalancer) GetBackends() []*BackendState {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	backends := append([]*BackendState(nil), b.backends.Load().list...)
 
-	backends := make([]*BackendState, 0, len(b.backends))
-	for _, state := range b.backends {
-		backends = append(backends, state)
+	if b.logger.DebugEnabled() {
+		b.logger.Debug(fmt.Sprintf("Получен список всех бэкендов (всего: %d)", len(backends)))
+		for _, state := range backends {
+			b.logger.Debug(fmt.Sprintf("Бэкенд %s: активных соединений=%d, всего запросов=%d, ошибок=%d, время ответа=%dms",
+				state.Backend.ID(),
+				atomic.LoadInt64(&state.Stats.ActiveConnections),
+				atomic.LoadUint64(&state.Stats.TotalRequests),
+				atomic.LoadUint64(&state.Stats.FailedRequests),
+				atomic.LoadInt64(&state.Stats.ResponseTime)))
+		}
 	}
 
-	b.logger.Debug(fmt.Sprintf("Получен список всех бэкендов (всего: %d)", len(backends)))
-	for _, state := range backends {
-		b.logger.Debug(fmt.Sprintf("Бэкенд %s: активных соединений=%d, всего запросов=%d, ошибок=%d, время ответа=%dms",
-			state.Backend.ID(),
-			state.Stats.ActiveConnections,
-			state.Stats.TotalRequests,
-			state.Stats.FailedRequests,
-			state.Stats.ResponseTime))
+	return backends
+}
+
+// GetAliveBackends возвращает список бэкендов, готовых принимать трафик:
+// прошедших проверку здоровья, не выведенных на обслуживание и не завершающих работу
+func (b *BaseLoadBalancer) GetAliveBackends() []*BackendState {
+	all := b.backends.Load().list
+	backends := make([]*BackendState, 0, len(all))
+	for _, state := range all {
+		if state.Backend.IsAlive() && !state.Backend.InMaintenance() && !state.Backend.IsDraining() {
+			backends = append(backends, state)
+		}
+	}
+
+	if b.logger.DebugEnabled() {
+		b.logger.Debug(fmt.Sprintf("Получен список доступных бэкендов (доступно: %d из %d)", len(backends), len(all)))
 	}
 
 	return backends
 }
 
+// available проверяет, можно ли отправить бэкенду запрос: он прошел проверку здоровья,
+// не выведен на обслуживание, не завершает работу, не исключен обнаружением выбросов
+// и не исчерпал лимит соединений
+func available(state *BackendState) bool {
+	be := state.Backend
+	return be.IsAlive() && !be.InMaintenance() && !be.IsDraining() && !be.IsEjected() && !backend.IsSaturated(be)
+}
+
+// GetAvailableBackends возвращает бэкенды, которым можно отправить запрос req: доступные,
+// не исключенные обнаружением выбросов и не исчерпавшие лимит одновременных соединений.
+// Если маршрут запроса ограничивает бэкенды (request.KeyBackends), выбор идет только
+// среди них, даже если ни один из них не доступен. Если запросу назначена версия бэкендов (request.KeyBackendVersion) и такие бэкенды есть,
+// выбор идет только среди них. Если клиент закреплен за бэкендом (request.KeyAffinity)
+// и тот остался среди подходящих, возвращается только он. Затем, если по правилам GeoIP клиенту назначена зона
+// и в ней есть такие бэкенды, возвращаются только бэкенды этой зоны.
+//
+// Если доступны все бэкенды, возвращается общий неизменяемый список без копирования:
+// вызывающий не должен изменять результат.
+func (b *BaseLoadBalancer) GetAvailableBackends(req request.Request) []*BackendState {
+	all := b.backends.Load().list
+
+	backends := all
+	for i, state := range all {
+		if available(state) {
+			continue
+		}
+		// Копия нужна, только если какой-либо бэкенд недоступен
+		backends = make([]*BackendState, i, len(all))
+		copy(backends, all[:i])
+		for _, state := range all[i+1:] {
+			if available(state) {
+				backends = append(backends, state)
+			}
+		}
+		break
+	}
+
+	if len(backends) < len(all) && b.logger.DebugEnabled() {
+		b.logger.Debug(fmt.Sprintf("Исключены недоступные бэкенды: %d из %d", len(all)-len(backends), len(all)))
+	}
+
+	if req == nil {
+		return backends
+	}
+
+	routed, _ := request.Get(req, request.KeyBackends)
+	if routed != nil {
+		var ofRoute []*BackendState
+		for _, state := range backends {
+			if routed[state.Backend.ID()] {
+				ofRoute = append(ofRoute, state)
+			}
+		}
+		backends = ofRoute
+	}
+
+	version, _ := request.Get(req, request.KeyBackendVersion)
+	if version != "" {
+		var ofVersion []*BackendState
+		for _, state := range backends {
+			if state.Backend.HealthMetadata().Version == version {
+				ofVersion = append(ofVersion, state)
+			}
+		}
+		if len(ofVersion) > 0 {
+			backends = ofVersion
+		} else {
+			if b.logger.DebugEnabled() {
+				b.logger.Debug(fmt.Sprintf("Нет доступных бэкендов версии %s, запрос направляется на другие версии", version))
+			}
+			version = ""
+		}
+	}
+
+	if pinned, _ := request.Get(req, request.KeyAffinity); pinned != "" {
+		for _, state := range backends {
+			if state.Backend.ID() == pinned {
+				Explain(req, "client is pinned to backend %s by affinity cookie", pinned)
+				return []*BackendState{state}
+			}
+		}
+		if b.logger.DebugEnabled() {
+			b.logger.Debug(fmt.Sprintf("Бэкенд %s, за которым закреплен клиент, недоступен, выбирается другой", pinned))
+		}
+	}
+
+	zone := req.GetGeo().Zone
+	if zone == "" {
+		b.traceCandidates(req, "", version)
+		return backends
+	}
+	var inZone []*BackendState
+	for _, state := range backends {
+		if state.Backend.Zone() == zone {
+			inZone = append(inZone, state)
+		}
+	}
+	if len(inZone) == 0 {
+		if b.logger.DebugEnabled() {
+			b.logger.Debug(fmt.Sprintf("В зоне %s нет доступных бэкендов, запрос направляется в другие зоны", zone))
+		}
+		b.traceCandidates(req, "", version)
+		return backends
+	}
+	b.traceCandidates(req, zone, version)
+	return inZone
+}
+
+// traceCandidates сохраняет в запросе состояние всех бэкендов и причины исключения
+// из выбора, если для запроса включена отладка маршрутизации. zone и version — зона
+// клиента и версия бэкендов запроса: бэкенды других зон и версий не участвовали в выборе.
+func (b *BaseLoadBalancer) traceCandidates(req request.Request, zone, version string) {
+	if !Tracing(req) {
+		return
+	}
+
+	routed, _ := request.Get(req, request.KeyBackends)
+	states := b.backends.Load().list
+	descriptions := make([]string, 0, len(states))
+	for _, state := range states {
+		be := state.Backend
+		status := "candidate"
+		switch {
+		case routed != nil && !routed[be.ID()]:
+			status = "other route"
+		case !be.IsAlive():
+			status = "unhealthy"
+		case be.InMaintenance():
+			status = "maintenance"
+		case be.IsDraining():
+			status = "draining"
+		case be.IsEjected():
+			status = "ejected"
+		case backend.IsSaturated(be):
+			status = "saturated"
+		case version != "" && be.HealthMetadata().Version != version:
+			status = "other version"
+		case zone != "" && be.Zone() != zone:
+			status = "other zone"
+		}
+
+		connections := fmt.Sprintf("%d", be.GetLoadStats().ActiveConnections)
+		if limit := be.MaxConnections(); limit > 0 {
+			connections += fmt.Sprintf("/%d", limit)
+		}
+		description := fmt.Sprintf("%s(%s, weight=%.2f, connections=%s", be.ID(), status, be.Weight(), connections)
+		if be.Zone() != "" {
+			description += ", zone=" + be.Zone()
+		}
+		if metadata := be.HealthMetadata(); metadata.Version != "" {
+			description += ", version=" + metadata.Version
+		}
+		descriptions = append(descriptions, description+")")
+	}
+	request.Set(req, KeyCandidates, strings.Join(descriptions, ", "))
+}
+
 // Logger возвращает логгер
-func (b *BaseLoadBalancer) Logger() *logger.CustomZapLogger {
+func (b *BaseLoadBalancer) Logger() logger.Logger {
 	return b.logger
 }
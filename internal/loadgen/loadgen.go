@@ -0,0 +1,304 @@
+// Package loadgen подает на прокси нагрузку с заданными частотой, параллелизмом и набором
+// запросов и считает процентили задержки и долю ошибок. Используется утилитой cmd/loadgen
+// и бенчмарками прокси.
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RequestSpec вид запроса в наборе нагрузки
+type RequestSpec struct {
+	Method string
+	Path   string
+
+	// Доля запроса в наборе относительно весов остальных запросов
+	Weight int
+}
+
+// ParseMix разбирает набор запросов вида "8:GET /,2:POST /api/orders": запросы через запятую,
+// перед каждым может быть указан вес и двоеточие (по умолчанию вес 1)
+func ParseMix(s string) ([]RequestSpec, error) {
+	var mix []RequestSpec
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		spec := RequestSpec{Weight: 1}
+		if weight, rest, found := strings.Cut(item, ":"); found && !strings.HasPrefix(rest, "/") {
+			w, err := strconv.Atoi(weight)
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf("invalid weight in %q", item)
+			}
+			spec.Weight = w
+			item = strings.TrimSpace(rest)
+		}
+
+		method, path, found := strings.Cut(item, " ")
+		path = strings.TrimSpace(path)
+		if !found || method == "" || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid request %q: expected \"METHOD /path\"", item)
+		}
+		spec.Method = strings.ToUpper(method)
+		spec.Path = path
+		mix = append(mix, spec)
+	}
+	if len(mix) == 0 {
+		return nil, errors.New("request mix is empty")
+	}
+	return mix, nil
+}
+
+// Options параметры нагрузки
+type Options struct {
+	// Адрес прокси, например http://localhost:8080
+	URL string
+
+	// Запросов в секунду (0 — без ограничения, столько, сколько успевают обработать)
+	Rate float64
+
+	// Количество одновременно отправляющих запросы клиентов
+	Concurrency int
+
+	// Продолжительность нагрузки. Если задано Requests, нагрузка завершается раньше,
+	// когда отправлено Requests запросов.
+	Duration time.Duration
+	Requests int
+
+	// Набор запросов (по умолчанию — только GET /)
+	Mix []RequestSpec
+
+	// Размер тела запросов с методами POST, PUT и PATCH
+	BodySize int
+
+	// Таймаут одного запроса (по умолчанию 10 секунд)
+	Timeout time.Duration
+}
+
+// defaultTimeout таймаут запроса по умолчанию
+const defaultTimeout = 10 * time.Second
+
+// Latency распределение задержки ответов
+type Latency struct {
+	Mean time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P99  time.Duration
+	P999 time.Duration
+	Max  time.Duration
+}
+
+// Report результаты нагрузки
+type Report struct {
+	// Запросы, на которые получен ответ, по статусам
+	Statuses map[int]int64
+	Requests int64
+
+	// Запросы, завершившиеся ошибкой соединения или таймаутом
+	Errors int64
+
+	Elapsed time.Duration
+	Latency Latency
+}
+
+// RPS возвращает фактическую частоту запросов
+func (r *Report) RPS() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Requests+r.Errors) / r.Elapsed.Seconds()
+}
+
+// ErrorRate возвращает долю запросов, завершившихся ошибкой соединения или ответом 5xx
+func (r *Report) ErrorRate() float64 {
+	total := r.Requests + r.Errors
+	if total == 0 {
+		return 0
+	}
+	failed := r.Errors
+	for status, count := range r.Statuses {
+		if status >= 500 {
+			failed += count
+		}
+	}
+	return float64(failed) / float64(total)
+}
+
+// workerResult результаты одного клиента; объединяются после завершения нагрузки
+type workerResult struct {
+	latencies []time.Duration
+	statuses  map[int]int64
+	errors    int64
+}
+
+// Run подает нагрузку на opts.URL и возвращает результаты. Нагрузка завершается по истечении
+// opts.Duration, после отправки opts.Requests запросов или при отмене ctx.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.URL == "" {
+		return nil, errors.New("target URL is required")
+	}
+	if opts.Duration <= 0 && opts.Requests <= 0 {
+		return nil, errors.New("either duration or number of requests is required")
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	mix := opts.Mix
+	if len(mix) == 0 {
+		mix = []RequestSpec{{Method: http.MethodGet, Path: "/", Weight: 1}}
+	}
+	totalWeight := 0
+	for _, spec := range mix {
+		totalWeight += spec.Weight
+	}
+	body := bytes.Repeat([]byte("x"), opts.BodySize)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = opts.Concurrency
+	client := &http.Client{Transport: transport, Timeout: opts.Timeout}
+	defer transport.CloseIdleConnections()
+
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	var limiter *rate.Limiter
+	if opts.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.Rate), 1)
+	}
+
+	var issued atomic.Int64
+	results := make([]workerResult, opts.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range results {
+		wg.Add(1)
+		go func(result *workerResult, seed uint64) {
+			defer wg.Done()
+			result.statuses = make(map[int]int64)
+			random := rand.New(rand.NewPCG(seed, uint64(start.UnixNano())))
+
+			for {
+				if opts.Requests > 0 && issued.Add(1) > int64(opts.Requests) {
+					return
+				}
+				if limiter != nil && limiter.Wait(ctx) != nil {
+					return
+				}
+				if ctx.Err() != nil {
+					return
+				}
+
+				spec := pick(mix, random.IntN(totalWeight))
+				var reqBody io.Reader
+				if opts.BodySize > 0 && (spec.Method == http.MethodPost || spec.Method == http.MethodPut || spec.Method == http.MethodPatch) {
+					reqBody = bytes.NewReader(body)
+				}
+				req, err := http.NewRequestWithContext(ctx, spec.Method, opts.URL+spec.Path, reqBody)
+				if err != nil {
+					result.errors++
+					continue
+				}
+
+				sent := time.Now()
+				resp, err := client.Do(req)
+				if err != nil {
+					// Запросы, прерванные окончанием нагрузки, не учитываются
+					if ctx.Err() != nil {
+						return
+					}
+					result.errors++
+					continue
+				}
+				_, err = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					result.errors++
+					continue
+				}
+				result.latencies = append(result.latencies, time.Since(sent))
+				result.statuses[resp.StatusCode]++
+			}
+		}(&results[i], uint64(i))
+	}
+	wg.Wait()
+
+	return newReport(results, time.Since(start)), nil
+}
+
+// pick выбирает запрос набора по числу n из [0, сумма весов)
+func pick(mix []RequestSpec, n int) RequestSpec {
+	for _, spec := range mix {
+		if n < spec.Weight {
+			return spec
+		}
+		n -= spec.Weight
+	}
+	return mix[len(mix)-1]
+}
+
+// newReport объединяет результаты клиентов
+func newReport(results []workerResult, elapsed time.Duration) *Report {
+	report := &Report{Statuses: make(map[int]int64), Elapsed: elapsed}
+	var latencies []time.Duration
+	for _, result := range results {
+		latencies = append(latencies, result.latencies...)
+		for status, count := range result.statuses {
+			report.Statuses[status] += count
+		}
+		report.Errors += result.errors
+	}
+	report.Requests = int64(len(latencies))
+	if len(latencies) == 0 {
+		return report
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var sum time.Duration
+	for _, latency := range latencies {
+		sum += latency
+	}
+	report.Latency = Latency{
+		Mean: sum / time.Duration(len(latencies)),
+		P50:  percentile(latencies, 0.5),
+		P90:  percentile(latencies, 0.9),
+		P99:  percentile(latencies, 0.99),
+		P999: percentile(latencies, 0.999),
+		Max:  latencies[len(latencies)-1],
+	}
+	return report
+}
+
+// percentile возвращает процентиль p отсортированных задержек
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
@@ -0,0 +1,94 @@
+package loadgen
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParseMix(t *testing.T) {
+	mix, err := ParseMix("8:GET /, post /api/orders?id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mix) != 2 ||
+		mix[0] != (RequestSpec{Method: "GET", Path: "/", Weight: 8}) ||
+		mix[1] != (RequestSpec{Method: "POST", Path: "/api/orders?id=1", Weight: 1}) {
+		t.Errorf("неверный набор запросов: %+v", mix)
+	}
+
+	for _, invalid := range []string{"", "GET", "0:GET /", "x:GET /", "GET api"} {
+		if _, err := ParseMix(invalid); err == nil {
+			t.Errorf("набор %q должен возвращать ошибку", invalid)
+		}
+	}
+}
+
+// startBackends запускает count бэкендов, отвечающих 200 OK
+func startBackends(tb testing.TB, count int) []string {
+	urls := make([]string, count)
+	for i := range urls {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}))
+		tb.Cleanup(server.Close)
+		urls[i] = server.URL
+	}
+	return urls
+}
+
+func TestRun(t *testing.T) {
+	proxy, err := StartProxy("RoundRobin", startBackends(t, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+
+	report, err := Run(context.Background(), Options{URL: proxy.URL, Concurrency: 4, Requests: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests != 200 || report.Statuses[http.StatusOK] != 200 || report.ErrorRate() != 0 {
+		t.Errorf("ожидалось 200 успешных запросов: %+v", report)
+	}
+	latency := report.Latency
+	if latency.P50 <= 0 || latency.P50 > latency.P99 || latency.P99 > latency.Max {
+		t.Errorf("некорректные процентили задержки: %+v", latency)
+	}
+
+	// Ограничение частоты: за 300 мс при 50 RPS отправляется около 15 запросов
+	report, err = Run(context.Background(), Options{URL: proxy.URL, Concurrency: 4, Rate: 50, Duration: 300 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests < 5 || report.Requests > 25 {
+		t.Errorf("при 50 RPS за 300 мс отправлено %d запросов", report.Requests)
+	}
+}
+
+func benchmarkProxy(b *testing.B, method string) {
+	proxy, err := StartProxy(method, startBackends(b, 3))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer proxy.Close()
+
+	b.ResetTimer()
+	report, err := Run(context.Background(), Options{URL: proxy.URL, Concurrency: 4 * runtime.GOMAXPROCS(0), Requests: b.N})
+	b.StopTimer()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if rate := report.ErrorRate(); rate > 0 {
+		b.Errorf("доля ошибок %.4f", rate)
+	}
+	b.ReportMetric(float64(report.Latency.P50.Microseconds()), "p50-µs")
+	b.ReportMetric(float64(report.Latency.P99.Microseconds()), "p99-µs")
+}
+
+func BenchmarkProxy_RoundRobin(b *testing.B)         { benchmarkProxy(b, "RoundRobin") }
+func BenchmarkProxy_WeightedRoundRobin(b *testing.B) { benchmarkProxy(b, "WeightedRoundRobin") }
+func BenchmarkProxy_LeastConnections(b *testing.B)   { benchmarkProxy(b, "LeastConnections") }
@@ -0,0 +1,40 @@
+package loadgen
+
+import (
+	"fmt"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/proxy"
+)
+
+// Proxy прокси, запущенный в текущем процессе для сравнения алгоритмов балансировки
+type Proxy struct {
+	// Адрес, на который подается нагрузка
+	URL string
+
+	embedded *proxy.Proxy
+}
+
+// StartProxy запускает на свободном локальном порту прокси с методом балансировки method
+// и бэкендами backendURLs. Middleware, ограничение частоты и логирование отключены,
+// чтобы измерялись только балансировка и проксирование.
+func StartProxy(method string, backendURLs []string) (*Proxy, error) {
+	opts := []proxy.Option{proxy.WithBalancer(method), proxy.WithListener("127.0.0.1:0")}
+	for i, url := range backendURLs {
+		opts = append(opts, proxy.WithBackend(config.BackendConfig{ID: fmt.Sprintf("backend%d", i+1), URL: url}))
+	}
+
+	embedded, err := proxy.New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := embedded.Start(); err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	return &Proxy{URL: "http://" + embedded.Addr(), embedded: embedded}, nil
+}
+
+// Close останавливает прокси
+func (p *Proxy) Close() error {
+	return p.embedded.Close()
+}
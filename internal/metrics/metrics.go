@@ -0,0 +1,222 @@
+// Package metrics содержит счетчики событий прокси, показатели состояния и их вывод
+// в текстовом формате Prometheus
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry набор именованных счетчиков
+type Registry struct {
+	mu       sync.RWMutex
+	counters map[string]*CounterVec
+}
+
+// Default реестр, в котором регистрируются счетчики подсистем прокси
+var Default = NewRegistry()
+
+// NewRegistry создает пустой реестр
+func NewRegistry() *Registry {
+	return &Registry{counters: make(map[string]*CounterVec)}
+}
+
+// CounterVec счетчик с метками, например lb_waf_hits_total{rule="sqli"}
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+
+	// Показатель (gauge): значение устанавливается методом Set и может уменьшаться
+	gauge bool
+
+	mu     sync.RWMutex
+	values map[string]*counterValue
+}
+
+// counterValue значение счетчика для набора меток
+type counterValue struct {
+	labels []string
+	value  atomic.Int64
+}
+
+// Counter возвращает счетчик с указанным именем, создавая его при первом обращении.
+// Повторные вызовы с тем же именем возвращают тот же счетчик.
+func (r *Registry) Counter(name, help string, labelNames ...string) *CounterVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := r.counters[name]; ok {
+		return c
+	}
+	c := &CounterVec{name: name, help: help, labelNames: labelNames, values: make(map[string]*counterValue)}
+	r.counters[name] = c
+	return c
+}
+
+// Gauge возвращает показатель состояния с указанным именем, например lb_ha_leader.
+// В отличие от счетчика его значение устанавливается методом Set.
+func (r *Registry) Gauge(name, help string, labelNames ...string) *CounterVec {
+	c := r.Counter(name, help, labelNames...)
+	c.gauge = true
+	return c
+}
+
+// value возвращает значение для набора меток, создавая его при первом обращении
+func (c *CounterVec) value(labelValues []string) *counterValue {
+	key := strings.Join(labelValues, "\xff")
+
+	c.mu.RLock()
+	v, ok := c.values[key]
+	c.mu.RUnlock()
+	if !ok {
+		c.mu.Lock()
+		if v, ok = c.values[key]; !ok {
+			v = &counterValue{labels: append([]string(nil), labelValues...)}
+			c.values[key] = v
+		}
+		c.mu.Unlock()
+	}
+	return v
+}
+
+// Add увеличивает счетчик для значений меток labelValues (в порядке labelNames)
+func (c *CounterVec) Add(delta int64, labelValues ...string) {
+	c.value(labelValues).value.Add(delta)
+}
+
+// Set устанавливает значение показателя для значений меток labelValues
+func (c *CounterVec) Set(value int64, labelValues ...string) {
+	c.value(labelValues).value.Store(value)
+}
+
+// Inc увеличивает счетчик на 1
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value возвращает текущее значение счетчика для значений меток
+func (c *CounterVec) Value(labelValues ...string) int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if v, ok := c.values[strings.Join(labelValues, "\xff")]; ok {
+		return v.value.Load()
+	}
+	return 0
+}
+
+// WritePrometheus выводит все счетчики в текстовом формате Prometheus
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.counters))
+	for name := range r.counters {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		r.mu.RLock()
+		c := r.counters[name]
+		r.mu.RUnlock()
+
+		typ := "counter"
+		if c.gauge {
+			typ = "gauge"
+		}
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", c.name, c.help, c.name, typ); err != nil {
+			return err
+		}
+
+		c.mu.RLock()
+		keys := make([]string, 0, len(c.values))
+		for key := range c.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			v := c.values[key]
+			if _, err := fmt.Fprintf(w, "%s%s %d\n", c.name, formatLabels(c.labelNames, v.labels), v.value.Load()); err != nil {
+				c.mu.RUnlock()
+				return err
+			}
+		}
+		c.mu.RUnlock()
+	}
+	return nil
+}
+
+// labelEscaper экранирует значения меток
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels форматирует метки как {name="value",...}
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		value = labelEscaper.Replace(value)
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Sample значение счетчика для набора меток
+type Sample struct {
+	Name   string
+	Help   string
+	Labels []Label
+	Value  int64
+
+	// Значение показателя, а не накопленный счетчик
+	Gauge bool
+}
+
+// Label метка значения счетчика
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Samples возвращает текущие значения всех счетчиков, упорядоченные по имени и меткам
+func (r *Registry) Samples() []Sample {
+	r.mu.RLock()
+	counters := make([]*CounterVec, 0, len(r.counters))
+	for _, c := range r.counters {
+		counters = append(counters, c)
+	}
+	r.mu.RUnlock()
+	sort.Slice(counters, func(i, j int) bool { return counters[i].name < counters[j].name })
+
+	var samples []Sample
+	for _, c := range counters {
+		c.mu.RLock()
+		keys := make([]string, 0, len(c.values))
+		for key := range c.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			v := c.values[key]
+			labels := make([]Label, len(c.labelNames))
+			for i, name := range c.labelNames {
+				labels[i].Name = name
+				if i < len(v.labels) {
+					labels[i].Value = v.labels[i]
+				}
+			}
+			samples = append(samples, Sample{Name: c.name, Help: c.help, Labels: labels, Value: v.value.Load(), Gauge: c.gauge})
+		}
+		c.mu.RUnlock()
+	}
+	return samples
+}
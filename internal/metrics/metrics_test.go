@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	hits := r.Counter("lb_test_hits_total", "Test hits", "rule")
+	hits.Inc("sqli")
+	hits.Add(2, `x"y`)
+	leader := r.Gauge("lb_test_leader", "Test leader")
+	leader.Set(1)
+	leader.Set(0)
+	if r.Counter("lb_test_hits_total", "Test hits", "rule") != hits {
+		t.Error("повторная регистрация должна возвращать тот же счетчик")
+	}
+
+	var out strings.Builder
+	if err := r.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP lb_test_hits_total Test hits
+# TYPE lb_test_hits_total counter
+lb_test_hits_total{rule="sqli"} 1
+lb_test_hits_total{rule="x\"y"} 2
+# HELP lb_test_leader Test leader
+# TYPE lb_test_leader gauge
+lb_test_leader 0
+`
+	if out.String() != want {
+		t.Errorf("неверный вывод:\n%s", out.String())
+	}
+}
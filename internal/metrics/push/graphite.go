@@ -0,0 +1,71 @@
+package push
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+)
+
+// graphiteEscaper заменяет символы, недопустимые в имени и тегах Graphite
+var graphiteEscaper = strings.NewReplacer(" ", "_", ";", "_", "=", "_", "~", "_", "\n", "_")
+
+// graphite отправляет значения счетчиков в plaintext протоколе Graphite по TCP; метки
+// передаются тегами: lb_requests_total;method=GET 42 1700000000
+type graphite struct {
+	address string
+	prefix  string
+	tags    string
+}
+
+func newGraphite(cfg config.MetricsPushConfig) *graphite {
+	g := &graphite{address: cfg.Address, prefix: cfg.Prefix}
+	names := make([]string, 0, len(cfg.Tags))
+	for name := range cfg.Tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.tags += ";" + graphiteEscaper.Replace(name) + "=" + graphiteEscaper.Replace(cfg.Tags[name])
+	}
+	return g
+}
+
+// export устанавливает соединение на каждую отправку: Graphite закрывает простаивающие
+// соединения, а интервал отправки обычно измеряется секундами
+func (g *graphite) export(ctx context.Context, samples []metrics.Sample, now time.Time) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", g.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	w := bufio.NewWriter(conn)
+	for _, sample := range samples {
+		fmt.Fprintf(w, "%s%s%s", g.prefix, graphiteEscaper.Replace(sample.Name), g.tags)
+		for _, l := range sample.Labels {
+			value := graphiteEscaper.Replace(l.Value)
+			if value == "" {
+				// Graphite не принимает пустые значения тегов
+				value = "none"
+			}
+			fmt.Fprintf(w, ";%s=%s", l.Name, value)
+		}
+		fmt.Fprintf(w, " %d %d\n", sample.Value, now.Unix())
+	}
+	return w.Flush()
+}
+
+func (g *graphite) close() error {
+	return nil
+}
@@ -0,0 +1,158 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+)
+
+// Временная агрегация OTLP: значения накапливаются с момента запуска процесса
+const aggregationTemporalityCumulative = 2
+
+// otlp отправляет значения счетчиков монотонными суммами в JSON кодировке OTLP/HTTP
+type otlp struct {
+	url      string
+	headers  map[string]string
+	prefix   string
+	resource []otlpAttribute
+	start    string
+	client   *http.Client
+}
+
+// Сообщения OTLP в JSON кодировке (opentelemetry/proto/collector/metrics/v1)
+type (
+	otlpRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScope    `json:"scope"`
+		Metrics []otlpMetric `json:"metrics"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpMetric struct {
+		Name        string  `json:"name"`
+		Description string  `json:"description,omitempty"`
+		Sum         otlpSum `json:"sum"`
+	}
+	otlpSum struct {
+		DataPoints             []otlpDataPoint `json:"dataPoints"`
+		AggregationTemporality int             `json:"aggregationTemporality"`
+		IsMonotonic            bool            `json:"isMonotonic"`
+	}
+	otlpDataPoint struct {
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		TimeUnixNano      string          `json:"timeUnixNano"`
+		AsInt             string          `json:"asInt"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+)
+
+func newOTLP(cfg config.MetricsPushConfig) *otlp {
+	o := &otlp{
+		url:     cfg.Address,
+		headers: cfg.Headers,
+		prefix:  cfg.Prefix,
+		start:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		client:  &http.Client{},
+	}
+
+	tags := map[string]string{"service.name": "cloud.ru_test"}
+	if hostname, err := os.Hostname(); err == nil {
+		tags["service.instance.id"] = hostname + "-" + strconv.Itoa(os.Getpid())
+	}
+	for name, value := range cfg.Tags {
+		tags[name] = value
+	}
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		o.resource = append(o.resource, otlpAttribute{Key: name, Value: otlpValue{StringValue: tags[name]}})
+	}
+	return o
+}
+
+func (o *otlp) export(ctx context.Context, samples []metrics.Sample, now time.Time) error {
+	timestamp := strconv.FormatInt(now.UnixNano(), 10)
+	var list []otlpMetric
+	for _, sample := range samples {
+		// Значения одного счетчика идут подряд: Samples упорядочивает их по имени
+		if len(list) == 0 || list[len(list)-1].Name != o.prefix+sample.Name {
+			list = append(list, otlpMetric{
+				Name:        o.prefix + sample.Name,
+				Description: sample.Help,
+				// Показатель передается немонотонной суммой: его значение может уменьшаться
+				Sum: otlpSum{AggregationTemporality: aggregationTemporalityCumulative, IsMonotonic: !sample.Gauge},
+			})
+		}
+		point := otlpDataPoint{StartTimeUnixNano: o.start, TimeUnixNano: timestamp, AsInt: strconv.FormatInt(sample.Value, 10)}
+		for _, l := range sample.Labels {
+			point.Attributes = append(point.Attributes, otlpAttribute{Key: l.Name, Value: otlpValue{StringValue: l.Value}})
+		}
+		sum := &list[len(list)-1].Sum
+		sum.DataPoints = append(sum.DataPoints, point)
+	}
+	if len(list) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     otlpResource{Attributes: o.resource},
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: "cloud.ru_test/internal/metrics"}, Metrics: list}},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range o.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (o *otlp) close() error {
+	o.client.CloseIdleConnections()
+	return nil
+}
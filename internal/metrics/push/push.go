@@ -0,0 +1,141 @@
+// Package push периодически отправляет счетчики прокси в системы мониторинга,
+// которые не опрашивают /metrics: StatsD, DogStatsD, Graphite и приемники OTLP
+package push
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Параметры отправки по умолчанию
+const (
+	defaultInterval = 10 * time.Second
+	requestTimeout  = 5 * time.Second
+)
+
+// pushErrors счетчик неудачных отправок метрик по протоколам
+var pushErrors = metrics.Default.Counter("lb_metrics_push_errors_total", "Failed metrics push attempts by exporter type", "type")
+
+// exporter отправляет значения счетчиков в конкретном протоколе
+type exporter interface {
+	export(ctx context.Context, samples []metrics.Sample, now time.Time) error
+	close() error
+}
+
+// Pusher раз в интервал отправляет значения счетчиков реестра одному приемнику
+type Pusher struct {
+	typ      string
+	address  string
+	interval time.Duration
+	registry *metrics.Registry
+	exporter exporter
+	logger   logger.Logger
+
+	started atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// New создает отправку метрик реестра registry по настройкам cfg
+func New(cfg config.MetricsPushConfig, registry *metrics.Registry, appLogger logger.Logger) (*Pusher, error) {
+	var (
+		e   exporter
+		err error
+	)
+	switch cfg.Type {
+	case "statsd", "dogstatsd":
+		e, err = newStatsd(cfg)
+	case "graphite":
+		e = newGraphite(cfg)
+	case "otlp":
+		e = newOTLP(cfg)
+	default:
+		return nil, fmt.Errorf("metrics push: unsupported type %q", cfg.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	interval := cfg.Interval
+	if interval == 0 {
+		interval = defaultInterval
+	}
+	return &Pusher{
+		typ:      cfg.Type,
+		address:  cfg.Address,
+		interval: interval,
+		registry: registry,
+		exporter: e,
+		logger:   appLogger,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start запускает периодическую отправку
+func (p *Pusher) Start() {
+	if !p.started.CompareAndSwap(false, true) {
+		return
+	}
+	if s, ok := p.exporter.(*statsd); ok {
+		s.baseline(p.registry.Samples())
+	}
+	go p.run()
+}
+
+// Stop останавливает отправку, перед этим отправив последние значения счетчиков.
+// Незапущенная отправка только закрывает соединение с приемником.
+func (p *Pusher) Stop() {
+	p.once.Do(func() {
+		close(p.stop)
+		if p.started.CompareAndSwap(false, true) {
+			p.exporter.close()
+			close(p.done)
+		}
+	})
+	<-p.done
+}
+
+func (p *Pusher) run() {
+	defer close(p.done)
+	defer p.exporter.close()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.push()
+		case <-p.stop:
+			p.push()
+			return
+		}
+	}
+}
+
+// push отправляет текущие значения счетчиков; ошибка не прерывает последующие отправки
+func (p *Pusher) push() {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err := p.exporter.export(ctx, p.registry.Samples(), time.Now()); err != nil {
+		pushErrors.Inc(p.typ)
+		p.logger.Warn(fmt.Sprintf("Не удалось отправить метрики %s на %s: %v", p.typ, p.address, err))
+	}
+}
+
+// sampleKey идентифицирует значение счетчика среди остальных
+func sampleKey(s metrics.Sample) string {
+	key := s.Name
+	for _, l := range s.Labels {
+		key += "\xff" + l.Value
+	}
+	return key
+}
@@ -0,0 +1,173 @@
+package push
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestPusher_Statsd(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	registry := metrics.NewRegistry()
+	hits := registry.Counter("lb_test_hits_total", "Test hits", "rule")
+	hits.Add(5, "sqli")
+
+	for _, tc := range []struct {
+		typ  string
+		want string
+	}{
+		{"statsd", "lb.lb_test_hits_total.rule.sqli:2|c"},
+		{"dogstatsd", "lb.lb_test_hits_total:2|c|#env:test,rule:sqli"},
+	} {
+		pusher, err := New(config.MetricsPushConfig{
+			Type:    tc.typ,
+			Address: conn.LocalAddr().String(),
+			Prefix:  "lb.",
+			Tags:    map[string]string{"env": "test"},
+		}, registry, logger.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		pusher.Start()
+		hits.Add(2, "sqli")
+		pusher.Stop()
+
+		// Значения до запуска уже отправлены предыдущей отправкой и не повторяются
+		buf := make([]byte, maxPacketSize)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("%s: пакет не получен: %v", tc.typ, err)
+		}
+		if got := string(buf[:n]); got != tc.want {
+			t.Errorf("%s: получено %q, ожидалось %q", tc.typ, got, tc.want)
+		}
+	}
+}
+
+func TestPusher_Graphite(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	registry := metrics.NewRegistry()
+	registry.Counter("lb_test_hits_total", "Test hits", "rule").Add(3, "")
+
+	pusher, err := New(config.MetricsPushConfig{Type: "graphite", Address: ln.Addr().String(), Tags: map[string]string{"dc": "a b"}}, registry, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pusher.Start()
+	pusher.Stop()
+
+	select {
+	case line := <-lines:
+		if !strings.HasPrefix(line, "lb_test_hits_total;dc=a_b;rule=none 3 ") {
+			t.Errorf("неверная строка Graphite: %q", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("метрики не получены")
+	}
+}
+
+func TestPusher_OTLP(t *testing.T) {
+	requests := make(chan otlpRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("заголовки приемника не переданы")
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("неверный JSON: %v", err)
+		}
+		requests <- req
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	hits := registry.Counter("lb_test_hits_total", "Test hits", "rule")
+	hits.Add(1, "sqli")
+	hits.Add(2, "xss")
+
+	pusher, err := New(config.MetricsPushConfig{
+		Type:    "otlp",
+		Address: server.URL,
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	}, registry, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pusher.Start()
+	pusher.Stop()
+
+	req := <-requests
+	list := req.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(list) != 1 || list[0].Name != "lb_test_hits_total" {
+		t.Fatalf("неверный список метрик: %+v", list)
+	}
+	sum := list[0].Sum
+	if !sum.IsMonotonic || sum.AggregationTemporality != aggregationTemporalityCumulative {
+		t.Errorf("счетчик должен передаваться накопленной монотонной суммой: %+v", sum)
+	}
+	if len(sum.DataPoints) != 2 || sum.DataPoints[1].AsInt != "2" || sum.DataPoints[1].Attributes[0].Value.StringValue != "xss" {
+		t.Errorf("неверные значения: %+v", sum.DataPoints)
+	}
+}
+
+func TestPusher_StopWithoutStart(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	pusher, err := New(config.MetricsPushConfig{Type: "statsd", Address: conn.LocalAddr().String()}, metrics.NewRegistry(), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		pusher.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("остановка незапущенной отправки не должна блокироваться")
+	}
+	if err := pusher.exporter.(*statsd).conn.Close(); err == nil {
+		t.Error("остановка незапущенной отправки должна закрывать соединение с приемником")
+	}
+
+	// Запуск после остановки не возобновляет отправку
+	pusher.Start()
+	pusher.Stop()
+}
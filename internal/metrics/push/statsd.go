@@ -0,0 +1,125 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+)
+
+// maxPacketSize размер UDP пакета StatsD, не требующий фрагментации в типичной сети
+const maxPacketSize = 1432
+
+// statsdNameEscaper заменяет символы, которые StatsD интерпретирует как разделители
+var statsdNameEscaper = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_")
+
+// statsd отправляет приращения счетчиков по UDP. В формате StatsD метки входят в имя
+// метрики (lb_requests_total.method.GET), в DogStatsD передаются тегами (|#method:GET).
+type statsd struct {
+	conn   net.Conn
+	dog    bool
+	prefix string
+	tags   string
+
+	// Значения счетчиков при предыдущей отправке
+	last map[string]int64
+}
+
+func newStatsd(cfg config.MetricsPushConfig) (*statsd, error) {
+	conn, err := net.Dial("udp", cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("metrics push: %w", err)
+	}
+	s := &statsd{conn: conn, dog: cfg.Type == "dogstatsd", prefix: cfg.Prefix, last: make(map[string]int64)}
+
+	names := make([]string, 0, len(cfg.Tags))
+	for name := range cfg.Tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.tags += "," + statsdNameEscaper.Replace(name) + ":" + statsdNameEscaper.Replace(cfg.Tags[name])
+	}
+	return s, nil
+}
+
+// baseline запоминает текущие значения счетчиков, чтобы не отправлять повторно то,
+// что уже отправила предыдущая отправка метрик после реконфигурации
+func (s *statsd) baseline(samples []metrics.Sample) {
+	for _, sample := range samples {
+		s.last[sampleKey(sample)] = sample.Value
+	}
+}
+
+func (s *statsd) export(ctx context.Context, samples []metrics.Sample, now time.Time) error {
+	var packet bytes.Buffer
+	for _, sample := range samples {
+		key := sampleKey(sample)
+		delta := sample.Value - s.last[key]
+		if delta == 0 {
+			continue
+		}
+		value := delta
+		if sample.Gauge {
+			// Показатель отправляется текущим значением, а не приращением
+			value = sample.Value
+		}
+		line := s.line(sample, value)
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxPacketSize {
+			if _, err := s.conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+		s.last[key] = sample.Value
+	}
+	if packet.Len() > 0 {
+		if _, err := s.conn.Write(packet.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// line форматирует приращение счетчика или значение показателя строкой протокола
+func (s *statsd) line(sample metrics.Sample, value int64) string {
+	var b strings.Builder
+	b.WriteString(s.prefix)
+	b.WriteString(statsdNameEscaper.Replace(sample.Name))
+	if !s.dog {
+		for _, l := range sample.Labels {
+			b.WriteString("." + l.Name + "." + statsdNameEscaper.Replace(strings.ReplaceAll(l.Value, ".", "_")))
+		}
+	}
+	kind := "|c"
+	if sample.Gauge {
+		kind = "|g"
+	}
+	b.WriteString(":" + strconv.FormatInt(value, 10) + kind)
+
+	if s.dog {
+		tags := s.tags
+		for _, l := range sample.Labels {
+			tags += "," + l.Name + ":" + statsdNameEscaper.Replace(l.Value)
+		}
+		if tags != "" {
+			b.WriteString("|#" + tags[1:])
+		}
+	}
+	return b.String()
+}
+
+func (s *statsd) close() error {
+	return s.conn.Close()
+}
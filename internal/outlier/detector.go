@@ -0,0 +1,284 @@
+package outlier
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Значения параметров по умолчанию (как в Envoy)
+const (
+	defaultConsecutive5xx           = 5
+	defaultInterval                 = 10 * time.Second
+	defaultBaseEjectionTime         = 30 * time.Second
+	defaultMaxEjectionTime          = 300 * time.Second
+	defaultMaxEjectionPercent       = 10
+	defaultSuccessRateMinimumHosts  = 5
+	defaultSuccessRateRequestVolume = 100
+	defaultSuccessRateStdevFactor   = 1.9
+)
+
+// hostState статистика бэкенда за текущий интервал и история исключений
+type hostState struct {
+	backend           backend.Backend
+	consecutiveErrors int
+	requests          int
+	successes         int
+
+	// Число исключений подряд, определяет длительность следующего исключения
+	ejections    int
+	ejectedUntil time.Time
+}
+
+// Detector пассивно отслеживает ответы бэкендов и временно исключает из ротации
+// бэкенды с серией ошибок или долей успешных ответов заметно ниже остальных
+type Detector struct {
+	lb     loadbalancer.LoadBalancer
+	logger logger.Logger
+
+	consecutive5xx           int
+	interval                 time.Duration
+	baseEjectionTime         time.Duration
+	maxEjectionTime          time.Duration
+	maxEjectionPercent       int
+	successRateMinimumHosts  int
+	successRateRequestVolume int
+	successRateStdevFactor   float64
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+	now   func() time.Time
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New создает детектор выбросов. Незаданные параметры заменяются значениями по умолчанию.
+func New(cfg *config.OutlierDetectionConfig, lb loadbalancer.LoadBalancer, appLogger logger.Logger) *Detector {
+	d := &Detector{
+		lb:                       lb,
+		logger:                   appLogger,
+		consecutive5xx:           defaultConsecutive5xx,
+		interval:                 defaultInterval,
+		baseEjectionTime:         defaultBaseEjectionTime,
+		maxEjectionTime:          defaultMaxEjectionTime,
+		maxEjectionPercent:       defaultMaxEjectionPercent,
+		successRateMinimumHosts:  defaultSuccessRateMinimumHosts,
+		successRateRequestVolume: defaultSuccessRateRequestVolume,
+		successRateStdevFactor:   defaultSuccessRateStdevFactor,
+		hosts:                    make(map[string]*hostState),
+		now:                      time.Now,
+		stopCh:                   make(chan struct{}),
+	}
+
+	if cfg != nil {
+		if cfg.Consecutive5xx > 0 {
+			d.consecutive5xx = cfg.Consecutive5xx
+		}
+		if cfg.Interval > 0 {
+			d.interval = cfg.Interval
+		}
+		if cfg.BaseEjectionTime > 0 {
+			d.baseEjectionTime = cfg.BaseEjectionTime
+		}
+		if cfg.MaxEjectionTime > 0 {
+			d.maxEjectionTime = cfg.MaxEjectionTime
+		}
+		if cfg.MaxEjectionPercent > 0 {
+			d.maxEjectionPercent = cfg.MaxEjectionPercent
+		}
+		if cfg.SuccessRateMinimumHosts > 0 {
+			d.successRateMinimumHosts = cfg.SuccessRateMinimumHosts
+		}
+		if cfg.SuccessRateRequestVolume > 0 {
+			d.successRateRequestVolume = cfg.SuccessRateRequestVolume
+		}
+		if cfg.SuccessRateStdevFactor > 0 {
+			d.successRateStdevFactor = cfg.SuccessRateStdevFactor
+		}
+	}
+
+	return d
+}
+
+// Start запускает периодический анализ в отдельной горутине
+func (d *Detector) Start() {
+	d.logger.Debug(fmt.Sprintf("Запуск обнаружения выбросов (интервал: %v, ошибок подряд: %d)", d.interval, d.consecutive5xx))
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				d.evaluate()
+			case <-d.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop останавливает анализ и возвращает в ротацию исключенные детектором бэкенды
+func (d *Detector) Stop() {
+	close(d.stopCh)
+	d.wg.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, host := range d.hosts {
+		host.backend.SetEjected(false)
+	}
+	d.logger.Debug("Обнаружение выбросов остановлено")
+}
+
+// Observe учитывает результат запроса к бэкенду. Ошибкой считаются ответ 5xx и ошибка соединения.
+func (d *Detector) Observe(b backend.Backend, statusCode int, err error) {
+	if d == nil {
+		return
+	}
+	failed := err != nil || statusCode >= http.StatusInternalServerError
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	host := d.host(b)
+	host.requests++
+	if !failed {
+		host.successes++
+		host.consecutiveErrors = 0
+		return
+	}
+
+	host.consecutiveErrors++
+	if host.consecutiveErrors >= d.consecutive5xx && !b.IsEjected() {
+		d.eject(host, fmt.Sprintf("%d ошибок подряд", host.consecutiveErrors))
+	}
+}
+
+// host возвращает статистику бэкенда; вызывается под d.mu
+func (d *Detector) host(b backend.Backend) *hostState {
+	host := d.hosts[b.ID()]
+	if host == nil || host.backend != b {
+		host = &hostState{backend: b}
+		d.hosts[b.ID()] = host
+	}
+	return host
+}
+
+// evaluate возвращает в ротацию бэкенды с истекшим временем исключения, исключает бэкенды
+// с низкой долей успешных ответов и начинает новый интервал; вызывается по таймеру
+func (d *Detector) evaluate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+
+	// Забываем бэкенды, удаленные из балансировщика
+	current := make(map[string]bool)
+	for _, state := range d.lb.GetBackends() {
+		current[state.Backend.ID()] = true
+	}
+	for id, host := range d.hosts {
+		if !current[id] {
+			host.backend.SetEjected(false)
+			delete(d.hosts, id)
+		}
+	}
+
+	for id, host := range d.hosts {
+		switch {
+		case host.backend.IsEjected() && !now.Before(host.ejectedUntil):
+			host.backend.SetEjected(false)
+			host.consecutiveErrors = 0
+			d.logger.Info(fmt.Sprintf("Бэкенд %s возвращен в ротацию после исключения", id))
+		case !host.backend.IsEjected() && host.ejections > 0 && now.Sub(host.ejectedUntil) > d.interval:
+			// Бэкенд стабильно работает после возврата: следующее исключение будет короче
+			host.ejections--
+		}
+	}
+
+	d.evaluateSuccessRate()
+
+	for _, host := range d.hosts {
+		host.requests, host.successes = 0, 0
+	}
+}
+
+// evaluateSuccessRate исключает бэкенды, доля успешных ответов которых ниже
+// mean - factor * stdev по бэкендам с достаточным числом запросов; вызывается под d.mu
+func (d *Detector) evaluateSuccessRate() {
+	var hosts []*hostState
+	for _, host := range d.hosts {
+		if !host.backend.IsEjected() && host.requests >= d.successRateRequestVolume {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) < d.successRateMinimumHosts || len(hosts) == 0 {
+		return
+	}
+
+	rates := make([]float64, len(hosts))
+	var mean float64
+	for i, host := range hosts {
+		rates[i] = float64(host.successes) / float64(host.requests)
+		mean += rates[i]
+	}
+	mean /= float64(len(hosts))
+
+	var variance float64
+	for _, rate := range rates {
+		variance += (rate - mean) * (rate - mean)
+	}
+	stdev := math.Sqrt(variance / float64(len(hosts)))
+	threshold := mean - d.successRateStdevFactor*stdev
+
+	for i, host := range hosts {
+		if rates[i] < threshold {
+			d.eject(host, fmt.Sprintf("доля успешных ответов %.2f ниже порога %.2f", rates[i], threshold))
+		}
+	}
+}
+
+// eject исключает бэкенд из ротации, если это не превысит допустимую долю
+// исключенных бэкендов; вызывается под d.mu
+func (d *Detector) eject(host *hostState, reason string) {
+	total := len(d.lb.GetBackends())
+	ejected := 0
+	for _, state := range d.lb.GetBackends() {
+		if state.Backend.IsEjected() {
+			ejected++
+		}
+	}
+
+	// Хотя бы один бэкенд может быть исключен, но пул никогда не опустошается полностью
+	limit := total * d.maxEjectionPercent / 100
+	if limit < 1 {
+		limit = 1
+	}
+	if ejected >= limit || ejected+1 >= total {
+		d.logger.Warn(fmt.Sprintf("Бэкенд %s не исключен (%s): достигнут предел исключенных бэкендов", host.backend.ID(), reason))
+		return
+	}
+
+	host.ejections++
+	duration := d.baseEjectionTime * time.Duration(host.ejections)
+	if duration > d.maxEjectionTime {
+		duration = d.maxEjectionTime
+	}
+	host.ejectedUntil = d.now().Add(duration)
+	host.backend.SetEjected(true)
+
+	d.logger.Warn(fmt.Sprintf("Бэкенд %s исключен из ротации на %v: %s", host.backend.ID(), duration, reason))
+}
@@ -0,0 +1,107 @@
+package outlier
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	roundrobin "cloud.ru_test/internal/loadbalancer/algorithms/round_robin"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestDetector_ConsecutiveErrors(t *testing.T) {
+	appLogger := logger.NewNop()
+	lb := roundrobin.New(appLogger)
+	b1 := backend.NewBackend("b1", "http://127.0.0.1:1", 1)
+	b2 := backend.NewBackend("b2", "http://127.0.0.1:2", 1)
+	lb.AddBackend(b1)
+	lb.AddBackend(b2)
+
+	d := New(&config.OutlierDetectionConfig{Consecutive5xx: 3, BaseEjectionTime: time.Minute, MaxEjectionPercent: 50}, lb, appLogger)
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	// Успешный ответ сбрасывает серию ошибок
+	d.Observe(b1, http.StatusBadGateway, nil)
+	d.Observe(b1, http.StatusOK, nil)
+	d.Observe(b1, 0, errors.New("connection refused"))
+	d.Observe(b1, http.StatusServiceUnavailable, nil)
+	if b1.IsEjected() {
+		t.Fatal("бэкенд не должен исключаться до серии из 3 ошибок")
+	}
+	d.Observe(b1, http.StatusInternalServerError, nil)
+	if !b1.IsEjected() {
+		t.Fatal("бэкенд должен исключаться после 3 ошибок подряд")
+	}
+
+	// Пул не опустошается: второй бэкенд не исключается
+	for i := 0; i < 3; i++ {
+		d.Observe(b2, http.StatusInternalServerError, nil)
+	}
+	if b2.IsEjected() {
+		t.Error("последний доступный бэкенд не должен исключаться")
+	}
+
+	now = now.Add(59 * time.Second)
+	d.evaluate()
+	if !b1.IsEjected() {
+		t.Error("бэкенд не должен возвращаться до истечения времени исключения")
+	}
+
+	now = now.Add(time.Second)
+	d.evaluate()
+	if b1.IsEjected() {
+		t.Error("бэкенд должен возвращаться после истечения времени исключения")
+	}
+
+	// Повторное исключение длится вдвое дольше
+	for i := 0; i < 3; i++ {
+		d.Observe(b1, http.StatusInternalServerError, nil)
+	}
+	if until := d.hosts["b1"].ejectedUntil; until.Sub(now) != 2*time.Minute {
+		t.Errorf("ожидалось исключение на 2m, получено %v", until.Sub(now))
+	}
+}
+
+func TestDetector_SuccessRate(t *testing.T) {
+	appLogger := logger.NewNop()
+	lb := roundrobin.New(appLogger)
+	d := New(&config.OutlierDetectionConfig{
+		Consecutive5xx:           1000,
+		MaxEjectionPercent:       50,
+		SuccessRateMinimumHosts:  3,
+		SuccessRateRequestVolume: 10,
+		SuccessRateStdevFactor:   1,
+	}, lb, appLogger)
+
+	var backends []*backend.BaseBackend
+	for _, id := range []string{"b1", "b2", "b3", "b4"} {
+		b := backend.NewBackend(id, "http://"+id, 1)
+		lb.AddBackend(b)
+		backends = append(backends, b)
+	}
+
+	// b4 отвечает ошибкой на каждый второй запрос, остальные — успешно
+	for i := 0; i < 20; i++ {
+		for j, b := range backends {
+			status := http.StatusOK
+			if j == 3 && i%2 == 0 {
+				status = http.StatusInternalServerError
+			}
+			d.Observe(b, status, nil)
+		}
+	}
+
+	d.evaluate()
+	if !backends[3].IsEjected() {
+		t.Error("бэкенд с низкой долей успешных ответов должен исключаться")
+	}
+	for _, b := range backends[:3] {
+		if b.IsEjected() {
+			t.Errorf("бэкенд %s не должен исключаться", b.ID())
+		}
+	}
+}
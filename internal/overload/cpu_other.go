@@ -0,0 +1,13 @@
+//go:build !unix
+
+package overload
+
+import (
+	"errors"
+	"time"
+)
+
+// processCPUTime не поддерживается на этой платформе
+func processCPUTime() (time.Duration, error) {
+	return 0, errors.New("process CPU time is not supported on this platform")
+}
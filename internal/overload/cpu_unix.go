@@ -0,0 +1,17 @@
+//go:build unix
+
+package overload
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime возвращает процессорное время процесса (user + system)
+func processCPUTime() (time.Duration, error) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, err
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), nil
+}
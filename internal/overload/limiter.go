@@ -0,0 +1,423 @@
+// Package overload защищает прокси от перегрузки: ограничивает число одновременно
+// обрабатываемых запросов и отбрасывает запросы низкого приоритета при нехватке CPU
+package overload
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Интервалы измерения нагрузки
+const (
+	lagSampleInterval = 100 * time.Millisecond
+	cpuSampleInterval = time.Second
+)
+
+// defaultQueueTimeout время ожидания в очереди по умолчанию
+const defaultQueueTimeout = time.Second
+
+// Причины отказа в обработке запроса
+const (
+	ReasonInFlight     = "inFlight"
+	ReasonRoute        = "route"
+	ReasonPressure     = "pressure"
+	ReasonQueueFull    = "queueFull"
+	ReasonQueueTimeout = "queueTimeout"
+)
+
+var (
+	// shed счетчик отброшенных запросов по причинам
+	shed = metrics.Default.Counter("lb_overload_shed_total", "Requests rejected by overload protection", "reason")
+
+	// queued счетчик запросов, ожидавших места в очереди, по приоритетам
+	queued = metrics.Default.Counter("lb_overload_queued_total", "Requests that waited in the overload queue by priority", "priority")
+)
+
+// Priority приоритет запросов маршрута
+type Priority int
+
+const (
+	// PriorityLow запросы, отбрасываемые первыми при нехватке CPU
+	PriorityLow Priority = iota
+
+	// PriorityNormal запросы по умолчанию
+	PriorityNormal
+
+	// PriorityHigh запросы, которым доступен резерв reservedInFlight
+	PriorityHigh
+)
+
+// numPriorities число приоритетов
+const numPriorities = int(PriorityHigh) + 1
+
+// String возвращает название приоритета, как в конфигурации
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityLow:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// parsePriority разбирает приоритет из конфигурации
+func parsePriority(s string) Priority {
+	switch s {
+	case "high":
+		return PriorityHigh
+	case "low":
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
+
+// route маршрут с собственным лимитом одновременных запросов
+type route struct {
+	prefix      string
+	maxInFlight int64
+	priority    Priority
+	inFlight    atomic.Int64
+}
+
+// waiter запрос, ожидающий места в очереди
+type waiter struct {
+	priority Priority
+	ready    chan struct{}
+	granted  bool
+}
+
+// Limiter ограничивает число одновременно обрабатываемых запросов. По умолчанию запросы
+// сверх лимита сразу получают отказ, а не ждут в очереди: под перегрузкой прокси деградирует
+// постепенно, и задержка принятых запросов не растет. С очередью запросы ждут ограниченное
+// время, а освободившееся место получает ожидающий запрос с наибольшим приоритетом.
+type Limiter struct {
+	maxInFlight  int64
+	reserved     int64
+	cpuThreshold float64
+	lagThreshold time.Duration
+	routes       []*route
+	users        map[string]Priority
+	logger       logger.Logger
+
+	queueSize    int
+	queueTimeout time.Duration
+
+	inFlight atomic.Int64
+
+	// Очереди ожидающих запросов по приоритетам. При включенной очереди места
+	// освобождаются под mu, чтобы передать их ожидающим запросам.
+	mu      sync.Mutex
+	waiting [numPriorities][]*waiter
+	queued  int
+
+	// Последние измерения нагрузки: доля CPU (math.Float64bits) и задержка планировщика
+	cpu atomic.Uint64
+	lag atomic.Int64
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// New создает ограничитель по конфигурации
+func New(cfg *config.OverloadConfig, appLogger logger.Logger) *Limiter {
+	l := &Limiter{
+		maxInFlight:  int64(cfg.MaxInFlight),
+		reserved:     int64(cfg.ReservedInFlight),
+		cpuThreshold: cfg.CPUThreshold,
+		lagThreshold: cfg.SchedulerLagThreshold,
+		users:        make(map[string]Priority, len(cfg.Users)),
+		logger:       appLogger,
+		queueSize:    cfg.QueueSize,
+		queueTimeout: cfg.QueueTimeout,
+		stopCh:       make(chan struct{}),
+	}
+	if l.queueTimeout == 0 {
+		l.queueTimeout = defaultQueueTimeout
+	}
+	for _, uc := range cfg.Users {
+		l.users[uc.UserID] = parsePriority(uc.Priority)
+	}
+	for _, rc := range cfg.Routes {
+		l.routes = append(l.routes, &route{
+			prefix:      rc.PathPrefix,
+			maxInFlight: int64(rc.MaxInFlight),
+			priority:    parsePriority(rc.Priority),
+		})
+	}
+	// Более длинные префиксы проверяются первыми
+	sort.SliceStable(l.routes, func(i, j int) bool {
+		return len(l.routes[i].prefix) > len(l.routes[j].prefix)
+	})
+	return l
+}
+
+// Start запускает измерение загрузки CPU и задержки планировщика, если заданы пороги
+func (l *Limiter) Start() {
+	l.logger.Debug(fmt.Sprintf("Запуск защиты от перегрузки (maxInFlight: %d, маршрутов: %d)", l.maxInFlight, len(l.routes)))
+
+	if l.lagThreshold > 0 {
+		l.wg.Add(1)
+		go l.measureLag()
+	}
+	if l.cpuThreshold > 0 {
+		l.wg.Add(1)
+		go l.measureCPU()
+	}
+}
+
+// Stop останавливает измерения
+func (l *Limiter) Stop() {
+	l.stopOnce.Do(func() { close(l.stopCh) })
+	l.wg.Wait()
+}
+
+// Acquire решает, обрабатывать ли запрос с путем path от пользователя userID. Если запрос
+// принят, возвращает пустую причину и функцию, которую нужно вызвать по окончании обработки.
+// При включенной очереди ожидает места, пока не истечет время ожидания или ctx.
+// Для nil ограничителя все запросы принимаются.
+func (l *Limiter) Acquire(ctx context.Context, path, userID string) (release func(), reason string) {
+	if l == nil {
+		return func() {}, ""
+	}
+
+	r := l.match(path)
+	priority := PriorityNormal
+	if r != nil {
+		priority = r.priority
+	}
+	if p, ok := l.users[userID]; ok {
+		priority = p
+	}
+
+	if priority == PriorityLow && l.UnderPressure() {
+		shed.Inc(ReasonPressure)
+		return nil, ReasonPressure
+	}
+
+	if r != nil && r.maxInFlight > 0 && r.inFlight.Add(1) > r.maxInFlight {
+		r.inFlight.Add(-1)
+		shed.Inc(ReasonRoute)
+		return nil, ReasonRoute
+	}
+	if l.maxInFlight > 0 {
+		if reason := l.acquireSlot(ctx, priority); reason != "" {
+			if r != nil && r.maxInFlight > 0 {
+				r.inFlight.Add(-1)
+			}
+			shed.Inc(reason)
+			return nil, reason
+		}
+	}
+
+	return func() {
+		if r != nil && r.maxInFlight > 0 {
+			r.inFlight.Add(-1)
+		}
+		if l.maxInFlight > 0 {
+			l.releaseSlot()
+		}
+	}, ""
+}
+
+// limit возвращает число мест, доступных запросам с приоритетом priority
+func (l *Limiter) limit(priority Priority) int64 {
+	if priority == PriorityHigh {
+		return l.maxInFlight
+	}
+	return l.maxInFlight - l.reserved
+}
+
+// tryAcquire занимает место, если оно доступно запросам с приоритетом priority
+func (l *Limiter) tryAcquire(priority Priority) bool {
+	if l.inFlight.Add(1) > l.limit(priority) {
+		l.inFlight.Add(-1)
+		return false
+	}
+	return true
+}
+
+// acquireSlot занимает место в общем лимите, при необходимости ожидая в очереди.
+// Возвращает причину отказа или пустую строку.
+func (l *Limiter) acquireSlot(ctx context.Context, priority Priority) string {
+	if l.tryAcquire(priority) {
+		return ""
+	}
+	if l.queueSize == 0 {
+		return ReasonInFlight
+	}
+
+	l.mu.Lock()
+	// Место могло освободиться до захвата mu; после него освобождение места заметит очередь
+	if l.tryAcquire(priority) {
+		l.mu.Unlock()
+		return ""
+	}
+	if l.queued >= l.queueSize {
+		l.mu.Unlock()
+		return ReasonQueueFull
+	}
+	w := &waiter{priority: priority, ready: make(chan struct{})}
+	l.waiting[priority] = append(l.waiting[priority], w)
+	l.queued++
+	l.mu.Unlock()
+	queued.Inc(priority.String())
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+		return ""
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.granted {
+		// Место передано одновременно с истечением ожидания
+		return ""
+	}
+	queue := l.waiting[priority]
+	for i := range queue {
+		if queue[i] == w {
+			l.waiting[priority] = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	l.queued--
+	return ReasonQueueTimeout
+}
+
+// releaseSlot освобождает место в общем лимите или передает его ожидающему запросу
+// с наибольшим приоритетом, которому оно доступно
+func (l *Limiter) releaseSlot() {
+	if l.queueSize == 0 {
+		l.inFlight.Add(-1)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.queued > 0 {
+		// Место переходит ожидающему запросу, поэтому счетчик не меняется
+		inFlight := l.inFlight.Load()
+		for p := numPriorities - 1; p >= 0; p-- {
+			queue := l.waiting[p]
+			if len(queue) == 0 || inFlight > l.limit(Priority(p)) {
+				continue
+			}
+			w := queue[0]
+			l.waiting[p] = queue[1:]
+			l.queued--
+			w.granted = true
+			close(w.ready)
+			return
+		}
+	}
+	l.inFlight.Add(-1)
+}
+
+// InFlight возвращает число обрабатываемых запросов (учитываются только при заданном maxInFlight)
+func (l *Limiter) InFlight() int64 {
+	return l.inFlight.Load()
+}
+
+// UnderPressure сообщает, превышен ли порог загрузки CPU или задержки планировщика
+func (l *Limiter) UnderPressure() bool {
+	if l.cpuThreshold > 0 && math.Float64frombits(l.cpu.Load()) > l.cpuThreshold {
+		return true
+	}
+	return l.lagThreshold > 0 && time.Duration(l.lag.Load()) > l.lagThreshold
+}
+
+// match возвращает маршрут запроса с путем path или nil
+func (l *Limiter) match(path string) *route {
+	for _, r := range l.routes {
+		if strings.HasPrefix(path, r.prefix) {
+			return r
+		}
+	}
+	return nil
+}
+
+// measureLag измеряет задержку планировщика: насколько позже запланированного
+// просыпается горутина. При нехватке CPU задержка растет раньше, чем время ответа.
+func (l *Limiter) measureLag() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(lagSampleInterval)
+	defer ticker.Stop()
+
+	expected := time.Now().Add(lagSampleInterval)
+	for {
+		select {
+		case <-l.stopCh:
+			return
+		case now := <-ticker.C:
+			lag := time.Since(expected)
+			if lag < 0 {
+				lag = 0
+			}
+			expected = now.Add(lagSampleInterval)
+
+			previous := time.Duration(l.lag.Swap(int64(lag)))
+			if lag > l.lagThreshold && previous <= l.lagThreshold {
+				l.logger.Warn(fmt.Sprintf("Задержка планировщика %v превышает порог %v, запросы с низким приоритетом отбрасываются", lag, l.lagThreshold))
+			} else if lag <= l.lagThreshold && previous > l.lagThreshold {
+				l.logger.Info(fmt.Sprintf("Задержка планировщика снизилась до %v", lag))
+			}
+		}
+	}
+}
+
+// measureCPU измеряет долю занятого процессом CPU от доступного по GOMAXPROCS
+func (l *Limiter) measureCPU() {
+	defer l.wg.Done()
+
+	prevCPU, err := processCPUTime()
+	if err != nil {
+		l.logger.Warn(fmt.Sprintf("Загрузка CPU не отслеживается: %v", err))
+		return
+	}
+	prevTime := time.Now()
+
+	ticker := time.NewTicker(cpuSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stopCh:
+			return
+		case now := <-ticker.C:
+			cpu, err := processCPUTime()
+			if err != nil {
+				continue
+			}
+			usage := float64(cpu-prevCPU) / float64(now.Sub(prevTime)) / float64(runtime.GOMAXPROCS(0))
+			prevCPU, prevTime = cpu, now
+
+			previous := math.Float64frombits(l.cpu.Swap(math.Float64bits(usage)))
+			if usage > l.cpuThreshold && previous <= l.cpuThreshold {
+				l.logger.Warn(fmt.Sprintf("Загрузка CPU %.0f%% превышает порог %.0f%%, запросы с низким приоритетом отбрасываются", usage*100, l.cpuThreshold*100))
+			} else if usage <= l.cpuThreshold && previous > l.cpuThreshold {
+				l.logger.Info(fmt.Sprintf("Загрузка CPU снизилась до %.0f%%", usage*100))
+			}
+		}
+	}
+}
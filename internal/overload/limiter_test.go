@@ -0,0 +1,161 @@
+package overload
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestLimiterAcquire(t *testing.T) {
+	ctx := context.Background()
+	l := New(&config.OverloadConfig{
+		MaxInFlight:      3,
+		ReservedInFlight: 1,
+		CPUThreshold:     0.8,
+		Routes: []config.OverloadRouteConfig{
+			{PathPrefix: "/api", MaxInFlight: 1},
+			{PathPrefix: "/api/checkout", Priority: "high"},
+			{PathPrefix: "/reports", Priority: "low"},
+		},
+	}, logger.NewNop())
+
+	// Маршрут с собственным лимитом
+	releaseAPI, reason := l.Acquire(ctx, "/api/items", "")
+	if reason != "" {
+		t.Fatalf("первый запрос маршрута должен приниматься: %s", reason)
+	}
+	if _, reason := l.Acquire(ctx, "/api/orders", ""); reason != ReasonRoute {
+		t.Errorf("запрос сверх лимита маршрута должен отбрасываться, причина: %q", reason)
+	}
+
+	// Обычным запросам доступно maxInFlight - reservedInFlight мест
+	releaseOther, reason := l.Acquire(ctx, "/", "")
+	if reason != "" {
+		t.Fatalf("второй запрос должен приниматься: %s", reason)
+	}
+	if _, reason := l.Acquire(ctx, "/", ""); reason != ReasonInFlight {
+		t.Errorf("обычный запрос не должен занимать резерв, причина: %q", reason)
+	}
+	releaseHigh, reason := l.Acquire(ctx, "/api/checkout/pay", "")
+	if reason != "" {
+		t.Errorf("запрос с высоким приоритетом должен получать резерв: %s", reason)
+	}
+	if _, reason := l.Acquire(ctx, "/api/checkout/pay", ""); reason != ReasonInFlight {
+		t.Errorf("общий лимит действует и для высокого приоритета, причина: %q", reason)
+	}
+
+	releaseAPI()
+	releaseOther()
+	releaseHigh()
+	if l.InFlight() != 0 {
+		t.Errorf("после завершения запросов счетчик должен обнуляться: %d", l.InFlight())
+	}
+
+	// При нехватке CPU отбрасываются только запросы с низким приоритетом
+	l.cpu.Store(math.Float64bits(0.95))
+	if _, reason := l.Acquire(ctx, "/reports/daily", ""); reason != ReasonPressure {
+		t.Errorf("запрос с низким приоритетом должен отбрасываться при нехватке CPU, причина: %q", reason)
+	}
+	if release, reason := l.Acquire(ctx, "/", ""); reason != "" {
+		t.Errorf("обычный запрос не должен отбрасываться при нехватке CPU: %s", reason)
+	} else {
+		release()
+	}
+	l.cpu.Store(math.Float64bits(0.5))
+	if release, reason := l.Acquire(ctx, "/reports/daily", ""); reason != "" {
+		t.Errorf("после снижения нагрузки запросы с низким приоритетом должны приниматься: %s", reason)
+	} else {
+		release()
+	}
+}
+
+func TestLimiterSchedulerLag(t *testing.T) {
+	ctx := context.Background()
+	l := New(&config.OverloadConfig{SchedulerLagThreshold: 50 * time.Millisecond}, logger.NewNop())
+	l.lag.Store(int64(80 * time.Millisecond))
+	if !l.UnderPressure() {
+		t.Errorf("задержка планировщика выше порога означает перегрузку")
+	}
+
+	var nilLimiter *Limiter
+	release, reason := nilLimiter.Acquire(ctx, "/", "")
+	if reason != "" {
+		t.Errorf("без настроек запросы не должны ограничиваться")
+	}
+	release()
+}
+
+func TestLimiterQueue(t *testing.T) {
+	ctx := context.Background()
+	l := New(&config.OverloadConfig{
+		MaxInFlight:  1,
+		QueueSize:    2,
+		QueueTimeout: time.Second,
+		Routes:       []config.OverloadRouteConfig{{PathPrefix: "/bulk", Priority: "low"}},
+		Users:        []config.OverloadUserConfig{{UserID: "10.0.0.1", Priority: "high"}},
+	}, logger.NewNop())
+
+	release, reason := l.Acquire(ctx, "/", "")
+	if reason != "" {
+		t.Fatalf("первый запрос должен приниматься: %s", reason)
+	}
+
+	// Первым в очередь встает запрос с низким приоритетом, за ним запрос с высоким
+	order := make(chan string, 2)
+	wait := func(path, userID string) {
+		release, reason := l.Acquire(ctx, path, userID)
+		if reason != "" {
+			order <- reason
+			return
+		}
+		order <- path + " " + userID
+		release()
+	}
+	go wait("/bulk", "")
+	waitQueued(t, l, 1)
+	go wait("/bulk", "10.0.0.1")
+	waitQueued(t, l, 2)
+
+	if _, reason := l.Acquire(ctx, "/", ""); reason != ReasonQueueFull {
+		t.Errorf("запрос сверх размера очереди должен отбрасываться, причина: %q", reason)
+	}
+
+	// Освободившееся место получает пользователь с высоким приоритетом, несмотря на маршрут
+	release()
+	if got := <-order; got != "/bulk 10.0.0.1" {
+		t.Errorf("первым должен обрабатываться запрос с высоким приоритетом, получено %q", got)
+	}
+	if got := <-order; got != "/bulk " {
+		t.Errorf("запрос с низким приоритетом должен дождаться места, получено %q", got)
+	}
+	if l.InFlight() != 0 {
+		t.Errorf("после завершения запросов счетчик должен обнуляться: %d", l.InFlight())
+	}
+
+	// Время ожидания ограничено контекстом запроса
+	release, _ = l.Acquire(ctx, "/", "")
+	defer release()
+	canceled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, reason := l.Acquire(canceled, "/", ""); reason != ReasonQueueTimeout {
+		t.Errorf("запрос должен покидать очередь по окончании контекста, причина: %q", reason)
+	}
+}
+
+// waitQueued ожидает, пока в очереди окажется n запросов
+func waitQueued(t *testing.T, l *Limiter, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		l.mu.Lock()
+		queued := l.queued
+		l.mu.Unlock()
+		if queued == n {
+			return
+		}
+	}
+	t.Fatalf("в очереди не оказалось %d запросов", n)
+}
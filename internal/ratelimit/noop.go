@@ -0,0 +1,66 @@
+package ratelimit
+
+import "time"
+
+// NoopRateLimiter пропускает все запросы. Используется, когда rate limiter
+// отключен в конфигурации или его секция отсутствует.
+type NoopRateLimiter struct{}
+
+// NewNoopRateLimiter создает rate limiter, не ограничивающий запросы
+func NewNoopRateLimiter() *NoopRateLimiter {
+	return &NoopRateLimiter{}
+}
+
+// Allow всегда пропускает запрос
+func (n *NoopRateLimiter) Allow(userID string) bool {
+	return true
+}
+
+// Wait не ожидает
+func (n *NoopRateLimiter) Wait(userID string) time.Duration {
+	return 0
+}
+
+// Reserve не резервирует токен: он доступен сразу
+func (n *NoopRateLimiter) Reserve(userID string) time.Duration {
+	return 0
+}
+
+// GetTokens возвращает 0: ограничений нет
+func (n *NoopRateLimiter) GetTokens(userID string) float64 {
+	return 0
+}
+
+// GetBurst возвращает 0: ограничений нет
+func (n *NoopRateLimiter) GetBurst(userID string) int {
+	return 0
+}
+
+// GetRate возвращает 0: ограничений нет
+func (n *NoopRateLimiter) GetRate(userID string) float64 {
+	return 0
+}
+
+// SetUserLimits ничего не делает
+func (n *NoopRateLimiter) SetUserLimits(userID string, rate float64, burst int) {}
+
+// GetUserLimits возвращает nil: пользовательских лимитов нет
+func (n *NoopRateLimiter) GetUserLimits(userID string) *UserLimits {
+	return nil
+}
+
+// DeleteUserLimits ничего не делает
+func (n *NoopRateLimiter) DeleteUserLimits(userID string) {}
+
+// UpdateUserLimits ничего не делает
+func (n *NoopRateLimiter) UpdateUserLimits(userID string, updateFn func(*UserLimits)) {}
+
+// ListUserLimits возвращает пустой список
+func (n *NoopRateLimiter) ListUserLimits() map[string]UserLimits {
+	return map[string]UserLimits{}
+}
+
+// Close ничего не делает
+func (n *NoopRateLimiter) Close() error {
+	return nil
+}
@@ -1,8 +1,21 @@
 package ratelimit
 
-import "time"
+import (
+	"fmt"
+	"sync"
+	"time"
 
-// RateLimiter определяет интерфейс для ограничения запросов
+	"cloud.ru_test/config"
+)
+
+// RateLimiter определяет интерфейс для ограничения запросов. userID — ключ клиента
+// (адрес клиента или идентификатор пользователя). Методы вызываются одновременно из
+// горутин обработки запросов и административного API, поэтому реализация должна быть
+// потокобезопасной; Allow вызывается на каждый запрос и не должна блокироваться надолго.
+// Реализация, не поддерживающая пользовательские лимиты, может игнорировать SetUserLimits,
+// UpdateUserLimits и DeleteUserLimits, возвращать nil из GetUserLimits и пустой список
+// из ListUserLimits. Лимиты из ListUserLimits переносятся в новый rate limiter при
+// перезагрузке конфигурации.
 type RateLimiter interface {
 	// Allow проверяет, можно ли пропустить запрос
 	Allow(userID string) bool
@@ -33,4 +46,54 @@ type RateLimiter interface {
 
 	// UpdateUserLimits обновляет лимиты пользователя
 	UpdateUserLimits(userID string, updateFn func(*UserLimits))
+
+	// ListUserLimits возвращает все пользовательские лимиты
+	ListUserLimits() map[string]UserLimits
+
+	// Close освобождает ресурсы rate limiter'а при его замене или остановке приложения
+	Close() error
+}
+
+// Factory создает rate limiter по включенной секции rateLimiter
+type Factory func(cfg *config.RateLimiterConfig) (RateLimiter, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+func init() {
+	Register("TokenBucket", func(cfg *config.RateLimiterConfig) (RateLimiter, error) {
+		if cfg.TokenBucket == nil {
+			return nil, fmt.Errorf("token bucket configuration is required")
+		}
+		return NewTokenBucket(cfg.TokenBucket.Rate, cfg.TokenBucket.Burst), nil
+	})
+}
+
+// Register регистрирует тип rate limiter. Имя становится допустимым значением
+// rateLimiter.type конфигурации, параметры передаются в rateLimiter.params.
+// Регистрация под существующим именем заменяет тип.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	factories[name] = factory
+	config.RegisterRateLimiterType(name)
+}
+
+// New создает rate limiter на основе конфигурации. Если секция отсутствует
+// или rate limiter отключен, возвращается NoopRateLimiter.
+func New(cfg *config.RateLimiterConfig) (RateLimiter, error) {
+	if cfg == nil || !cfg.Enabled {
+		return NewNoopRateLimiter(), nil
+	}
+
+	factoriesMu.RLock()
+	factory, ok := factories[cfg.Type]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported rate limiter type: %s", cfg.Type)
+	}
+	return factory(cfg)
 }
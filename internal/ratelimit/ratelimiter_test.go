@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"testing"
+
+	"go.uber.org/goleak"
+
+	"cloud.ru_test/config"
+)
+
+// TestMain проверяет, что после тестов пакета не остается запущенных горутин
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func TestNew(t *testing.T) {
+	// Отсутствующая секция и отключенный rate limiter не ограничивают запросы
+	for _, cfg := range []*config.RateLimiterConfig{nil, {Enabled: false, Type: "TokenBucket"}} {
+		limiter, err := New(cfg)
+		if err != nil {
+			t.Fatalf("неожиданная ошибка: %v", err)
+		}
+		if _, ok := limiter.(*NoopRateLimiter); !ok {
+			t.Errorf("ожидался NoopRateLimiter, получен %T", limiter)
+		}
+		for i := 0; i < 1000; i++ {
+			if !limiter.Allow("user1") {
+				t.Fatal("отключенный rate limiter должен пропускать все запросы")
+			}
+		}
+	}
+
+	limiter, err := New(&config.RateLimiterConfig{
+		Enabled:     true,
+		Type:        "TokenBucket",
+		TokenBucket: &config.TokenBucketConfig{Rate: 1, Burst: 1},
+	})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if !limiter.Allow("user1") || limiter.Allow("user1") {
+		t.Error("включенный rate limiter должен ограничивать запросы")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("Deny", func(cfg *config.RateLimiterConfig) (RateLimiter, error) {
+		return &denyAll{}, nil
+	})
+
+	limiter, err := New(&config.RateLimiterConfig{Enabled: true, Type: "Deny"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limiter.Allow("user1") {
+		t.Error("должен использоваться зарегистрированный rate limiter")
+	}
+	if _, err := New(&config.RateLimiterConfig{Enabled: true, Type: "Unknown"}); err == nil {
+		t.Error("незарегистрированный тип должен отклоняться")
+	}
+
+	// Зарегистрированный тип допустим в конфигурации и не требует секции tokenBucket
+	if _, err := config.Parse([]byte("rateLimiter:\n  enabled: true\n  type: Deny\n" +
+		"loadBalancer:\n  method: RoundRobin\n" +
+		"logger:\n  logLevel: info\n  serviceName: test\n" +
+		"backends:\n  - id: backend1\n    url: http://127.0.0.1:8081\n")); err != nil {
+		t.Errorf("зарегистрированный тип должен проходить проверку конфигурации: %v", err)
+	}
+}
+
+// denyAll отклоняет все запросы
+type denyAll struct {
+	NoopRateLimiter
+}
+
+func (d *denyAll) Allow(userID string) bool {
+	return false
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// State состояние rate limiter'а для переноса на другой экземпляр при обновлении:
+// новый экземпляр продолжает с теми же корзинами, а не выдает клиентам полный burst
+type State struct {
+	// Время снятия состояния; при загрузке корзины пополняются за прошедшее время
+	Time time.Time `json:"time"`
+
+	// Пользовательские лимиты
+	Overrides map[string]UserLimits `json:"overrides"`
+
+	// Токены в неполных корзинах клиентов; полные корзины не выгружаются
+	Tokens map[string]float64 `json:"tokens"`
+}
+
+// StateTransfer реализуется rate limiter'ами, состояние которых можно выгрузить и загрузить
+type StateTransfer interface {
+	// ExportState возвращает текущее состояние
+	ExportState() State
+
+	// ImportState загружает состояние, заменяя лимиты и корзины перечисленных в нем клиентов
+	ImportState(state State) error
+}
+
+// ExportState возвращает пользовательские лимиты и токены в неполных корзинах
+func (tb *TokenBucket) ExportState() State {
+	now := time.Now()
+	state := State{
+		Time:      now,
+		Overrides: tb.ListUserLimits(),
+		Tokens:    make(map[string]float64),
+	}
+	tb.limiters.Range(func(key, value interface{}) bool {
+		limiter := value.(*rate.Limiter)
+		if tokens := limiter.TokensAt(now); tokens < float64(limiter.Burst()) {
+			state.Tokens[key.(string)] = tokens
+		}
+		return true
+	})
+	return state
+}
+
+// ImportState загружает лимиты и корзины. Токены пополняются за время, прошедшее
+// с выгрузки состояния, и округляются вниз до целого: клиент не получает больше, чем имел.
+func (tb *TokenBucket) ImportState(state State) error {
+	for userID, limits := range state.Overrides {
+		if limits.Rate <= 0 || limits.Burst <= 0 {
+			return fmt.Errorf("invalid limits for %q: rate and burst must be positive", userID)
+		}
+	}
+
+	now := time.Now()
+	elapsed := max(now.Sub(state.Time), 0)
+	for userID, limits := range state.Overrides {
+		tb.SetUserLimits(userID, limits.Rate, limits.Burst)
+	}
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	for userID, tokens := range state.Tokens {
+		limits := tb.GetUserLimits(userID)
+		limiter := rate.NewLimiter(rate.Limit(limits.Rate), limits.Burst)
+		tokens = min(tokens+limits.Rate*elapsed.Seconds(), float64(limits.Burst))
+		// Новый лимитер создается с полной корзиной: лишние токены расходуются сразу
+		if spent := limits.Burst - int(math.Floor(max(tokens, 0))); spent > 0 {
+			limiter.AllowN(now, spent)
+		}
+		tb.limiters.Store(userID, limiter)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package ratelimit
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -9,8 +10,8 @@ import (
 
 // UserLimits содержит настройки лимитов для пользователя
 type UserLimits struct {
-	Rate  float64 // Количество запросов в секунду
-	Burst int     // Максимальный размер корзины
+	Rate  float64 `json:"rate"`  // Количество запросов в секунду
+	Burst int     `json:"burst"` // Максимальный размер корзины
 }
 
 // TokenBucket реализует алгоритм маркерного ведра с поддержкой пользовательских лимитов
@@ -27,6 +28,10 @@ type TokenBucket struct {
 
 	// Мьютекс для синхронизации операций с настройками
 	mu sync.RWMutex
+
+	// Учет пропущенных запросов для обмена с другими экземплярами; включается первым вызовом TakeUsage
+	sharing atomic.Bool
+	usage   sync.Map // map[string]*atomic.Int64
 }
 
 // NewTokenBucket создает новый TokenBucket с указанными параметрами по умолчанию
@@ -40,7 +45,13 @@ func NewTokenBucket(defaultRate float64, defaultBurst int) *TokenBucket {
 // Allow проверяет, можно ли пропустить запрос для указанного пользователя
 func (tb *TokenBucket) Allow(userID string) bool {
 	limiter := tb.getLimiter(userID)
-	return limiter.Allow()
+	if !limiter.Allow() {
+		return false
+	}
+	if tb.sharing.Load() {
+		tb.countUsage(userID)
+	}
+	return true
 }
 
 // SetUserLimits устанавливает лимиты для конкретного пользователя
@@ -95,6 +106,26 @@ func (tb *TokenBucket) UpdateUserLimits(userID string, updateFn func(*UserLimits
 	tb.limiters.Store(userID, limiter)
 }
 
+// ListUserLimits возвращает копию всех пользовательских лимитов
+func (tb *TokenBucket) ListUserLimits() map[string]UserLimits {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+
+	result := make(map[string]UserLimits)
+	tb.userLimits.Range(func(key, value interface{}) bool {
+		result[key.(string)] = *value.(*UserLimits)
+		return true
+	})
+	return result
+}
+
+// Close освобождает лимитеры пользователей. Пользовательские настройки сохраняются,
+// чтобы их можно было перенести в новый rate limiter.
+func (tb *TokenBucket) Close() error {
+	tb.limiters.Clear()
+	return nil
+}
+
 // Wait ожидает, пока не появится доступный токен
 func (tb *TokenBucket) Wait(userID string) time.Duration {
 	limiter := tb.getLimiter(userID)
@@ -115,3 +115,77 @@ func TestTokenBucket_Reserve(t *testing.T) {
 		t.Errorf("неверная задержка для второго запроса: got=%v, want=%v±10%%", delay, expectedDelay)
 	}
 }
+
+func TestTokenBucket_StateTransfer(t *testing.T) {
+	old := NewTokenBucket(0.001, 5) // пополнение пренебрежимо мало
+	old.SetUserLimits("vip", 0.001, 10)
+	for i := 0; i < 4; i++ {
+		old.Allow("user1")
+	}
+	old.Allow("user2")
+	old.Allow("user2")
+	old.GetTokens("idle") // полная корзина не выгружается
+
+	state := old.ExportState()
+	if len(state.Tokens) != 2 || state.Overrides["vip"].Burst != 10 {
+		t.Fatalf("неверное состояние: %+v", state)
+	}
+
+	// Новый экземпляр продолжает с теми же корзинами
+	tb := NewTokenBucket(0.001, 5)
+	if err := tb.ImportState(state); err != nil {
+		t.Fatal(err)
+	}
+	if limits := tb.GetUserLimits("vip"); limits.Rate != 0.001 || limits.Burst != 10 {
+		t.Errorf("пользовательские лимиты не перенесены: %+v", limits)
+	}
+	if tokens := tb.GetTokens("user1"); tokens < 0.99 || tokens > 1.01 {
+		t.Errorf("у user1 должен остаться 1 токен, получено %.2f", tokens)
+	}
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if tb.Allow("user2") {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("user2 должен получить 3 запроса из оставшихся токенов, получено %d", allowed)
+	}
+
+	if err := tb.ImportState(State{Overrides: map[string]UserLimits{"bad": {Rate: 0, Burst: 1}}}); err == nil {
+		t.Error("некорректные лимиты должны отклоняться")
+	}
+}
+
+func TestTokenBucket_UsageSharing(t *testing.T) {
+	local := NewTokenBucket(0.001, 5)
+	if usage := local.TakeUsage(); len(usage) != 0 {
+		t.Fatalf("первый вызов только включает учет: %v", usage)
+	}
+	local.Allow("user1")
+	local.Allow("user1")
+	local.Allow("user2")
+	if usage := local.TakeUsage(); usage["user1"] != 2 || usage["user2"] != 1 || len(usage) != 2 {
+		t.Errorf("неверный учет запросов: %v", usage)
+	}
+	if usage := local.TakeUsage(); len(usage) != 0 {
+		t.Errorf("учтенные запросы не должны возвращаться повторно: %v", usage)
+	}
+
+	// Запросы, пропущенные другим экземпляром, расходуют токены этого
+	remote := NewTokenBucket(0.001, 5)
+	remote.AddUsage(map[string]int64{"user1": 3})
+	if tokens := remote.GetTokens("user1"); tokens < 1.99 || tokens > 2.01 {
+		t.Errorf("у user1 должно остаться 2 токена, получено %.2f", tokens)
+	}
+
+	// Долг корзины ограничен burst
+	remote.AddUsage(map[string]int64{"user2": 100})
+	remote.AddUsage(map[string]int64{"user2": 100})
+	if tokens := remote.GetTokens("user2"); tokens < -5.01 || tokens > -4.99 {
+		t.Errorf("корзина не должна уходить в минус больше чем на burst, получено %.2f", tokens)
+	}
+	if usage := remote.TakeUsage(); len(usage) != 0 {
+		t.Errorf("чужие запросы не учитываются как свои: %v", usage)
+	}
+}
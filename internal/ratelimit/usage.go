@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// UsageSharing реализуется rate limiter'ами, которые учитывают запросы, пропущенные
+// другими экземплярами прокси: клиент, распределяющий запросы между экземплярами,
+// получает общий лимит, а не лимит каждого экземпляра
+type UsageSharing interface {
+	// TakeUsage возвращает число запросов, пропущенных экземпляром с предыдущего вызова,
+	// по ключам клиентов
+	TakeUsage() map[string]int64
+
+	// AddUsage расходует токены клиентов на запросы, пропущенные другими экземплярами
+	AddUsage(usage map[string]int64)
+}
+
+// countUsage учитывает пропущенный запрос клиента
+func (tb *TokenBucket) countUsage(userID string) {
+	counter, ok := tb.usage.Load(userID)
+	if !ok {
+		counter, _ = tb.usage.LoadOrStore(userID, new(atomic.Int64))
+	}
+	counter.(*atomic.Int64).Add(1)
+}
+
+// TakeUsage возвращает запросы, пропущенные с предыдущего вызова. Первый вызов включает
+// учет и возвращает пустой результат. Запрос, учтенный одновременно с вызовом, может
+// не попасть ни в текущий, ни в следующий результат: обмен лимитами приблизительный.
+func (tb *TokenBucket) TakeUsage() map[string]int64 {
+	usage := make(map[string]int64)
+	if !tb.sharing.Swap(true) {
+		return usage
+	}
+	tb.usage.Range(func(key, value interface{}) bool {
+		tb.usage.Delete(key)
+		if n := value.(*atomic.Int64).Load(); n > 0 {
+			usage[key.(string)] = n
+		}
+		return true
+	})
+	return usage
+}
+
+// AddUsage расходует токены клиентов. Если токенов не хватает, корзина уходит в минус,
+// но не глубже чем на burst, и запросы клиента отклоняются, пока она не пополнится.
+func (tb *TokenBucket) AddUsage(usage map[string]int64) {
+	now := time.Now()
+	for userID, n := range usage {
+		limiter := tb.getLimiter(userID)
+		// ReserveN не резервирует больше burst токенов за раз
+		burst := int64(limiter.Burst())
+		if n = min(n, burst, int64(math.Floor(limiter.TokensAt(now)))+burst); n > 0 {
+			limiter.ReserveN(now, int(n))
+		}
+	}
+}
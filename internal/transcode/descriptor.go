@@ -0,0 +1,467 @@
+// Package transcode преобразует запросы gRPC-Web и REST/JSON в вызовы gRPC по
+// скомпилированному набору дескрипторов (protoc --descriptor_set_out --include_imports)
+package transcode
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Kind тип поля из FieldDescriptorProto.Type
+type Kind int32
+
+// Типы полей protobuf
+const (
+	KindDouble   Kind = 1
+	KindFloat    Kind = 2
+	KindInt64    Kind = 3
+	KindUint64   Kind = 4
+	KindInt32    Kind = 5
+	KindFixed64  Kind = 6
+	KindFixed32  Kind = 7
+	KindBool     Kind = 8
+	KindString   Kind = 9
+	KindGroup    Kind = 10
+	KindMessage  Kind = 11
+	KindBytes    Kind = 12
+	KindUint32   Kind = 13
+	KindEnum     Kind = 14
+	KindSfixed32 Kind = 15
+	KindSfixed64 Kind = 16
+	KindSint32   Kind = 17
+	KindSint64   Kind = 18
+)
+
+// httpRuleExtension номер расширения google.api.http в MethodOptions
+const httpRuleExtension = 72295728
+
+// Message описание сообщения
+type Message struct {
+	// Полное имя без ведущей точки, например library.v1.Book
+	FullName string
+	Fields   []*Field
+
+	// Сообщение описывает элемент map
+	MapEntry bool
+
+	byNumber map[int32]*Field
+	byName   map[string]*Field
+}
+
+// Field описание поля сообщения
+type Field struct {
+	Name     string
+	JSONName string
+	Number   int32
+	Kind     Kind
+	Repeated bool
+	Packed   bool
+
+	// Тип поля-сообщения или перечисления (nil для скалярных полей)
+	Message *Message
+	Enum    *Enum
+
+	typeName string
+}
+
+// FieldByName возвращает поле по имени в proto или JSON
+func (m *Message) FieldByName(name string) *Field {
+	return m.byName[name]
+}
+
+// IsMap проверяет, что поле — map
+func (f *Field) IsMap() bool {
+	return f.Repeated && f.Message != nil && f.Message.MapEntry
+}
+
+// Enum описание перечисления
+type Enum struct {
+	FullName string
+	byName   map[string]int32
+	byNumber map[int32]string
+}
+
+// Method описание метода сервиса
+type Method struct {
+	// Путь вызова gRPC, например /library.v1.Library/GetBook
+	Path string
+
+	Input           *Message
+	Output          *Message
+	ClientStreaming bool
+	ServerStreaming bool
+
+	// Правила HTTP из аннотаций google.api.http
+	Rules []*HTTPRule
+}
+
+// Registry сообщения и методы из набора дескрипторов
+type Registry struct {
+	messages map[string]*Message
+	enums    map[string]*Enum
+	methods  map[string]*Method
+	rules    []*HTTPRule
+}
+
+// Load читает набор дескрипторов (FileDescriptorSet) из файла
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse разбирает набор дескрипторов
+func Parse(data []byte) (*Registry, error) {
+	r := &Registry{
+		messages: make(map[string]*Message),
+		enums:    make(map[string]*Enum),
+		methods:  make(map[string]*Method),
+	}
+
+	set, err := parseFields(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+
+	// Типы собираются из всех файлов до разрешения ссылок на них
+	var services []pendingService
+	for _, f := range set {
+		if f.number != 1 || f.wireType != wireBytes {
+			continue
+		}
+		pending, err := r.addFile(f.bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid descriptor set: %w", err)
+		}
+		services = append(services, pending...)
+	}
+
+	for _, m := range r.messages {
+		for _, fd := range m.Fields {
+			if err := r.resolve(fd); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, s := range services {
+		if err := r.addService(s); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Method возвращает метод по пути вызова gRPC
+func (r *Registry) Method(path string) *Method {
+	return r.methods[path]
+}
+
+// Message возвращает сообщение по полному имени
+func (r *Registry) Message(fullName string) *Message {
+	return r.messages[strings.TrimPrefix(fullName, ".")]
+}
+
+// Match находит метод по правилам HTTP и возвращает значения переменных пути
+func (r *Registry) Match(method, path string) (*HTTPRule, map[string]string) {
+	for _, rule := range r.rules {
+		if vars, ok := rule.match(method, path); ok {
+			return rule, vars
+		}
+	}
+	return nil, nil
+}
+
+// pendingService сервис, методы которого разрешаются после сбора всех типов
+type pendingService struct {
+	pkg  string
+	data []byte
+}
+
+// addFile разбирает FileDescriptorProto
+func (r *Registry) addFile(data []byte) ([]pendingService, error) {
+	fields, err := parseFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkg string
+	proto3 := false
+	for _, f := range fields {
+		switch f.number {
+		case 2:
+			pkg = string(f.bytes)
+		case 12:
+			proto3 = string(f.bytes) == "proto3"
+		}
+	}
+
+	var services []pendingService
+	for _, f := range fields {
+		switch f.number {
+		case 4:
+			if err := r.addMessage(pkg, f.bytes, proto3); err != nil {
+				return nil, err
+			}
+		case 5:
+			if err := r.addEnum(pkg, f.bytes); err != nil {
+				return nil, err
+			}
+		case 6:
+			services = append(services, pendingService{pkg: pkg, data: f.bytes})
+		}
+	}
+	return services, nil
+}
+
+// qualify возвращает полное имя типа name в области scope
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// addMessage разбирает DescriptorProto вместе с вложенными типами
+func (r *Registry) addMessage(scope string, data []byte, proto3 bool) error {
+	fields, err := parseFields(data)
+	if err != nil {
+		return err
+	}
+
+	m := &Message{byNumber: make(map[int32]*Field), byName: make(map[string]*Field)}
+	for _, f := range fields {
+		if f.number == 1 {
+			m.FullName = qualify(scope, string(f.bytes))
+		}
+	}
+
+	for _, f := range fields {
+		switch f.number {
+		case 2:
+			fd, err := parseField(f.bytes, proto3)
+			if err != nil {
+				return fmt.Errorf("message %s: %w", m.FullName, err)
+			}
+			m.Fields = append(m.Fields, fd)
+			m.byNumber[fd.Number] = fd
+			m.byName[fd.Name] = fd
+			m.byName[fd.JSONName] = fd
+		case 3:
+			if err := r.addMessage(m.FullName, f.bytes, proto3); err != nil {
+				return err
+			}
+		case 4:
+			if err := r.addEnum(m.FullName, f.bytes); err != nil {
+				return err
+			}
+		case 7:
+			options, err := parseFields(f.bytes)
+			if err != nil {
+				return err
+			}
+			for _, o := range options {
+				if o.number == 7 && o.wireType == wireVarint {
+					m.MapEntry = o.scalar != 0
+				}
+			}
+		}
+	}
+
+	r.messages[m.FullName] = m
+	return nil
+}
+
+// parseField разбирает FieldDescriptorProto
+func parseField(data []byte, proto3 bool) (*Field, error) {
+	fields, err := parseFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	fd := &Field{}
+	packedOption := -1
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			fd.Name = string(f.bytes)
+		case 3:
+			fd.Number = int32(f.scalar)
+		case 4:
+			fd.Repeated = f.scalar == 3
+		case 5:
+			fd.Kind = Kind(f.scalar)
+		case 6:
+			fd.typeName = strings.TrimPrefix(string(f.bytes), ".")
+		case 10:
+			fd.JSONName = string(f.bytes)
+		case 8:
+			options, err := parseFields(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			for _, o := range options {
+				if o.number == 2 && o.wireType == wireVarint {
+					packedOption = int(o.scalar)
+				}
+			}
+		}
+	}
+
+	if fd.Kind == KindGroup {
+		return nil, fmt.Errorf("field %s: groups are not supported", fd.Name)
+	}
+	if fd.JSONName == "" {
+		fd.JSONName = jsonName(fd.Name)
+	}
+	scalar := fd.Kind != KindString && fd.Kind != KindBytes && fd.Kind != KindMessage
+	fd.Packed = fd.Repeated && scalar && (packedOption == 1 || (proto3 && packedOption != 0))
+	return fd, nil
+}
+
+// jsonName возвращает имя поля в JSON по умолчанию (lowerCamelCase)
+func jsonName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, c := range name {
+		switch {
+		case c == '_':
+			upper = true
+		case upper && 'a' <= c && c <= 'z':
+			b.WriteRune(c - 'a' + 'A')
+			upper = false
+		default:
+			b.WriteRune(c)
+			upper = false
+		}
+	}
+	return b.String()
+}
+
+// addEnum разбирает EnumDescriptorProto
+func (r *Registry) addEnum(scope string, data []byte) error {
+	fields, err := parseFields(data)
+	if err != nil {
+		return err
+	}
+
+	e := &Enum{byName: make(map[string]int32), byNumber: make(map[int32]string)}
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			e.FullName = qualify(scope, string(f.bytes))
+		case 2:
+			values, err := parseFields(f.bytes)
+			if err != nil {
+				return err
+			}
+			var name string
+			var number int32
+			for _, v := range values {
+				switch v.number {
+				case 1:
+					name = string(v.bytes)
+				case 2:
+					number = int32(v.scalar)
+				}
+			}
+			e.byName[name] = number
+			if _, exists := e.byNumber[number]; !exists {
+				e.byNumber[number] = name
+			}
+		}
+	}
+	r.enums[e.FullName] = e
+	return nil
+}
+
+// resolve связывает поле с типом сообщения или перечисления
+func (r *Registry) resolve(fd *Field) error {
+	switch fd.Kind {
+	case KindMessage:
+		if fd.Message = r.messages[fd.typeName]; fd.Message == nil {
+			return fmt.Errorf("message type %s of field %s not found in descriptor set", fd.typeName, fd.Name)
+		}
+	case KindEnum:
+		if fd.Enum = r.enums[fd.typeName]; fd.Enum == nil {
+			return fmt.Errorf("enum type %s of field %s not found in descriptor set", fd.typeName, fd.Name)
+		}
+	}
+	return nil
+}
+
+// addService разбирает ServiceDescriptorProto и правила HTTP его методов
+func (r *Registry) addService(s pendingService) error {
+	fields, err := parseFields(s.data)
+	if err != nil {
+		return err
+	}
+
+	var service string
+	for _, f := range fields {
+		if f.number == 1 {
+			service = qualify(s.pkg, string(f.bytes))
+		}
+	}
+
+	for _, f := range fields {
+		if f.number != 2 {
+			continue
+		}
+		methodFields, err := parseFields(f.bytes)
+		if err != nil {
+			return err
+		}
+
+		m := &Method{}
+		var name, input, output string
+		var options []byte
+		for _, mf := range methodFields {
+			switch mf.number {
+			case 1:
+				name = string(mf.bytes)
+			case 2:
+				input = strings.TrimPrefix(string(mf.bytes), ".")
+			case 3:
+				output = strings.TrimPrefix(string(mf.bytes), ".")
+			case 4:
+				options = mf.bytes
+			case 5:
+				m.ClientStreaming = mf.scalar != 0
+			case 6:
+				m.ServerStreaming = mf.scalar != 0
+			}
+		}
+
+		m.Path = "/" + service + "/" + name
+		if m.Input = r.messages[input]; m.Input == nil {
+			return fmt.Errorf("input type %s of method %s not found in descriptor set", input, m.Path)
+		}
+		if m.Output = r.messages[output]; m.Output == nil {
+			return fmt.Errorf("output type %s of method %s not found in descriptor set", output, m.Path)
+		}
+
+		if options != nil {
+			optionFields, err := parseFields(options)
+			if err != nil {
+				return err
+			}
+			for _, o := range optionFields {
+				if o.number != httpRuleExtension {
+					continue
+				}
+				rules, err := parseHTTPRule(m, o.bytes)
+				if err != nil {
+					return fmt.Errorf("method %s: %w", m.Path, err)
+				}
+				m.Rules = append(m.Rules, rules...)
+			}
+		}
+
+		r.methods[m.Path] = m
+		r.rules = append(r.rules, m.Rules...)
+	}
+	return nil
+}
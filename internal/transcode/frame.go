@@ -0,0 +1,119 @@
+package transcode
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/http"
+)
+
+// Флаги кадра gRPC
+const (
+	// FlagCompressed сообщение сжато (grpc-encoding)
+	FlagCompressed byte = 0x01
+
+	// FlagTrailer кадр gRPC-Web с трейлерами вместо сообщения
+	FlagTrailer byte = 0x80
+)
+
+// frameHeaderSize размер заголовка кадра: флаги и длина сообщения
+const frameHeaderSize = 5
+
+// Frame кадр потока gRPC: сообщение с префиксом длины
+type Frame struct {
+	Flags   byte
+	Payload []byte
+}
+
+// AppendFrame дописывает к b кадр с сообщением payload
+func AppendFrame(b []byte, flags byte, payload []byte) []byte {
+	b = append(b, flags)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(payload)))
+	return append(b, payload...)
+}
+
+// ParseFrames разбирает поток кадров целиком
+func ParseFrames(data []byte) ([]Frame, error) {
+	var frames []Frame
+	for len(data) > 0 {
+		if len(data) < frameHeaderSize {
+			return nil, errors.New("truncated gRPC frame header")
+		}
+		length := binary.BigEndian.Uint32(data[1:frameHeaderSize])
+		if uint64(length) > uint64(len(data)-frameHeaderSize) {
+			return nil, errors.New("truncated gRPC frame")
+		}
+		frames = append(frames, Frame{Flags: data[0], Payload: data[frameHeaderSize : frameHeaderSize+int(length)]})
+		data = data[frameHeaderSize+int(length):]
+	}
+	return frames, nil
+}
+
+// Коды статуса gRPC
+const (
+	CodeOK                 = 0
+	CodeCanceled           = 1
+	CodeUnknown            = 2
+	CodeInvalidArgument    = 3
+	CodeDeadlineExceeded   = 4
+	CodeNotFound           = 5
+	CodeAlreadyExists      = 6
+	CodePermissionDenied   = 7
+	CodeResourceExhausted  = 8
+	CodeFailedPrecondition = 9
+	CodeAborted            = 10
+	CodeOutOfRange         = 11
+	CodeUnimplemented      = 12
+	CodeInternal           = 13
+	CodeUnavailable        = 14
+	CodeDataLoss           = 15
+	CodeUnauthenticated    = 16
+)
+
+// HTTPStatus возвращает HTTP статус, соответствующий коду gRPC (по google.rpc.Code)
+func HTTPStatus(code int) int {
+	switch code {
+	case CodeOK:
+		return http.StatusOK
+	case CodeCanceled:
+		return 499
+	case CodeInvalidArgument, CodeFailedPrecondition, CodeOutOfRange:
+		return http.StatusBadRequest
+	case CodeDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeAlreadyExists, CodeAborted:
+		return http.StatusConflict
+	case CodePermissionDenied:
+		return http.StatusForbidden
+	case CodeResourceExhausted:
+		return http.StatusTooManyRequests
+	case CodeUnimplemented:
+		return http.StatusNotImplemented
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case CodeUnauthenticated:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// StatusCode возвращает код gRPC для ответа HTTP, не содержащего статуса gRPC
+// (например, ошибки самого прокси)
+func StatusCode(httpStatus int) int {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return CodeInternal
+	case http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case http.StatusForbidden:
+		return CodePermissionDenied
+	case http.StatusNotFound:
+		return CodeUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return CodeUnavailable
+	default:
+		return CodeUnknown
+	}
+}
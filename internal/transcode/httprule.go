@@ -0,0 +1,209 @@
+package transcode
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// HTTPRule правило google.api.http, связывающее HTTP запрос с методом gRPC
+type HTTPRule struct {
+	Method *Method
+
+	// HTTP метод и шаблон пути, например GET /v1/{name=shelves/*}
+	HTTPMethod string
+	Pattern    string
+
+	// Поле запроса, заполняемое телом HTTP запроса ("*" — все сообщение, "" — тело не используется)
+	Body string
+
+	// Поле ответа, возвращаемое вместо всего сообщения
+	ResponseBody string
+
+	segments  []string
+	variables []templateVariable
+	verb      string
+}
+
+// templateVariable переменная шаблона пути
+type templateVariable struct {
+	// Путь поля запроса через точку
+	field string
+
+	// Сегменты пути с start по end (end == -1 — до конца пути)
+	start, end int
+}
+
+// parseHTTPRule разбирает HttpRule вместе с дополнительными привязками
+func parseHTTPRule(m *Method, data []byte) ([]*HTTPRule, error) {
+	fields, err := parseFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &HTTPRule{Method: m}
+	var rules []*HTTPRule
+	for _, f := range fields {
+		switch f.number {
+		case 2, 3, 4, 5, 6:
+			rule.HTTPMethod = [...]string{2: "GET", 3: "PUT", 4: "POST", 5: "DELETE", 6: "PATCH"}[f.number]
+			rule.Pattern = string(f.bytes)
+		case 7:
+			rule.Body = string(f.bytes)
+		case 8:
+			custom, err := parseFields(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range custom {
+				switch c.number {
+				case 1:
+					rule.HTTPMethod = string(c.bytes)
+				case 2:
+					rule.Pattern = string(c.bytes)
+				}
+			}
+		case 11:
+			additional, err := parseHTTPRule(m, f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, additional...)
+		case 12:
+			rule.ResponseBody = string(f.bytes)
+		}
+	}
+
+	if rule.Pattern == "" {
+		return rules, nil
+	}
+	if err := rule.compile(); err != nil {
+		return nil, err
+	}
+	return append([]*HTTPRule{rule}, rules...), nil
+}
+
+// compile разбирает шаблон пути
+func (r *HTTPRule) compile() error {
+	pattern := r.Pattern
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("http rule pattern %q must start with /", pattern)
+	}
+
+	// Глагол отделяется двоеточием после последнего сегмента вне переменной
+	if i := strings.LastIndexByte(pattern, ':'); i > strings.LastIndexByte(pattern, '}') && i > strings.LastIndexByte(pattern, '/') {
+		pattern, r.verb = pattern[:i], pattern[i+1:]
+	}
+
+	rest := pattern[1:]
+	for rest != "" {
+		if rest[0] == '{' {
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				return fmt.Errorf("http rule pattern %q: unclosed variable", r.Pattern)
+			}
+			name, template, hasTemplate := strings.Cut(rest[1:end], "=")
+			if !hasTemplate {
+				template = "*"
+			}
+			v := templateVariable{field: name, start: len(r.segments)}
+			for _, s := range strings.Split(template, "/") {
+				if strings.ContainsAny(s, "{}") || s == "" {
+					return fmt.Errorf("http rule pattern %q: invalid variable template", r.Pattern)
+				}
+				r.segments = append(r.segments, s)
+			}
+			v.end = len(r.segments)
+			r.variables = append(r.variables, v)
+			rest = rest[end+1:]
+		} else {
+			end := strings.IndexByte(rest, '/')
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return fmt.Errorf("http rule pattern %q: empty segment", r.Pattern)
+			}
+			r.segments = append(r.segments, rest[:end])
+			rest = rest[end:]
+		}
+
+		if rest != "" {
+			if rest[0] != '/' {
+				return fmt.Errorf("http rule pattern %q: unexpected %q", r.Pattern, rest)
+			}
+			rest = rest[1:]
+			if rest == "" {
+				return fmt.Errorf("http rule pattern %q: trailing /", r.Pattern)
+			}
+		}
+	}
+
+	for i, s := range r.segments {
+		if s == "**" && i != len(r.segments)-1 {
+			return fmt.Errorf("http rule pattern %q: ** must be the last segment", r.Pattern)
+		}
+	}
+	return nil
+}
+
+// match сопоставляет запрос с правилом и возвращает значения переменных пути
+func (r *HTTPRule) match(method, path string) (map[string]string, bool) {
+	if method != r.HTTPMethod && !(method == "HEAD" && r.HTTPMethod == "GET") {
+		return nil, false
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	path = path[1:]
+
+	if r.verb != "" {
+		var ok bool
+		if path, ok = strings.CutSuffix(path, ":"+r.verb); !ok {
+			return nil, false
+		}
+	}
+
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+
+	// Индексы сегментов запроса для каждого сегмента шаблона
+	bounds := make([]int, len(r.segments)+1)
+	i := 0
+	for n, s := range r.segments {
+		bounds[n] = i
+		switch s {
+		case "**":
+			i = len(parts)
+		case "*":
+			if i >= len(parts) || parts[i] == "" {
+				return nil, false
+			}
+			i++
+		default:
+			if i >= len(parts) || parts[i] != s {
+				return nil, false
+			}
+			i++
+		}
+	}
+	bounds[len(r.segments)] = i
+	if i != len(parts) {
+		return nil, false
+	}
+
+	vars := make(map[string]string, len(r.variables))
+	for _, v := range r.variables {
+		value := strings.Join(parts[bounds[v.start]:bounds[v.end]], "/")
+		if v.end-v.start == 1 && r.segments[v.start] == "*" {
+			// Значение из одного сегмента декодируется полностью, из нескольких — сохраняет / как есть
+			if unescaped, err := url.PathUnescape(value); err == nil {
+				value = unescaped
+			}
+		}
+		vars[v.field] = value
+	}
+	return vars, true
+}
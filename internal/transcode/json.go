@@ -0,0 +1,585 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// ToJSON преобразует сообщение в кодировке protobuf в JSON по правилам proto3:
+// 64-битные числа передаются строками, перечисления — именами, bytes — в base64,
+// поля со значениями по умолчанию опускаются
+func (m *Message) ToJSON(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeMessage(&buf, m, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FromJSON кодирует в protobuf значение, разобранное из JSON (decoder.UseNumber).
+// Поля принимаются по имени в proto и в JSON, числа — и в виде строк.
+func (m *Message) FromJSON(v interface{}) ([]byte, error) {
+	return encodeMessage(nil, m, v)
+}
+
+// groupFields разбирает сообщение и группирует значения по номеру поля
+func groupFields(data []byte) (map[int32][]field, error) {
+	fields, err := parseFields(data)
+	if err != nil {
+		return nil, err
+	}
+	grouped := make(map[int32][]field)
+	for _, f := range fields {
+		grouped[f.number] = append(grouped[f.number], f)
+	}
+	return grouped, nil
+}
+
+// scalarWire возвращает тип кодирования значений поля
+func scalarWire(kind Kind) int {
+	switch kind {
+	case KindDouble, KindFixed64, KindSfixed64:
+		return wireFixed64
+	case KindFloat, KindFixed32, KindSfixed32:
+		return wireFixed32
+	case KindString, KindBytes, KindMessage:
+		return wireBytes
+	default:
+		return wireVarint
+	}
+}
+
+// writeMessage записывает сообщение в JSON в порядке объявления полей
+func writeMessage(buf *bytes.Buffer, m *Message, data []byte) error {
+	if ok, err := writeWellKnown(buf, m, data); ok {
+		return err
+	}
+
+	values, err := groupFields(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.FullName, err)
+	}
+
+	buf.WriteByte('{')
+	first := true
+	for _, fd := range m.Fields {
+		vs := values[fd.Number]
+		if len(vs) == 0 {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		writeString(buf, fd.JSONName)
+		buf.WriteByte(':')
+		if err := writeField(buf, fd, vs); err != nil {
+			return fmt.Errorf("field %s: %w", fd.Name, err)
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// writeField записывает значения поля: массив для repeated, объект для map
+func writeField(buf *bytes.Buffer, fd *Field, vs []field) error {
+	switch {
+	case fd.IsMap():
+		return writeMap(buf, fd, vs)
+	case fd.Repeated:
+		items, err := unpack(fd, vs)
+		if err != nil {
+			return err
+		}
+		buf.WriteByte('[')
+		for i, item := range items {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeValue(buf, fd, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case fd.Kind == KindMessage && len(vs) > 1:
+		// Повторения поля-сообщения объединяются, как при разборе protobuf
+		var merged []byte
+		for _, v := range vs {
+			merged = append(merged, v.bytes...)
+		}
+		return writeValue(buf, fd, field{number: fd.Number, wireType: wireBytes, bytes: merged})
+	default:
+		return writeValue(buf, fd, vs[len(vs)-1])
+	}
+}
+
+// unpack раскрывает упакованные значения repeated поля
+func unpack(fd *Field, vs []field) ([]field, error) {
+	wire := scalarWire(fd.Kind)
+	if wire == wireBytes {
+		return vs, nil
+	}
+
+	var items []field
+	for _, v := range vs {
+		if v.wireType != wireBytes {
+			items = append(items, v)
+			continue
+		}
+		b := v.bytes
+		for len(b) > 0 {
+			item := field{number: fd.Number, wireType: wire}
+			switch wire {
+			case wireVarint:
+				value, n, err := consumeVarint(b)
+				if err != nil {
+					return nil, err
+				}
+				item.scalar, b = value, b[n:]
+			case wireFixed32:
+				if len(b) < 4 {
+					return nil, errTruncated
+				}
+				item.scalar, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+			case wireFixed64:
+				if len(b) < 8 {
+					return nil, errTruncated
+				}
+				item.scalar, b = binary.LittleEndian.Uint64(b), b[8:]
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// writeMap записывает элементы map как объект JSON
+func writeMap(buf *bytes.Buffer, fd *Field, vs []field) error {
+	key, value := fd.Message.byNumber[1], fd.Message.byNumber[2]
+	if key == nil || value == nil {
+		return fmt.Errorf("invalid map entry %s", fd.Message.FullName)
+	}
+
+	buf.WriteByte('{')
+	for i, v := range vs {
+		entry, err := groupFields(v.bytes)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		// Ключи map в JSON всегда строки
+		k := ""
+		if kv := entry[1]; len(kv) > 0 {
+			last := kv[len(kv)-1]
+			if key.Kind == KindString {
+				k = string(last.bytes)
+			} else {
+				k = scalarText(key.Kind, last.scalar)
+			}
+		} else if key.Kind != KindString {
+			k = scalarText(key.Kind, 0)
+		}
+		writeString(buf, k)
+		buf.WriteByte(':')
+
+		if vv := entry[2]; len(vv) > 0 {
+			err = writeField(buf, value, vv)
+		} else {
+			err = writeDefault(buf, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// writeDefault записывает значение поля по умолчанию
+func writeDefault(buf *bytes.Buffer, fd *Field) error {
+	switch {
+	case fd.IsMap():
+		buf.WriteString("{}")
+	case fd.Repeated:
+		buf.WriteString("[]")
+	case fd.Kind == KindMessage:
+		return writeMessage(buf, fd.Message, nil)
+	case fd.Kind == KindString || fd.Kind == KindBytes:
+		buf.WriteString(`""`)
+	default:
+		return writeValue(buf, fd, field{number: fd.Number, wireType: scalarWire(fd.Kind)})
+	}
+	return nil
+}
+
+// writeValue записывает одно значение поля
+func writeValue(buf *bytes.Buffer, fd *Field, f field) error {
+	if f.wireType != scalarWire(fd.Kind) {
+		return fmt.Errorf("unexpected wire type %d", f.wireType)
+	}
+
+	switch fd.Kind {
+	case KindMessage:
+		return writeMessage(buf, fd.Message, f.bytes)
+	case KindString:
+		writeString(buf, string(f.bytes))
+	case KindBytes:
+		writeString(buf, base64.StdEncoding.EncodeToString(f.bytes))
+	case KindEnum:
+		if fd.Enum.FullName == "google.protobuf.NullValue" {
+			buf.WriteString("null")
+		} else if name, ok := fd.Enum.byNumber[int32(f.scalar)]; ok {
+			writeString(buf, name)
+		} else {
+			buf.WriteString(strconv.FormatInt(int64(int32(f.scalar)), 10))
+		}
+	case KindInt64, KindSint64, KindSfixed64, KindUint64, KindFixed64:
+		writeString(buf, scalarText(fd.Kind, f.scalar))
+	case KindDouble, KindFloat:
+		text := scalarText(fd.Kind, f.scalar)
+		if text == "NaN" || text == "Infinity" || text == "-Infinity" {
+			writeString(buf, text)
+		} else {
+			buf.WriteString(text)
+		}
+	default:
+		buf.WriteString(scalarText(fd.Kind, f.scalar))
+	}
+	return nil
+}
+
+// scalarText возвращает текстовое представление скалярного значения
+func scalarText(kind Kind, v uint64) string {
+	switch kind {
+	case KindDouble:
+		return formatFloat(math.Float64frombits(v), 64)
+	case KindFloat:
+		return formatFloat(float64(math.Float32frombits(uint32(v))), 32)
+	case KindInt32, KindEnum:
+		return strconv.FormatInt(int64(int32(v)), 10)
+	case KindSint32, KindSint64:
+		return strconv.FormatInt(unzigzag(v), 10)
+	case KindSfixed32:
+		return strconv.FormatInt(int64(int32(uint32(v))), 10)
+	case KindUint32, KindFixed32:
+		return strconv.FormatUint(uint64(uint32(v)), 10)
+	case KindInt64, KindSfixed64:
+		return strconv.FormatInt(int64(v), 10)
+	case KindBool:
+		return strconv.FormatBool(v != 0)
+	default:
+		return strconv.FormatUint(v, 10)
+	}
+}
+
+// formatFloat форматирует число с плавающей точкой, используя имена JSON для особых значений
+func formatFloat(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bits)
+}
+
+// writeString записывает строку JSON
+func writeString(buf *bytes.Buffer, s string) {
+	data, _ := json.Marshal(s)
+	buf.Write(data)
+}
+
+// encodeMessage дописывает к b сообщение m, заданное объектом JSON
+func encodeMessage(b []byte, m *Message, v interface{}) ([]byte, error) {
+	if encoded, ok, err := encodeWellKnown(b, m, v); ok {
+		return encoded, err
+	}
+	if v == nil {
+		return b, nil
+	}
+
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: expected JSON object", m.FullName)
+	}
+	for key := range obj {
+		if m.byName[key] == nil {
+			return nil, fmt.Errorf("%s: unknown field %q", m.FullName, key)
+		}
+	}
+
+	for _, fd := range m.Fields {
+		val, ok := obj[fd.JSONName]
+		if !ok {
+			val, ok = obj[fd.Name]
+		}
+		if !ok || (val == nil && !isValue(fd)) {
+			continue
+		}
+		var err error
+		if b, err = encodeField(b, fd, val); err != nil {
+			return nil, fmt.Errorf("field %s: %w", fd.Name, err)
+		}
+	}
+	return b, nil
+}
+
+// isValue проверяет, что поле имеет тип google.protobuf.Value, для которого null — значение
+func isValue(fd *Field) bool {
+	return fd.Kind == KindMessage && fd.Message.FullName == "google.protobuf.Value"
+}
+
+// encodeField дописывает к b значения поля
+func encodeField(b []byte, fd *Field, val interface{}) ([]byte, error) {
+	var err error
+	switch {
+	case fd.IsMap():
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected JSON object")
+		}
+		key, value := fd.Message.byNumber[1], fd.Message.byNumber[2]
+		if key == nil || value == nil {
+			return nil, fmt.Errorf("invalid map entry %s", fd.Message.FullName)
+		}
+
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			entry, err := encodeValue(nil, key, k)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", k, err)
+			}
+			if obj[k] != nil || isValue(value) {
+				if entry, err = encodeField(entry, value, obj[k]); err != nil {
+					return nil, fmt.Errorf("key %q: %w", k, err)
+				}
+			}
+			b = appendTag(b, fd.Number, wireBytes)
+			b = appendBytes(b, entry)
+		}
+		return b, nil
+
+	case fd.Repeated:
+		items, ok := val.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected JSON array")
+		}
+		if fd.Packed {
+			if len(items) == 0 {
+				return b, nil
+			}
+			var packed []byte
+			for _, item := range items {
+				if packed, err = appendScalar(packed, fd, item); err != nil {
+					return nil, err
+				}
+			}
+			b = appendTag(b, fd.Number, wireBytes)
+			return appendBytes(b, packed), nil
+		}
+		for _, item := range items {
+			if b, err = encodeValue(b, fd, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+
+	default:
+		return encodeValue(b, fd, val)
+	}
+}
+
+// encodeValue дописывает к b одно значение поля вместе с тегом
+func encodeValue(b []byte, fd *Field, val interface{}) ([]byte, error) {
+	switch fd.Kind {
+	case KindMessage:
+		nested, err := encodeMessage(nil, fd.Message, val)
+		if err != nil {
+			return nil, err
+		}
+		b = appendTag(b, fd.Number, wireBytes)
+		return appendBytes(b, nested), nil
+	case KindString:
+		s, ok := val.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string")
+		}
+		b = appendTag(b, fd.Number, wireBytes)
+		return appendBytes(b, []byte(s)), nil
+	case KindBytes:
+		s, ok := val.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected base64 string")
+		}
+		data, err := decodeBase64(s)
+		if err != nil {
+			return nil, err
+		}
+		b = appendTag(b, fd.Number, wireBytes)
+		return appendBytes(b, data), nil
+	default:
+		b = appendTag(b, fd.Number, scalarWire(fd.Kind))
+		return appendScalar(b, fd, val)
+	}
+}
+
+// appendScalar дописывает к b значение скалярного поля без тега
+func appendScalar(b []byte, fd *Field, val interface{}) ([]byte, error) {
+	switch fd.Kind {
+	case KindEnum:
+		switch v := val.(type) {
+		case nil:
+			return appendVarint(b, 0), nil
+		case string:
+			if n, ok := fd.Enum.byName[v]; ok {
+				return appendVarint(b, uint64(int64(n))), nil
+			}
+			if _, err := strconv.ParseInt(v, 10, 32); err != nil {
+				return nil, fmt.Errorf("unknown value %q of enum %s", v, fd.Enum.FullName)
+			}
+		}
+	case KindBool:
+		switch v := val.(type) {
+		case bool:
+			return appendVarint(b, boolValue(v)), nil
+		case string:
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bool %q", v)
+			}
+			return appendVarint(b, boolValue(parsed)), nil
+		default:
+			return nil, fmt.Errorf("expected bool")
+		}
+	}
+
+	text, err := numberText(val)
+	if err != nil {
+		return nil, err
+	}
+
+	switch fd.Kind {
+	case KindDouble:
+		f, err := parseFloat(text, 64)
+		if err != nil {
+			return nil, err
+		}
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(f)), nil
+	case KindFloat:
+		f, err := parseFloat(text, 32)
+		if err != nil {
+			return nil, err
+		}
+		return binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(f))), nil
+	case KindUint32, KindFixed32, KindUint64, KindFixed64:
+		bits := 64
+		if fd.Kind == KindUint32 || fd.Kind == KindFixed32 {
+			bits = 32
+		}
+		u, err := strconv.ParseUint(text, 10, bits)
+		if err != nil {
+			f, ferr := parseFloat(text, 64)
+			if ferr != nil || f < 0 || f != math.Trunc(f) || f >= math.Pow(2, float64(bits)) {
+				return nil, fmt.Errorf("invalid unsigned integer %q", text)
+			}
+			u = uint64(f)
+		}
+		switch fd.Kind {
+		case KindFixed32:
+			return binary.LittleEndian.AppendUint32(b, uint32(u)), nil
+		case KindFixed64:
+			return binary.LittleEndian.AppendUint64(b, u), nil
+		default:
+			return appendVarint(b, u), nil
+		}
+	default:
+		bits := 64
+		if fd.Kind == KindInt32 || fd.Kind == KindSint32 || fd.Kind == KindSfixed32 || fd.Kind == KindEnum {
+			bits = 32
+		}
+		i, err := strconv.ParseInt(text, 10, bits)
+		if err != nil {
+			f, ferr := parseFloat(text, 64)
+			limit := math.Pow(2, float64(bits-1))
+			if ferr != nil || f != math.Trunc(f) || f < -limit || f >= limit {
+				return nil, fmt.Errorf("invalid integer %q", text)
+			}
+			i = int64(f)
+		}
+		switch fd.Kind {
+		case KindSint32, KindSint64:
+			return appendVarint(b, zigzag(i)), nil
+		case KindSfixed32:
+			return binary.LittleEndian.AppendUint32(b, uint32(int32(i))), nil
+		case KindSfixed64:
+			return binary.LittleEndian.AppendUint64(b, uint64(i)), nil
+		default:
+			return appendVarint(b, uint64(i)), nil
+		}
+	}
+}
+
+// boolValue кодирует bool как varint
+func boolValue(v bool) uint64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// numberText возвращает текст числа из JSON числа или строки
+func numberText(val interface{}) (string, error) {
+	switch v := val.(type) {
+	case json.Number:
+		return string(v), nil
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	default:
+		return "", fmt.Errorf("expected number")
+	}
+}
+
+// parseFloat разбирает число с плавающей точкой, включая NaN и Infinity
+func parseFloat(text string, bits int) (float64, error) {
+	switch text {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+	f, err := strconv.ParseFloat(text, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", text)
+	}
+	return f, nil
+}
+
+// decodeBase64 декодирует bytes в стандартном или URL-безопасном base64, с дополнением и без
+func decodeBase64(s string) ([]byte, error) {
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if data, err := encoding.DecodeString(s); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid base64 value")
+}
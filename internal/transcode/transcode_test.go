@@ -0,0 +1,220 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// pb собирает сообщение protobuf для дескрипторов в тестах
+type pb []byte
+
+func (b pb) str(number int32, s string) pb {
+	return appendBytes(appendTag(b, number, wireBytes), []byte(s))
+}
+
+func (b pb) msg(number int32, m pb) pb {
+	return appendBytes(appendTag(b, number, wireBytes), m)
+}
+
+func (b pb) num(number int32, v uint64) pb {
+	return appendVarint(appendTag(b, number, wireVarint), v)
+}
+
+// fieldDesc описание поля FieldDescriptorProto
+func fieldDesc(name string, number int32, kind Kind, repeated bool, typeName string) pb {
+	label := uint64(1)
+	if repeated {
+		label = 3
+	}
+	f := pb(nil).str(1, name).num(3, uint64(number)).num(4, label).num(5, uint64(kind))
+	if typeName != "" {
+		f = f.str(6, typeName)
+	}
+	return f
+}
+
+// methodDesc описание метода с правилом google.api.http
+func methodDesc(name, input, output string, serverStreaming bool, rule pb) pb {
+	m := pb(nil).str(1, name).str(2, input).str(3, output)
+	if rule != nil {
+		m = m.msg(4, pb(nil).msg(httpRuleExtension, rule))
+	}
+	if serverStreaming {
+		m = m.num(6, 1)
+	}
+	return m
+}
+
+// testDescriptorSet набор дескрипторов, соответствующий файлу
+//
+//	syntax = "proto3";
+//	package library.v1;
+//
+//	service Library {
+//	  rpc GetBook(GetBookRequest) returns (Book) { option (google.api.http) = { get: "/v1/{name=shelves/*/books/*}" }; }
+//	  rpc CreateBook(CreateBookRequest) returns (Book) { option (google.api.http) = { post: "/v1/{parent=shelves/*}/books" body: "book" }; }
+//	  rpc SearchBooks(SearchBooksRequest) returns (stream Book) { option (google.api.http) = { post: "/v1/books:search" body: "*" }; }
+//	}
+//
+//	message Book {
+//	  enum Format { FORMAT_UNSPECIFIED = 0; FORMAT_PAPER = 1; FORMAT_EBOOK = 2; }
+//	  string name = 1;
+//	  int64 page_count = 2;
+//	  repeated int32 ratings = 3;
+//	  map<string, string> labels = 4;
+//	  Format format = 5;
+//	  google.protobuf.Timestamp published = 6;
+//	}
+//	message GetBookRequest { string name = 1; }
+//	message CreateBookRequest { string parent = 1; Book book = 2; }
+//	message SearchBooksRequest { string query = 1; repeated string labels = 2; }
+func testDescriptorSet() []byte {
+	timestamp := pb(nil).str(1, "google/protobuf/timestamp.proto").str(2, "google.protobuf").
+		msg(4, pb(nil).str(1, "Timestamp").
+			msg(2, fieldDesc("seconds", 1, KindInt64, false, "")).
+			msg(2, fieldDesc("nanos", 2, KindInt32, false, ""))).
+		str(12, "proto3")
+
+	format := pb(nil).str(1, "Format").
+		msg(2, pb(nil).str(1, "FORMAT_UNSPECIFIED").num(2, 0)).
+		msg(2, pb(nil).str(1, "FORMAT_PAPER").num(2, 1)).
+		msg(2, pb(nil).str(1, "FORMAT_EBOOK").num(2, 2))
+	labelsEntry := pb(nil).str(1, "LabelsEntry").
+		msg(2, fieldDesc("key", 1, KindString, false, "")).
+		msg(2, fieldDesc("value", 2, KindString, false, "")).
+		msg(7, pb(nil).num(7, 1))
+	book := pb(nil).str(1, "Book").
+		msg(2, fieldDesc("name", 1, KindString, false, "")).
+		msg(2, fieldDesc("page_count", 2, KindInt64, false, "")).
+		msg(2, fieldDesc("ratings", 3, KindInt32, true, "")).
+		msg(2, fieldDesc("labels", 4, KindMessage, true, ".library.v1.Book.LabelsEntry")).
+		msg(2, fieldDesc("format", 5, KindEnum, false, ".library.v1.Book.Format")).
+		msg(2, fieldDesc("published", 6, KindMessage, false, ".google.protobuf.Timestamp")).
+		msg(3, labelsEntry).
+		msg(4, format)
+
+	service := pb(nil).str(1, "Library").
+		msg(2, methodDesc("GetBook", ".library.v1.GetBookRequest", ".library.v1.Book", false,
+			pb(nil).str(2, "/v1/{name=shelves/*/books/*}"))).
+		msg(2, methodDesc("CreateBook", ".library.v1.CreateBookRequest", ".library.v1.Book", false,
+			pb(nil).str(4, "/v1/{parent=shelves/*}/books").str(7, "book"))).
+		msg(2, methodDesc("SearchBooks", ".library.v1.SearchBooksRequest", ".library.v1.Book", true,
+			pb(nil).str(4, "/v1/books:search").str(7, "*")))
+
+	library := pb(nil).str(1, "library.proto").str(2, "library.v1").
+		msg(4, book).
+		msg(4, pb(nil).str(1, "GetBookRequest").msg(2, fieldDesc("name", 1, KindString, false, ""))).
+		msg(4, pb(nil).str(1, "CreateBookRequest").
+			msg(2, fieldDesc("parent", 1, KindString, false, "")).
+			msg(2, fieldDesc("book", 2, KindMessage, false, ".library.v1.Book"))).
+		msg(4, pb(nil).str(1, "SearchBooksRequest").
+			msg(2, fieldDesc("query", 1, KindString, false, "")).
+			msg(2, fieldDesc("labels", 2, KindString, true, ""))).
+		msg(6, service).
+		str(12, "proto3")
+
+	return pb(nil).msg(1, timestamp).msg(1, library)
+}
+
+func TestRegistryMatch(t *testing.T) {
+	r, err := Parse(testDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule, vars := r.Match("GET", "/v1/shelves/1/books/moby%20dick")
+	if rule == nil || rule.Method.Path != "/library.v1.Library/GetBook" {
+		t.Fatalf("запрос не сопоставлен с GetBook: %+v", rule)
+	}
+	if vars["name"] != "shelves/1/books/moby%20dick" {
+		t.Errorf("переменная name = %q", vars["name"])
+	}
+
+	if rule, vars := r.Match("POST", "/v1/shelves/7/books"); rule == nil || vars["parent"] != "shelves/7" || rule.Body != "book" {
+		t.Errorf("запрос не сопоставлен с CreateBook: %+v %v", rule, vars)
+	}
+	if rule, _ := r.Match("POST", "/v1/books:search"); rule == nil || !rule.Method.ServerStreaming {
+		t.Errorf("запрос с глаголом не сопоставлен с SearchBooks: %+v", rule)
+	}
+
+	for _, miss := range []struct{ method, path string }{
+		{"POST", "/v1/shelves/1/books/2"},
+		{"GET", "/v1/shelves/1/books"},
+		{"GET", "/v1/shelves/1/books/2/pages"},
+		{"POST", "/v1/books"},
+	} {
+		if rule, _ := r.Match(miss.method, miss.path); rule != nil {
+			t.Errorf("%s %s не должен сопоставляться с %s", miss.method, miss.path, rule.Pattern)
+		}
+	}
+
+	if r.Method("/library.v1.Library/GetBook") == nil {
+		t.Error("метод не найден по пути gRPC")
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	r, err := Parse(testDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := r.Message("library.v1.Book")
+
+	input := `{"name":"Moby Dick","page_count":635,"ratings":[5,"4",-1],"labels":{"lang":"en"},"format":"FORMAT_PAPER","published":"1851-10-18T12:00:00.5Z"}`
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(input)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := book.FromJSON(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := book.ToJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"name":"Moby Dick","pageCount":"635","ratings":[5,4,-1],"labels":{"lang":"en"},"format":"FORMAT_PAPER","published":"1851-10-18T12:00:00.5Z"}`
+	if string(out) != want {
+		t.Errorf("неверный JSON:\n%s\nожидалось:\n%s", out, want)
+	}
+
+	// Значения по умолчанию опускаются
+	if out, err := book.ToJSON(nil); err != nil || string(out) != "{}" {
+		t.Errorf("пустое сообщение: %s, %v", out, err)
+	}
+
+	for _, invalid := range []string{
+		`{"unknown":1}`,
+		`{"format":"FORMAT_VINYL"}`,
+		`{"ratings":[1.5]}`,
+		`{"page_count":"x"}`,
+		`{"published":"yesterday"}`,
+	} {
+		var v interface{}
+		json.Unmarshal([]byte(invalid), &v)
+		if _, err := book.FromJSON(v); err == nil {
+			t.Errorf("ожидалась ошибка для %s", invalid)
+		}
+	}
+}
+
+func TestHTTPRuleCompile(t *testing.T) {
+	for _, pattern := range []string{"v1/books", "/v1/{name", "/v1/**/books", "/v1//books", "/v1/books/"} {
+		rule := &HTTPRule{HTTPMethod: "GET", Pattern: pattern}
+		if err := rule.compile(); err == nil {
+			t.Errorf("ожидалась ошибка для шаблона %q", pattern)
+		}
+	}
+
+	rule := &HTTPRule{HTTPMethod: "GET", Pattern: "/v1/{name=files/**}:download"}
+	if err := rule.compile(); err != nil {
+		t.Fatal(err)
+	}
+	if vars, ok := rule.match("GET", "/v1/files/a/b/c.txt:download"); !ok || vars["name"] != "files/a/b/c.txt" {
+		t.Errorf("шаблон ** не сопоставлен: %v %v", vars, ok)
+	}
+}
@@ -0,0 +1,119 @@
+package transcode
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Типы кодирования значений в формате protobuf
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireGroup   = 3
+	wireEnd     = 4
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// appendVarint дописывает число в кодировке varint
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// appendTag дописывает номер поля и тип кодирования
+func appendTag(b []byte, number int32, wireType int) []byte {
+	return appendVarint(b, uint64(number)<<3|uint64(wireType))
+}
+
+// appendBytes дописывает значение с префиксом длины
+func appendBytes(b, v []byte) []byte {
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// consumeVarint читает varint и возвращает значение и число прочитанных байт
+func consumeVarint(b []byte) (uint64, int, error) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7F) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, errTruncated
+}
+
+// field закодированное поле сообщения
+type field struct {
+	number   int32
+	wireType int
+
+	// Значение varint, fixed32 или fixed64
+	scalar uint64
+
+	// Значение с префиксом длины
+	bytes []byte
+}
+
+// parseFields разбирает сообщение на поля в порядке их следования
+func parseFields(b []byte) ([]field, error) {
+	var fields []field
+	for len(b) > 0 {
+		tag, n, err := consumeVarint(b)
+		if err != nil {
+			return nil, err
+		}
+		b = b[n:]
+
+		f := field{number: int32(tag >> 3), wireType: int(tag & 7)}
+		if f.number <= 0 {
+			return nil, errors.New("invalid protobuf field number")
+		}
+		switch f.wireType {
+		case wireVarint:
+			if f.scalar, n, err = consumeVarint(b); err != nil {
+				return nil, err
+			}
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, errTruncated
+			}
+			f.scalar, n = binary.LittleEndian.Uint64(b), 8
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, errTruncated
+			}
+			f.scalar, n = uint64(binary.LittleEndian.Uint32(b)), 4
+		case wireBytes:
+			length, m, err := consumeVarint(b)
+			if err != nil {
+				return nil, err
+			}
+			if length > uint64(len(b)-m) {
+				return nil, errTruncated
+			}
+			f.bytes, n = b[m:m+int(length)], m+int(length)
+		default:
+			return nil, errors.New("protobuf groups are not supported")
+		}
+		b = b[n:]
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// zigzag кодирует знаковое число для sint32/sint64
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// unzigzag декодирует sint32/sint64
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
@@ -0,0 +1,257 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// wrapperTypes известные типы, представленные в JSON значением своего единственного поля
+var wrapperTypes = map[string]bool{
+	"google.protobuf.DoubleValue": true,
+	"google.protobuf.FloatValue":  true,
+	"google.protobuf.Int64Value":  true,
+	"google.protobuf.UInt64Value": true,
+	"google.protobuf.Int32Value":  true,
+	"google.protobuf.UInt32Value": true,
+	"google.protobuf.BoolValue":   true,
+	"google.protobuf.StringValue": true,
+	"google.protobuf.BytesValue":  true,
+	"google.protobuf.Struct":      true,
+	"google.protobuf.ListValue":   true,
+}
+
+// writeWellKnown записывает известный тип google.protobuf в его особом представлении JSON.
+// Возвращает false, если тип не относится к известным.
+func writeWellKnown(buf *bytes.Buffer, m *Message, data []byte) (bool, error) {
+	switch {
+	case m.FullName == "google.protobuf.Timestamp":
+		seconds, nanos, err := secondsNanos(data)
+		if err != nil {
+			return true, err
+		}
+		writeString(buf, time.Unix(seconds, nanos).UTC().Format(time.RFC3339Nano))
+		return true, nil
+
+	case m.FullName == "google.protobuf.Duration":
+		seconds, nanos, err := secondsNanos(data)
+		if err != nil {
+			return true, err
+		}
+		writeString(buf, formatDuration(seconds, nanos))
+		return true, nil
+
+	case m.FullName == "google.protobuf.FieldMask":
+		values, err := groupFields(data)
+		if err != nil {
+			return true, err
+		}
+		paths := make([]string, 0, len(values[1]))
+		for _, v := range values[1] {
+			paths = append(paths, jsonName(string(v.bytes)))
+		}
+		writeString(buf, strings.Join(paths, ","))
+		return true, nil
+
+	case m.FullName == "google.protobuf.Value":
+		fields, err := parseFields(data)
+		if err != nil {
+			return true, err
+		}
+		// Поля Value входят в oneof, действует последнее
+		var kind *field
+		for i := range fields {
+			if m.byNumber[fields[i].number] != nil {
+				kind = &fields[i]
+			}
+		}
+		if kind == nil {
+			buf.WriteString("null")
+			return true, nil
+		}
+		return true, writeValue(buf, m.byNumber[kind.number], *kind)
+
+	case wrapperTypes[m.FullName]:
+		fd := m.byNumber[1]
+		if fd == nil {
+			return false, nil
+		}
+		values, err := groupFields(data)
+		if err != nil {
+			return true, err
+		}
+		if len(values[1]) == 0 {
+			return true, writeDefault(buf, fd)
+		}
+		return true, writeField(buf, fd, values[1])
+	}
+	return false, nil
+}
+
+// encodeWellKnown кодирует известный тип google.protobuf из его представления JSON.
+// Возвращает false, если тип не относится к известным.
+func encodeWellKnown(b []byte, m *Message, v interface{}) ([]byte, bool, error) {
+	switch {
+	case m.FullName == "google.protobuf.Timestamp":
+		s, ok := v.(string)
+		if !ok {
+			return nil, true, fmt.Errorf("expected RFC 3339 timestamp string")
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid timestamp %q", s)
+		}
+		return appendSecondsNanos(b, t.Unix(), int64(t.Nanosecond())), true, nil
+
+	case m.FullName == "google.protobuf.Duration":
+		s, ok := v.(string)
+		if !ok {
+			return nil, true, fmt.Errorf("expected duration string")
+		}
+		seconds, nanos, err := parseDuration(s)
+		if err != nil {
+			return nil, true, err
+		}
+		return appendSecondsNanos(b, seconds, nanos), true, nil
+
+	case m.FullName == "google.protobuf.FieldMask":
+		s, ok := v.(string)
+		if !ok {
+			return nil, true, fmt.Errorf("expected field mask string")
+		}
+		if s == "" {
+			return b, true, nil
+		}
+		for _, path := range strings.Split(s, ",") {
+			b = appendTag(b, 1, wireBytes)
+			b = appendBytes(b, []byte(snakeName(path)))
+		}
+		return b, true, nil
+
+	case m.FullName == "google.protobuf.Value":
+		var number int32
+		switch v.(type) {
+		case nil:
+			number = 1
+		case json.Number, float64:
+			number = 2
+		case string:
+			number = 3
+		case bool:
+			number = 4
+		case map[string]interface{}:
+			number = 5
+		case []interface{}:
+			number = 6
+		default:
+			return nil, true, fmt.Errorf("unsupported value %v", v)
+		}
+		fd := m.byNumber[number]
+		if fd == nil {
+			return nil, true, fmt.Errorf("invalid %s descriptor", m.FullName)
+		}
+		encoded, err := encodeValue(b, fd, v)
+		return encoded, true, err
+
+	case wrapperTypes[m.FullName]:
+		fd := m.byNumber[1]
+		if fd == nil {
+			return nil, false, nil
+		}
+		if v == nil {
+			return b, true, nil
+		}
+		encoded, err := encodeField(b, fd, v)
+		return encoded, true, err
+	}
+	return nil, false, nil
+}
+
+// secondsNanos читает поля seconds и nanos сообщений Timestamp и Duration
+func secondsNanos(data []byte) (int64, int64, error) {
+	values, err := groupFields(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	var seconds, nanos int64
+	if v := values[1]; len(v) > 0 {
+		seconds = int64(v[len(v)-1].scalar)
+	}
+	if v := values[2]; len(v) > 0 {
+		nanos = int64(int32(v[len(v)-1].scalar))
+	}
+	return seconds, nanos, nil
+}
+
+// appendSecondsNanos дописывает поля seconds и nanos, опуская нулевые
+func appendSecondsNanos(b []byte, seconds, nanos int64) []byte {
+	if seconds != 0 {
+		b = appendTag(b, 1, wireVarint)
+		b = appendVarint(b, uint64(seconds))
+	}
+	if nanos != 0 {
+		b = appendTag(b, 2, wireVarint)
+		b = appendVarint(b, uint64(nanos))
+	}
+	return b
+}
+
+// formatDuration форматирует длительность в виде "1.5s"
+func formatDuration(seconds, nanos int64) string {
+	sign := ""
+	if seconds < 0 || nanos < 0 {
+		sign, seconds, nanos = "-", -seconds, -nanos
+	}
+	text := sign + strconv.FormatInt(seconds, 10)
+	if nanos != 0 {
+		fraction := fmt.Sprintf("%09d", nanos)
+		for strings.HasSuffix(fraction, "000") {
+			fraction = fraction[:len(fraction)-3]
+		}
+		text += "." + fraction
+	}
+	return text + "s"
+}
+
+// parseDuration разбирает длительность в виде "1.5s"
+func parseDuration(s string) (int64, int64, error) {
+	text, ok := strings.CutSuffix(s, "s")
+	if !ok || text == "" {
+		return 0, 0, fmt.Errorf("invalid duration %q", s)
+	}
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(text, "-")
+
+	whole, fraction, _ := strings.Cut(text, ".")
+	seconds, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || len(fraction) > 9 {
+		return 0, 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var nanos int64
+	if fraction != "" {
+		if nanos, err = strconv.ParseInt(fraction+strings.Repeat("0", 9-len(fraction)), 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid duration %q", s)
+		}
+	}
+	if negative {
+		seconds, nanos = -seconds, -nanos
+	}
+	return seconds, nanos, nil
+}
+
+// snakeName преобразует путь FieldMask из lowerCamelCase в имена полей proto
+func snakeName(path string) string {
+	var b strings.Builder
+	for _, c := range path {
+		if unicode.IsUpper(c) {
+			b.WriteByte('_')
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,289 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/accessevents"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/internal/outlier"
+	"cloud.ru_test/internal/usage"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+	"cloud.ru_test/pkg/workerpool"
+)
+
+// Значения по умолчанию для конвейера учета запросов
+const (
+	defaultRecordQueueSize = 8192
+	defaultRecordWorkers   = 2
+)
+
+var (
+	// requestsTotal счетчик обработанных запросов по бэкендам и классам статусов
+	requestsTotal = metrics.Default.Counter("lb_requests_total", "Requests handled by the proxy by backend and status class", "backend", "code")
+
+	// responseBytes счетчик байт тела ответов, отправленных клиентам
+	responseBytes = metrics.Default.Counter("lb_response_bytes_total", "Response body bytes sent to clients by backend", "backend")
+
+	// droppedRecords счетчик записей, отброшенных при переполнении очереди
+	droppedRecords = metrics.Default.Counter("lb_request_records_dropped_total", "Request records dropped because the processing queue was full")
+)
+
+// accessRecord итог обработки запроса. Заполняется в горутине запроса и обрабатывается
+// Recorder: запись в журнал доступа, метрики и результат запроса к бэкенду для детектора выбросов.
+type accessRecord struct {
+	start     time.Time
+	duration  time.Duration
+	method    string
+	path      string
+	requestID string
+	client    string
+	userID    string
+	status    int
+	bytes     int64
+	bytesIn   int64
+
+	// Тенант для учета потребления (пустой, если учет отключен)
+	tenant string
+	usage  *usage.Accountant
+
+	// Публикация события о запросе (nil, если отключена)
+	events *accessevents.Publisher
+
+	// Бэкенд, выбранный для запроса (nil, если запрос не дошел до этапа proxy)
+	backend backend.Backend
+
+	// Результат запроса к бэкенду, передаваемый детектору выбросов
+	outlier  *outlier.Detector
+	observed bool
+	upstream int
+	err      error
+}
+
+// recordKey ключ записи запроса в контексте
+type recordKey struct{}
+
+// recordFromContext возвращает запись запроса или nil
+func recordFromContext(ctx context.Context) *accessRecord {
+	rec, _ := ctx.Value(recordKey{}).(*accessRecord)
+	return rec
+}
+
+// process учитывает запрос в метриках, передает результат запроса к бэкенду
+// детектору выбросов и, если log не nil, записывает запрос в журнал доступа
+func (rec *accessRecord) process(log logger.Logger) {
+	if rec.observed {
+		rec.outlier.Observe(rec.backend, rec.upstream, rec.err)
+	}
+
+	backendID := ""
+	if rec.backend != nil {
+		backendID = rec.backend.ID()
+	}
+	requestsTotal.Inc(backendID, statusClass(rec.status))
+	responseBytes.Add(rec.bytes, backendID)
+	if rec.usage != nil {
+		rec.usage.Record(rec.tenant, rec.bytesIn, rec.bytes, rec.status)
+	}
+	if rec.events != nil {
+		rec.events.Publish(accessevents.Event{
+			Time:      rec.start,
+			RequestID: rec.requestID,
+			Client:    rec.client,
+			UserID:    rec.userID,
+			Method:    rec.method,
+			Path:      rec.path,
+			Status:    rec.status,
+			BytesIn:   rec.bytesIn,
+			BytesOut:  rec.bytes,
+			Duration:  float64(rec.duration) / float64(time.Millisecond),
+			Backend:   backendID,
+		})
+	}
+
+	if log != nil {
+		log.Info("Запрос обработан",
+			"requestID", rec.requestID,
+			"client", rec.client,
+			"userID", rec.userID,
+			"method", rec.method,
+			"path", rec.path,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration", rec.duration,
+			"backend", backendID,
+			"time", rec.start)
+	}
+}
+
+// statusClass возвращает класс статуса ответа: 2xx, 3xx, 4xx или 5xx
+func statusClass(status int) string {
+	switch {
+	case status >= 500:
+		return "5xx"
+	case status >= 400:
+		return "4xx"
+	case status >= 300:
+		return "3xx"
+	default:
+		return "2xx"
+	}
+}
+
+// Recorder обрабатывает записи о запросах вне горутин запросов: на нагрузке запись
+// в журнал и обновление статистики не увеличивают время ответа. При переполнении
+// очереди записи отбрасываются (lb_request_records_dropped_total), а не задерживают
+// запросы. Как и RoutingDebug, не зависит от реконфигурации прокси.
+type Recorder struct {
+	pool    *workerpool.WorkerPool
+	logger  logger.Logger
+	enabled atomic.Bool
+	usage   atomic.Pointer[usage.Accountant]
+	events  atomic.Pointer[accessevents.Publisher]
+}
+
+// NewRecorder создает Recorder с очередью и числом горутин из cfg (nil — значения по умолчанию,
+// журнал доступа отключен)
+func NewRecorder(cfg *config.AccessLogConfig, appLogger logger.Logger) *Recorder {
+	queueSize, workers := defaultRecordQueueSize, defaultRecordWorkers
+	if cfg != nil {
+		if cfg.QueueSize > 0 {
+			queueSize = cfg.QueueSize
+		}
+		if cfg.Workers > 0 {
+			workers = cfg.Workers
+		}
+	}
+
+	r := &Recorder{logger: appLogger}
+	r.pool = workerpool.New(workerpool.Options{
+		Workers:   workers,
+		QueueSize: queueSize,
+		Policy:    workerpool.Reject,
+		PanicHandler: func(recovered interface{}, stack []byte) {
+			appLogger.Error("Паника при обработке записи о запросе", "panic", recovered, "stack", string(stack))
+		},
+	})
+	r.Configure(cfg)
+	return r
+}
+
+// Configure включает или отключает журнал доступа. Размер очереди и число горутин
+// задаются при создании.
+func (r *Recorder) Configure(cfg *config.AccessLogConfig) {
+	r.enabled.Store(cfg != nil && cfg.Enabled)
+}
+
+// SetUsage задает учет потребления по тенантам (nil — учет отключен)
+func (r *Recorder) SetUsage(a *usage.Accountant) {
+	r.usage.Store(a)
+}
+
+// accountant возвращает учет потребления или nil
+func (r *Recorder) accountant() *usage.Accountant {
+	if r == nil {
+		return nil
+	}
+	return r.usage.Load()
+}
+
+// SetEvents задает публикацию событий о запросах (nil — публикация отключена)
+func (r *Recorder) SetEvents(p *accessevents.Publisher) {
+	r.events.Store(p)
+}
+
+// publisher возвращает публикацию событий о запросах или nil
+func (r *Recorder) publisher() *accessevents.Publisher {
+	if r == nil {
+		return nil
+	}
+	return r.events.Load()
+}
+
+// record ставит запись в очередь. Без Recorder запись обрабатывается сразу, без журнала доступа.
+func (r *Recorder) record(rec *accessRecord) {
+	if r == nil {
+		rec.process(nil)
+		return
+	}
+
+	var log logger.Logger
+	if r.enabled.Load() {
+		log = r.logger
+	}
+	if err := r.pool.Submit(context.Background(), func() { rec.process(log) }); err != nil {
+		droppedRecords.Inc()
+	}
+}
+
+// Close дожидается обработки записей из очереди до окончания ctx
+func (r *Recorder) Close(ctx context.Context) error {
+	return r.pool.Shutdown(ctx)
+}
+
+// access заводит запись о запросе и по завершении обработки передает ее Recorder
+func (p *Proxy) access(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &accessRecord{start: time.Now(), method: r.Method, path: r.URL.Path, outlier: p.outlier, usage: p.recorder.accountant(), events: p.recorder.publisher()}
+		sw := &statusWriter{ResponseWriter: w}
+		r = r.WithContext(context.WithValue(r.Context(), recordKey{}, rec))
+
+		// Заголовок тенанта читается до middleware, которые могут его удалить
+		if rec.usage != nil {
+			if header := rec.usage.TenantHeader(); header != "" {
+				rec.tenant = r.Header.Get(header)
+			}
+		}
+		var body *countingBody
+		if (rec.usage != nil || rec.events != nil) && r.Body != nil && r.Body != http.NoBody {
+			body = &countingBody{ReadCloser: r.Body}
+			r.Body = body
+		}
+		next.ServeHTTP(sw, r)
+
+		rec.duration = time.Since(rec.start)
+		rec.status = sw.status()
+		rec.bytes = sw.bytes
+		if body != nil {
+			rec.bytesIn = body.read.Load()
+		}
+		if req := request.FromContext(r.Context()); req != nil {
+			rec.client = req.GetClientIP()
+			rec.userID = req.GetUserID()
+			rec.requestID, _ = request.Get(req, request.KeyRequestID)
+		}
+		if rec.tenant == "" {
+			rec.tenant = rec.userID
+		}
+		p.recorder.record(rec)
+	})
+}
+
+// countingBody считает байты тела запроса, прочитанные прокси. Тело может читаться
+// транспортом в другой горутине, поэтому счетчик атомарный.
+type countingBody struct {
+	io.ReadCloser
+	read atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read.Add(int64(n))
+	return n, err
+}
+
+// observe передает результат запроса к бэкенду детектору выбросов: вместе с записью
+// о запросе, если она есть, иначе сразу
+func (p *Proxy) observe(r *http.Request, b backend.Backend, statusCode int, err error) {
+	if rec := recordFromContext(r.Context()); rec != nil {
+		rec.observed, rec.upstream, rec.err = true, statusCode, err
+		return
+	}
+	p.outlier.Observe(b, statusCode, err)
+}
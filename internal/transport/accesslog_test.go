@@ -0,0 +1,129 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/internal/usage"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestRecorder(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer upstream.Close()
+
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backend.NewBackendWithOptions("recorder-b1", upstream.URL, 1, backend.Options{})
+	defer b.Close()
+	lb.AddBackend(b)
+
+	core, logs := observer.New(zap.InfoLevel)
+	recorder := NewRecorder(&config.AccessLogConfig{Enabled: true}, logger.FromZap(zap.New(core)))
+	proxy := NewProxy(lb, ratelimit.NewNoopRateLimiter(), Options{Recorder: recorder}, logger.NewNop())
+
+	requestsBefore, bytesBefore := requestsTotal.Value("recorder-b1", "2xx"), responseBytes.Value("recorder-b1")
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest("GET", "/path", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("ожидался ответ 200, получено %d", rec.Code)
+		}
+	}
+
+	// Close дожидается обработки всех записей из очереди
+	if err := recorder.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := logs.FilterMessage("Запрос обработан").All()
+	if len(entries) != 3 {
+		t.Fatalf("ожидалось 3 записи журнала доступа, получено %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["path"] != "/path" || fields["backend"] != "recorder-b1" || fields["status"] != int64(http.StatusOK) {
+		t.Errorf("неверные поля записи журнала доступа: %v", fields)
+	}
+	if got := requestsTotal.Value("recorder-b1", "2xx") - requestsBefore; got != 3 {
+		t.Errorf("ожидалось 3 запроса в lb_requests_total, получено %d", got)
+	}
+	if got := responseBytes.Value("recorder-b1") - bytesBefore; got != 15 {
+		t.Errorf("ожидалось 15 байт в lb_response_bytes_total, получено %d", got)
+	}
+
+	// После закрытия записи отбрасываются, а не обрабатываются в горутине запроса
+	dropped := droppedRecords.Value()
+	proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/path", nil))
+	if droppedRecords.Value() != dropped+1 {
+		t.Errorf("запись, не принятая в очередь, должна учитываться в lb_request_records_dropped_total")
+	}
+}
+
+func TestRecorder_Usage(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte("hello"))
+	}))
+	defer upstream.Close()
+
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backend.NewBackendWithOptions("usage-b1", upstream.URL, 1, backend.Options{})
+	defer b.Close()
+	lb.AddBackend(b)
+
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	accountant, err := usage.New(&config.UsageConfig{
+		TenantHeader: "X-Tenant",
+		Exports:      []config.UsageExportConfig{{Type: "file", Path: path}},
+	}, "test", logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountant.Start()
+	recorder := NewRecorder(nil, logger.NewNop())
+	recorder.SetUsage(accountant)
+	proxy := NewProxy(lb, ratelimit.NewNoopRateLimiter(), Options{Recorder: recorder}, logger.NewNop())
+
+	req := httptest.NewRequest("POST", "/upload", strings.NewReader("0123456789"))
+	req.Header.Set("X-Tenant", "acme")
+	proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := recorder.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	accountant.Stop()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report usage.Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	want := usage.Usage{Tenant: "acme", Requests: 1, BytesIn: 10, BytesOut: 5}
+	if len(report.Tenants) != 1 || report.Tenants[0] != want {
+		t.Errorf("неверный отчет о потреблении: %+v", report.Tenants)
+	}
+}
@@ -0,0 +1,197 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+)
+
+// Параметры закрепления клиентов по умолчанию
+const (
+	defaultAffinityCookieName = "_lb_affinity"
+	defaultAffinityTTL        = 24 * time.Hour
+)
+
+// Результаты закрепления клиента за бэкендом
+const (
+	affinityPinned  = "pinned"
+	affinityMoved   = "moved"
+	affinityNew     = "new"
+	affinityInvalid = "invalid"
+)
+
+// affinityRequests счетчик запросов с закреплением клиентов по результату: pinned — запрос
+// передан бэкенду из cookie, moved — тот недоступен и клиент закреплен за другим, new —
+// клиент пришел без cookie, invalid — cookie поддельная, поврежденная или просроченная
+var affinityRequests = metrics.Default.Counter("lb_affinity_requests_total", "Requests with backend affinity by result", "result")
+
+// affinityParams параметры middleware affinity
+type affinityParams struct {
+	// Имя cookie (по умолчанию _lb_affinity)
+	CookieName string `yaml:"cookieName"`
+
+	// Ключ шифрования cookie (не короче 32 символов)
+	Secret string `yaml:"secret"`
+
+	// Время закрепления клиента (по умолчанию 24h); продлевается, пока клиент обращается к бэкенду
+	TTL time.Duration `yaml:"ttl"`
+
+	// Атрибуты cookie: Path (по умолчанию /) и Domain (по умолчанию хост запроса)
+	Path   string `yaml:"path"`
+	Domain string `yaml:"domain"`
+}
+
+// affinityCookie закрепление клиента в зашифрованной cookie
+type affinityCookie struct {
+	Backend string `json:"b"`
+	Expires int64  `json:"exp"`
+}
+
+// affinity закрепляет клиента за бэкендом, выбранным для его первого запроса
+// (sticky sessions). Бэкенд хранится в cookie, зашифрованной и подписанной AES-GCM:
+// клиент не может подставить в нее другой бэкенд или продлить закрепление.
+// Если бэкенд из cookie недоступен или не относится к маршруту запроса, балансировщик
+// выбирает другой, и клиент закрепляется за ним.
+type affinity struct {
+	params  affinityParams
+	cookies *cookieCipher
+	logger  logger.Logger
+}
+
+// newAffinityMiddleware создает middleware закрепления клиентов за бэкендами
+func newAffinityMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params affinityParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(params.Secret) < minCookieSecretLength:
+		return nil, fmt.Errorf("affinity: secret must be at least %d characters", minCookieSecretLength)
+	case params.TTL < 0:
+		return nil, fmt.Errorf("affinity: ttl must not be negative")
+	case params.Path != "" && !strings.HasPrefix(params.Path, "/"):
+		return nil, fmt.Errorf("affinity: path must start with /")
+	}
+	if params.CookieName == "" {
+		params.CookieName = defaultAffinityCookieName
+	}
+	if params.TTL == 0 {
+		params.TTL = defaultAffinityTTL
+	}
+	if params.Path == "" {
+		params.Path = "/"
+	}
+
+	cookies, err := newCookieCipher(params.Secret)
+	if err != nil {
+		return nil, err
+	}
+	a := &affinity{params: params, cookies: cookies, logger: appLogger}
+	return a.middleware, nil
+}
+
+func (a *affinity) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request.FromContext(r.Context())
+		if req == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		pinned, expires, result := a.read(r)
+		request.Set(req, request.KeyAffinity, pinned)
+
+		// Cookie закрепления нужна только прокси и не передается бэкенду
+		removeRequestCookie(r, a.params.CookieName)
+
+		tls := r.TLS != nil
+		next.ServeHTTP(&responseHeaderWriter{ResponseWriter: w, apply: func(header http.Header) {
+			selected, _ := request.Get(req, request.KeyAffinity)
+			if selected == "" {
+				return
+			}
+			if pinned != "" {
+				result = affinityPinned
+				if selected != pinned {
+					result = affinityMoved
+				}
+			}
+			affinityRequests.Inc(result)
+
+			// Закрепление продлевается, когда прошла половина его срока, чтобы не выдавать
+			// новую cookie в каждом ответе
+			if selected != pinned || time.Until(expires) < a.params.TTL/2 {
+				a.write(header, selected, tls)
+			}
+		}}, r)
+	})
+}
+
+// read возвращает бэкенд из cookie закрепления и срок закрепления. Пустой бэкенд —
+// cookie нет (new) или ей нельзя доверять (invalid).
+func (a *affinity) read(r *http.Request) (string, time.Time, string) {
+	cookie, err := r.Cookie(a.params.CookieName)
+	if err != nil {
+		return "", time.Time{}, affinityNew
+	}
+	var value affinityCookie
+	if err := a.cookies.decrypt(cookie.Value, &value); err != nil {
+		// Поддельные cookie и cookie, зашифрованные прежним ключом, учитываются в метрике
+		if log := logger.FromContext(r.Context(), a.logger); log.DebugEnabled() {
+			log.Debug(fmt.Sprintf("Отклонена cookie закрепления от %s: %v", clientIP(r), err))
+		}
+		return "", time.Time{}, affinityInvalid
+	}
+	expires := time.Unix(value.Expires, 0)
+	if value.Backend == "" || time.Now().After(expires) {
+		return "", time.Time{}, affinityInvalid
+	}
+	return value.Backend, expires, ""
+}
+
+// write добавляет в ответ cookie закрепления клиента за бэкендом id
+func (a *affinity) write(header http.Header, id string, tls bool) {
+	value, err := a.cookies.encrypt(affinityCookie{Backend: id, Expires: time.Now().Add(a.params.TTL).Unix()})
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("Не удалось зашифровать cookie закрепления: %v", err))
+		return
+	}
+	cookie := &http.Cookie{
+		Name:     a.params.CookieName,
+		Value:    value,
+		Path:     a.params.Path,
+		Domain:   a.params.Domain,
+		MaxAge:   int(a.params.TTL.Seconds()),
+		HttpOnly: true,
+		Secure:   tls,
+		SameSite: http.SameSiteLaxMode,
+	}
+	header.Add("Set-Cookie", cookie.String())
+}
+
+// removeRequestCookie удаляет cookie name из заголовков Cookie запроса
+func removeRequestCookie(r *http.Request, name string) {
+	cookies := r.Cookies()
+	kept := make([]string, 0, len(cookies))
+	removed := false
+	for _, c := range cookies {
+		if c.Name == name {
+			removed = true
+			continue
+		}
+		kept = append(kept, c.Name+"="+c.Value)
+	}
+	if !removed {
+		return
+	}
+	r.Header.Del("Cookie")
+	if len(kept) > 0 {
+		r.Header.Set("Cookie", strings.Join(kept, "; "))
+	}
+}
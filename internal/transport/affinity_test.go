@@ -0,0 +1,86 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestAffinity(t *testing.T) {
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	backends := make(map[string]backend.Backend)
+	for _, id := range []string{"b1", "b2"} {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := r.Cookie(defaultAffinityCookieName); err == nil {
+				t.Error("cookie закрепления не должна передаваться бэкенду")
+			}
+			io.WriteString(w, id)
+		}))
+		defer upstream.Close()
+		backends[id] = backend.NewBackendWithOptions(id, upstream.URL, 1, backend.Options{})
+		lb.AddBackend(backends[id])
+	}
+
+	m, err := newAffinityMiddleware(config.MiddlewareConfig{Name: "affinity", Params: map[string]interface{}{
+		"secret": "0123456789abcdef0123456789abcdef",
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := &Chain{}
+	chain.Add("affinity", PhaseRewrite, m)
+	proxy := NewProxy(lb, ratelimit.NewNoopRateLimiter(), Options{Middlewares: chain}, logger.NewNop())
+
+	send := func(cookie *http.Cookie) (string, *http.Cookie) {
+		req := httptest.NewRequest("GET", "/", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		var issued *http.Cookie
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == defaultAffinityCookieName {
+				issued = c
+			}
+		}
+		return rec.Body.String(), issued
+	}
+
+	first, cookie := send(nil)
+	if cookie == nil {
+		t.Fatal("клиент должен получить cookie закрепления")
+	}
+	for i := 0; i < 4; i++ {
+		if got, reissued := send(cookie); got != first || reissued != nil {
+			t.Fatalf("запрос с cookie должен попадать на %s без новой cookie, получен %s", first, got)
+		}
+	}
+
+	before := affinityRequests.Value(affinityInvalid)
+	send(&http.Cookie{Name: defaultAffinityCookieName, Value: "forged"})
+	if affinityRequests.Value(affinityInvalid) != before+1 {
+		t.Error("поддельная cookie должна учитываться в метрике")
+	}
+
+	// Бэкенд недоступен — клиент закрепляется за другим
+	backends[first].SetAlive(false)
+	moved, reissued := send(cookie)
+	if moved == first || reissued == nil {
+		t.Fatalf("клиент должен перейти на другой бэкенд и получить новую cookie, получен %s", moved)
+	}
+	backends[first].SetAlive(true)
+	if got, _ := send(reissued); got != moved {
+		t.Errorf("клиент должен остаться на новом бэкенде %s, получен %s", moved, got)
+	}
+}
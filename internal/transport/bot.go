@@ -0,0 +1,440 @@
+package transport
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Параметры защиты от ботов по умолчанию
+const (
+	defaultBotWindow        = time.Minute
+	defaultBotPenalty       = 5 * time.Minute
+	defaultBotMinRequests   = 20
+	defaultBotBaseDelay     = time.Second
+	defaultBotMaxDelay      = 30 * time.Second
+	defaultBotMaxTarpitted  = 100
+	defaultBotDifficulty    = 4
+	defaultBotChallengeTTL  = time.Hour
+	defaultBotChallengeName = "_lb_challenge"
+)
+
+// botActions счетчик мер, примененных к подозрительным клиентам
+var botActions = metrics.Default.Counter("lb_bot_actions_total", "Requests from abusive clients by applied action", "action")
+
+// botParams параметры middleware botProtection
+type botParams struct {
+	// Окно подсчета запросов и ошибок клиента (по умолчанию 1m)
+	Window time.Duration `yaml:"window"`
+
+	// Порог числа запросов клиента за окно (0 — не проверяется)
+	MaxRequests int `yaml:"maxRequests"`
+
+	// Порог доли ответов 4xx/5xx за окно (0 — не проверяется) и минимальное число запросов для ее оценки
+	MaxErrorRatio float64 `yaml:"maxErrorRatio"`
+	MinRequests   int     `yaml:"minRequests"`
+
+	// Регулярные выражения User-Agent подозрительных клиентов; emptyUserAgent — считать подозрительным пустой User-Agent
+	UserAgents     []string `yaml:"userAgents"`
+	EmptyUserAgent bool     `yaml:"emptyUserAgent"`
+
+	// Сколько клиент остается под подозрением после последнего нарушения (по умолчанию 5m)
+	Penalty time.Duration `yaml:"penalty"`
+
+	// Мера: tarpit (по умолчанию) — задерживать ответы, challenge — требовать решения задачи в браузере
+	Action string `yaml:"action"`
+
+	// Задержка первого ответа и ее предел; задержка удваивается с каждым запросом под подозрением
+	BaseDelay time.Duration `yaml:"baseDelay"`
+	MaxDelay  time.Duration `yaml:"maxDelay"`
+
+	// Максимум одновременно задерживаемых запросов, остальные сразу получают 429
+	MaxTarpitted int `yaml:"maxTarpitted"`
+
+	// Сложность задачи (число нулевых шестнадцатеричных цифр хеша) и срок действия решения
+	Difficulty   int           `yaml:"difficulty"`
+	ChallengeTTL time.Duration `yaml:"challengeTTL"`
+
+	// Ключ подписи cookie решения (по умолчанию случайный при запуске)
+	ChallengeSecret string `yaml:"challengeSecret"`
+}
+
+// botClient статистика клиента за текущее окно
+type botClient struct {
+	windowStart time.Time
+	requests    int
+	errors      int
+
+	// Нарушения подряд и время, до которого клиент под подозрением
+	offenses     int
+	flaggedUntil time.Time
+}
+
+// botProtection замедляет или проверяет клиентов, превысивших пороги злоупотребления,
+// вместо того чтобы сразу отвечать 429: сбор данных становится дорогим,
+// а ошибочно заподозренные пользователи продолжают получать ответы
+type botProtection struct {
+	params     botParams
+	userAgents []*regexp.Regexp
+	secret     []byte
+	logger     logger.Logger
+
+	mu        sync.Mutex
+	clients   map[string]*botClient
+	lastSweep time.Time
+
+	tarpitted atomic.Int64
+	now       func() time.Time
+	sleep     func(r *http.Request, d time.Duration) bool
+}
+
+// newBotMiddleware создает middleware защиты от ботов
+func newBotMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	b, err := newBotProtection(cfg, appLogger)
+	if err != nil {
+		return nil, err
+	}
+	return b.middleware, nil
+}
+
+func newBotProtection(cfg config.MiddlewareConfig, appLogger logger.Logger) (*botProtection, error) {
+	var params botParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	if params.Window < 0 || params.Penalty < 0 || params.BaseDelay < 0 || params.MaxDelay < 0 || params.ChallengeTTL < 0 {
+		return nil, fmt.Errorf("durations must not be negative")
+	}
+	if params.MaxRequests < 0 || params.MinRequests < 0 || params.MaxTarpitted < 0 || params.Difficulty < 0 || params.Difficulty > 8 {
+		return nil, fmt.Errorf("invalid limits")
+	}
+	if params.MaxErrorRatio < 0 || params.MaxErrorRatio > 1 {
+		return nil, fmt.Errorf("maxErrorRatio must be between 0 and 1")
+	}
+	switch params.Action {
+	case "":
+		params.Action = "tarpit"
+	case "tarpit", "challenge":
+	default:
+		return nil, fmt.Errorf("action must be tarpit or challenge")
+	}
+
+	setDefault := func(d *time.Duration, value time.Duration) {
+		if *d == 0 {
+			*d = value
+		}
+	}
+	setDefault(&params.Window, defaultBotWindow)
+	setDefault(&params.Penalty, defaultBotPenalty)
+	setDefault(&params.BaseDelay, defaultBotBaseDelay)
+	setDefault(&params.MaxDelay, defaultBotMaxDelay)
+	setDefault(&params.ChallengeTTL, defaultBotChallengeTTL)
+	if params.MinRequests == 0 {
+		params.MinRequests = defaultBotMinRequests
+	}
+	if params.MaxTarpitted == 0 {
+		params.MaxTarpitted = defaultBotMaxTarpitted
+	}
+	if params.Difficulty == 0 {
+		params.Difficulty = defaultBotDifficulty
+	}
+
+	b := &botProtection{
+		params:  params,
+		logger:  appLogger,
+		clients: make(map[string]*botClient),
+		now:     time.Now,
+		sleep:   sleepContext,
+	}
+	for _, pattern := range params.UserAgents {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid userAgents pattern %q: %w", pattern, err)
+		}
+		b.userAgents = append(b.userAgents, re)
+	}
+	if params.ChallengeSecret != "" {
+		b.secret = []byte(params.ChallengeSecret)
+	} else {
+		b.secret = make([]byte, 32)
+		rand.Read(b.secret)
+	}
+	return b, nil
+}
+
+func (b *botProtection) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+
+		if b.params.Action == "challenge" {
+			if b.solvedChallenge(r, ip) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if b.flagged(r, ip) {
+				botActions.Inc("challenge")
+				b.challenge(w, r, ip)
+				return
+			}
+			b.serve(next, w, r, ip)
+			return
+		}
+
+		if b.flagged(r, ip) && !b.tarpit(w, r, ip) {
+			return
+		}
+		b.serve(next, w, r, ip)
+	})
+}
+
+// serve передает запрос дальше и учитывает статус ответа в статистике клиента
+func (b *botProtection) serve(next http.Handler, w http.ResponseWriter, r *http.Request, ip string) {
+	sw := &statusWriter{ResponseWriter: w}
+	next.ServeHTTP(sw, r)
+	if sw.status() >= http.StatusBadRequest {
+		b.mu.Lock()
+		if c := b.clients[ip]; c != nil {
+			c.errors++
+		}
+		b.mu.Unlock()
+	}
+}
+
+// flagged учитывает запрос и проверяет, находится ли клиент под подозрением
+func (b *botProtection) flagged(r *http.Request, ip string) bool {
+	now := b.now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sweep(now)
+
+	c := b.clients[ip]
+	if c == nil {
+		c = &botClient{windowStart: now}
+		b.clients[ip] = c
+	}
+	if now.Sub(c.windowStart) >= b.params.Window {
+		c.windowStart, c.requests, c.errors = now, 0, 0
+	}
+	c.requests++
+
+	reason := b.violation(r, c)
+	if reason != "" {
+		if now.After(c.flaggedUntil) {
+			b.logger.Warn(fmt.Sprintf("Клиент %s заподозрен в злоупотреблении: %s", ip, reason))
+		}
+		c.offenses++
+		c.flaggedUntil = now.Add(b.params.Penalty)
+		return true
+	}
+	if now.Before(c.flaggedUntil) {
+		c.offenses++
+		return true
+	}
+	c.offenses = 0
+	return false
+}
+
+// violation возвращает описание нарушенного порога или пустую строку; вызывается под b.mu
+func (b *botProtection) violation(r *http.Request, c *botClient) string {
+	if b.params.MaxRequests > 0 && c.requests > b.params.MaxRequests {
+		return fmt.Sprintf("%d запросов за %v", c.requests, b.params.Window)
+	}
+	if b.params.MaxErrorRatio > 0 && c.requests >= b.params.MinRequests {
+		if ratio := float64(c.errors) / float64(c.requests); ratio > b.params.MaxErrorRatio {
+			return fmt.Sprintf("доля ошибок %.2f", ratio)
+		}
+	}
+	ua := r.UserAgent()
+	if b.params.EmptyUserAgent && ua == "" {
+		return "пустой User-Agent"
+	}
+	for _, re := range b.userAgents {
+		if re.MatchString(ua) {
+			return fmt.Sprintf("User-Agent %q", ua)
+		}
+	}
+	return ""
+}
+
+// sweep удаляет клиентов без активности, не чаще раза за окно; вызывается под b.mu
+func (b *botProtection) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < b.params.Window {
+		return
+	}
+	b.lastSweep = now
+	for ip, c := range b.clients {
+		if now.Sub(c.windowStart) >= b.params.Window && now.After(c.flaggedUntil) {
+			delete(b.clients, ip)
+		}
+	}
+}
+
+// tarpit задерживает запрос клиента под подозрением. Задержка удваивается с каждым
+// запросом до maxDelay. Возвращает false, если запрос уже завершен: клиент отключился
+// или задерживаемых запросов слишком много и клиенту отправлен 429.
+func (b *botProtection) tarpit(w http.ResponseWriter, r *http.Request, ip string) bool {
+	b.mu.Lock()
+	offenses := 1
+	if c := b.clients[ip]; c != nil {
+		offenses = c.offenses
+	}
+	b.mu.Unlock()
+
+	delay := b.params.MaxDelay
+	if offenses <= 32 {
+		if d := b.params.BaseDelay << (offenses - 1); d > 0 && d < delay {
+			delay = d
+		}
+	}
+
+	if b.tarpitted.Add(1) > int64(b.params.MaxTarpitted) {
+		b.tarpitted.Add(-1)
+		botActions.Inc("reject")
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		return false
+	}
+	defer b.tarpitted.Add(-1)
+
+	botActions.Inc("tarpit")
+	b.logger.Debug(fmt.Sprintf("Запрос клиента %s задержан на %v", ip, delay))
+	return b.sleep(r, delay)
+}
+
+// challengePage страница с задачей: браузер подбирает число, при котором SHA-256
+// от nonce и числа начинается с difficulty нулей, сохраняет решение в cookie и перезагружает страницу
+var challengePage = template.Must(template.New("challenge").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Проверка браузера</title></head>
+<body><p>Проверяем ваш браузер, это займет несколько секунд…</p>
+<script>
+(async () => {
+  const nonce = {{.Nonce}}, prefix = "0".repeat({{.Difficulty}});
+  const enc = new TextEncoder();
+  for (let i = 0; ; i++) {
+    const hash = await crypto.subtle.digest("SHA-256", enc.encode(nonce + ":" + i));
+    const hex = Array.from(new Uint8Array(hash)).map(b => b.toString(16).padStart(2, "0")).join("");
+    if (hex.startsWith(prefix)) {
+      document.cookie = {{.Cookie}} + "=" + nonce + ":" + i + "; path=/; max-age=" + {{.MaxAge}} + "; SameSite=Lax";
+      location.reload();
+      return;
+    }
+  }
+})();
+</script>
+<noscript>Для доступа к сайту включите JavaScript.</noscript>
+</body></html>
+`))
+
+// challenge отправляет клиенту страницу с задачей
+func (b *botProtection) challenge(w http.ResponseWriter, r *http.Request, ip string) {
+	expires := b.now().Add(b.params.ChallengeTTL).Unix()
+	payload := ip + "|" + strconv.FormatInt(expires, 10)
+	nonce := strconv.FormatInt(expires, 10) + "." + b.sign(payload)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	challengePage.Execute(w, map[string]interface{}{
+		"Nonce":      nonce,
+		"Difficulty": b.params.Difficulty,
+		"Cookie":     defaultBotChallengeName,
+		"MaxAge":     int(b.params.ChallengeTTL.Seconds()),
+	})
+}
+
+// solvedChallenge проверяет cookie с решением задачи: подпись привязана к IP клиента
+// и сроку действия, хеш решения должен начинаться с нужного числа нулей
+func (b *botProtection) solvedChallenge(r *http.Request, ip string) bool {
+	cookie, err := r.Cookie(defaultBotChallengeName)
+	if err != nil {
+		return false
+	}
+	nonce, counter, found := strings.Cut(cookie.Value, ":")
+	if !found {
+		return false
+	}
+	expiresStr, signature, found := strings.Cut(nonce, ".")
+	if !found {
+		return false
+	}
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || b.now().Unix() >= expires {
+		return false
+	}
+	if !hmac.Equal([]byte(signature), []byte(b.sign(ip+"|"+expiresStr))) {
+		return false
+	}
+
+	hash := sha256.Sum256([]byte(nonce + ":" + counter))
+	return strings.HasPrefix(hex.EncodeToString(hash[:]), strings.Repeat("0", b.params.Difficulty))
+}
+
+// sign возвращает HMAC подпись значения
+func (b *botProtection) sign(value string) string {
+	mac := hmac.New(sha256.New, b.secret)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// sleepContext ждет d или отмены запроса; возвращает false, если клиент отключился
+func sleepContext(r *http.Request, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// statusWriter запоминает статус и размер тела ответа
+type statusWriter struct {
+	http.ResponseWriter
+	code  int
+	bytes int64
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if w.code == 0 {
+		w.code = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap возвращает исходный ResponseWriter для http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// status возвращает статус ответа (200, если он не был записан явно)
+func (w *statusWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
@@ -0,0 +1,177 @@
+package transport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+// testBot создает защиту от ботов с фиктивными часами; задержки не выполняются, а записываются в delays
+func testBot(t *testing.T, params map[string]interface{}, now *time.Time, delays *[]time.Duration) *botProtection {
+	t.Helper()
+	b, err := newBotProtection(config.MiddlewareConfig{Name: "botProtection", Params: params}, logger.NewNop())
+	if err != nil {
+		t.Fatalf("middleware должен создаваться: %v", err)
+	}
+	b.now = func() time.Time { return *now }
+	b.sleep = func(_ *http.Request, d time.Duration) bool {
+		if delays != nil {
+			*delays = append(*delays, d)
+		}
+		return true
+	}
+	return b
+}
+
+func TestBotProtectionTarpit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var delays []time.Duration
+	b := testBot(t, map[string]interface{}{
+		"maxRequests":   3,
+		"maxErrorRatio": 0.5,
+		"minRequests":   4,
+		"userAgents":    []interface{}{`(?i)scrapy`},
+		"baseDelay":     "1s",
+		"maxDelay":      "4s",
+		"penalty":       "1m",
+	}, &now, &delays)
+	handler := b.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	send := func(addr, path, ua string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = addr
+		req.Header.Set("User-Agent", ua)
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Превышение частоты: задержка удваивается до maxDelay
+	for i := 0; i < 6; i++ {
+		send("10.0.0.1:1000", "/", "curl")
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+	if len(delays) != len(want) {
+		t.Fatalf("ожидалось %d задержанных запросов, получено %v", len(want), delays)
+	}
+	for i := range want {
+		if delays[i] != want[i] {
+			t.Errorf("задержка %d: ожидалось %v, получено %v", i, want[i], delays[i])
+		}
+	}
+
+	// Подозрительный User-Agent задерживается сразу
+	delays = nil
+	send("10.0.0.2:1000", "/", "Scrapy/2.11")
+	if len(delays) != 1 {
+		t.Errorf("запрос с подозрительным User-Agent должен задерживаться")
+	}
+
+	// Доля ошибок учитывается после minRequests запросов
+	delays = nil
+	for i := 0; i < 3; i++ {
+		send("10.0.0.3:1000", "/missing", "curl")
+	}
+	if len(delays) != 0 {
+		t.Errorf("до minRequests запросов доля ошибок не должна учитываться")
+	}
+	now = now.Add(2 * time.Minute)
+	delays = nil
+	for i := 0; i < 3; i++ {
+		send("10.0.0.3:1000", "/missing", "curl")
+	}
+	send("10.0.0.3:1000", "/", "curl")
+	if len(delays) != 1 {
+		t.Errorf("клиент с высокой долей ошибок должен задерживаться: %v", delays)
+	}
+
+	// После окончания наказания клиент обслуживается без задержек
+	now = now.Add(5 * time.Minute)
+	delays = nil
+	if send("10.0.0.1:1000", "/", "curl") != http.StatusOK || len(delays) != 0 {
+		t.Errorf("после окончания наказания запросы не должны задерживаться")
+	}
+}
+
+func TestBotProtectionTarpitLimit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var delays []time.Duration
+	b := testBot(t, map[string]interface{}{"emptyUserAgent": true, "maxTarpitted": 1}, &now, &delays)
+
+	// Первый запрос занимает единственное место, второй получает 429
+	b.tarpitted.Add(1)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	b.middleware(http.NotFoundHandler()).ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("при переполнении задерживаемых запросов ожидался 429, получен %d", rec.Code)
+	}
+}
+
+func TestBotProtectionChallenge(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	b := testBot(t, map[string]interface{}{
+		"action":          "challenge",
+		"userAgents":      []interface{}{`bot`},
+		"difficulty":      2,
+		"challengeSecret": "secret",
+	}, &now, nil)
+	handler := b.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "bot")
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "crypto.subtle") {
+		t.Fatalf("подозрительному клиенту должна отправляться страница с задачей (%d)", rec.Code)
+	}
+
+	nonce := regexp.MustCompile(`const nonce = "([^"]+)"`).FindStringSubmatch(rec.Body.String())
+	if nonce == nil {
+		t.Fatalf("страница должна содержать nonce: %s", rec.Body.String())
+	}
+	counter := 0
+	for ; ; counter++ {
+		hash := sha256.Sum256([]byte(nonce[1] + ":" + strconv.Itoa(counter)))
+		if strings.HasPrefix(hex.EncodeToString(hash[:]), "00") {
+			break
+		}
+	}
+
+	solve := func(value, addr string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = addr
+		req.Header.Set("User-Agent", "bot")
+		req.AddCookie(&http.Cookie{Name: defaultBotChallengeName, Value: value})
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	solution := nonce[1] + ":" + strconv.Itoa(counter)
+	if code := solve(solution, req.RemoteAddr); code != http.StatusOK {
+		t.Errorf("клиент с решенной задачей должен пропускаться (%d)", code)
+	}
+	if code := solve(nonce[1]+":"+strconv.Itoa(counter+1), req.RemoteAddr); code == http.StatusOK {
+		t.Errorf("неверное решение не должно приниматься")
+	}
+	if code := solve(solution, "10.9.9.9:1234"); code == http.StatusOK {
+		t.Errorf("решение привязано к IP клиента")
+	}
+	now = now.Add(2 * time.Hour)
+	if code := solve(solution, req.RemoteAddr); code == http.StatusOK {
+		t.Errorf("просроченное решение не должно приниматься")
+	}
+}
@@ -0,0 +1,501 @@
+package transport
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Параметры кэша ответов по умолчанию
+const (
+	defaultCacheMaxEntries  = 10000
+	defaultCacheMaxBodySize = 1 << 20
+)
+
+// Заголовок с результатом обращения к кэшу
+const cacheStatusHeader = "X-Cache"
+
+// cacheRequests счетчик запросов к кэшируемым маршрутам по результатам
+var cacheRequests = metrics.Default.Counter("lb_cache_requests_total", "Requests to cached routes by result", "result")
+
+// cacheParams параметры middleware cache
+type cacheParams struct {
+	// Кэшируемые маршруты; применяется первый, префиксу которого соответствует путь
+	Routes []cacheRouteParams `yaml:"routes"`
+
+	// Заголовки запроса, входящие в ключ вместе с методом, хостом, путем и параметрами
+	// (дополнительно к Accept, Accept-Encoding и Accept-Language)
+	VaryHeaders []string `yaml:"varyHeaders"`
+
+	// Максимальное число записей (по умолчанию 10000); вытесняются давно не запрошенные
+	MaxEntries int `yaml:"maxEntries"`
+
+	// Максимальный размер кэшируемого тела ответа (по умолчанию 1MB)
+	MaxBodySize int64 `yaml:"maxBodySize"`
+}
+
+// cacheRouteParams время жизни ответов маршрута
+type cacheRouteParams struct {
+	PathPrefix string `yaml:"pathPrefix"`
+
+	// Время, в течение которого ответ отдается из кэша без обращения к бэкенду
+	TTL time.Duration `yaml:"ttl"`
+
+	// Время после ttl, в течение которого отдается устаревший ответ, а кэш обновляется в фоне
+	StaleWhileRevalidate time.Duration `yaml:"staleWhileRevalidate"`
+
+	// Время после ttl, в течение которого устаревший ответ отдается вместо ошибки бэкенда
+	StaleIfError time.Duration `yaml:"staleIfError"`
+
+	// Добавлять к сохраненным ответам ETag и Last-Modified, если их не прислал бэкенд,
+	// и отвечать 304 на условные запросы без обращения к бэкенду
+	ETag bool `yaml:"etag"`
+}
+
+// cacheRoute разобранный маршрут middleware cache
+type cacheRoute struct {
+	pathPrefix           string
+	ttl                  time.Duration
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+	etag                 bool
+}
+
+// cacheEntry сохраненный ответ
+type cacheEntry struct {
+	key    string
+	status int
+	header http.Header
+	body   []byte
+	stored time.Time
+	route  *cacheRoute
+
+	// Выполняется фоновое обновление; изменяется под responseCache.mu
+	refreshing bool
+}
+
+// responseCache кэширует успешные ответы на GET запросы. Устаревший ответ отдается, пока
+// кэш обновляется в фоне (stale-while-revalidate) и пока бэкенды отвечают ошибкой или
+// недоступны (stale-if-error).
+type responseCache struct {
+	routes      []*cacheRoute
+	vary        []string
+	maxEntries  int
+	maxBodySize int64
+	logger      logger.Logger
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+
+	// Текущее время; подменяется в тестах
+	now func() time.Time
+}
+
+// newCacheMiddleware создает middleware кэширования ответов
+func newCacheMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params cacheParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	c, err := newResponseCache(params, appLogger)
+	if err != nil {
+		return nil, err
+	}
+	return c.middleware, nil
+}
+
+// newResponseCache проверяет параметры маршрутов
+func newResponseCache(params cacheParams, appLogger logger.Logger) (*responseCache, error) {
+	if len(params.Routes) == 0 {
+		return nil, fmt.Errorf("cache: at least one route is required")
+	}
+	if params.MaxEntries < 0 {
+		return nil, fmt.Errorf("cache: maxEntries must not be negative")
+	}
+	if params.MaxBodySize < 0 {
+		return nil, fmt.Errorf("cache: maxBodySize must not be negative")
+	}
+
+	c := &responseCache{
+		vary:        varyHeaders(params.VaryHeaders),
+		maxEntries:  params.MaxEntries,
+		maxBodySize: params.MaxBodySize,
+		logger:      appLogger,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		now:         time.Now,
+	}
+	if c.maxEntries == 0 {
+		c.maxEntries = defaultCacheMaxEntries
+	}
+	if c.maxBodySize == 0 {
+		c.maxBodySize = defaultCacheMaxBodySize
+	}
+
+	for i, rp := range params.Routes {
+		if rp.TTL <= 0 {
+			return nil, fmt.Errorf("cache: routes[%d].ttl must be positive", i)
+		}
+		if rp.StaleWhileRevalidate < 0 || rp.StaleIfError < 0 {
+			return nil, fmt.Errorf("cache: routes[%d]: stale windows must not be negative", i)
+		}
+		c.routes = append(c.routes, &cacheRoute{
+			pathPrefix:           rp.PathPrefix,
+			ttl:                  rp.TTL,
+			staleWhileRevalidate: rp.StaleWhileRevalidate,
+			staleIfError:         rp.StaleIfError,
+			etag:                 rp.ETag,
+		})
+	}
+	return c, nil
+}
+
+// match возвращает маршрут запроса или nil, если запрос не кэшируется
+func (c *responseCache) match(r *http.Request) *cacheRoute {
+	if r.Method != http.MethodGet {
+		return nil
+	}
+	for _, route := range c.routes {
+		if strings.HasPrefix(r.URL.Path, route.pathPrefix) {
+			return route
+		}
+	}
+	return nil
+}
+
+// get возвращает запись по ключу
+func (c *responseCache) get(key string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*cacheEntry)
+}
+
+// store сохраняет ответ, вытесняя давно не запрошенные записи
+func (c *responseCache) store(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[entry.key]; ok {
+		c.lru.Remove(elem)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// startRefresh отмечает начало фонового обновления записи. Возвращает false,
+// если обновление уже выполняется.
+func (c *responseCache) startRefresh(entry *cacheEntry) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry.refreshing {
+		return false
+	}
+	entry.refreshing = true
+	return true
+}
+
+// finishRefresh снимает отметку фонового обновления
+func (c *responseCache) finishRefresh(entry *cacheEntry) {
+	c.mu.Lock()
+	entry.refreshing = false
+	c.mu.Unlock()
+}
+
+// newEntry создает запись. На маршрутах с etag ответ без ETag получает сильный ETag по
+// хешу тела, а ответ без Last-Modified — время сохранения.
+func (c *responseCache) newEntry(key string, route *cacheRoute, status int, header http.Header, body []byte) *cacheEntry {
+	entry := &cacheEntry{key: key, status: status, header: header, body: body, stored: c.now(), route: route}
+	if route.etag {
+		if header.Get("ETag") == "" {
+			sum := sha256.Sum256(body)
+			header.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+		}
+		if header.Get("Last-Modified") == "" {
+			header.Set("Last-Modified", entry.stored.UTC().Format(http.TimeFormat))
+		}
+	}
+	return entry
+}
+
+// notModified сообщает, что у клиента уже есть сохраненный ответ: ETag входит в If-None-Match
+// (слабое сравнение) или, если If-None-Match нет, ответ не изменялся после If-Modified-Since
+func notModified(r *http.Request, entry *cacheEntry) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		etag := strings.TrimPrefix(entry.header.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(entry.header.Get("Last-Modified"))
+	return err == nil && !modified.After(since)
+}
+
+// cacheable сообщает, можно ли сохранить ответ
+func cacheable(status int, header http.Header) bool {
+	if status != http.StatusOK || header.Get("Set-Cookie") != "" {
+		return false
+	}
+	cc := header.Get("Cache-Control")
+	return !strings.Contains(cc, "no-store") && !strings.Contains(cc, "private")
+}
+
+func (c *responseCache) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := c.match(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key, ok := varyKey(r, c.vary)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		entry := c.get(key)
+		if entry == nil {
+			cacheRequests.Inc("miss")
+			c.fetch(key, route, next, w, r)
+			return
+		}
+
+		age := c.now().Sub(entry.stored)
+		switch {
+		case age < entry.route.ttl:
+			cacheRequests.Inc("hit")
+			c.serve(w, r, entry, "HIT")
+		case age < entry.route.ttl+entry.route.staleWhileRevalidate:
+			cacheRequests.Inc("stale")
+			if c.startRefresh(entry) {
+				// Запрос выполняется без отмены вместе с запросом клиента и без его записи
+				// журнала доступа, которая будет обработана раньше
+				ctx := context.WithValue(context.WithoutCancel(r.Context()), recordKey{}, (*accessRecord)(nil))
+				go c.refresh(entry, route, next, r.Clone(ctx))
+			}
+			c.serve(w, r, entry, "STALE")
+		case age < entry.route.ttl+entry.route.staleIfError:
+			c.revalidate(entry, route, next, w, r)
+		default:
+			cacheRequests.Inc("miss")
+			c.fetch(key, route, next, w, r)
+		}
+	})
+}
+
+// serve отдает сохраненный ответ или, на маршрутах с etag, 304 на условный запрос
+func (c *responseCache) serve(w http.ResponseWriter, r *http.Request, entry *cacheEntry, status string) {
+	// Значения копируются: middleware, выполняющиеся после кэша, могут изменять заголовки ответа
+	for name, values := range entry.header {
+		w.Header()[name] = slices.Clone(values)
+	}
+	age := int(c.now().Sub(entry.stored).Seconds())
+	w.Header().Set("Age", strconv.Itoa(age))
+	w.Header().Set(cacheStatusHeader, status)
+
+	if entry.route.etag && notModified(r, entry) {
+		cacheRequests.Inc("notModified")
+		for _, name := range []string{"Content-Type", "Content-Length", "Content-Encoding"} {
+			w.Header().Del(name)
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(entry.status)
+	w.Write(entry.body)
+}
+
+// fetch передает запрос бэкенду, отдает ответ клиенту по мере получения и сохраняет его.
+// На маршрутах с etag ответ буферизуется: ETag вычисляется по всему телу.
+func (c *responseCache) fetch(key string, route *cacheRoute, next http.Handler, w http.ResponseWriter, r *http.Request) {
+	if route.etag {
+		resp := newCacheRecorder(c.maxBodySize, w)
+		next.ServeHTTP(resp, r)
+		if !resp.streamed {
+			c.respond(key, route, resp, w, r)
+		}
+		return
+	}
+
+	w.Header().Set(cacheStatusHeader, "MISS")
+	cw := &coalesceWriter{ResponseWriter: w, limit: c.maxBodySize}
+	next.ServeHTTP(cw, r)
+
+	status := cw.status()
+	if r.Context().Err() != nil || cw.overflow || !cacheable(status, w.Header()) {
+		return
+	}
+	header := w.Header().Clone()
+	header.Del(cacheStatusHeader)
+	c.store(c.newEntry(key, route, status, header, cw.body.Bytes()))
+}
+
+// revalidate запрашивает бэкенд, пока ответ еще можно отдать вместо ошибки: ответ бэкенда
+// буферизуется, и при ошибке 5xx клиент получает сохраненный ответ
+func (c *responseCache) revalidate(entry *cacheEntry, route *cacheRoute, next http.Handler, w http.ResponseWriter, r *http.Request) {
+	resp := newCacheRecorder(c.maxBodySize, w)
+	resp.staleIfError = true
+	next.ServeHTTP(resp, r)
+
+	if resp.status() >= http.StatusInternalServerError {
+		cacheRequests.Inc("staleIfError")
+		if c.logger.DebugEnabled() {
+			c.logger.Debug(fmt.Sprintf("Запрос %s %s получил сохраненный ответ вместо ошибки бэкенда %d", r.Method, r.URL.Path, resp.status()))
+		}
+		c.serve(w, r, entry, "STALE")
+		return
+	}
+
+	cacheRequests.Inc("miss")
+	if !resp.streamed {
+		c.respond(entry.key, route, resp, w, r)
+	}
+}
+
+// respond сохраняет буферизованный ответ бэкенда, если его можно кэшировать, и отдает клиенту
+func (c *responseCache) respond(key string, route *cacheRoute, resp *cacheRecorder, w http.ResponseWriter, r *http.Request) {
+	if entry := c.storeRecorded(key, route, resp); entry != nil {
+		c.serve(w, r, entry, "MISS")
+		return
+	}
+	for name, values := range resp.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(cacheStatusHeader, "MISS")
+	w.WriteHeader(resp.status())
+	w.Write(resp.body.Bytes())
+}
+
+// refresh обновляет запись в фоне
+func (c *responseCache) refresh(entry *cacheEntry, route *cacheRoute, next http.Handler, r *http.Request) {
+	defer c.finishRefresh(entry)
+
+	resp := newCacheRecorder(c.maxBodySize, nil)
+	next.ServeHTTP(resp, r)
+	if c.storeRecorded(entry.key, route, resp) == nil && c.logger.DebugEnabled() {
+		c.logger.Debug(fmt.Sprintf("Фоновое обновление %s %s не изменило кэш, статус ответа %d", r.Method, r.URL.Path, resp.status()))
+	}
+}
+
+// storeRecorded сохраняет буферизованный ответ, если его можно кэшировать, и возвращает запись
+func (c *responseCache) storeRecorded(key string, route *cacheRoute, resp *cacheRecorder) *cacheEntry {
+	if resp.overflow || !cacheable(resp.status(), resp.header) {
+		return nil
+	}
+	entry := c.newEntry(key, route, resp.status(), resp.header, resp.body.Bytes())
+	c.store(entry)
+	return entry
+}
+
+// cacheRecorder буферизует ответ бэкенда не больше limit байт. Ответ с телом больше
+// лимита не кэшируется: накопленная часть и остаток тела передаются клиенту без
+// буферизации, а без клиента (фоновое обновление) остаток отбрасывается.
+type cacheRecorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+	limit  int64
+
+	// Клиент, получающий ответ с телом больше лимита; nil — тело отбрасывается
+	client http.ResponseWriter
+
+	// Ответ 5xx с телом больше лимита не передается клиенту, а отбрасывается:
+	// клиент получит сохраненный ответ
+	staleIfError bool
+
+	// Тело превысило лимит; streamed — и ответ передается клиенту
+	overflow bool
+	streamed bool
+}
+
+func newCacheRecorder(limit int64, client http.ResponseWriter) *cacheRecorder {
+	return &cacheRecorder{header: make(http.Header), limit: limit, client: client}
+}
+
+func (w *cacheRecorder) Header() http.Header {
+	if w.streamed {
+		return w.client.Header()
+	}
+	return w.header
+}
+
+func (w *cacheRecorder) WriteHeader(statusCode int) {
+	if w.code == 0 {
+		w.code = statusCode
+	}
+}
+
+func (w *cacheRecorder) Write(p []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if w.streamed {
+		return w.client.Write(p)
+	}
+	if w.overflow {
+		return len(p), nil
+	}
+	if int64(w.body.Len()+len(p)) <= w.limit {
+		return w.body.Write(p)
+	}
+
+	w.overflow = true
+	if w.client == nil || (w.staleIfError && w.code >= http.StatusInternalServerError) {
+		w.body = bytes.Buffer{}
+		return len(p), nil
+	}
+	for name, values := range w.header {
+		w.client.Header()[name] = values
+	}
+	w.client.Header().Set(cacheStatusHeader, "MISS")
+	w.client.WriteHeader(w.code)
+	w.streamed = true
+	if _, err := w.client.Write(w.body.Bytes()); err != nil {
+		return 0, err
+	}
+	w.body = bytes.Buffer{}
+	return w.client.Write(p)
+}
+
+// status возвращает статус ответа
+func (w *cacheRecorder) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
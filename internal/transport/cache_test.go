@@ -0,0 +1,194 @@
+package transport
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestResponseCache(t *testing.T) {
+	c, err := newResponseCache(cacheParams{Routes: []cacheRouteParams{
+		{PathPrefix: "/catalog", TTL: time.Minute, StaleWhileRevalidate: time.Minute, StaleIfError: time.Hour},
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	var version, failing atomic.Int32
+	refreshed := make(chan struct{}, 1)
+	handler := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() == 1 {
+			http.Error(w, "No available backends", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, "v%d", version.Add(1))
+		select {
+		case refreshed <- struct{}{}:
+		default:
+		}
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/catalog", nil))
+		return rec
+	}
+	expect := func(step, body, status string) {
+		t.Helper()
+		rec := serve()
+		if rec.Body.String() != body || rec.Header().Get(cacheStatusHeader) != status {
+			t.Errorf("%s: ожидался ответ %q (%s), получен %q (%s)", step, body, status, rec.Body.String(), rec.Header().Get(cacheStatusHeader))
+		}
+	}
+
+	expect("первый запрос", "v1", "MISS")
+	<-refreshed
+	expect("свежий ответ", "v1", "HIT")
+
+	// После ttl отдается устаревший ответ, а кэш обновляется в фоне
+	now = now.Add(90 * time.Second)
+	expect("stale-while-revalidate", "v1", "STALE")
+	<-refreshed
+	for deadline := time.Now().Add(time.Second); serve().Body.String() != "v2"; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("кэш должен обновиться в фоне")
+		}
+	}
+
+	// Пока бэкенды недоступны, отдается устаревший ответ
+	failing.Store(1)
+	now = now.Add(10 * time.Minute)
+	expect("stale-if-error", "v2", "STALE")
+
+	// После окончания окна stale-if-error клиент получает ошибку
+	now = now.Add(2 * time.Hour)
+	if rec := serve(); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("после окна stale-if-error ожидался 503, получен %d", rec.Code)
+	}
+
+	// Ответ бэкенда заменяет устаревший, когда бэкенд снова доступен
+	failing.Store(0)
+	expect("восстановление", "v3", "MISS")
+	<-refreshed
+	expect("новый ответ", "v3", "HIT")
+}
+
+func TestResponseCache_Conditional(t *testing.T) {
+	c, err := newResponseCache(cacheParams{Routes: []cacheRouteParams{
+		{PathPrefix: "/catalog", TTL: time.Minute, ETag: true},
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls atomic.Int32
+	handler := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("items"))
+	}))
+	serve := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/catalog", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Уже первый ответ получает ETag и Last-Modified
+	first := serve(nil)
+	etag, lastModified := first.Header().Get("ETag"), first.Header().Get("Last-Modified")
+	if first.Body.String() != "items" || !strings.HasPrefix(etag, `"`) || lastModified == "" {
+		t.Fatalf("ответ должен содержать ETag и Last-Modified: %q, %v", first.Body.String(), first.Header())
+	}
+
+	if rec := serve(map[string]string{"If-None-Match": `"other", ` + etag}); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("запрос с совпадающим If-None-Match должен получить 304, получен %d", rec.Code)
+	}
+	if rec := serve(map[string]string{"If-Modified-Since": lastModified}); rec.Code != http.StatusNotModified {
+		t.Errorf("запрос с If-Modified-Since должен получить 304, получен %d", rec.Code)
+	}
+	if rec := serve(map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}); rec.Code != http.StatusOK || rec.Body.String() != "items" {
+		t.Errorf("If-None-Match важнее If-Modified-Since: ожидался 200, получен %d", rec.Code)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("условные запросы не должны передаваться бэкенду, выполнено %d", calls.Load())
+	}
+}
+
+func TestResponseCache_LargeBody(t *testing.T) {
+	c, err := newResponseCache(cacheParams{MaxBodySize: 16, Routes: []cacheRouteParams{
+		{PathPrefix: "/", TTL: time.Minute, StaleIfError: time.Hour, ETag: true},
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	var size, status atomic.Int32
+	size.Store(3)
+	status.Store(http.StatusOK)
+	handler := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Tags", "catalog")
+		w.WriteHeader(int(status.Load()))
+		for i := 0; i < int(size.Load()); i++ {
+			io.WriteString(w, "0123456789")
+		}
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/catalog", nil))
+		return rec
+	}
+
+	// Ответ больше maxBodySize передается клиенту целиком и не кэшируется
+	for i := 0; i < 2; i++ {
+		if rec := serve(); rec.Body.Len() != 30 || rec.Header().Get(cacheStatusHeader) != "MISS" || rec.Header().Get("X-Tags") != "catalog" {
+			t.Errorf("большой ответ должен передаваться клиенту без кэширования: %d байт, %s", rec.Body.Len(), rec.Header().Get(cacheStatusHeader))
+		}
+	}
+
+	// Буфер не растет больше лимита, остаток тела без клиента отбрасывается
+	recorder := newCacheRecorder(16, nil)
+	for i := 0; i < 3; i++ {
+		recorder.Write([]byte("0123456789"))
+	}
+	if !recorder.overflow || recorder.body.Len() > 16 {
+		t.Errorf("буфер ответа должен ограничиваться лимитом: %d байт", recorder.body.Len())
+	}
+
+	// Изменение заголовков ответа из кэша не затрагивает сохраненную запись
+	size.Store(1)
+	serve()
+	rec := serve()
+	if rec.Header().Get(cacheStatusHeader) != "HIT" {
+		t.Fatalf("небольшой ответ должен кэшироваться, получен %s", rec.Header().Get(cacheStatusHeader))
+	}
+	rec.Header()["X-Tags"][0] = "changed"
+	if rec := serve(); rec.Header().Get("X-Tags") != "catalog" {
+		t.Errorf("заголовки сохраненного ответа не должны изменяться: %q", rec.Header().Get("X-Tags"))
+	}
+
+	// При обновлении большой ответ с ошибкой заменяется сохраненным, а большой успешный передается клиенту
+	now = now.Add(2 * time.Minute)
+	size.Store(3)
+	status.Store(http.StatusBadGateway)
+	if rec := serve(); rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Errorf("вместо большой ошибки ожидался сохраненный ответ: %d %q", rec.Code, rec.Body.String())
+	}
+	status.Store(http.StatusOK)
+	if rec := serve(); rec.Body.Len() != 30 || rec.Header().Get(cacheStatusHeader) != "MISS" {
+		t.Errorf("большой ответ при обновлении должен передаваться клиенту: %d байт", rec.Body.Len())
+	}
+}
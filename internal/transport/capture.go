@@ -0,0 +1,314 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/capture"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Параметры записи запросов по умолчанию
+const (
+	defaultCaptureMaxBodySize = 64 << 10
+	defaultCaptureMaxFileSize = 100 << 20
+	defaultCaptureQueueSize   = 1024
+	captureRedactedValue      = "REDACTED"
+)
+
+// captureAlwaysScrubbed заголовки с учетными данными, которые не записываются никогда
+var captureAlwaysScrubbed = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// captureRecords счетчик записанных и отброшенных из-за переполнения очереди запросов
+var captureRecords = metrics.Default.Counter("lb_capture_records_total", "Captured requests by result", "result")
+
+// captureParams параметры middleware capture
+type captureParams struct {
+	// Каталог файлов записи
+	Dir string `yaml:"dir"`
+
+	// Доля записываемых запросов в процентах (по умолчанию 100)
+	Percentage *float64 `yaml:"percentage"`
+
+	// Префиксы путей и методы записываемых запросов (по умолчанию все)
+	PathPrefixes []string `yaml:"pathPrefixes"`
+	Methods      []string `yaml:"methods"`
+
+	// Сколько байт тела запроса записывать (по умолчанию 64KB)
+	MaxBodySize int64 `yaml:"maxBodySize"`
+
+	// Размер файла, после которого начинается новый (по умолчанию 100MB)
+	MaxFileSize int64 `yaml:"maxFileSize"`
+
+	// Правила удаления персональных данных
+	Scrub captureScrubParams `yaml:"scrub"`
+}
+
+// captureScrubParams правила удаления персональных данных из записи. Значения
+// заменяются на REDACTED, чтобы запрос сохранял форму и оставался воспроизводимым.
+type captureScrubParams struct {
+	// Заголовки (Authorization, Proxy-Authorization и Cookie скрываются всегда)
+	Headers []string `yaml:"headers"`
+
+	// Параметры query
+	QueryParams []string `yaml:"queryParams"`
+
+	// Поля JSON тела (JSONPath, например $..email)
+	JSONFields []string `yaml:"jsonFields"`
+
+	// Регулярные выражения, совпадения с которыми заменяются в теле и query
+	Patterns []string `yaml:"patterns"`
+}
+
+// capturer записывает выборку запросов для отладки и регрессионного тестирования
+type capturer struct {
+	percentage   float64
+	pathPrefixes []string
+	methods      map[string]bool
+	maxBodySize  int64
+
+	headers     []string
+	queryParams []string
+	jsonFields  []*jsonPath
+	patterns    []*regexp.Regexp
+
+	queue  *captureQueue
+	logger logger.Logger
+}
+
+// newCaptureMiddleware создает middleware записи запросов
+func newCaptureMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params captureParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	c, err := newCapturer(params, appLogger)
+	if err != nil {
+		return nil, err
+	}
+	return c.middleware, nil
+}
+
+// newCapturer проверяет параметры и подключает очередь записи в каталог
+func newCapturer(params captureParams, appLogger logger.Logger) (*capturer, error) {
+	if params.Dir == "" {
+		return nil, fmt.Errorf("capture: dir is required")
+	}
+	percentage, err := faultPercentage(params.Percentage)
+	if err != nil {
+		return nil, fmt.Errorf("capture: %w", err)
+	}
+	if params.MaxBodySize < 0 || params.MaxFileSize < 0 {
+		return nil, fmt.Errorf("capture: sizes must not be negative")
+	}
+
+	c := &capturer{
+		percentage:   percentage,
+		pathPrefixes: params.PathPrefixes,
+		methods:      make(map[string]bool),
+		maxBodySize:  params.MaxBodySize,
+		headers:      append(append([]string(nil), captureAlwaysScrubbed...), params.Scrub.Headers...),
+		queryParams:  params.Scrub.QueryParams,
+		logger:       appLogger,
+	}
+	if c.maxBodySize == 0 {
+		c.maxBodySize = defaultCaptureMaxBodySize
+	}
+	for _, method := range params.Methods {
+		c.methods[strings.ToUpper(method)] = true
+	}
+	for _, expr := range params.Scrub.JSONFields {
+		path, err := parseJSONPath(expr)
+		if err != nil {
+			return nil, fmt.Errorf("capture: scrub.jsonFields: %w", err)
+		}
+		c.jsonFields = append(c.jsonFields, path)
+	}
+	for _, expr := range params.Scrub.Patterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("capture: scrub.patterns: %w", err)
+		}
+		c.patterns = append(c.patterns, re)
+	}
+
+	maxFileSize := params.MaxFileSize
+	if maxFileSize == 0 {
+		maxFileSize = defaultCaptureMaxFileSize
+	}
+	if c.queue, err = sharedCaptureQueue(params.Dir, maxFileSize, appLogger); err != nil {
+		return nil, fmt.Errorf("capture: %w", err)
+	}
+	return c, nil
+}
+
+// matches проверяет, записывается ли запрос
+func (c *capturer) matches(r *http.Request) bool {
+	if len(c.methods) > 0 && !c.methods[r.Method] {
+		return false
+	}
+	if len(c.pathPrefixes) > 0 {
+		matched := false
+		for _, prefix := range c.pathPrefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return c.percentage >= 100 || rand.Float64()*100 < c.percentage
+}
+
+func (c *capturer) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.matches(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &capture.Record{
+			Time:   time.Now(),
+			Method: r.Method,
+			Host:   r.Host,
+			Path:   r.URL.Path,
+			Query:  r.URL.RawQuery,
+			Header: r.Header.Clone(),
+		}
+
+		// Записывается начало тела, а бэкенд получает тело целиком
+		var body []byte
+		if r.Body != nil && r.Body != http.NoBody {
+			var err error
+			body, err = io.ReadAll(io.LimitReader(r.Body, c.maxBodySize+1))
+			if err != nil {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			if int64(len(body)) > c.maxBodySize {
+				body = body[:c.maxBodySize]
+				rec.BodyTruncated = true
+			}
+		}
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		rec.Status = sw.status()
+		rec.DurationMs = float64(time.Since(rec.Time).Microseconds()) / 1000
+		c.scrub(rec, body)
+		c.queue.push(rec)
+	})
+}
+
+// scrub удаляет из записи персональные данные по правилам и сохраняет тело
+func (c *capturer) scrub(rec *capture.Record, body []byte) {
+	for _, name := range c.headers {
+		if len(rec.Header.Values(name)) > 0 {
+			rec.Header.Set(name, captureRedactedValue)
+		}
+	}
+
+	if len(c.queryParams) > 0 && rec.Query != "" {
+		if query, err := url.ParseQuery(rec.Query); err == nil {
+			changed := false
+			for _, name := range c.queryParams {
+				if _, ok := query[name]; ok {
+					query.Set(name, captureRedactedValue)
+					changed = true
+				}
+			}
+			if changed {
+				rec.Query = query.Encode()
+			}
+		}
+	}
+
+	if len(c.jsonFields) > 0 && !rec.BodyTruncated && len(body) > 0 {
+		var doc interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if decoder.Decode(&doc) == nil {
+			for _, path := range c.jsonFields {
+				for _, field := range path.fields(doc) {
+					field.object[field.key] = captureRedactedValue
+				}
+			}
+			if scrubbed, err := json.Marshal(doc); err == nil {
+				body = scrubbed
+			}
+		}
+	}
+
+	for _, re := range c.patterns {
+		body = re.ReplaceAll(body, []byte(captureRedactedValue))
+		rec.Query = re.ReplaceAllString(rec.Query, captureRedactedValue)
+	}
+	rec.SetBody(body)
+}
+
+// captureQueue очередь записи в каталог. Запись на диск выполняется в отдельной горутине,
+// чтобы не задерживать ответы; при переполнении очереди записи отбрасываются.
+// Очередь общая для всех middleware с тем же каталогом и переживает реконфигурацию.
+type captureQueue struct {
+	records chan *capture.Record
+	writer  *capture.Writer
+}
+
+var (
+	captureQueuesMu sync.Mutex
+	captureQueues   = make(map[string]*captureQueue)
+)
+
+// sharedCaptureQueue возвращает очередь записи в каталог dir, создавая ее при первом обращении
+func sharedCaptureQueue(dir string, maxFileSize int64, appLogger logger.Logger) (*captureQueue, error) {
+	captureQueuesMu.Lock()
+	defer captureQueuesMu.Unlock()
+
+	if q := captureQueues[dir]; q != nil {
+		q.writer.SetMaxFileSize(maxFileSize)
+		return q, nil
+	}
+
+	writer, err := capture.NewWriter(dir, maxFileSize)
+	if err != nil {
+		return nil, err
+	}
+	q := &captureQueue{records: make(chan *capture.Record, defaultCaptureQueueSize), writer: writer}
+	go func() {
+		for rec := range q.records {
+			if err := writer.Write(rec); err != nil {
+				appLogger.Error(fmt.Sprintf("Ошибка записи запроса в %s: %v", dir, err))
+			}
+		}
+	}()
+	captureQueues[dir] = q
+	return q, nil
+}
+
+// push ставит запись в очередь
+func (q *captureQueue) push(rec *capture.Record) {
+	select {
+	case q.records <- rec:
+		captureRecords.Inc("written")
+	default:
+		captureRecords.Inc("dropped")
+	}
+}
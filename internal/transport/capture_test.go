@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.ru_test/pkg/capture"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestCapturer(t *testing.T) {
+	dir := t.TempDir()
+	c, err := newCapturer(captureParams{
+		Dir:          dir,
+		PathPrefixes: []string{"/api"},
+		MaxBodySize:  1024,
+		Scrub: captureScrubParams{
+			Headers:     []string{"X-Api-Key"},
+			QueryParams: []string{"token"},
+			JSONFields:  []string{"$..email"},
+			Patterns:    []string{`\d{4}-\d{4}-\d{4}-\d{4}`},
+		},
+	}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var backendBody string
+	handler := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		backendBody = string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	body := `{"user":{"email":"a@example.com","card":"1111-2222-3333-4444"}}`
+	req := httptest.NewRequest("POST", "/api/users?token=secret&page=1", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Api-Key", "secret")
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if backendBody != body {
+		t.Errorf("бэкенд должен получить исходное тело, получено %q", backendBody)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+
+	// Запись на диск асинхронная
+	var records []*capture.Record
+	for deadline := time.Now().Add(2 * time.Second); len(records) == 0 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		files, _ := capture.Files(dir)
+		for _, name := range files {
+			file, err := os.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			capture.Read(file, func(rec *capture.Record) error {
+				records = append(records, rec)
+				return nil
+			})
+			file.Close()
+		}
+	}
+	if len(records) != 1 {
+		t.Fatalf("должен быть записан только запрос с префиксом /api, записей: %d", len(records))
+	}
+
+	rec := records[0]
+	if rec.Status != http.StatusCreated || rec.Method != "POST" || rec.Path != "/api/users" {
+		t.Errorf("неверная запись запроса: %+v", rec)
+	}
+	if rec.Header.Get("Authorization") != captureRedactedValue || rec.Header.Get("X-Api-Key") != captureRedactedValue {
+		t.Errorf("заголовки с учетными данными должны скрываться: %v", rec.Header)
+	}
+	if rec.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("остальные заголовки должны сохраняться: %v", rec.Header)
+	}
+	if rec.Query != "page=1&token="+captureRedactedValue {
+		t.Errorf("параметр token должен скрываться: %s", rec.Query)
+	}
+	if strings.Contains(rec.Body, "a@example.com") || strings.Contains(rec.Body, "1111-2222") {
+		t.Errorf("персональные данные должны удаляться из тела: %s", rec.Body)
+	}
+	if !strings.Contains(rec.Body, `"email":"`+captureRedactedValue+`"`) {
+		t.Errorf("поле email должно заменяться на %s: %s", captureRedactedValue, rec.Body)
+	}
+}
@@ -0,0 +1,113 @@
+package transport
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/request"
+)
+
+// defaultClientCertHeader заголовок со сведениями о клиентском сертификате по умолчанию
+const defaultClientCertHeader = "X-Forwarded-Client-Cert"
+
+// defaultClientCertFields поля заголовка по умолчанию: сертификат целиком не передается,
+// чтобы не увеличивать каждый запрос на несколько килобайт
+var defaultClientCertFields = []string{
+	config.ClientCertFieldHash, config.ClientCertFieldSubject, config.ClientCertFieldURI, config.ClientCertFieldDNS,
+}
+
+// clientCertForwarding передает бэкендам сведения о проверенном клиентском сертификате
+// в формате заголовка X-Forwarded-Client-Cert Envoy: Hash=...;Subject="...";URI=...;DNS=...
+// Без передачи (fields пуст) заголовок только очищается от значений, подставленных клиентами.
+type clientCertForwarding struct {
+	header string
+	fields []string
+}
+
+// newClientCertForwarding создает передачу сведений о сертификате; для nil настроек
+// заголовок по умолчанию только очищается
+func newClientCertForwarding(cfg *config.ClientCertForwardingConfig) *clientCertForwarding {
+	if cfg == nil {
+		return &clientCertForwarding{header: defaultClientCertHeader}
+	}
+	f := &clientCertForwarding{header: http.CanonicalHeaderKey(cfg.Header), fields: cfg.Fields}
+	if f.header == "" {
+		f.header = defaultClientCertHeader
+	}
+	if len(f.fields) == 0 {
+		f.fields = defaultClientCertFields
+	}
+	return f
+}
+
+// apply заменяет заголовок запроса сведениями о сертификате клиента. Значение, полученное
+// от доверенного прокси, сохраняется, и сведения о сертификате добавляются к нему через
+// запятую; значение от остальных отправителей мог подставить клиент, поэтому оно отбрасывается.
+// Без проверенного сертификата или без передачи сведения не добавляются.
+func (f *clientCertForwarding) apply(r *http.Request, trusted request.TrustedProxies) {
+	var prior []string
+	if trusted.Contains(net.ParseIP(request.TrustedProxies(nil).ClientIP(r))) {
+		prior = r.Header.Values(f.header)
+	}
+	r.Header.Del(f.header)
+
+	if len(f.fields) > 0 && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.PeerCertificates) > 0 {
+		prior = append(prior, f.element(r.TLS.PeerCertificates))
+	}
+	if len(prior) > 0 {
+		r.Header.Set(f.header, strings.Join(prior, ","))
+	}
+}
+
+// element собирает сведения о сертификате клиента; certs — сертификат клиента
+// и промежуточные сертификаты, которые он предъявил
+func (f *clientCertForwarding) element(certs []*x509.Certificate) string {
+	cert := certs[0]
+	var params []string
+	for _, field := range f.fields {
+		switch field {
+		case config.ClientCertFieldHash:
+			sum := sha256.Sum256(cert.Raw)
+			params = append(params, "Hash="+hex.EncodeToString(sum[:]))
+		case config.ClientCertFieldSubject:
+			params = append(params, "Subject="+quoteCertValue(cert.Subject.String()))
+		case config.ClientCertFieldURI:
+			for _, uri := range cert.URIs {
+				params = append(params, "URI="+quoteCertValue(uri.String()))
+			}
+		case config.ClientCertFieldDNS:
+			for _, name := range cert.DNSNames {
+				params = append(params, "DNS="+quoteCertValue(name))
+			}
+		case config.ClientCertFieldCert:
+			params = append(params, "Cert="+quoteCertValue(encodeCertPEM(cert)))
+		case config.ClientCertFieldChain:
+			var chain strings.Builder
+			for _, c := range certs {
+				chain.WriteString(encodeCertPEM(c))
+			}
+			params = append(params, "Chain="+quoteCertValue(chain.String()))
+		}
+	}
+	return strings.Join(params, ";")
+}
+
+// encodeCertPEM возвращает сертификат в PEM, закодированный для URL
+func encodeCertPEM(cert *x509.Certificate) string {
+	return url.PathEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+}
+
+// quoteCertValue заключает значение в кавычки, если в нем есть разделители заголовка
+func quoteCertValue(value string) string {
+	if !strings.ContainsAny(value, `,;="\ `) {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
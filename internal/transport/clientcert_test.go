@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+)
+
+func TestClientCertForwarding(t *testing.T) {
+	trusted, err := request.ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spiffe, _ := url.Parse("spiffe://cluster.local/ns/billing/sa/api")
+	cert := &x509.Certificate{
+		Raw:      []byte("der"),
+		Subject:  pkix.Name{CommonName: "billing", Organization: []string{"Example, Inc"}},
+		URIs:     []*url.URL{spiffe},
+		DNSNames: []string{"billing.internal"},
+	}
+	sum := sha256.Sum256(cert.Raw)
+	element := `Hash=` + hex.EncodeToString(sum[:]) + `;Subject="CN=billing,O=Example\\, Inc";URI=spiffe://cluster.local/ns/billing/sa/api;DNS=billing.internal`
+
+	f := newClientCertForwarding(&config.ClientCertForwardingConfig{})
+	incoming := func(remote string, verified bool) string {
+		r := httptest.NewRequest("GET", "https://shop.example/", nil)
+		r.RemoteAddr = remote
+		r.Header.Set("X-Forwarded-Client-Cert", "Hash=forged")
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		if verified {
+			r.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+		}
+		f.apply(r, trusted)
+		return r.Header.Get("X-Forwarded-Client-Cert")
+	}
+
+	if got := incoming("198.51.100.1:4000", true); got != element {
+		t.Errorf("заголовок клиента должен заменяться сведениями о сертификате:\n%s\nожидалось:\n%s", got, element)
+	}
+	if got := incoming("10.1.2.3:4000", true); got != "Hash=forged,"+element {
+		t.Errorf("сведения от доверенного прокси должны дополняться: %s", got)
+	}
+	if got := incoming("198.51.100.1:4000", false); got != "" {
+		t.Errorf("без проверенного сертификата заголовок не передается: %s", got)
+	}
+}
+
+func TestServer_StripsClientCertHeader(t *testing.T) {
+	var received []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("X-Forwarded-Client-Cert"))
+	}))
+	defer upstream.Close()
+
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lb.AddBackend(backend.NewBackendWithOptions("b1", upstream.URL, 1, backend.Options{}))
+	trusted, err := request.ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Listener без TLS и без forwardClientCert
+	s := NewServer(logger.NewNop())
+	s.SetProxy(NewProxy(lb, ratelimit.NewNoopRateLimiter(), Options{TrustedProxies: trusted}, logger.NewNop()))
+	for _, remote := range []string{"198.51.100.1:4000", "10.1.2.3:4000"} {
+		req := httptest.NewRequest("GET", "http://shop.example/", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-Client-Cert", "Hash=forged")
+		s.serveHTTP(httptest.NewRecorder(), req)
+	}
+	if len(received) != 2 || received[0] != "" || received[1] != "Hash=forged" {
+		t.Errorf("заголовок клиента должен отбрасываться, а от доверенного прокси — сохраняться: %q", received)
+	}
+}
@@ -0,0 +1,268 @@
+package transport
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// defaultCoalesceMaxBodySize размер тела ответа, который можно разделить между запросами, по умолчанию
+const defaultCoalesceMaxBodySize = 1 << 20
+
+// coalesceDefaultVary заголовки, от которых ответ зависит всегда
+var coalesceDefaultVary = []string{"Accept", "Accept-Encoding", "Accept-Language"}
+
+// coalesceCredentials заголовки с учетными данными: запросы с ними объединяются,
+// только если заголовок входит в ключ
+var coalesceCredentials = []string{"Authorization", "Cookie"}
+
+// coalescedRequests счетчик запросов, получивших ответ другого запроса или
+// выполненных самостоятельно после неудачи объединения
+var coalescedRequests = metrics.Default.Counter("lb_coalesced_requests_total", "Requests that waited for an identical in-flight request by result", "result")
+
+// coalesceParams параметры middleware coalesce
+type coalesceParams struct {
+	// Префиксы путей идемпотентных маршрутов, запросы к которым объединяются
+	PathPrefixes []string `yaml:"pathPrefixes"`
+
+	// Заголовки запроса, входящие в ключ вместе с методом, хостом, путем и параметрами
+	// (дополнительно к Accept, Accept-Encoding и Accept-Language)
+	VaryHeaders []string `yaml:"varyHeaders"`
+
+	// Максимальный размер тела ответа, передаваемого ожидающим запросам (по умолчанию 1MB)
+	MaxBodySize int64 `yaml:"maxBodySize"`
+}
+
+// coalescedResponse ответ запроса, выполняемого для всех одинаковых запросов
+type coalescedResponse struct {
+	done chan struct{}
+
+	// Число ожидающих запросов; изменяется под coalescer.mu
+	waiters int
+
+	// Заполняются до закрытия done; ok — ответ можно отдать ожидающим
+	ok     bool
+	status int
+	header http.Header
+	body   []byte
+}
+
+// coalescer объединяет одновременные одинаковые GET запросы в один запрос к бэкенду:
+// пока он выполняется, такие же запросы ждут его ответа, а не создают нагрузку на бэкенд
+type coalescer struct {
+	pathPrefixes []string
+	vary         []string
+	maxBodySize  int64
+	logger       logger.Logger
+
+	mu       sync.Mutex
+	inFlight map[string]*coalescedResponse
+}
+
+// newCoalesceMiddleware создает middleware объединения запросов
+func newCoalesceMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params coalesceParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	c, err := newCoalescer(params, appLogger)
+	if err != nil {
+		return nil, err
+	}
+	return c.middleware, nil
+}
+
+// newCoalescer проверяет параметры
+func newCoalescer(params coalesceParams, appLogger logger.Logger) (*coalescer, error) {
+	if len(params.PathPrefixes) == 0 {
+		return nil, fmt.Errorf("coalesce: at least one path prefix is required")
+	}
+	if params.MaxBodySize < 0 {
+		return nil, fmt.Errorf("coalesce: maxBodySize must not be negative")
+	}
+
+	c := &coalescer{
+		pathPrefixes: params.PathPrefixes,
+		maxBodySize:  params.MaxBodySize,
+		logger:       appLogger,
+		inFlight:     make(map[string]*coalescedResponse),
+	}
+	if c.maxBodySize == 0 {
+		c.maxBodySize = defaultCoalesceMaxBodySize
+	}
+	c.vary = varyHeaders(params.VaryHeaders)
+	return c, nil
+}
+
+// varyHeaders возвращает заголовки, входящие в ключ запроса: заголовки по умолчанию и extra
+func varyHeaders(extra []string) []string {
+	vary := slices.Clone(coalesceDefaultVary)
+	for _, name := range extra {
+		vary = append(vary, http.CanonicalHeaderKey(name))
+	}
+	return vary
+}
+
+// key возвращает ключ запроса или false, если запрос нельзя объединять с другими
+func (c *coalescer) key(r *http.Request) (string, bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return "", false
+	}
+	matched := false
+	for _, prefix := range c.pathPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return "", false
+	}
+	return varyKey(r, c.vary)
+}
+
+// varyKey возвращает ключ запроса из метода, хоста, пути, query и значений заголовков vary
+// или false, если запрос содержит учетные данные, не входящие в ключ
+func varyKey(r *http.Request, vary []string) (string, bool) {
+	for _, name := range coalesceCredentials {
+		if r.Header.Get(name) != "" && !slices.Contains(vary, name) {
+			return "", false
+		}
+	}
+	var key strings.Builder
+	key.WriteString(r.Method + " " + r.Host + r.URL.RequestURI())
+	for _, name := range vary {
+		// Значения разделяются символом, недопустимым в заголовках
+		key.WriteString("\n" + name + ":" + strings.Join(r.Header.Values(name), "\x00"))
+	}
+	return key.String(), true
+}
+
+func (c *coalescer) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := c.key(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		c.mu.Lock()
+		shared, waiting := c.inFlight[key]
+		if !waiting {
+			shared = &coalescedResponse{done: make(chan struct{})}
+			c.inFlight[key] = shared
+		} else {
+			shared.waiters++
+		}
+		c.mu.Unlock()
+
+		if waiting {
+			c.wait(shared, next, w, r)
+			return
+		}
+		c.lead(key, shared, next, w, r)
+	})
+}
+
+// lead выполняет запрос, отдавая ответ клиенту и запоминая его для ожидающих запросов
+func (c *coalescer) lead(key string, shared *coalescedResponse, next http.Handler, w http.ResponseWriter, r *http.Request) {
+	cw := &coalesceWriter{ResponseWriter: w, limit: c.maxBodySize}
+	completed := false
+	defer func() {
+		c.mu.Lock()
+		delete(c.inFlight, key)
+		c.mu.Unlock()
+
+		// Ответ не передается ожидающим, если обработка прервана, тело слишком велико или
+		// ответ предназначен только этому клиенту
+		header := w.Header()
+		shared.ok = completed && r.Context().Err() == nil && !cw.overflow && header.Get("Set-Cookie") == "" &&
+			!strings.Contains(header.Get("Cache-Control"), "private")
+		if shared.ok {
+			shared.status = cw.status()
+			shared.header = header.Clone()
+			shared.body = cw.body.Bytes()
+		}
+		close(shared.done)
+	}()
+	next.ServeHTTP(cw, r)
+	completed = true
+}
+
+// wait ожидает ответа одинакового запроса. Если ответ нельзя разделить, запрос выполняется сам.
+func (c *coalescer) wait(shared *coalescedResponse, next http.Handler, w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-shared.done:
+	case <-r.Context().Done():
+		return
+	}
+
+	if !shared.ok {
+		coalescedRequests.Inc("fallback")
+		next.ServeHTTP(w, r)
+		return
+	}
+	coalescedRequests.Inc("shared")
+	if c.logger.DebugEnabled() {
+		c.logger.Debug(fmt.Sprintf("Запрос %s %s получил ответ одновременного одинакового запроса", r.Method, r.URL.Path))
+	}
+
+	for name, values := range shared.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(shared.status)
+	if r.Method != http.MethodHead {
+		w.Write(shared.body)
+	}
+}
+
+// coalesceWriter передает ответ клиенту и сохраняет копию тела до limit байт
+type coalesceWriter struct {
+	http.ResponseWriter
+	code     int
+	limit    int64
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *coalesceWriter) WriteHeader(statusCode int) {
+	if w.code == 0 {
+		w.code = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *coalesceWriter) Write(p []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if !w.overflow {
+		if int64(w.body.Len()+len(p)) > w.limit {
+			w.overflow = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(p)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// status возвращает отправленный статус ответа
+func (w *coalesceWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
+
+// Unwrap возвращает исходный ResponseWriter для http.ResponseController
+func (w *coalesceWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,106 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestCoalescer(t *testing.T) {
+	c, err := newCoalescer(coalesceParams{PathPrefixes: []string{"/catalog"}, MaxBodySize: 16}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls atomic.Int32
+	var release chan struct{}
+	handler := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Header().Set("X-Backend", "b1")
+		if r.URL.Path == "/catalog/big" {
+			io.WriteString(w, "body larger than sixteen bytes")
+			return
+		}
+		io.WriteString(w, "items")
+	}))
+
+	// serve выполняет n одинаковых запросов и отпускает бэкенд, когда все, кроме первого, ожидают его ответа
+	serve := func(path string, n int) []*httptest.ResponseRecorder {
+		calls.Store(0)
+		release = make(chan struct{})
+		recs := make([]*httptest.ResponseRecorder, n)
+		var wg sync.WaitGroup
+		for i := range recs {
+			recs[i] = httptest.NewRecorder()
+			wg.Add(1)
+			go func(rec *httptest.ResponseRecorder) {
+				defer wg.Done()
+				handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+			}(recs[i])
+		}
+		waitWaiters(t, c, "GET example.com"+path+"\n", n-1)
+		close(release)
+		wg.Wait()
+		return recs
+	}
+
+	// Одновременные одинаковые запросы получают ответ одного запроса к бэкенду
+	for _, rec := range serve("/catalog?page=1", 3) {
+		if rec.Code != http.StatusOK || rec.Body.String() != "items" || rec.Header().Get("X-Backend") != "b1" {
+			t.Errorf("ожидающий запрос должен получить ответ бэкенда: %d %q", rec.Code, rec.Body.String())
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("ожидался один запрос к бэкенду, выполнено %d", calls.Load())
+	}
+
+	// Слишком большой ответ не разделяется: ожидающие запросы выполняются сами
+	for _, rec := range serve("/catalog/big", 2) {
+		if rec.Body.String() != "body larger than sixteen bytes" {
+			t.Errorf("каждый запрос должен получить полный ответ: %q", rec.Body.String())
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("ожидалось два запроса к бэкенду, выполнено %d", calls.Load())
+	}
+
+	// Запросы с учетными данными, кроме входящих в ключ, и запросы вне маршрутов не объединяются
+	req := httptest.NewRequest("GET", "/catalog", nil)
+	req.Header.Set("Authorization", "Bearer x")
+	if _, ok := c.key(req); ok {
+		t.Error("запрос с Authorization не должен объединяться, если заголовок не входит в ключ")
+	}
+	if _, ok := c.key(httptest.NewRequest("POST", "/catalog", nil)); ok {
+		t.Error("POST запросы не должны объединяться")
+	}
+	if _, ok := c.key(httptest.NewRequest("GET", "/cart", nil)); ok {
+		t.Error("запросы вне маршрутов не должны объединяться")
+	}
+}
+
+// waitWaiters ожидает, пока у запроса с ключом, начинающимся с prefix, появится n ожидающих
+func waitWaiters(t *testing.T, c *coalescer, prefix string, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		waiters := -1
+		c.mu.Lock()
+		for key, shared := range c.inFlight {
+			if strings.HasPrefix(key, prefix) {
+				waiters = shared.waiters
+			}
+		}
+		c.mu.Unlock()
+		if waiters == n {
+			return
+		}
+	}
+	t.Fatalf("у запроса %s не появилось %d ожидающих", prefix, n)
+}
@@ -0,0 +1,148 @@
+package transport
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+)
+
+// Причины отказа в соединении
+const (
+	connRejectMax   = "max"
+	connRejectPerIP = "perIP"
+)
+
+// rejectResponse ответ на соединение сверх лимита на listener'е без TLS
+const rejectResponse = "HTTP/1.1 503 Service Unavailable\r\nConnection: close\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Length: 20\r\nRetry-After: 1\r\n\r\nToo many connections"
+
+// rejectWriteTimeout время на отправку ответа отклоненному соединению
+const rejectWriteTimeout = 100 * time.Millisecond
+
+// connectionsRejected счетчик соединений, отклоненных из-за лимитов, по причинам
+var connectionsRejected = metrics.Default.Counter("lb_connections_rejected_total", "Connections rejected by listener connection limits by reason", "reason")
+
+// SetConnectionLimits задает лимиты открытых соединений (nil — без ограничений).
+// Может вызываться во время работы: лимиты применяются к новым соединениям.
+func (s *Server) SetConnectionLimits(cfg *config.ConnectionLimitsConfig) {
+	var max, perIP int64
+	if cfg != nil {
+		max, perIP = int64(cfg.MaxConnections), int64(cfg.MaxConnectionsPerIP)
+	}
+	s.conns.max.Store(max)
+	s.conns.perIP.Store(perIP)
+}
+
+// connLimiter считает открытые соединения listener'а, всего и по IP адресам клиентов
+type connLimiter struct {
+	max   atomic.Int64
+	perIP atomic.Int64
+
+	open atomic.Int64
+
+	mu   sync.Mutex
+	byIP map[string]int64
+}
+
+// acquire учитывает новое соединение с адреса ip и возвращает причину отказа
+// или пустую строку, если соединение принято
+func (l *connLimiter) acquire(ip string) string {
+	open := l.open.Add(1)
+	if max := l.max.Load(); max > 0 && open > max {
+		l.open.Add(-1)
+		return connRejectMax
+	}
+
+	perIP := l.perIP.Load()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if perIP > 0 && ip != "" && l.byIP[ip] >= perIP {
+		l.open.Add(-1)
+		return connRejectPerIP
+	}
+	if l.byIP == nil {
+		l.byIP = make(map[string]int64)
+	}
+	l.byIP[ip]++
+	return ""
+}
+
+// release учитывает закрытие соединения с адреса ip
+func (l *connLimiter) release(ip string) {
+	l.open.Add(-1)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.byIP[ip]--; l.byIP[ip] <= 0 {
+		delete(l.byIP, ip)
+	}
+}
+
+// limitListener отклоняет соединения сверх лимитов. На listener'е без TLS клиент получает
+// 503, на TLS listener'е соединение сбрасывается (RST) еще до рукопожатия.
+type limitListener struct {
+	net.Listener
+	limiter *connLimiter
+	tls     bool
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		ip := connIP(conn)
+		reason := l.limiter.acquire(ip)
+		if reason == "" {
+			return &limitedConn{Conn: conn, limiter: l.limiter, ip: ip}, nil
+		}
+		connectionsRejected.Inc(reason)
+		l.reject(conn)
+	}
+}
+
+// reject закрывает соединение сверх лимита, не задерживая прием следующих
+func (l *limitListener) reject(conn net.Conn) {
+	if !l.tls {
+		conn.SetWriteDeadline(time.Now().Add(rejectWriteTimeout))
+		conn.Write([]byte(rejectResponse))
+	} else if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// connIP возвращает IP адрес отправителя соединения; у unix сокетов адреса нет
+func connIP(conn net.Conn) string {
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return ""
+	}
+	return addr.IP.String()
+}
+
+// limitedConn освобождает место в лимитах при закрытии соединения
+type limitedConn struct {
+	net.Conn
+	limiter *connLimiter
+	ip      string
+	once    sync.Once
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() { c.limiter.release(c.ip) })
+	return err
+}
+
+// CloseWrite закрывает запись в TCP соединение, оставляя его открытым для чтения
+func (c *limitedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
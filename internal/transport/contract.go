@@ -0,0 +1,236 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/filter"
+	"cloud.ru_test/pkg/logger"
+)
+
+// maxLoggedViolations число несоответствий схеме в одной записи лога
+const maxLoggedViolations = 5
+
+// contractViolations счетчик ответов бэкендов, нарушающих спецификацию, по операции и виду
+// нарушения: operation — запрос не описан, status — статус не описан, contentType — тип
+// содержимого не описан, body — тело не соответствует схеме
+var contractViolations = metrics.Default.Counter("lb_contract_violations_total", "Backend responses violating the OpenAPI contract by operation and kind", "operation", "kind")
+
+// contractFieldsStripped счетчик полей, удаленных из ответов в режиме strip
+var contractFieldsStripped = metrics.Default.Counter("lb_contract_fields_stripped_total", "Response fields removed for violating the OpenAPI contract", "operation")
+
+// responseValidationParams параметры middleware responseValidation
+type responseValidationParams struct {
+	// Путь к спецификации OpenAPI 3 в YAML или JSON
+	Spec string `yaml:"spec"`
+
+	// report — только метрики и лог (по умолчанию), strip — удалять из ответа поля,
+	// нарушающие схему
+	Mode string `yaml:"mode"`
+
+	// Префикс пути, под которым опубликованы пути спецификации
+	BasePath string `yaml:"basePath"`
+
+	// Максимальный размер проверяемого тела ответа (по умолчанию 1MB)
+	MaxBodySize int64 `yaml:"maxBodySize"`
+}
+
+// responseValidator сверяет ответы бэкендов со спецификацией OpenAPI. Нарушения
+// не ломают клиентов: в режиме report ответ передается без изменений, в режиме strip
+// из него удаляются только поля с неверными значениями и неописанные поля.
+type responseValidator struct {
+	spec        *openAPISpec
+	strip       bool
+	basePath    string
+	maxBodySize int64
+	logger      logger.Logger
+}
+
+// newResponseValidationMiddleware создает middleware проверки ответов по спецификации
+func newResponseValidationMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params responseValidationParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	v, err := newResponseValidator(params, appLogger)
+	if err != nil {
+		return nil, err
+	}
+	return v.middleware, nil
+}
+
+// newResponseValidator проверяет параметры и загружает спецификацию
+func newResponseValidator(params responseValidationParams, appLogger logger.Logger) (*responseValidator, error) {
+	if params.Spec == "" {
+		return nil, fmt.Errorf("responseValidation: spec is required")
+	}
+	if params.Mode != "" && params.Mode != "report" && params.Mode != "strip" {
+		return nil, fmt.Errorf("responseValidation: unknown mode %q (expected report or strip)", params.Mode)
+	}
+	if params.MaxBodySize < 0 {
+		return nil, fmt.Errorf("responseValidation: maxBodySize must not be negative")
+	}
+	spec, err := loadOpenAPISpec(params.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("responseValidation: %w", err)
+	}
+
+	v := &responseValidator{
+		spec:        spec,
+		strip:       params.Mode == "strip",
+		basePath:    strings.TrimSuffix(params.BasePath, "/"),
+		maxBodySize: params.MaxBodySize,
+		logger:      appLogger,
+	}
+	if v.maxBodySize == 0 {
+		v.maxBodySize = defaultMaxFilterBodySize
+	}
+	return v, nil
+}
+
+func (v *responseValidator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, ok := strings.CutPrefix(r.URL.Path, v.basePath)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		op := v.spec.match(r.Method, path)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			v.report(r, "unknown", "operation", "operation is not declared")
+			return
+		}
+
+		if !v.strip {
+			// Ответ передается клиенту сразу, проверяется его копия
+			cw := &coalesceWriter{ResponseWriter: w, limit: v.maxBodySize}
+			next.ServeHTTP(cw, r)
+			if cw.overflow {
+				v.logger.Debug(fmt.Sprintf("Ответ на запрос %s %s больше %d байт и не проверен по спецификации", r.Method, r.URL.Path, v.maxBodySize))
+				return
+			}
+			v.check(r, op, cw.status(), w.Header(), cw.body.Bytes())
+			return
+		}
+
+		rec := &filterResponseWriter{ResponseWriter: w, header: make(http.Header), limit: v.maxBodySize}
+		next.ServeHTTP(rec, r)
+		if rec.overflow {
+			v.logger.Debug(fmt.Sprintf("Ответ на запрос %s %s больше %d байт и передан без проверки по спецификации", r.Method, r.URL.Path, v.maxBodySize))
+			return
+		}
+
+		resp := &filter.Response{StatusCode: rec.status(), Header: rec.header, Body: rec.body.Bytes()}
+		doc, violations := v.check(r, op, resp.StatusCode, resp.Header, resp.Body)
+		if n := stripViolations(doc, violations); n > 0 {
+			contractFieldsStripped.Add(int64(n), op.name)
+			var buf bytes.Buffer
+			encoder := json.NewEncoder(&buf)
+			encoder.SetEscapeHTML(false)
+			if err := encoder.Encode(doc); err == nil {
+				resp.Body = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+				resp.Header.Del("ETag")
+			}
+		}
+		writeFilterResponse(w, resp)
+	})
+}
+
+// check сверяет ответ с описанием операции и сообщает о нарушениях. Возвращает разобранное
+// JSON тело и несоответствия схеме.
+func (v *responseValidator) check(r *http.Request, op *openAPIOperation, status int, header http.Header, body []byte) (interface{}, []contractViolation) {
+	resp := op.response(status)
+	if resp == nil {
+		v.report(r, op.name, "status", fmt.Sprintf("status %d is not declared", status))
+		return nil, nil
+	}
+	// Тело не описано или его нет по протоколу
+	if len(resp.content) == 0 || r.Method == http.MethodHead || status == http.StatusNoContent || status == http.StatusNotModified {
+		return nil, nil
+	}
+
+	contentType := header.Get("Content-Type")
+	schema, found := resp.schema(contentType)
+	if !found {
+		v.report(r, op.name, "contentType", fmt.Sprintf("content type %q is not declared for status %d", contentType, status))
+		return nil, nil
+	}
+	if schema == nil || !isJSON(contentType) {
+		return nil, nil
+	}
+	if encoding := header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		v.logger.Debug(fmt.Sprintf("Сжатый ответ (%s) на запрос %s %s не проверен по спецификации", encoding, r.Method, r.URL.Path))
+		return nil, nil
+	}
+
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		v.report(r, op.name, "body", fmt.Sprintf("invalid JSON: %v", err))
+		return nil, nil
+	}
+
+	var violations []contractViolation
+	schema.validate(doc, nil, &violations)
+	if len(violations) > 0 {
+		details := make([]string, 0, maxLoggedViolations)
+		for i, violation := range violations {
+			if i == maxLoggedViolations {
+				details = append(details, fmt.Sprintf("и еще %d", len(violations)-i))
+				break
+			}
+			details = append(details, violation.location()+": "+violation.message)
+		}
+		v.report(r, op.name, "body", strings.Join(details, "; "))
+	}
+	return doc, violations
+}
+
+// report учитывает нарушение контракта в метриках и логе
+func (v *responseValidator) report(r *http.Request, operation, kind, details string) {
+	contractViolations.Inc(operation, kind)
+	logger.FromContext(r.Context(), v.logger).Warn(fmt.Sprintf("Ответ на запрос %s %s (%s) нарушает спецификацию: %s", r.Method, r.URL.Path, operation, details))
+}
+
+// stripViolations удаляет из документа поля объектов, значения которых нарушают схему,
+// и возвращает число удаленных полей
+func stripViolations(doc interface{}, violations []contractViolation) int {
+	n := 0
+	for _, violation := range violations {
+		if !violation.strippable {
+			continue
+		}
+		key, ok := violation.path[len(violation.path)-1].(string)
+		if !ok {
+			continue
+		}
+		parent := doc
+		for _, step := range violation.path[:len(violation.path)-1] {
+			switch step := step.(type) {
+			case string:
+				m, _ := parent.(map[string]interface{})
+				parent = m[step]
+			case int:
+				if items, ok := parent.([]interface{}); ok && step < len(items) {
+					parent = items[step]
+				} else {
+					parent = nil
+				}
+			}
+		}
+		if object, ok := parent.(map[string]interface{}); ok {
+			if _, exists := object[key]; exists {
+				delete(object, key)
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,136 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cloud.ru_test/pkg/logger"
+)
+
+const testOpenAPISpec = `
+openapi: 3.0.3
+paths:
+  /pets/{id}:
+    get:
+      operationId: getPet
+      responses:
+        200:
+          description: pet
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pet'
+        404:
+          description: not found
+  /pets/mine:
+    get:
+      operationId: getMyPet
+      responses:
+        default:
+          description: pet
+components:
+  schemas:
+    Pet:
+      type: object
+      additionalProperties: false
+      required: [id, name]
+      properties:
+        id: {type: integer, minimum: 1}
+        name: {type: string, minLength: 1}
+        tag: {type: string, enum: [cat, dog], nullable: true}
+        born: {type: string, format: date-time}
+        parent: {$ref: '#/components/schemas/Pet'}
+`
+
+func newTestResponseValidator(t *testing.T, mode string) *responseValidator {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "openapi.yaml")
+	if err := os.WriteFile(path, []byte(testOpenAPISpec), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	v, err := newResponseValidator(responseValidationParams{Spec: path, Mode: mode, BasePath: "/api/"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestOpenAPISpec_Match(t *testing.T) {
+	v := newTestResponseValidator(t, "")
+	if op := v.spec.match("GET", "/pets/mine"); op == nil || op.name != "getMyPet" {
+		t.Errorf("путь без параметров должен иметь приоритет над шаблоном: %+v", op)
+	}
+	if op := v.spec.match("GET", "/pets/7"); op == nil || op.name != "getPet" {
+		t.Errorf("путь должен соответствовать шаблону /pets/{id}: %+v", op)
+	}
+	if op := v.spec.match("POST", "/pets/7"); op != nil {
+		t.Errorf("метод не описан в спецификации: %+v", op)
+	}
+	op := v.spec.match("GET", "/pets/7")
+	if op.response(200) == nil || op.response(404) == nil || op.response(500) != nil {
+		t.Error("неверный выбор описания ответа по статусу")
+	}
+}
+
+func TestResponseValidation_Report(t *testing.T) {
+	v := newTestResponseValidator(t, "report")
+	body := `{"id":"7","name":"rex","tag":"fish","extra":true,"parent":{"id":0,"name":"max"}}`
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/pets/8" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		io.WriteString(w, body)
+	})
+
+	before := contractViolations.Value("getPet", "body")
+	rec := httptest.NewRecorder()
+	v.middleware(backend).ServeHTTP(rec, httptest.NewRequest("GET", "/api/pets/7", nil))
+	if rec.Body.String() != body {
+		t.Errorf("в режиме report ответ не должен изменяться: %s", rec.Body.String())
+	}
+	if contractViolations.Value("getPet", "body") != before+1 {
+		t.Error("нарушение схемы должно учитываться в метрике")
+	}
+
+	op := v.spec.match("GET", "/pets/7")
+	_, violations := v.check(httptest.NewRequest("GET", "/api/pets/7", nil), op, 200, rec.Header(), []byte(body))
+	want := map[string]bool{"$.extra": true, "$.id": true, "$.parent.id": true, "$.tag": true}
+	if len(violations) != len(want) {
+		t.Errorf("ожидалось %d нарушений, получено %+v", len(want), violations)
+	}
+	for _, violation := range violations {
+		if !want[violation.location()] {
+			t.Errorf("неожиданное нарушение %s: %s", violation.location(), violation.message)
+		}
+	}
+
+	before = contractViolations.Value("getPet", "status")
+	v.middleware(backend).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/pets/8", nil))
+	if contractViolations.Value("getPet", "status") != before+1 {
+		t.Error("неописанный статус должен учитываться в метрике")
+	}
+}
+
+func TestResponseValidation_Strip(t *testing.T) {
+	v := newTestResponseValidator(t, "strip")
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, `{"id":7,"name":"rex","tag":null,"born":"yesterday","internal":"<x>","parent":{"id":1,"name":""}}`)
+	})
+
+	rec := httptest.NewRecorder()
+	v.middleware(backend).ServeHTTP(rec, httptest.NewRequest("GET", "/api/pets/7", nil))
+	want := `{"id":7,"name":"rex","parent":{"id":1},"tag":null}`
+	if rec.Body.String() != want {
+		t.Errorf("неверный ответ после удаления полей:\n%s\nожидалось:\n%s", rec.Body.String(), want)
+	}
+	if rec.Header().Get("ETag") != "" || rec.Header().Get("Content-Length") != "50" {
+		t.Errorf("заголовки измененного ответа не обновлены: %v", rec.Header())
+	}
+}
@@ -0,0 +1,199 @@
+package transport
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+// minCookieSecretLength минимальная длина ключа шифрования cookie
+const minCookieSecretLength = 32
+
+// cookieCipher шифрует значения cookie прокси: клиент не может ни прочитать,
+// ни подделать их, не зная ключа
+type cookieCipher struct {
+	aead cipher.AEAD
+}
+
+// newCookieCipher создает шифр с ключом AES-256, полученным из secret
+func newCookieCipher(secret string) (*cookieCipher, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &cookieCipher{aead: aead}, nil
+}
+
+// encrypt шифрует значение cookie: base64(nonce || AES-GCM(json))
+func (c *cookieCipher) encrypt(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(c.aead.Seal(nonce, nonce, data, nil)), nil
+}
+
+// decrypt расшифровывает и проверяет значение cookie
+func (c *cookieCipher) decrypt(value string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return err
+	}
+	if len(data) < c.aead.NonceSize() {
+		return errors.New("cookie is too short")
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plain, v)
+}
+
+// removeValue значение domain, удаляющее атрибут из cookie
+const removeValue = "-"
+
+// sameSiteModes значения атрибута SameSite
+var sameSiteModes = map[string]http.SameSite{
+	"strict": http.SameSiteStrictMode,
+	"lax":    http.SameSiteLaxMode,
+	"none":   http.SameSiteNoneMode,
+}
+
+// cookieRewriteParams параметры middleware cookieRewrite
+type cookieRewriteParams struct {
+	// Правила; к cookie применяются все подходящие правила по порядку
+	Rules []cookieRewriteRule `yaml:"rules"`
+}
+
+// cookieRewriteRule изменение атрибутов cookie из Set-Cookie ответов бэкендов
+type cookieRewriteRule struct {
+	// Имена cookie, к которым применяется правило (по умолчанию все)
+	Names []string `yaml:"names"`
+
+	// Новый Domain; "-" — атрибут удаляется, cookie достается только хосту ответа
+	Domain string `yaml:"domain"`
+
+	// Новый Path
+	Path string `yaml:"path"`
+
+	Secure   *bool `yaml:"secure"`
+	HTTPOnly *bool `yaml:"httpOnly"`
+
+	// Strict, Lax или None (None включает Secure: без него браузеры отвергают cookie)
+	SameSite string `yaml:"sameSite"`
+}
+
+// matches проверяет, что правило применяется к cookie name
+func (rule *cookieRewriteRule) matches(name string) bool {
+	if len(rule.Names) == 0 {
+		return true
+	}
+	for _, n := range rule.Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// rewrite изменяет атрибуты cookie по правилу
+func (rule *cookieRewriteRule) rewrite(c *http.Cookie) {
+	switch rule.Domain {
+	case "":
+	case removeValue:
+		c.Domain = ""
+	default:
+		c.Domain = rule.Domain
+	}
+	if rule.Path != "" {
+		c.Path = rule.Path
+	}
+	if rule.Secure != nil {
+		c.Secure = *rule.Secure
+	}
+	if rule.HTTPOnly != nil {
+		c.HttpOnly = *rule.HTTPOnly
+	}
+	if rule.SameSite != "" {
+		c.SameSite = sameSiteModes[strings.ToLower(rule.SameSite)]
+	}
+	if c.SameSite == http.SameSiteNoneMode {
+		c.Secure = true
+	}
+}
+
+// cookieRewriter изменяет атрибуты cookie, которые устанавливают бэкенды: например, домен
+// и путь бэкенда, опубликованного под другим хостом или префиксом, или Secure и SameSite
+// для приложений, не знающих, что клиенты обращаются к ним по HTTPS
+type cookieRewriter struct {
+	rules []cookieRewriteRule
+}
+
+// newCookieRewriteMiddleware создает middleware изменения атрибутов cookie
+func newCookieRewriteMiddleware(cfg config.MiddlewareConfig, _ logger.Logger) (Middleware, error) {
+	var params cookieRewriteParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	if len(params.Rules) == 0 {
+		return nil, fmt.Errorf("cookieRewrite: at least one rule is required")
+	}
+	for i, rule := range params.Rules {
+		if rule.SameSite != "" && sameSiteModes[strings.ToLower(rule.SameSite)] == 0 {
+			return nil, fmt.Errorf("cookieRewrite: rules[%d].sameSite must be Strict, Lax or None, got %q", i, rule.SameSite)
+		}
+		if rule.Path != "" && !strings.HasPrefix(rule.Path, "/") {
+			return nil, fmt.Errorf("cookieRewrite: rules[%d].path must start with /", i)
+		}
+	}
+	c := &cookieRewriter{rules: params.Rules}
+	return c.middleware, nil
+}
+
+func (c *cookieRewriter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&responseHeaderWriter{ResponseWriter: w, apply: c.apply}, r)
+	})
+}
+
+// apply изменяет заголовки Set-Cookie ответа. Cookie, к которым не подошло ни одно
+// правило, и заголовки, которые не удалось разобрать, передаются без изменений.
+func (c *cookieRewriter) apply(header http.Header) {
+	values := header["Set-Cookie"]
+	for i, value := range values {
+		cookie, err := http.ParseSetCookie(value)
+		if err != nil {
+			continue
+		}
+		matched := false
+		for j := range c.rules {
+			if c.rules[j].matches(cookie.Name) {
+				c.rules[j].rewrite(cookie)
+				matched = true
+			}
+		}
+		if matched {
+			values[i] = cookie.String()
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.ru_test/config"
+)
+
+func TestCookieRewrite(t *testing.T) {
+	secure := true
+	m, err := newCookieRewriteMiddleware(config.MiddlewareConfig{Name: "cookieRewrite", Params: map[string]interface{}{}}, nil)
+	if err == nil || m != nil {
+		t.Fatal("middleware без правил должен отклоняться")
+	}
+	c := &cookieRewriter{rules: []cookieRewriteRule{
+		{Domain: "-", Secure: &secure},
+		{Names: []string{"sid"}, Path: "/app/", SameSite: "None"},
+	}}
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "sid=abc; Domain=backend.internal; Path=/; HttpOnly")
+		w.Header().Add("Set-Cookie", "theme=dark; Domain=backend.internal")
+		w.Header().Add("Set-Cookie", "=broken")
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	c.middleware(backend).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	want := []string{
+		"sid=abc; Path=/app/; HttpOnly; Secure; SameSite=None",
+		"theme=dark; Secure",
+		"=broken",
+	}
+	got := rec.Header().Values("Set-Cookie")
+	if len(got) != len(want) {
+		t.Fatalf("ожидалось %d заголовков Set-Cookie, получено %q", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Set-Cookie %d: получено %q, ожидалось %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCookieCipher(t *testing.T) {
+	c, err := newCookieCipher("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := c.encrypt(affinityCookie{Backend: "api-1", Expires: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded affinityCookie
+	if err := c.decrypt(value, &decoded); err != nil || decoded.Backend != "api-1" {
+		t.Errorf("cookie должна расшифровываться: %+v, %v", decoded, err)
+	}
+
+	tampered := []byte(value)
+	tampered[len(tampered)/2] ^= 1
+	if err := c.decrypt(string(tampered), &decoded); err == nil {
+		t.Error("измененная cookie должна отклоняться")
+	}
+}
@@ -0,0 +1,81 @@
+package transport
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// copyBufferSize размер буфера, через который тело ответа бэкенда передается клиенту
+const copyBufferSize = 32 * 1024
+
+// responseWriteTimeout время, за которое клиент должен принять очередную часть тела ответа
+const responseWriteTimeout = time.Minute
+
+// copyBuffers пул буферов копирования: при высоком RPS буфер не выделяется на каждый запрос
+var copyBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, copyBufferSize)
+		return &buf
+	},
+}
+
+// copyResponse передает тело ответа бэкенда клиенту через буфер из пула. Следующая часть
+// читается у бэкенда только после того, как клиент принял предыдущую, поэтому медленный
+// клиент замедляет чтение ответа, а не накапливает его в памяти. Перед каждой записью
+// продлевается таймаут записи: клиент, переставший принимать данные, отключается.
+// При flush каждая часть сразу отправляется клиенту, а не копится в буфере соединения.
+func copyResponse(w http.ResponseWriter, body io.Reader, flush bool) (int64, error) {
+	bufp := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(bufp)
+	buf := *bufp
+
+	// Таймаут записи поддерживают не все ResponseWriter (например, httptest.ResponseRecorder)
+	controller := http.NewResponseController(w)
+	deadlines := true
+	defer func() {
+		if deadlines {
+			controller.SetWriteDeadline(time.Time{})
+		}
+	}()
+
+	var written int64
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if deadlines && controller.SetWriteDeadline(time.Now().Add(responseWriteTimeout)) != nil {
+				deadlines = false
+			}
+			m, err := w.Write(buf[:n])
+			written += int64(m)
+			if err != nil {
+				return written, err
+			}
+			if m < n {
+				return written, io.ErrShortWrite
+			}
+			// Сброс поддерживают не все ResponseWriter (например, буферизующие ответ middleware)
+			if flush && controller.Flush() != nil {
+				flush = false
+			}
+		}
+		if readErr == io.EOF {
+			return written, nil
+		}
+		if readErr != nil {
+			return written, readErr
+		}
+	}
+}
+
+// streamingResponse проверяет, что ответ нужно передавать клиенту по мере получения, как
+// в httputil.ReverseProxy: длина тела неизвестна (потоковый ответ) или это Server-Sent Events
+func streamingResponse(resp *http.Response) bool {
+	if resp.ContentLength == -1 {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
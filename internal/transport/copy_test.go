@@ -0,0 +1,127 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+// discardResponseWriter ResponseWriter без io.ReaderFrom, как соединение с клиентом
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+// backendBody тело ответа бэкенда размером size: как и resp.Body, не реализует io.WriterTo
+func backendBody(size int) io.Reader {
+	return &io.LimitedReader{R: strings.NewReader(strings.Repeat("x", size)), N: int64(size)}
+}
+
+func TestCopyResponse(t *testing.T) {
+	body := strings.Repeat("ответ бэкенда ", 10000)
+	recorder := httptest.NewRecorder()
+
+	written, err := copyResponse(recorder, backendBody(len(body)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != int64(len(body)) || recorder.Body.Len() != len(body) {
+		t.Errorf("передано %d байт, ожидалось %d", written, len(body))
+	}
+
+	// Обрыв записи прерывает копирование
+	if _, err := copyResponse(&failingWriter{}, backendBody(len(body)), true); err == nil {
+		t.Error("ошибка записи не возвращена")
+	}
+}
+
+func TestProxy_StreamsResponse(t *testing.T) {
+	finish := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-finish
+		io.WriteString(w, "data: last\n\n")
+	}))
+	defer upstream.Close()
+
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backend.NewBackendWithOptions("events", upstream.URL, 1, backend.Options{})
+	defer b.Close()
+	lb.AddBackend(b)
+	proxy := httptest.NewServer(NewProxy(lb, ratelimit.NewNoopRateLimiter(), Options{}, logger.NewNop()))
+	defer proxy.Close()
+	defer close(finish)
+
+	// Первое событие приходит клиенту, пока бэкенд еще не завершил ответ
+	line := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(proxy.URL + "/events")
+		if err != nil {
+			line <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		first, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		line <- first
+	}()
+	select {
+	case first := <-line:
+		if first != "data: first\n" {
+			t.Errorf("неожиданное первое событие: %q", first)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("потоковый ответ должен передаваться клиенту до завершения ответа бэкендом")
+	}
+}
+
+// failingWriter ResponseWriter, запись в который всегда завершается ошибкой
+type failingWriter struct{ discardResponseWriter }
+
+func (*failingWriter) Write([]byte) (int, error) { return 0, io.ErrClosedPipe }
+
+func benchmarkCopy(b *testing.B, size int, copy func(http.ResponseWriter, io.Reader) (int64, error)) {
+	w := &discardResponseWriter{header: make(http.Header)}
+	data := bytes.Repeat([]byte("x"), size)
+	b.ReportAllocs()
+	b.SetBytes(int64(size))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			body := &io.LimitedReader{R: bytes.NewReader(data), N: int64(size)}
+			if _, err := copy(w, body); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func ioCopy(w http.ResponseWriter, body io.Reader) (int64, error) {
+	return io.Copy(w, body)
+}
+
+func bufferedCopy(w http.ResponseWriter, body io.Reader) (int64, error) {
+	return copyResponse(w, body, false)
+}
+
+func BenchmarkCopyResponse_Small(b *testing.B) { benchmarkCopy(b, 1024, bufferedCopy) }
+func BenchmarkIOCopy_Small(b *testing.B)       { benchmarkCopy(b, 1024, ioCopy) }
+func BenchmarkCopyResponse_Large(b *testing.B) { benchmarkCopy(b, 1024*1024, bufferedCopy) }
+func BenchmarkIOCopy_Large(b *testing.B)       { benchmarkCopy(b, 1024*1024, ioCopy) }
@@ -0,0 +1,248 @@
+package transport
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer/base"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/pkg/request"
+)
+
+// defaultDebugHeader заголовок с токеном отладки маршрутизации по умолчанию
+const defaultDebugHeader = "X-LB-Debug"
+
+// Заголовки ответа с объяснением маршрутизации
+const (
+	debugHeaderBackend    = "X-LB-Debug-Backend"
+	debugHeaderDecision   = "X-LB-Debug-Decision"
+	debugHeaderCandidates = "X-LB-Debug-Candidates"
+	debugHeaderRoute      = "X-LB-Debug-Route"
+	debugHeaderClient     = "X-LB-Debug-Client"
+	debugHeaderRateLimit  = "X-LB-Debug-RateLimit"
+)
+
+// debugBackend бэкенд, выбранный на этапе balance, для заголовков отладки
+var debugBackend = request.NewKey[string]("debug.backend")
+
+// RoutingDebug режим отладки маршрутизации. Отладка включается для отдельных запросов
+// токеном, подписанным ключом из конфигурации, или для всех запросов через
+// административное API. Состояние не зависит от реконфигурации прокси.
+type RoutingDebug struct {
+	mu     sync.RWMutex
+	header string
+	secret []byte
+	until  time.Time
+	always bool
+
+	now func() time.Time
+}
+
+// RoutingDebugStatus состояние режима отладки маршрутизации
+type RoutingDebugStatus struct {
+	// Отладка включена для всех запросов
+	Enabled bool `json:"enabled"`
+
+	// Время автоматического отключения (nil — до явного отключения)
+	Until *time.Time `json:"until,omitempty"`
+
+	// Заголовок токена отладки и возможность выпуска токенов (задан ключ подписи)
+	Header        string `json:"header"`
+	TokensEnabled bool   `json:"tokensEnabled"`
+}
+
+// NewRoutingDebug создает выключенный режим отладки
+func NewRoutingDebug() *RoutingDebug {
+	return &RoutingDebug{header: defaultDebugHeader, now: time.Now}
+}
+
+// Configure применяет заголовок и ключ подписи из конфигурации; включение
+// отладки для всех запросов через API сохраняется
+func (d *RoutingDebug) Configure(cfg *config.RoutingDebugConfig) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.header, d.secret = defaultDebugHeader, nil
+	if cfg == nil {
+		return
+	}
+	if cfg.Header != "" {
+		d.header = cfg.Header
+	}
+	if cfg.Secret != "" {
+		d.secret = []byte(cfg.Secret)
+	}
+}
+
+// SetEnabled включает или выключает отладку для всех запросов. Положительная
+// длительность duration ограничивает время работы режима.
+func (d *RoutingDebug) SetEnabled(enabled bool, duration time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.always, d.until = false, time.Time{}
+	switch {
+	case !enabled:
+	case duration > 0:
+		d.until = d.now().Add(duration)
+	default:
+		d.always = true
+	}
+}
+
+// Status возвращает текущее состояние режима
+func (d *RoutingDebug) Status() RoutingDebugStatus {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	status := RoutingDebugStatus{
+		Enabled:       d.always,
+		Header:        d.header,
+		TokensEnabled: len(d.secret) > 0,
+	}
+	if !d.always && d.now().Before(d.until) {
+		until := d.until
+		status.Enabled, status.Until = true, &until
+	}
+	return status
+}
+
+// Token выпускает токен отладки, действующий ttl
+func (d *RoutingDebug) Token(ttl time.Duration) (string, time.Time, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if len(d.secret) == 0 {
+		return "", time.Time{}, errors.New("routing debug secret is not configured")
+	}
+	expires := d.now().Add(ttl).Truncate(time.Second)
+	payload := strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + d.sign(payload), expires, nil
+}
+
+// active проверяет, включена ли отладка для запроса, и удаляет токен из запроса,
+// чтобы он не передавался бэкенду
+func (d *RoutingDebug) active(r *http.Request) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	token := r.Header.Get(d.header)
+	r.Header.Del(d.header)
+
+	if d.always || d.now().Before(d.until) {
+		return true
+	}
+	if token == "" || len(d.secret) == 0 {
+		return false
+	}
+
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(d.sign(payload))) {
+		return false
+	}
+	expires, err := strconv.ParseInt(payload, 10, 64)
+	return err == nil && d.now().Before(time.Unix(expires, 0))
+}
+
+// sign возвращает HMAC подпись данных токена
+func (d *RoutingDebug) sign(payload string) string {
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write([]byte("lb-debug:" + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// debug включает объяснение маршрутизации в заголовках ответа для запросов,
+// которым отладка разрешена токеном или административным API
+func (p *Proxy) debug(next http.Handler) http.Handler {
+	if p.routingDebug == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !p.routingDebug.active(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		req := request.FromContext(r.Context())
+		request.Set(req, request.KeyDebug, true)
+		p.logger.Debug(fmt.Sprintf("Отладка маршрутизации для запроса %s %s от %s", r.Method, r.URL.Path, r.RemoteAddr))
+		next.ServeHTTP(&debugWriter{ResponseWriter: w, proxy: p, req: req}, r)
+	})
+}
+
+// debugWriter добавляет заголовки отладки перед отправкой заголовков ответа
+type debugWriter struct {
+	http.ResponseWriter
+	proxy   *Proxy
+	req     request.Request
+	written bool
+}
+
+func (w *debugWriter) WriteHeader(statusCode int) {
+	w.annotate()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *debugWriter) Write(p []byte) (int, error) {
+	w.annotate()
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap позволяет http.ResponseController использовать исходный ResponseWriter
+func (w *debugWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// annotate заполняет заголовки отладки по значениям, сохраненным этапами обработки
+func (w *debugWriter) annotate() {
+	if w.written {
+		return
+	}
+	w.written = true
+
+	header := w.Header()
+	for _, name := range []string{debugHeaderBackend, debugHeaderDecision, debugHeaderCandidates, debugHeaderRoute, debugHeaderClient, debugHeaderRateLimit} {
+		header.Del(name)
+	}
+
+	if backend, ok := request.Get(w.req, debugBackend); ok {
+		header.Set(debugHeaderBackend, backend)
+	} else {
+		header.Set(debugHeaderBackend, "none")
+	}
+	if decision, ok := request.Get(w.req, base.KeyDecision); ok {
+		header.Set(debugHeaderDecision, decision)
+	}
+	if candidates, ok := request.Get(w.req, base.KeyCandidates); ok {
+		header.Set(debugHeaderCandidates, candidates)
+	}
+	if route, ok := request.Get(w.req, request.KeyRoute); ok {
+		header.Set(debugHeaderRoute, route)
+	}
+
+	client := "ip=" + w.req.GetClientIP()
+	if userID := w.req.GetUserID(); userID != w.req.GetClientIP() {
+		client += ", user=" + userID
+	}
+	if geo := w.req.GetGeo(); geo.Country != "" || geo.ASN != 0 || geo.Zone != "" {
+		client += fmt.Sprintf(", country=%s, asn=%d, zone=%s", geo.Country, geo.ASN, geo.Zone)
+	}
+	header.Set(debugHeaderClient, client)
+
+	limiter := w.proxy.ratelimit
+	if _, noop := limiter.(*ratelimit.NoopRateLimiter); limiter == nil || noop {
+		header.Set(debugHeaderRateLimit, "disabled")
+	} else {
+		key := w.req.GetClientIP()
+		header.Set(debugHeaderRateLimit, fmt.Sprintf("key=%s, tokens=%.2f, rate=%.2f, burst=%d",
+			key, limiter.GetTokens(key), limiter.GetRate(key), limiter.GetBurst(key)))
+	}
+}
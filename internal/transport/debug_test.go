@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestRoutingDebug(t *testing.T) {
+	var forwardedToken string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedToken = r.Header.Get("X-LB-Debug")
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lb.AddBackend(backend.NewBackendWithOptions("b1", upstream.URL, 1, backend.Options{}))
+	down := backend.NewBackendWithOptions("b2", "http://127.0.0.1:1", 1, backend.Options{})
+	down.SetAlive(false)
+	lb.AddBackend(down)
+
+	debug := NewRoutingDebug()
+	debug.Configure(&config.RoutingDebugConfig{Secret: "secret"})
+	proxy := NewProxy(lb, ratelimit.NewNoopRateLimiter(), Options{RoutingDebug: debug}, logger.NewNop())
+
+	serve := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		if token != "" {
+			req.Header.Set("X-LB-Debug", token)
+		}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve(""); rec.Header().Get(debugHeaderBackend) != "" {
+		t.Errorf("без токена заголовки отладки не добавляются")
+	}
+	if rec := serve("1.deadbeef"); rec.Header().Get(debugHeaderBackend) != "" {
+		t.Errorf("токен с неверной подписью не должен включать отладку")
+	}
+
+	token, _, err := debug.Token(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(token)
+	if rec.Header().Get(debugHeaderBackend) != "b1" {
+		t.Errorf("ожидался выбранный бэкенд b1: %q", rec.Header().Get(debugHeaderBackend))
+	}
+	if !strings.HasPrefix(rec.Header().Get(debugHeaderDecision), "RoundRobin:") {
+		t.Errorf("ожидалось объяснение алгоритма: %q", rec.Header().Get(debugHeaderDecision))
+	}
+	if candidates := rec.Header().Get(debugHeaderCandidates); !strings.Contains(candidates, "b1(candidate") || !strings.Contains(candidates, "b2(unhealthy") {
+		t.Errorf("ожидалось состояние бэкендов: %q", candidates)
+	}
+	if rec.Header().Get(debugHeaderRateLimit) != "disabled" {
+		t.Errorf("rate limiter отключен: %q", rec.Header().Get(debugHeaderRateLimit))
+	}
+	if forwardedToken != "" {
+		t.Errorf("токен отладки не должен передаваться бэкенду")
+	}
+
+	debug.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if rec := serve(token); rec.Header().Get(debugHeaderBackend) != "" {
+		t.Errorf("просроченный токен не должен включать отладку")
+	}
+
+	debug.SetEnabled(true, time.Minute)
+	if rec := serve(""); rec.Header().Get(debugHeaderBackend) != "b1" {
+		t.Errorf("включенная через API отладка действует для всех запросов")
+	}
+	debug.SetEnabled(false, 0)
+	if rec := serve(""); rec.Header().Get(debugHeaderBackend) != "" {
+		t.Errorf("отладка должна отключаться")
+	}
+}
@@ -0,0 +1,194 @@
+package transport
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Параметры внешней авторизации по умолчанию
+const (
+	defaultExtAuthzTimeout = time.Second
+	maxExtAuthzDenyBody    = 64 << 10
+)
+
+// extAuthzParams параметры middleware extAuthz
+type extAuthzParams struct {
+	// Адрес сервиса авторизации: http://authz:9000 для HTTP проверки,
+	// grpc://authz:9001 (grpcs:// с TLS) для envoy.service.auth.v3.Authorization/Check
+	Address string `yaml:"address"`
+
+	// Префикс, добавляемый к пути запроса при обращении к сервису (только HTTP)
+	PathPrefix string `yaml:"pathPrefix"`
+
+	// Таймаут проверки (по умолчанию 1s)
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Заголовки запроса, передаваемые сервису (по умолчанию все)
+	AllowedHeaders []string `yaml:"allowedHeaders"`
+
+	// Заголовки ответа сервиса, добавляемые к запросу при разрешении (например, X-User-ID)
+	UpstreamHeaders []string `yaml:"upstreamHeaders"`
+
+	// Пропускать запросы, если сервис авторизации недоступен
+	FailureModeAllow bool `yaml:"failureModeAllow"`
+}
+
+// extAuthz проверяет каждый запрос во внешнем сервисе авторизации (в стиле Envoy ext_authz):
+// сервису отправляется запрос с методом, путем и заголовками клиента без тела.
+// Ответ 200 разрешает запрос, ответы 5xx и ошибки соединения считаются недоступностью
+// сервиса, остальные ответы возвращаются клиенту как отказ. В режиме gRPC решение
+// приводится к такому же ответу (см. checkGRPC).
+type extAuthz struct {
+	address         *url.URL
+	grpc            bool
+	pathPrefix      string
+	allowedHeaders  []string
+	upstreamHeaders []string
+	failureMode     bool
+	client          *http.Client
+	logger          logger.Logger
+}
+
+// newExtAuthzMiddleware создает middleware внешней авторизации
+func newExtAuthzMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params extAuthzParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+
+	address, err := url.Parse(params.Address)
+	if err != nil || address.Host == "" {
+		return nil, fmt.Errorf("address must be an http(s) or grpc(s) URL: %q", params.Address)
+	}
+	var grpcMode bool
+	switch address.Scheme {
+	case "http", "https":
+	case "grpc", "grpcs":
+		grpcMode = true
+	default:
+		return nil, fmt.Errorf("address must be an http(s) or grpc(s) URL: %q", params.Address)
+	}
+	if params.Timeout < 0 {
+		return nil, fmt.Errorf("timeout must not be negative")
+	}
+	if params.Timeout == 0 {
+		params.Timeout = defaultExtAuthzTimeout
+	}
+
+	a := &extAuthz{
+		address:         address,
+		grpc:            grpcMode,
+		pathPrefix:      strings.TrimSuffix(params.PathPrefix, "/"),
+		allowedHeaders:  params.AllowedHeaders,
+		upstreamHeaders: params.UpstreamHeaders,
+		failureMode:     params.FailureModeAllow,
+		client: &http.Client{
+			Timeout: params.Timeout,
+			// Перенаправление (например, на страницу входа) возвращается клиенту
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		logger: appLogger,
+	}
+	if grpcMode {
+		a.client.Transport = newExtAuthzGRPCTransport(address.Scheme == "grpcs")
+	}
+	return a.middleware, nil
+}
+
+func (a *extAuthz) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := a.check(r)
+		if err != nil {
+			if a.failureMode {
+				a.logger.Warn(fmt.Sprintf("Сервис авторизации недоступен, запрос %s пропущен: %v", r.URL.Path, err))
+				next.ServeHTTP(w, r)
+				return
+			}
+			a.logger.Error(fmt.Sprintf("Ошибка обращения к сервису авторизации: %v", err))
+			http.Error(w, "Authorization service unavailable", http.StatusForbidden)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			a.logger.Debug(fmt.Sprintf("Сервис авторизации отклонил запрос %s %s со статусом %d", r.Method, r.URL.Path, resp.StatusCode))
+			a.deny(w, resp)
+			return
+		}
+
+		if len(a.upstreamHeaders) > 0 {
+			// Значения, присланные клиентом, заменяются ответом сервиса, чтобы их нельзя было подделать
+			r = r.Clone(r.Context())
+			for _, name := range a.upstreamHeaders {
+				r.Header.Del(name)
+				if values := resp.Header.Values(name); len(values) > 0 {
+					r.Header[http.CanonicalHeaderKey(name)] = values
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// check запрашивает решение сервиса авторизации. Ошибка означает, что сервис недоступен.
+func (a *extAuthz) check(r *http.Request) (*http.Response, error) {
+	if a.grpc {
+		return a.checkGRPC(r)
+	}
+	resp, err := a.checkHTTP(r)
+	if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+		// Ошибка самого сервиса авторизации не является отказом
+		resp.Body.Close()
+		return nil, fmt.Errorf("authorization service returned status %d", resp.StatusCode)
+	}
+	return resp, err
+}
+
+// checkHTTP отправляет запрос на проверку сервису авторизации по HTTP
+func (a *extAuthz) checkHTTP(r *http.Request) (*http.Response, error) {
+	target := *a.address
+	target.Path = a.pathPrefix + r.URL.Path
+	target.RawPath = ""
+	target.RawQuery = r.URL.RawQuery
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(a.allowedHeaders) == 0 {
+		req.Header = r.Header.Clone()
+	} else {
+		for _, name := range a.allowedHeaders {
+			if values := r.Header.Values(name); len(values) > 0 {
+				req.Header[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+	}
+	req.Header.Del("Content-Length")
+	req.Header.Set("X-Forwarded-For", clientIP(r))
+	req.Header.Set("X-Forwarded-Host", r.Host)
+	req.Host = a.address.Host
+
+	return a.client.Do(req)
+}
+
+// deny возвращает клиенту статус, заголовки и тело отказа сервиса авторизации
+func (a *extAuthz) deny(w http.ResponseWriter, resp *http.Response) {
+	for name, values := range resp.Header {
+		switch name {
+		case "Content-Length", "Connection", "Transfer-Encoding":
+			continue
+		}
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, io.LimitReader(resp.Body, maxExtAuthzDenyBody))
+}
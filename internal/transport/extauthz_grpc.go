@@ -0,0 +1,275 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cloud.ru_test/internal/transcode"
+)
+
+// Метод проверки сервиса авторизации Envoy
+const extAuthzGRPCPath = "/envoy.service.auth.v3.Authorization/Check"
+
+// maxExtAuthzGRPCResponse ограничение размера ответа CheckResponse
+const maxExtAuthzGRPCResponse = maxExtAuthzDenyBody + 64<<10
+
+var errMalformedCheckResponse = errors.New("malformed authorization check response")
+
+// newExtAuthzGRPCTransport создает транспорт HTTP/2 для вызовов gRPC: без TLS (h2c)
+// для схемы grpc и с TLS для grpcs
+func newExtAuthzGRPCTransport(useTLS bool) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Protocols = new(http.Protocols)
+	if useTLS {
+		transport.Protocols.SetHTTP2(true)
+	} else {
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return transport
+}
+
+// checkGRPC вызывает envoy.service.auth.v3.Authorization/Check и приводит решение
+// к ответу HTTP: разрешение — ответ 200 с заголовками ok_response, отказ — статус,
+// заголовки и тело denied_response (по умолчанию 403). Ошибка вызова gRPC считается
+// недоступностью сервиса.
+func (a *extAuthz) checkGRPC(r *http.Request) (*http.Response, error) {
+	target := *a.address
+	target.Scheme = "http"
+	if a.address.Scheme == "grpcs" {
+		target.Scheme = "https"
+	}
+	target.Path = extAuthzGRPCPath
+	target.RawPath = ""
+	target.RawQuery = ""
+
+	frame := transcode.AppendFrame(nil, 0, a.checkRequest(r))
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, target.String(), bytes.NewReader(frame))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxExtAuthzGRPCResponse+1))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("authorization service returned status %d", resp.StatusCode)
+	}
+	if len(body) > maxExtAuthzGRPCResponse {
+		return nil, errors.New("authorization check response is too large")
+	}
+
+	// Ответ без сообщения передает статус в заголовках, а не в трейлерах
+	status := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	if status != "0" {
+		return nil, fmt.Errorf("authorization service returned grpc status %s: %s", status, message)
+	}
+
+	frames, err := transcode.ParseFrames(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) != 1 || frames[0].Flags&transcode.FlagCompressed != 0 {
+		return nil, errMalformedCheckResponse
+	}
+	return checkResponse(frames[0].Payload)
+}
+
+// checkRequest кодирует CheckRequest с атрибутами запроса клиента:
+// attributes.source.address.socket_address и attributes.request.http
+func (a *extAuthz) checkRequest(r *http.Request) []byte {
+	var socket []byte
+	socket = appendAuthzBytes(socket, 2, []byte(clientIP(r)))
+	if _, port, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if value, err := strconv.ParseUint(port, 10, 32); err == nil {
+			socket = appendAuthzVarint(socket, 3, value)
+		}
+	}
+	source := appendAuthzBytes(nil, 1, appendAuthzBytes(nil, 1, socket))
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	var httpRequest []byte
+	httpRequest = appendAuthzBytes(httpRequest, 2, []byte(r.Method))
+	for name, values := range r.Header {
+		if len(a.allowedHeaders) > 0 && !containsHeader(a.allowedHeaders, name) {
+			continue
+		}
+		entry := appendAuthzBytes(nil, 1, []byte(strings.ToLower(name)))
+		entry = appendAuthzBytes(entry, 2, []byte(strings.Join(values, ",")))
+		httpRequest = appendAuthzBytes(httpRequest, 3, entry)
+	}
+	httpRequest = appendAuthzBytes(httpRequest, 4, []byte(r.URL.RequestURI()))
+	httpRequest = appendAuthzBytes(httpRequest, 5, []byte(r.Host))
+	httpRequest = appendAuthzBytes(httpRequest, 6, []byte(scheme))
+	httpRequest = appendAuthzBytes(httpRequest, 10, []byte(r.Proto))
+
+	attributes := appendAuthzBytes(nil, 1, source)
+	attributes = appendAuthzBytes(attributes, 4, appendAuthzBytes(nil, 2, httpRequest))
+	return appendAuthzBytes(nil, 1, attributes)
+}
+
+// containsHeader проверяет, есть ли заголовок name в списке names
+func containsHeader(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkResponse разбирает CheckResponse: status (1), denied_response (2), ok_response (3)
+func checkResponse(msg []byte) (*http.Response, error) {
+	var code uint64
+	var denied, ok []byte
+	err := walkAuthzMessage(msg, func(number, value uint64, data []byte) error {
+		switch number {
+		case 1:
+			return walkAuthzMessage(data, func(number, value uint64, _ []byte) error {
+				if number == 1 {
+					code = value
+				}
+				return nil
+			})
+		case 2:
+			denied = data
+		case 3:
+			ok = data
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: http.NoBody}
+	if code == transcode.CodeOK {
+		// OkHttpResponse: headers (2)
+		err = walkAuthzMessage(ok, func(number, _ uint64, data []byte) error {
+			if number == 2 {
+				return addAuthzHeader(resp.Header, data)
+			}
+			return nil
+		})
+		return resp, err
+	}
+
+	// DeniedHttpResponse: status (1), headers (2), body (3)
+	resp.StatusCode = http.StatusForbidden
+	var body []byte
+	err = walkAuthzMessage(denied, func(number, _ uint64, data []byte) error {
+		switch number {
+		case 1:
+			return walkAuthzMessage(data, func(number, value uint64, _ []byte) error {
+				if number == 1 && value >= 100 && value <= 599 {
+					resp.StatusCode = int(value)
+				}
+				return nil
+			})
+		case 2:
+			return addAuthzHeader(resp.Header, data)
+		case 3:
+			body = data
+		}
+		return nil
+	})
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, err
+}
+
+// addAuthzHeader добавляет заголовок из HeaderValueOption{header: HeaderValue{key, value}}
+func addAuthzHeader(header http.Header, option []byte) error {
+	return walkAuthzMessage(option, func(number, _ uint64, data []byte) error {
+		if number != 1 {
+			return nil
+		}
+		var key, value string
+		err := walkAuthzMessage(data, func(number, _ uint64, data []byte) error {
+			switch number {
+			case 1:
+				key = string(data)
+			case 2:
+				value = string(data)
+			}
+			return nil
+		})
+		if err == nil && key != "" {
+			header.Add(key, value)
+		}
+		return err
+	})
+}
+
+// appendAuthzBytes дописывает поле protobuf с префиксом длины
+func appendAuthzBytes(b []byte, number int, value []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(number)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// appendAuthzVarint дописывает поле protobuf varint
+func appendAuthzVarint(b []byte, number int, value uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(number)<<3)
+	return binary.AppendUvarint(b, value)
+}
+
+// walkAuthzMessage вызывает fn для каждого поля сообщения protobuf: значение varint
+// передается в value, содержимое поля с префиксом длины — в data
+func walkAuthzMessage(msg []byte, fn func(number, value uint64, data []byte) error) error {
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return errMalformedCheckResponse
+		}
+		msg = msg[n:]
+		var value uint64
+		var data []byte
+		switch tag & 7 {
+		case 0:
+			if value, n = binary.Uvarint(msg); n <= 0 {
+				return errMalformedCheckResponse
+			}
+		case 1:
+			n = 8
+		case 2:
+			length, m := binary.Uvarint(msg)
+			if m <= 0 || length > uint64(len(msg)-m) {
+				return errMalformedCheckResponse
+			}
+			data, n = msg[m:m+int(length)], m+int(length)
+		case 5:
+			n = 4
+		default:
+			return errMalformedCheckResponse
+		}
+		if n > len(msg) {
+			return errMalformedCheckResponse
+		}
+		msg = msg[n:]
+		if err := fn(tag>>3, value, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
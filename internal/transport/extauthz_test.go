@@ -0,0 +1,216 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/transcode"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestExtAuthz(t *testing.T) {
+	authz := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/check/orders" {
+			t.Errorf("неверный путь проверки: %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-User-ID", "42")
+	}))
+	defer authz.Close()
+
+	m, err := newExtAuthzMiddleware(config.MiddlewareConfig{Name: "extAuthz", Params: map[string]interface{}{
+		"address":         authz.URL,
+		"pathPrefix":      "/check",
+		"upstreamHeaders": []interface{}{"X-User-ID"},
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatalf("middleware должен создаваться: %v", err)
+	}
+	handler := m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + r.Header.Get("X-User-ID")))
+	}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("Authorization", "Bearer bad")
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("отказ сервиса должен возвращаться клиенту: %d %v", rec.Code, rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("Authorization", "Bearer good")
+	req.Header.Set("X-User-ID", "spoofed")
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "user 42" {
+		t.Errorf("разрешенный запрос должен получать заголовки сервиса: %d %q", rec.Code, rec.Body.String())
+	}
+
+	authz.Close()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("при недоступном сервисе запрос должен отклоняться, получен статус %d", rec.Code)
+	}
+}
+
+// grpcAuthzServer сервис envoy.service.auth.v3.Authorization по h2c: разрешает запросы
+// с токеном good, отклоняет с токенами bad и denied и завершает вызов ошибкой для остальных
+func grpcAuthzServer(t *testing.T) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != extAuthzGRPCPath || r.ProtoMajor != 2 || r.Header.Get("Content-Type") != "application/grpc" {
+			t.Errorf("неверный вызов проверки: %s %s %s", r.Proto, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		frames, err := transcode.ParseFrames(body)
+		if err != nil || len(frames) != 1 {
+			t.Errorf("неверные кадры запроса: %v", err)
+			return
+		}
+
+		// CheckRequest.attributes.request.http
+		var path, authorization string
+		walkAuthzMessage(frames[0].Payload, func(_, _ uint64, attributes []byte) error {
+			return walkAuthzMessage(attributes, func(number, _ uint64, request []byte) error {
+				if number != 4 {
+					return nil
+				}
+				return walkAuthzMessage(request, func(_, _ uint64, httpRequest []byte) error {
+					return walkAuthzMessage(httpRequest, func(number, _ uint64, data []byte) error {
+						switch number {
+						case 3:
+							var key, value string
+							walkAuthzMessage(data, func(number, _ uint64, data []byte) error {
+								if number == 1 {
+									key = string(data)
+								} else {
+									value = string(data)
+								}
+								return nil
+							})
+							if key == "authorization" {
+								authorization = value
+							}
+						case 4:
+							path = string(data)
+						}
+						return nil
+					})
+				})
+			})
+		})
+		if path != "/orders?page=2" {
+			t.Errorf("неверный путь в запросе проверки: %q", path)
+		}
+
+		header := func(key, value string) []byte {
+			return appendAuthzBytes(nil, 1, appendAuthzBytes(appendAuthzBytes(nil, 1, []byte(key)), 2, []byte(value)))
+		}
+		var msg []byte
+		switch authorization {
+		case "Bearer good":
+			msg = appendAuthzBytes(nil, 3, appendAuthzBytes(nil, 2, header("X-User-ID", "42")))
+		case "Bearer bad":
+			denied := appendAuthzBytes(nil, 1, appendAuthzVarint(nil, 1, http.StatusUnauthorized))
+			denied = appendAuthzBytes(denied, 2, header("WWW-Authenticate", "Bearer"))
+			denied = appendAuthzBytes(denied, 3, []byte("invalid token"))
+			msg = appendAuthzBytes(appendAuthzBytes(nil, 1, appendAuthzVarint(nil, 1, transcode.CodeUnauthenticated)), 2, denied)
+		case "Bearer denied":
+			msg = appendAuthzBytes(nil, 1, appendAuthzVarint(nil, 1, transcode.CodePermissionDenied))
+		default:
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Grpc-Status", "14")
+			w.Header().Set("Grpc-Message", "unavailable")
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write(transcode.AppendFrame(nil, 0, msg))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExtAuthz_GRPC(t *testing.T) {
+	authz := grpcAuthzServer(t)
+	newHandler := func(failureModeAllow bool) http.Handler {
+		m, err := newExtAuthzMiddleware(config.MiddlewareConfig{Name: "extAuthz", Params: map[string]interface{}{
+			"address":          "grpc://" + authz.Listener.Addr().String(),
+			"upstreamHeaders":  []interface{}{"X-User-ID"},
+			"failureModeAllow": failureModeAllow,
+		}}, logger.NewNop())
+		if err != nil {
+			t.Fatalf("middleware должен создаваться: %v", err)
+		}
+		return m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("user " + r.Header.Get("X-User-ID")))
+		}))
+	}
+	handler := newHandler(false)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+		body   string
+	}{
+		{"разрешение", "Bearer good", http.StatusOK, "user 42"},
+		{"отказ с ответом", "Bearer bad", http.StatusUnauthorized, "invalid token"},
+		{"отказ без ответа", "Bearer denied", http.StatusForbidden, ""},
+		{"ошибка вызова", "Bearer other", http.StatusForbidden, "Authorization service unavailable\n"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/orders?page=2", nil)
+		req.Header.Set("Authorization", tt.token)
+		req.Header.Set("X-User-ID", "spoofed")
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status || rec.Body.String() != tt.body {
+			t.Errorf("%s: статус %d, тело %q", tt.name, rec.Code, rec.Body.String())
+		}
+		if tt.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("%s: заголовки отказа должны возвращаться клиенту: %v", tt.name, rec.Header())
+		}
+	}
+
+	// При failureModeAllow ошибка вызова пропускает запрос, но отказ остается отказом
+	handler = newHandler(true)
+	for token, want := range map[string]int{"Bearer other": http.StatusOK, "Bearer denied": http.StatusForbidden} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/orders?page=2", nil)
+		req.Header.Set("Authorization", token)
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("failureModeAllow, токен %q: статус %d, ожидался %d", token, rec.Code, want)
+		}
+	}
+}
+
+func TestExtAuthz_Address(t *testing.T) {
+	for address, valid := range map[string]bool{
+		"http://authz:9000":  true,
+		"https://authz:9000": true,
+		"grpc://authz:9001":  true,
+		"grpcs://authz:9001": true,
+		"ftp://authz:21":     false,
+		"grpc://":            false,
+		"authz:9001":         false,
+	} {
+		_, err := newExtAuthzMiddleware(config.MiddlewareConfig{Name: "extAuthz", Params: map[string]interface{}{"address": address}}, logger.NewNop())
+		if (err == nil) != valid {
+			t.Errorf("адрес %q: ошибка %v", address, err)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package transport
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Заголовки, которыми клиент запрашивает сбой на маршрутах с allowHeaders
+const (
+	faultDelayHeader = "X-Fault-Delay"
+	faultAbortHeader = "X-Fault-Abort"
+)
+
+// Параметры внесения сбоев по умолчанию
+const (
+	defaultFaultStatus         = http.StatusServiceUnavailable
+	defaultFaultMaxHeaderDelay = 10 * time.Second
+)
+
+// faultsInjected счетчик внесенных сбоев
+var faultsInjected = metrics.Default.Counter("lb_faults_injected_total", "Requests affected by fault injection by fault type", "type")
+
+// faultParams параметры middleware faults
+type faultParams struct {
+	// Маршруты со сбоями; применяется первый подходящий
+	Routes []faultRouteParams `yaml:"routes"`
+
+	// Предел задержки, запрошенной заголовком X-Fault-Delay (по умолчанию 10s)
+	MaxHeaderDelay time.Duration `yaml:"maxHeaderDelay"`
+}
+
+// faultRouteParams сбои маршрута
+type faultRouteParams struct {
+	// Префикс пути и методы запросов маршрута (по умолчанию все)
+	PathPrefix string   `yaml:"pathPrefix"`
+	Methods    []string `yaml:"methods"`
+
+	// Задержка запросов перед передачей бэкенду
+	Delay *faultDelayParams `yaml:"delay"`
+
+	// Ответ ошибкой или разрыв соединения вместо передачи запроса бэкенду
+	Abort *faultAbortParams `yaml:"abort"`
+
+	// Разрешить клиентам запрашивать сбои заголовками X-Fault-Delay и X-Fault-Abort
+	AllowHeaders bool `yaml:"allowHeaders"`
+}
+
+// faultDelayParams задержка запросов
+type faultDelayParams struct {
+	// Задержка; если задан maxDuration, задержка выбирается случайно между duration и maxDuration
+	Duration    time.Duration `yaml:"duration"`
+	MaxDuration time.Duration `yaml:"maxDuration"`
+
+	// Доля задерживаемых запросов в процентах (по умолчанию 100)
+	Percentage *float64 `yaml:"percentage"`
+}
+
+// faultAbortParams прерывание запросов
+type faultAbortParams struct {
+	// Статус ответа (по умолчанию 503)
+	Status int `yaml:"status"`
+
+	// Разорвать соединение без ответа вместо ответа со статусом
+	Reset bool `yaml:"reset"`
+
+	// Доля прерываемых запросов в процентах (по умолчанию 100)
+	Percentage *float64 `yaml:"percentage"`
+}
+
+// faultRoute разобранный маршрут middleware faults
+type faultRoute struct {
+	pathPrefix string
+	methods    map[string]bool
+
+	delay         time.Duration
+	maxDelay      time.Duration
+	delayPercent  float64
+	abort         bool
+	abortStatus   int
+	abortReset    bool
+	abortPercent  float64
+	allowsHeaders bool
+}
+
+// faultInjector вносит задержки и ошибки в обработку запросов для проверки устойчивости
+// клиентов и их логики повторов
+type faultInjector struct {
+	routes         []*faultRoute
+	maxHeaderDelay time.Duration
+	logger         logger.Logger
+
+	// Случайное число из [0, 100) и ожидание; подменяются в тестах
+	random func() float64
+	sleep  func(r *http.Request, d time.Duration) bool
+}
+
+// newFaultMiddleware создает middleware внесения сбоев
+func newFaultMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params faultParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	f, err := newFaultInjector(params, appLogger)
+	if err != nil {
+		return nil, err
+	}
+	return f.middleware, nil
+}
+
+// newFaultInjector проверяет параметры маршрутов
+func newFaultInjector(params faultParams, appLogger logger.Logger) (*faultInjector, error) {
+	if len(params.Routes) == 0 {
+		return nil, fmt.Errorf("faults: at least one route is required")
+	}
+	if params.MaxHeaderDelay < 0 {
+		return nil, fmt.Errorf("faults: maxHeaderDelay must not be negative")
+	}
+
+	f := &faultInjector{
+		maxHeaderDelay: params.MaxHeaderDelay,
+		logger:         appLogger,
+		random:         func() float64 { return rand.Float64() * 100 },
+		sleep:          sleepContext,
+	}
+	if f.maxHeaderDelay == 0 {
+		f.maxHeaderDelay = defaultFaultMaxHeaderDelay
+	}
+
+	for i, rp := range params.Routes {
+		route := &faultRoute{pathPrefix: rp.PathPrefix, methods: make(map[string]bool), allowsHeaders: rp.AllowHeaders}
+		for _, method := range rp.Methods {
+			route.methods[strings.ToUpper(method)] = true
+		}
+
+		if d := rp.Delay; d != nil {
+			if d.Duration <= 0 {
+				return nil, fmt.Errorf("faults: routes[%d].delay.duration must be positive", i)
+			}
+			if d.MaxDuration != 0 && d.MaxDuration < d.Duration {
+				return nil, fmt.Errorf("faults: routes[%d].delay.maxDuration must not be less than duration", i)
+			}
+			percent, err := faultPercentage(d.Percentage)
+			if err != nil {
+				return nil, fmt.Errorf("faults: routes[%d].delay: %w", i, err)
+			}
+			route.delay, route.maxDelay, route.delayPercent = d.Duration, d.MaxDuration, percent
+		}
+
+		if a := rp.Abort; a != nil {
+			if a.Status == 0 {
+				a.Status = defaultFaultStatus
+			}
+			if a.Status < 100 || a.Status > 599 {
+				return nil, fmt.Errorf("faults: routes[%d].abort.status must be between 100 and 599", i)
+			}
+			percent, err := faultPercentage(a.Percentage)
+			if err != nil {
+				return nil, fmt.Errorf("faults: routes[%d].abort: %w", i, err)
+			}
+			route.abort, route.abortStatus, route.abortReset, route.abortPercent = true, a.Status, a.Reset, percent
+		}
+
+		if rp.Delay == nil && rp.Abort == nil && !rp.AllowHeaders {
+			return nil, fmt.Errorf("faults: routes[%d]: delay, abort or allowHeaders is required", i)
+		}
+		f.routes = append(f.routes, route)
+	}
+	return f, nil
+}
+
+// faultPercentage проверяет долю запросов; по умолчанию сбой вносится во все запросы
+func faultPercentage(p *float64) (float64, error) {
+	if p == nil {
+		return 100, nil
+	}
+	if *p < 0 || *p > 100 {
+		return 0, fmt.Errorf("percentage must be between 0 and 100")
+	}
+	return *p, nil
+}
+
+// match возвращает первый маршрут, подходящий запросу
+func (f *faultInjector) match(r *http.Request) *faultRoute {
+	for _, route := range f.routes {
+		if !strings.HasPrefix(r.URL.Path, route.pathPrefix) {
+			continue
+		}
+		if len(route.methods) > 0 && !route.methods[r.Method] {
+			continue
+		}
+		return route
+	}
+	return nil
+}
+
+// fault сбой, вносимый в запрос
+type fault struct {
+	delay  time.Duration
+	status int
+	reset  bool
+}
+
+// choose определяет сбой для запроса по настройкам маршрута и заголовкам клиента
+func (f *faultInjector) choose(route *faultRoute, r *http.Request) fault {
+	var chosen fault
+	if route.delay > 0 && f.random() < route.delayPercent {
+		chosen.delay = route.delay
+		if route.maxDelay > route.delay {
+			chosen.delay += time.Duration(rand.Int64N(int64(route.maxDelay - route.delay + 1)))
+		}
+	}
+	if route.abort && f.random() < route.abortPercent {
+		chosen.status, chosen.reset = route.abortStatus, route.abortReset
+	}
+
+	if !route.allowsHeaders {
+		return chosen
+	}
+	if value := r.Header.Get(faultDelayHeader); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			chosen.delay = min(d, f.maxHeaderDelay)
+		}
+	}
+	switch value := r.Header.Get(faultAbortHeader); {
+	case value == "":
+	case strings.EqualFold(value, "reset"):
+		chosen.reset = true
+	default:
+		if status, err := strconv.Atoi(value); err == nil && status >= 100 && status <= 599 {
+			chosen.status = status
+		}
+	}
+	// Заголовки сбоев не передаются бэкенду
+	r.Header.Del(faultDelayHeader)
+	r.Header.Del(faultAbortHeader)
+	return chosen
+}
+
+func (f *faultInjector) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := f.match(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		chosen := f.choose(route, r)
+		if chosen.delay > 0 {
+			faultsInjected.Inc("delay")
+			if f.logger.DebugEnabled() {
+				f.logger.Debug(fmt.Sprintf("Запрос %s %s задержан на %v внесением сбоев", r.Method, r.URL.Path, chosen.delay))
+			}
+			if !f.sleep(r, chosen.delay) {
+				return
+			}
+		}
+
+		switch {
+		case chosen.reset:
+			faultsInjected.Inc("reset")
+			if f.logger.DebugEnabled() {
+				f.logger.Debug(fmt.Sprintf("Соединение запроса %s %s разорвано внесением сбоев", r.Method, r.URL.Path))
+			}
+			// Сервер закрывает соединение (HTTP/2 — поток) без ответа
+			panic(http.ErrAbortHandler)
+		case chosen.status != 0:
+			faultsInjected.Inc("abort")
+			if f.logger.DebugEnabled() {
+				f.logger.Debug(fmt.Sprintf("Запрос %s %s прерван внесением сбоев со статусом %d", r.Method, r.URL.Path, chosen.status))
+			}
+			http.Error(w, "Fault injected", chosen.status)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
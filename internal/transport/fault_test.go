@@ -0,0 +1,92 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestFaultInjector(t *testing.T) {
+	half := 50.0
+	f, err := newFaultInjector(faultParams{Routes: []faultRouteParams{
+		{PathPrefix: "/slow", Delay: &faultDelayParams{Duration: time.Second}},
+		{PathPrefix: "/flaky", Methods: []string{"post"}, Abort: &faultAbortParams{Status: 502, Percentage: &half}},
+		{PathPrefix: "/chaos", AllowHeaders: true},
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var slept time.Duration
+	f.sleep = func(r *http.Request, d time.Duration) bool {
+		slept += d
+		return true
+	}
+
+	var backendHeaders http.Header
+	handler := f.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendHeaders = r.Header
+		io.WriteString(w, "ok")
+	}))
+	serve := func(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Задержка по настройкам маршрута
+	if rec := serve("GET", "/slow/report", nil); rec.Code != http.StatusOK || slept != time.Second {
+		t.Errorf("запрос должен быть задержан на 1s и передан бэкенду: статус %d, задержка %v", rec.Code, slept)
+	}
+
+	// Ошибка для доли запросов маршрута
+	f.random = func() float64 { return 10 }
+	if rec := serve("POST", "/flaky", nil); rec.Code != http.StatusBadGateway {
+		t.Errorf("запрос в пределах доли должен получить 502, получен %d", rec.Code)
+	}
+	f.random = func() float64 { return 90 }
+	if rec := serve("POST", "/flaky", nil); rec.Code != http.StatusOK {
+		t.Errorf("запрос вне доли должен быть передан бэкенду, получен %d", rec.Code)
+	}
+	if rec := serve("GET", "/flaky", nil); rec.Code != http.StatusOK {
+		t.Errorf("метод вне маршрута не должен прерываться, получен %d", rec.Code)
+	}
+
+	// Сбои по заголовкам только на маршрутах с allowHeaders; бэкенду заголовки не передаются
+	if rec := serve("GET", "/chaos", map[string]string{faultAbortHeader: "429"}); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("заголовок %s должен вернуть 429, получен %d", faultAbortHeader, rec.Code)
+	}
+	slept = 0
+	if rec := serve("GET", "/chaos", map[string]string{faultDelayHeader: "1h"}); rec.Code != http.StatusOK || slept != defaultFaultMaxHeaderDelay {
+		t.Errorf("задержка из заголовка должна ограничиваться %v: статус %d, задержка %v", defaultFaultMaxHeaderDelay, rec.Code, slept)
+	}
+	if backendHeaders.Get(faultDelayHeader) != "" {
+		t.Error("заголовки сбоев не должны передаваться бэкенду")
+	}
+	if rec := serve("GET", "/slow", map[string]string{faultAbortHeader: "500"}); rec.Code != http.StatusOK {
+		t.Errorf("заголовки сбоев вне маршрутов с allowHeaders должны игнорироваться, получен %d", rec.Code)
+	}
+}
+
+func TestFaultInjector_Reset(t *testing.T) {
+	f, err := newFaultInjector(faultParams{Routes: []faultRouteParams{
+		{Abort: &faultAbortParams{Reset: true}},
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(f.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	defer server.Close()
+
+	if resp, err := http.Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Errorf("соединение должно разрываться без ответа, получен статус %d", resp.StatusCode)
+	}
+}
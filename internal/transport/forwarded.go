@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/request"
+)
+
+// setForwardedHeaders заполняет в запросе к бэкенду out заголовки X-Forwarded-For,
+// X-Forwarded-Proto, X-Forwarded-Host, X-Forwarded-Port и, если включено, Forwarded.
+//
+// В режиме append заголовки запроса от доверенного прокси сохраняются: к цепочкам
+// X-Forwarded-For и Forwarded добавляется адрес отправителя, а X-Forwarded-Proto, -Host
+// и -Port остаются такими, какими их увидел первый прокси. Заголовки остальных
+// отправителей мог подставить клиент, поэтому они отбрасываются. В режиме overwrite
+// балансировщик считается первым прокси, и заголовки всегда формируются заново.
+func (p *Proxy) setForwardedHeaders(out http.Header, r *http.Request) {
+	cfg := p.forwarding
+	if cfg == nil {
+		cfg = &config.ForwardedHeadersConfig{}
+	}
+
+	remote := request.TrustedProxies(nil).ClientIP(r)
+	trusted := cfg.Mode != "overwrite" && p.trusted.Contains(net.ParseIP(remote))
+
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+	xProto, xHost, xPort := proto, r.Host, requestPort(r, proto)
+	if trusted {
+		xProto = headerOr(r.Header, "X-Forwarded-Proto", xProto)
+		xHost = headerOr(r.Header, "X-Forwarded-Host", xHost)
+		xPort = headerOr(r.Header, "X-Forwarded-Port", xPort)
+	}
+
+	xff := remote
+	if prior := r.Header.Values("X-Forwarded-For"); trusted && len(prior) > 0 {
+		xff = strings.Join(prior, ", ") + ", " + remote
+	}
+	out.Set("X-Forwarded-For", xff)
+	out.Set("X-Forwarded-Proto", xProto)
+	out.Set("X-Forwarded-Host", xHost)
+	out.Set("X-Forwarded-Port", xPort)
+
+	switch {
+	case cfg.Forwarded:
+		element := forwardedElement(remote, r.Host, proto, cfg.By)
+		if prior := r.Header.Values("Forwarded"); trusted && len(prior) > 0 {
+			element = strings.Join(prior, ", ") + ", " + element
+		}
+		out.Set("Forwarded", element)
+	case !trusted:
+		out.Del("Forwarded")
+	}
+}
+
+// headerOr возвращает значение заголовка name или fallback, если заголовка нет
+func headerOr(header http.Header, name, fallback string) string {
+	if value := header.Get(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// requestPort возвращает порт, к которому обратился клиент: из заголовка Host,
+// из адреса listener'а или порт схемы по умолчанию
+func requestPort(r *http.Request, proto string) string {
+	if _, port, err := net.SplitHostPort(r.Host); err == nil && port != "" {
+		return port
+	}
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		if _, port, err := net.SplitHostPort(addr.String()); err == nil && port != "" {
+			return port
+		}
+	}
+	if proto == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// forwardedElement собирает элемент заголовка Forwarded (RFC 7239, раздел 4) для
+// отправителя с адресом remote
+func forwardedElement(remote, host, proto, by string) string {
+	node := forwardedValue(remote)
+	if ip := net.ParseIP(remote); ip != nil && ip.To4() == nil {
+		// Адрес IPv6 записывается в квадратных скобках и всегда в кавычках
+		node = `"[` + remote + `]"`
+	}
+
+	params := []string{"for=" + node}
+	if by != "" {
+		params = append(params, "by="+forwardedValue(by))
+	}
+	if host != "" {
+		params = append(params, "host="+forwardedValue(host))
+	}
+	params = append(params, "proto="+proto)
+	return strings.Join(params, ";")
+}
+
+// forwardedValue возвращает значение параметра Forwarded: token как есть,
+// остальное — строкой в кавычках
+func forwardedValue(value string) string {
+	for _, c := range value {
+		if !isTokenChar(c) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+	}
+	return value
+}
+
+// isTokenChar проверяет, что символ допустим в token (RFC 7230, раздел 3.2.6)
+func isTokenChar(c rune) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", c)
+}
@@ -0,0 +1,100 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/request"
+)
+
+func TestSetForwardedHeaders(t *testing.T) {
+	trusted, err := request.ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forwarding := &config.ForwardedHeadersConfig{Forwarded: true, By: "_lb1"}
+
+	incoming := func(remote string) *http.Request {
+		r := httptest.NewRequest("GET", "http://shop.example:8080/", nil)
+		r.RemoteAddr = remote
+		r.Header.Set("X-Forwarded-For", "203.0.113.7")
+		r.Header.Set("X-Forwarded-Proto", "https")
+		r.Header.Set("X-Forwarded-Host", "shop.example")
+		r.Header.Set("Forwarded", "for=203.0.113.7;proto=https")
+		return r
+	}
+
+	tests := []struct {
+		name   string
+		mode   string
+		remote string
+		want   map[string]string
+	}{
+		{
+			name: "цепочка доверенного прокси дополняется", remote: "10.1.2.3:4000",
+			want: map[string]string{
+				"X-Forwarded-For":   "203.0.113.7, 10.1.2.3",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "shop.example",
+				"X-Forwarded-Port":  "8080",
+				"Forwarded":         "for=203.0.113.7;proto=https, for=10.1.2.3;by=_lb1;host=\"shop.example:8080\";proto=http",
+			},
+		},
+		{
+			name: "заголовки клиента отбрасываются", remote: "198.51.100.1:4000",
+			want: map[string]string{
+				"X-Forwarded-For":   "198.51.100.1",
+				"X-Forwarded-Proto": "http",
+				"X-Forwarded-Host":  "shop.example:8080",
+				"Forwarded":         "for=198.51.100.1;by=_lb1;host=\"shop.example:8080\";proto=http",
+			},
+		},
+		{
+			name: "overwrite не доверяет никому", mode: "overwrite", remote: "10.1.2.3:4000",
+			want: map[string]string{
+				"X-Forwarded-For":   "10.1.2.3",
+				"X-Forwarded-Proto": "http",
+				"Forwarded":         "for=10.1.2.3;by=_lb1;host=\"shop.example:8080\";proto=http",
+			},
+		},
+		{
+			name: "адрес IPv6 в кавычках", remote: "[2001:db8::1]:4000",
+			want: map[string]string{
+				"X-Forwarded-For": "2001:db8::1",
+				"Forwarded":       "for=\"[2001:db8::1]\";by=_lb1;host=\"shop.example:8080\";proto=http",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := *forwarding
+			cfg.Mode = tt.mode
+			p := &Proxy{trusted: trusted, forwarding: &cfg}
+
+			r := incoming(tt.remote)
+			out := r.Header.Clone()
+			p.setForwardedHeaders(out, r)
+			for name, want := range tt.want {
+				if got := out.Get(name); got != want {
+					t.Errorf("%s = %q, ожидалось %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSetForwardedHeaders_WithoutForwarded(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Forwarded", "for=203.0.113.7")
+	out := r.Header.Clone()
+
+	(&Proxy{}).setForwardedHeaders(out, r)
+	if out.Get("Forwarded") != "" {
+		t.Error("заголовок Forwarded недоверенного отправителя должен удаляться")
+	}
+	if out.Get("X-Forwarded-Port") != "80" || out.Get("X-Forwarded-Proto") != "http" {
+		t.Errorf("неверные заголовки X-Forwarded-*: %v", out)
+	}
+}
@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/geoip"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+)
+
+// geoParams параметры middleware geo
+type geoParams struct {
+	// Разрешенные страны (коды ISO 3166-1 alpha-2) и автономные системы; если список задан,
+	// запросы из остальных, в том числе из неизвестных, отклоняются
+	AllowCountries []string `yaml:"allowCountries"`
+	AllowASNs      []uint32 `yaml:"allowASNs"`
+
+	// Запрещенные страны и автономные системы
+	DenyCountries []string `yaml:"denyCountries"`
+	DenyASNs      []uint32 `yaml:"denyASNs"`
+}
+
+// geoFilter отклоняет с 403 запросы по стране или автономной системе клиента,
+// определенным по базам GeoIP
+type geoFilter struct {
+	allowCountries map[string]bool
+	allowASNs      map[uint32]bool
+	denyCountries  map[string]bool
+	denyASNs       map[uint32]bool
+	logger         logger.Logger
+}
+
+// newGeoMiddleware создает middleware фильтрации по географии клиента
+func newGeoMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params geoParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	if len(params.AllowCountries)+len(params.AllowASNs)+len(params.DenyCountries)+len(params.DenyASNs) == 0 {
+		return nil, fmt.Errorf("at least one allow or deny list is required")
+	}
+
+	countries := func(codes []string) map[string]bool {
+		set := make(map[string]bool, len(codes))
+		for _, code := range codes {
+			set[strings.ToUpper(code)] = true
+		}
+		return set
+	}
+	asns := func(numbers []uint32) map[uint32]bool {
+		set := make(map[uint32]bool, len(numbers))
+		for _, asn := range numbers {
+			set[asn] = true
+		}
+		return set
+	}
+
+	f := &geoFilter{
+		allowCountries: countries(params.AllowCountries),
+		allowASNs:      asns(params.AllowASNs),
+		denyCountries:  countries(params.DenyCountries),
+		denyASNs:       asns(params.DenyASNs),
+		logger:         appLogger,
+	}
+	return f.middleware, nil
+}
+
+func (f *geoFilter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := geoInfo(r)
+		if !f.allowed(info) {
+			f.logger.Debug(fmt.Sprintf("Запрос %s %s от %s (страна: %q, AS%d) отклонен по географии", r.Method, r.URL.Path, r.RemoteAddr, info.Country, info.ASN))
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// geoInfo возвращает географические данные клиента, определенные прокси
+func geoInfo(r *http.Request) geoip.Info {
+	if req := request.FromContext(r.Context()); req != nil {
+		return req.GetGeo()
+	}
+	return geoip.Info{}
+}
+
+// allowed проверяет страну и автономную систему клиента по спискам.
+// Запрет имеет приоритет; при заданных разрешающих списках клиент должен попасть хотя бы в один.
+func (f *geoFilter) allowed(info geoip.Info) bool {
+	if f.denyCountries[info.Country] || f.denyASNs[info.ASN] {
+		return false
+	}
+	if len(f.allowCountries) == 0 && len(f.allowASNs) == 0 {
+		return true
+	}
+	return f.allowCountries[info.Country] || f.allowASNs[info.ASN]
+}
@@ -0,0 +1,43 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/geoip"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+)
+
+func TestGeoMiddleware(t *testing.T) {
+	m, err := newGeoMiddleware(config.MiddlewareConfig{Name: "geo", Params: map[string]interface{}{
+		"allowCountries": []interface{}{"ru", "KZ"},
+		"denyASNs":       []interface{}{64500},
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatalf("middleware должен создаваться: %v", err)
+	}
+	handler := m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tc := range []struct {
+		info geoip.Info
+		code int
+	}{
+		{geoip.Info{Country: "RU", ASN: 13238}, http.StatusOK},
+		{geoip.Info{Country: "KZ"}, http.StatusOK},
+		{geoip.Info{Country: "US"}, http.StatusForbidden},
+		{geoip.Info{Country: "RU", ASN: 64500}, http.StatusForbidden},
+		{geoip.Info{}, http.StatusForbidden},
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		customReq := request.NewRequest(req, nil)
+		request.Set(customReq, request.KeyGeo, tc.info)
+		handler.ServeHTTP(rec, req.WithContext(request.NewContext(req.Context(), customReq)))
+		if rec.Code != tc.code {
+			t.Errorf("%+v: ожидался статус %d, получен %d", tc.info, tc.code, rec.Code)
+		}
+	}
+}
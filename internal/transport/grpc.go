@@ -0,0 +1,597 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/transcode"
+	"cloud.ru_test/pkg/logger"
+)
+
+// grpcTranscodeParams параметры middleware grpcTranscode
+type grpcTranscodeParams struct {
+	// Скомпилированный набор дескрипторов (protoc --include_imports --descriptor_set_out);
+	// обязателен для запросов REST/JSON
+	DescriptorSet string `yaml:"descriptorSet"`
+
+	// Принимать запросы gRPC-Web (по умолчанию true)
+	GRPCWeb *bool `yaml:"grpcWeb"`
+
+	// Принимать запросы REST/JSON по аннотациям google.api.http и POST /пакет.Сервис/Метод (по умолчанию true)
+	JSON *bool `yaml:"json"`
+
+	// Максимальный размер тела запроса и ответа REST/JSON (по умолчанию 1MB)
+	MaxBodySize int64 `yaml:"maxBodySize"`
+}
+
+// grpcTranscoder преобразует запросы браузеров (gRPC-Web и REST/JSON) в вызовы gRPC бэкендов.
+// Бэкенды gRPC должны принимать HTTP/2 (protocol: h2c или https).
+type grpcTranscoder struct {
+	grpcWeb     bool
+	registry    *transcode.Registry
+	maxBodySize int64
+	logger      logger.Logger
+}
+
+// newGRPCTranscodeMiddleware создает middleware преобразования запросов в gRPC
+func newGRPCTranscodeMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params grpcTranscodeParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	g, err := newGRPCTranscoder(params, appLogger)
+	if err != nil {
+		return nil, err
+	}
+	return g.middleware, nil
+}
+
+// newGRPCTranscoder загружает набор дескрипторов
+func newGRPCTranscoder(params grpcTranscodeParams, appLogger logger.Logger) (*grpcTranscoder, error) {
+	g := &grpcTranscoder{
+		grpcWeb:     params.GRPCWeb == nil || *params.GRPCWeb,
+		maxBodySize: params.MaxBodySize,
+		logger:      appLogger,
+	}
+	if g.maxBodySize <= 0 {
+		g.maxBodySize = defaultMaxFilterBodySize
+	}
+
+	if params.JSON == nil || *params.JSON {
+		if params.DescriptorSet == "" {
+			if params.JSON != nil {
+				return nil, fmt.Errorf("grpcTranscode: descriptorSet is required for JSON transcoding")
+			}
+		} else {
+			registry, err := transcode.Load(params.DescriptorSet)
+			if err != nil {
+				return nil, fmt.Errorf("grpcTranscode: failed to load descriptor set: %w", err)
+			}
+			g.registry = registry
+		}
+	}
+	if !g.grpcWeb && g.registry == nil {
+		return nil, fmt.Errorf("grpcTranscode: both gRPC-Web and JSON transcoding are disabled")
+	}
+	return g, nil
+}
+
+func (g *grpcTranscoder) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		switch {
+		case g.grpcWeb && strings.HasPrefix(contentType, "application/grpc-web"):
+			g.serveGRPCWeb(w, r, next)
+		case g.registry != nil && !strings.HasPrefix(contentType, "application/grpc"):
+			method, rule, vars := g.match(r)
+			if method == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			g.serveJSON(w, r, next, method, rule, vars)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// match находит метод gRPC для запроса REST/JSON: по правилам google.api.http
+// или по пути вызова gRPC для POST запросов
+func (g *grpcTranscoder) match(r *http.Request) (*transcode.Method, *transcode.HTTPRule, map[string]string) {
+	if rule, vars := g.registry.Match(r.Method, r.URL.EscapedPath()); rule != nil {
+		return rule.Method, rule, vars
+	}
+	if r.Method == http.MethodPost {
+		if method := g.registry.Method(r.URL.Path); method != nil {
+			return method, &transcode.HTTPRule{Method: method, HTTPMethod: http.MethodPost, Body: "*"}, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// serveGRPCWeb передает запрос gRPC-Web бэкенду как запрос gRPC и преобразует ответ:
+// трейлеры gRPC передаются клиенту последним кадром тела ответа
+func (g *grpcTranscoder) serveGRPCWeb(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	contentType := r.Header.Get("Content-Type")
+	text := strings.HasPrefix(contentType, "application/grpc-web-text")
+	suffix := strings.TrimPrefix(strings.TrimPrefix(contentType, "application/grpc-web-text"), "application/grpc-web")
+
+	out := r.Clone(r.Context())
+	out.Header.Set("Content-Type", "application/grpc"+suffix)
+	out.Header.Set("TE", "trailers")
+	out.Header.Del("X-Grpc-Web")
+	if text {
+		// В текстовом режиме тело — base64; gRPC-Web не поддерживает потоки от клиента,
+		// поэтому запрос читается целиком
+		raw, err := io.ReadAll(io.LimitReader(r.Body, g.maxBodySize*4/3+4))
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		body, err := decodeGRPCWebText(raw)
+		if err != nil {
+			g.logger.Debug(fmt.Sprintf("Некорректное тело запроса gRPC-Web %s: %v", r.URL.Path, err))
+			http.Error(w, "Invalid grpc-web-text body", http.StatusBadRequest)
+			return
+		}
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		out.ContentLength = int64(len(body))
+		out.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	g.logger.Debug(fmt.Sprintf("Запрос gRPC-Web %s передается бэкенду как gRPC", r.URL.Path))
+	gw := &grpcWebWriter{ResponseWriter: w, text: text}
+	next.ServeHTTP(gw, out)
+	gw.finish()
+}
+
+// decodeGRPCWebText декодирует тело gRPC-Web в base64. Клиент может передать несколько
+// сегментов base64 подряд, каждый со своим дополнением.
+func decodeGRPCWebText(raw []byte) ([]byte, error) {
+	encoded := bytes.Join(bytes.Fields(raw), nil)
+	if len(encoded)%4 != 0 {
+		return nil, fmt.Errorf("invalid base64 length %d", len(encoded))
+	}
+	decoded := make([]byte, 0, len(encoded)/4*3)
+	chunk := make([]byte, 3)
+	for i := 0; i < len(encoded); i += 4 {
+		n, err := base64.StdEncoding.Decode(chunk, encoded[i:i+4])
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, chunk[:n]...)
+	}
+	return decoded, nil
+}
+
+// grpcWebWriter преобразует ответ gRPC бэкенда в ответ gRPC-Web
+type grpcWebWriter struct {
+	http.ResponseWriter
+	text        bool
+	wroteHeader bool
+
+	// Статус ответа, полученного не от gRPC сервера (например, ошибки прокси); 0 — ответ gRPC
+	failed int
+
+	// Байты, не вошедшие в последнюю группу base64 текстового режима
+	carry []byte
+}
+
+func (w *grpcWebWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	header := w.Header()
+	contentType := header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/grpc") {
+		// Тело такого ответа клиенту не передается, статус сообщается трейлером gRPC
+		w.failed = statusCode
+		contentType = "application/grpc"
+		header.Del("Content-Encoding")
+		statusCode = http.StatusOK
+	}
+
+	webType := "application/grpc-web"
+	if w.text {
+		webType = "application/grpc-web-text"
+	}
+	header.Set("Content-Type", webType+strings.TrimPrefix(contentType, "application/grpc"))
+	header.Del("Content-Length")
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *grpcWebWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.failed != 0 {
+		return len(p), nil
+	}
+	if !w.text {
+		return w.ResponseWriter.Write(p)
+	}
+
+	data := append(w.carry, p...)
+	n := len(data) / 3 * 3
+	if n > 0 {
+		if _, err := w.ResponseWriter.Write([]byte(base64.StdEncoding.EncodeToString(data[:n]))); err != nil {
+			return 0, err
+		}
+	}
+	w.carry = append([]byte(nil), data[n:]...)
+	return len(p), nil
+}
+
+// Unwrap позволяет http.ResponseController использовать исходный ResponseWriter
+func (w *grpcWebWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish дописывает кадр с трейлерами gRPC
+func (w *grpcWebWriter) finish() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	header := w.Header()
+	trailer := make(http.Header)
+	for name, values := range header {
+		if key, ok := strings.CutPrefix(name, http.TrailerPrefix); ok {
+			trailer[http.CanonicalHeaderKey(key)] = values
+			delete(header, name)
+		}
+	}
+
+	switch {
+	case w.failed != 0:
+		trailer.Set("Grpc-Status", strconv.Itoa(transcode.StatusCode(w.failed)))
+		trailer.Set("Grpc-Message", http.StatusText(w.failed))
+	case len(trailer) == 0 && header.Get("Grpc-Status") != "":
+		// Ответ без сообщений: статус уже передан в заголовках
+		trailer = nil
+	case trailer.Get("Grpc-Status") == "":
+		trailer.Set("Grpc-Status", strconv.Itoa(transcode.CodeUnknown))
+		trailer.Set("Grpc-Message", "missing grpc-status")
+	}
+
+	var frame []byte
+	if trailer != nil {
+		names := make([]string, 0, len(trailer))
+		for name := range trailer {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var payload []byte
+		for _, name := range names {
+			for _, value := range trailer[name] {
+				payload = append(payload, strings.ToLower(name)+": "+value+"\r\n"...)
+			}
+		}
+		frame = transcode.AppendFrame(nil, transcode.FlagTrailer, payload)
+	}
+
+	if w.text {
+		// Остаток тела и кадр трейлеров кодируются с дополнением base64
+		frame = []byte(base64.StdEncoding.EncodeToString(append(w.carry, frame...)))
+		w.carry = nil
+	}
+	if len(frame) > 0 {
+		w.ResponseWriter.Write(frame)
+	}
+}
+
+// serveJSON преобразует запрос REST/JSON в вызов метода gRPC и ответ gRPC в JSON
+func (g *grpcTranscoder) serveJSON(w http.ResponseWriter, r *http.Request, next http.Handler, method *transcode.Method, rule *transcode.HTTPRule, vars map[string]string) {
+	if method.ClientStreaming {
+		writeGRPCError(w, http.StatusNotImplemented, transcode.CodeUnimplemented, "client streaming methods are not supported")
+		return
+	}
+
+	payload, err := g.buildMessage(r, method, rule, vars)
+	if err != nil {
+		g.logger.Debug(fmt.Sprintf("Не удалось построить сообщение %s для %s %s: %v", method.Input.FullName, r.Method, r.URL.Path, err))
+		status := http.StatusBadRequest
+		var tooLarge *bodyTooLargeError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeGRPCError(w, status, transcode.CodeInvalidArgument, err.Error())
+		return
+	}
+
+	body := transcode.AppendFrame(nil, 0, payload)
+	out := r.Clone(r.Context())
+	out.Method = http.MethodPost
+	out.URL.Path, out.URL.RawPath, out.URL.RawQuery = method.Path, "", ""
+	out.Header.Set("Content-Type", "application/grpc")
+	out.Header.Set("TE", "trailers")
+	out.Header.Del("Content-Encoding")
+	out.Header.Del("Accept-Encoding")
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	out.Header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	g.logger.Debug(fmt.Sprintf("Запрос %s %s преобразован в вызов gRPC %s", r.Method, r.URL.Path, method.Path))
+	rec := &grpcResponseRecorder{header: make(http.Header), limit: g.maxBodySize}
+	next.ServeHTTP(rec, out)
+
+	status, result, err := g.buildResponse(rec, method, rule)
+	if err != nil {
+		g.logger.Debug(fmt.Sprintf("Не удалось преобразовать ответ %s в JSON: %v", method.Path, err))
+		writeGRPCError(w, http.StatusBadGateway, transcode.CodeInternal, err.Error())
+		return
+	}
+	if result == nil {
+		// Ответ не от gRPC сервера (например, ошибка прокси) передается без изменений
+		writeRecordedResponse(w, rec)
+		return
+	}
+
+	for name, values := range rec.header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "grpc-") || strings.HasPrefix(name, http.TrailerPrefix) || lower == "content-type" || lower == "content-length" {
+			continue
+		}
+		w.Header()[name] = values
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(result)))
+	w.WriteHeader(status)
+	w.Write(result)
+}
+
+// bodyTooLargeError тело запроса больше maxBodySize
+type bodyTooLargeError struct {
+	limit int64
+}
+
+func (e *bodyTooLargeError) Error() string {
+	return fmt.Sprintf("request body exceeds %d bytes", e.limit)
+}
+
+// buildMessage строит сообщение запроса из тела, переменных пути и параметров query
+func (g *grpcTranscoder) buildMessage(r *http.Request, method *transcode.Method, rule *transcode.HTTPRule, vars map[string]string) ([]byte, error) {
+	msg := make(map[string]interface{})
+
+	if rule.Body != "" && r.Body != nil {
+		raw, err := io.ReadAll(io.LimitReader(r.Body, g.maxBodySize+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		if int64(len(raw)) > g.maxBodySize {
+			return nil, &bodyTooLargeError{limit: g.maxBodySize}
+		}
+		if len(bytes.TrimSpace(raw)) > 0 {
+			if contentType := r.Header.Get("Content-Type"); contentType != "" && !isJSON(contentType) {
+				return nil, fmt.Errorf("unsupported content type %s", contentType)
+			}
+			var body interface{}
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.UseNumber()
+			if err := decoder.Decode(&body); err != nil {
+				return nil, fmt.Errorf("invalid JSON request body: %w", err)
+			}
+			if rule.Body == "*" {
+				object, ok := body.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("request body must be a JSON object")
+				}
+				msg = object
+			} else if err := setMessageField(msg, method.Input, rule.Body, body); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for name, value := range vars {
+		if err := setMessageField(msg, method.Input, name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	// Параметры query заполняют поля, не переданные в теле и пути; неизвестные параметры пропускаются
+	if rule.Body != "*" {
+		for name, values := range r.URL.Query() {
+			fd := messageField(method.Input, name)
+			if fd == nil || vars[name] != "" || (rule.Body != "" && strings.HasPrefix(name+".", rule.Body+".")) {
+				continue
+			}
+			var value interface{} = values[len(values)-1]
+			if fd.Repeated {
+				items := make([]interface{}, len(values))
+				for i, v := range values {
+					items[i] = v
+				}
+				value = items
+			}
+			if err := setMessageField(msg, method.Input, name, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return method.Input.FromJSON(msg)
+}
+
+// messageField возвращает поле по пути через точку (например, book.author.name)
+func messageField(m *transcode.Message, path string) *transcode.Field {
+	var fd *transcode.Field
+	for _, name := range strings.Split(path, ".") {
+		if m == nil {
+			return nil
+		}
+		if fd = m.FieldByName(name); fd == nil {
+			return nil
+		}
+		m = fd.Message
+	}
+	return fd
+}
+
+// setMessageField записывает значение в объект сообщения по пути поля через точку
+func setMessageField(msg map[string]interface{}, m *transcode.Message, path string, value interface{}) error {
+	if messageField(m, path) == nil {
+		return fmt.Errorf("unknown field %s of %s", path, m.FullName)
+	}
+	names := strings.Split(path, ".")
+	for _, name := range names[:len(names)-1] {
+		nested, ok := msg[name].(map[string]interface{})
+		if !ok {
+			nested = make(map[string]interface{})
+			msg[name] = nested
+		}
+		msg = nested
+	}
+	msg[names[len(names)-1]] = value
+	return nil
+}
+
+// buildResponse преобразует ответ gRPC в HTTP статус и тело JSON. Для ответа,
+// полученного не от gRPC сервера, возвращает nil тело.
+func (g *grpcTranscoder) buildResponse(rec *grpcResponseRecorder, method *transcode.Method, rule *transcode.HTTPRule) (int, []byte, error) {
+	if rec.overflow {
+		return 0, nil, fmt.Errorf("response exceeds %d bytes", g.maxBodySize)
+	}
+
+	grpcStatus := rec.header.Get(http.TrailerPrefix + "Grpc-Status")
+	grpcMessage := rec.header.Get(http.TrailerPrefix + "Grpc-Message")
+	if grpcStatus == "" {
+		grpcStatus, grpcMessage = rec.header.Get("Grpc-Status"), rec.header.Get("Grpc-Message")
+	}
+	if !strings.HasPrefix(rec.header.Get("Content-Type"), "application/grpc") && grpcStatus == "" {
+		return 0, nil, nil
+	}
+
+	code, err := strconv.Atoi(grpcStatus)
+	if err != nil {
+		return 0, nil, fmt.Errorf("missing or invalid grpc-status %q", grpcStatus)
+	}
+	if code != transcode.CodeOK {
+		if unescaped, err := url.PathUnescape(grpcMessage); err == nil {
+			grpcMessage = unescaped
+		}
+		return transcode.HTTPStatus(code), grpcErrorBody(code, grpcMessage), nil
+	}
+
+	frames, err := transcode.ParseFrames(rec.body.Bytes())
+	if err != nil {
+		return 0, nil, err
+	}
+	messages := make([]json.RawMessage, 0, len(frames))
+	for _, frame := range frames {
+		if frame.Flags&transcode.FlagCompressed != 0 {
+			return 0, nil, fmt.Errorf("compressed gRPC messages are not supported")
+		}
+		data, err := method.Output.ToJSON(frame.Payload)
+		if err != nil {
+			return 0, nil, err
+		}
+		if rule.ResponseBody != "" {
+			if data, err = responseField(data, method.Output, rule.ResponseBody); err != nil {
+				return 0, nil, err
+			}
+		}
+		messages = append(messages, data)
+	}
+
+	// Потоковый ответ передается массивом сообщений
+	if method.ServerStreaming {
+		data, err := json.Marshal(messages)
+		return http.StatusOK, data, err
+	}
+	if len(messages) != 1 {
+		return 0, nil, fmt.Errorf("expected one response message, got %d", len(messages))
+	}
+	return http.StatusOK, messages[0], nil
+}
+
+// responseField возвращает поле ответа, указанное в response_body правила
+func responseField(data []byte, m *transcode.Message, name string) ([]byte, error) {
+	fd := m.FieldByName(name)
+	if fd == nil {
+		return nil, fmt.Errorf("unknown response field %s of %s", name, m.FullName)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if value, ok := fields[fd.JSONName]; ok {
+		return value, nil
+	}
+	return []byte("null"), nil
+}
+
+// grpcErrorBody возвращает тело ответа с ошибкой в формате google.rpc.Status
+func grpcErrorBody(code int, message string) []byte {
+	data, _ := json.Marshal(struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{code, message})
+	return data
+}
+
+// writeGRPCError отправляет клиенту REST/JSON ошибку с кодом gRPC
+func writeGRPCError(w http.ResponseWriter, status, code int, message string) {
+	body := grpcErrorBody(code, message)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// writeRecordedResponse отправляет клиенту буферизованный ответ без изменений
+func writeRecordedResponse(w http.ResponseWriter, rec *grpcResponseRecorder) {
+	for name, values := range rec.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(rec.status())
+	w.Write(rec.body.Bytes())
+}
+
+// grpcResponseRecorder буферизует ответ gRPC. Ответ больше лимита не сохраняется.
+type grpcResponseRecorder struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+	limit      int64
+	overflow   bool
+}
+
+func (r *grpcResponseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *grpcResponseRecorder) WriteHeader(statusCode int) {
+	if r.statusCode == 0 {
+		r.statusCode = statusCode
+	}
+}
+
+func (r *grpcResponseRecorder) Write(p []byte) (int, error) {
+	if r.overflow || int64(r.body.Len()+len(p)) > r.limit {
+		r.overflow = true
+		r.body.Reset()
+		return len(p), nil
+	}
+	return r.body.Write(p)
+}
+
+// status возвращает записанный статус ответа (по умолчанию 200)
+func (r *grpcResponseRecorder) status() int {
+	if r.statusCode == 0 {
+		return http.StatusOK
+	}
+	return r.statusCode
+}
@@ -0,0 +1,198 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cloud.ru_test/internal/transcode"
+	"cloud.ru_test/pkg/logger"
+)
+
+// protoField дописывает поле protobuf с префиксом длины
+func protoField(b []byte, number int, value []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(number)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// protoVarint дописывает поле protobuf varint
+func protoVarint(b []byte, number int, value byte) []byte {
+	return append(b, byte(number<<3), value)
+}
+
+// writeTestDescriptorSet сохраняет набор дескрипторов сервиса
+//
+//	package books;
+//	service Books {
+//	  rpc GetBook(GetBookRequest) returns (Book) { option (google.api.http) = { get: "/v1/{name=books/*}" }; }
+//	}
+//	message GetBookRequest { string name = 1; }
+//	message Book { string name = 1; int64 pages = 2; }
+func writeTestDescriptorSet(t *testing.T) string {
+	field := func(name string, number, kind byte) []byte {
+		f := protoField(nil, 1, []byte(name))
+		f = protoVarint(f, 3, number)
+		f = protoVarint(f, 4, 1)
+		return protoVarint(f, 5, kind)
+	}
+	request := protoField(protoField(nil, 1, []byte("GetBookRequest")), 2, field("name", 1, 9))
+	book := protoField(protoField(protoField(nil, 1, []byte("Book")), 2, field("name", 1, 9)), 2, field("pages", 2, 3))
+
+	// Расширение google.api.http (номер 72295728) в MethodOptions
+	options := protoField(nil, 72295728, protoField(nil, 2, []byte("/v1/{name=books/*}")))
+	method := protoField(nil, 1, []byte("GetBook"))
+	method = protoField(method, 2, []byte(".books.GetBookRequest"))
+	method = protoField(method, 3, []byte(".books.Book"))
+	method = protoField(method, 4, options)
+	service := protoField(protoField(nil, 1, []byte("Books")), 2, method)
+
+	file := protoField(nil, 1, []byte("books.proto"))
+	file = protoField(file, 2, []byte("books"))
+	file = protoField(file, 4, request)
+	file = protoField(file, 4, book)
+	file = protoField(file, 6, service)
+	file = protoField(file, 12, []byte("proto3"))
+
+	path := filepath.Join(t.TempDir(), "books.pb")
+	if err := os.WriteFile(path, protoField(nil, 1, file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// grpcBackend имитирует ответ сервера gRPC так, как его передает клиенту этап proxy:
+// трейлеры записываются в заголовки с префиксом http.TrailerPrefix после тела
+func grpcBackend(t *testing.T, status string, messages ...[]byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") || r.Header.Get("TE") != "trailers" {
+			t.Errorf("бэкенд получил не запрос gRPC: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		frames, err := transcode.ParseFrames(body)
+		if err != nil || len(frames) != 1 {
+			t.Errorf("неверные кадры запроса: %v %v", frames, err)
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("X-Request-Path", r.URL.Path)
+		w.Header().Set("X-Request-Message", base64.StdEncoding.EncodeToString(frames[0].Payload))
+		w.WriteHeader(http.StatusOK)
+		for _, m := range messages {
+			w.Write(transcode.AppendFrame(nil, 0, m))
+		}
+		w.Header()[http.TrailerPrefix+"Grpc-Status"] = []string{status}
+		if status != "0" {
+			w.Header()[http.TrailerPrefix+"Grpc-Message"] = []string{"book%20not%20found"}
+		}
+	})
+}
+
+func TestGRPCTranscodeJSON(t *testing.T) {
+	g, err := newGRPCTranscoder(grpcTranscodeParams{DescriptorSet: writeTestDescriptorSet(t)}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	book := protoVarint(protoField(nil, 1, []byte("books/1")), 2, 42)
+	rec := httptest.NewRecorder()
+	g.middleware(grpcBackend(t, "0", book)).ServeHTTP(rec, httptest.NewRequest("GET", "/v1/books/1", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"name":"books/1","pages":"42"}` {
+		t.Errorf("неверный ответ: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Request-Path") != "/books.Books/GetBook" {
+		t.Errorf("неверный путь вызова gRPC: %s", rec.Header().Get("X-Request-Path"))
+	}
+	if want := base64.StdEncoding.EncodeToString(protoField(nil, 1, []byte("books/1"))); rec.Header().Get("X-Request-Message") != want {
+		t.Errorf("переменная пути не передана в сообщение запроса")
+	}
+	if rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get(http.TrailerPrefix+"Grpc-Status") != "" {
+		t.Errorf("неверные заголовки ответа: %v", rec.Header())
+	}
+
+	// Статус gRPC преобразуется в статус HTTP
+	rec = httptest.NewRecorder()
+	g.middleware(grpcBackend(t, "5")).ServeHTTP(rec, httptest.NewRequest("GET", "/v1/books/2", nil))
+	if rec.Code != http.StatusNotFound || rec.Body.String() != `{"code":5,"message":"book not found"}` {
+		t.Errorf("неверный ответ с ошибкой: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Вызов по пути gRPC с телом JSON
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/books.Books/GetBook", strings.NewReader(`{"name":"books/1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	g.middleware(grpcBackend(t, "0", book)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("вызов по пути gRPC: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Некорректный JSON отклоняется до обращения к бэкенду
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/books.Books/GetBook", strings.NewReader(`{"title":"x"}`))
+	g.middleware(http.NotFoundHandler()).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("неизвестное поле: ожидался статус 400, получен %d", rec.Code)
+	}
+
+	// Запросы вне правил передаются дальше без изменений
+	rec = httptest.NewRecorder()
+	g.middleware(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest("GET", "/v2/books/1", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("запрос вне правил: %d", rec.Code)
+	}
+}
+
+func TestGRPCWeb(t *testing.T) {
+	g, err := newGRPCTranscoder(grpcTranscodeParams{}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := protoField(nil, 1, []byte("books/1"))
+	frame := transcode.AppendFrame(nil, 0, message)
+	trailer := transcode.AppendFrame(nil, transcode.FlagTrailer, []byte("grpc-status: 0\r\n"))
+
+	// Бинарный режим: тело передается как есть, трейлеры — последним кадром
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/books.Books/GetBook", bytes.NewReader(frame))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	g.middleware(grpcBackend(t, "0", message)).ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Type") != "application/grpc-web" {
+		t.Errorf("неверный Content-Type: %s", rec.Header().Get("Content-Type"))
+	}
+	if want := append(append([]byte(nil), frame...), trailer...); !bytes.Equal(rec.Body.Bytes(), want) {
+		t.Errorf("неверное тело ответа gRPC-Web: %q", rec.Body.Bytes())
+	}
+
+	// Текстовый режим: запрос и ответ в base64
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/books.Books/GetBook", strings.NewReader(base64.StdEncoding.EncodeToString(frame)))
+	req.Header.Set("Content-Type", "application/grpc-web-text")
+	g.middleware(grpcBackend(t, "0", message)).ServeHTTP(rec, req)
+	body, err := decodeGRPCWebText(rec.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(append([]byte(nil), frame...), trailer...); !bytes.Equal(body, want) {
+		t.Errorf("неверное тело ответа gRPC-Web text: %q", body)
+	}
+
+	// Ошибка прокси передается статусом gRPC
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/books.Books/GetBook", bytes.NewReader(frame))
+	req.Header.Set("Content-Type", "application/grpc-web")
+	g.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+	})).ServeHTTP(rec, req)
+	frames, err := transcode.ParseFrames(rec.Body.Bytes())
+	if rec.Code != http.StatusOK || err != nil || len(frames) != 1 || !strings.Contains(string(frames[0].Payload), "grpc-status: 14") {
+		t.Errorf("неверный ответ при ошибке прокси: %d %q", rec.Code, rec.Body.Bytes())
+	}
+}
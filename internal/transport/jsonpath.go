@@ -0,0 +1,175 @@
+package transport
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPathSegment шаг выражения JSONPath
+type jsonPathSegment struct {
+	// Имя поля объекта (пустое для индекса и *)
+	key string
+
+	// Индекс элемента массива (-1, если шаг не индекс)
+	index int
+
+	// Все поля объекта или элементы массива (*)
+	wildcard bool
+
+	// Шаг применяется к узлу и всем его потомкам (..)
+	recursive bool
+}
+
+// jsonPath выражение JSONPath, указывающее на поля объектов. Поддерживается
+// подмножество синтаксиса: $.a.b, $['a b'], $.items[0], $.items[*].id, $..password.
+type jsonPath struct {
+	expr     string
+	segments []jsonPathSegment
+}
+
+// jsonField поле объекта, найденное выражением
+type jsonField struct {
+	object map[string]interface{}
+	key    string
+}
+
+// parseJSONPath разбирает выражение. Последний шаг должен указывать на поле объекта.
+func parseJSONPath(expr string) (*jsonPath, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("JSONPath %q must start with $", expr)
+	}
+
+	p := &jsonPath{expr: expr}
+	rest := expr[1:]
+	for rest != "" {
+		segment := jsonPathSegment{index: -1}
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			segment.recursive = true
+			rest = rest[2:]
+		case rest[0] == '.':
+			rest = rest[1:]
+		case rest[0] != '[':
+			return nil, fmt.Errorf("JSONPath %q: unexpected %q", expr, rest)
+		}
+
+		switch {
+		case strings.HasPrefix(rest, "["):
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q: unclosed [", expr)
+			}
+			selector := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case selector == "*":
+				segment.wildcard = true
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				segment.key = selector[1 : len(selector)-1]
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("JSONPath %q: invalid selector [%s]", expr, selector)
+				}
+				segment.index = index
+			}
+		case strings.HasPrefix(rest, "*"):
+			segment.wildcard = true
+			rest = rest[1:]
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			segment.key = rest[:end]
+			rest = rest[end:]
+			if segment.key == "" {
+				return nil, fmt.Errorf("JSONPath %q: empty field name", expr)
+			}
+		}
+		p.segments = append(p.segments, segment)
+	}
+
+	if len(p.segments) == 0 {
+		return nil, fmt.Errorf("JSONPath %q must select a field", expr)
+	}
+	if last := p.segments[len(p.segments)-1]; last.key == "" {
+		return nil, fmt.Errorf("JSONPath %q must end with a field name", expr)
+	}
+	return p, nil
+}
+
+// fields возвращает поля объектов документа doc, на которые указывает выражение
+func (p *jsonPath) fields(doc interface{}) []jsonField {
+	var found []jsonField
+	p.walk(doc, p.segments, &found)
+	return found
+}
+
+// walk проходит шаги segments начиная с узла node
+func (p *jsonPath) walk(node interface{}, segments []jsonPathSegment, found *[]jsonField) {
+	segment := segments[0]
+	if segment.recursive {
+		segment.recursive = false
+		rest := append([]jsonPathSegment{segment}, segments[1:]...)
+		for _, n := range descendants(node) {
+			p.walk(n, rest, found)
+		}
+		return
+	}
+
+	if len(segments) == 1 {
+		if object, ok := node.(map[string]interface{}); ok {
+			if _, exists := object[segment.key]; exists {
+				*found = append(*found, jsonField{object: object, key: segment.key})
+			}
+		}
+		return
+	}
+
+	for _, child := range children(node, segment) {
+		p.walk(child, segments[1:], found)
+	}
+}
+
+// children возвращает дочерние узлы, выбранные шагом
+func children(node interface{}, segment jsonPathSegment) []interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if segment.wildcard {
+			result := make([]interface{}, 0, len(n))
+			for _, v := range n {
+				result = append(result, v)
+			}
+			return result
+		}
+		if v, ok := n[segment.key]; ok && segment.index < 0 {
+			return []interface{}{v}
+		}
+	case []interface{}:
+		if segment.wildcard {
+			return n
+		}
+		if segment.index >= 0 && segment.index < len(n) {
+			return []interface{}{n[segment.index]}
+		}
+	}
+	return nil
+}
+
+// descendants возвращает узел и всех его потомков
+func descendants(node interface{}) []interface{} {
+	result := []interface{}{node}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for _, v := range n {
+			result = append(result, descendants(v)...)
+		}
+	case []interface{}:
+		for _, v := range n {
+			result = append(result, descendants(v)...)
+		}
+	}
+	return result
+}
@@ -0,0 +1,252 @@
+package transport
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval ключи подписи перечитываются при неизвестном kid не чаще раза в минуту
+const jwksRefreshInterval = time.Minute
+
+// idTokenClaims утверждения ID токена OIDC
+type idTokenClaims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	Expires           int64    `json:"exp"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+// audience утверждение aud: строка или массив строк
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a audience) contains(value string) bool {
+	for _, v := range a {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyJWT проверяет подпись JWT (RS256/384/512, ES256/384/512) ключом, который
+// возвращает keyFunc по kid из заголовка, и декодирует утверждения в claims
+func verifyJWT(token string, keyFunc func(kid string) (interface{}, error), claims interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed jwt")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return fmt.Errorf("invalid jwt header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("invalid jwt signature encoding: %w", err)
+	}
+
+	var h hash.Hash
+	var hashID crypto.Hash
+	switch header.Alg {
+	case "RS256", "ES256":
+		h, hashID = sha256.New(), crypto.SHA256
+	case "RS384", "ES384":
+		h, hashID = sha512.New384(), crypto.SHA384
+	case "RS512", "ES512":
+		h, hashID = sha512.New(), crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported jwt algorithm %q", header.Alg)
+	}
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+
+	key, err := keyFunc(header.Kid)
+	if err != nil {
+		return err
+	}
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg[0] != 'R' {
+			return fmt.Errorf("algorithm %s does not match RSA key", header.Alg)
+		}
+		if err := rsa.VerifyPKCS1v15(key, hashID, digest, signature); err != nil {
+			return fmt.Errorf("invalid jwt signature: %w", err)
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg[0] != 'E' {
+			return fmt.Errorf("algorithm %s does not match EC key", header.Alg)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid jwt signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid jwt signature")
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return fmt.Errorf("invalid jwt claims: %w", err)
+	}
+	return nil
+}
+
+// decodeSegment декодирует сегмент JWT в base64url
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// jwkSet набор открытых ключей провайдера (JWKS)
+type jwkSet struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	} `json:"keys"`
+}
+
+// jwks разобранные ключи подписи по kid
+type jwks struct {
+	keys    map[string]interface{}
+	fetched time.Time
+}
+
+// parse разбирает ключи подписи RSA и EC; ключи других типов и назначений пропускаются
+func (s jwkSet) parse() *jwks {
+	result := &jwks{keys: make(map[string]interface{}), fetched: time.Now()}
+	for _, k := range s.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				continue
+			}
+			result.keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			result.keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	return result
+}
+
+// jwksSource ключи подписи, загружаемые по адресу JWKS. Набор ключей перечитывается,
+// когда токен подписан неизвестным ключом, но не чаще jwksRefreshInterval.
+type jwksSource struct {
+	client *http.Client
+
+	mu   sync.Mutex
+	keys *jwks
+}
+
+// key возвращает ключ подписи kid из набора по адресу url
+func (s *jwksSource) key(ctx context.Context, url, kid string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.keys != nil {
+		if key, ok := s.keys.keys[kid]; ok {
+			return key, nil
+		}
+		if time.Since(s.keys.fetched) < jwksRefreshInterval {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+	}
+
+	var set jwkSet
+	if err := getJSON(ctx, s.client, url, &set); err != nil {
+		return nil, err
+	}
+	s.keys = set.parse()
+	if key, ok := s.keys.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// getJSON загружает и декодирует JSON документ
+func getJSON(ctx context.Context, client *http.Client, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", url, err)
+	}
+	return nil
+}
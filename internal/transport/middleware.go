@@ -0,0 +1,249 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Middleware оборачивает обработчик запросов прокси
+type Middleware func(next http.Handler) http.Handler
+
+// Phase этап конвейера обработки запроса. Конвейер выполняется в порядке
+// auth → ratelimit → rewrite → balance → proxy; этапы balance и proxy
+// реализует сам прокси, middleware встраиваются в предшествующие им этапы.
+type Phase int
+
+const (
+	// PhaseAuth аутентификация и авторизация клиента
+	PhaseAuth Phase = iota
+
+	// PhaseRateLimit ограничение частоты и объема запросов
+	PhaseRateLimit
+
+	// PhaseRewrite изменение запроса перед выбором бэкенда
+	PhaseRewrite
+)
+
+// String возвращает имя этапа
+func (p Phase) String() string {
+	switch p {
+	case PhaseAuth:
+		return "auth"
+	case PhaseRateLimit:
+		return "ratelimit"
+	case PhaseRewrite:
+		return "rewrite"
+	default:
+		return fmt.Sprintf("phase(%d)", int(p))
+	}
+}
+
+// MiddlewareFactory создает middleware по настройкам из секции middlewares
+type MiddlewareFactory func(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error)
+
+// registeredMiddleware фабрика middleware и этап, в который он встраивается
+type registeredMiddleware struct {
+	phase   Phase
+	factory MiddlewareFactory
+}
+
+var (
+	middlewaresMu sync.RWMutex
+	middlewares   = make(map[string]registeredMiddleware)
+)
+
+func init() {
+	RegisterMiddleware("headers", PhaseRewrite, newHeadersMiddleware)
+	RegisterMiddleware("extAuthz", PhaseAuth, newExtAuthzMiddleware)
+	RegisterMiddleware("oidc", PhaseAuth, newOIDCMiddleware)
+	RegisterMiddleware("waf", PhaseAuth, newWAFMiddleware)
+	RegisterMiddleware("geo", PhaseAuth, newGeoMiddleware)
+	RegisterMiddleware("botProtection", PhaseRateLimit, newBotMiddleware)
+	RegisterMiddleware("transform", PhaseRewrite, newTransformMiddleware)
+	RegisterMiddleware("grpcTranscode", PhaseRewrite, newGRPCTranscodeMiddleware)
+	RegisterMiddleware("static", PhaseRewrite, newStaticMiddleware)
+	RegisterMiddleware("faults", PhaseRewrite, newFaultMiddleware)
+	RegisterMiddleware("capture", PhaseRewrite, newCaptureMiddleware)
+	RegisterMiddleware("mock", PhaseRewrite, newMockMiddleware)
+	RegisterMiddleware("coalesce", PhaseRewrite, newCoalesceMiddleware)
+	RegisterMiddleware("cache", PhaseRewrite, newCacheMiddleware)
+	RegisterMiddleware("responseLimit", PhaseRewrite, newResponseLimitMiddleware)
+	RegisterMiddleware("requestEncoding", PhaseRewrite, newRequestEncodingMiddleware)
+	RegisterMiddleware("responseValidation", PhaseRewrite, newResponseValidationMiddleware)
+	RegisterMiddleware("securityHeaders", PhaseRewrite, newSecurityHeadersMiddleware)
+	RegisterMiddleware("cookieRewrite", PhaseRewrite, newCookieRewriteMiddleware)
+	RegisterMiddleware("affinity", PhaseRewrite, newAffinityMiddleware)
+}
+
+// RegisterMiddleware регистрирует middleware. Имя становится допустимым в секции
+// middlewares конфигурации, параметры передаются в params.
+func RegisterMiddleware(name string, phase Phase, factory MiddlewareFactory) {
+	middlewaresMu.Lock()
+	defer middlewaresMu.Unlock()
+
+	middlewares[name] = registeredMiddleware{phase: phase, factory: factory}
+	config.RegisterMiddlewareType(name)
+}
+
+// stage middleware, привязанный к этапу конвейера
+type stage struct {
+	name       string
+	phase      Phase
+	middleware Middleware
+}
+
+// Chain middleware из секции middlewares конфигурации. Создается до остальных
+// подсистем, чтобы ошибка в параметрах middleware не прерывала реконфигурацию на середине.
+type Chain struct {
+	stages []stage
+}
+
+// NewChain создает middleware по конфигурации
+func NewChain(configs []config.MiddlewareConfig, appLogger logger.Logger) (*Chain, error) {
+	chain := &Chain{}
+	for _, cfg := range configs {
+		m, phase, err := NewMiddleware(cfg, appLogger)
+		if err != nil {
+			return nil, err
+		}
+		chain.Add(cfg.Name, phase, m)
+	}
+	return chain, nil
+}
+
+// NewMiddleware создает зарегистрированный middleware по настройкам и возвращает этап,
+// в который он встраивается
+func NewMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, Phase, error) {
+	middlewaresMu.RLock()
+	registered, ok := middlewares[cfg.Name]
+	middlewaresMu.RUnlock()
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown middleware: %s", cfg.Name)
+	}
+
+	m, err := registered.factory(cfg, appLogger)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create middleware %s: %w", cfg.Name, err)
+	}
+	return m, registered.phase, nil
+}
+
+// Add добавляет в цепочку middleware m на этапе phase. Используется при встраивании
+// прокси в приложение, когда middleware задаются кодом, а не конфигурацией.
+func (c *Chain) Add(name string, phase Phase, m Middleware) {
+	c.stages = append(c.stages, stage{name: name, phase: phase, middleware: m})
+}
+
+// join возвращает цепочку из middleware c, за которыми следуют middleware other
+func (c *Chain) join(other *Chain) *Chain {
+	if other == nil {
+		return c
+	}
+	if c == nil {
+		return other
+	}
+	joined := &Chain{stages: make([]stage, 0, len(c.stages)+len(other.stages))}
+	joined.stages = append(joined.stages, c.stages...)
+	joined.stages = append(joined.stages, other.stages...)
+	return joined
+}
+
+// buildHandler собирает конвейер вокруг обработчика h: встроенный rate limit и middleware
+// цепочки chain выполняются в порядке этапов, внутри этапа — в порядке конфигурации
+func buildHandler(h http.Handler, limiter ratelimit.RateLimiter, chain *Chain, appLogger logger.Logger) http.Handler {
+	stages := []stage{{name: "ratelimit", phase: PhaseRateLimit, middleware: rateLimitMiddleware(limiter, appLogger)}}
+	if chain != nil {
+		stages = append(stages, chain.stages...)
+	}
+
+	sort.SliceStable(stages, func(i, j int) bool {
+		return stages[i].phase < stages[j].phase
+	})
+
+	// Оборачиваем с конца, чтобы первый middleware выполнялся первым
+	for i := len(stages) - 1; i >= 0; i-- {
+		h = stages[i].middleware(h)
+		appLogger.Debug(fmt.Sprintf("Middleware %s подключен к этапу %s", stages[i].name, stages[i].phase))
+	}
+	return h
+}
+
+// rateLimited счетчик запросов, отклоненных встроенным rate limiter'ом
+var rateLimited = metrics.Default.Counter("lb_ratelimit_rejected_total", "Requests rejected by the rate limiter")
+
+// rateLimitMiddleware ограничивает частоту запросов клиентов
+func rateLimitMiddleware(limiter ratelimit.RateLimiter, appLogger logger.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// проверяем даст ли токен
+			ip := clientIP(r)
+			if !limiter.Allow(ip) {
+				rateLimited.Inc()
+				if appLogger.DebugEnabled() {
+					appLogger.Debug(fmt.Sprintf("Превышен rate limit для %s", ip))
+				}
+				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			if appLogger.DebugEnabled() {
+				appLogger.Debug(fmt.Sprintf("Rate limit проверка пройдена для %s", ip))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// headersParams параметры middleware headers
+type headersParams struct {
+	// Заголовки, устанавливаемые в запросе
+	Set map[string]string `yaml:"set"`
+
+	// Заголовки, удаляемые из запроса
+	Remove []string `yaml:"remove"`
+}
+
+// newHeadersMiddleware создает middleware, изменяющий заголовки запроса перед проксированием
+func newHeadersMiddleware(cfg config.MiddlewareConfig, _ logger.Logger) (Middleware, error) {
+	var params headersParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	if len(params.Set) == 0 && len(params.Remove) == 0 {
+		return nil, fmt.Errorf("set or remove is required")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, name := range params.Remove {
+				r.Header.Del(name)
+			}
+			for name, value := range params.Set {
+				r.Header.Set(name, value)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// backendContextKey ключ выбранного бэкенда в контексте запроса
+type backendContextKey struct{}
+
+// withBackend сохраняет выбранный бэкенд в контексте запроса
+func withBackend(r *http.Request, b backend.Backend) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), backendContextKey{}, b))
+}
+
+// BackendFromContext возвращает бэкенд, выбранный для запроса на этапе balance
+func BackendFromContext(ctx context.Context) backend.Backend {
+	b, _ := ctx.Value(backendContextKey{}).(backend.Backend)
+	return b
+}
@@ -0,0 +1,53 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestBuildHandler_PhaseOrder(t *testing.T) {
+	var order []string
+	record := func(name string) MiddlewareFactory {
+		return func(cfg config.MiddlewareConfig, _ logger.Logger) (Middleware, error) {
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					order = append(order, name)
+					next.ServeHTTP(w, r)
+				})
+			}, nil
+		}
+	}
+	RegisterMiddleware("test-rewrite", PhaseRewrite, record("rewrite"))
+	RegisterMiddleware("test-auth", PhaseAuth, record("auth"))
+
+	chain, err := NewChain([]config.MiddlewareConfig{
+		{Name: "test-rewrite"},
+		{Name: "headers", Params: map[string]interface{}{"set": map[string]interface{}{"X-Env": "test"}}},
+		{Name: "test-auth"},
+	}, logger.NewNop())
+	if err != nil {
+		t.Fatalf("цепочка middleware должна создаваться: %v", err)
+	}
+
+	handler := buildHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "proxy")
+		if r.Header.Get("X-Env") != "test" {
+			t.Error("middleware headers должен устанавливать заголовок")
+		}
+	}), ratelimit.NewNoopRateLimiter(), chain, logger.NewNop())
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := strings.Join(order, ","); got != "auth,rewrite,proxy" {
+		t.Errorf("неверный порядок этапов: %s", got)
+	}
+
+	if _, err := NewChain([]config.MiddlewareConfig{{Name: "headers", Params: map[string]interface{}{"unknown": 1}}}, logger.NewNop()); err == nil {
+		t.Error("неизвестные параметры middleware должны возвращать ошибку")
+	}
+}
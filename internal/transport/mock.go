@@ -0,0 +1,269 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+)
+
+// mockParams параметры middleware mock
+type mockParams struct {
+	// Заглушки ответов; применяется первая подходящая
+	Routes []mockRouteParams `yaml:"routes"`
+}
+
+// mockRouteParams заглушка: условия запроса и ответ на него
+type mockRouteParams struct {
+	// Точный путь или префикс пути и методы запросов (по умолчанию все)
+	Path       string   `yaml:"path"`
+	PathPrefix string   `yaml:"pathPrefix"`
+	Methods    []string `yaml:"methods"`
+
+	// Значения заголовков и параметров query, которые должны быть у запроса
+	Headers map[string]string `yaml:"headers"`
+	Query   map[string]string `yaml:"query"`
+
+	// Подстрока, которую должно содержать тело запроса
+	BodyContains string `yaml:"bodyContains"`
+
+	// Ответ заглушки
+	Response mockResponseParams `yaml:"response"`
+}
+
+// mockResponseParams ответ заглушки
+type mockResponseParams struct {
+	// Статус ответа (по умолчанию 200)
+	Status  int               `yaml:"status"`
+	Headers map[string]string `yaml:"headers"`
+
+	// Тело ответа: строка, значение, кодируемое в JSON, или файл; задается одно из трех
+	Body     string      `yaml:"body"`
+	JSON     interface{} `yaml:"json"`
+	BodyFile string      `yaml:"bodyFile"`
+
+	// Задержка ответа; если задан maxDelay, задержка выбирается случайно между delay и maxDelay
+	Delay    time.Duration `yaml:"delay"`
+	MaxDelay time.Duration `yaml:"maxDelay"`
+}
+
+// mockRoute разобранная заглушка
+type mockRoute struct {
+	name         string
+	path         string
+	pathPrefix   string
+	methods      map[string]bool
+	headers      map[string]string
+	query        map[string]string
+	bodyContains string
+
+	status   int
+	header   http.Header
+	body     []byte
+	delay    time.Duration
+	maxDelay time.Duration
+}
+
+// mock отвечает на запросы заглушками из конфигурации, не обращаясь к бэкендам, чтобы
+// клиенты могли разрабатываться до появления бэкенда
+type mock struct {
+	routes []*mockRoute
+	logger logger.Logger
+
+	// Ожидание; подменяется в тестах
+	sleep func(r *http.Request, d time.Duration) bool
+}
+
+// newMockMiddleware создает middleware ответов заглушками
+func newMockMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params mockParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	m, err := newMock(params, appLogger)
+	if err != nil {
+		return nil, err
+	}
+	return m.middleware, nil
+}
+
+// newMock проверяет заглушки и готовит тела ответов
+func newMock(params mockParams, appLogger logger.Logger) (*mock, error) {
+	if len(params.Routes) == 0 {
+		return nil, fmt.Errorf("mock: at least one route is required")
+	}
+
+	m := &mock{logger: appLogger, sleep: sleepContext}
+	for i, rp := range params.Routes {
+		if rp.Path != "" && rp.PathPrefix != "" {
+			return nil, fmt.Errorf("mock: routes[%d]: only one of path and pathPrefix may be set", i)
+		}
+		route := &mockRoute{
+			name:         rp.Path,
+			path:         rp.Path,
+			pathPrefix:   rp.PathPrefix,
+			methods:      make(map[string]bool),
+			headers:      rp.Headers,
+			query:        rp.Query,
+			bodyContains: rp.BodyContains,
+			status:       rp.Response.Status,
+			header:       make(http.Header),
+			delay:        rp.Response.Delay,
+			maxDelay:     rp.Response.MaxDelay,
+		}
+		if route.name == "" {
+			route.name = rp.PathPrefix + "*"
+		}
+		for _, method := range rp.Methods {
+			route.methods[strings.ToUpper(method)] = true
+		}
+
+		if route.status == 0 {
+			route.status = http.StatusOK
+		}
+		if route.status < 100 || route.status > 599 {
+			return nil, fmt.Errorf("mock: routes[%d].response.status must be between 100 and 599", i)
+		}
+		if route.delay < 0 || (route.maxDelay != 0 && route.maxDelay < route.delay) {
+			return nil, fmt.Errorf("mock: routes[%d].response: invalid delay range", i)
+		}
+
+		body, contentType, err := mockBody(rp.Response)
+		if err != nil {
+			return nil, fmt.Errorf("mock: routes[%d].response: %w", i, err)
+		}
+		route.body = body
+		if contentType != "" {
+			route.header.Set("Content-Type", contentType)
+		}
+		for name, value := range rp.Response.Headers {
+			route.header.Set(name, value)
+		}
+		m.routes = append(m.routes, route)
+	}
+	return m, nil
+}
+
+// mockBody возвращает тело ответа заглушки и его тип по умолчанию
+func mockBody(rp mockResponseParams) ([]byte, string, error) {
+	set := 0
+	for _, ok := range []bool{rp.Body != "", rp.JSON != nil, rp.BodyFile != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, "", fmt.Errorf("only one of body, json and bodyFile may be set")
+	}
+
+	switch {
+	case rp.JSON != nil:
+		body, err := json.Marshal(rp.JSON)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid json: %w", err)
+		}
+		return body, "application/json", nil
+	case rp.BodyFile != "":
+		body, err := os.ReadFile(rp.BodyFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("bodyFile: %w", err)
+		}
+		return body, http.DetectContentType(body), nil
+	case rp.Body != "":
+		return []byte(rp.Body), "text/plain; charset=utf-8", nil
+	}
+	return nil, "", nil
+}
+
+// match возвращает первую заглушку, условиям которой соответствует запрос.
+// Тело запроса читается, только если у заглушек есть условие bodyContains.
+func (m *mock) match(r *http.Request, body func() string) *mockRoute {
+	for _, route := range m.routes {
+		if route.path != "" && r.URL.Path != route.path {
+			continue
+		}
+		if !strings.HasPrefix(r.URL.Path, route.pathPrefix) {
+			continue
+		}
+		if len(route.methods) > 0 && !route.methods[r.Method] {
+			continue
+		}
+		if !mockValuesMatch(route.headers, r.Header.Get) || !mockValuesMatch(route.query, r.URL.Query().Get) {
+			continue
+		}
+		if route.bodyContains != "" && !strings.Contains(body(), route.bodyContains) {
+			continue
+		}
+		return route
+	}
+	return nil
+}
+
+// mockValuesMatch проверяет, что у запроса есть все ожидаемые значения
+func mockValuesMatch(expected map[string]string, get func(string) string) bool {
+	for name, value := range expected {
+		if get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *mock) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body *string
+		readBody := func() string {
+			if body == nil {
+				var data []byte
+				if r.Body != nil {
+					// Прочитанное начало тела возвращается в запрос для бэкенда
+					data, _ = io.ReadAll(io.LimitReader(r.Body, defaultMaxFilterBodySize))
+					r.Body = struct {
+						io.Reader
+						io.Closer
+					}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+				}
+				s := string(data)
+				body = &s
+			}
+			return *body
+		}
+
+		route := m.match(r, readBody)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if req := request.FromContext(r.Context()); req != nil {
+			request.Set(req, request.KeyRoute, "mock:"+route.name)
+		}
+
+		delay := route.delay
+		if route.maxDelay > route.delay {
+			delay += time.Duration(rand.Int64N(int64(route.maxDelay - route.delay + 1)))
+		}
+		if delay > 0 && !m.sleep(r, delay) {
+			return
+		}
+		if m.logger.DebugEnabled() {
+			m.logger.Debug(fmt.Sprintf("Запрос %s %s обработан заглушкой %s", r.Method, r.URL.Path, route.name))
+		}
+
+		for name, values := range route.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(route.status)
+		if r.Method != http.MethodHead {
+			w.Write(route.body)
+		}
+	})
+}
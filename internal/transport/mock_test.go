@@ -0,0 +1,79 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestMock(t *testing.T) {
+	m, err := newMock(mockParams{Routes: []mockRouteParams{
+		{Path: "/api/users/1", Methods: []string{"get"}, Response: mockResponseParams{
+			JSON: map[string]interface{}{"id": 1, "name": "Ivan"},
+		}},
+		{PathPrefix: "/api/orders", Methods: []string{"POST"}, BodyContains: `"express":true`, Response: mockResponseParams{
+			Status: http.StatusCreated, Body: "express", Headers: map[string]string{"X-Mock": "1"}, Delay: 300 * time.Millisecond,
+		}},
+		{PathPrefix: "/api/orders", Query: map[string]string{"status": "failed"}, Response: mockResponseParams{
+			Status: http.StatusServiceUnavailable,
+		}},
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var slept time.Duration
+	m.sleep = func(r *http.Request, d time.Duration) bool {
+		slept += d
+		return true
+	}
+
+	var backendBody string
+	handler := m.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		backendBody = string(body)
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := serve("GET", "/api/users/1", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"id":1,"name":"Ivan"}` || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("неверный ответ заглушки json: %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+	if rec := serve("GET", "/api/users/2", ""); rec.Code != http.StatusTeapot {
+		t.Errorf("запрос вне заглушек должен передаваться дальше, получен %d", rec.Code)
+	}
+
+	rec = serve("POST", "/api/orders", `{"express":true}`)
+	if rec.Code != http.StatusCreated || rec.Body.String() != "express" || rec.Header().Get("X-Mock") != "1" || slept != 300*time.Millisecond {
+		t.Errorf("неверный ответ заглушки по телу: %d %q %v, задержка %v", rec.Code, rec.Body.String(), rec.Header(), slept)
+	}
+	if rec := serve("POST", "/api/orders", `{"express":false}`); rec.Code != http.StatusTeapot || backendBody != `{"express":false}` {
+		t.Errorf("бэкенд должен получить исходное тело: %d %q", rec.Code, backendBody)
+	}
+	if rec := serve("GET", "/api/orders?status=failed", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("заглушка по параметру query должна вернуть 503, получен %d", rec.Code)
+	}
+}
+
+func TestMock_InvalidParams(t *testing.T) {
+	for _, params := range []mockParams{
+		{},
+		{Routes: []mockRouteParams{{Path: "/a", PathPrefix: "/a"}}},
+		{Routes: []mockRouteParams{{Response: mockResponseParams{Body: "a", JSON: "b"}}}},
+		{Routes: []mockRouteParams{{Response: mockResponseParams{Status: 1000}}}},
+		{Routes: []mockRouteParams{{Response: mockResponseParams{Delay: time.Second, MaxDelay: time.Millisecond}}}},
+	} {
+		if _, err := newMock(params, logger.NewNop()); err == nil {
+			t.Errorf("параметры %+v должны быть отклонены", params)
+		}
+	}
+}
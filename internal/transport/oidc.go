@@ -0,0 +1,468 @@
+package transport
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+)
+
+// Параметры OIDC по умолчанию
+const (
+	defaultOIDCCookieName = "_lb_session"
+	defaultOIDCSessionTTL = 8 * time.Hour
+	defaultOIDCSignOut    = "/oauth2/sign_out"
+	oidcStateTTL          = 10 * time.Minute
+	oidcRequestTimeout    = 10 * time.Second
+)
+
+// Заголовки с данными пользователя, передаваемые бэкендам
+const (
+	headerForwardedUser     = "X-Forwarded-User"
+	headerForwardedEmail    = "X-Forwarded-Email"
+	headerForwardedUsername = "X-Forwarded-Preferred-Username"
+)
+
+// oidcParams параметры middleware oidc
+type oidcParams struct {
+	// Адрес провайдера; настройки читаются из {issuer}/.well-known/openid-configuration
+	Issuer string `yaml:"issuer"`
+
+	ClientID     string `yaml:"clientID"`
+	ClientSecret string `yaml:"clientSecret"`
+
+	// Адрес возврата после входа, зарегистрированный у провайдера (например, https://app.example.com/oauth2/callback)
+	RedirectURL string `yaml:"redirectURL"`
+
+	// Запрашиваемые scope (по умолчанию openid, email, profile)
+	Scopes []string `yaml:"scopes"`
+
+	// Имя и ключ шифрования cookie сессии (ключ не короче 32 символов)
+	CookieName   string `yaml:"cookieName"`
+	CookieSecret string `yaml:"cookieSecret"`
+
+	// Время жизни сессии (по умолчанию 8h)
+	SessionTTL time.Duration `yaml:"sessionTTL"`
+
+	// Путь выхода (по умолчанию /oauth2/sign_out)
+	SignOutPath string `yaml:"signOutPath"`
+
+	// Хосты и префиксы путей, для которых требуется вход (по умолчанию все запросы)
+	Hosts []string `yaml:"hosts"`
+	Paths []string `yaml:"paths"`
+}
+
+// oidcSession данные пользователя в cookie сессии
+type oidcSession struct {
+	Subject  string `json:"sub"`
+	Email    string `json:"email,omitempty"`
+	Username string `json:"username,omitempty"`
+	Expires  int64  `json:"exp"`
+}
+
+// oidcState состояние входа, хранимое в cookie до возврата от провайдера
+type oidcState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Redirect string `json:"redirect"`
+	Expires  int64  `json:"exp"`
+}
+
+// oidcProviderConfig настройки провайдера из документа discovery
+type oidcProviderConfig struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcProxy режим аутентифицирующего прокси (как oauth2-proxy): запросы без сессии
+// из браузера перенаправляются к OIDC провайдеру, после входа пользователь получает
+// зашифрованную cookie сессии, а бэкенды — заголовки с данными пользователя.
+// Запросы не из браузера без сессии получают 401.
+type oidcProxy struct {
+	params       oidcParams
+	callbackPath string
+	cookies      *cookieCipher
+	client       *http.Client
+	logger       logger.Logger
+
+	mu       sync.Mutex
+	provider *oidcProviderConfig
+	keys     *jwksSource
+}
+
+// newOIDCMiddleware создает middleware аутентификации через OIDC провайдера
+func newOIDCMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params oidcParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case params.Issuer == "":
+		return nil, fmt.Errorf("issuer is required")
+	case params.ClientID == "" || params.ClientSecret == "":
+		return nil, fmt.Errorf("clientID and clientSecret are required")
+	case len(params.CookieSecret) < minCookieSecretLength:
+		return nil, fmt.Errorf("cookieSecret must be at least %d characters", minCookieSecretLength)
+	case params.SessionTTL < 0:
+		return nil, fmt.Errorf("sessionTTL must not be negative")
+	}
+	redirectURL, err := url.Parse(params.RedirectURL)
+	if err != nil || redirectURL.Scheme == "" || redirectURL.Host == "" || redirectURL.Path == "" {
+		return nil, fmt.Errorf("redirectURL must be an absolute URL with callback path: %q", params.RedirectURL)
+	}
+
+	if len(params.Scopes) == 0 {
+		params.Scopes = []string{"openid", "email", "profile"}
+	}
+	if params.CookieName == "" {
+		params.CookieName = defaultOIDCCookieName
+	}
+	if params.SessionTTL == 0 {
+		params.SessionTTL = defaultOIDCSessionTTL
+	}
+	if params.SignOutPath == "" {
+		params.SignOutPath = defaultOIDCSignOut
+	}
+	params.Issuer = strings.TrimSuffix(params.Issuer, "/")
+
+	cookies, err := newCookieCipher(params.CookieSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &oidcProxy{
+		params:       params,
+		callbackPath: redirectURL.Path,
+		cookies:      cookies,
+		client:       &http.Client{Timeout: oidcRequestTimeout},
+		logger:       appLogger,
+	}
+	p.keys = &jwksSource{client: p.client}
+	return p.middleware, nil
+}
+
+func (p *oidcProxy) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == p.callbackPath:
+			p.callback(w, r)
+			return
+		case r.URL.Path == p.params.SignOutPath:
+			p.clearCookie(w, p.params.CookieName)
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		case !p.protects(r):
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var session oidcSession
+		if cookie, err := r.Cookie(p.params.CookieName); err == nil &&
+			p.cookies.decrypt(cookie.Value, &session) == nil && time.Now().Unix() < session.Expires {
+			// Заголовки пользователя от клиента не передаются: их может установить только прокси
+			r = r.Clone(r.Context())
+			r.Header.Set(headerForwardedUser, session.Subject)
+			setOrDelete(r.Header, headerForwardedEmail, session.Email)
+			setOrDelete(r.Header, headerForwardedUsername, session.Username)
+
+			customReq := request.FromContext(r.Context())
+			request.Set(customReq, request.KeyUserID, session.Subject)
+			request.Set(customReq, request.KeyClaims, map[string]interface{}{
+				"sub":                session.Subject,
+				"email":              session.Email,
+				"preferred_username": session.Username,
+			})
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !isBrowserRequest(r) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		p.login(w, r)
+	})
+}
+
+// protects проверяет, требуется ли вход для запроса
+func (p *oidcProxy) protects(r *http.Request) bool {
+	if len(p.params.Hosts) > 0 {
+		host := r.Host
+		if h, _, found := strings.Cut(host, ":"); found {
+			host = h
+		}
+		matched := false
+		for _, h := range p.params.Hosts {
+			if strings.EqualFold(h, host) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(p.params.Paths) == 0 {
+		return true
+	}
+	for _, prefix := range p.params.Paths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// login перенаправляет браузер на страницу входа провайдера
+func (p *oidcProxy) login(w http.ResponseWriter, r *http.Request) {
+	provider, err := p.providerConfig(r.Context())
+	if err != nil {
+		p.logger.Error(fmt.Sprintf("Ошибка получения настроек OIDC провайдера: %v", err))
+		http.Error(w, "Authentication provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	state := oidcState{
+		State:    randomToken(),
+		Nonce:    randomToken(),
+		Redirect: r.URL.RequestURI(),
+		Expires:  time.Now().Add(oidcStateTTL).Unix(),
+	}
+	value, err := p.cookies.encrypt(state)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	p.setCookie(w, p.stateCookieName(), value, oidcStateTTL)
+
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.params.ClientID},
+		"redirect_uri":  {p.params.RedirectURL},
+		"scope":         {strings.Join(p.params.Scopes, " ")},
+		"state":         {state.State},
+		"nonce":         {state.Nonce},
+	}
+	target := provider.AuthorizationEndpoint
+	if strings.Contains(target, "?") {
+		target += "&" + query.Encode()
+	} else {
+		target += "?" + query.Encode()
+	}
+	p.logger.Debug(fmt.Sprintf("Перенаправление на вход OIDC для %s", r.URL.Path))
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// callback обрабатывает возврат от провайдера: обменивает код на токены,
+// проверяет ID токен и создает сессию
+func (p *oidcProxy) callback(w http.ResponseWriter, r *http.Request) {
+	var state oidcState
+	cookie, err := r.Cookie(p.stateCookieName())
+	if err != nil || p.cookies.decrypt(cookie.Value, &state) != nil ||
+		time.Now().Unix() >= state.Expires || r.URL.Query().Get("state") != state.State {
+		http.Error(w, "Invalid authentication state", http.StatusBadRequest)
+		return
+	}
+	p.clearCookie(w, p.stateCookieName())
+
+	if errCode := r.URL.Query().Get("error"); errCode != "" {
+		p.logger.Warn(fmt.Sprintf("OIDC провайдер вернул ошибку входа: %s", errCode))
+		http.Error(w, "Authentication failed", http.StatusForbidden)
+		return
+	}
+
+	claims, err := p.exchange(r.Context(), r.URL.Query().Get("code"), state.Nonce)
+	if err != nil {
+		p.logger.Warn(fmt.Sprintf("Ошибка входа через OIDC: %v", err))
+		http.Error(w, "Authentication failed", http.StatusForbidden)
+		return
+	}
+
+	expires := time.Now().Add(p.params.SessionTTL).Unix()
+	session := oidcSession{Subject: claims.Subject, Email: claims.Email, Username: claims.PreferredUsername, Expires: expires}
+	value, err := p.cookies.encrypt(session)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	p.setCookie(w, p.params.CookieName, value, p.params.SessionTTL)
+	p.logger.Info(fmt.Sprintf("Пользователь %s вошел через OIDC", claims.Subject))
+
+	// Возвращаем только на локальный путь, чтобы state нельзя было использовать для открытого редиректа
+	redirect := state.Redirect
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") {
+		redirect = "/"
+	}
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+// exchange обменивает код авторизации на токены и возвращает проверенные утверждения ID токена
+func (p *oidcProxy) exchange(ctx context.Context, code, nonce string) (*idTokenClaims, error) {
+	if code == "" {
+		return nil, fmt.Errorf("authorization code is missing")
+	}
+	provider, err := p.providerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {p.params.RedirectURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.params.ClientID), url.QueryEscape(p.params.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("token endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+
+	claims, err := p.verifyIDToken(ctx, tokens.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("id_token nonce mismatch")
+	}
+	return claims, nil
+}
+
+// verifyIDToken проверяет подпись и утверждения ID токена
+func (p *oidcProxy) verifyIDToken(ctx context.Context, token string) (*idTokenClaims, error) {
+	var claims idTokenClaims
+	if err := verifyJWT(token, func(kid string) (interface{}, error) {
+		return p.signingKey(ctx, kid)
+	}, &claims); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	switch {
+	case claims.Issuer != p.params.Issuer:
+		return nil, fmt.Errorf("unexpected id_token issuer %q", claims.Issuer)
+	case !claims.Audience.contains(p.params.ClientID):
+		return nil, fmt.Errorf("id_token is not issued for client %s", p.params.ClientID)
+	case claims.Expires <= now:
+		return nil, fmt.Errorf("id_token expired")
+	case claims.Subject == "":
+		return nil, fmt.Errorf("id_token has no subject")
+	}
+	return &claims, nil
+}
+
+// providerConfig возвращает настройки провайдера, загружая их при первом обращении
+func (p *oidcProxy) providerConfig(ctx context.Context) (*oidcProviderConfig, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.provider != nil {
+		return p.provider, nil
+	}
+
+	var provider oidcProviderConfig
+	if err := getJSON(ctx, p.client, p.params.Issuer+"/.well-known/openid-configuration", &provider); err != nil {
+		return nil, err
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JWKSURI == "" {
+		return nil, fmt.Errorf("incomplete OIDC discovery document")
+	}
+	if strings.TrimSuffix(provider.Issuer, "/") != p.params.Issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", provider.Issuer, p.params.Issuer)
+	}
+	p.provider = &provider
+	return p.provider, nil
+}
+
+// signingKey возвращает ключ подписи провайдера
+func (p *oidcProxy) signingKey(ctx context.Context, kid string) (interface{}, error) {
+	provider, err := p.providerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.keys.key(ctx, provider.JWKSURI, kid)
+}
+
+// stateCookieName имя cookie состояния входа
+func (p *oidcProxy) stateCookieName() string {
+	return p.params.CookieName + "_state"
+}
+
+// setCookie устанавливает cookie прокси
+func (p *oidcProxy) setCookie(w http.ResponseWriter, name, value string, ttl time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(p.params.RedirectURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearCookie удаляет cookie прокси
+func (p *oidcProxy) clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{Name: name, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+}
+
+// isBrowserRequest отличает переходы браузера от запросов API: только их можно перенаправить на вход
+func isBrowserRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// setOrDelete устанавливает заголовок или удаляет его, если значение пустое
+func setOrDelete(h http.Header, name, value string) {
+	if value == "" {
+		h.Del(name)
+		return
+	}
+	h.Set(name, value)
+}
+
+// randomToken возвращает случайную строку для state и nonce
+func randomToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
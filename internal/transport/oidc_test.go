@@ -0,0 +1,150 @@
+package transport
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+// signTestJWT подписывает утверждения ключом RS256
+func signTestJWT(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test"})
+	payload, _ := json.Marshal(claims)
+	signing := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signing))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestOIDC_LoginFlow(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var issuer, nonce string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 issuer,
+				"authorization_endpoint": issuer + "/authorize",
+				"token_endpoint":         issuer + "/token",
+				"jwks_uri":               issuer + "/keys",
+			})
+		case "/keys":
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kid": "test", "kty": "RSA", "use": "sig",
+				"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		case "/token":
+			if user, pass, _ := r.BasicAuth(); user != "proxy" || pass != "client-secret" || r.FormValue("code") != "good-code" {
+				http.Error(w, "invalid_grant", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"id_token": signTestJWT(t, key, map[string]interface{}{
+				"iss": issuer, "sub": "user-1", "aud": "proxy", "exp": time.Now().Add(time.Hour).Unix(),
+				"nonce": nonce, "email": "user@example.com",
+			})})
+		}
+	}))
+	defer provider.Close()
+	issuer = provider.URL
+
+	m, err := newOIDCMiddleware(config.MiddlewareConfig{Name: "oidc", Params: map[string]interface{}{
+		"issuer":       issuer,
+		"clientID":     "proxy",
+		"clientSecret": "client-secret",
+		"redirectURL":  "http://app.local/oauth2/callback",
+		"cookieSecret": strings.Repeat("s", 32),
+		"paths":        []interface{}{"/private"},
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatalf("middleware должен создаваться: %v", err)
+	}
+	handler := m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(headerForwardedUser) + " " + r.Header.Get(headerForwardedEmail)))
+	}))
+
+	// Незащищенные пути и запросы API
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/public", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("незащищенный путь не должен требовать входа: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/private/data", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("запрос API без сессии должен получать 401: %d", rec.Code)
+	}
+
+	// Браузер перенаправляется к провайдеру
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/private/page?x=1", nil)
+	req.Header.Set("Accept", "text/html")
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("браузер должен перенаправляться на вход: %d", rec.Code)
+	}
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	if !strings.HasPrefix(location.String(), issuer+"/authorize") {
+		t.Fatalf("неверный адрес входа: %s", location)
+	}
+	state := location.Query().Get("state")
+	nonce = location.Query().Get("nonce")
+	stateCookie := rec.Result().Cookies()[0]
+
+	// Возврат от провайдера
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/oauth2/callback?code=good-code&state="+url.QueryEscape(state), nil)
+	req.AddCookie(stateCookie)
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/private/page?x=1" {
+		t.Fatalf("после входа должен быть возврат на исходную страницу: %d %s %s", rec.Code, rec.Header().Get("Location"), rec.Body.String())
+	}
+	var session *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == defaultOIDCCookieName {
+			session = c
+		}
+	}
+	if session == nil {
+		t.Fatal("после входа должна устанавливаться cookie сессии")
+	}
+
+	// Запрос с сессией получает заголовки пользователя, подделанные клиентом значения заменяются
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/private/data", nil)
+	req.Header.Set(headerForwardedUser, "admin")
+	req.AddCookie(session)
+	handler.ServeHTTP(rec, req)
+	if rec.Body.String() != "user-1 user@example.com" {
+		t.Errorf("бэкенд должен получать данные пользователя: %q", rec.Body.String())
+	}
+
+	// Повтор callback с чужим state отклоняется
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/oauth2/callback?code=good-code&state=forged", nil)
+	req.AddCookie(stateCookie)
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("неверный state должен отклоняться: %d", rec.Code)
+	}
+}
@@ -0,0 +1,676 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// openAPISpec операции спецификации OpenAPI 3 и схемы их ответов
+type openAPISpec struct {
+	// Операции в порядке проверки: пути без параметров раньше путей с параметрами
+	operations []*openAPIOperation
+}
+
+// openAPIOperation операция спецификации
+type openAPIOperation struct {
+	// operationId или метод и шаблон пути
+	name   string
+	method string
+	path   *regexp.Regexp
+
+	// Число параметров в шаблоне пути
+	params int
+
+	// Ответы по коду статуса: "200", "2XX" или "default"
+	responses map[string]*openAPIResponse
+}
+
+// openAPIResponse описание ответа: схемы тела по типам содержимого
+type openAPIResponse struct {
+	// Типы содержимого и их схемы; nil схема — тело не проверяется
+	content map[string]*jsonSchema
+}
+
+// loadOpenAPISpec читает спецификацию OpenAPI 3 в YAML или JSON
+func loadOpenAPISpec(path string) (*openAPISpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document %s: %w", path, err)
+	}
+	doc, ok := normalizeYAML(raw).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid OpenAPI document %s: not an object", path)
+	}
+	if version, _ := doc["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("invalid OpenAPI document %s: only OpenAPI 3 is supported", path)
+	}
+
+	c := &schemaCompiler{doc: doc, refs: make(map[string]*jsonSchema)}
+	spec := &openAPISpec{}
+	paths, _ := doc["paths"].(map[string]interface{})
+	for template, item := range paths {
+		item, _ := item.(map[string]interface{})
+		pattern, params, err := compilePathTemplate(template)
+		if err != nil {
+			return nil, err
+		}
+		for method, op := range item {
+			method = strings.ToUpper(method)
+			if !isOpenAPIMethod(method) {
+				continue
+			}
+			op, _ := op.(map[string]interface{})
+			operation := &openAPIOperation{
+				name:      method + " " + template,
+				method:    method,
+				path:      pattern,
+				params:    params,
+				responses: make(map[string]*openAPIResponse),
+			}
+			if id, _ := op["operationId"].(string); id != "" {
+				operation.name = id
+			}
+
+			responses, _ := op["responses"].(map[string]interface{})
+			for status, resp := range responses {
+				resp, err := c.resolve(resp)
+				if err != nil {
+					return nil, fmt.Errorf("%s: responses.%s: %w", operation.name, status, err)
+				}
+				response := &openAPIResponse{content: make(map[string]*jsonSchema)}
+				content, _ := resp["content"].(map[string]interface{})
+				for mediaType, media := range content {
+					media, _ := media.(map[string]interface{})
+					var schema *jsonSchema
+					if media["schema"] != nil {
+						if schema, err = c.compile(media["schema"]); err != nil {
+							return nil, fmt.Errorf("%s: responses.%s: %w", operation.name, status, err)
+						}
+					}
+					response.content[strings.ToLower(mediaType)] = schema
+				}
+				operation.responses[strings.ToUpper(status)] = response
+			}
+			spec.operations = append(spec.operations, operation)
+		}
+	}
+
+	sort.SliceStable(spec.operations, func(i, j int) bool {
+		if spec.operations[i].params != spec.operations[j].params {
+			return spec.operations[i].params < spec.operations[j].params
+		}
+		return spec.operations[i].name < spec.operations[j].name
+	})
+	return spec, nil
+}
+
+// isOpenAPIMethod проверяет, что ключ элемента paths — метод операции
+func isOpenAPIMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+		http.MethodOptions, http.MethodHead, http.MethodPatch, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// compilePathTemplate преобразует шаблон пути /pets/{id} в регулярное выражение
+func compilePathTemplate(template string) (*regexp.Regexp, int, error) {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	params := 0
+	for rest := template; rest != ""; {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			pattern.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, 0, fmt.Errorf("invalid path template %q", template)
+		}
+		pattern.WriteString(regexp.QuoteMeta(rest[:start]))
+		pattern.WriteString("[^/]+")
+		params++
+		rest = rest[start+end+1:]
+	}
+	pattern.WriteString("$")
+	re, err := regexp.Compile(pattern.String())
+	return re, params, err
+}
+
+// match возвращает операцию запроса или nil
+func (s *openAPISpec) match(method, path string) *openAPIOperation {
+	for _, op := range s.operations {
+		if op.method == method && op.path.MatchString(path) {
+			return op
+		}
+	}
+	return nil
+}
+
+// response возвращает описание ответа со статусом status: точный код, класс NXX или default
+func (op *openAPIOperation) response(status int) *openAPIResponse {
+	code := strconv.Itoa(status)
+	if resp, ok := op.responses[code]; ok {
+		return resp
+	}
+	if resp, ok := op.responses[code[:1]+"XX"]; ok {
+		return resp
+	}
+	return op.responses["DEFAULT"]
+}
+
+// schema возвращает схему тела с типом содержимого contentType. found — тип описан в спецификации.
+func (resp *openAPIResponse) schema(contentType string) (schema *jsonSchema, found bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	if schema, ok := resp.content[mediaType]; ok {
+		return schema, true
+	}
+	if major, _, ok := strings.Cut(mediaType, "/"); ok {
+		if schema, ok := resp.content[major+"/*"]; ok {
+			return schema, true
+		}
+	}
+	schema, found = resp.content["*/*"]
+	return schema, found
+}
+
+// normalizeYAML приводит ключи отображений YAML к строкам: коды статусов 200 без кавычек
+// разбираются как числа
+func normalizeYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = normalizeYAML(value)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalizeYAML(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = normalizeYAML(value)
+		}
+		return v
+	}
+	return v
+}
+
+// jsonSchema схема OpenAPI (подмножество JSON Schema): типы, перечисления, свойства
+// объектов, элементы массивов, композиция и ограничения значений
+type jsonSchema struct {
+	types    []string
+	nullable bool
+	enum     []interface{}
+
+	properties           map[string]*jsonSchema
+	required             []string
+	additionalProperties *jsonSchema
+	noAdditional         bool
+
+	items              *jsonSchema
+	minItems, maxItems *int
+
+	allOf, anyOf, oneOf []*jsonSchema
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum bool
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+	format               string
+}
+
+// schemaCompiler разбирает схемы документа и ссылки $ref на его части
+type schemaCompiler struct {
+	doc map[string]interface{}
+
+	// Разобранные схемы по ссылкам; схема попадает сюда до разбора, чтобы рекурсивные
+	// ссылки указывали на нее же
+	refs map[string]*jsonSchema
+}
+
+// resolve возвращает объект документа, следуя ссылке $ref
+func (c *schemaCompiler) resolve(node interface{}) (map[string]interface{}, error) {
+	for depth := 0; depth < 32; depth++ {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an object")
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m, nil
+		}
+		target, err := c.pointer(ref)
+		if err != nil {
+			return nil, err
+		}
+		node = target
+	}
+	return nil, fmt.Errorf("too many nested references")
+}
+
+// pointer находит часть документа по локальной ссылке #/components/schemas/Pet
+func (c *schemaCompiler) pointer(ref string) (interface{}, error) {
+	path, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil, fmt.Errorf("only local references are supported: %s", ref)
+	}
+	var node interface{} = c.doc
+	for _, token := range strings.Split(path, "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolved reference %s", ref)
+		}
+		if node, ok = m[token]; !ok {
+			return nil, fmt.Errorf("unresolved reference %s", ref)
+		}
+	}
+	return node, nil
+}
+
+// compile разбирает схему
+func (c *schemaCompiler) compile(node interface{}) (*jsonSchema, error) {
+	if b, ok := node.(bool); ok {
+		// Схема true допускает любое значение, false — никакое
+		if b {
+			return &jsonSchema{}, nil
+		}
+		return &jsonSchema{types: []string{}}, nil
+	}
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema must be an object")
+	}
+
+	if ref, ok := m["$ref"].(string); ok {
+		if schema, ok := c.refs[ref]; ok {
+			return schema, nil
+		}
+		schema := &jsonSchema{}
+		c.refs[ref] = schema
+		target, err := c.pointer(ref)
+		if err != nil {
+			return nil, err
+		}
+		compiled, err := c.compile(target)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		*schema = *compiled
+		return schema, nil
+	}
+
+	s := &jsonSchema{}
+	switch t := m["type"].(type) {
+	case string:
+		s.types = []string{t}
+	case []interface{}:
+		// OpenAPI 3.1: type: [string, "null"]
+		for _, item := range t {
+			if name, _ := item.(string); name == "null" {
+				s.nullable = true
+			} else if name != "" {
+				s.types = append(s.types, name)
+			}
+		}
+	}
+	s.nullable = s.nullable || m["nullable"] == true
+	if enum, ok := m["enum"].([]interface{}); ok {
+		s.enum = enum
+	}
+	s.format, _ = m["format"].(string)
+
+	if props, ok := m["properties"].(map[string]interface{}); ok {
+		s.properties = make(map[string]*jsonSchema, len(props))
+		for name, prop := range props {
+			schema, err := c.compile(prop)
+			if err != nil {
+				return nil, fmt.Errorf("properties.%s: %w", name, err)
+			}
+			s.properties[name] = schema
+		}
+	}
+	if required, ok := m["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				s.required = append(s.required, name)
+			}
+		}
+	}
+	switch additional := m["additionalProperties"].(type) {
+	case bool:
+		s.noAdditional = !additional
+	case map[string]interface{}:
+		schema, err := c.compile(additional)
+		if err != nil {
+			return nil, fmt.Errorf("additionalProperties: %w", err)
+		}
+		s.additionalProperties = schema
+	}
+
+	if items, ok := m["items"]; ok {
+		schema, err := c.compile(items)
+		if err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+		s.items = schema
+	}
+	for keyword, target := range map[string]*[]*jsonSchema{"allOf": &s.allOf, "anyOf": &s.anyOf, "oneOf": &s.oneOf} {
+		list, _ := m[keyword].([]interface{})
+		for i, item := range list {
+			schema, err := c.compile(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d]: %w", keyword, i, err)
+			}
+			*target = append(*target, schema)
+		}
+	}
+
+	s.minimum, s.maximum = schemaNumber(m["minimum"]), schemaNumber(m["maximum"])
+	// В OpenAPI 3.0 exclusiveMinimum — признак, в 3.1 — граница
+	switch v := m["exclusiveMinimum"].(type) {
+	case bool:
+		s.exclusiveMinimum = v
+	default:
+		if n := schemaNumber(v); n != nil {
+			s.minimum, s.exclusiveMinimum = n, true
+		}
+	}
+	switch v := m["exclusiveMaximum"].(type) {
+	case bool:
+		s.exclusiveMaximum = v
+	default:
+		if n := schemaNumber(v); n != nil {
+			s.maximum, s.exclusiveMaximum = n, true
+		}
+	}
+	s.minLength, s.maxLength = schemaInt(m["minLength"]), schemaInt(m["maxLength"])
+	s.minItems, s.maxItems = schemaInt(m["minItems"]), schemaInt(m["maxItems"])
+	if pattern, ok := m["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern: %w", err)
+		}
+		s.pattern = re
+	}
+	return s, nil
+}
+
+// schemaNumber возвращает числовое значение ключевого слова схемы
+func schemaNumber(v interface{}) *float64 {
+	var n float64
+	switch v := v.(type) {
+	case int:
+		n = float64(v)
+	case float64:
+		n = v
+	default:
+		return nil
+	}
+	return &n
+}
+
+// schemaInt возвращает целое значение ключевого слова схемы
+func schemaInt(v interface{}) *int {
+	if n := schemaNumber(v); n != nil {
+		i := int(*n)
+		return &i
+	}
+	return nil
+}
+
+// contractViolation несоответствие значения схеме
+type contractViolation struct {
+	// Путь к значению: имена полей и индексы элементов
+	path []interface{}
+
+	message string
+
+	// Значение можно удалить из объекта, в котором оно находится
+	strippable bool
+}
+
+// location возвращает путь к значению в виде $.items[0].price
+func (v contractViolation) location() string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, step := range v.path {
+		switch step := step.(type) {
+		case string:
+			b.WriteString(".")
+			b.WriteString(step)
+		case int:
+			b.WriteString("[")
+			b.WriteString(strconv.Itoa(step))
+			b.WriteString("]")
+		}
+	}
+	return b.String()
+}
+
+// appendPath возвращает путь к вложенному значению, не изменяя path
+func appendPath(path []interface{}, step interface{}) []interface{} {
+	return append(path[:len(path):len(path)], step)
+}
+
+// validate проверяет значение, разобранное json.Decoder с UseNumber, и добавляет
+// несоответствия в out
+func (s *jsonSchema) validate(v interface{}, path []interface{}, out *[]contractViolation) {
+	fail := func(strippable bool, format string, args ...interface{}) {
+		*out = append(*out, contractViolation{path: path, message: fmt.Sprintf(format, args...), strippable: strippable && len(path) > 0})
+	}
+
+	if v == nil {
+		if !s.nullable && len(s.types) > 0 {
+			fail(true, "must not be null")
+		}
+		return
+	}
+	if s.types != nil && !s.hasType(v) {
+		if len(s.types) == 0 {
+			fail(true, "no value is allowed")
+		} else {
+			fail(true, "expected %s, got %s", strings.Join(s.types, " or "), jsonType(v))
+		}
+		return
+	}
+	if len(s.enum) > 0 && !inEnum(v, s.enum) {
+		fail(true, "value is not one of the enum values")
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				fail(false, "missing required property %s", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			switch prop, ok := s.properties[name]; {
+			case ok:
+				prop.validate(v[name], appendPath(path, name), out)
+			case s.additionalProperties != nil:
+				s.additionalProperties.validate(v[name], appendPath(path, name), out)
+			case s.noAdditional:
+				*out = append(*out, contractViolation{path: appendPath(path, name), message: "property is not declared", strippable: true})
+			}
+		}
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail(false, "must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail(false, "must have at most %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(item, appendPath(path, i), out)
+			}
+		}
+	case json.Number:
+		n, _ := v.Float64()
+		if s.minimum != nil && (n < *s.minimum || (s.exclusiveMinimum && n == *s.minimum)) {
+			if s.exclusiveMinimum {
+				fail(true, "must be greater than %v", *s.minimum)
+			} else {
+				fail(true, "must be greater than or equal to %v", *s.minimum)
+			}
+		}
+		if s.maximum != nil && (n > *s.maximum || (s.exclusiveMaximum && n == *s.maximum)) {
+			if s.exclusiveMaximum {
+				fail(true, "must be less than %v", *s.maximum)
+			} else {
+				fail(true, "must be less than or equal to %v", *s.maximum)
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if s.minLength != nil && length < *s.minLength {
+			fail(true, "must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			fail(true, "must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail(true, "does not match pattern %s", s.pattern)
+		}
+		if !validFormat(s.format, v) {
+			fail(true, "is not a valid %s", s.format)
+		}
+	}
+
+	for _, schema := range s.allOf {
+		schema.validate(v, path, out)
+	}
+	if len(s.anyOf) > 0 && s.matching(s.anyOf, v, path) == 0 {
+		fail(true, "does not match any schema of anyOf")
+	}
+	if len(s.oneOf) > 0 {
+		if n := s.matching(s.oneOf, v, path); n != 1 {
+			fail(true, "matches %d schemas of oneOf instead of one", n)
+		}
+	}
+}
+
+// matching возвращает число схем, которым соответствует значение
+func (s *jsonSchema) matching(schemas []*jsonSchema, v interface{}, path []interface{}) int {
+	n := 0
+	for _, schema := range schemas {
+		var violations []contractViolation
+		schema.validate(v, path, &violations)
+		if len(violations) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// hasType проверяет, что значение относится к одному из типов схемы
+func (s *jsonSchema) hasType(v interface{}) bool {
+	actual := jsonType(v)
+	for _, t := range s.types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType возвращает тип значения JSON; числа без дробной части — integer
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if n, err := v.Float64(); err == nil && n == math.Trunc(n) {
+			return "integer"
+		}
+		return "number"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// inEnum проверяет, что значение равно одному из значений перечисления
+func inEnum(v interface{}, enum []interface{}) bool {
+	for _, allowed := range enum {
+		if n, ok := v.(json.Number); ok {
+			f, _ := n.Float64()
+			if expected := schemaNumber(allowed); expected != nil && *expected == f {
+				return true
+			}
+			continue
+		}
+		switch v.(type) {
+		case string, bool:
+			if v == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// uuidPattern формат uuid
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// validFormat проверяет распространенные форматы строк; неизвестные форматы не проверяются
+func validFormat(format, v string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, v)
+		return err == nil
+	case "date":
+		_, err := time.Parse(time.DateOnly, v)
+		return err == nil
+	case "uuid":
+		return uuidPattern.MatchString(v)
+	case "email":
+		at := strings.LastIndexByte(v, '@')
+		return at > 0 && at < len(v)-1
+	case "ipv4":
+		ip := net.ParseIP(v)
+		return ip != nil && ip.To4() != nil && !strings.Contains(v, ":")
+	case "ipv6":
+		ip := net.ParseIP(v)
+		return ip != nil && strings.Contains(v, ":")
+	}
+	return true
+}
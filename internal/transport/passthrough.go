@@ -0,0 +1,207 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/request"
+)
+
+// Результаты передачи TLS соединений без расшифровки
+const (
+	passthroughForwarded = "forwarded"
+	passthroughNoBackend = "noBackend"
+	passthroughSaturated = "saturated"
+	passthroughFailed    = "failed"
+)
+
+// passthroughConnections счетчик TLS соединений, переданных бэкендам без расшифровки,
+// по маршруту и результату
+var passthroughConnections = metrics.Default.Counter("lb_passthrough_connections_total", "TLS passthrough connections by route and result", "route", "result")
+
+// errClientHelloRead прерывает рукопожатие после чтения ClientHello
+var errClientHelloRead = errors.New("client hello read")
+
+// passthroughRoute маршрут TLS соединений по имени сервера
+type passthroughRoute struct {
+	name string
+
+	// Точные имена и суффиксы имен с подстановкой (.example.com для *.example.com)
+	serverNames map[string]bool
+	wildcards   []string
+
+	// ID бэкендов маршрута; nil — все бэкенды
+	backends map[string]bool
+
+	// Порт TLS на бэкендах; пустой — порт из адреса бэкенда
+	port string
+}
+
+// newPassthroughRoute разбирает маршрут TLS соединений
+func newPassthroughRoute(cfg config.PassthroughRouteConfig) *passthroughRoute {
+	rt := &passthroughRoute{name: cfg.Name, serverNames: make(map[string]bool)}
+	for _, name := range cfg.ServerNames {
+		name = strings.ToLower(name)
+		if suffix, ok := strings.CutPrefix(name, "*"); ok {
+			rt.wildcards = append(rt.wildcards, suffix)
+		} else {
+			rt.serverNames[name] = true
+		}
+	}
+	if len(cfg.Backends) > 0 {
+		rt.backends = make(map[string]bool, len(cfg.Backends))
+		for _, id := range cfg.Backends {
+			rt.backends[id] = true
+		}
+	}
+	if cfg.Port != 0 {
+		rt.port = strconv.Itoa(cfg.Port)
+	}
+	return rt
+}
+
+// matches проверяет, что маршрут обслуживает имя сервера. Подстановка заменяет ровно
+// один уровень: *.example.com подходит для api.example.com, но не для a.b.example.com.
+func (rt *passthroughRoute) matches(serverName string) bool {
+	if rt.serverNames[serverName] {
+		return true
+	}
+	for _, suffix := range rt.wildcards {
+		if label, ok := strings.CutSuffix(serverName, suffix); ok && label != "" && !strings.Contains(label, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// passthroughRoute возвращает маршрут TLS соединения с именем сервера serverName или nil
+func (rt *Router) passthroughRoute(serverName string) *passthroughRoute {
+	for _, route := range rt.passthrough {
+		if route.matches(serverName) {
+			return route
+		}
+	}
+	return nil
+}
+
+// passthroughRoute возвращает маршрут TLS соединения без расшифровки или nil
+func (p *Proxy) passthroughRoute(serverName string) *passthroughRoute {
+	if p.router == nil || serverName == "" {
+		return nil
+	}
+	return p.router.passthroughRoute(serverName)
+}
+
+// hasPassthrough проверяет, что у прокси есть маршруты TLS соединений без расшифровки
+func (p *Proxy) hasPassthrough() bool {
+	return p.router != nil && len(p.router.passthrough) > 0
+}
+
+// tunnelTLS передает TLS соединение бэкенду маршрута без расшифровки. Бэкенд выбирается
+// балансировщиком среди бэкендов маршрута, как для запроса CONNECT к имени сервера
+// от клиента соединения. Соединение с бэкендом занимает место в его лимите MaxConnections;
+// ctx отменяется, когда сервер останавливается и туннель нужно закрыть.
+func (p *Proxy) tunnelTLS(ctx context.Context, conn net.Conn, rt *passthroughRoute, serverName string) {
+	defer conn.Close()
+
+	r := &http.Request{
+		Method:     http.MethodConnect,
+		URL:        &url.URL{Host: serverName},
+		Host:       serverName,
+		Header:     make(http.Header),
+		RemoteAddr: conn.RemoteAddr().String(),
+	}
+	req := request.NewRequest(r, p.trusted)
+	request.Set(req, request.KeyRoute, rt.name)
+	if rt.backends != nil {
+		request.Set(req, request.KeyBackends, rt.backends)
+	}
+
+	be := p.loadbalancer.Invoke(req)
+	if be == nil {
+		passthroughConnections.Inc(rt.name, passthroughNoBackend)
+		p.logger.Warn(fmt.Sprintf("Нет доступных бэкендов для TLS соединения %s (маршрут %s)", serverName, rt.name))
+		return
+	}
+	upstream, err := be.DialTunnel(ctx, rt.port)
+	switch {
+	case errors.Is(err, backend.ErrMaxConnections):
+		passthroughConnections.Inc(rt.name, passthroughSaturated)
+		p.logger.Warn(fmt.Sprintf("Бэкенд %s исчерпал лимит соединений, TLS соединение %s отклонено", be.ID(), serverName))
+		return
+	case err != nil:
+		passthroughConnections.Inc(rt.name, passthroughFailed)
+		p.logger.Warn(fmt.Sprintf("Не удалось подключиться к бэкенду %s для TLS соединения %s: %v", be.ID(), serverName, err))
+		return
+	}
+	defer upstream.Close()
+	stop := context.AfterFunc(ctx, func() { upstream.Close() })
+	defer stop()
+
+	passthroughConnections.Inc(rt.name, passthroughForwarded)
+	if p.logger.DebugEnabled() {
+		p.logger.Debug(fmt.Sprintf("TLS соединение %s от %s передано бэкенду %s без расшифровки", serverName, conn.RemoteAddr(), be.ID()))
+	}
+	p.loadbalancer.IncActiveConnections(be.ID())
+	defer p.loadbalancer.DecActiveConnections(be.ID())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pipe(upstream, conn)
+	}()
+	pipe(conn, upstream)
+	wg.Wait()
+}
+
+// readServerName читает ClientHello и возвращает имя сервера из SNI (пустое, если его нет
+// или ClientHello не удалось разобрать) и соединение, чтение которого начинается
+// с прочитанных байтов
+func readServerName(conn net.Conn, timeout time.Duration) (string, net.Conn) {
+	var read bytes.Buffer
+	var serverName string
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	// ClientHello разбирает crypto/tls: рукопожатие прерывается сразу после него
+	hello := tls.Server(helloConn{Conn: conn, r: io.TeeReader(conn, &read)}, &tls.Config{
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = info.ServerName
+			return nil, errClientHelloRead
+		},
+	})
+	hello.Handshake()
+
+	return strings.ToLower(serverName), &sniffedConn{Conn: conn, r: io.MultiReader(&read, conn)}
+}
+
+// helloConn соединение для чтения ClientHello: запись (сообщение об ошибке рукопожатия)
+// не отправляется клиенту
+type helloConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c helloConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c helloConn) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
@@ -0,0 +1,142 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestServer_TLSPassthrough(t *testing.T) {
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Бэкенд secure сам завершает TLS, web принимает проксированные HTTP запросы
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secure:"+r.TLS.ServerName)
+	}))
+	defer secure.Close()
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "web")
+	}))
+	defer web.Close()
+	for id, url := range map[string]string{"secure": secure.URL, "web": web.URL} {
+		b := backend.NewBackendWithOptions(id, url, 1, backend.Options{})
+		defer b.Close()
+		lb.AddBackend(b)
+	}
+
+	router, err := NewRouter(&config.RoutingConfig{
+		Rules: []config.RouteConfig{{Name: "web", Backends: []string{"web"}}},
+		Passthrough: []config.PassthroughRouteConfig{
+			{Name: "payments", ServerNames: []string{"payments.example.com", "*.mtls.example.com"}, Backends: []string{"secure"}},
+		},
+	}, nil, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(logger.NewNop())
+	s.server.TLSConfig = &tls.Config{Certificates: secure.TLS.Certificates}
+	s.tls = true
+	s.SetProxy(NewProxy(lb, ratelimit.NewNoopRateLimiter(), Options{Router: router}, logger.NewNop()))
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	forwarded := passthroughConnections.Value("payments", passthroughForwarded)
+	for serverName, want := range map[string]string{
+		"payments.example.com": "secure:payments.example.com",
+		"API.mtls.example.com": "secure:API.mtls.example.com",
+		"a.b.mtls.example.com": "web",
+		"www.example.com":      "web",
+	} {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{ServerName: serverName, InsecureSkipVerify: true},
+		}}
+		resp, err := client.Get("https://" + s.Addr() + "/")
+		if err != nil {
+			t.Fatalf("%s: %v", serverName, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		client.CloseIdleConnections()
+		if string(body) != want {
+			t.Errorf("%s: ожидался ответ %q, получен %q", serverName, want, body)
+		}
+	}
+	if got := passthroughConnections.Value("payments", passthroughForwarded) - forwarded; got != 2 {
+		t.Errorf("ожидалось 2 соединения без расшифровки, учтено %d", got)
+	}
+}
+
+func TestServer_TLSPassthroughSaturated(t *testing.T) {
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secure")
+	}))
+	defer secure.Close()
+	b := backend.NewBackendWithOptions("secure", secure.URL, 1, backend.Options{MaxConnections: 1})
+	defer b.Close()
+	lb.AddBackend(b)
+
+	router, err := NewRouter(&config.RoutingConfig{
+		Passthrough: []config.PassthroughRouteConfig{{Name: "payments", ServerNames: []string{"payments.example.com"}}},
+	}, nil, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(logger.NewNop())
+	s.server.TLSConfig = &tls.Config{Certificates: secure.TLS.Certificates}
+	s.tls = true
+	s.SetProxy(NewProxy(lb, ratelimit.NewNoopRateLimiter(), Options{Router: router}, logger.NewNop()))
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+
+	dial := func() (*tls.Conn, error) {
+		return tls.Dial("tcp", s.Addr(), &tls.Config{ServerName: "payments.example.com", InsecureSkipVerify: true})
+	}
+
+	// Первый туннель занимает единственное соединение бэкенда
+	first, err := dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.ActiveConnections() != 1 {
+		t.Fatalf("туннель должен занимать соединение бэкенда, занято %d", b.ActiveConnections())
+	}
+	if second, err := dial(); err == nil {
+		second.Close()
+		t.Error("туннель к бэкенду с исчерпанным лимитом соединений должен отклоняться")
+	}
+	if b.ActiveConnections() != 1 {
+		t.Errorf("отклоненный туннель не должен занимать соединение, занято %d", b.ActiveConnections())
+	}
+
+	// Остановка сервера закрывает оставшиеся туннели и освобождает соединения бэкенда
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s.Shutdown(ctx)
+	first.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := first.Read(make([]byte, 1)); err == nil {
+		t.Error("туннель должен закрываться при остановке сервера")
+	}
+	if b.ActiveConnections() != 0 {
+		t.Errorf("после закрытия туннеля соединения бэкенда должны освобождаться, занято %d", b.ActiveConnections())
+	}
+}
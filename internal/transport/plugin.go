@@ -0,0 +1,250 @@
+package transport
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"plugin"
+	"strconv"
+	"sync"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/filter"
+	"cloud.ru_test/pkg/logger"
+)
+
+// defaultMaxFilterBodySize размер тела, передаваемого фильтру плагина, по умолчанию
+const defaultMaxFilterBodySize = 1 << 20
+
+// filterFactory функция NewFilter, экспортируемая плагином
+type filterFactory func(params map[string]interface{}) (filter.Filter, error)
+
+var (
+	pluginsMu sync.Mutex
+
+	// Имена middleware, зарегистрированных из плагинов
+	pluginMiddlewares = make(map[string]bool)
+)
+
+// LoadPlugins загружает фильтры из Go плагинов и регистрирует их как middleware,
+// которые затем подключаются в секции middlewares. Повторная загрузка того же
+// файла не открывает его заново: выгрузить Go плагин невозможно.
+func LoadPlugins(configs []config.PluginConfig, appLogger logger.Logger) error {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	for _, cfg := range configs {
+		middlewaresMu.RLock()
+		_, registered := middlewares[cfg.Name]
+		middlewaresMu.RUnlock()
+		if registered && !pluginMiddlewares[cfg.Name] {
+			return fmt.Errorf("plugin %s conflicts with built-in middleware", cfg.Name)
+		}
+
+		phase, err := parsePhase(cfg.Phase)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", cfg.Name, err)
+		}
+
+		newFilter, err := openPlugin(cfg.Path)
+		if err != nil {
+			return fmt.Errorf("failed to load plugin %s: %w", cfg.Name, err)
+		}
+
+		RegisterMiddleware(cfg.Name, phase, func(mc config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+			f, err := newFilter(mc.Params)
+			if err != nil {
+				return nil, err
+			}
+			if f == nil {
+				return nil, fmt.Errorf("plugin returned nil filter")
+			}
+			return filterMiddleware(f, cfg, appLogger), nil
+		})
+		pluginMiddlewares[cfg.Name] = true
+		appLogger.Info(fmt.Sprintf("Загружен плагин %s из %s (этап %s)", cfg.Name, cfg.Path, phase))
+	}
+	return nil
+}
+
+// openPlugin открывает плагин и проверяет его ABI
+func openPlugin(path string) (filterFactory, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if sym, err := p.Lookup("ABIVersion"); err == nil {
+		version, ok := sym.(*int)
+		if !ok {
+			return nil, fmt.Errorf("ABIVersion must be an int variable")
+		}
+		if *version != filter.ABIVersion {
+			return nil, fmt.Errorf("unsupported ABI version %d, expected %d", *version, filter.ABIVersion)
+		}
+	}
+
+	sym, err := p.Lookup("NewFilter")
+	if err != nil {
+		return nil, err
+	}
+	newFilter, ok := sym.(func(map[string]interface{}) (filter.Filter, error))
+	if !ok {
+		return nil, fmt.Errorf("NewFilter has unexpected signature %T", sym)
+	}
+	return newFilter, nil
+}
+
+// parsePhase возвращает этап конвейера по имени из конфигурации
+func parsePhase(name string) (Phase, error) {
+	switch name {
+	case "auth":
+		return PhaseAuth, nil
+	case "ratelimit":
+		return PhaseRateLimit, nil
+	case "", "rewrite":
+		return PhaseRewrite, nil
+	default:
+		return 0, fmt.Errorf("unknown phase: %s", name)
+	}
+}
+
+// filterMiddleware адаптирует фильтр плагина к конвейеру прокси
+func filterMiddleware(f filter.Filter, cfg config.PluginConfig, appLogger logger.Logger) Middleware {
+	maxBodySize := cfg.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultMaxFilterBodySize
+	}
+	responseFilter, filtersResponses := f.(filter.ResponseFilter)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req := &filter.Request{
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Query:      r.URL.RawQuery,
+				RemoteAddr: r.RemoteAddr,
+				Header:     r.Header.Clone(),
+			}
+			if cfg.RequestBody && r.Body != nil {
+				body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+				if err != nil {
+					http.Error(w, "Failed to read request body", http.StatusBadRequest)
+					return
+				}
+				if int64(len(body)) > maxBodySize {
+					http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				req.Body = body
+			}
+
+			if resp := f.OnRequest(req); resp != nil {
+				appLogger.Debug(fmt.Sprintf("Плагин %s ответил на запрос %s %s со статусом %d", cfg.Name, r.Method, r.URL.Path, resp.StatusCode))
+				writeFilterResponse(w, resp)
+				return
+			}
+
+			// Применяем изменения фильтра к копии запроса
+			out := r.Clone(r.Context())
+			if req.Path != r.URL.Path {
+				out.URL.Path, out.URL.RawPath = req.Path, ""
+			}
+			out.URL.RawQuery = req.Query
+			out.Header = req.Header
+			if cfg.RequestBody && r.Body != nil {
+				out.Body = io.NopCloser(bytes.NewReader(req.Body))
+				out.ContentLength = int64(len(req.Body))
+				out.Header.Set("Content-Length", strconv.Itoa(len(req.Body)))
+			}
+
+			if !filtersResponses {
+				next.ServeHTTP(w, out)
+				return
+			}
+
+			rec := &filterResponseWriter{ResponseWriter: w, header: make(http.Header), limit: maxBodySize}
+			next.ServeHTTP(rec, out)
+			if rec.overflow {
+				appLogger.Debug(fmt.Sprintf("Ответ на запрос %s больше %d байт и передан без обработки плагином %s", r.URL.Path, maxBodySize, cfg.Name))
+				return
+			}
+
+			resp := &filter.Response{StatusCode: rec.status(), Header: rec.header, Body: rec.body.Bytes()}
+			responseFilter.OnResponse(req, resp)
+			writeFilterResponse(w, resp)
+		})
+	}
+}
+
+// writeFilterResponse отправляет клиенту ответ, сформированный фильтром
+func writeFilterResponse(w http.ResponseWriter, resp *filter.Response) {
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+
+	status := resp.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(resp.Body)
+}
+
+// filterResponseWriter буферизует ответ для обработки фильтром. Если ответ больше
+// лимита, накопленная часть отправляется клиенту и остаток передается без буферизации.
+type filterResponseWriter struct {
+	http.ResponseWriter
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+	limit      int64
+	overflow   bool
+}
+
+func (w *filterResponseWriter) Header() http.Header {
+	if w.overflow {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *filterResponseWriter) WriteHeader(statusCode int) {
+	if w.overflow {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+func (w *filterResponseWriter) Write(p []byte) (int, error) {
+	if w.overflow {
+		return w.ResponseWriter.Write(p)
+	}
+	if int64(w.body.Len()+len(p)) <= w.limit {
+		return w.body.Write(p)
+	}
+
+	// Ответ не помещается в буфер: отправляем накопленное и переходим к прямой передаче
+	w.overflow = true
+	for name, values := range w.header {
+		w.ResponseWriter.Header()[name] = values
+	}
+	w.ResponseWriter.WriteHeader(w.status())
+	if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// status возвращает записанный статус ответа (по умолчанию 200)
+func (w *filterResponseWriter) status() int {
+	if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
+}
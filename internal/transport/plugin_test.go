@@ -0,0 +1,55 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/filter"
+	"cloud.ru_test/pkg/logger"
+)
+
+// testFilter отклоняет запросы без токена, переписывает путь и дополняет ответ
+type testFilter struct{}
+
+func (testFilter) OnRequest(req *filter.Request) *filter.Response {
+	if req.Header.Get("X-Token") == "" {
+		return &filter.Response{StatusCode: http.StatusForbidden, Body: []byte("denied")}
+	}
+	req.Path = "/v2" + req.Path
+	req.Body = []byte(strings.ToUpper(string(req.Body)))
+	return nil
+}
+
+func (testFilter) OnResponse(req *filter.Request, resp *filter.Response) {
+	resp.Header.Set("X-Filtered", "true")
+	resp.Body = append(resp.Body, "!"...)
+}
+
+func TestFilterMiddleware(t *testing.T) {
+	handler := filterMiddleware(testFilter{}, config.PluginConfig{Name: "test", RequestBody: true}, logger.NewNop())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.Write([]byte(r.URL.Path + " " + string(body)))
+		}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("data")))
+	if rec.Code != http.StatusForbidden || rec.Body.String() != "denied" {
+		t.Errorf("фильтр должен отклонять запрос: %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("data"))
+	req.Header.Set("X-Token", "secret")
+	handler.ServeHTTP(rec, req)
+	if rec.Body.String() != "/v2/orders DATA!" {
+		t.Errorf("изменения фильтра не применены: %q", rec.Body.String())
+	}
+	if rec.Header().Get("X-Filtered") != "true" {
+		t.Error("фильтр ответа должен добавлять заголовок")
+	}
+}
@@ -0,0 +1,42 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// panicsRecovered счетчик паник, перехваченных при обработке запросов
+var panicsRecovered = metrics.Default.Counter("lb_panics_recovered_total", "Panics recovered while handling requests")
+
+// recoverPanic перехватывает панику в middleware и этапах обработки запроса: записывает
+// в лог стек вызовов с полями запроса и отвечает 500, не прерывая работу сервера.
+// Если ответ уже начат, соединение обрывается, чтобы клиент не принял неполный ответ за целый.
+func (p *Proxy) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				// Штатное прерывание ответа обрабатывает http.Server
+				panic(v)
+			}
+
+			panicsRecovered.Inc()
+			logger.FromContext(r.Context(), p.logger).Error(
+				fmt.Sprintf("Паника при обработке запроса %s %s: %v", r.Method, r.URL.Path, v),
+				"stack", string(debug.Stack()))
+			if sw.code != 0 {
+				panic(http.ErrAbortHandler)
+			}
+			http.Error(sw, "Internal Server Error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
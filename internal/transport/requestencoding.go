@@ -0,0 +1,181 @@
+package transport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Параметры middleware requestEncoding по умолчанию
+const (
+	defaultMaxDecompressedSize = 10 << 20
+	defaultCompressMinSize     = 1024
+)
+
+// requestBodiesRecoded счетчик тел запросов, распакованных (decompress) или сжатых (compress) прокси
+var requestBodiesRecoded = metrics.Default.Counter("lb_request_bodies_recoded_total", "Request bodies decompressed or compressed before forwarding", "action")
+
+// requestEncodingParams параметры middleware requestEncoding
+type requestEncodingParams struct {
+	// Маршруты; применяется первый, префиксу которого соответствует путь
+	Routes []requestEncodingRouteParams `yaml:"routes"`
+}
+
+// requestEncodingRouteParams преобразование тел запросов маршрута
+type requestEncodingRouteParams struct {
+	PathPrefix string `yaml:"pathPrefix"`
+
+	// Распаковывать тела с Content-Encoding: gzip для бэкендов, которые его не поддерживают
+	Decompress bool `yaml:"decompress"`
+
+	// Максимальный размер распакованного тела (по умолчанию 10MB)
+	MaxDecompressedSize int64 `yaml:"maxDecompressedSize"`
+
+	// Сжимать gzip несжатые тела для бэкендов, которые принимают Content-Encoding: gzip
+	Compress bool `yaml:"compress"`
+
+	// Минимальный размер сжимаемого тела (по умолчанию 1KB); тела неизвестной длины сжимаются всегда
+	MinSize int64 `yaml:"minSize"`
+
+	// Степень сжатия от 1 до 9 (по умолчанию gzip.DefaultCompression)
+	Level int `yaml:"level"`
+}
+
+// requestEncoder распаковывает или сжимает тела запросов к бэкендам маршрута
+type requestEncoder struct {
+	routes []requestEncodingRouteParams
+	logger logger.Logger
+}
+
+// newRequestEncodingMiddleware создает middleware преобразования тел запросов
+func newRequestEncodingMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params requestEncodingParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	if len(params.Routes) == 0 {
+		return nil, fmt.Errorf("requestEncoding: at least one route is required")
+	}
+	for i := range params.Routes {
+		route := &params.Routes[i]
+		switch {
+		case route.Decompress == route.Compress:
+			return nil, fmt.Errorf("requestEncoding: routes[%d] must set either decompress or compress", i)
+		case route.MaxDecompressedSize < 0:
+			return nil, fmt.Errorf("requestEncoding: routes[%d].maxDecompressedSize must not be negative", i)
+		case route.MinSize < 0:
+			return nil, fmt.Errorf("requestEncoding: routes[%d].minSize must not be negative", i)
+		case route.Level < 0 || route.Level > gzip.BestCompression:
+			return nil, fmt.Errorf("requestEncoding: routes[%d].level must be between 1 and 9", i)
+		}
+		if route.MaxDecompressedSize == 0 {
+			route.MaxDecompressedSize = defaultMaxDecompressedSize
+		}
+		if route.MinSize == 0 {
+			route.MinSize = defaultCompressMinSize
+		}
+		if route.Level == 0 {
+			route.Level = gzip.DefaultCompression
+		}
+	}
+	e := &requestEncoder{routes: params.Routes, logger: appLogger}
+	return e.middleware, nil
+}
+
+// route возвращает маршрут запроса или nil
+func (e *requestEncoder) route(r *http.Request) *requestEncodingRouteParams {
+	for i, route := range e.routes {
+		if strings.HasPrefix(r.URL.Path, route.PathPrefix) {
+			return &e.routes[i]
+		}
+	}
+	return nil
+}
+
+func (e *requestEncoder) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := e.route(r)
+		if route == nil || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		switch {
+		case route.Decompress && (encoding == "gzip" || encoding == "x-gzip"):
+			if !e.decompress(w, r, route) {
+				return
+			}
+		case route.Compress && (encoding == "" || encoding == "identity") &&
+			(r.ContentLength < 0 || r.ContentLength >= route.MinSize):
+			// Если запрос не дошел до бэкенда, сжатие останавливается вместе с обработкой
+			defer compress(r, route.Level).Close()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decompress заменяет тело запроса распакованным. Тело читается целиком, чтобы передать
+// бэкенду Content-Length: старые бэкенды, не понимающие gzip, часто не принимают
+// и chunked. Если тело повреждено или больше лимита, клиент получает ошибку и
+// decompress возвращает false.
+func (e *requestEncoder) decompress(w http.ResponseWriter, r *http.Request, route *requestEncodingRouteParams) bool {
+	log := logger.FromContext(r.Context(), e.logger)
+
+	zr, err := gzip.NewReader(r.Body)
+	if err != nil {
+		log.Debug(fmt.Sprintf("Некорректное gzip тело запроса %s %s: %v", r.Method, r.URL.Path, err))
+		http.Error(w, "Invalid gzip request body", http.StatusBadRequest)
+		return false
+	}
+	body, err := io.ReadAll(io.LimitReader(zr, route.MaxDecompressedSize+1))
+	if err != nil {
+		log.Debug(fmt.Sprintf("Некорректное gzip тело запроса %s %s: %v", r.Method, r.URL.Path, err))
+		http.Error(w, "Invalid gzip request body", http.StatusBadRequest)
+		return false
+	}
+	if int64(len(body)) > route.MaxDecompressedSize {
+		log.Warn(fmt.Sprintf("Распакованное тело запроса %s %s больше %d байт", r.Method, r.URL.Path, route.MaxDecompressedSize))
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return false
+	}
+	r.Body.Close()
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	r.Header.Del("Content-Encoding")
+	requestBodiesRecoded.Inc("decompress")
+	return true
+}
+
+// compress заменяет тело запроса потоком, сжатым gzip, и возвращает новое тело.
+// Тело сжимается по мере отправки бэкенду, поэтому его длина заранее неизвестна.
+func compress(r *http.Request, level int) io.Closer {
+	body := r.Body
+	pr, pw := io.Pipe()
+	go func() {
+		zw, _ := gzip.NewWriterLevel(pw, level)
+		_, err := io.Copy(zw, body)
+		if closeErr := zw.Close(); err == nil {
+			err = closeErr
+		}
+		body.Close()
+		pw.CloseWithError(err)
+	}()
+
+	r.Body = pr
+	r.ContentLength = -1
+	r.Header.Del("Content-Length")
+	r.Header.Set("Content-Encoding", "gzip")
+	requestBodiesRecoded.Inc("compress")
+	return pr
+}
@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestRequestEncoding(t *testing.T) {
+	m, err := newRequestEncodingMiddleware(config.MiddlewareConfig{Name: "requestEncoding", Params: map[string]interface{}{
+		"routes": []interface{}{
+			map[string]interface{}{"pathPrefix": "/legacy", "decompress": true, "maxDecompressedSize": 64},
+			map[string]interface{}{"pathPrefix": "/upload", "compress": true, "minSize": 16},
+		},
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got *http.Request
+	var body []byte
+	handler := m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	serve := func(path, encoding string, payload []byte) *httptest.ResponseRecorder {
+		got, body = nil, nil
+		req := httptest.NewRequest("POST", path, bytes.NewReader(payload))
+		if encoding != "" {
+			req.Header.Set("Content-Encoding", encoding)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Тело распаковывается, бэкенд получает его длину
+	serve("/legacy/orders", "gzip", gzipped(t, "hello legacy"))
+	if string(body) != "hello legacy" || got.ContentLength != 12 || got.Header.Get("Content-Encoding") != "" {
+		t.Errorf("тело должно быть распаковано: %q, длина %d, %v", body, got.ContentLength, got.Header)
+	}
+
+	if rec := serve("/legacy/orders", "gzip", []byte("not gzip")); rec.Code != http.StatusBadRequest || got != nil {
+		t.Errorf("поврежденное тело должно отклоняться с 400, получено %d", rec.Code)
+	}
+	if rec := serve("/legacy/orders", "gzip", gzipped(t, strings.Repeat("x", 65))); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("тело больше лимита должно отклоняться с 413, получено %d", rec.Code)
+	}
+
+	// Большое тело сжимается, маленькое передается как есть
+	payload := strings.Repeat("compressible ", 10)
+	serve("/upload/file", "", []byte(payload))
+	if got.Header.Get("Content-Encoding") != "gzip" || got.ContentLength != -1 {
+		t.Fatalf("тело должно быть сжато: %v", got.Header)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, _ := io.ReadAll(zr); string(plain) != payload {
+		t.Errorf("сжатое тело не совпадает с исходным: %q", plain)
+	}
+
+	serve("/upload/file", "", []byte("small"))
+	if got.Header.Get("Content-Encoding") != "" || string(body) != "small" {
+		t.Errorf("тело меньше minSize не должно сжиматься: %q", body)
+	}
+}
@@ -0,0 +1,149 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// errResponseTooLarge ошибка записи тела ответа сверх лимита маршрута
+var errResponseTooLarge = errors.New("response body exceeds the route limit")
+
+// responsesTooLarge счетчик ответов бэкендов, превысивших лимит размера: до начала
+// ответа по Content-Length (headers) или во время передачи тела (stream)
+var responsesTooLarge = metrics.Default.Counter("lb_responses_too_large_total", "Backend responses aborted for exceeding the size limit", "stage")
+
+// responseLimitParams параметры middleware responseLimit
+type responseLimitParams struct {
+	// Маршруты с лимитом; применяется первый, префиксу которого соответствует путь
+	Routes []responseLimitRouteParams `yaml:"routes"`
+}
+
+// responseLimitRouteParams лимит размера ответа маршрута
+type responseLimitRouteParams struct {
+	PathPrefix string `yaml:"pathPrefix"`
+
+	// Максимальный размер тела ответа в байтах
+	MaxBodySize int64 `yaml:"maxBodySize"`
+}
+
+// responseLimiter прерывает ответы бэкендов, тело которых превышает лимит маршрута,
+// защищая прокси и клиентов от бесконечных или неожиданно больших ответов
+type responseLimiter struct {
+	routes []responseLimitRouteParams
+	logger logger.Logger
+}
+
+// newResponseLimitMiddleware создает middleware ограничения размера ответов
+func newResponseLimitMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params responseLimitParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	if len(params.Routes) == 0 {
+		return nil, fmt.Errorf("responseLimit: at least one route is required")
+	}
+	for i, route := range params.Routes {
+		if route.MaxBodySize <= 0 {
+			return nil, fmt.Errorf("responseLimit: routes[%d].maxBodySize must be positive", i)
+		}
+	}
+	l := &responseLimiter{routes: params.Routes, logger: appLogger}
+	return l.middleware, nil
+}
+
+// limit возвращает лимит размера ответа на запрос или 0
+func (l *responseLimiter) limit(r *http.Request) int64 {
+	for _, route := range l.routes {
+		if strings.HasPrefix(r.URL.Path, route.PathPrefix) {
+			return route.MaxBodySize
+		}
+	}
+	return 0
+}
+
+func (l *responseLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := l.limit(r)
+		if limit == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		lw := &limitWriter{ResponseWriter: w, limit: limit}
+		next.ServeHTTP(lw, r)
+
+		switch {
+		case lw.rejected:
+			responsesTooLarge.Inc("headers")
+			logger.FromContext(r.Context(), l.logger).Warn(fmt.Sprintf("Ответ на запрос %s %s больше лимита %d байт, клиент получил 502", r.Method, r.URL.Path, limit))
+		case lw.exceeded:
+			responsesTooLarge.Inc("stream")
+			logger.FromContext(r.Context(), l.logger).Warn(fmt.Sprintf("Передача ответа на запрос %s %s прервана после %d байт: превышен лимит", r.Method, r.URL.Path, lw.written))
+			// Статус уже отправлен: соединение обрывается, чтобы клиент не принял неполный ответ за целый
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+// limitWriter считает байты тела ответа. Ответ с Content-Length больше лимита заменяется
+// на 502, запись тела сверх лимита завершается ошибкой errResponseTooLarge, и прокси
+// перестает читать ответ бэкенда.
+type limitWriter struct {
+	http.ResponseWriter
+	limit       int64
+	written     int64
+	wroteHeader bool
+
+	// Ответ заменен на 502 до отправки статуса
+	rejected bool
+
+	// Лимит превышен во время передачи тела
+	exceeded bool
+}
+
+func (w *limitWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if length, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil && length > w.limit {
+		w.rejected = true
+		for _, name := range []string{"Content-Encoding", "Content-Range", "ETag", "Last-Modified"} {
+			w.Header().Del(name)
+		}
+		http.Error(w.ResponseWriter, "Backend response too large", http.StatusBadGateway)
+		return
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.rejected || w.exceeded {
+		return 0, errResponseTooLarge
+	}
+	if w.written+int64(len(p)) > w.limit {
+		w.exceeded = true
+		n, _ := w.ResponseWriter.Write(p[:w.limit-w.written])
+		w.written += int64(n)
+		return n, errResponseTooLarge
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// Unwrap возвращает исходный ResponseWriter для http.ResponseController
+func (w *limitWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
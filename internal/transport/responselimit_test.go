@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestResponseLimit(t *testing.T) {
+	m, err := newResponseLimitMiddleware(config.MiddlewareConfig{Name: "responseLimit", Params: map[string]interface{}{
+		"routes": []interface{}{map[string]interface{}{"pathPrefix": "/export", "maxBodySize": 8}},
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var writeErr error
+	handler := m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := strings.Repeat("x", 16)
+		if r.URL.Query().Get("length") != "" {
+			w.Header().Set("Content-Length", "16")
+		}
+		if r.URL.Query().Get("small") != "" {
+			body = "ok"
+		}
+		_, writeErr = w.Write([]byte(body))
+	}))
+	serve := func(path string) (rec *httptest.ResponseRecorder, aborted bool) {
+		defer func() {
+			aborted = recover() == http.ErrAbortHandler
+		}()
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec, false
+	}
+
+	// Content-Length больше лимита: клиент получает 502 вместо ответа
+	rec, aborted := serve("/export?length=1")
+	if aborted || rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "too large") || writeErr != errResponseTooLarge {
+		t.Errorf("ожидался ответ 502, получено %d %q (прервано: %v, ошибка записи: %v)", rec.Code, rec.Body.String(), aborted, writeErr)
+	}
+
+	// Тело без Content-Length передается до лимита, затем соединение обрывается
+	rec, aborted = serve("/export")
+	if !aborted || rec.Body.Len() != 8 || writeErr != errResponseTooLarge {
+		t.Errorf("ответ сверх лимита должен прерываться после 8 байт: передано %d (прервано: %v)", rec.Body.Len(), aborted)
+	}
+
+	if rec, aborted := serve("/export?small=1"); aborted || rec.Body.String() != "ok" {
+		t.Errorf("ответ в пределах лимита должен передаваться: %q", rec.Body.String())
+	}
+	if rec, aborted := serve("/other"); aborted || rec.Body.Len() != 16 {
+		t.Errorf("ответы вне маршрутов не должны ограничиваться: %d", rec.Body.Len())
+	}
+}
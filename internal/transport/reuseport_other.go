@@ -0,0 +1,13 @@
+//go:build !unix || solaris
+
+package transport
+
+import (
+	"errors"
+	"syscall"
+)
+
+// reusePortControl не поддерживается на этой платформе
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is not supported on this platform")
+}
@@ -0,0 +1,217 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/apikeys"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+)
+
+// Параметры аутентификации маршрутов по умолчанию
+const (
+	defaultAPIKeyHeader = "X-API-Key"
+	jwtRequestTimeout   = 10 * time.Second
+)
+
+// routeAuthRejected счетчик запросов, отклоненных аутентификацией маршрутов
+var routeAuthRejected = metrics.Default.Counter("lb_route_auth_rejected_total", "Requests rejected by route authentication", "route", "type")
+
+// routeAuth способы аутентификации из секции routing.auth, общие для всех маршрутов
+type routeAuth struct {
+	apiKeyHeader string
+	apiKeys      *apikeys.Manager
+
+	jwt     *config.JWTAuthConfig
+	jwtKeys *jwksSource
+}
+
+// newRouteAuth создает способы аутентификации маршрутов; keys проверяет ключи API
+func newRouteAuth(cfg *config.RouteAuthProvidersConfig, keys *apikeys.Manager) *routeAuth {
+	a := &routeAuth{apiKeys: keys}
+	if cfg == nil {
+		return a
+	}
+	if cfg.APIKeys != nil {
+		a.apiKeyHeader = cfg.APIKeys.Header
+		if a.apiKeyHeader == "" {
+			a.apiKeyHeader = defaultAPIKeyHeader
+		}
+	}
+	if cfg.JWT != nil {
+		a.jwt = cfg.JWT
+		a.jwtKeys = &jwksSource{client: &http.Client{Timeout: jwtRequestTimeout}}
+	}
+	return a
+}
+
+// middleware возвращает middleware этапа auth, требующий от клиентов маршрута route
+// аутентификации cfg; nil — аутентификация не требуется
+func (a *routeAuth) middleware(route string, cfg *config.RouteAuthConfig, appLogger logger.Logger) (Middleware, error) {
+	if cfg == nil || cfg.Type == "none" {
+		return nil, nil
+	}
+
+	var authenticate func(r *http.Request) (identity string, claims map[string]interface{}, status int, reason string)
+	switch cfg.Type {
+	case "apiKey":
+		if a.apiKeys == nil {
+			return nil, fmt.Errorf("route %s: apiKey auth requires routing.auth.apiKeys", route)
+		}
+		authenticate = func(r *http.Request) (string, map[string]interface{}, int, string) {
+			return a.apiKey(r, route)
+		}
+	case "jwt":
+		if a.jwt == nil {
+			return nil, fmt.Errorf("route %s: jwt auth requires routing.auth.jwt", route)
+		}
+		authenticate = func(r *http.Request) (string, map[string]interface{}, int, string) {
+			return a.bearer(r, cfg.Scopes)
+		}
+	case "mtls":
+		names := make(map[string]bool, len(cfg.ClientNames))
+		for _, name := range cfg.ClientNames {
+			names[name] = true
+		}
+		authenticate = func(r *http.Request) (string, map[string]interface{}, int, string) {
+			return clientCertificate(r, names)
+		}
+	default:
+		return nil, fmt.Errorf("route %s: unsupported auth type %q", route, cfg.Type)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, claims, status, reason := authenticate(r)
+			if status != 0 {
+				routeAuthRejected.Inc(route, cfg.Type)
+				if log := logger.FromContext(r.Context(), appLogger); log.DebugEnabled() {
+					log.Debug(fmt.Sprintf("Запрос к маршруту %s отклонен аутентификацией %s: %s", route, cfg.Type, reason))
+				}
+				switch {
+				case status == http.StatusUnauthorized && cfg.Type == "jwt":
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				case status == http.StatusForbidden && cfg.Type == "jwt":
+					w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, strings.Join(cfg.Scopes, " ")))
+				}
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+
+			if req := request.FromContext(r.Context()); req != nil {
+				request.Set(req, request.KeyUserID, identity)
+				request.Set(req, request.KeyClaims, claims)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// apiKey находит клиента по ключу из заголовка и проверяет доступ ключа к маршруту
+// и его ограничение частоты запросов. Ключ не передается бэкенду.
+func (a *routeAuth) apiKey(r *http.Request, route string) (string, map[string]interface{}, int, string) {
+	secret := r.Header.Get(a.apiKeyHeader)
+	if secret == "" {
+		return "", nil, http.StatusUnauthorized, "no API key"
+	}
+	key, err := a.apiKeys.Authenticate(secret)
+	if err != nil {
+		return "", nil, http.StatusUnauthorized, err.Error()
+	}
+	if !key.AllowsRoute(route) {
+		return "", nil, http.StatusForbidden, fmt.Sprintf("API key %s has no access to the route", key.ID)
+	}
+	if !a.apiKeys.Allow(key) {
+		return "", nil, http.StatusTooManyRequests, fmt.Sprintf("API key %s exceeded its rate limit", key.ID)
+	}
+	r.Header.Del(a.apiKeyHeader)
+	return key.Name, map[string]interface{}{"sub": key.Name, "key_id": key.ID}, 0, ""
+}
+
+// bearer проверяет JWT из заголовка Authorization и наличие в нем scopes
+func (a *routeAuth) bearer(r *http.Request, scopes []string) (string, map[string]interface{}, int, string) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", nil, http.StatusUnauthorized, "no bearer token"
+	}
+
+	claims := make(map[string]interface{})
+	if err := verifyJWT(token, func(kid string) (interface{}, error) {
+		return a.jwtKeys.key(r.Context(), a.jwt.JWKSURL, kid)
+	}, &claims); err != nil {
+		return "", nil, http.StatusUnauthorized, err.Error()
+	}
+
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); ok && exp <= now {
+		return "", nil, http.StatusUnauthorized, "token expired"
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && nbf > now {
+		return "", nil, http.StatusUnauthorized, "token is not valid yet"
+	}
+	if a.jwt.Issuer != "" && claims["iss"] != a.jwt.Issuer {
+		return "", nil, http.StatusUnauthorized, fmt.Sprintf("unexpected issuer %v", claims["iss"])
+	}
+	if a.jwt.Audience != "" && !claimValues(claims["aud"])[a.jwt.Audience] {
+		return "", nil, http.StatusUnauthorized, fmt.Sprintf("token is not issued for %s", a.jwt.Audience)
+	}
+
+	granted := claimValues(claims["scope"])
+	for scope := range claimValues(claims["scp"]) {
+		granted[scope] = true
+	}
+	for _, scope := range scopes {
+		if !granted[scope] {
+			return "", nil, http.StatusForbidden, fmt.Sprintf("token has no scope %s", scope)
+		}
+	}
+
+	subject, _ := claims["sub"].(string)
+	return subject, claims, 0, ""
+}
+
+// claimValues возвращает значения утверждения: строки, разделенной пробелами, или массива строк
+func claimValues(claim interface{}) map[string]bool {
+	values := make(map[string]bool)
+	switch claim := claim.(type) {
+	case string:
+		for _, value := range strings.Fields(claim) {
+			values[value] = true
+		}
+	case []interface{}:
+		for _, value := range claim {
+			if s, ok := value.(string); ok {
+				values[s] = true
+			}
+		}
+	}
+	return values
+}
+
+// clientCertificate проверяет клиентский сертификат, подписанный CA listener'а.
+// names — допустимые CN или DNS имена SAN; пустой — любой проверенный сертификат.
+func clientCertificate(r *http.Request, names map[string]bool) (string, map[string]interface{}, int, string) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return "", nil, http.StatusForbidden, "no verified client certificate"
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+
+	allowed := len(names) == 0 || names[cert.Subject.CommonName]
+	for _, name := range cert.DNSNames {
+		allowed = allowed || names[name]
+	}
+	if !allowed {
+		return "", nil, http.StatusForbidden, fmt.Sprintf("client certificate %s is not allowed", cert.Subject.CommonName)
+	}
+
+	dnsNames := make([]interface{}, len(cert.DNSNames))
+	for i, name := range cert.DNSNames {
+		dnsNames[i] = name
+	}
+	return cert.Subject.CommonName, map[string]interface{}{"sub": cert.Subject.CommonName, "dns_names": dnsNames}, 0, ""
+}
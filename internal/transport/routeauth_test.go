@@ -0,0 +1,204 @@
+package transport
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/apikeys"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+)
+
+// routeAuthHandler оборачивает в аутентификацию маршрута обработчик, возвращающий
+// пользователя запроса и заголовок X-API-Key, дошедший до бэкенда
+func routeAuthHandler(t *testing.T, auth *routeAuth, cfg *config.RouteAuthConfig) http.Handler {
+	t.Helper()
+	mw, err := auth.middleware("private", cfg, logger.NewNop())
+	if err != nil {
+		t.Fatalf("middleware должен создаваться: %v", err)
+	}
+	return mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Key", r.Header.Get("X-API-Key"))
+		w.Write([]byte(request.FromContext(r.Context()).GetUserID()))
+	}))
+}
+
+// serveRouteAuth выполняет запрос с контекстом запроса прокси
+func serveRouteAuth(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r.WithContext(request.NewContext(r.Context(), request.NewRequest(r, nil))))
+	return rec
+}
+
+func TestRouteAuth_APIKey(t *testing.T) {
+	providers := &config.RouteAuthProvidersConfig{APIKeys: &config.APIKeyAuthConfig{
+		Keys: []config.APIKeyConfig{
+			{Name: "billing", Key: "0123456789abcdef"},
+			{Name: "reports", Hash: apikeys.HashKey("reports-key-0123456"), Routes: []string{"reports"}},
+			{Name: "limited", Key: "limited-key-0123456", RateLimit: &config.APIKeyRateLimitConfig{Rate: 0.001, Burst: 1}},
+		},
+	}}
+	keys, err := apikeys.New(providers.APIKeys, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := newRouteAuth(providers, keys)
+	h := routeAuthHandler(t, auth, &config.RouteAuthConfig{Type: "apiKey"})
+
+	tests := []struct {
+		key    string
+		status int
+		user   string
+	}{
+		{"", http.StatusUnauthorized, ""},
+		{"fedcba9876543210", http.StatusUnauthorized, ""},
+		{"0123456789abcdef", http.StatusOK, "billing"},
+		{"reports-key-0123456", http.StatusForbidden, ""},
+		{"limited-key-0123456", http.StatusOK, "limited"},
+		{"limited-key-0123456", http.StatusTooManyRequests, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/private", nil)
+		if tt.key != "" {
+			r.Header.Set("X-API-Key", tt.key)
+		}
+		rec := serveRouteAuth(h, r)
+		if rec.Code != tt.status {
+			t.Errorf("ключ %q: ожидался статус %d, получен %d", tt.key, tt.status, rec.Code)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		if rec.Body.String() != tt.user {
+			t.Errorf("ключ %q: ожидался пользователь %q, получен %q", tt.key, tt.user, rec.Body.String())
+		}
+		if rec.Header().Get("X-Key") != "" {
+			t.Error("ключ API не должен передаваться бэкенду")
+		}
+	}
+
+	// Ключ с ограничением маршрутов проходит на свой маршрут
+	mw, err := auth.middleware("reports", &config.RouteAuthConfig{Type: "apiKey"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("GET", "/reports", nil)
+	r.Header.Set("X-API-Key", "reports-key-0123456")
+	if rec := serveRouteAuth(mw(http.NotFoundHandler()), r); rec.Code != http.StatusNotFound {
+		t.Errorf("ключ должен давать доступ к маршруту reports, получен статус %d", rec.Code)
+	}
+
+	if mw, err := auth.middleware("public", &config.RouteAuthConfig{Type: "none"}, logger.NewNop()); err != nil || mw != nil {
+		t.Errorf("для auth.type: none middleware не нужен: %v", err)
+	}
+}
+
+func TestRouteAuth_JWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "test", "kty": "RSA", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer issuer.Close()
+
+	auth := newRouteAuth(&config.RouteAuthProvidersConfig{JWT: &config.JWTAuthConfig{
+		JWKSURL:  issuer.URL,
+		Issuer:   "https://issuer.example",
+		Audience: "orders",
+	}}, nil)
+	h := routeAuthHandler(t, auth, &config.RouteAuthConfig{Type: "jwt", Scopes: []string{"orders:write"}})
+
+	token := func(claims map[string]interface{}) string {
+		base := map[string]interface{}{
+			"iss": "https://issuer.example", "aud": []interface{}{"orders"}, "sub": "user-1",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for name, value := range claims {
+			base[name] = value
+		}
+		return signTestJWT(t, key, base)
+	}
+
+	tests := []struct {
+		name      string
+		header    string
+		status    int
+		challenge string
+	}{
+		{"без токена", "", http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"испорченный токен", "Bearer " + token(map[string]interface{}{"scope": "orders:write"}) + "x", http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"истекший токен", "Bearer " + token(map[string]interface{}{"scope": "orders:write", "exp": time.Now().Add(-time.Minute).Unix()}), http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"чужой audience", "Bearer " + token(map[string]interface{}{"scope": "orders:write", "aud": "billing"}), http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"без scope", "Bearer " + token(map[string]interface{}{"scope": "orders:read"}), http.StatusForbidden, `Bearer error="insufficient_scope", scope="orders:write"`},
+		{"scope строкой", "Bearer " + token(map[string]interface{}{"scope": "orders:read orders:write"}), http.StatusOK, ""},
+		{"scp массивом", "Bearer " + token(map[string]interface{}{"scp": []interface{}{"orders:write"}}), http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/orders", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		rec := serveRouteAuth(h, r)
+		if rec.Code != tt.status {
+			t.Errorf("%s: ожидался статус %d, получен %d", tt.name, tt.status, rec.Code)
+			continue
+		}
+		if got := rec.Header().Get("WWW-Authenticate"); got != tt.challenge {
+			t.Errorf("%s: ожидался WWW-Authenticate %q, получен %q", tt.name, tt.challenge, got)
+		}
+		if tt.status == http.StatusOK && rec.Body.String() != "user-1" {
+			t.Errorf("%s: пользователем запроса должен стать sub токена, получен %q", tt.name, rec.Body.String())
+		}
+	}
+}
+
+func TestRouteAuth_MTLS(t *testing.T) {
+	h := routeAuthHandler(t, newRouteAuth(nil, nil), &config.RouteAuthConfig{Type: "mtls", ClientNames: []string{"billing.internal"}})
+
+	withCert := func(cn string, dnsNames ...string) *http.Request {
+		r := httptest.NewRequest("GET", "/internal", nil)
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+			{Subject: pkix.Name{CommonName: cn}, DNSNames: dnsNames},
+		}}}
+		return r
+	}
+
+	tests := []struct {
+		name   string
+		r      *http.Request
+		status int
+	}{
+		{"без TLS", httptest.NewRequest("GET", "/internal", nil), http.StatusForbidden},
+		{"без сертификата", func() *http.Request {
+			r := httptest.NewRequest("GET", "/internal", nil)
+			r.TLS = &tls.ConnectionState{}
+			return r
+		}(), http.StatusForbidden},
+		{"чужой сертификат", withCert("orders", "orders.internal"), http.StatusForbidden},
+		{"по CN", withCert("billing.internal"), http.StatusOK},
+		{"по SAN", withCert("billing", "billing.internal"), http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := serveRouteAuth(h, tt.r); rec.Code != tt.status {
+			t.Errorf("%s: ожидался статус %d, получен %d", tt.name, tt.status, rec.Code)
+		}
+	}
+}
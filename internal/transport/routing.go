@@ -0,0 +1,314 @@
+package transport
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/apikeys"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+)
+
+// routeRequests счетчик запросов по маршрутам
+var routeRequests = metrics.Default.Counter("lb_route_requests_total", "Requests by route; requests matching no route are counted as unmatched", "route")
+
+// route разобранное правило маршрутизации
+type route struct {
+	name string
+
+	// Позиция правила в порядке проверки: из подходящих правил выбирается правило
+	// с наименьшим rank
+	rank int
+
+	path       string
+	pathPrefix string
+	pathRegex  *regexp.Regexp
+	methods    map[string]bool
+	headers    map[string]string
+	query      map[string]string
+	clientIPs  []netip.Prefix
+	weight     float64
+
+	// ID бэкендов маршрута; nil — все бэкенды
+	backends map[string]bool
+
+	// Собственные middleware маршрута
+	chain *Chain
+}
+
+// matches проверяет условия правила, кроме пути: путь проверяет индекс Router
+func (rt *route) matches(r *http.Request) bool {
+	if rt.pathRegex != nil && !rt.pathRegex.MatchString(r.URL.Path) {
+		return false
+	}
+	if len(rt.methods) > 0 && !rt.methods[r.Method] {
+		return false
+	}
+	for name, value := range rt.headers {
+		if !matchValue(r.Header.Values(name), value) {
+			return false
+		}
+	}
+	if len(rt.query) > 0 {
+		query := r.URL.Query()
+		for name, value := range rt.query {
+			if !matchValue(query[name], value) {
+				return false
+			}
+		}
+	}
+	if len(rt.clientIPs) > 0 && !rt.matchesClient(r) {
+		return false
+	}
+	return rt.weight == 0 || rand.Float64()*100 < rt.weight
+}
+
+// matchesClient проверяет, что адрес клиента входит в подсети правила
+func (rt *route) matchesClient(r *http.Request) bool {
+	addr, err := netip.ParseAddr(clientIP(r))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range rt.clientIPs {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchValue проверяет, что среди значений есть ожидаемое; "*" — любое значение
+func matchValue(values []string, expected string) bool {
+	if expected == "*" {
+		return len(values) > 0
+	}
+	for _, value := range values {
+		if value == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixNode узел префиксного дерева путей
+type prefixNode struct {
+	children map[byte]*prefixNode
+
+	// Правила с префиксом, заканчивающимся в этом узле, в порядке rank
+	routes []*route
+}
+
+// Router выбирает маршрут запроса по правилам из секции routing. Правила разбираются
+// при загрузке конфигурации и индексируются по пути: правила с точным путем — в таблице,
+// с префиксом — в префиксном дереве, поэтому на запрос проверяются только правила,
+// путь которых ему подходит, и правила без пути или с регулярным выражением.
+type Router struct {
+	routes []*route
+
+	exact  map[string][]*route
+	prefix *prefixNode
+	other  []*route
+
+	// Маршруты TLS соединений, передаваемых бэкендам без расшифровки
+	passthrough []*passthroughRoute
+}
+
+// NewRouter разбирает правила маршрутизации и создает middleware маршрутов. keys проверяет
+// ключи API маршрутов с auth.type: apiKey; nil — такие маршруты не поддерживаются.
+func NewRouter(cfg *config.RoutingConfig, keys *apikeys.Manager, appLogger logger.Logger) (*Router, error) {
+	rules := append([]config.RouteConfig(nil), cfg.Rules...)
+	if cfg.Order == "priority" {
+		sort.SliceStable(rules, func(i, j int) bool {
+			return rules[i].Priority > rules[j].Priority
+		})
+	}
+
+	router := &Router{
+		exact:  make(map[string][]*route),
+		prefix: &prefixNode{},
+	}
+	auth := newRouteAuth(cfg.Auth, keys)
+	for rank, rule := range rules {
+		rt, err := newRoute(rule, rank, auth, appLogger)
+		if err != nil {
+			return nil, err
+		}
+		router.routes = append(router.routes, rt)
+
+		switch {
+		case rt.path != "":
+			router.exact[rt.path] = append(router.exact[rt.path], rt)
+		case rt.pathPrefix != "":
+			node := router.prefix
+			for i := 0; i < len(rt.pathPrefix); i++ {
+				if node.children == nil {
+					node.children = make(map[byte]*prefixNode)
+				}
+				child := node.children[rt.pathPrefix[i]]
+				if child == nil {
+					child = &prefixNode{}
+					node.children[rt.pathPrefix[i]] = child
+				}
+				node = child
+			}
+			node.routes = append(node.routes, rt)
+		default:
+			router.other = append(router.other, rt)
+		}
+	}
+	for _, rule := range cfg.Passthrough {
+		router.passthrough = append(router.passthrough, newPassthroughRoute(rule))
+	}
+	return router, nil
+}
+
+// newRoute разбирает правило маршрутизации
+func newRoute(rule config.RouteConfig, rank int, auth *routeAuth, appLogger logger.Logger) (*route, error) {
+	match := rule.Match
+	rt := &route{
+		name:       rule.Name,
+		rank:       rank,
+		path:       match.Path,
+		pathPrefix: match.PathPrefix,
+		query:      match.Query,
+	}
+
+	if match.PathRegex != "" {
+		re, err := regexp.Compile(match.PathRegex)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: invalid pathRegex: %w", rule.Name, err)
+		}
+		rt.pathRegex = re
+	}
+	if len(match.Methods) > 0 {
+		rt.methods = make(map[string]bool, len(match.Methods))
+		for _, method := range match.Methods {
+			rt.methods[method] = true
+		}
+	}
+	if len(match.Headers) > 0 {
+		rt.headers = make(map[string]string, len(match.Headers))
+		for name, value := range match.Headers {
+			rt.headers[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+	for _, ip := range match.ClientIPs {
+		var prefix netip.Prefix
+		var err error
+		if strings.Contains(ip, "/") {
+			prefix, err = netip.ParsePrefix(ip)
+		} else {
+			var addr netip.Addr
+			if addr, err = netip.ParseAddr(ip); err == nil {
+				addr = addr.Unmap()
+				prefix = netip.PrefixFrom(addr, addr.BitLen())
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("route %s: invalid client IP %q: %w", rule.Name, ip, err)
+		}
+		rt.clientIPs = append(rt.clientIPs, prefix.Masked())
+	}
+	if match.Weight != nil && *match.Weight < 100 {
+		rt.weight = *match.Weight
+	}
+
+	if len(rule.Backends) > 0 {
+		rt.backends = make(map[string]bool, len(rule.Backends))
+		for _, id := range rule.Backends {
+			rt.backends[id] = true
+		}
+	}
+
+	// Аутентификация маршрута выполняется первой из его middleware этапа auth
+	authMiddleware, err := auth.middleware(rule.Name, rule.Auth, appLogger)
+	if err != nil {
+		return nil, err
+	}
+	if authMiddleware != nil {
+		rt.chain = &Chain{}
+		rt.chain.Add("auth:"+rule.Auth.Type, PhaseAuth, authMiddleware)
+	}
+	if len(rule.Middlewares) > 0 {
+		chain, err := NewChain(rule.Middlewares, appLogger)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", rule.Name, err)
+		}
+		rt.chain = rt.chain.join(chain)
+	}
+	return rt, nil
+}
+
+// match возвращает маршрут запроса или nil, если запросу не подходит ни одно правило
+func (rt *Router) match(r *http.Request) *route {
+	path := r.URL.Path
+	var matched *route
+
+	// Из каждого списка берется первое подходящее правило: списки упорядочены по rank
+	consider := func(routes []*route) {
+		for _, candidate := range routes {
+			if matched != nil && candidate.rank > matched.rank {
+				return
+			}
+			if candidate.matches(r) {
+				matched = candidate
+				return
+			}
+		}
+	}
+
+	consider(rt.exact[path])
+	node := rt.prefix
+	for i := 0; node != nil; i++ {
+		consider(node.routes)
+		if i == len(path) {
+			break
+		}
+		node = node.children[path[i]]
+	}
+	consider(rt.other)
+	return matched
+}
+
+// routes собирает конвейеры маршрутов вокруг обработчика balance → proxy и возвращает
+// обработчик, направляющий запрос в конвейер его маршрута. Без правил маршрутизации
+// все запросы обрабатываются одним конвейером.
+func (p *Proxy) routes(router *Router, next http.Handler, chain *Chain) http.Handler {
+	if router == nil {
+		return buildHandler(next, p.ratelimit, chain, p.logger)
+	}
+
+	handlers := make(map[*route]http.Handler, len(router.routes))
+	for _, rt := range router.routes {
+		handlers[rt] = buildHandler(next, p.ratelimit, chain.join(rt.chain), p.logger)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt := router.match(r)
+		if rt == nil {
+			routeRequests.Inc("unmatched")
+			if log := logger.FromContext(r.Context(), p.logger); log.DebugEnabled() {
+				log.Debug(fmt.Sprintf("Запросу %s %s не подходит ни одно правило маршрутизации", r.Method, r.URL.Path))
+			}
+			http.Error(w, "No route", http.StatusNotFound)
+			return
+		}
+
+		routeRequests.Inc(rt.name)
+		if req := request.FromContext(r.Context()); req != nil {
+			request.Set(req, request.KeyRoute, rt.name)
+			if rt.backends != nil {
+				request.Set(req, request.KeyBackends, rt.backends)
+			}
+		}
+		handlers[rt].ServeHTTP(w, r)
+	})
+}
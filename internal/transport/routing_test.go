@@ -0,0 +1,170 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+// routeName возвращает имя маршрута, выбранного для запроса, или пустую строку
+func routeName(t *testing.T, router *Router, r *http.Request) string {
+	t.Helper()
+	if rt := router.match(r); rt != nil {
+		return rt.name
+	}
+	return ""
+}
+
+func TestRouter_Match(t *testing.T) {
+	half := 50.0
+	router, err := NewRouter(&config.RoutingConfig{Rules: []config.RouteConfig{
+		{Name: "health", Match: config.RouteMatchConfig{Path: "/health"}},
+		{Name: "api-write", Match: config.RouteMatchConfig{PathPrefix: "/api/", Methods: []string{"POST", "PUT"}}},
+		{Name: "api-beta", Match: config.RouteMatchConfig{PathPrefix: "/api/", Headers: map[string]string{"x-beta": "*"}}},
+		{Name: "api-v2", Match: config.RouteMatchConfig{PathPrefix: "/api/v2/"}},
+		{Name: "api", Match: config.RouteMatchConfig{PathPrefix: "/api/"}},
+		{Name: "items", Match: config.RouteMatchConfig{PathRegex: `^/items/[0-9]+$`, Query: map[string]string{"view": "full"}}},
+		{Name: "office", Match: config.RouteMatchConfig{ClientIPs: []string{"10.0.0.0/8", "192.0.2.7"}}},
+		{Name: "canary", Match: config.RouteMatchConfig{PathPrefix: "/shop/", Weight: &half}},
+		{Name: "default"},
+	}}, nil, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, target string
+		header         http.Header
+		remote         string
+		want           string
+	}{
+		{"GET", "/health", nil, "", "health"},
+		{"GET", "/health/deep", nil, "", "default"},
+		{"POST", "/api/v2/users", nil, "", "api-write"},
+		{"GET", "/api/v2/users", http.Header{"X-Beta": {"1"}}, "", "api-beta"},
+		{"GET", "/api/v2/users", nil, "", "api-v2"},
+		{"GET", "/api/v1/users", nil, "", "api"},
+		{"GET", "/api", nil, "", "default"},
+		{"GET", "/items/42?view=full", nil, "", "items"},
+		{"GET", "/items/42", nil, "", "default"},
+		{"GET", "/items/abc?view=full", nil, "", "default"},
+		{"GET", "/anything", nil, "10.1.2.3:1000", "office"},
+		{"GET", "/anything", nil, "192.0.2.7:1000", "office"},
+		{"GET", "/anything", nil, "192.0.2.8:1000", "default"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.target, nil)
+		for name, values := range tt.header {
+			r.Header[name] = values
+		}
+		if tt.remote != "" {
+			r.RemoteAddr = tt.remote
+		}
+		if got := routeName(t, router, r); got != tt.want {
+			t.Errorf("%s %s: ожидался маршрут %q, выбран %q", tt.method, tt.target, tt.want, got)
+		}
+	}
+
+	// Правило с весом забирает только часть подходящих запросов
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		counts[routeName(t, router, httptest.NewRequest("GET", "/shop/cart", nil))]++
+	}
+	if counts["canary"] < 350 || counts["canary"] > 650 || counts["canary"]+counts["default"] != 1000 {
+		t.Errorf("ожидалось около половины запросов на canary: %v", counts)
+	}
+}
+
+func TestRouter_Priority(t *testing.T) {
+	rules := []config.RouteConfig{
+		{Name: "api", Match: config.RouteMatchConfig{PathPrefix: "/api/"}},
+		{Name: "users", Priority: 10, Match: config.RouteMatchConfig{Path: "/api/users"}},
+		{Name: "fallback", Priority: -1},
+		{Name: "any"},
+	}
+
+	first, err := NewRouter(&config.RoutingConfig{Rules: rules}, nil, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := routeName(t, first, httptest.NewRequest("GET", "/api/users", nil)); got != "api" {
+		t.Errorf("при order: first ожидался маршрут api, выбран %q", got)
+	}
+	if got := routeName(t, first, httptest.NewRequest("GET", "/", nil)); got != "fallback" {
+		t.Errorf("при order: first ожидался маршрут fallback, выбран %q", got)
+	}
+
+	byPriority, err := NewRouter(&config.RoutingConfig{Order: "priority", Rules: rules}, nil, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := routeName(t, byPriority, httptest.NewRequest("GET", "/api/users", nil)); got != "users" {
+		t.Errorf("при order: priority ожидался маршрут users, выбран %q", got)
+	}
+	if got := routeName(t, byPriority, httptest.NewRequest("GET", "/", nil)); got != "any" {
+		t.Errorf("при order: priority ожидался маршрут any, выбран %q", got)
+	}
+}
+
+func TestProxy_Routing(t *testing.T) {
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"api", "web"} {
+		id := id
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, id+":"+r.Header.Get("X-Route"))
+		}))
+		defer upstream.Close()
+		b := backend.NewBackendWithOptions(id, upstream.URL, 1, backend.Options{})
+		defer b.Close()
+		lb.AddBackend(b)
+	}
+
+	router, err := NewRouter(&config.RoutingConfig{Rules: []config.RouteConfig{
+		{
+			Name:        "api",
+			Match:       config.RouteMatchConfig{PathPrefix: "/api/"},
+			Backends:    []string{"api"},
+			Middlewares: []config.MiddlewareConfig{{Name: "headers", Params: map[string]interface{}{"set": map[string]string{"X-Route": "api"}}}},
+		},
+		{Name: "web", Match: config.RouteMatchConfig{Methods: []string{"GET"}}, Backends: []string{"web"}},
+		{Name: "missing", Match: config.RouteMatchConfig{Path: "/missing"}, Backends: []string{"absent"}},
+	}}, nil, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := NewProxy(lb, ratelimit.NewNoopRateLimiter(), Options{Router: router}, logger.NewNop())
+
+	tests := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{"GET", "/api/users", http.StatusOK, "api:api"},
+		{"POST", "/api/users", http.StatusOK, "api:api"},
+		{"GET", "/index.html", http.StatusOK, "web:"},
+		{"GET", "/index.html", http.StatusOK, "web:"},
+		{"POST", "/index.html", http.StatusNotFound, ""},
+		{"DELETE", "/missing", http.StatusServiceUnavailable, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: ожидался статус %d, получен %d", tt.method, tt.path, tt.status, rec.Code)
+			continue
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s %s: ожидался ответ %q, получен %q", tt.method, tt.path, tt.body, rec.Body.String())
+		}
+	}
+}
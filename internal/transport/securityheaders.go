@@ -0,0 +1,217 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Значения заголовков безопасности по умолчанию
+const (
+	defaultFrameOptions   = "SAMEORIGIN"
+	defaultReferrerPolicy = "strict-origin-when-cross-origin"
+	defaultHSTSMaxAge     = 365 * 24 * time.Hour
+
+	// Значение параметра, отключающее заголовок по умолчанию
+	securityHeaderOff = "off"
+)
+
+// hiddenResponseHeaders заголовки ответов бэкендов, раскрывающие сервер и его версию
+var hiddenResponseHeaders = []string{"Server", "X-Powered-By"}
+
+// referrerPolicies допустимые значения Referrer-Policy
+var referrerPolicies = map[string]bool{
+	"no-referrer": true, "no-referrer-when-downgrade": true, "origin": true, "origin-when-cross-origin": true,
+	"same-origin": true, "strict-origin": true, "strict-origin-when-cross-origin": true, "unsafe-url": true,
+}
+
+// securityHeadersParams параметры middleware securityHeaders
+type securityHeadersParams struct {
+	// Strict-Transport-Security для ответов по TLS (nil — не добавляется)
+	HSTS *hstsParams `yaml:"hsts"`
+
+	// X-Content-Type-Options: nosniff (по умолчанию true)
+	ContentTypeOptions *bool `yaml:"contentTypeOptions"`
+
+	// X-Frame-Options: DENY или SAMEORIGIN (по умолчанию), off — не добавляется
+	FrameOptions string `yaml:"frameOptions"`
+
+	// Content-Security-Policy (пустая — не добавляется)
+	ContentSecurityPolicy string `yaml:"contentSecurityPolicy"`
+
+	// Отправлять политику в Content-Security-Policy-Report-Only: нарушения только сообщаются
+	CSPReportOnly bool `yaml:"cspReportOnly"`
+
+	// Referrer-Policy (по умолчанию strict-origin-when-cross-origin), off — не добавляется
+	ReferrerPolicy string `yaml:"referrerPolicy"`
+
+	// Заменять заголовки, которые установил бэкенд; по умолчанию его значения сохраняются
+	Override bool `yaml:"override"`
+
+	// Заголовки, удаляемые из ответов вместе с Server и X-Powered-By
+	RemoveHeaders []string `yaml:"removeHeaders"`
+}
+
+// hstsParams параметры заголовка Strict-Transport-Security
+type hstsParams struct {
+	// Срок, в течение которого браузер обращается к сайту только по HTTPS (по умолчанию 8760h)
+	MaxAge time.Duration `yaml:"maxAge"`
+
+	IncludeSubDomains bool `yaml:"includeSubDomains"`
+
+	// Согласие на включение домена в списки предзагрузки браузеров
+	Preload bool `yaml:"preload"`
+}
+
+// securityHeaders добавляет в ответы заголовки безопасности и убирает заголовки,
+// раскрывающие программное обеспечение бэкенда
+type securityHeaders struct {
+	// Заголовки для всех ответов и Strict-Transport-Security для ответов по TLS
+	set  http.Header
+	hsts string
+
+	override bool
+	remove   []string
+}
+
+// newSecurityHeadersMiddleware создает middleware заголовков безопасности
+func newSecurityHeadersMiddleware(cfg config.MiddlewareConfig, _ logger.Logger) (Middleware, error) {
+	var params securityHeadersParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	h, err := newSecurityHeaders(params)
+	if err != nil {
+		return nil, err
+	}
+	return h.middleware, nil
+}
+
+// newSecurityHeaders проверяет параметры и готовит значения заголовков
+func newSecurityHeaders(params securityHeadersParams) (*securityHeaders, error) {
+	h := &securityHeaders{
+		set:      make(http.Header),
+		override: params.Override,
+		remove:   append(append([]string(nil), hiddenResponseHeaders...), params.RemoveHeaders...),
+	}
+
+	if params.ContentTypeOptions == nil || *params.ContentTypeOptions {
+		h.set.Set("X-Content-Type-Options", "nosniff")
+	}
+
+	switch frameOptions := strings.ToUpper(params.FrameOptions); frameOptions {
+	case "":
+		h.set.Set("X-Frame-Options", defaultFrameOptions)
+	case "DENY", "SAMEORIGIN":
+		h.set.Set("X-Frame-Options", frameOptions)
+	case strings.ToUpper(securityHeaderOff):
+	default:
+		return nil, fmt.Errorf("securityHeaders: frameOptions must be DENY, SAMEORIGIN or off, got %q", params.FrameOptions)
+	}
+
+	switch policy := strings.ToLower(params.ReferrerPolicy); {
+	case policy == "":
+		h.set.Set("Referrer-Policy", defaultReferrerPolicy)
+	case referrerPolicies[policy]:
+		h.set.Set("Referrer-Policy", policy)
+	case policy != securityHeaderOff:
+		return nil, fmt.Errorf("securityHeaders: unknown referrerPolicy %q", params.ReferrerPolicy)
+	}
+
+	if csp := strings.TrimSpace(params.ContentSecurityPolicy); csp != "" {
+		if strings.ContainsAny(csp, "\r\n") {
+			return nil, fmt.Errorf("securityHeaders: contentSecurityPolicy must be a single line")
+		}
+		if params.CSPReportOnly {
+			h.set.Set("Content-Security-Policy-Report-Only", csp)
+		} else {
+			h.set.Set("Content-Security-Policy", csp)
+		}
+	} else if params.CSPReportOnly {
+		return nil, fmt.Errorf("securityHeaders: cspReportOnly requires contentSecurityPolicy")
+	}
+
+	if hsts := params.HSTS; hsts != nil {
+		maxAge := hsts.MaxAge
+		if maxAge == 0 {
+			maxAge = defaultHSTSMaxAge
+		}
+		if maxAge < 0 {
+			return nil, fmt.Errorf("securityHeaders: hsts.maxAge must not be negative")
+		}
+		// Требования списка предзагрузки hstspreload.org
+		if hsts.Preload && (!hsts.IncludeSubDomains || maxAge < defaultHSTSMaxAge) {
+			return nil, fmt.Errorf("securityHeaders: hsts.preload requires includeSubDomains and maxAge of at least %s", defaultHSTSMaxAge)
+		}
+		h.hsts = "max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)
+		if hsts.IncludeSubDomains {
+			h.hsts += "; includeSubDomains"
+		}
+		if hsts.Preload {
+			h.hsts += "; preload"
+		}
+	}
+
+	for _, name := range params.RemoveHeaders {
+		if name == "" || strings.ContainsAny(name, ": \t\r\n") {
+			return nil, fmt.Errorf("securityHeaders: invalid header name %q in removeHeaders", name)
+		}
+	}
+	return h, nil
+}
+
+func (h *securityHeaders) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tls := r.TLS != nil
+		next.ServeHTTP(&responseHeaderWriter{ResponseWriter: w, apply: func(header http.Header) { h.apply(header, tls) }}, r)
+	})
+}
+
+// apply изменяет заголовки ответа перед отправкой
+func (h *securityHeaders) apply(header http.Header, tls bool) {
+	for _, name := range h.remove {
+		header.Del(name)
+	}
+	for name, values := range h.set {
+		if h.override || header.Get(name) == "" {
+			header[name] = values
+		}
+	}
+	// Браузеры учитывают Strict-Transport-Security только в ответах по HTTPS (RFC 6797, раздел 8.1)
+	if h.hsts != "" && tls && (h.override || header.Get("Strict-Transport-Security") == "") {
+		header.Set("Strict-Transport-Security", h.hsts)
+	}
+}
+
+// responseHeaderWriter изменяет заголовки ответа функцией apply перед отправкой статуса
+type responseHeaderWriter struct {
+	http.ResponseWriter
+	apply   func(header http.Header)
+	written bool
+}
+
+func (w *responseHeaderWriter) WriteHeader(statusCode int) {
+	// Промежуточные ответы 1xx отправляются без изменений
+	if !w.written && statusCode >= http.StatusOK {
+		w.written = true
+		w.apply(w.Header())
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseHeaderWriter) Write(p []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap позволяет http.ResponseController использовать исходный ResponseWriter
+func (w *responseHeaderWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
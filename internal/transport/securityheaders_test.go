@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	h, err := newSecurityHeaders(securityHeadersParams{
+		HSTS:                  &hstsParams{MaxAge: 48 * time.Hour, IncludeSubDomains: true},
+		ContentSecurityPolicy: "default-src 'self'",
+		RemoveHeaders:         []string{"X-AspNet-Version"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.25.3")
+		w.Header().Set("X-Powered-By", "PHP/8.3")
+		w.Header().Set("X-AspNet-Version", "4.0")
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Write([]byte("ok"))
+	})
+
+	r := httptest.NewRequest("GET", "https://shop.example/", nil)
+	r.TLS = &tls.ConnectionState{}
+	rec := httptest.NewRecorder()
+	h.middleware(backend).ServeHTTP(rec, r)
+
+	want := map[string]string{
+		"Strict-Transport-Security": "max-age=172800; includeSubDomains",
+		"Content-Security-Policy":   "default-src 'self'",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"Server":                    "",
+		"X-Powered-By":              "",
+		"X-Aspnet-Version":          "",
+	}
+	for name, value := range want {
+		if got := rec.Header().Get(name); got != value {
+			t.Errorf("%s: получено %q, ожидалось %q", name, got, value)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.middleware(backend).ServeHTTP(rec, httptest.NewRequest("GET", "http://shop.example/", nil))
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("HSTS не должен отправляться без TLS: %q", got)
+	}
+
+	if _, err := newSecurityHeaders(securityHeadersParams{HSTS: &hstsParams{Preload: true}}); err == nil {
+		t.Error("preload без includeSubDomains должен отклоняться")
+	}
+}
@@ -0,0 +1,344 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/upgrade"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Server владеет listener'ом прокси на протяжении всей работы приложения.
+// Реконфигурация лишь подменяет текущий Proxy, поэтому порт не освобождается
+// и не занимается повторно, а keep-alive соединения продолжают работать.
+type Server struct {
+	server *http.Server
+	proxy  atomic.Pointer[Proxy]
+	logger logger.Logger
+
+	// Порт HTTPS, на который перенаправляются все запросы; 0 — запросы проксируются
+	redirectPort atomic.Int32
+
+	// Сервер слушает unix сокет
+	unix bool
+
+	// Сервер принимает TLS соединения: только их или, при определении протокола, наряду
+	// с соединениями без TLS. Признак хранится отдельно от TLSConfig:
+	// http.Server заполняет TLSConfig и для сервера без TLS, когда начинает работу.
+	tls bool
+
+	// Порт занимается с SO_REUSEPORT
+	reusePort bool
+
+	// Определение протокола по первым байтам соединения; nil — выключено
+	sniff *config.ListenerSniffConfig
+
+	// Передача бэкендам сведений о клиентском сертификате. Listener'ы без нее только
+	// отбрасывают заголовок X-Forwarded-Client-Cert, полученный не от доверенного прокси.
+	clientCert *clientCertForwarding
+
+	// Минимальная скорость передачи тела запроса (0 — не проверяется) и время до начала проверки
+	minBodyRate      int64
+	minBodyRateGrace time.Duration
+
+	// Открытые соединения и их лимиты
+	conns connLimiter
+
+	// Соединения, переданные бэкендам без разбора протокола
+	tunnels *tunnels
+
+	// Listener, на котором сервер принимает соединения (nil — адрес не занят), и его адрес
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+// UnixPrefix префикс адреса listener'а на unix сокете: unix:/run/lb.sock
+const UnixPrefix = "unix:"
+
+// unixRemoteAddr адрес отправителя запросов, принятых на unix сокете. У таких соединений
+// нет IP адреса, а отправитель всегда находится на том же хосте.
+const unixRemoteAddr = "127.0.0.1:0"
+
+// NewServer создает сервер прокси
+func NewServer(appLogger logger.Logger) *Server {
+	s := &Server{
+		logger:     appLogger,
+		clientCert: newClientCertForwarding(nil),
+		tunnels:    newTunnels(),
+	}
+
+	s.server = &http.Server{
+		Handler: http.HandlerFunc(s.serveHTTP),
+	}
+	s.SetSlowClient(nil)
+
+	return s
+}
+
+// SetReusePort включает SO_REUSEPORT: порт смогут одновременно слушать другие процессы,
+// также включившие этот режим. Вызывается до Start.
+func (s *Server) SetReusePort(enabled bool) {
+	s.reusePort = enabled
+}
+
+// Start занимает порт и начинает обслуживать запросы в отдельной горутине.
+// Ошибка привязки к порту возвращается сразу, а не только логируется.
+func (s *Server) Start(addr string) error {
+	s.logger.Debug(fmt.Sprintf("Запуск прокси-сервера на порту %s", addr))
+
+	network := "tcp"
+	if path, ok := strings.CutPrefix(addr, UnixPrefix); ok {
+		network, addr = "unix", path
+		s.unix = true
+		if !upgrade.Inherited(network, path) {
+			if err := removeStaleSocket(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Сокет, переданный предыдущим процессом при обновлении, используется вместо нового
+	var lc net.ListenConfig
+	if s.reusePort && network == "tcp" {
+		lc.Control = reusePortControl
+	}
+	listener, err := upgrade.Listen(lc, network, addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	return s.Serve(listener)
+}
+
+// removeStaleSocket удаляет файл сокета, оставшийся от прежнего запуска. Если на сокете
+// кто-то принимает соединения, адрес считается занятым.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat socket %s: %w", path, err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("failed to listen on %s: file exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("failed to listen on %s: address already in use", path)
+	}
+	return os.Remove(path)
+}
+
+// StartTLS занимает порт и принимает только TLS соединения. Если в настройках
+// задан CA, клиенты без сертификата, подписанного этим CA, отклоняются, а сведения
+// о проверенных сертификатах передаются бэкендам, если включено forwardClientCert.
+func (s *Server) StartTLS(addr string, tlsCfg *config.ListenerTLSConfig) error {
+	tlsConfig, err := buildTLSConfig(tlsCfg)
+	if err != nil {
+		return err
+	}
+	s.server.TLSConfig = tlsConfig
+	s.tls = true
+	s.clientCert = newClientCertForwarding(tlsCfg.ForwardClientCert)
+
+	return s.Start(addr)
+}
+
+// buildTLSConfig загружает сертификат сервера и CA клиентских сертификатов
+func buildTLSConfig(tlsCfg *config.ListenerTLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(tlsCfg.CertFile, tlsCfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading listener certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if tlsCfg.ClientCAFile != "" {
+		caPEM, err := os.ReadFile(tlsCfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading listener client CA: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in listener client CA file")
+		}
+
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if tlsCfg.ClientAuth == "optional" {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// Serve начинает обслуживать запросы на переданном listener'е
+func (s *Server) Serve(listener net.Listener) error {
+	listener = &limitListener{Listener: listener, limiter: &s.conns, tls: s.tls && s.sniff == nil}
+	if s.sniff != nil || s.tls {
+		var tlsConfig *tls.Config
+		if s.tls {
+			// Соединения оборачиваются в TLS до сервера, чтобы listener мог определить
+			// протокол и передать соединения маршрутов passthrough бэкендам без расшифровки,
+			// поэтому HTTP/2 объявляется здесь, а не в ServeTLS
+			tlsConfig = s.server.TLSConfig.Clone()
+			tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+			s.server.TLSConfig = tlsConfig
+		}
+		listener = newSniffListener(listener, s.sniff, tlsConfig, &s.proxy, s.tunnels, s.logger)
+	}
+	s.mu.Lock()
+	s.server.Addr = listener.Addr().String()
+	s.listener = listener
+	s.mu.Unlock()
+
+	go func() {
+		err := s.server.Serve(listener)
+		// После Release listener закрыт, но сервер продолжает работать
+		if err != nil && err != http.ErrServerClosed && !errors.Is(err, net.ErrClosed) {
+			s.logger.Error(fmt.Sprintf("Ошибка прокси-сервера: %v", err))
+		}
+	}()
+
+	return nil
+}
+
+// Release перестает принимать соединения и освобождает адрес, но продолжает обслуживать
+// уже открытые соединения. Адрес можно снова занять вызовом Start.
+func (s *Server) Release() error {
+	s.mu.Lock()
+	listener := s.listener
+	s.listener = nil
+	s.mu.Unlock()
+
+	if listener == nil {
+		return nil
+	}
+	s.logger.Debug(fmt.Sprintf("Прокси-сервер освобождает адрес %s", listener.Addr()))
+	return listener.Close()
+}
+
+// Addr возвращает адрес, который слушает сервер (с фактическим портом, если был указан порт 0)
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.server.Addr
+}
+
+// SetProxy переключает обработку новых запросов на переданный прокси и возвращает предыдущий
+func (s *Server) SetProxy(p *Proxy) *Proxy {
+	return s.proxy.Swap(p)
+}
+
+// SetRedirectHTTPS включает перенаправление всех запросов без TLS на HTTPS с указанным
+// портом. Порт 0 отключает перенаправление.
+func (s *Server) SetRedirectHTTPS(port int) {
+	s.redirectPort.Store(int32(port))
+}
+
+// Stop перестает принимать соединения и ожидает завершения текущих запросов
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return s.Shutdown(ctx)
+}
+
+// Shutdown перестает принимать соединения и ждет завершения текущих запросов.
+// Если ctx завершается раньше, оставшиеся соединения закрываются принудительно.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.logger.Debug("Начало graceful shutdown прокси-сервера")
+
+	// Перестаем принимать новые соединения и ждем завершения текущих
+	err := s.server.Shutdown(ctx)
+
+	// Туннели к бэкендам ждут окончания ctx вместе с запросами; после него они закрываются
+	if err := s.tunnels.shutdown(ctx); err != nil {
+		s.logger.Warn(fmt.Sprintf("Туннели к бэкендам не завершились до остановки и закрыты: %v", err))
+	}
+
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("Ошибка при graceful shutdown: %v", err))
+		// Если не удалось graceful shutdown, закрываем принудительно
+		if err := s.server.Close(); err != nil {
+			s.logger.Error(fmt.Sprintf("Ошибка при принудительном закрытии: %v", err))
+		}
+		return err
+	}
+
+	s.logger.Debug("Прокси-сервер успешно остановлен")
+	return nil
+}
+
+// serveHTTP передает запрос текущему прокси
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if port := s.redirectPort.Load(); port != 0 && r.TLS == nil {
+		redirectHTTPS(w, r, int(port))
+		return
+	}
+
+	if s.unix {
+		r.RemoteAddr = unixRemoteAddr
+	}
+	r = s.limitBodyRate(w, r)
+
+	p := s.proxy.Load()
+	for p != nil && !p.enter() {
+		// Прокси заменен после загрузки и уже завершает запросы: запрос передается новому
+		if current := s.proxy.Load(); current != p {
+			p = current
+			continue
+		}
+		http.Error(w, "Proxy is draining", http.StatusServiceUnavailable)
+		return
+	}
+	if p == nil {
+		http.Error(w, "Proxy is not configured yet", http.StatusServiceUnavailable)
+		return
+	}
+	defer p.leave()
+	s.clientCert.apply(r, p.trusted)
+
+	p.handler.ServeHTTP(w, r)
+}
+
+// redirectHTTPS перенаправляет запрос на тот же хост и путь по HTTPS.
+// Для запросов с телом используется 308, чтобы клиент сохранил метод.
+func redirectHTTPS(w http.ResponseWriter, r *http.Request, port int) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" {
+		http.Error(w, "Host header is required", http.StatusBadRequest)
+		return
+	}
+	if port != 443 {
+		host = net.JoinHostPort(host, strconv.Itoa(port))
+	} else if net.ParseIP(host) != nil && net.ParseIP(host).To4() == nil {
+		host = "[" + host + "]"
+	}
+
+	status := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		status = http.StatusPermanentRedirect
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+}
@@ -0,0 +1,283 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestServer_RedirectHTTPS(t *testing.T) {
+	s := NewServer(logger.NewNop())
+	s.SetRedirectHTTPS(443)
+
+	for _, tc := range []struct {
+		method, host, target string
+		status               int
+		location             string
+	}{
+		{"GET", "example.com", "/a?b=1", http.StatusMovedPermanently, "https://example.com/a?b=1"},
+		{"GET", "example.com:80", "/", http.StatusMovedPermanently, "https://example.com/"},
+		{"POST", "example.com", "/form", http.StatusPermanentRedirect, "https://example.com/form"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.target, nil)
+		req.Host = tc.host
+		rec := httptest.NewRecorder()
+		s.serveHTTP(rec, req)
+		if rec.Code != tc.status || rec.Header().Get("Location") != tc.location {
+			t.Errorf("%s %s%s: %d %s", tc.method, tc.host, tc.target, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+
+	// Нестандартный порт HTTPS указывается в адресе
+	s.SetRedirectHTTPS(8443)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "example.com:8080"
+	rec := httptest.NewRecorder()
+	s.serveHTTP(rec, req)
+	if rec.Header().Get("Location") != "https://example.com:8443/" {
+		t.Errorf("неверный адрес перенаправления: %s", rec.Header().Get("Location"))
+	}
+
+	// Без перенаправления запрос передается прокси
+	s.SetRedirectHTTPS(0)
+	rec = httptest.NewRecorder()
+	s.serveHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("ожидался статус 503 без прокси, получен %d", rec.Code)
+	}
+}
+
+func TestServer_Unix(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "lb.sock")
+
+	// Файл сокета от прежнего запуска не мешает занять адрес
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	s := NewServer(logger.NewNop())
+	if err := s.Start(UnixPrefix + socket); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	// Занятый сокет не перехватывается вторым сервером
+	if err := NewServer(logger.NewNop()).Start(UnixPrefix + socket); err == nil {
+		t.Error("ожидалась ошибка для занятого сокета")
+	}
+
+	s.SetRedirectHTTPS(443)
+	client := &http.Client{
+		Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		}},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Get("http://example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Location") != "https://example.com/a" {
+		t.Errorf("неверный ответ сервера на unix сокете: %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
+
+func TestServer_ReusePort(t *testing.T) {
+	first := NewServer(logger.NewNop())
+	first.SetReusePort(true)
+	if err := first.Start("127.0.0.1:0"); err != nil {
+		t.Skipf("SO_REUSEPORT недоступен: %v", err)
+	}
+	defer first.Stop()
+
+	// Второй процесс (например, новая версия при обновлении) занимает тот же порт
+	second := NewServer(logger.NewNop())
+	second.SetReusePort(true)
+	if err := second.Start(first.server.Addr); err != nil {
+		t.Fatalf("порт с SO_REUSEPORT не удалось занять повторно: %v", err)
+	}
+	defer second.Stop()
+
+	// Без SO_REUSEPORT порт занят
+	if err := NewServer(logger.NewNop()).Start(first.server.Addr); err == nil {
+		t.Error("ожидалась ошибка занятого порта без SO_REUSEPORT")
+	}
+}
+
+func TestServer_Release(t *testing.T) {
+	s := NewServer(logger.NewNop())
+	s.SetRedirectHTTPS(443)
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	addr := s.Addr()
+
+	// Открытое соединение продолжает обслуживаться после освобождения адреса
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := s.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("освобожденный адрес не должен принимать соединения")
+	}
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil || resp.StatusCode != http.StatusMovedPermanently {
+		t.Fatalf("открытое соединение должно обслуживаться: %v", err)
+	}
+	resp.Body.Close()
+
+	// Адрес занимается повторно тем же сервером
+	if err := s.Start(addr); err != nil {
+		t.Fatalf("адрес не удалось занять повторно: %v", err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err = client.Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestServer_SlowClient(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer upstream.Close()
+
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backend.NewBackendWithOptions("slow-b1", upstream.URL, 1, backend.Options{})
+	defer b.Close()
+	lb.AddBackend(b)
+
+	s := NewServer(logger.NewNop())
+	s.SetSlowClient(&config.SlowClientConfig{
+		ReadHeaderTimeout: 100 * time.Millisecond,
+		MinBodyRate:       1000,
+		MinBodyRateGrace:  100 * time.Millisecond,
+	})
+	s.SetProxy(NewProxy(lb, ratelimit.NewNoopRateLimiter(), Options{}, logger.NewNop()))
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	// Клиент, не приславший заголовки вовремя, отключается
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example.com\r\n")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Errorf("соединение без заголовков должно закрываться сервером: %v", err)
+	}
+
+	// Тело, передаваемое медленнее минимальной скорости, прерывается с 408
+	conn, err = net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 100000\r\n\r\nslow")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("ожидался статус 408 для медленного тела, получен %d", resp.StatusCode)
+	}
+}
+
+func TestServer_ConnectionLimits(t *testing.T) {
+	s := NewServer(logger.NewNop())
+	s.SetRedirectHTTPS(443)
+	s.SetConnectionLimits(&config.ConnectionLimitsConfig{MaxConnections: 3, MaxConnectionsPerIP: 2})
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	// status открывает соединение, отправляет запрос и возвращает соединение и статус ответа
+	status := func() (net.Conn, int) {
+		t.Helper()
+		conn, err := net.Dial("tcp", s.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return conn, resp.StatusCode
+	}
+
+	// Соединения в пределах лимита на IP остаются открытыми
+	for i := 0; i < 2; i++ {
+		conn, code := status()
+		defer conn.Close()
+		if code != http.StatusMovedPermanently {
+			t.Fatalf("соединение в пределах лимита должно обслуживаться, статус %d", code)
+		}
+	}
+	conn, code := status()
+	conn.Close()
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("соединение сверх лимита на IP должно получать 503, получен %d", code)
+	}
+
+	// Общий лимит действует при снятом лимите на IP; изменения применяются без перезапуска
+	s.SetConnectionLimits(&config.ConnectionLimitsConfig{MaxConnections: 3})
+	third, code := status()
+	defer third.Close()
+	if code != http.StatusMovedPermanently {
+		t.Fatalf("третье соединение должно обслуживаться, статус %d", code)
+	}
+	conn, code = status()
+	conn.Close()
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("соединение сверх общего лимита должно получать 503, получен %d", code)
+	}
+
+	// Закрытое соединение освобождает место
+	third.Close()
+	for deadline := time.Now().Add(time.Second); s.conns.open.Load() > 2; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("закрытое соединение должно освобождать место в лимите")
+		}
+	}
+	conn, code = status()
+	conn.Close()
+	if code != http.StatusMovedPermanently {
+		t.Errorf("после закрытия соединения новое должно обслуживаться, статус %d", code)
+	}
+}
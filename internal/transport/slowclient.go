@@ -0,0 +1,138 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+)
+
+// Ограничения для медленных клиентов по умолчанию
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultMaxHeaderBytes    = 64 << 10
+	defaultMinBodyRate       = 240
+	defaultMinBodyRateGrace  = 5 * time.Second
+)
+
+// errBodyTooSlow ошибка чтения тела запроса, которое клиент передает медленнее минимальной скорости
+var errBodyTooSlow = errors.New("request body transfer rate is below the minimum")
+
+// slowClients счетчик запросов, прерванных из-за низкой скорости передачи тела
+var slowClients = metrics.Default.Counter("lb_slow_clients_total", "Requests aborted because the client sent the body too slowly")
+
+// SetSlowClient задает ограничения для медленных клиентов (nil — значения по умолчанию).
+// Вызывается до Start.
+func (s *Server) SetSlowClient(cfg *config.SlowClientConfig) {
+	var sc config.SlowClientConfig
+	if cfg != nil {
+		sc = *cfg
+	}
+
+	s.server.ReadHeaderTimeout = sc.ReadHeaderTimeout
+	if s.server.ReadHeaderTimeout == 0 {
+		s.server.ReadHeaderTimeout = defaultReadHeaderTimeout
+	}
+	s.server.ReadTimeout = sc.ReadTimeout
+	s.server.IdleTimeout = sc.IdleTimeout
+	if s.server.IdleTimeout == 0 {
+		s.server.IdleTimeout = defaultIdleTimeout
+	}
+	s.server.MaxHeaderBytes = sc.MaxHeaderBytes
+	if s.server.MaxHeaderBytes == 0 {
+		s.server.MaxHeaderBytes = defaultMaxHeaderBytes
+	}
+
+	switch sc.MinBodyRate {
+	case -1:
+		s.minBodyRate = 0
+	case 0:
+		s.minBodyRate = defaultMinBodyRate
+	default:
+		s.minBodyRate = sc.MinBodyRate
+	}
+	s.minBodyRateGrace = sc.MinBodyRateGrace
+	if s.minBodyRateGrace == 0 {
+		s.minBodyRateGrace = defaultMinBodyRateGrace
+	}
+}
+
+// slowBodyKey ключ тела запроса с проверкой скорости в контексте
+type slowBodyKey struct{}
+
+// limitBodyRate ограничивает время чтения тела запроса так, чтобы клиент передавал его
+// не медленнее минимальной скорости
+func (s *Server) limitBodyRate(w http.ResponseWriter, r *http.Request) *http.Request {
+	if s.minBodyRate <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return r
+	}
+	body := &minRateReader{
+		ReadCloser: r.Body,
+		controller: http.NewResponseController(w),
+		start:      time.Now(),
+		rate:       s.minBodyRate,
+		grace:      s.minBodyRateGrace,
+		timeout:    s.server.ReadTimeout,
+	}
+	r.Body = body
+	return r.WithContext(context.WithValue(r.Context(), slowBodyKey{}, body))
+}
+
+// bodyTooSlow сообщает, что чтение тела запроса прервано из-за низкой скорости передачи.
+// Сервер при этом отменяет контекст запроса, поэтому ошибка запроса к бэкенду — context.Canceled.
+func bodyTooSlow(ctx context.Context) bool {
+	body, _ := ctx.Value(slowBodyKey{}).(*minRateReader)
+	return body != nil && body.tooSlow.Load()
+}
+
+// minRateReader перед каждым чтением продлевает таймаут чтения соединения: после grace
+// клиент должен передать очередной байт не позже, чем при передаче со скоростью rate
+type minRateReader struct {
+	io.ReadCloser
+	controller *http.ResponseController
+	start      time.Time
+	rate       int64
+	grace      time.Duration
+	read       int64
+
+	// Таймаут чтения всего запроса, который продление не должно отменять (0 — нет)
+	timeout time.Duration
+
+	// Таймаут чтения не поддерживается (например, HTTP/2 без поддержки дедлайнов)
+	unsupported bool
+
+	// Чтение прервано из-за низкой скорости; проверяется из горутины обработчика
+	tooSlow atomic.Bool
+}
+
+func (r *minRateReader) Read(p []byte) (int, error) {
+	if !r.unsupported {
+		deadline := r.start.Add(r.grace + time.Duration(float64(r.read+1)/float64(r.rate)*float64(time.Second)))
+		if r.timeout > 0 && deadline.After(r.start.Add(r.timeout)) {
+			deadline = r.start.Add(r.timeout)
+		}
+		if r.controller.SetReadDeadline(deadline) != nil {
+			r.unsupported = true
+		}
+	}
+
+	n, err := r.ReadCloser.Read(p)
+	r.read += int64(n)
+	switch {
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		slowClients.Inc()
+		r.tooSlow.Store(true)
+		return n, fmt.Errorf("%w after %d bytes", errBodyTooSlow, r.read)
+	case err == io.EOF && !r.unsupported:
+		r.controller.SetReadDeadline(time.Time{})
+	}
+	return n, err
+}
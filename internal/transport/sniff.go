@@ -0,0 +1,329 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Протоколы, определяемые по первым байтам соединения
+const (
+	sniffTLS  = "tls"
+	sniffHTTP = "http"
+	sniffTCP  = "tcp"
+
+	// Протокол не определен, а TCP бэкенд не задан: соединение закрывается
+	sniffUnknown = "unknown"
+)
+
+// Параметры определения протокола
+const (
+	defaultSniffTimeout = 5 * time.Second
+
+	// Байтов достаточно, чтобы узнать метод HTTP с пробелом после него
+	sniffPeekSize = 8
+
+	sniffDialTimeout = 5 * time.Second
+)
+
+// httpMethodPrefixes начала HTTP/1.x запросов
+var httpMethodPrefixes = [][]byte{
+	[]byte("GET "), []byte("HEAD "), []byte("POST "), []byte("PUT "), []byte("DELETE "),
+	[]byte("OPTIONS "), []byte("PATCH "), []byte("CONNECT "), []byte("TRACE "),
+}
+
+// sniffedConnections счетчик соединений по протоколу, определенному по первым байтам
+var sniffedConnections = metrics.Default.Counter("lb_sniffed_connections_total", "Connections accepted on sniffing listeners by detected protocol", "protocol")
+
+// SetSniff включает определение протокола по первым байтам соединения (nil — выключено):
+// на одном порту принимаются TLS (если сервер запущен StartTLS), HTTP без TLS и, если задан
+// TCP бэкенд, соединения других протоколов. Вызывается до Start.
+func (s *Server) SetSniff(cfg *config.ListenerSniffConfig) {
+	s.sniff = cfg
+}
+
+// sniffListener распределяет принятые соединения: передает HTTP серверу соединения HTTP
+// и TLS (TLS — уже обернутыми в tls.Conn), TLS соединения маршрутов passthrough — их
+// бэкендам без расшифровки, а соединения других протоколов — TCP бэкенду. Соединения
+// разбираются в отдельной горутине для каждого, чтобы клиент, не присылающий данных,
+// не задерживал прием остальных.
+type sniffListener struct {
+	net.Listener
+
+	// Определять протокол по первым байтам; false — все соединения считаются TLS
+	sniff bool
+
+	// Настройки TLS; nil — listener без TLS
+	tlsConfig  *tls.Config
+	tcpBackend string
+	timeout    time.Duration
+	logger     logger.Logger
+
+	// Текущий прокси сервера с маршрутами passthrough
+	proxy *atomic.Pointer[Proxy]
+
+	// Туннели к бэкендам, которые закрываются при остановке сервера
+	tunnels *tunnels
+
+	// Соединения для HTTP сервера; канал без буфера, поэтому прием замедляется вместе с сервером
+	accepted  chan acceptResult
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// acceptResult соединение или ошибка приема для Accept
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// newSniffListener начинает принимать соединения listener'а. cfg — настройки определения
+// протокола (nil — listener принимает только TLS).
+func newSniffListener(listener net.Listener, cfg *config.ListenerSniffConfig, tlsConfig *tls.Config, proxy *atomic.Pointer[Proxy], tunnels *tunnels, appLogger logger.Logger) *sniffListener {
+	l := &sniffListener{
+		Listener:  listener,
+		tlsConfig: tlsConfig,
+		logger:    appLogger,
+		proxy:     proxy,
+		tunnels:   tunnels,
+		accepted:  make(chan acceptResult),
+		done:      make(chan struct{}),
+	}
+	if cfg != nil {
+		l.sniff, l.tcpBackend, l.timeout = true, cfg.TCPBackend, cfg.Timeout
+	}
+	if l.timeout == 0 {
+		l.timeout = defaultSniffTimeout
+	}
+	go l.acceptLoop()
+	return l
+}
+
+// acceptLoop принимает соединения и определяет их протокол
+func (l *sniffListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			// Ошибка передается серверу: после временной ошибки он повторяет прием с паузой
+			if !l.deliver(acceptResult{err: err}) || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		go l.dispatch(conn)
+	}
+}
+
+// deliver передает результат приема серверу; false — listener закрыт
+func (l *sniffListener) deliver(result acceptResult) bool {
+	select {
+	case l.accepted <- result:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+// dispatch определяет протокол соединения и передает его обработчику
+func (l *sniffListener) dispatch(conn net.Conn) {
+	protocol, sniffed := sniffTLS, conn
+	if l.sniff {
+		protocol, sniffed = l.detect(conn)
+		sniffedConnections.Inc(protocol)
+	}
+
+	switch protocol {
+	case sniffTLS:
+		// Имя сервера читается, только если есть маршруты passthrough
+		if p := l.proxy.Load(); p != nil && p.hasPassthrough() {
+			var serverName string
+			serverName, sniffed = readServerName(sniffed, l.timeout)
+			if rt := p.passthroughRoute(serverName); rt != nil {
+				l.tunnels.run(sniffed, func(ctx context.Context) { p.tunnelTLS(ctx, sniffed, rt, serverName) })
+				return
+			}
+		}
+		if !l.deliver(acceptResult{conn: tls.Server(sniffed, l.tlsConfig)}) {
+			conn.Close()
+		}
+	case sniffHTTP:
+		if !l.deliver(acceptResult{conn: sniffed}) {
+			conn.Close()
+		}
+	case sniffTCP:
+		l.tunnels.run(sniffed, func(ctx context.Context) { l.tunnel(ctx, sniffed) })
+	default:
+		conn.Close()
+	}
+}
+
+// detect читает первые байты соединения и возвращает протокол и соединение, которое
+// начинает чтение с этих байтов. Клиент, не приславший данных за время ожидания, считается
+// клиентом протокола, в котором первым говорит сервер.
+func (l *sniffListener) detect(conn net.Conn) (string, net.Conn) {
+	r := bufio.NewReader(conn)
+	sniffed := &sniffedConn{Conn: conn, r: r}
+
+	conn.SetReadDeadline(time.Now().Add(l.timeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	// Запись TLS рукопожатия: тип 22, версия 3.x
+	if head, err := r.Peek(1); err == nil && head[0] == 0x16 && l.tlsConfig != nil {
+		return sniffTLS, sniffed
+	}
+	head, _ := r.Peek(sniffPeekSize)
+	for _, prefix := range httpMethodPrefixes {
+		if bytes.HasPrefix(head, prefix) {
+			return sniffHTTP, sniffed
+		}
+	}
+	if l.tcpBackend == "" {
+		return sniffUnknown, sniffed
+	}
+	return sniffTCP, sniffed
+}
+
+// tunnel передает соединение TCP бэкенду без разбора протокола; ctx отменяется, когда
+// сервер останавливается и туннель нужно закрыть
+func (l *sniffListener) tunnel(ctx context.Context, client net.Conn) {
+	defer client.Close()
+
+	dialer := net.Dialer{Timeout: sniffDialTimeout}
+	backend, err := dialer.DialContext(ctx, "tcp", l.tcpBackend)
+	if err != nil {
+		l.logger.Warn(fmt.Sprintf("Не удалось подключиться к TCP бэкенду %s: %v", l.tcpBackend, err))
+		return
+	}
+	defer backend.Close()
+	stop := context.AfterFunc(ctx, func() { backend.Close() })
+	defer stop()
+	l.logger.Debug(fmt.Sprintf("Соединение %s передано TCP бэкенду %s", client.RemoteAddr(), l.tcpBackend))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pipe(backend, client)
+	}()
+	pipe(client, backend)
+	wg.Wait()
+}
+
+// pipe копирует данные из src в dst и закрывает запись в dst, чтобы другая сторона
+// получила конец потока, а ответ в обратном направлении продолжал передаваться
+func pipe(dst, src net.Conn) {
+	bufp := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(bufp)
+
+	io.CopyBuffer(dst, src, *bufp)
+	if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	} else {
+		dst.Close()
+	}
+}
+
+func (l *sniffListener) Accept() (net.Conn, error) {
+	select {
+	case result := <-l.accepted:
+		return result.conn, result.err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *sniffListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// sniffedConn соединение, чтение которого начинается с прочитанных при определении
+// протокола байтов
+type sniffedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *sniffedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// CloseWrite закрывает запись в TCP соединение
+func (c *sniffedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
+
+// tunnels соединения, которые listener передает бэкендам без разбора протокола. http.Server
+// о них не знает, поэтому при остановке сервер ждет их завершения и закрывает оставшиеся.
+type tunnels struct {
+	mu       sync.Mutex
+	stopping bool
+	active   sync.WaitGroup
+
+	// Отменяется, когда оставшиеся туннели нужно закрыть
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newTunnels() *tunnels {
+	t := &tunnels{}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	return t
+}
+
+// run выполняет туннель fn для клиентского соединения conn. Во время остановки сервера
+// новые туннели не открываются, а conn закрывается.
+func (t *tunnels) run(conn net.Conn, fn func(ctx context.Context)) {
+	// Новый туннель учитывается под блокировкой, чтобы shutdown не начал ожидание раньше
+	t.mu.Lock()
+	if t.stopping {
+		t.mu.Unlock()
+		conn.Close()
+		return
+	}
+	t.active.Add(1)
+	t.mu.Unlock()
+	defer t.active.Done()
+
+	stop := context.AfterFunc(t.ctx, func() { conn.Close() })
+	defer stop()
+	fn(t.ctx)
+}
+
+// shutdown перестает открывать туннели и ждет завершения текущих до окончания ctx,
+// после чего закрывает оставшиеся
+func (t *tunnels) shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	t.stopping = true
+	t.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		t.active.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		t.cancel()
+		<-finished
+		return ctx.Err()
+	}
+}
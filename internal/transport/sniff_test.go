@@ -0,0 +1,92 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestServer_Sniff(t *testing.T) {
+	// Сертификат и доверяющий ему клиент тестового TLS сервера
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	// TCP бэкенд приветствует клиента и возвращает полученные строки
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	go func() {
+		for {
+			conn, err := backend.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.WriteString(conn, "hello\n")
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	s := NewServer(logger.NewNop())
+	s.SetSniff(&config.ListenerSniffConfig{Timeout: 100 * time.Millisecond, TCPBackend: backend.Addr().String()})
+	s.SetRedirectHTTPS(8443)
+	s.server.TLSConfig = &tls.Config{Certificates: ts.TLS.Certificates}
+	s.tls = true
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	addr := s.Addr()
+
+	// TLS соединение обслуживается прокси, в том числе по HTTP/2
+	client := ts.Client()
+	client.Transport.(*http.Transport).ForceAttemptHTTP2 = true
+	resp, err := client.Get("https://" + addr + "/")
+	if err != nil {
+		t.Fatalf("TLS запрос на порт с sniff: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.ProtoMajor != 2 {
+		t.Errorf("TLS запрос должен дойти до прокси по HTTP/2: %d %s", resp.StatusCode, resp.Proto)
+	}
+
+	// HTTP без TLS на том же порту перенаправляется на HTTPS
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err = noRedirect.Get("http://" + addr + "/path")
+	if err != nil {
+		t.Fatalf("HTTP запрос на порт с sniff: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "https://127.0.0.1:8443/path" {
+		t.Errorf("HTTP запрос должен перенаправляться на HTTPS: %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	// Соединение другого протокола передается TCP бэкенду, в том числе если клиент
+	// ждет, что первым заговорит сервер
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	if line, err := r.ReadString('\n'); err != nil || line != "hello\n" {
+		t.Fatalf("ожидалось приветствие TCP бэкенда, получено %q: %v", line, err)
+	}
+	io.WriteString(conn, "ping\n")
+	if line, err := r.ReadString('\n'); err != nil || line != "ping\n" {
+		t.Errorf("TCP бэкенд должен получать данные клиента, получено %q: %v", line, err)
+	}
+}
@@ -0,0 +1,265 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+)
+
+// staticParams параметры middleware static
+type staticParams struct {
+	// Маршруты со статическими файлами; применяется первый подходящий
+	Routes []staticRouteParams `yaml:"routes"`
+}
+
+// staticRouteParams маршрут, обслуживаемый файлами локального каталога
+type staticRouteParams struct {
+	// Префикс пути маршрута; остаток пути — путь файла в каталоге root
+	PathPrefix string `yaml:"pathPrefix"`
+	Root       string `yaml:"root"`
+
+	// Файлы, отдаваемые при запросе каталога (по умолчанию index.html)
+	Index []string `yaml:"index"`
+
+	// Отдавать список файлов каталога без индексного файла
+	Listing bool `yaml:"listing"`
+
+	// Файл, отдаваемый вместо отсутствующих (например, index.html для SPA)
+	Fallback string `yaml:"fallback"`
+
+	// Значение заголовка Cache-Control ответов
+	CacheControl string `yaml:"cacheControl"`
+}
+
+// staticRoute разобранный маршрут middleware static
+type staticRoute struct {
+	pathPrefix   string
+	root         string
+	index        []string
+	listing      bool
+	fallback     string
+	cacheControl string
+}
+
+// static отдает файлы из локальных каталогов, не обращаясь к бэкендам
+type static struct {
+	routes []*staticRoute
+	logger logger.Logger
+}
+
+// newStaticMiddleware создает middleware, обслуживающий маршруты файлами
+func newStaticMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params staticParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	s, err := newStatic(params, appLogger)
+	if err != nil {
+		return nil, err
+	}
+	return s.middleware, nil
+}
+
+// newStatic проверяет каталоги маршрутов
+func newStatic(params staticParams, appLogger logger.Logger) (*static, error) {
+	if len(params.Routes) == 0 {
+		return nil, fmt.Errorf("static: at least one route is required")
+	}
+
+	s := &static{logger: appLogger}
+	for i, rp := range params.Routes {
+		if !strings.HasPrefix(rp.PathPrefix, "/") {
+			return nil, fmt.Errorf("static: routes[%d].pathPrefix must start with /", i)
+		}
+		info, err := os.Stat(rp.Root)
+		if err != nil {
+			return nil, fmt.Errorf("static: routes[%d].root: %w", i, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("static: routes[%d].root %s is not a directory", i, rp.Root)
+		}
+
+		route := &staticRoute{
+			pathPrefix:   rp.PathPrefix,
+			root:         rp.Root,
+			index:        rp.Index,
+			listing:      rp.Listing,
+			cacheControl: rp.CacheControl,
+		}
+		if len(route.index) == 0 {
+			route.index = []string{"index.html"}
+		}
+		if rp.Fallback != "" {
+			route.fallback = strings.TrimPrefix(path.Clean("/"+rp.Fallback), "/")
+		}
+		s.routes = append(s.routes, route)
+	}
+	return s, nil
+}
+
+// match возвращает маршрут запроса и путь файла относительно каталога маршрута
+func (s *static) match(r *http.Request) (*staticRoute, string) {
+	for _, route := range s.routes {
+		rest, ok := strings.CutPrefix(r.URL.Path, route.pathPrefix)
+		if !ok {
+			continue
+		}
+		// Префикс /app не должен совпадать с /application
+		if !strings.HasSuffix(route.pathPrefix, "/") && rest != "" && rest[0] != '/' {
+			continue
+		}
+		return route, strings.TrimPrefix(path.Clean("/"+rest), "/")
+	}
+	return nil, ""
+}
+
+func (s *static) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, name := s.match(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if req := request.FromContext(r.Context()); req != nil {
+			request.Set(req, request.KeyRoute, "static:"+route.pathPrefix)
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		route.serve(w, r, name, s.logger)
+	})
+}
+
+// serve отдает файл или каталог name
+func (route *staticRoute) serve(w http.ResponseWriter, r *http.Request, name string, appLogger logger.Logger) {
+	f, info, err := route.open(name)
+	if errors.Is(err, fs.ErrNotExist) && route.fallback != "" {
+		name = route.fallback
+		f, info, err = route.open(name)
+	}
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			appLogger.Debug(fmt.Sprintf("Не удалось открыть файл %s в %s: %v", name, route.root, err))
+		}
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	if info.IsDir() {
+		// Относительные ссылки страниц каталога требуют завершающего /
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			target := r.URL.Path + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		for _, index := range route.index {
+			indexFile, indexInfo, err := route.open(path.Join(name, index))
+			if err == nil && !indexInfo.IsDir() {
+				defer indexFile.Close()
+				route.serveFile(w, r, indexFile, indexInfo)
+				return
+			}
+			if err == nil {
+				indexFile.Close()
+			}
+		}
+		if !route.listing {
+			http.NotFound(w, r)
+			return
+		}
+		route.serveListing(w, r, f)
+		return
+	}
+	route.serveFile(w, r, f, info)
+}
+
+// open открывает файл внутри каталога маршрута. Скрытые файлы (.git, .env) не отдаются;
+// выход за пределы каталога, в том числе по символическим ссылкам, запрещен.
+func (route *staticRoute) open(name string) (*os.File, fs.FileInfo, error) {
+	if name == "" {
+		name = "."
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") && segment != "." {
+			return nil, nil, fs.ErrNotExist
+		}
+	}
+
+	f, err := os.OpenInRoot(route.root, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+// serveFile отдает файл с ETag; условные запросы и Range обрабатывает http.ServeContent
+func (route *staticRoute) serveFile(w http.ResponseWriter, r *http.Request, f *os.File, info fs.FileInfo) {
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	if route.cacheControl != "" {
+		w.Header().Set("Cache-Control", route.cacheControl)
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// serveListing отдает HTML страницу со списком файлов каталога
+func (route *staticRoute) serveListing(w http.ResponseWriter, r *http.Request, dir *os.File) {
+	entries, err := dir.ReadDir(-1)
+	if err != nil {
+		http.Error(w, "Failed to read directory", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var b strings.Builder
+	title := html.EscapeString(r.URL.Path)
+	b.WriteString("<!doctype html>\n<meta charset=\"utf-8\">\n<title>" + title + "</title>\n<h1>" + title + "</h1>\n<ul>\n")
+	if r.URL.Path != route.pathPrefix && r.URL.Path != route.pathPrefix+"/" {
+		b.WriteString("<li><a href=\"../\">../</a></li>\n")
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		href := (&url.URL{Path: name}).String()
+		b.WriteString("<li><a href=\"" + html.EscapeString(href) + "\">" + html.EscapeString(name) + "</a></li>\n")
+	}
+	b.WriteString("</ul>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(b.Len()))
+	if route.cacheControl != "" {
+		w.Header().Set("Cache-Control", route.cacheControl)
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,86 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestStatic(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "index.html"), []byte("<h1>app</h1>"), 0o644)
+	os.WriteFile(filepath.Join(root, "app.js"), []byte("console.log(1)"), 0o644)
+	os.WriteFile(filepath.Join(root, ".env"), []byte("SECRET=1"), 0o644)
+	os.Mkdir(filepath.Join(root, "files"), 0o755)
+	os.WriteFile(filepath.Join(root, "files", "a <b>.txt"), []byte("text"), 0o644)
+
+	s, err := newStatic(staticParams{Routes: []staticRouteParams{
+		{PathPrefix: "/files", Root: filepath.Join(root, "files"), Listing: true},
+		{PathPrefix: "/", Root: root, Fallback: "index.html", CacheControl: "no-cache"},
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := s.middleware(http.NotFoundHandler())
+
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/app.js")
+	if rec.Code != http.StatusOK || rec.Body.String() != "console.log(1)" || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("неверный ответ: %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("ответ без ETag")
+	}
+	if rec := get("/app.js", "If-None-Match", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: ожидался статус 304, получен %d", rec.Code)
+	}
+	if rec := get("/app.js", "Range", "bytes=0-6"); rec.Code != http.StatusPartialContent || rec.Body.String() != "console" {
+		t.Errorf("Range: %d %q", rec.Code, rec.Body.String())
+	}
+
+	// Индексный файл и запасной файл SPA
+	if rec := get("/"); rec.Body.String() != "<h1>app</h1>" {
+		t.Errorf("индексный файл не отдан: %q", rec.Body.String())
+	}
+	if rec := get("/users/42"); rec.Code != http.StatusOK || rec.Body.String() != "<h1>app</h1>" {
+		t.Errorf("запасной файл не отдан: %d %q", rec.Code, rec.Body.String())
+	}
+
+	// Скрытые файлы и выход за пределы каталога не отдаются
+	for _, path := range []string{"/.env", "/files/../.env", "/files/%2e%2e/.env"} {
+		if rec := get(path); strings.Contains(rec.Body.String(), "SECRET") {
+			t.Errorf("%s: отдан скрытый файл", path)
+		}
+	}
+
+	// Список файлов каталога
+	if rec := get("/files"); rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/files/" {
+		t.Errorf("ожидалось перенаправление на /files/: %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	rec = get("/files/")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `<a href="a%20%3Cb%3E.txt">a &lt;b&gt;.txt</a>`) {
+		t.Errorf("неверный список файлов: %d %s", rec.Code, rec.Body.String())
+	}
+
+	req := httptest.NewRequest("POST", "/app.js", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: ожидался статус 405, получен %d", rec.Code)
+	}
+}
@@ -0,0 +1,374 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/filter"
+	"cloud.ru_test/pkg/logger"
+	"cloud.ru_test/pkg/request"
+)
+
+// transformParams параметры middleware transform
+type transformParams struct {
+	// Максимальный размер преобразуемого тела запроса и ответа (по умолчанию 1MB)
+	MaxBodySize int64 `yaml:"maxBodySize"`
+
+	// Маршруты с преобразованиями; применяется первый подходящий
+	Routes []transformRouteParams `yaml:"routes"`
+}
+
+// transformRouteParams преобразования запросов маршрута
+type transformRouteParams struct {
+	// Префикс пути и методы запросов маршрута (по умолчанию все)
+	PathPrefix string   `yaml:"pathPrefix"`
+	Methods    []string `yaml:"methods"`
+
+	// Построение запроса к бэкенду по шаблону
+	Request *transformRequestParams `yaml:"request"`
+
+	// Преобразование JSON ответа бэкенда
+	Response *transformResponseParams `yaml:"response"`
+}
+
+// transformRequestParams шаблон запроса к бэкенду
+type transformRequestParams struct {
+	// Метод запроса к бэкенду (по умолчанию метод клиента)
+	Method string `yaml:"method"`
+
+	// Шаблон пути запроса к бэкенду (по умолчанию путь клиента)
+	Path string `yaml:"path"`
+
+	// Шаблон тела запроса (text/template) и его тип (по умолчанию application/json)
+	Body        string `yaml:"body"`
+	ContentType string `yaml:"contentType"`
+}
+
+// transformResponseParams правила преобразования JSON ответа
+type transformResponseParams struct {
+	// Удаляемые поля (JSONPath)
+	Remove []string `yaml:"remove"`
+
+	// Переименование полей: JSONPath поля → новое имя
+	Rename map[string]string `yaml:"rename"`
+}
+
+// transformRoute разобранный маршрут middleware transform
+type transformRoute struct {
+	pathPrefix string
+	methods    map[string]bool
+
+	method      string
+	path        *template.Template
+	body        *template.Template
+	contentType string
+
+	transformsResponse bool
+	remove             []*jsonPath
+	rename             []jsonRename
+}
+
+// jsonRename правило переименования поля
+type jsonRename struct {
+	path *jsonPath
+	name string
+}
+
+// transform строит запросы к бэкенду по шаблонам и преобразует JSON ответы
+type transform struct {
+	routes      []*transformRoute
+	maxBodySize int64
+	logger      logger.Logger
+}
+
+// transformData данные, доступные шаблонам запроса
+type transformData struct {
+	// Метод, путь и query запроса клиента
+	Method   string
+	Path     string
+	RawQuery string
+
+	// Тело запроса клиента: разобранный JSON (nil, если тело не JSON) и исходный текст
+	Body    interface{}
+	RawBody string
+
+	// Идентификатор пользователя (после аутентификации) или IP адрес клиента
+	UserID string
+
+	req *http.Request
+}
+
+// Query возвращает параметр query запроса клиента
+func (d transformData) Query(name string) string {
+	return d.req.URL.Query().Get(name)
+}
+
+// Header возвращает заголовок запроса клиента
+func (d transformData) Header(name string) string {
+	return d.req.Header.Get(name)
+}
+
+// transformFuncs функции шаблонов запроса
+var transformFuncs = template.FuncMap{
+	// json кодирует значение в JSON, например {{ json (.Query "id") }} дает строку в кавычках
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// newTransformMiddleware создает middleware, преобразующий запросы и ответы маршрутов
+func newTransformMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params transformParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	t, err := newTransform(params, appLogger)
+	if err != nil {
+		return nil, err
+	}
+	return t.middleware, nil
+}
+
+// newTransform разбирает шаблоны и выражения JSONPath маршрутов
+func newTransform(params transformParams, appLogger logger.Logger) (*transform, error) {
+	if len(params.Routes) == 0 {
+		return nil, fmt.Errorf("transform: at least one route is required")
+	}
+
+	t := &transform{maxBodySize: params.MaxBodySize, logger: appLogger}
+	if t.maxBodySize <= 0 {
+		t.maxBodySize = defaultMaxFilterBodySize
+	}
+
+	for i, rp := range params.Routes {
+		route := &transformRoute{pathPrefix: rp.PathPrefix, methods: make(map[string]bool)}
+		for _, method := range rp.Methods {
+			route.methods[strings.ToUpper(method)] = true
+		}
+
+		if req := rp.Request; req != nil {
+			route.method = strings.ToUpper(req.Method)
+			route.contentType = req.ContentType
+			if route.contentType == "" {
+				route.contentType = "application/json"
+			}
+			var err error
+			if req.Path != "" {
+				if route.path, err = template.New("path").Funcs(transformFuncs).Parse(req.Path); err != nil {
+					return nil, fmt.Errorf("transform: routes[%d].request.path: %w", i, err)
+				}
+			}
+			if req.Body != "" {
+				if route.body, err = template.New("body").Funcs(transformFuncs).Parse(req.Body); err != nil {
+					return nil, fmt.Errorf("transform: routes[%d].request.body: %w", i, err)
+				}
+			}
+		}
+
+		if resp := rp.Response; resp != nil {
+			route.transformsResponse = true
+			for _, expr := range resp.Remove {
+				path, err := parseJSONPath(expr)
+				if err != nil {
+					return nil, fmt.Errorf("transform: routes[%d].response.remove: %w", i, err)
+				}
+				route.remove = append(route.remove, path)
+			}
+
+			// Переименования применяются в порядке выражений, чтобы результат не зависел от порядка ключей
+			exprs := make([]string, 0, len(resp.Rename))
+			for expr := range resp.Rename {
+				exprs = append(exprs, expr)
+			}
+			sort.Strings(exprs)
+			for _, expr := range exprs {
+				path, err := parseJSONPath(expr)
+				if err != nil {
+					return nil, fmt.Errorf("transform: routes[%d].response.rename: %w", i, err)
+				}
+				if resp.Rename[expr] == "" {
+					return nil, fmt.Errorf("transform: routes[%d].response.rename: empty new name for %s", i, expr)
+				}
+				route.rename = append(route.rename, jsonRename{path: path, name: resp.Rename[expr]})
+			}
+		}
+
+		t.routes = append(t.routes, route)
+	}
+	return t, nil
+}
+
+// match возвращает первый маршрут, подходящий запросу
+func (t *transform) match(r *http.Request) *transformRoute {
+	for _, route := range t.routes {
+		if !strings.HasPrefix(r.URL.Path, route.pathPrefix) {
+			continue
+		}
+		if len(route.methods) > 0 && !route.methods[r.Method] {
+			continue
+		}
+		return route
+	}
+	return nil
+}
+
+func (t *transform) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := t.match(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if route.method != "" || route.path != nil || route.body != nil {
+			out, status, err := t.buildRequest(route, r)
+			if err != nil {
+				t.logger.Debug(fmt.Sprintf("Не удалось построить запрос к бэкенду для %s %s: %v", r.Method, r.URL.Path, err))
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+			t.logger.Debug(fmt.Sprintf("Запрос %s %s преобразован в %s %s", r.Method, r.URL.Path, out.Method, out.URL.Path))
+			r = out
+		}
+
+		if !route.transformsResponse || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &filterResponseWriter{ResponseWriter: w, header: make(http.Header), limit: t.maxBodySize}
+		next.ServeHTTP(rec, r)
+		if rec.overflow {
+			t.logger.Debug(fmt.Sprintf("Ответ на запрос %s больше %d байт и передан без преобразования", r.URL.Path, t.maxBodySize))
+			return
+		}
+
+		resp := &filter.Response{StatusCode: rec.status(), Header: rec.header, Body: rec.body.Bytes()}
+		if body, err := route.transformResponse(resp); err != nil {
+			t.logger.Debug(fmt.Sprintf("Ответ на запрос %s передан без преобразования: %v", r.URL.Path, err))
+		} else {
+			resp.Body = body
+		}
+		writeFilterResponse(w, resp)
+	})
+}
+
+// buildRequest строит запрос к бэкенду по шаблонам маршрута. При ошибке возвращает статус ответа клиенту.
+func (t *transform) buildRequest(route *transformRoute, r *http.Request) (*http.Request, int, error) {
+	data := transformData{
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		RawQuery: r.URL.RawQuery,
+		UserID:   clientIP(r),
+		req:      r,
+	}
+	if req := request.FromContext(r.Context()); req != nil {
+		data.UserID = req.GetUserID()
+	}
+
+	if r.Body != nil {
+		raw, err := io.ReadAll(io.LimitReader(r.Body, t.maxBodySize+1))
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("failed to read request body: %w", err)
+		}
+		if int64(len(raw)) > t.maxBodySize {
+			return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", t.maxBodySize)
+		}
+		data.RawBody = string(raw)
+		if len(raw) > 0 && isJSON(r.Header.Get("Content-Type")) {
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.UseNumber()
+			if err := decoder.Decode(&data.Body); err != nil {
+				return nil, http.StatusBadRequest, fmt.Errorf("invalid JSON request body: %w", err)
+			}
+		}
+	}
+
+	out := r.Clone(r.Context())
+	if route.method != "" {
+		out.Method = route.method
+	}
+	if route.path != nil {
+		var path strings.Builder
+		if err := route.path.Execute(&path, data); err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("path template: %w", err)
+		}
+		if !strings.HasPrefix(path.String(), "/") {
+			return nil, http.StatusInternalServerError, fmt.Errorf("path template produced %q", path.String())
+		}
+		out.URL.Path, out.URL.RawPath = path.String(), ""
+	}
+
+	body := []byte(data.RawBody)
+	if route.body != nil {
+		var buf bytes.Buffer
+		if err := route.body.Execute(&buf, data); err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("body template: %w", err)
+		}
+		body = buf.Bytes()
+		out.Header.Set("Content-Type", route.contentType)
+		out.Header.Del("Content-Encoding")
+	}
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	out.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return out, 0, nil
+}
+
+// transformResponse применяет правила маршрута к JSON ответу и возвращает новое тело
+func (route *transformRoute) transformResponse(resp *filter.Response) ([]byte, error) {
+	if !isJSON(resp.Header.Get("Content-Type")) {
+		return nil, fmt.Errorf("content type %q is not JSON", resp.Header.Get("Content-Type"))
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return nil, fmt.Errorf("encoded response (%s) is not supported", encoding)
+	}
+
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(resp.Body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	for _, path := range route.remove {
+		for _, field := range path.fields(doc) {
+			delete(field.object, field.key)
+		}
+	}
+	for _, rule := range route.rename {
+		for _, field := range rule.path.fields(doc) {
+			value := field.object[field.key]
+			delete(field.object, field.key)
+			field.object[rule.name] = value
+		}
+	}
+
+	// Символы <, > и & не экранируются, чтобы не менять строки, не затронутые правилами
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// isJSON проверяет, что тип содержимого — JSON (application/json или */*+json)
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
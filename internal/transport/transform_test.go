@@ -0,0 +1,81 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestTransformResponse(t *testing.T) {
+	tr, err := newTransform(transformParams{Routes: []transformRouteParams{{
+		PathPrefix: "/users",
+		Response: &transformResponseParams{
+			Remove: []string{"$..password", "$.items[*].internal"},
+			Rename: map[string]string{"$.user_name": "userName"},
+		},
+	}}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		io.WriteString(w, `{"user_name":"alice","password":"x","items":[{"id":1,"internal":true},{"id":2,"nested":{"password":"y"}}],"big":12345678901234567890}`)
+	})
+
+	rec := httptest.NewRecorder()
+	tr.middleware(backend).ServeHTTP(rec, httptest.NewRequest("GET", "/users/1", nil))
+	want := `{"big":12345678901234567890,"items":[{"id":1},{"id":2,"nested":{}}],"userName":"alice"}`
+	if rec.Body.String() != want {
+		t.Errorf("неверное преобразование ответа:\n%s\nожидалось:\n%s", rec.Body.String(), want)
+	}
+
+	// Ответ вне маршрута не изменяется
+	rec = httptest.NewRecorder()
+	tr.middleware(backend).ServeHTTP(rec, httptest.NewRequest("GET", "/orders", nil))
+	if !strings.Contains(rec.Body.String(), `"password":"x"`) {
+		t.Errorf("ответ вне маршрута не должен изменяться: %s", rec.Body.String())
+	}
+}
+
+func TestTransformRequest(t *testing.T) {
+	tr, err := newTransform(transformParams{Routes: []transformRouteParams{{
+		PathPrefix: "/users/",
+		Methods:    []string{"get"},
+		Request: &transformRequestParams{
+			Method: "POST",
+			Path:   "/rpc",
+			Body:   `{"method":"getUser","params":{"id":{{ json (.Query "id") }},"trace":{{ json (.Header "X-Trace") }}}}`,
+		},
+	}}}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var method, path, contentType, body string
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, contentType, body = r.Method, r.URL.Path, r.Header.Get("Content-Type"), string(data)
+	})
+
+	req := httptest.NewRequest("GET", `/users/?id=a"b`, nil)
+	req.Header.Set("X-Trace", "t1")
+	tr.middleware(backend).ServeHTTP(httptest.NewRecorder(), req)
+
+	if method != "POST" || path != "/rpc" || contentType != "application/json" {
+		t.Errorf("неверный запрос к бэкенду: %s %s (%s)", method, path, contentType)
+	}
+	if want := `{"method":"getUser","params":{"id":"a\"b","trace":"t1"}}`; body != want {
+		t.Errorf("неверное тело запроса: %s", body)
+	}
+
+	for _, expr := range []string{"password", "$.items[*]", "$.a[", "$."} {
+		if _, err := parseJSONPath(expr); err == nil {
+			t.Errorf("выражение %q должно быть отклонено", expr)
+		}
+	}
+}
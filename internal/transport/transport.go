@@ -2,312 +2,451 @@ package transport
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
-	"strings"
+	"sync"
 	"time"
 
+	"cloud.ru_test/config"
+	backendpkg "cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/geoip"
 	"cloud.ru_test/pkg/logger"
 	"cloud.ru_test/pkg/request"
 
 	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/loadbalancer/base"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/internal/outlier"
+	"cloud.ru_test/internal/overload"
 	"cloud.ru_test/internal/ratelimit"
 )
 
-// UserRateLimit представляет настройки rate limit для пользователя
-type UserRateLimit struct {
-	Rate  float64 `json:"rate"`  // Запросов в секунду
-	Burst int     `json:"burst"` // Максимальный размер корзины
-}
+// statusClientClosedRequest статус запроса, клиент которого отключился до ответа бэкенда
+// (nginx 499). Клиенту он не доставляется, но его видят middleware, учитывающие статусы ответов.
+const statusClientClosedRequest = 499
 
-type Server interface {
-	ListenAndServe() error
-	Shutdown(ctx context.Context) error
-}
+// Заголовок с идентификатором запроса. Идентификатор, присланный клиентом или прокси
+// перед балансировщиком, сохраняется, иначе генерируется; бэкенд получает его в том же заголовке.
+const (
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
 
+// Proxy обрабатывает запросы клиентов: проверяет rate limit, выбирает бэкенд и проксирует запрос.
+// При реконфигурации создается новый Proxy, а Server переключается на него без пересоздания listener.
 type Proxy struct {
-	loadbalancer loadbalancer.LoadBalancer
-	ratelimit    ratelimit.RateLimiter
-	server       *http.Server
-	logger       *logger.CustomZapLogger
+	loadbalancer  loadbalancer.LoadBalancer
+	ratelimit     ratelimit.RateLimiter
+	handler       http.Handler
+	logger        logger.Logger
+	probeHeader   string
+	versionHeader string
+	forwarding    *config.ForwardedHeadersConfig
+	outlier       *outlier.Detector
+	overload      *overload.Limiter
+	geoIP         *geoip.Resolver
+	trusted       request.TrustedProxies
+	routingDebug  *RoutingDebug
+	recorder      *Recorder
+
+	// Правила маршрутизации; используются для TLS соединений без расшифровки
+	router *Router
+
+	// Запросы, обрабатываемые этим экземпляром прокси. После начала Drain новые запросы
+	// не принимаются, чтобы ожидание не пропустило запрос, начатый сразу после замены прокси.
+	mu       sync.Mutex
+	inFlight int
+	draining bool
+
+	// Закрывается, когда после начала Drain не осталось запросов
+	idle chan struct{}
+}
+
+// Options дополнительные параметры прокси
+type Options struct {
+	// Заголовок пробных запросов к бэкендам в режиме обслуживания (пустой — отключено)
+	ProbeHeader string
+
+	// Заголовок с версией бэкендов, на которые направляется запрос (пустой — отключено)
+	VersionHeader string
+
+	// Заголовки X-Forwarded-* и Forwarded (nil — режим append без Forwarded)
+	Forwarding *config.ForwardedHeadersConfig
+
+	// Детектор выбросов, учитывающий результаты запросов (nil — отключено)
+	Outlier *outlier.Detector
+
+	// Дополнительные middleware из секции middlewares (nil — только встроенные этапы)
+	Middlewares *Chain
+
+	// Правила маршрутизации (nil — все запросы обрабатываются одним маршрутом)
+	Router *Router
+
+	// Ограничитель одновременных запросов (nil — без ограничения)
+	Overload *overload.Limiter
+
+	// Определение страны и автономной системы клиента (nil — отключено)
+	GeoIP *geoip.Resolver
+
+	// Прокси, от которых принимаются заголовки X-Forwarded-For и X-Real-IP (nil — ни от кого)
+	TrustedProxies request.TrustedProxies
+
+	// Режим отладки маршрутизации (nil — отключено)
+	RoutingDebug *RoutingDebug
+
+	// Асинхронный учет обработанных запросов (nil — учет выполняется в горутине запроса,
+	// журнал доступа не пишется)
+	Recorder *Recorder
 }
 
-func NewProxy(lb loadbalancer.LoadBalancer, limiter ratelimit.RateLimiter, appLogger *logger.CustomZapLogger) *Proxy {
+// NewProxy создает прокси с конвейером обработки запросов
+// auth → ratelimit → rewrite → balance → proxy
+func NewProxy(lb loadbalancer.LoadBalancer, limiter ratelimit.RateLimiter, opts Options, appLogger logger.Logger) *Proxy {
 	p := &Proxy{
-		loadbalancer: lb,
-		ratelimit:    limiter,
-		logger:       appLogger,
+		loadbalancer:  lb,
+		ratelimit:     limiter,
+		logger:        appLogger,
+		probeHeader:   opts.ProbeHeader,
+		versionHeader: opts.VersionHeader,
+		forwarding:    opts.Forwarding,
+		outlier:       opts.Outlier,
+		overload:      opts.Overload,
+		geoIP:         opts.GeoIP,
+		trusted:       opts.TrustedProxies,
+		routingDebug:  opts.RoutingDebug,
+		recorder:      opts.Recorder,
+		router:        opts.Router,
+		idle:          make(chan struct{}),
 	}
 
-	// Создаем HTTP сервер
+	routes := p.routes(opts.Router, p.balance(http.HandlerFunc(p.forward)), opts.Middlewares)
+
+	// ServeMux нормализует путь запроса до проверки правил маршрутизации
 	mux := http.NewServeMux()
+	mux.Handle("/", p.newRequest(p.shed(p.access(p.recoverPanic(p.debug(p.resolveGeo(p.logRequest(routes))))))))
 
-	// Основной прокси хендлер
-	mux.HandleFunc("/", p.handleRequest)
+	p.handler = mux
 
-	mux.HandleFunc("/ratelimit/", p.handleRateLimit)
+	return p
+}
 
-	p.server = &http.Server{
-		Handler: mux,
+// ServeHTTP реализует http.Handler. Прокси, начавший Drain, отвечает 503.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.enter() {
+		http.Error(w, "Proxy is draining", http.StatusServiceUnavailable)
+		return
 	}
+	defer p.leave()
 
-	return p
+	p.handler.ServeHTTP(w, r)
+}
+
+// enter учитывает начало запроса; false — прокси уже завершает работу
+func (p *Proxy) enter() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.draining {
+		return false
+	}
+	p.inFlight++
+	return true
 }
 
-func (p *Proxy) Start(port string) error {
-	p.logger.Debug(fmt.Sprintf("Запуск прокси-сервера на порту %s", port))
+// leave учитывает завершение запроса
+func (p *Proxy) leave() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	// Добавляем настройки для быстрого освобождения порта
-	p.server.Addr = port
-	p.server.SetKeepAlivesEnabled(false) // Отключаем keep-alive для быстрого закрытия соединений
+	p.inFlight--
+	if p.draining && p.inFlight == 0 {
+		close(p.idle)
+	}
+}
 
-	// Запускаем сервер в отдельной горутине
-	go func() {
-		if err := p.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			p.logger.Error(fmt.Sprintf("Ошибка запуска сервера: %v", err))
+// Drain перестает принимать запросы и ожидает завершения тех, которые обрабатывает
+// этот экземпляр прокси
+func (p *Proxy) Drain(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.draining {
+		p.draining = true
+		if p.inFlight == 0 {
+			close(p.idle)
 		}
-	}()
-
-	// Даем серверу время на запуск
-	time.Sleep(100 * time.Millisecond)
+	}
+	p.mu.Unlock()
 
-	return nil
+	select {
+	case <-p.idle:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("proxy drain interrupted: %w", ctx.Err())
+	}
 }
 
-func (p *Proxy) Stop() error {
-	p.logger.Debug("Начало graceful shutdown прокси-сервера")
+// logRequest отмечает в логе начало обработки запроса
+func (p *Proxy) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if log := logger.FromContext(r.Context(), p.logger); log.DebugEnabled() {
+			log.Debug(fmt.Sprintf("Получен новый запрос: %s %s от %s", r.Method, r.URL.Path, r.RemoteAddr))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-	// Создаем контекст с таймаутом для graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// shed отвечает 503 на запросы сверх лимита одновременных запросов, не тратя ресурсы
+// на их обработку. Приоритет запроса определяется по маршруту и пользователю.
+func (p *Proxy) shed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var userID string
+		if req := request.FromContext(r.Context()); req != nil {
+			userID = req.GetUserID()
+		}
+		release, reason := p.overload.Acquire(r.Context(), r.URL.Path, userID)
+		if reason != "" {
+			if p.logger.DebugEnabled() {
+				p.logger.Debug(fmt.Sprintf("Запрос %s %s от %s отброшен защитой от перегрузки (%s)", r.Method, r.URL.Path, r.RemoteAddr, reason))
+			}
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Service overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
 
-	// Перестаем принимать новые соединения и ждем завершения текущих
-	if err := p.server.Shutdown(ctx); err != nil {
-		p.logger.Error(fmt.Sprintf("Ошибка при graceful shutdown: %v", err))
-		// Если не удалось graceful shutdown, закрываем принудительно
-		if err := p.server.Close(); err != nil {
-			p.logger.Error(fmt.Sprintf("Ошибка при принудительном закрытии: %v", err))
+// newRequest создает request.Request, общий для всех этапов обработки, и сохраняет его в контексте
+// вместе с логгером запроса, добавляющим к сообщениям идентификатор запроса и адрес клиента
+func (p *Proxy) newRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = fmt.Sprintf("%016x", rand.Uint64())
+			r.Header.Set(requestIDHeader, id)
 		}
-		return err
-	}
+		req := request.NewRequest(r, p.trusted)
+		request.Set(req, request.KeyRequestID, id)
 
-	p.logger.Debug("Прокси-сервер успешно остановлен")
-	return nil
+		ctx := request.NewContext(r.Context(), req)
+		ctx = logger.WithContext(ctx, logger.With(p.logger, "requestID", id, "client", req.GetClientIP()))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
 
-// handleRequest обрабатывает входящие HTTP запросы к бэкендам
-func (p *Proxy) handleRequest(w http.ResponseWriter, r *http.Request) {
-	p.logger.Debug(fmt.Sprintf("Получен новый запрос: %s %s от %s", r.Method, r.URL.Path, r.RemoteAddr))
+// clientIP возвращает IP адрес клиента, определенный прокси с учетом доверенных прокси,
+// или адрес отправителя, если запрос обрабатывается вне прокси
+func clientIP(r *http.Request) string {
+	if req := request.FromContext(r.Context()); req != nil {
+		return req.GetClientIP()
+	}
+	return request.TrustedProxies(nil).ClientIP(r)
+}
 
-	// проверяем даст ли токен
-	if !p.ratelimit.Allow(r.RemoteAddr) {
-		p.logger.Debug(fmt.Sprintf("Превышен rate limit для %s", r.RemoteAddr))
-		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
-		return
+// geoRequests счетчик запросов по странам клиентов
+var geoRequests = metrics.Default.Counter("lb_geo_requests_total", "Requests by client country", "country")
+
+// resolveGeo определяет страну и автономную систему клиента и сохраняет их
+// в request.Request, откуда их получают middleware и балансировщик
+func (p *Proxy) resolveGeo(next http.Handler) http.Handler {
+	if p.geoIP == nil {
+		return next
 	}
-	p.logger.Debug(fmt.Sprintf("Rate limit проверка пройдена для %s", r.RemoteAddr))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := p.geoIP.Lookup(net.ParseIP(clientIP(r)))
+		country := info.Country
+		if country == "" {
+			country = "unknown"
+		}
+		geoRequests.Inc(country)
+		request.Set(request.FromContext(r.Context()), request.KeyGeo, info)
+		next.ServeHTTP(w, r)
+	})
+}
 
-	customReq := request.NewRequest(r)
-	p.logger.Debug(fmt.Sprintf("Создан кастомный запрос для пользователя %s", customReq.GetUserID()))
+// balance выбирает бэкенд для запроса и сохраняет его в контексте (этап balance)
+func (p *Proxy) balance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		customReq := request.FromContext(r.Context())
+		if customReq == nil {
+			customReq = request.NewRequest(r, p.trusted)
+		}
+		log := logger.FromContext(r.Context(), p.logger)
+		if log.DebugEnabled() {
+			if geo := customReq.GetGeo(); geo.Country != "" || geo.ASN != 0 {
+				log.Debug(fmt.Sprintf("Создан кастомный запрос для пользователя %s (страна: %s, AS%d %s)", customReq.GetUserID(), geo.Country, geo.ASN, geo.ASOrg))
+			} else {
+				log.Debug(fmt.Sprintf("Создан кастомный запрос для пользователя %s", customReq.GetUserID()))
+			}
+		}
 
-	backend := p.loadbalancer.Invoke(customReq)
-	if backend == nil {
-		p.logger.Debug("Не найдено доступных бэкендов")
-		http.Error(w, "No available backends", http.StatusServiceUnavailable)
-		return
+		if p.versionHeader != "" {
+			if version := r.Header.Get(p.versionHeader); version != "" {
+				request.Set(customReq, request.KeyBackendVersion, version)
+			}
+		}
+
+		backend := p.probeBackend(r)
+		if backend != nil {
+			base.Explain(customReq, "probe request to backend in maintenance (header %s)", p.probeHeader)
+		} else {
+			backend = p.loadbalancer.Invoke(customReq)
+		}
+		if backend == nil {
+			log.Debug("Не найдено доступных бэкендов")
+			http.Error(w, "No available backends", http.StatusServiceUnavailable)
+			return
+		}
+		if log.DebugEnabled() {
+			log.Debug(fmt.Sprintf("Выбран бэкенд %s для запроса", backend.ID()))
+		}
+		if base.Tracing(customReq) {
+			request.Set(customReq, debugBackend, backend.ID())
+		}
+		if _, ok := request.Get(customReq, request.KeyAffinity); ok {
+			request.Set(customReq, request.KeyAffinity, backend.ID())
+		}
+
+		// Пользователь и маршрут известны после этапов auth и rewrite, бэкенд — после выбора
+		fields := []interface{}{"userID", customReq.GetUserID(), "backend", backend.ID()}
+		if route, ok := request.Get(customReq, request.KeyRoute); ok {
+			fields = append(fields, "route", route)
+		}
+		r = r.WithContext(logger.WithContext(r.Context(), logger.With(log, fields...)))
+
+		next.ServeHTTP(w, withBackend(r, backend))
+	})
+}
+
+// forward проксирует запрос к бэкенду, выбранному на этапе balance (этап proxy)
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request) {
+	backend := BackendFromContext(r.Context())
+	log := logger.FromContext(r.Context(), p.logger)
+	if rec := recordFromContext(r.Context()); rec != nil {
+		rec.backend = backend
 	}
-	p.logger.Debug(fmt.Sprintf("Выбран бэкенд %s для запроса", backend.ID()))
 
 	// Создаем URL для запроса к бэкенду
-	backendURL := backend.URL() + r.URL.Path
+	backendURL := backendpkg.RequestURL(backend.URL(), r.URL.Path)
 	if r.URL.RawQuery != "" {
 		backendURL += "?" + r.URL.RawQuery
 	}
-	p.logger.Debug(fmt.Sprintf("Проксирование запроса к %s", backendURL))
+	if log.DebugEnabled() {
+		log.Debug(fmt.Sprintf("Проксирование запроса к %s", backendURL))
+	}
 
 	outReq, err := http.NewRequestWithContext(r.Context(), r.Method, backendURL, r.Body)
 	if err != nil {
-		p.logger.Error(fmt.Sprintf("Ошибка создания запроса к бэкенду: %v", err))
+		log.Error(fmt.Sprintf("Ошибка создания запроса к бэкенду: %v", err))
 		http.Error(w, "Ошибка создания запроса к бэкенду", http.StatusInternalServerError)
 		return
 	}
 
+	// Длина тела известна, если его не заменили потоком middleware (например, сжатием)
+	outReq.ContentLength = r.ContentLength
+
 	// Копируем заголовки из оригинального запроса
 	outReq.Header = r.Header.Clone()
-	p.logger.Debug("Заголовки запроса скопированы")
+	if p.probeHeader != "" {
+		outReq.Header.Del(p.probeHeader)
+	}
+	log.Debug("Заголовки запроса скопированы")
 
 	// Добавляем заголовки прокси
-	outReq.Header.Set("X-Forwarded-For", r.RemoteAddr)
+	p.setForwardedHeaders(outReq.Header, r)
 	outReq.Header.Set("X-Proxy-ID", "cloud-ru-proxy")
-	outReq.Header.Set("X-Real-IP", r.RemoteAddr)
-	p.logger.Debug("Добавлены прокси-заголовки")
+	outReq.Header.Set("X-Real-IP", clientIP(r))
+	log.Debug("Добавлены прокси-заголовки")
 
 	// Отправляем запрос на бэкенд
 	start := time.Now()
 	resp, err := backend.Handle(r.Context(), outReq)
 	duration := time.Since(start)
 
+	if errors.Is(err, backendpkg.ErrMaxConnections) {
+		// Бэкенд успел исчерпать лимит соединений после выбора балансировщиком
+		if log.DebugEnabled() {
+			log.Debug(fmt.Sprintf("Исчерпан лимит соединений бэкенда %s", backend.ID()))
+		}
+		http.Error(w, "Backend connection limit reached", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil && (errors.Is(err, errBodyTooSlow) || bodyTooSlow(r.Context())) {
+		// Клиент передавал тело слишком медленно: это не сбой бэкенда
+		if log.DebugEnabled() {
+			log.Debug(fmt.Sprintf("Запрос к бэкенду %s прерван: %v", backend.ID(), err))
+		}
+		http.Error(w, "Request body transfer too slow", http.StatusRequestTimeout)
+		return
+	}
+	if err != nil && errors.Is(r.Context().Err(), context.Canceled) {
+		// Клиент отключился, и запрос к бэкенду прерван: это не сбой бэкенда
+		if log.DebugEnabled() {
+			log.Debug(fmt.Sprintf("Клиент отключился до получения ответа от бэкенда %s через %v", backend.ID(), duration))
+		}
+		w.WriteHeader(statusClientClosedRequest)
+		return
+	}
 	if err != nil {
-		p.logger.Debug(fmt.Sprintf("Ошибка при запросе к бэкенду %s: %v, URL: %s", backend.ID(), err, backendURL))
+		p.observe(r, backend, 0, err)
+		if log.DebugEnabled() {
+			log.Debug(fmt.Sprintf("Ошибка при запросе к бэкенду %s: %v, URL: %s", backend.ID(), err, backendURL))
+		}
 		http.Error(w, fmt.Sprintf("Backend error: %v", err), http.StatusBadGateway)
 		return
 	}
-	p.logger.Debug(fmt.Sprintf("Получен ответ от бэкенда %s за %v, статус: %d", backend.ID(), duration, resp.StatusCode))
+	p.observe(r, backend, resp.StatusCode, nil)
+	if log.DebugEnabled() {
+		log.Debug(fmt.Sprintf("Получен ответ от бэкенда %s за %v, статус: %d", backend.ID(), duration, resp.StatusCode))
+	}
 	defer resp.Body.Close()
 
 	// Копируем заголовки ответа
 	for k, v := range resp.Header {
 		w.Header()[k] = v
 	}
-	p.logger.Debug("Заголовки ответа скопированы")
+	log.Debug("Заголовки ответа скопированы")
 
 	// Устанавливаем статус ответа
 	w.WriteHeader(resp.StatusCode)
 
 	// Копируем тело ответа
-	written, err := io.Copy(w, resp.Body)
-	if err != nil {
-		p.logger.Error(fmt.Sprintf("Error copying response body: %v\n", err))
-	} else {
-		p.logger.Debug(fmt.Sprintf("Тело ответа успешно отправлено клиенту, размер: %d байт", written))
+	written, err := copyResponse(w, resp.Body, streamingResponse(resp))
+	if errors.Is(err, errResponseTooLarge) {
+		// Превышение лимита логирует middleware responseLimit
+	} else if err != nil {
+		log.Error(fmt.Sprintf("Error copying response body: %v\n", err))
+	} else if log.DebugEnabled() {
+		log.Debug(fmt.Sprintf("Тело ответа успешно отправлено клиенту, размер: %d байт", written))
 	}
-}
 
-// handleRateLimit обрабатывает CRUD операции для rate limit пользователей
-func (p *Proxy) handleRateLimit(w http.ResponseWriter, r *http.Request) {
-	p.logger.Debug(fmt.Sprintf("Получен запрос к API rate limit: %s %s", r.Method, r.URL.Path))
-
-	// Извлекаем userID из URL
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 3 {
-		p.logger.Debug("Некорректный формат URL для rate limit API")
-		http.Error(w, "Invalid URL format. Use /ratelimit/{userID}", http.StatusBadRequest)
-		return
-	}
-	userID := parts[2]
-	p.logger.Debug(fmt.Sprintf("Обработка rate limit для пользователя: %s", userID))
-
-	switch r.Method {
-	case http.MethodGet:
-		p.getRateLimit(w, userID)
-	case http.MethodPost:
-		p.createRateLimit(w, r, userID)
-	case http.MethodPut:
-		p.updateRateLimit(w, r, userID)
-	case http.MethodDelete:
-		p.deleteRateLimit(w, userID)
-	default:
-		p.logger.Debug(fmt.Sprintf("Неподдерживаемый метод %s для rate limit API", r.Method))
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// Трейлеры ответа (например, grpc-status) передаются клиенту после тела
+	for k, v := range resp.Trailer {
+		w.Header()[http.TrailerPrefix+k] = v
 	}
 }
 
-// getRateLimit возвращает текущие настройки rate limit для пользователя
-func (p *Proxy) getRateLimit(w http.ResponseWriter, userID string) {
-	p.logger.Debug(fmt.Sprintf("Получение настроек rate limit для пользователя %s", userID))
-
-	limits := p.ratelimit.GetUserLimits(userID)
-	if limits == nil {
-		p.logger.Debug(fmt.Sprintf("Настройки rate limit не найдены для пользователя %s", userID))
-		http.Error(w, "User limits not found", http.StatusNotFound)
-		return
+// probeBackend возвращает бэкенд в режиме обслуживания, указанный в заголовке пробного запроса.
+// Для обычных запросов и бэкендов в ротации возвращает nil, и бэкенд выбирает балансировщик.
+func (p *Proxy) probeBackend(r *http.Request) backendpkg.Backend {
+	if p.probeHeader == "" {
+		return nil
 	}
-	p.logger.Debug(fmt.Sprintf("Найдены настройки rate limit для %s: rate=%.2f, burst=%d", userID, limits.Rate, limits.Burst))
-
-	response := UserRateLimit{
-		Rate:  limits.Rate,
-		Burst: limits.Burst,
+	id := r.Header.Get(p.probeHeader)
+	if id == "" {
+		return nil
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		p.logger.Error(fmt.Sprintf("Failed to encode response", "error", err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-	} else {
-		p.logger.Debug(fmt.Sprintf("Успешно отправлены настройки rate limit для %s", userID))
+	state := p.loadbalancer.GetBackend(id)
+	if state == nil || !state.Backend.InMaintenance() {
+		return nil
 	}
-}
 
-// createRateLimit создает новые настройки rate limit для пользователя
-func (p *Proxy) createRateLimit(w http.ResponseWriter, r *http.Request, userID string) {
-	p.logger.Debug(fmt.Sprintf("Создание новых настроек rate limit для пользователя %s", userID))
-
-	var limits UserRateLimit
-	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
-		p.logger.Debug(fmt.Sprintf("Ошибка декодирования тела запроса: %v", err))
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+	if p.logger.DebugEnabled() {
+		p.logger.Debug(fmt.Sprintf("Пробный запрос к бэкенду %s в режиме обслуживания", id))
 	}
-
-	// Валидация
-	if limits.Rate <= 0 || limits.Burst <= 0 {
-		p.logger.Debug(fmt.Sprintf("Некорректные значения rate/burst: rate=%.2f, burst=%d", limits.Rate, limits.Burst))
-		http.Error(w, "Rate and burst must be positive", http.StatusBadRequest)
-		return
-	}
-
-	// Проверяем, существуют ли уже лимиты
-	if existing := p.ratelimit.GetUserLimits(userID); existing != nil {
-		p.logger.Debug(fmt.Sprintf("Rate limit уже существует для пользователя %s", userID))
-		http.Error(w, "Rate limits already exist for this user", http.StatusConflict)
-		return
-	}
-
-	p.ratelimit.SetUserLimits(userID, limits.Rate, limits.Burst)
-	p.logger.Debug(fmt.Sprintf("Успешно созданы настройки rate limit для %s: rate=%.2f, burst=%d", userID, limits.Rate, limits.Burst))
-
-	w.WriteHeader(http.StatusCreated)
-}
-
-// updateRateLimit обновляет настройки rate limit для пользователя
-func (p *Proxy) updateRateLimit(w http.ResponseWriter, r *http.Request, userID string) {
-	p.logger.Debug(fmt.Sprintf("Обновление настроек rate limit для пользователя %s", userID))
-
-	var limits UserRateLimit
-	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
-		p.logger.Debug(fmt.Sprintf("Ошибка декодирования тела запроса: %v", err))
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// Валидация
-	if limits.Rate <= 0 || limits.Burst <= 0 {
-		p.logger.Debug(fmt.Sprintf("Некорректные значения rate/burst: rate=%.2f, burst=%d", limits.Rate, limits.Burst))
-		http.Error(w, "Rate and burst must be positive", http.StatusBadRequest)
-		return
-	}
-
-	// Проверяем существование пользователя
-	if existing := p.ratelimit.GetUserLimits(userID); existing == nil {
-		p.logger.Debug(fmt.Sprintf("Настройки rate limit не найдены для пользователя %s", userID))
-		http.Error(w, "User limits not found", http.StatusNotFound)
-		return
-	}
-
-	p.ratelimit.UpdateUserLimits(userID, func(ul *ratelimit.UserLimits) {
-		ul.Rate = limits.Rate
-		ul.Burst = limits.Burst
-	})
-	p.logger.Debug(fmt.Sprintf("Успешно обновлены настройки rate limit для %s: rate=%.2f, burst=%d", userID, limits.Rate, limits.Burst))
-
-	w.WriteHeader(http.StatusOK)
-}
-
-// deleteRateLimit удаляет настройки rate limit для пользователя
-func (p *Proxy) deleteRateLimit(w http.ResponseWriter, userID string) {
-	p.logger.Debug(fmt.Sprintf("Удаление настроек rate limit для пользователя %s", userID))
-
-	// Проверяем существование пользователя
-	if existing := p.ratelimit.GetUserLimits(userID); existing == nil {
-		p.logger.Debug(fmt.Sprintf("Настройки rate limit не найдены для пользователя %s", userID))
-		http.Error(w, "User limits not found", http.StatusNotFound)
-		return
-	}
-
-	p.ratelimit.DeleteUserLimits(userID)
-	p.logger.Debug(fmt.Sprintf("Успешно удалены настройки rate limit для пользователя %s", userID))
-
-	w.WriteHeader(http.StatusNoContent)
+	return state.Backend
 }
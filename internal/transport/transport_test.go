@@ -0,0 +1,108 @@
+package transport
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestProxy_RequestLogger(t *testing.T) {
+	var forwardedID string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedID = r.Header.Get(requestIDHeader)
+	}))
+	defer upstream.Close()
+
+	var buf bytes.Buffer
+	appLogger := logger.FromSlog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, appLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lb.AddBackend(backend.NewBackendWithOptions("b1", upstream.URL, 1, backend.Options{}))
+	proxy := NewProxy(lb, ratelimit.NewNoopRateLimiter(), Options{}, appLogger)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(requestIDHeader, "req-1")
+	proxy.ServeHTTP(httptest.NewRecorder(), req)
+	if forwardedID != "req-1" {
+		t.Errorf("бэкенд должен получить идентификатор запроса клиента, получен %q", forwardedID)
+	}
+	// Сообщение об ответе бэкенда записывается с полями запроса и выбранного бэкенда
+	var found bool
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, "Получен ответ от бэкенда") {
+			found = strings.Contains(line, "requestID=req-1") && strings.Contains(line, "client=192.0.2.1") &&
+				strings.Contains(line, "userID=192.0.2.1") && strings.Contains(line, "backend=b1")
+		}
+	}
+	if !found {
+		t.Errorf("сообщения запроса должны содержать его поля:\n%s", buf.String())
+	}
+
+	// Без заголовка идентификатор генерируется
+	proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if forwardedID == "" || forwardedID == "req-1" {
+		t.Errorf("бэкенд должен получить сгенерированный идентификатор запроса, получен %q", forwardedID)
+	}
+}
+
+func TestProxy_RecoverPanic(t *testing.T) {
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: "RoundRobin"}, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	chain := &Chain{}
+	chain.Add("panic", PhaseAuth, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/partial" {
+				w.WriteHeader(http.StatusOK)
+			}
+			panic("middleware failed")
+		})
+	})
+	appLogger := logger.FromSlog(slog.New(slog.NewTextHandler(&buf, nil)))
+	server := httptest.NewServer(NewProxy(lb, ratelimit.NewNoopRateLimiter(), Options{Middlewares: chain}, appLogger))
+	defer server.Close()
+
+	before := panicsRecovered.Value()
+	resp, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("паника должна превращаться в ответ 500, получен %d", resp.StatusCode)
+	}
+	if panicsRecovered.Value()-before != 1 {
+		t.Error("перехваченная паника должна учитываться в метрике")
+	}
+	if out := buf.String(); !strings.Contains(out, "middleware failed") || !strings.Contains(out, "requestID=") || !strings.Contains(out, "recover.go") {
+		t.Errorf("паника должна записываться в лог со стеком и полями запроса: %s", out)
+	}
+
+	// Начатый ответ обрывается, а сервер продолжает обрабатывать запросы
+	if resp, err := http.Get(server.URL + "/partial"); err == nil {
+		_, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err == nil {
+			t.Error("начатый ответ должен обрываться")
+		}
+	}
+	if resp, err := http.Get(server.URL + "/"); err != nil || resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("сервер должен продолжать обрабатывать запросы: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+}
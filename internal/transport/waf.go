@@ -0,0 +1,188 @@
+package transport
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// defaultWAFMaxBodySize объем тела запроса, проверяемый WAF, по умолчанию
+const defaultWAFMaxBodySize = 64 << 10
+
+// Сигнатуры типовых атак. Правила намеренно простые и ловят только очевидные попытки.
+var (
+	sqliSignature = regexp.MustCompile(`(?i)(\bunion\b[\s(]+(all\s+)?select\b|\bselect\b\s+[\w*,\s]+\s+\bfrom\b|\binsert\s+into\b|\bdrop\s+(table|database)\b|\bdelete\s+from\b|\b(or|and)\b\s+['"]?\w+['"]?\s*=\s*['"]?\w+['"]?\s*(--|#|$)|'\s*(--|#|;)|\b(sleep|benchmark|pg_sleep)\s*\(|/\*.*\*/)`)
+	xssSignature  = regexp.MustCompile(`(?i)(<\s*script\b|<\s*/\s*script\s*>|javascript\s*:|\bon(error|load|click|mouseover|focus|submit)\s*=|<\s*(iframe|object|embed|svg)\b|document\.(cookie|location)|\beval\s*\()`)
+)
+
+// wafHits счетчик срабатываний правил WAF
+var wafHits = metrics.Default.Counter("lb_waf_hits_total", "Requests matched by WAF rules", "rule")
+
+// wafParams параметры middleware waf
+type wafParams struct {
+	// Ограничения размера запроса (0 — без ограничения)
+	MaxHeaderCount int `yaml:"maxHeaderCount"`
+	MaxHeaderSize  int `yaml:"maxHeaderSize"`
+	MaxURLLength   int `yaml:"maxURLLength"`
+
+	// Допустимые методы (по умолчанию все)
+	AllowedMethods []string `yaml:"allowedMethods"`
+
+	// Регулярные выражения для пути и query, запросы с совпадением отклоняются
+	BlockedURLs []string `yaml:"blockedURLs"`
+
+	// Проверка сигнатур SQL инъекций и XSS в пути, query и теле запроса
+	SQLi bool `yaml:"sqli"`
+	XSS  bool `yaml:"xss"`
+
+	// Проверять тело запроса (первые maxBodySize байт, по умолчанию 64KB)
+	InspectBody bool  `yaml:"inspectBody"`
+	MaxBodySize int64 `yaml:"maxBodySize"`
+
+	// Только учитывать и логировать срабатывания, не отклоняя запросы
+	DryRun bool `yaml:"dryRun"`
+}
+
+// waf отклоняет с 403 запросы, нарушающие правила, и считает срабатывания по правилам
+type waf struct {
+	params      wafParams
+	methods     map[string]bool
+	blockedURLs []*regexp.Regexp
+	logger      logger.Logger
+}
+
+// newWAFMiddleware создает middleware фильтрации запросов
+func newWAFMiddleware(cfg config.MiddlewareConfig, appLogger logger.Logger) (Middleware, error) {
+	var params wafParams
+	if err := cfg.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+	if params.MaxHeaderCount < 0 || params.MaxHeaderSize < 0 || params.MaxURLLength < 0 || params.MaxBodySize < 0 {
+		return nil, fmt.Errorf("limits must not be negative")
+	}
+	if params.MaxBodySize == 0 {
+		params.MaxBodySize = defaultWAFMaxBodySize
+	}
+
+	f := &waf{params: params, logger: appLogger}
+	if len(params.AllowedMethods) > 0 {
+		f.methods = make(map[string]bool, len(params.AllowedMethods))
+		for _, method := range params.AllowedMethods {
+			f.methods[strings.ToUpper(method)] = true
+		}
+	}
+	for _, pattern := range params.BlockedURLs {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blockedURLs pattern %q: %w", pattern, err)
+		}
+		f.blockedURLs = append(f.blockedURLs, re)
+	}
+	return f.middleware, nil
+}
+
+func (f *waf) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, err := f.match(r)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if rule == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		wafHits.Inc(rule)
+		if f.params.DryRun {
+			f.logger.Info(fmt.Sprintf("WAF: запрос %s %s от %s нарушает правило %s (dry run)", r.Method, r.URL.Path, r.RemoteAddr, rule))
+			next.ServeHTTP(w, r)
+			return
+		}
+		f.logger.Warn(fmt.Sprintf("WAF: запрос %s %s от %s отклонен правилом %s", r.Method, r.URL.Path, r.RemoteAddr, rule))
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	})
+}
+
+// match возвращает имя первого нарушенного правила или пустую строку.
+// Прочитанная для проверки часть тела возвращается в запрос.
+func (f *waf) match(r *http.Request) (string, error) {
+	if f.methods != nil && !f.methods[r.Method] {
+		return "method", nil
+	}
+	if f.params.MaxURLLength > 0 && len(r.URL.RequestURI()) > f.params.MaxURLLength {
+		return "urlLength", nil
+	}
+	if f.params.MaxHeaderCount > 0 || f.params.MaxHeaderSize > 0 {
+		count, size := 0, 0
+		for name, values := range r.Header {
+			for _, value := range values {
+				count++
+				size += len(name) + len(value)
+			}
+		}
+		if f.params.MaxHeaderCount > 0 && count > f.params.MaxHeaderCount {
+			return "headerCount", nil
+		}
+		if f.params.MaxHeaderSize > 0 && size > f.params.MaxHeaderSize {
+			return "headerSize", nil
+		}
+	}
+
+	target := r.URL.Path
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	for _, re := range f.blockedURLs {
+		if re.MatchString(target) {
+			return "blockedURL", nil
+		}
+	}
+
+	if !f.params.SQLi && !f.params.XSS {
+		return "", nil
+	}
+
+	inputs := []string{r.URL.Path}
+	if query, err := url.QueryUnescape(r.URL.RawQuery); err == nil {
+		inputs = append(inputs, query)
+	} else {
+		inputs = append(inputs, r.URL.RawQuery)
+	}
+	if f.params.InspectBody && r.Body != nil && r.Body != http.NoBody {
+		prefix, err := io.ReadAll(io.LimitReader(r.Body, f.params.MaxBodySize))
+		if err != nil {
+			return "", err
+		}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(prefix), r.Body), r.Body}
+
+		body := string(prefix)
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			if decoded, err := url.QueryUnescape(body); err == nil {
+				body = decoded
+			}
+		}
+		inputs = append(inputs, body)
+	}
+
+	for _, input := range inputs {
+		if f.params.SQLi && sqliSignature.MatchString(input) {
+			return "sqli", nil
+		}
+		if f.params.XSS && xssSignature.MatchString(input) {
+			return "xss", nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestWAF(t *testing.T) {
+	m, err := newWAFMiddleware(config.MiddlewareConfig{Name: "waf", Params: map[string]interface{}{
+		"allowedMethods": []interface{}{"GET", "POST"},
+		"blockedURLs":    []interface{}{`^/\.git`},
+		"maxHeaderCount": 5,
+		"sqli":           true,
+		"xss":            true,
+		"inspectBody":    true,
+	}}, logger.NewNop())
+	if err != nil {
+		t.Fatalf("middleware должен создаваться: %v", err)
+	}
+	handler := m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+
+	for _, tc := range []struct {
+		method, target, body string
+		rule                 string
+	}{
+		{http.MethodGet, "/products?id=42&sort=name", "", ""},
+		{http.MethodDelete, "/products/42", "", "method"},
+		{http.MethodGet, "/.git/config", "", "blockedURL"},
+		{http.MethodGet, "/products?id=1%27%20OR%201=1--", "", "sqli"},
+		{http.MethodGet, "/search?q=1 UNION SELECT password FROM users", "", "sqli"},
+		{http.MethodGet, "/search?q=%3Cscript%3Ealert(1)%3C/script%3E", "", "xss"},
+		{http.MethodPost, "/comments", `{"text": "<img src=x onerror=alert(1)>"}`, "xss"},
+	} {
+		before := wafHits.Value(tc.rule)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, "/", strings.NewReader(tc.body))
+		req.URL, _ = req.URL.Parse(tc.target)
+		req.RequestURI = tc.target
+		handler.ServeHTTP(rec, req)
+
+		if tc.rule == "" {
+			if rec.Code != http.StatusOK {
+				t.Errorf("%s %s: обычный запрос не должен отклоняться (%d)", tc.method, tc.target, rec.Code)
+			}
+			continue
+		}
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s: ожидалось срабатывание правила %s, статус %d", tc.method, tc.target, tc.rule, rec.Code)
+		}
+		if wafHits.Value(tc.rule) != before+1 {
+			t.Errorf("%s %s: срабатывание правила %s должно учитываться в метриках", tc.method, tc.target, tc.rule)
+		}
+	}
+
+	// Проверенное тело передается бэкенду без изменений
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/comments", strings.NewReader(`{"text": "hello"}`)))
+	if rec.Body.String() != `{"text": "hello"}` {
+		t.Errorf("тело запроса должно сохраняться: %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package upgrade
+
+import "os"
+
+// Signal на этой платформе не поддерживается: передать сокет другому процессу нельзя
+var Signal os.Signal
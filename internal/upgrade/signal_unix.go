@@ -0,0 +1,11 @@
+//go:build unix
+
+package upgrade
+
+import (
+	"os"
+	"syscall"
+)
+
+// Signal сигнал, по которому процесс передает сокеты новой версии (как SIGUSR2 у nginx)
+var Signal os.Signal = syscall.SIGUSR2
@@ -0,0 +1,244 @@
+// Package upgrade передает сокеты прослушивания новому процессу при обновлении без простоя.
+//
+// Процесс, получивший сигнал обновления, запускает новую версию исполняемого файла
+// и передает ей открытые сокеты. Новый процесс продолжает принимать соединения
+// на тех же сокетах, сообщает о готовности, после чего старый процесс завершает
+// текущие запросы и выходит. Соединения, ожидающие в очереди сокета, не теряются:
+// сокет не закрывается ни на мгновение.
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Переменные окружения, через которые новый процесс получает сокеты
+const (
+	// Ключи переданных сокетов через ";" в порядке дескрипторов, начиная с 3
+	listenersEnv = "LB_UPGRADE_LISTENERS"
+
+	// Дескриптор канала, через который новый процесс сообщает о готовности
+	readyEnv = "LB_UPGRADE_READY_FD"
+)
+
+// firstExtraFD номер первого дескриптора из exec.Cmd.ExtraFiles
+const firstExtraFD = 3
+
+var (
+	mu sync.Mutex
+
+	// Сокеты, унаследованные от предыдущего процесса и еще не занятые
+	inherited map[string]*os.File
+	loadOnce  sync.Once
+
+	// Сокеты, открытые текущим процессом через Listen
+	active = make(map[string]net.Listener)
+
+	// Канал готовности (nil, если процесс запущен не обновлением)
+	ready     *os.File
+	readyOnce sync.Once
+)
+
+// arguments возвращает аргументы нового процесса: те же, с которыми запущен текущий
+var arguments = func() []string {
+	return os.Args[1:]
+}
+
+// key возвращает ключ сокета: сеть и адрес из конфигурации
+func key(network, address string) string {
+	return network + ":" + address
+}
+
+// load разбирает сокеты, переданные предыдущим процессом
+func load() {
+	inherited = make(map[string]*os.File)
+	if keys := os.Getenv(listenersEnv); keys != "" {
+		for i, k := range strings.Split(keys, ";") {
+			inherited[k] = os.NewFile(uintptr(firstExtraFD+i), k)
+		}
+	}
+	if fd, err := strconv.Atoi(os.Getenv(readyEnv)); err == nil {
+		ready = os.NewFile(uintptr(fd), "upgrade-ready")
+	}
+	os.Unsetenv(listenersEnv)
+	os.Unsetenv(readyEnv)
+}
+
+// Listen возвращает сокет, унаследованный от предыдущего процесса, или открывает новый.
+// Сокет запоминается, чтобы передать его следующему процессу при обновлении.
+func Listen(lc net.ListenConfig, network, address string) (net.Listener, error) {
+	loadOnce.Do(load)
+	k := key(network, address)
+
+	mu.Lock()
+	file := inherited[k]
+	delete(inherited, k)
+	mu.Unlock()
+
+	var listener net.Listener
+	if file != nil {
+		l, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to use inherited socket %s: %w", k, err)
+		}
+		listener = l
+	} else {
+		l, err := lc.Listen(context.Background(), network, address)
+		if err != nil {
+			return nil, err
+		}
+		listener = l
+	}
+
+	mu.Lock()
+	active[k] = listener
+	mu.Unlock()
+	return &trackedListener{Listener: listener, key: k}, nil
+}
+
+// Inherited проверяет, передан ли сокет предыдущим процессом
+func Inherited(network, address string) bool {
+	loadOnce.Do(load)
+	mu.Lock()
+	defer mu.Unlock()
+	return inherited[key(network, address)] != nil
+}
+
+// trackedListener удаляет сокет из списка передаваемых при закрытии
+type trackedListener struct {
+	net.Listener
+	key string
+}
+
+func (l *trackedListener) Close() error {
+	mu.Lock()
+	if active[l.key] == l.Listener {
+		delete(active, l.key)
+	}
+	mu.Unlock()
+	return l.Listener.Close()
+}
+
+// Ready сообщает предыдущему процессу, что новый процесс готов принимать запросы,
+// и закрывает унаследованные сокеты, которые не понадобились новой конфигурации
+func Ready() {
+	loadOnce.Do(load)
+	readyOnce.Do(func() {
+		mu.Lock()
+		for k, file := range inherited {
+			file.Close()
+			delete(inherited, k)
+		}
+		mu.Unlock()
+
+		if ready != nil {
+			ready.Write([]byte{1})
+			ready.Close()
+		}
+	})
+}
+
+// fileListener сокет, дескриптор которого можно передать другому процессу
+type fileListener interface {
+	File() (*os.File, error)
+}
+
+// Upgrade запускает новый экземпляр исполняемого файла с теми же аргументами, передает ему
+// открытые сокеты и ждет его готовности. После успешного возврата текущий процесс должен
+// завершить обработку запросов и выйти. Если новый процесс не сообщил о готовности
+// до отмены ctx или завершился, он останавливается и возвращается ошибка.
+func Upgrade(ctx context.Context) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	mu.Lock()
+	keys := make([]string, 0, len(active))
+	files := make([]*os.File, 0, len(active))
+	for k, listener := range active {
+		fl, ok := listener.(fileListener)
+		if !ok {
+			continue
+		}
+		// Сокет остается у нового процесса, поэтому старый не должен удалять его файл
+		if unixListener, ok := listener.(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(false)
+		}
+		file, err := fl.File()
+		if err != nil {
+			mu.Unlock()
+			closeFiles(files)
+			return fmt.Errorf("failed to get descriptor of socket %s: %w", k, err)
+		}
+		keys = append(keys, k)
+		files = append(files, file)
+	}
+	mu.Unlock()
+	defer closeFiles(files)
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create readiness pipe: %w", err)
+	}
+	defer readyReader.Close()
+
+	cmd := exec.Command(executable, arguments()...)
+	cmd.Env = append(os.Environ(),
+		listenersEnv+"="+strings.Join(keys, ";"),
+		readyEnv+"="+strconv.Itoa(firstExtraFD+len(files)),
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, readyWriter)
+
+	err = cmd.Start()
+	readyWriter.Close()
+	if err != nil {
+		return fmt.Errorf("failed to start new process: %w", err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		if _, err := readyReader.Read(buf); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("new process exited before becoming ready")
+			}
+			result <- err
+			return
+		}
+		result <- nil
+	}()
+
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = fmt.Errorf("new process did not become ready: %w", ctx.Err())
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	// Новый процесс продолжит работу после выхода текущего
+	cmd.Process.Release()
+	return nil
+}
+
+// closeFiles закрывает дубликаты дескрипторов в текущем процессе
+func closeFiles(files []*os.File) {
+	for _, file := range files {
+		file.Close()
+	}
+}
@@ -0,0 +1,68 @@
+package upgrade
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// helperEnv отмечает процесс теста, запущенный обновлением
+const helperEnv = "LB_UPGRADE_TEST_HELPER"
+
+func TestUpgrade(t *testing.T) {
+	if os.Getenv(helperEnv) != "" {
+		// Новый процесс: принимает соединения на унаследованном сокете
+		listener, err := Listen(net.ListenConfig{}, "tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		Ready()
+		served := make(chan struct{})
+		go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("new"))
+			close(served)
+		}))
+		select {
+		case <-served:
+			time.Sleep(100 * time.Millisecond)
+		case <-time.After(10 * time.Second):
+		}
+		return
+	}
+
+	listener, err := Listen(net.ListenConfig{}, "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+
+	// Новый процесс выполняет только этот тест один раз, независимо от -test.run и -test.count
+	// текущего запуска
+	t.Setenv(helperEnv, "1")
+	defer func(previous func() []string) { arguments = previous }(arguments)
+	arguments = func() []string {
+		return []string{"-test.run=^TestUpgrade$", "-test.count=1"}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := Upgrade(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// После закрытия сокета в старом процессе соединения принимает новый
+	listener.Close()
+	resp, err := http.Get("http://" + addr)
+	if err != nil {
+		t.Fatalf("новый процесс не принимает соединения: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "new" {
+		t.Errorf("ответ не от нового процесса: %q", body)
+	}
+}
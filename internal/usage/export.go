@@ -0,0 +1,119 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"cloud.ru_test/internal/kafka"
+)
+
+// fileExporter дописывает отчеты в файл в формате JSON, по одному в строке
+type fileExporter struct {
+	path string
+}
+
+func newFileExporter(path string) *fileExporter {
+	return &fileExporter{path: path}
+}
+
+func (e *fileExporter) export(ctx context.Context, report *Report) error {
+	line, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(e.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (e *fileExporter) close() error {
+	return nil
+}
+
+// webhookExporter отправляет отчет в теле POST запроса
+type webhookExporter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newWebhookExporter(url string, headers map[string]string) *webhookExporter {
+	return &webhookExporter{url: url, headers: headers, client: &http.Client{}}
+}
+
+func (e *webhookExporter) export(ctx context.Context, report *Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *webhookExporter) close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// tenantRecord сообщение Kafka о потреблении одного тенанта за период
+type tenantRecord struct {
+	Instance string    `json:"instance"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Usage
+}
+
+// kafkaExporter записывает потребление каждого тенанта отдельным сообщением с ключом
+// тенанта, так что сообщения одного тенанта попадают в один раздел
+type kafkaExporter struct {
+	topic    string
+	producer *kafka.Producer
+}
+
+func newKafkaExporter(brokers []string, topic string) *kafkaExporter {
+	return &kafkaExporter{topic: topic, producer: kafka.NewProducer(brokers, "lb-usage")}
+}
+
+func (e *kafkaExporter) export(ctx context.Context, report *Report) error {
+	messages := make([]kafka.Message, 0, len(report.Tenants))
+	for _, u := range report.Tenants {
+		value, err := json.Marshal(tenantRecord{Instance: report.Instance, Start: report.Start, End: report.End, Usage: u})
+		if err != nil {
+			return err
+		}
+		messages = append(messages, kafka.Message{Key: []byte(u.Tenant), Value: value})
+	}
+	return e.producer.Produce(ctx, e.topic, messages)
+}
+
+func (e *kafkaExporter) close() error {
+	return e.producer.Close()
+}
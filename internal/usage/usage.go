@@ -0,0 +1,241 @@
+// Package usage ведет учет потребления по тенантам: количество запросов, переданные
+// байты и ошибки. В отличие от rate limiter учет не ограничивает запросы, а копит
+// итоги за период и выгружает их отчетами для биллинга.
+package usage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/metrics"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Параметры учета по умолчанию
+const (
+	defaultInterval = time.Minute
+	exportTimeout   = 10 * time.Second
+
+	// Отчеты, ожидающие повторной выгрузки в одно назначение
+	maxPending = 60
+
+	// Тенант запросов без пользователя и заголовка тенанта
+	Anonymous = "anonymous"
+)
+
+// exports счетчик выгрузок отчетов по типам назначений и результатам
+var exports = metrics.Default.Counter("lb_usage_exports_total", "Usage report exports by destination type and result", "type", "result")
+
+// Usage потребление тенанта за период
+type Usage struct {
+	Tenant       string `json:"tenant"`
+	Requests     int64  `json:"requests"`
+	BytesIn      int64  `json:"bytesIn"`
+	BytesOut     int64  `json:"bytesOut"`
+	ClientErrors int64  `json:"clientErrors"`
+	ServerErrors int64  `json:"serverErrors"`
+}
+
+// Report итоги потребления всех тенантов за период
+type Report struct {
+	// Процесс, выгрузивший отчет: в режиме нескольких процессов каждый выгружает свои итоги
+	Instance string    `json:"instance"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Tenants  []Usage   `json:"tenants"`
+}
+
+// exporter выгружает отчет в конкретное назначение
+type exporter interface {
+	export(ctx context.Context, report *Report) error
+	close() error
+}
+
+// destination назначение отчетов с очередью отчетов, которые не удалось выгрузить
+type destination struct {
+	typ      string
+	exporter exporter
+	pending  []*Report
+}
+
+// Accountant копит потребление тенантов и раз в период выгружает отчет во все назначения.
+// Отчет, который не удалось выгрузить, повторяется в следующий период (до maxPending
+// отчетов на назначение), поэтому при сбоях назначение может получить отчет повторно.
+type Accountant struct {
+	interval     time.Duration
+	tenantHeader string
+	instance     string
+	destinations []*destination
+	logger       logger.Logger
+
+	mu      sync.Mutex
+	start   time.Time
+	tenants map[string]*Usage
+
+	started atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// New создает учет потребления по настройкам cfg; instance идентифицирует процесс в отчетах
+func New(cfg *config.UsageConfig, instance string, appLogger logger.Logger) (*Accountant, error) {
+	a := &Accountant{
+		interval:     cfg.Interval,
+		tenantHeader: cfg.TenantHeader,
+		instance:     instance,
+		logger:       appLogger,
+		start:        time.Now(),
+		tenants:      make(map[string]*Usage),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	if a.interval == 0 {
+		a.interval = defaultInterval
+	}
+	if a.instance == "" {
+		hostname, _ := os.Hostname()
+		a.instance = hostname + "-" + strconv.Itoa(os.Getpid())
+	}
+
+	for _, e := range cfg.Exports {
+		var exp exporter
+		switch e.Type {
+		case "file":
+			exp = newFileExporter(e.Path)
+		case "webhook":
+			exp = newWebhookExporter(e.URL, e.Headers)
+		case "kafka":
+			exp = newKafkaExporter(e.Brokers, e.Topic)
+		default:
+			a.closeExporters()
+			return nil, fmt.Errorf("usage: unsupported export type %q", e.Type)
+		}
+		a.destinations = append(a.destinations, &destination{typ: e.Type, exporter: exp})
+	}
+	return a, nil
+}
+
+// TenantHeader возвращает заголовок запроса с идентификатором тенанта или пустую строку
+func (a *Accountant) TenantHeader() string {
+	return a.tenantHeader
+}
+
+// Record учитывает обработанный запрос тенанта
+func (a *Accountant) Record(tenant string, bytesIn, bytesOut int64, status int) {
+	if tenant == "" {
+		tenant = Anonymous
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	u, ok := a.tenants[tenant]
+	if !ok {
+		u = &Usage{Tenant: tenant}
+		a.tenants[tenant] = u
+	}
+	u.Requests++
+	u.BytesIn += bytesIn
+	u.BytesOut += bytesOut
+	switch {
+	case status >= 500:
+		u.ServerErrors++
+	case status >= 400:
+		u.ClientErrors++
+	}
+}
+
+// Start запускает периодическую выгрузку отчетов
+func (a *Accountant) Start() {
+	if a.started.CompareAndSwap(false, true) {
+		go a.run()
+	}
+}
+
+// Stop выгружает итоги текущего периода и останавливает учет. Незапущенный учет
+// только закрывает назначения отчетов.
+func (a *Accountant) Stop() {
+	a.once.Do(func() {
+		close(a.stop)
+		if a.started.CompareAndSwap(false, true) {
+			a.closeExporters()
+			close(a.done)
+		}
+	})
+	<-a.done
+}
+
+func (a *Accountant) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.flush()
+		case <-a.stop:
+			a.flush()
+			a.closeExporters()
+			return
+		}
+	}
+}
+
+// closeExporters закрывает назначения отчетов
+func (a *Accountant) closeExporters() {
+	for _, d := range a.destinations {
+		d.exporter.close()
+	}
+}
+
+// report завершает текущий период и возвращает его итоги
+func (a *Accountant) report() *Report {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	report := &Report{Instance: a.instance, Start: a.start, End: now, Tenants: make([]Usage, 0, len(a.tenants))}
+	for _, u := range a.tenants {
+		report.Tenants = append(report.Tenants, *u)
+	}
+	sort.Slice(report.Tenants, func(i, j int) bool { return report.Tenants[i].Tenant < report.Tenants[j].Tenant })
+
+	a.start = now
+	a.tenants = make(map[string]*Usage)
+	return report
+}
+
+// flush выгружает отчет за период и ранее не выгруженные отчеты. Периоды без запросов
+// не выгружаются.
+func (a *Accountant) flush() {
+	report := a.report()
+	for _, d := range a.destinations {
+		if len(report.Tenants) > 0 {
+			d.pending = append(d.pending, report)
+		}
+		if len(d.pending) > maxPending {
+			a.logger.Warn(fmt.Sprintf("Отчеты о потреблении за %d периодов не выгружены в %s и отброшены", len(d.pending)-maxPending, d.typ))
+			d.pending = d.pending[len(d.pending)-maxPending:]
+		}
+
+		for len(d.pending) > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+			err := d.exporter.export(ctx, d.pending[0])
+			cancel()
+			if err != nil {
+				exports.Inc(d.typ, "error")
+				a.logger.Warn(fmt.Sprintf("Не удалось выгрузить отчет о потреблении в %s, повтор в следующий период: %v", d.typ, err))
+				break
+			}
+			exports.Inc(d.typ, "success")
+			d.pending = d.pending[1:]
+		}
+	}
+}
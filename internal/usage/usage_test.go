@@ -0,0 +1,122 @@
+package usage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+func TestAccountant(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+
+	// Первая выгрузка в webhook завершается ошибкой, отчет повторяется в следующий период
+	var calls atomic.Int32
+	received := make(chan Report, 2)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var report Report
+		json.NewDecoder(r.Body).Decode(&report)
+		received <- report
+	}))
+	defer webhook.Close()
+
+	a, err := New(&config.UsageConfig{Exports: []config.UsageExportConfig{
+		{Type: "file", Path: path},
+		{Type: "webhook", URL: webhook.URL},
+	}}, "test", logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.Record("alice", 10, 100, http.StatusOK)
+	a.Record("alice", 5, 50, http.StatusInternalServerError)
+	a.Record("", 0, 20, http.StatusNotFound)
+	a.flush()
+	a.Record("bob", 1, 2, http.StatusOK)
+	a.flush()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var reports []Report
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var report Report
+		if err := json.Unmarshal(scanner.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		reports = append(reports, report)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("ожидалось 2 отчета в файле, получено %d", len(reports))
+	}
+	first := reports[0]
+	if first.Instance != "test" || len(first.Tenants) != 2 {
+		t.Fatalf("неверный отчет: %+v", first)
+	}
+	want := Usage{Tenant: "alice", Requests: 2, BytesIn: 15, BytesOut: 150, ServerErrors: 1}
+	if first.Tenants[0] != want {
+		t.Errorf("неверное потребление alice: %+v", first.Tenants[0])
+	}
+	if first.Tenants[1].Tenant != Anonymous || first.Tenants[1].ClientErrors != 1 {
+		t.Errorf("запросы без тенанта должны учитываться как %s: %+v", Anonymous, first.Tenants[1])
+	}
+
+	// Webhook получает оба отчета по порядку: неудачный повторяется перед следующим
+	if got := (<-received).Tenants[0].Tenant; got != "alice" {
+		t.Errorf("первым должен быть повторно выгружен отчет с alice, получен %s", got)
+	}
+	if got := (<-received).Tenants[0].Tenant; got != "bob" {
+		t.Errorf("вторым должен быть выгружен отчет с bob, получен %s", got)
+	}
+}
+
+// closeExporter назначение, отмечающее свое закрытие
+type closeExporter struct {
+	closed atomic.Bool
+}
+
+func (e *closeExporter) export(context.Context, *Report) error { return nil }
+
+func (e *closeExporter) close() error {
+	e.closed.Store(true)
+	return nil
+}
+
+func TestAccountant_StopWithoutStart(t *testing.T) {
+	a, err := New(&config.UsageConfig{}, "test", logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := &closeExporter{}
+	a.destinations = append(a.destinations, &destination{typ: "test", exporter: exp})
+
+	stopped := make(chan struct{})
+	go func() {
+		a.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("остановка незапущенного учета не должна блокироваться")
+	}
+	if !exp.closed.Load() {
+		t.Error("остановка незапущенного учета должна закрывать назначения отчетов")
+	}
+}
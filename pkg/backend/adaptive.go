@@ -0,0 +1,156 @@
+package backend
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"cloud.ru_test/config"
+)
+
+// Значения параметров адаптивного лимита по умолчанию
+const (
+	defaultInitialLimit     = 20
+	defaultMinLimit         = 1
+	defaultMaxLimit         = 1000
+	defaultTolerance        = 1.5
+	defaultSmoothing        = 0.2
+	defaultLatencyThreshold = time.Second
+	defaultBackoffRatio     = 0.9
+
+	// Число ответов, по которым считается кратковременная задержка (gradient)
+	gradientSampleSize = 20
+
+	// Число выборок в окне долговременной (базовой) задержки (gradient)
+	gradientLongWindow = 30
+)
+
+// concurrencyLimit адаптивный лимит одновременных запросов к бэкенду.
+//
+// Алгоритм gradient сравнивает среднюю задержку последних ответов с долговременной:
+// пока задержка не растет, лимит увеличивается на величину допустимой очереди (√limit),
+// а при росте задержки уменьшается пропорционально ее росту (не более чем вдвое за шаг).
+// Алгоритм aimd увеличивает лимит на 1 после каждого быстрого ответа и умножает
+// на backoffRatio после медленного ответа, ошибки, 429 или 503.
+type concurrencyLimit struct {
+	aimd             bool
+	minLimit         float64
+	maxLimit         float64
+	tolerance        float64
+	smoothing        float64
+	latencyThreshold time.Duration
+	backoffRatio     float64
+
+	mu    sync.Mutex
+	limit float64
+
+	// Долговременная задержка и текущая выборка ответов (gradient)
+	longRTT     float64
+	sampleSum   float64
+	sampleCount int
+	maxInFlight int64
+	dropped     bool
+}
+
+// newConcurrencyLimit создает адаптивный лимит. Незаданные параметры заменяются значениями по умолчанию.
+func newConcurrencyLimit(cfg *config.AdaptiveConcurrencyConfig) *concurrencyLimit {
+	l := &concurrencyLimit{
+		aimd:             cfg.Algorithm == "aimd",
+		minLimit:         defaultMinLimit,
+		maxLimit:         defaultMaxLimit,
+		tolerance:        defaultTolerance,
+		smoothing:        defaultSmoothing,
+		latencyThreshold: defaultLatencyThreshold,
+		backoffRatio:     defaultBackoffRatio,
+		limit:            defaultInitialLimit,
+	}
+	if cfg.MinLimit > 0 {
+		l.minLimit = float64(cfg.MinLimit)
+	}
+	if cfg.MaxLimit > 0 {
+		l.maxLimit = float64(cfg.MaxLimit)
+	}
+	if cfg.InitialLimit > 0 {
+		l.limit = float64(cfg.InitialLimit)
+	}
+	if cfg.Tolerance > 0 {
+		l.tolerance = cfg.Tolerance
+	}
+	if cfg.Smoothing > 0 {
+		l.smoothing = cfg.Smoothing
+	}
+	if cfg.LatencyThreshold > 0 {
+		l.latencyThreshold = cfg.LatencyThreshold
+	}
+	if cfg.BackoffRatio > 0 {
+		l.backoffRatio = cfg.BackoffRatio
+	}
+	l.limit = l.clamp(l.limit)
+	return l
+}
+
+// Limit возвращает текущий лимит
+func (l *concurrencyLimit) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// observe учитывает ответ бэкенда: задержку rtt, число запросов к бэкенду в момент
+// отправки inFlight и признак перегрузки dropped (ошибка соединения, 429 или 503)
+func (l *concurrencyLimit) observe(rtt time.Duration, inFlight int64, dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.aimd {
+		switch {
+		case dropped || rtt > l.latencyThreshold:
+			l.limit = l.clamp(l.limit * l.backoffRatio)
+		case float64(inFlight)*2 >= l.limit:
+			// Лимит растет, только если он действительно используется
+			l.limit = l.clamp(l.limit + 1)
+		}
+		return
+	}
+
+	l.sampleSum += float64(rtt)
+	l.sampleCount++
+	l.dropped = l.dropped || dropped
+	if inFlight > l.maxInFlight {
+		l.maxInFlight = inFlight
+	}
+	if l.sampleCount < gradientSampleSize {
+		return
+	}
+
+	shortRTT := l.sampleSum / float64(l.sampleCount)
+	maxInFlight, dropped := l.maxInFlight, l.dropped
+	l.sampleSum, l.sampleCount, l.maxInFlight, l.dropped = 0, 0, 0, false
+
+	if l.longRTT == 0 {
+		l.longRTT = shortRTT
+	} else {
+		l.longRTT += (shortRTT - l.longRTT) / gradientLongWindow
+	}
+	// После перегрузки задержка возвращается к норме, и базовая задержка снижается быстрее
+	if l.longRTT/shortRTT > 2 {
+		l.longRTT *= 0.95
+	}
+
+	// Лимит не растет, пока используется меньше его половины
+	if !dropped && float64(maxInFlight) < l.limit/2 {
+		return
+	}
+
+	gradient := 0.5
+	if !dropped {
+		gradient = math.Max(0.5, math.Min(1, l.tolerance*l.longRTT/shortRTT))
+	}
+	newLimit := l.limit*gradient + math.Sqrt(l.limit)
+	l.limit = l.clamp(l.limit*(1-l.smoothing) + newLimit*l.smoothing)
+}
+
+// clamp ограничивает лимит границами minLimit и maxLimit
+func (l *concurrencyLimit) clamp(limit float64) float64 {
+	return math.Max(l.minLimit, math.Min(l.maxLimit, limit))
+}
@@ -0,0 +1,74 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+)
+
+func TestGradientConcurrencyLimit(t *testing.T) {
+	l := newConcurrencyLimit(&config.AdaptiveConcurrencyConfig{InitialLimit: 20, MaxLimit: 100})
+
+	// Задержка стабильна и лимит используется: лимит растет
+	for i := 0; i < 10*gradientSampleSize; i++ {
+		l.observe(10*time.Millisecond, int64(l.Limit()), false)
+	}
+	grown := l.Limit()
+	if grown <= 20 {
+		t.Fatalf("при стабильной задержке лимит должен расти: %d", grown)
+	}
+
+	// Лимит используется меньше чем наполовину: не растет
+	for i := 0; i < 5*gradientSampleSize; i++ {
+		l.observe(10*time.Millisecond, 1, false)
+	}
+	if l.Limit() != grown {
+		t.Errorf("неиспользуемый лимит не должен расти: %d -> %d", grown, l.Limit())
+	}
+
+	// Задержка выросла в несколько раз: лимит снижается
+	for i := 0; i < 10*gradientSampleSize; i++ {
+		l.observe(100*time.Millisecond, int64(l.Limit()), false)
+	}
+	if l.Limit() >= grown {
+		t.Errorf("при росте задержки лимит должен снижаться: %d -> %d", grown, l.Limit())
+	}
+}
+
+func TestAIMDConcurrencyLimit(t *testing.T) {
+	l := newConcurrencyLimit(&config.AdaptiveConcurrencyConfig{
+		Algorithm:        "aimd",
+		InitialLimit:     10,
+		MinLimit:         5,
+		LatencyThreshold: 50 * time.Millisecond,
+		BackoffRatio:     0.5,
+	})
+
+	l.observe(10*time.Millisecond, 8, false)
+	if l.Limit() != 11 {
+		t.Errorf("быстрый ответ должен увеличивать лимит на 1: %d", l.Limit())
+	}
+	l.observe(100*time.Millisecond, 8, false)
+	if l.Limit() != 5 {
+		t.Errorf("медленный ответ должен уменьшать лимит: %d", l.Limit())
+	}
+	l.observe(10*time.Millisecond, 1, true)
+	if l.Limit() != 5 {
+		t.Errorf("лимит не должен опускаться ниже minLimit: %d", l.Limit())
+	}
+}
+
+func TestAdaptiveMaxConnections(t *testing.T) {
+	b := NewBackendWithOptions("b1", "http://127.0.0.1:1", 1, Options{
+		MaxConnections:      8,
+		AdaptiveConcurrency: &config.AdaptiveConcurrencyConfig{InitialLimit: 20},
+	})
+	defer b.Close()
+	if b.MaxConnections() != 8 {
+		t.Errorf("статический лимит ниже адаптивного должен действовать: %d", b.MaxConnections())
+	}
+	if b.GetLoadStats().ConcurrencyLimit != 20 {
+		t.Errorf("адаптивный лимит должен отображаться в статистике: %d", b.GetLoadStats().ConcurrencyLimit)
+	}
+}
@@ -2,7 +2,12 @@ package backend
 
 import (
 	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -21,8 +26,51 @@ type LoadStats struct {
 	// Количество запросов в секунду
 	RequestsPerSecond float64
 
-	// Процент успешных запросов
+	// Доля успешных ответов (2xx и 3xx) за последнюю минуту; 1, если запросов не было
 	SuccessRate float64
+
+	// Ответы по классам статусов и ошибки соединения за последнюю минуту
+	Responses ResponseCounts
+
+	// Текущий адаптивный лимит одновременных запросов (0 — адаптивный лимит отключен)
+	ConcurrencyLimit int
+}
+
+// ResponseCounts количество ответов бэкенда по классам HTTP статусов
+type ResponseCounts struct {
+	Status2xx int64
+	Status3xx int64
+	Status4xx int64
+	Status5xx int64
+
+	// Запросы, завершившиеся ошибкой соединения или таймаутом
+	Errors int64
+
+	// Запросы, прерванные из-за отключения клиента до получения ответа.
+	// В Total не входят: они ничего не говорят о состоянии бэкенда.
+	Canceled int64
+}
+
+// Total возвращает общее количество запросов, завершившихся ответом или ошибкой бэкенда
+func (c ResponseCounts) Total() int64 {
+	return c.Status2xx + c.Status3xx + c.Status4xx + c.Status5xx + c.Errors
+}
+
+// Successful возвращает количество успешных ответов (2xx и 3xx)
+func (c ResponseCounts) Successful() int64 {
+	return c.Status2xx + c.Status3xx
+}
+
+// HealthMetadata сведения, которые бэкенд сообщает о себе в ответе на проверку здоровья
+type HealthMetadata struct {
+	// Загрузка по оценке самого бэкенда (например, доля занятых воркеров); nil — не сообщается
+	Load *float64
+
+	// Версия приложения бэкенда
+	Version string
+
+	// Бэкенд завершает работу: новые запросы ему не направляются, текущие завершаются
+	Draining bool
 }
 
 // Backend представляет интерфейс для взаимодействия с бэкендом
@@ -33,6 +81,9 @@ type Backend interface {
 	// URL возвращает полный URL бэкенда
 	URL() string
 
+	// Zone возвращает зону доступности бэкенда (пустая строка, если неизвестна)
+	Zone() string
+
 	// Weight возвращает текущий вес бэкенда
 	Weight() float64
 
@@ -42,33 +93,156 @@ type Backend interface {
 	// IsAlive проверяет, доступен ли бэкенд
 	IsAlive() bool
 
+	// SetAlive устанавливает состояние доступности бэкенда (по результатам health check)
+	SetAlive(alive bool)
+
+	// InMaintenance проверяет, выведен ли бэкенд на обслуживание
+	InMaintenance() bool
+
+	// SetMaintenance включает или выключает режим обслуживания
+	SetMaintenance(maintenance bool)
+
+	// IsEjected проверяет, исключен ли бэкенд из ротации обнаружением выбросов
+	IsEjected() bool
+
+	// SetEjected исключает бэкенд из ротации или возвращает его
+	SetEjected(ejected bool)
+
+	// HealthMetadata возвращает сведения из последней успешной проверки здоровья
+	HealthMetadata() HealthMetadata
+
+	// SetHealthMetadata сохраняет сведения из ответа на проверку здоровья
+	SetHealthMetadata(metadata HealthMetadata)
+
+	// IsDraining проверяет, сообщил ли бэкенд о завершении работы
+	IsDraining() bool
+
+	// MaxConnections возвращает лимит одновременных соединений с учетом адаптивного лимита (0 — без ограничения)
+	MaxConnections() int
+
+	// ActiveConnections возвращает текущее количество активных соединений. В отличие
+	// от GetLoadStats не копирует статистику и не блокирует: вызывается при выборе бэкенда.
+	ActiveConnections() int64
+
 	// GetLoadStats возвращает текущую статистику загруженности
 	GetLoadStats() LoadStats
 
-	// Handle обрабатывает входящий запрос
+	// Handle отправляет запрос бэкенду. URL запроса уже должен указывать на бэкенд
+	// (его формирует прокси); тело ответа необходимо закрыть.
 	Handle(ctx context.Context, req *http.Request) (*http.Response, error)
+
+	// DialTunnel открывает соединение с бэкендом для передачи данных без разбора протокола;
+	// соединение занимает место в лимите MaxConnections до своего закрытия
+	DialTunnel(ctx context.Context, port string) (net.Conn, error)
+
+	// Close останавливает фоновое обновление статистики и закрывает простаивающие
+	// соединения. Текущие запросы завершаются; повторный вызов ничего не делает.
+	Close() error
 }
 
-// BaseBackend базовая реализация бэкенда
+// BaseBackend реализация бэкенда, создаваемая из конфигурации (NewFromConfig)
+// или с явными параметрами подключения (NewBackendWithOptions)
 type BaseBackend struct {
-	id       string
-	url      string
-	weight   float64
-	isAlive  bool
-	stats    LoadStats
-	client   *http.Client
-	statsMux sync.RWMutex
+	id          string
+	url         string
+	zone        string
+	weight      atomic.Uint64 // math.Float64bits веса
+	isAlive     atomic.Bool
+	maintenance atomic.Bool
+	ejected     atomic.Bool
+	metadata    atomic.Pointer[HealthMetadata]
+	stats       LoadStats
+	client      *http.Client
+	statsMux    sync.RWMutex
+
+	// Установка соединений с бэкендом: с таймаутом подключения и через resolver
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// Повторное разрешение имени хоста бэкенда (nil, если в URL указан IP)
+	resolver *resolver
+
+	// Лимит одновременных соединений (0 — без ограничения)
+	maxConnections int
+
+	// Адаптивный лимит одновременных запросов (nil — отключен)
+	concurrency *concurrencyLimit
+
+	// Заголовок Host и дополнительные заголовки запросов к бэкенду
+	host    string
+	headers http.Header
+
+	// Протокол запросов и способ проверки здоровья из конфигурации
+	protocol    string
+	healthCheck *config.BackendHealthCheckConfig
 
 	// Окно для подсчета статистики (1 минута)
 	requestTimes    []time.Duration // Времена ответов
 	requestTimesIdx int             // Индекс для циклического буфера
 	timesMux        sync.RWMutex
 
+	// Текущее количество активных соединений
+	activeConnections atomic.Int64
+
 	// Счетчики для подсчета RPS
-	requestCount    atomic.Int64
-	lastCountReset  time.Time
-	successCount    atomic.Int64
-	lastSuccessTime time.Time
+	requestCount   atomic.Int64
+	lastCountReset time.Time
+
+	// Ответы по классам статусов за последнюю минуту
+	responses responseWindow
+
+	// Повторный Close ничего не делает
+	closeOnce sync.Once
+}
+
+// Значения по умолчанию для параметров подключения к бэкенду
+const (
+	DefaultConnectTimeout = 5 * time.Second
+	DefaultReadTimeout    = 10 * time.Second
+)
+
+// Options параметры подключения к бэкенду
+type Options struct {
+	// Таймаут установки TCP соединения
+	ConnectTimeout time.Duration
+
+	// Таймаут ожидания заголовков ответа после отправки запроса
+	ReadTimeout time.Duration
+
+	// Максимальное количество одновременных соединений (0 — без ограничения).
+	// Запросы сверх лимита отклоняются с ErrMaxConnections.
+	MaxConnections int
+
+	// Значение заголовка Host в запросах к бэкенду (пустое — без изменений)
+	Host string
+
+	// Заголовки, добавляемые к каждому запросу к бэкенду
+	Headers map[string]string
+
+	// Интервал повторного разрешения имени хоста бэкенда (по умолчанию DefaultDNSRefreshInterval)
+	DNSRefreshInterval time.Duration
+
+	// Адаптивный лимит одновременных запросов (nil — отключен). Запросы сверх лимита
+	// отклоняются с ErrMaxConnections, как и при исчерпании MaxConnections.
+	AdaptiveConcurrency *config.AdaptiveConcurrencyConfig
+
+	// Протокол запросов: http (по умолчанию) или h2c — HTTP/2 без TLS для gRPC бэкендов
+	Protocol string
+
+	// Способ проверки здоровья (nil — HTTP проверка по умолчанию)
+	HealthCheck *config.BackendHealthCheckConfig
+
+	// Число сессий TLS, сохраняемых для возобновления (0 — DefaultTLSSessionCacheSize,
+	// отрицательное — сессии не сохраняются)
+	TLSSessionCacheSize int
+}
+
+// ErrMaxConnections возвращается Handle, если у бэкенда исчерпан лимит соединений
+var ErrMaxConnections = errors.New("backend connection limit reached")
+
+// IsSaturated проверяет, исчерпан ли у бэкенда лимит одновременных соединений
+func IsSaturated(b Backend) bool {
+	limit := b.MaxConnections()
+	return limit > 0 && b.ActiveConnections() >= int64(limit)
 }
 
 // NewFromConfig создает новый бэкенд из конфигурации
@@ -77,29 +251,118 @@ func NewFromConfig(cfg config.BackendConfig) Backend {
 	if cfg.Weight != nil {
 		weight = *cfg.Weight
 	}
-	return NewBackend(cfg.ID, cfg.URL, weight)
+	opts := Options{
+		ConnectTimeout: cfg.ConnectTimeout,
+		ReadTimeout:    cfg.ReadTimeout,
+		MaxConnections: cfg.MaxConnections,
+		Host:           cfg.Host,
+		Headers:        cfg.Headers,
+
+		DNSRefreshInterval:  cfg.DNSRefreshInterval,
+		AdaptiveConcurrency: cfg.AdaptiveConcurrency,
+		Protocol:            cfg.Protocol,
+		HealthCheck:         cfg.HealthCheck,
+	}
+	if cfg.TLS != nil {
+		opts.TLSSessionCacheSize = cfg.TLS.SessionCacheSize
+	}
+	b := NewBackendWithOptions(cfg.ID, cfg.URL, weight, opts)
+	b.SetMaintenance(cfg.Maintenance)
+	b.zone = cfg.Zone
+	return b
 }
 
-// NewBackend создает новый бэкенд
+// NewBackend создает новый бэкенд с параметрами подключения по умолчанию
 func NewBackend(id, url string, weight float64) *BaseBackend {
+	return NewBackendWithOptions(id, url, weight, Options{})
+}
+
+// NewBackendWithOptions создает новый бэкенд. Незаданные таймауты заменяются значениями по умолчанию.
+func NewBackendWithOptions(id, url string, weight float64, opts Options) *BaseBackend {
+	client, resolver := newHTTPClient(id, url, opts)
 	b := &BaseBackend{
-		id:      id,
-		url:     url,
-		weight:  weight,
-		isAlive: true,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		id:             id,
+		url:            url,
+		client:         client,
+		dial:           client.Transport.(*http.Transport).DialContext,
+		resolver:       resolver,
+		maxConnections: opts.MaxConnections,
+		host:           opts.Host,
+		headers:        make(http.Header, len(opts.Headers)),
+		protocol:       opts.Protocol,
+		healthCheck:    opts.HealthCheck,
 		requestTimes:   make([]time.Duration, 60), // Храним историю за минуту
 		lastCountReset: time.Now(),
 	}
+	if opts.AdaptiveConcurrency != nil {
+		b.concurrency = newConcurrencyLimit(opts.AdaptiveConcurrency)
+	}
+	b.weight.Store(math.Float64bits(weight))
+	b.isAlive.Store(true)
+	b.stats.SuccessRate = 1
+	for name, value := range opts.Headers {
+		b.headers.Set(name, value)
+	}
 
-	// Запускаем обновление статистики
-	go b.updateStats()
+	// Статистика обновляется общим для всех бэкендов агрегатором
+	aggregator.add(b)
 
 	return b
 }
 
+// newHTTPClient создает HTTP клиент с таймаутами и ограничением соединений бэкенда.
+// Общий таймаут клиента не задается, чтобы не обрывать передачу длинных ответов.
+// Если хост бэкенда задан именем, соединения устанавливаются через resolver,
+// который периодически разрешает имя заново. Бэкенд с URL unix:// подключается к сокету.
+// Новые соединения и TLS рукопожатия учитываются в метриках бэкенда id.
+func newHTTPClient(id, rawURL string, opts Options) (*http.Client, *resolver) {
+	connectTimeout := opts.ConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = DefaultConnectTimeout
+	}
+	readTimeout := opts.ReadTimeout
+	if readTimeout <= 0 {
+		readTimeout = DefaultReadTimeout
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	var transport *http.Transport
+	if socket := SocketPath(rawURL); socket != "" {
+		transport = NewUnixTransport(socket, dialer)
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = dialer.DialContext
+	}
+	transport.ResponseHeaderTimeout = readTimeout
+	if opts.Protocol == "h2c" {
+		// Ответы gRPC содержат трейлеры, поэтому бэкенду нужен HTTP/2 и без TLS
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+	if opts.MaxConnections > 0 {
+		transport.MaxConnsPerHost = opts.MaxConnections
+		transport.MaxIdleConnsPerHost = opts.MaxConnections
+	}
+
+	var r *resolver
+	if parsed, err := url.Parse(rawURL); err == nil {
+		r = newResolver(parsed.Hostname(), opts.DNSRefreshInterval, dialer)
+	}
+	if r != nil {
+		// Соединения со старыми адресами больше не переиспользуются
+		r.onChange = transport.CloseIdleConnections
+		transport.DialContext = r.DialContext
+	}
+	transport.DialContext = countConnections(id, transport.DialContext)
+	transport.TLSClientConfig = upstreamTLSConfig(id, opts.TLSSessionCacheSize)
+
+	return &http.Client{Transport: transport}, r
+}
+
 func (b *BaseBackend) ID() string {
 	return b.id
 }
@@ -108,42 +371,198 @@ func (b *BaseBackend) URL() string {
 	return b.url
 }
 
+func (b *BaseBackend) Zone() string {
+	return b.zone
+}
+
+// Protocol возвращает протокол запросов к бэкенду: http или h2c
+func (b *BaseBackend) Protocol() string {
+	if b.protocol == "" {
+		return "http"
+	}
+	return b.protocol
+}
+
+// HealthCheck возвращает способ проверки здоровья бэкенда (nil — по умолчанию)
+func (b *BaseBackend) HealthCheck() *config.BackendHealthCheckConfig {
+	return b.healthCheck
+}
+
 func (b *BaseBackend) Weight() float64 {
-	return b.weight
+	return math.Float64frombits(b.weight.Load())
 }
 
 func (b *BaseBackend) SetWeight(weight float64) {
-	b.weight = weight
+	b.weight.Store(math.Float64bits(weight))
 }
 
 func (b *BaseBackend) IsAlive() bool {
-	return b.isAlive
+	return b.isAlive.Load()
+}
+
+func (b *BaseBackend) SetAlive(alive bool) {
+	b.isAlive.Store(alive)
+}
+
+func (b *BaseBackend) InMaintenance() bool {
+	return b.maintenance.Load()
+}
+
+func (b *BaseBackend) SetMaintenance(maintenance bool) {
+	b.maintenance.Store(maintenance)
+}
+
+func (b *BaseBackend) IsEjected() bool {
+	return b.ejected.Load()
+}
+
+func (b *BaseBackend) SetEjected(ejected bool) {
+	b.ejected.Store(ejected)
+}
+
+func (b *BaseBackend) HealthMetadata() HealthMetadata {
+	if m := b.metadata.Load(); m != nil {
+		return *m
+	}
+	return HealthMetadata{}
+}
+
+func (b *BaseBackend) SetHealthMetadata(metadata HealthMetadata) {
+	b.metadata.Store(&metadata)
+}
+
+func (b *BaseBackend) IsDraining() bool {
+	m := b.metadata.Load()
+	return m != nil && m.Draining
+}
+
+func (b *BaseBackend) MaxConnections() int {
+	if b.concurrency == nil {
+		return b.maxConnections
+	}
+	limit := b.concurrency.Limit()
+	if b.maxConnections > 0 && b.maxConnections < limit {
+		return b.maxConnections
+	}
+	return limit
+}
+
+func (b *BaseBackend) ActiveConnections() int64 {
+	return b.activeConnections.Load()
 }
 
 func (b *BaseBackend) GetLoadStats() LoadStats {
 	b.statsMux.RLock()
-	defer b.statsMux.RUnlock()
-	return b.stats
+	stats := b.stats
+	b.statsMux.RUnlock()
+
+	stats.ActiveConnections = b.activeConnections.Load()
+	if b.concurrency != nil {
+		stats.ConcurrencyLimit = b.concurrency.Limit()
+	}
+	return stats
 }
 
+// Close останавливает обновление статистики и закрывает простаивающие соединения с бэкендом.
+// Запросы, отправленные до закрытия, завершаются как обычно.
+func (b *BaseBackend) Close() error {
+	b.closeOnce.Do(func() {
+		aggregator.remove(b)
+		b.client.CloseIdleConnections()
+	})
+	return nil
+}
+
+// Handle отправляет запрос бэкенду, предварительно применив к нему заголовки бэкенда и Host.
+// Соединение считается активным до закрытия тела ответа, поэтому длинные ответы
+// учитываются в лимите MaxConnections.
 func (b *BaseBackend) Handle(ctx context.Context, req *http.Request) (*http.Response, error) {
-	start := time.Now()
+	// Занимаем соединение; при исчерпанном лимите запрос отклоняется
+	active, ok := b.acquire()
+	if !ok {
+		return nil, ErrMaxConnections
+	}
+
+	if b.resolver != nil {
+		b.resolver.maybeRefresh()
+	}
 
-	// Увеличиваем счетчик активных соединений
-	atomic.AddInt64(&b.stats.ActiveConnections, 1)
-	defer atomic.AddInt64(&b.stats.ActiveConnections, -1)
+	for name, values := range b.headers {
+		req.Header[name] = values
+	}
+	if b.host != "" {
+		req.Host = b.host
+	}
+
+	start := time.Now()
 
 	// Отправляем запрос напрямую, так как URL уже сформирован в transport
 	resp, err := b.client.Do(req)
 
 	// Обновляем статистику
 	duration := time.Since(start)
-	b.updateRequestStats(duration, err == nil)
+	statusCode := 0
+	if err == nil {
+		statusCode = resp.StatusCode
+	} else if errors.Is(ctx.Err(), context.Canceled) {
+		// Клиент отключился: запрос к бэкенду прерван вместе с контекстом
+		statusCode = statusCanceled
+	}
+	b.updateRequestStats(duration, statusCode)
+
+	// Запросы, отмененные клиентом, ничего не говорят о состоянии бэкенда
+	if b.concurrency != nil && ctx.Err() == nil {
+		dropped := err != nil || statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+		b.concurrency.observe(duration, active, dropped)
+	}
+
+	if err != nil {
+		b.release()
+		return nil, err
+	}
+
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: b.release}
+	return resp, nil
+}
 
-	return resp, err
+// acquire занимает соединение в лимите MaxConnections и возвращает число активных
+// соединений вместе с ним; false — лимит исчерпан, соединение не занято
+func (b *BaseBackend) acquire() (int64, bool) {
+	active := b.activeConnections.Add(1)
+	if limit := b.MaxConnections(); limit > 0 && active > int64(limit) {
+		b.activeConnections.Add(-1)
+		return active - 1, false
+	}
+	return active, true
 }
 
-func (b *BaseBackend) updateRequestStats(duration time.Duration, success bool) {
+// release освобождает соединение, занятое acquire
+func (b *BaseBackend) release() {
+	b.activeConnections.Add(-1)
+}
+
+// releaseBody освобождает соединение бэкенда при закрытии тела ответа
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseBody) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// updateRequestStats учитывает запрос в статистике; statusCode 0 означает ошибку соединения,
+// statusCanceled — отключение клиента. Прерванные запросы не учитываются во времени ответа.
+func (b *BaseBackend) updateRequestStats(duration time.Duration, statusCode int) {
+	if statusCode == statusCanceled {
+		b.requestCount.Add(1)
+		b.responses.add(time.Now(), statusCode)
+		return
+	}
+
 	// Обновляем времена ответов
 	b.timesMux.Lock()
 	b.requestTimes[b.requestTimesIdx] = duration
@@ -152,51 +571,44 @@ func (b *BaseBackend) updateRequestStats(duration time.Duration, success bool) {
 
 	// Увеличиваем счетчики
 	b.requestCount.Add(1)
-	if success {
-		b.successCount.Add(1)
-	}
+	b.responses.add(time.Now(), statusCode)
 }
 
+// updateStats пересчитывает статистику за последнюю минуту. Вызывается statsAggregator раз в секунду.
 func (b *BaseBackend) updateStats() {
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		b.statsMux.Lock()
-
-		// Обновляем RPS
-		now := time.Now()
-		elapsed := now.Sub(b.lastCountReset).Seconds()
-		if elapsed > 0 {
-			count := b.requestCount.Load()
-			b.stats.RequestsPerSecond = float64(count) / elapsed
-			b.requestCount.Store(0)
-			b.lastCountReset = now
-		}
-
-		// Обновляем Success Rate
-		totalRequests := b.requestCount.Load()
-		if totalRequests > 0 {
-			successRequests := b.successCount.Load()
-			b.stats.SuccessRate = float64(successRequests) / float64(totalRequests)
-		}
+	b.statsMux.Lock()
+	defer b.statsMux.Unlock()
+
+	// Обновляем RPS
+	now := time.Now()
+	elapsed := now.Sub(b.lastCountReset).Seconds()
+	if elapsed > 0 {
+		count := b.requestCount.Load()
+		b.stats.RequestsPerSecond = float64(count) / elapsed
+		b.requestCount.Store(0)
+		b.lastCountReset = now
+	}
 
-		// Обновляем среднее время ответа
-		b.timesMux.RLock()
-		var total time.Duration
-		count := 0
-		for _, t := range b.requestTimes {
-			if t > 0 {
-				total += t
-				count++
-			}
-		}
-		b.timesMux.RUnlock()
+	// Обновляем Success Rate по ответам за последнюю минуту
+	b.stats.Responses = b.responses.counts(now)
+	b.stats.SuccessRate = 1
+	if total := b.stats.Responses.Total(); total > 0 {
+		b.stats.SuccessRate = float64(b.stats.Responses.Successful()) / float64(total)
+	}
 
-		if count > 0 {
-			b.stats.AvgResponseTime = total / time.Duration(count)
+	// Обновляем среднее время ответа
+	b.timesMux.RLock()
+	var total time.Duration
+	count := 0
+	for _, t := range b.requestTimes {
+		if t > 0 {
+			total += t
+			count++
 		}
+	}
+	b.timesMux.RUnlock()
 
-		b.statsMux.Unlock()
+	if count > 0 {
+		b.stats.AvgResponseTime = total / time.Duration(count)
 	}
 }
@@ -0,0 +1,378 @@
+package backend
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"cloud.ru_test/config"
+)
+
+// TestMain проверяет, что после тестов пакета не остается запущенных горутин
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func TestNewFromConfig_ReadTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	b := NewFromConfig(config.BackendConfig{
+		ID:             "backend1",
+		URL:            server.URL,
+		ReadTimeout:    50 * time.Millisecond,
+		MaxConnections: 1,
+	})
+	defer b.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/fast", nil)
+	resp, err := b.Handle(context.Background(), req)
+	if err != nil {
+		t.Fatalf("быстрый запрос не должен завершаться ошибкой: %v", err)
+	}
+	resp.Body.Close()
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/slow", nil)
+	if _, err := b.Handle(context.Background(), req); err == nil {
+		t.Error("запрос дольше readTimeout должен завершаться ошибкой")
+	}
+}
+
+func TestHandle_MaxConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	b := NewBackendWithOptions("backend1", server.URL, 1, Options{MaxConnections: 1})
+	defer b.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := b.Handle(context.Background(), req)
+	if err != nil {
+		t.Fatalf("первый запрос не должен завершаться ошибкой: %v", err)
+	}
+
+	// Пока тело ответа не закрыто, соединение считается занятым
+	if !IsSaturated(b) {
+		t.Error("бэкенд с занятым единственным соединением должен считаться насыщенным")
+	}
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := b.Handle(context.Background(), req); !errors.Is(err, ErrMaxConnections) {
+		t.Errorf("ожидалась ошибка ErrMaxConnections, получено %v", err)
+	}
+
+	resp.Body.Close()
+	if IsSaturated(b) {
+		t.Error("после закрытия тела ответа соединение должно освобождаться")
+	}
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err = b.Handle(context.Background(), req)
+	if err != nil {
+		t.Fatalf("запрос после освобождения соединения не должен завершаться ошибкой: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestHandle_HostAndHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "api.internal" {
+			t.Errorf("ожидался Host api.internal, получен %s", r.Host)
+		}
+		if r.Header.Get("X-Internal-Token") != "secret" {
+			t.Errorf("заголовок бэкенда не передан: %v", r.Header)
+		}
+		if r.Header.Get("X-Client") != "curl" {
+			t.Error("заголовки клиента должны сохраняться")
+		}
+	}))
+	defer server.Close()
+
+	b := NewFromConfig(config.BackendConfig{
+		ID:      "backend1",
+		URL:     server.URL,
+		Host:    "api.internal",
+		Headers: map[string]string{"x-internal-token": "secret"},
+	})
+	defer b.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("X-Client", "curl")
+	resp, err := b.Handle(context.Background(), req)
+	if err != nil {
+		t.Fatalf("запрос не должен завершаться ошибкой: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestResponseWindow(t *testing.T) {
+	var w responseWindow
+	start := time.Unix(1000, 0)
+
+	w.add(start, http.StatusOK)
+	w.add(start, http.StatusFound)
+	w.add(start.Add(10*time.Second), http.StatusNotFound)
+	w.add(start.Add(20*time.Second), http.StatusBadGateway)
+	w.add(start.Add(30*time.Second), 0)
+
+	counts := w.counts(start.Add(30 * time.Second))
+	want := ResponseCounts{Status2xx: 1, Status3xx: 1, Status4xx: 1, Status5xx: 1, Errors: 1}
+	if counts != want {
+		t.Errorf("ожидалось %+v, получено %+v", want, counts)
+	}
+	if counts.Successful() != 2 || counts.Total() != 5 {
+		t.Errorf("неверные итоги: успешных %d из %d", counts.Successful(), counts.Total())
+	}
+
+	// Ответы старше минуты не учитываются
+	counts = w.counts(start.Add(85 * time.Second))
+	if counts.Total() != 1 || counts.Errors != 1 {
+		t.Errorf("устаревшие ответы должны выпадать из окна: %+v", counts)
+	}
+}
+
+func TestHandle_H2C(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("ожидался запрос HTTP/2, получен %s", r.Proto)
+		}
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte("ok"))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetHTTP1(true)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	b := NewFromConfig(config.BackendConfig{ID: "grpc", URL: server.URL, Protocol: "h2c"})
+	defer b.Close()
+	req, _ := http.NewRequest(http.MethodPost, server.URL, nil)
+	resp, err := b.Handle(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 || resp.Trailer.Get("Grpc-Status") != "0" {
+		t.Errorf("ожидался ответ HTTP/2 с трейлером, получен %s %v", resp.Proto, resp.Trailer)
+	}
+}
+
+func TestHandle_Unix(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + r.URL.RequestURI()))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	b := NewFromConfig(config.BackendConfig{ID: "sidecar", URL: "unix://" + socket})
+	defer b.Close()
+	req, _ := http.NewRequest(http.MethodGet, RequestURL(b.URL(), "/api?x=1"), nil)
+	resp, err := b.Handle(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "localhost/api?x=1" {
+		t.Errorf("неверный запрос к бэкенду на unix сокете: %s", body)
+	}
+}
+
+func TestHandle_ClientCanceled(t *testing.T) {
+	aborted := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(aborted)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	b := NewBackend("b1", server.URL, 1)
+	defer b.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if _, err := b.Handle(ctx, req); err == nil {
+		t.Fatal("ожидалась ошибка отмененного запроса")
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Error("запрос к бэкенду не прерван после отключения клиента")
+	}
+	counts := b.responses.counts(time.Now())
+	if counts.Canceled != 1 || counts.Errors != 0 || counts.Total() != 0 {
+		t.Errorf("отключение клиента должно учитываться отдельно от ошибок: %+v", counts)
+	}
+}
+
+func TestClose_NoLeaks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	registered := aggregator.len()
+	ignore := goleak.IgnoreCurrent()
+
+	backends := make([]*BaseBackend, 5)
+	for i := range backends {
+		backends[i] = NewBackend("backend", server.URL, 1)
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := backends[i].Handle(context.Background(), req)
+		if err != nil {
+			t.Fatalf("запрос не должен завершаться ошибкой: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if aggregator.len() != registered+len(backends) {
+		t.Fatal("статистика каждого открытого бэкенда должна обновляться агрегатором")
+	}
+
+	for _, b := range backends {
+		b.Close()
+		b.Close() // Повторный вызов ничего не делает
+	}
+	if aggregator.len() != registered {
+		t.Error("закрытые бэкенды не должны оставаться в агрегаторе статистики")
+	}
+
+	// Агрегатор статистики и соединения с бэкендами завершаются вместе с последним бэкендом
+	goleak.VerifyNone(t, ignore)
+}
+
+func TestStatsAggregator(t *testing.T) {
+	b := NewBackend("backend1", "http://127.0.0.1:1", 1)
+	defer b.Close()
+
+	b.updateRequestStats(10*time.Millisecond, http.StatusInternalServerError)
+	deadline := time.Now().Add(3 * statsInterval)
+	for b.GetLoadStats().Responses.Status5xx == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("статистика бэкенда должна обновляться агрегатором")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if rate := b.GetLoadStats().SuccessRate; rate != 0 {
+		t.Errorf("ожидалась доля успешных ответов 0, получено %v", rate)
+	}
+}
+
+func TestHandle_TLSSessionResumption(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	for _, tc := range []struct {
+		id        string
+		cacheSize int
+		resumed   int64
+	}{
+		{"tls-cached", 0, 2},
+		{"tls-uncached", -1, 0},
+	} {
+		b := NewFromConfig(config.BackendConfig{ID: tc.id, URL: server.URL, TLS: &config.BackendTLSConfig{SessionCacheSize: tc.cacheSize}}).(*BaseBackend)
+		transport := b.client.Transport.(*http.Transport)
+		transport.TLSClientConfig.RootCAs = pool
+
+		opened, resumed, full := connectionsOpened.Value(tc.id), tlsHandshakes.Value(tc.id, "true"), tlsHandshakes.Value(tc.id, "false")
+
+		// Каждый запрос открывает новое соединение
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			resp, err := b.Handle(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+			transport.CloseIdleConnections()
+		}
+		b.Close()
+
+		if got := connectionsOpened.Value(tc.id) - opened; got != 3 {
+			t.Errorf("%s: ожидалось 3 открытых соединения, учтено %d", tc.id, got)
+		}
+		if got := tlsHandshakes.Value(tc.id, "true") - resumed; got != tc.resumed {
+			t.Errorf("%s: ожидалось %d рукопожатий с возобновлением сессии, учтено %d", tc.id, tc.resumed, got)
+		}
+		if got := tlsHandshakes.Value(tc.id, "false") - full; got != 3-tc.resumed {
+			t.Errorf("%s: ожидалось %d полных рукопожатий, учтено %d", tc.id, 3-tc.resumed, got)
+		}
+	}
+}
+
+func TestDialTunnel(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	b := NewBackendWithOptions("tunnel", "https://"+listener.Addr().String(), 1, Options{MaxConnections: 1})
+	defer b.Close()
+	conn, err := b.DialTunnel(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.DialTunnel(context.Background(), ""); !errors.Is(err, ErrMaxConnections) {
+		t.Errorf("при исчерпанном лимите ожидалась ErrMaxConnections, получено %v", err)
+	}
+	conn.Close()
+	conn.Close()
+	if b.ActiveConnections() != 0 {
+		t.Errorf("закрытый туннель должен освобождать соединение, занято %d", b.ActiveConnections())
+	}
+}
+
+func TestTunnelAddr(t *testing.T) {
+	for _, tc := range []struct{ url, port, want string }{
+		{"https://10.0.0.1:8443", "", "10.0.0.1:8443"},
+		{"http://10.0.0.1:8080", "9443", "10.0.0.1:9443"},
+		{"https://payments.internal", "", "payments.internal:443"},
+		{"http://[::1]", "", "[::1]:80"},
+	} {
+		if got, err := TunnelAddr(tc.url, tc.port); err != nil || got != tc.want {
+			t.Errorf("%s (port %q): ожидался адрес %s, получен %s: %v", tc.url, tc.port, tc.want, got, err)
+		}
+	}
+}
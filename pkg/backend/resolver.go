@@ -0,0 +1,154 @@
+package backend
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDNSRefreshInterval интервал повторного разрешения имени бэкенда по умолчанию
+const DefaultDNSRefreshInterval = 30 * time.Second
+
+// resolveTimeout ограничивает время одного разрешения имени
+const resolveTimeout = 5 * time.Second
+
+// resolver кэширует адреса имени хоста бэкенда и периодически разрешает его заново.
+// Стандартный транспорт разрешает имя только при установке соединения, поэтому
+// keep-alive соединения продолжают использовать старый IP, пока не истекут.
+// При изменении набора адресов resolver закрывает простаивающие соединения,
+// и новые запросы подключаются к актуальным адресам.
+type resolver struct {
+	host     string
+	interval time.Duration
+	dialer   *net.Dialer
+	lookup   func(ctx context.Context, host string) ([]string, error)
+
+	// Вызывается при изменении набора адресов
+	onChange func()
+
+	mu         sync.RWMutex
+	addrs      []string
+	resolvedAt time.Time
+
+	refreshing atomic.Bool
+	next       atomic.Uint64
+}
+
+// newResolver создает resolver для имени хоста. Для IP адресов возвращает nil:
+// разрешать заново нечего.
+func newResolver(host string, interval time.Duration, dialer *net.Dialer) *resolver {
+	if host == "" || net.ParseIP(host) != nil {
+		return nil
+	}
+	if interval <= 0 {
+		interval = DefaultDNSRefreshInterval
+	}
+	return &resolver{
+		host:     host,
+		interval: interval,
+		dialer:   dialer,
+		lookup:   net.DefaultResolver.LookupHost,
+	}
+}
+
+// DialContext подключается к одному из закэшированных адресов хоста по очереди.
+// Соединения с другими хостами устанавливаются обычным образом.
+func (r *resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || host != r.host {
+		return r.dialer.DialContext(ctx, network, address)
+	}
+
+	addrs, err := r.addresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	start := int(r.next.Add(1))
+	var conn net.Conn
+	for i := range addrs {
+		addr := addrs[(start+i)%len(addrs)]
+		conn, err = r.dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	// Ни один адрес не ответил: возможно, адреса уже сменились
+	r.refreshAsync()
+	return nil, err
+}
+
+// addresses возвращает закэшированные адреса; при первом обращении разрешает имя синхронно
+func (r *resolver) addresses(ctx context.Context) ([]string, error) {
+	r.mu.RLock()
+	addrs := r.addrs
+	r.mu.RUnlock()
+	if len(addrs) > 0 {
+		return addrs, nil
+	}
+
+	if err := r.refresh(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.addrs, nil
+}
+
+// maybeRefresh запускает фоновое разрешение имени, если закэшированные адреса устарели.
+// До первого соединения имя разрешается синхронно в DialContext.
+func (r *resolver) maybeRefresh() {
+	r.mu.RLock()
+	stale := !r.resolvedAt.IsZero() && time.Since(r.resolvedAt) >= r.interval
+	r.mu.RUnlock()
+	if stale {
+		r.refreshAsync()
+	}
+}
+
+// refreshAsync разрешает имя в фоне; одновременно выполняется не больше одного разрешения
+func (r *resolver) refreshAsync() {
+	if !r.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer r.refreshing.Store(false)
+
+		ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+		defer cancel()
+		_ = r.refresh(ctx)
+	}()
+}
+
+// refresh разрешает имя и обновляет кэш. При ошибке сохраняются прежние адреса:
+// временная недоступность DNS не должна прерывать работу с бэкендом.
+func (r *resolver) refresh(ctx context.Context) error {
+	addrs, err := r.lookup(ctx, r.host)
+	if err == nil && len(addrs) == 0 {
+		err = &net.DNSError{Err: "no addresses", Name: r.host, IsNotFound: true}
+	}
+
+	r.mu.Lock()
+	r.resolvedAt = time.Now()
+	if err != nil {
+		r.mu.Unlock()
+		return err
+	}
+
+	slices.Sort(addrs)
+	changed := r.addrs != nil && !slices.Equal(addrs, r.addrs)
+	r.addrs = addrs
+	r.mu.Unlock()
+
+	if changed && r.onChange != nil {
+		r.onChange()
+	}
+	return nil
+}
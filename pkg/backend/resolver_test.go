@@ -0,0 +1,63 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolver_ReresolvesHostname(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	b := NewBackend("backend1", "http://backend.test:"+port, 1)
+	defer b.Close()
+	if b.resolver == nil {
+		t.Fatal("для имени хоста должен создаваться resolver")
+	}
+
+	addrs := []string{"127.0.0.1"}
+	var lookupErr error
+	b.resolver.lookup = func(ctx context.Context, host string) ([]string, error) {
+		return append([]string(nil), addrs...), lookupErr
+	}
+	changes := 0
+	b.resolver.onChange = func() { changes++ }
+
+	req, _ := http.NewRequest(http.MethodGet, "http://backend.test:"+port+"/", nil)
+	resp, err := b.Handle(context.Background(), req)
+	if err != nil {
+		t.Fatalf("запрос должен идти на разрешенный адрес: %v", err)
+	}
+	resp.Body.Close()
+
+	// Тот же набор адресов в другом порядке не считается изменением
+	addrs = []string{"127.0.0.2", "127.0.0.1"}
+	b.resolver.refresh(context.Background())
+	addrs = []string{"127.0.0.1", "127.0.0.2"}
+	b.resolver.refresh(context.Background())
+	if changes != 1 {
+		t.Errorf("ожидалось одно изменение набора адресов, получено %d", changes)
+	}
+
+	// Ошибка DNS не должна сбрасывать известные адреса
+	lookupErr = errors.New("dns unavailable")
+	if err := b.resolver.refresh(context.Background()); err == nil {
+		t.Error("ошибка разрешения должна возвращаться")
+	}
+	if got, _ := b.resolver.addresses(context.Background()); len(got) != 2 {
+		t.Errorf("при ошибке DNS должны сохраняться прежние адреса, получено %v", got)
+	}
+
+	direct := NewBackend("backend2", server.URL, 1)
+	defer direct.Close()
+	if direct.resolver != nil {
+		t.Error("для IP адреса resolver не нужен")
+	}
+}
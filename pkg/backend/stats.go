@@ -0,0 +1,165 @@
+package backend
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cloud.ru_test/pkg/workerpool"
+)
+
+// responseWindowSize длина скользящего окна статистики ответов в секундах
+const responseWindowSize = 60
+
+// statusCanceled код запроса, прерванного из-за отключения клиента
+const statusCanceled = -1
+
+// responseBucket ответы за одну секунду
+type responseBucket struct {
+	second int64
+	counts ResponseCounts
+}
+
+// responseWindow считает ответы по классам статусов в скользящем окне
+// из responseWindowSize секундных интервалов
+type responseWindow struct {
+	mu      sync.Mutex
+	buckets [responseWindowSize]responseBucket
+}
+
+// add учитывает ответ со статусом statusCode (0 — ошибка соединения, statusCanceled — отключение клиента)
+func (w *responseWindow) add(now time.Time, statusCode int) {
+	second := now.Unix()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	bucket := &w.buckets[second%responseWindowSize]
+	if bucket.second != second {
+		*bucket = responseBucket{second: second}
+	}
+
+	switch {
+	case statusCode == statusCanceled:
+		bucket.counts.Canceled++
+	case statusCode == 0:
+		bucket.counts.Errors++
+	case statusCode < 300:
+		bucket.counts.Status2xx++
+	case statusCode < 400:
+		bucket.counts.Status3xx++
+	case statusCode < 500:
+		bucket.counts.Status4xx++
+	default:
+		bucket.counts.Status5xx++
+	}
+}
+
+// counts возвращает сумму ответов за последние responseWindowSize секунд
+func (w *responseWindow) counts(now time.Time) ResponseCounts {
+	oldest := now.Unix() - responseWindowSize
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var total ResponseCounts
+	for _, bucket := range w.buckets {
+		if bucket.second <= oldest {
+			continue
+		}
+		total.Status2xx += bucket.counts.Status2xx
+		total.Status3xx += bucket.counts.Status3xx
+		total.Status4xx += bucket.counts.Status4xx
+		total.Status5xx += bucket.counts.Status5xx
+		total.Errors += bucket.counts.Errors
+		total.Canceled += bucket.counts.Canceled
+	}
+	return total
+}
+
+// statsInterval период обновления статистики бэкендов
+const statsInterval = time.Second
+
+// statsAggregator раз в statsInterval обновляет статистику всех открытых бэкендов на общем
+// пуле горутин, так что их число не растет с числом бэкендов. Таймер и пул работают,
+// только пока есть хотя бы один открытый бэкенд.
+type statsAggregator struct {
+	mu       sync.Mutex
+	backends map[*BaseBackend]struct{}
+
+	// Закрывается, когда закрыт последний бэкенд
+	stop chan struct{}
+}
+
+// aggregator агрегатор статистики бэкендов процесса
+var aggregator = &statsAggregator{backends: make(map[*BaseBackend]struct{})}
+
+// add начинает обновлять статистику бэкенда
+func (a *statsAggregator) add(b *BaseBackend) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.backends[b] = struct{}{}
+	if len(a.backends) == 1 {
+		a.stop = make(chan struct{})
+		go a.run(a.stop)
+	}
+}
+
+// remove прекращает обновлять статистику бэкенда
+func (a *statsAggregator) remove(b *BaseBackend) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.backends[b]; !ok {
+		return
+	}
+	delete(a.backends, b)
+	if len(a.backends) == 0 {
+		close(a.stop)
+	}
+}
+
+// len возвращает количество бэкендов, статистика которых обновляется
+func (a *statsAggregator) len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.backends)
+}
+
+// run обновляет статистику до закрытия stop
+func (a *statsAggregator) run(stop <-chan struct{}) {
+	pool := workerpool.New(workerpool.Options{})
+	defer pool.Shutdown(context.Background())
+
+	// Ожидание свободной горутины пула прерывается закрытием последнего бэкенда
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		a.mu.Lock()
+		backends := make([]*BaseBackend, 0, len(a.backends))
+		for b := range a.backends {
+			backends = append(backends, b)
+		}
+		a.mu.Unlock()
+
+		for _, b := range backends {
+			if err := pool.Submit(ctx, b.updateStats); err != nil {
+				break
+			}
+		}
+	}
+}
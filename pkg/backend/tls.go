@@ -0,0 +1,56 @@
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strconv"
+
+	"cloud.ru_test/internal/metrics"
+)
+
+// DefaultTLSSessionCacheSize число сессий TLS бэкенда, сохраняемых для возобновления
+const DefaultTLSSessionCacheSize = 64
+
+var (
+	// tlsHandshakes счетчик TLS рукопожатий с бэкендами: полных (resumed="false") и
+	// с возобновлением сохраненной сессии (resumed="true")
+	tlsHandshakes = metrics.Default.Counter("lb_backend_tls_handshakes_total", "TLS handshakes with backends by whether the session was resumed", "backend", "resumed")
+
+	// connectionsOpened счетчик соединений, открытых к бэкендам. Вместе с lb_requests_total
+	// показывает, какая доля запросов использует уже открытые соединения.
+	connectionsOpened = metrics.Default.Counter("lb_backend_connections_opened_total", "Connections opened to backends", "backend")
+)
+
+// upstreamTLSConfig настройки TLS соединений с бэкендом id: сессии сохраняются в кэше
+// на cacheSize записей (0 — DefaultTLSSessionCacheSize, отрицательное — не сохраняются),
+// чтобы новые соединения обходились без полного рукопожатия, а каждое рукопожатие
+// учитывается в метриках
+func upstreamTLSConfig(id string, cacheSize int) *tls.Config {
+	cfg := &tls.Config{
+		// Вызывается после проверки сертификата для каждого рукопожатия, в том числе
+		// с возобновлением сессии
+		VerifyConnection: func(state tls.ConnectionState) error {
+			tlsHandshakes.Inc(id, strconv.FormatBool(state.DidResume))
+			return nil
+		},
+	}
+	if cacheSize == 0 {
+		cacheSize = DefaultTLSSessionCacheSize
+	}
+	if cacheSize > 0 {
+		cfg.ClientSessionCache = tls.NewLRUClientSessionCache(cacheSize)
+	}
+	return cfg
+}
+
+// countConnections учитывает соединения, успешно открытые функцией dial, в метрике бэкенда id
+func countConnections(id string, dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err == nil {
+			connectionsOpened.Inc(id)
+		}
+		return conn, err
+	}
+}
@@ -0,0 +1,80 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+)
+
+// DialTunnel открывает TCP соединение с бэкендом для передачи данных без разбора протокола
+// (например, TLS без расшифровки). Соединение устанавливается с таймаутом и через
+// resolver бэкенда и занимает место в лимите MaxConnections до своего закрытия; при
+// исчерпанном лимите возвращается ErrMaxConnections. port заменяет порт из URL бэкенда
+// (пустой — порт из URL, 443 для https и 80 для http, если он не указан).
+func (b *BaseBackend) DialTunnel(ctx context.Context, port string) (net.Conn, error) {
+	if SocketPath(b.url) != "" {
+		return nil, fmt.Errorf("tunnels to unix socket backends are not supported")
+	}
+	addr, err := TunnelAddr(b.url, port)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := b.acquire(); !ok {
+		return nil, ErrMaxConnections
+	}
+	if b.resolver != nil {
+		b.resolver.maybeRefresh()
+	}
+	conn, err := b.dial(ctx, "tcp", addr)
+	if err != nil {
+		b.release()
+		return nil, err
+	}
+	return &tunnelConn{Conn: conn, release: b.release}, nil
+}
+
+// TunnelAddr возвращает адрес host:port бэкенда backendURL для туннеля: порт port
+// или порт из адреса бэкенда (443 для https и 80 для http, если он не указан)
+func TunnelAddr(backendURL, port string) (string, error) {
+	u, err := url.Parse(backendURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("no host in %q", backendURL)
+	}
+	if port == "" {
+		port = u.Port()
+	}
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// tunnelConn соединение туннеля, освобождающее место в лимите соединений бэкенда при закрытии
+type tunnelConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *tunnelConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+// CloseWrite закрывает запись в TCP соединение
+func (c *tunnelConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
@@ -0,0 +1,45 @@
+package backend
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// UnixScheme схема URL бэкенда, принимающего запросы на unix сокете: unix:///run/app.sock
+const UnixScheme = "unix"
+
+// unixRequestHost хост в URL запросов к бэкенду на unix сокете
+const unixRequestHost = "localhost"
+
+// SocketPath возвращает путь unix сокета бэкенда или пустую строку для TCP бэкендов
+func SocketPath(rawURL string) string {
+	if !strings.HasPrefix(rawURL, UnixScheme+":") {
+		return ""
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme != UnixScheme {
+		return ""
+	}
+	return parsed.Path
+}
+
+// RequestURL возвращает URL запроса к бэкенду с путем path. Запросы к бэкенду на unix
+// сокете адресуются хосту localhost, а к сокету подключается транспорт бэкенда.
+func RequestURL(rawURL, path string) string {
+	if SocketPath(rawURL) != "" {
+		return "http://" + unixRequestHost + path
+	}
+	return rawURL + path
+}
+
+// NewUnixTransport возвращает транспорт, подключающийся к unix сокету независимо от адреса запроса
+func NewUnixTransport(socket string, dialer *net.Dialer) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", socket)
+	}
+	return transport
+}
@@ -0,0 +1,197 @@
+// Package capture описывает формат записанных запросов и их запись на диск и чтение.
+//
+// Запросы хранятся в файлах JSON Lines (*.jsonl): одна запись Record на строку.
+// Файлы создает middleware capture прокси, воспроизводит их утилита cmd/replay.
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FileExtension расширение файлов с записанными запросами
+const FileExtension = ".jsonl"
+
+// Record записанный запрос клиента и результат его обработки
+type Record struct {
+	Time   time.Time   `json:"time"`
+	Method string      `json:"method"`
+	Host   string      `json:"host,omitempty"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+
+	// Тело запроса: текст, если тело в UTF-8, иначе base64 в BodyBase64
+	Body       string `json:"body,omitempty"`
+	BodyBase64 string `json:"bodyBase64,omitempty"`
+
+	// Тело длиннее предела записи и сохранено не полностью; такой запрос не воспроизводится точно
+	BodyTruncated bool `json:"bodyTruncated,omitempty"`
+
+	// Статус ответа прокси и время обработки запроса
+	Status     int     `json:"status"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+// SetBody сохраняет тело запроса
+func (r *Record) SetBody(body []byte) {
+	r.Body, r.BodyBase64 = "", ""
+	if utf8.Valid(body) {
+		r.Body = string(body)
+	} else {
+		r.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+}
+
+// BodyBytes возвращает тело запроса
+func (r *Record) BodyBytes() ([]byte, error) {
+	if r.BodyBase64 != "" {
+		return base64.StdEncoding.DecodeString(r.BodyBase64)
+	}
+	return []byte(r.Body), nil
+}
+
+// NewRequest создает запрос для воспроизведения записи на target (например, http://staging:8080).
+// Заголовки Host и Content-Length формируются заново, остальные заголовки сохраняются.
+func (r *Record) NewRequest(target string) (*http.Request, error) {
+	body, err := r.BodyBytes()
+	if err != nil {
+		return nil, fmt.Errorf("invalid body: %w", err)
+	}
+	url := strings.TrimSuffix(target, "/") + r.Path
+	if r.Query != "" {
+		url += "?" + r.Query
+	}
+	req, err := http.NewRequest(r.Method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Header.Del("Content-Length")
+	return req, nil
+}
+
+// Writer записывает запросы в файлы каталога, начиная новый файл, когда текущий
+// превышает заданный размер. Безопасен для одновременного использования.
+type Writer struct {
+	dir string
+
+	mu          sync.Mutex
+	maxFileSize int64
+	file        *os.File
+	size        int64
+}
+
+// NewWriter создает каталог dir и возвращает Writer. maxFileSize — размер файла,
+// после которого начинается новый (0 — без ограничения).
+func NewWriter(dir string, maxFileSize int64) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	return &Writer{dir: dir, maxFileSize: maxFileSize}, nil
+}
+
+// SetMaxFileSize изменяет размер файла, после которого начинается новый
+func (w *Writer) SetMaxFileSize(maxFileSize int64) {
+	w.mu.Lock()
+	w.maxFileSize = maxFileSize
+	w.mu.Unlock()
+}
+
+// Write дописывает запись в текущий файл
+func (w *Writer) Write(rec *Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file != nil && w.maxFileSize > 0 && w.size+int64(len(line)) > w.maxFileSize {
+		w.file.Close()
+		w.file = nil
+	}
+	if w.file == nil {
+		name := filepath.Join(w.dir, "capture-"+time.Now().UTC().Format("20060102T150405.000000000")+FileExtension)
+		file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to create capture file: %w", err)
+		}
+		w.file, w.size = file, 0
+	}
+
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// Close закрывает текущий файл
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// Files возвращает файлы записи: сам path, если это файл, или файлы *.jsonl каталога
+// в порядке создания
+func Files(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(path, "*"+FileExtension))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Read читает записи из r и передает их fn; чтение прекращается при первой ошибке fn
+func Read(r io.Reader, fn func(*Record) error) error {
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			var rec Record
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			if err := fn(&rec); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
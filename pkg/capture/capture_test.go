@@ -0,0 +1,80 @@
+package capture
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func TestWriter_RotateAndRead(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		rec := &Record{Method: "POST", Path: "/api", Status: 200}
+		rec.SetBody([]byte{0xff, byte(i)})
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) < 2 {
+		t.Fatalf("файл должен ротироваться при превышении размера, файлов: %d", len(files))
+	}
+
+	var read []*Record
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Read(file, func(rec *Record) error {
+			read = append(read, rec)
+			return nil
+		})
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(read) != 5 {
+		t.Fatalf("должно быть прочитано 5 записей, прочитано %d", len(read))
+	}
+	for i, rec := range read {
+		body, err := rec.BodyBytes()
+		if err != nil || len(body) != 2 || body[1] != byte(i) {
+			t.Errorf("записи должны читаться в порядке записи с исходным телом: %d: %v %v", i, body, err)
+		}
+	}
+}
+
+func TestRecord_NewRequest(t *testing.T) {
+	rec := &Record{
+		Method: "PUT",
+		Path:   "/items/1",
+		Query:  "a=1",
+		Header: map[string][]string{"Content-Type": {"application/json"}, "Content-Length": {"100"}},
+	}
+	rec.SetBody([]byte(`{"name":"x"}`))
+
+	req, err := rec.NewRequest("http://staging:8080/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.String() != "http://staging:8080/items/1?a=1" {
+		t.Errorf("неверный адрес запроса: %s", req.URL)
+	}
+	if req.Header.Get("Content-Type") != "application/json" || req.Header.Get("Content-Length") != "" {
+		t.Errorf("заголовки должны сохраняться, кроме Content-Length: %v", req.Header)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"name":"x"}` || req.ContentLength != int64(len(body)) {
+		t.Errorf("неверное тело запроса: %q, длина %d", body, req.ContentLength)
+	}
+}
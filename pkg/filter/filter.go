@@ -0,0 +1,50 @@
+// Package filter описывает ABI фильтров запросов, загружаемых из Go плагинов (.so).
+//
+// Плагин собирается командой go build -buildmode=plugin с теми же версиями Go и
+// зависимостей, что и прокси, и экспортирует функцию
+//
+//	func NewFilter(params map[string]interface{}) (filter.Filter, error)
+//
+// и, необязательно, переменную ABIVersion int, равную filter.ABIVersion.
+package filter
+
+import "net/http"
+
+// ABIVersion версия ABI фильтров. Плагин, объявивший другую версию, не загружается.
+const ABIVersion = 1
+
+// Request запрос клиента, передаваемый фильтру. Изменения пути, query,
+// заголовков и тела применяются к запросу перед его передачей дальше по конвейеру.
+type Request struct {
+	Method     string
+	Path       string
+	Query      string
+	RemoteAddr string
+	Header     http.Header
+
+	// Тело запроса; заполняется, если в настройках плагина включен requestBody
+	Body []byte
+}
+
+// Response ответ клиенту
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Filter фильтр запросов
+type Filter interface {
+	// OnRequest вызывается до выбора бэкенда и возвращает решение по запросу:
+	// nil — продолжить обработку, иначе ответ, который сразу отправляется клиенту
+	OnRequest(req *Request) *Response
+}
+
+// ResponseFilter фильтр, который также обрабатывает ответы бэкендов
+type ResponseFilter interface {
+	Filter
+
+	// OnResponse вызывается перед отправкой ответа клиенту и может изменить
+	// статус, заголовки и тело ответа
+	OnResponse(req *Request, resp *Response)
+}
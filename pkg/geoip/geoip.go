@@ -0,0 +1,238 @@
+// Package geoip определяет страну и автономную систему клиента по IP адресу
+// с помощью баз в формате MaxMind DB и отслеживает обновление файлов баз
+package geoip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+// reloadDebounceDelay задержка перечитывания базы после изменения файла:
+// базы обычно копируются на место крупными блоками
+const reloadDebounceDelay = time.Second
+
+// Info географические данные клиента
+type Info struct {
+	// Код страны ISO 3166-1 alpha-2 (пустой, если неизвестна)
+	Country string
+
+	// Номер и организация автономной системы (0, если неизвестна)
+	ASN   uint32
+	ASOrg string
+
+	// Зона бэкендов, в которую направляются запросы клиента по правилам geoIP.zones
+	Zone string
+}
+
+// zoneRule правило выбора зоны бэкендов
+type zoneRule struct {
+	countries map[string]bool
+	asns      map[uint32]bool
+	zone      string
+}
+
+// Resolver определяет географические данные клиентов. Базы стран и автономных систем
+// перечитываются при изменении файлов; если новая база повреждена, используется прежняя.
+type Resolver struct {
+	countryPath string
+	asnPath     string
+	zones       []zoneRule
+	logger      logger.Logger
+
+	mu      sync.RWMutex
+	country *Reader
+	asn     *Reader
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New открывает базы из конфигурации
+func New(cfg *config.GeoIPConfig, appLogger logger.Logger) (*Resolver, error) {
+	r := &Resolver{
+		countryPath: cfg.Database,
+		asnPath:     cfg.ASNDatabase,
+		logger:      appLogger,
+	}
+
+	for _, zc := range cfg.Zones {
+		rule := zoneRule{countries: make(map[string]bool), asns: make(map[uint32]bool), zone: zc.Zone}
+		for _, country := range zc.Countries {
+			rule.countries[strings.ToUpper(country)] = true
+		}
+		for _, asn := range zc.ASNs {
+			rule.asns[asn] = true
+		}
+		r.zones = append(r.zones, rule)
+	}
+
+	var err error
+	if r.countryPath != "" {
+		if r.country, err = Open(r.countryPath); err != nil {
+			return nil, fmt.Errorf("failed to open GeoIP database %s: %w", r.countryPath, err)
+		}
+	}
+	if r.asnPath != "" {
+		if r.asn, err = Open(r.asnPath); err != nil {
+			return nil, fmt.Errorf("failed to open GeoIP ASN database %s: %w", r.asnPath, err)
+		}
+	}
+	return r, nil
+}
+
+// Lookup возвращает географические данные IP адреса. Ошибки чтения базы не прерывают
+// обработку запроса: неизвестные поля остаются пустыми.
+func (r *Resolver) Lookup(ip net.IP) Info {
+	var info Info
+	if r == nil || ip == nil {
+		return info
+	}
+
+	r.mu.RLock()
+	country, asn := r.country, r.asn
+	r.mu.RUnlock()
+
+	for _, db := range []*Reader{country, asn} {
+		if db == nil {
+			continue
+		}
+		record, err := db.Lookup(ip)
+		if err != nil {
+			r.logger.Debug(fmt.Sprintf("Ошибка поиска %s в базе GeoIP %s: %v", ip, db.DatabaseType(), err))
+			continue
+		}
+		fillInfo(&info, record)
+	}
+
+	for _, rule := range r.zones {
+		if (info.Country != "" && rule.countries[info.Country]) || (info.ASN != 0 && rule.asns[info.ASN]) {
+			info.Zone = rule.zone
+			break
+		}
+	}
+	return info
+}
+
+// fillInfo заполняет незаполненные поля из записи базы. Поддерживаются записи баз
+// Country/City (country.iso_code, registered_country.iso_code) и ASN.
+func fillInfo(info *Info, record map[string]interface{}) {
+	if info.Country == "" {
+		for _, key := range []string{"country", "registered_country"} {
+			if c, ok := record[key].(map[string]interface{}); ok {
+				if code, ok := c["iso_code"].(string); ok && code != "" {
+					info.Country = code
+					break
+				}
+			}
+		}
+	}
+	if info.ASN == 0 {
+		info.ASN = uint32(toUint(record["autonomous_system_number"]))
+	}
+	if info.ASOrg == "" {
+		info.ASOrg, _ = record["autonomous_system_organization"].(string)
+	}
+}
+
+// Start запускает отслеживание изменений файлов баз
+func (r *Resolver) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("Не удалось отслеживать базы GeoIP: %v", err))
+		return
+	}
+
+	// Отслеживаются каталоги: так обнаруживается замена файла через rename
+	// и подмена символических ссылок в смонтированных ConfigMap
+	watched := make(map[string]bool)
+	for _, path := range []string{r.countryPath, r.asnPath} {
+		if path == "" || watched[filepath.Dir(path)] {
+			continue
+		}
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			r.logger.Error(fmt.Sprintf("Не удалось отслеживать базу GeoIP %s: %v", path, err))
+			continue
+		}
+		watched[filepath.Dir(path)] = true
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer watcher.Close()
+
+		debounce := time.NewTimer(reloadDebounceDelay)
+		debounce.Stop()
+		defer debounce.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				base := filepath.Base(event.Name)
+				relevant := base == "..data" ||
+					(r.countryPath != "" && base == filepath.Base(r.countryPath)) ||
+					(r.asnPath != "" && base == filepath.Base(r.asnPath))
+				if relevant && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					debounce.Reset(reloadDebounceDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				r.logger.Warn(fmt.Sprintf("Ошибка отслеживания баз GeoIP: %v", err))
+			case <-debounce.C:
+				r.reload()
+			}
+		}
+	}()
+}
+
+// Stop останавливает отслеживание изменений
+func (r *Resolver) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+}
+
+// reload перечитывает базы с диска
+func (r *Resolver) reload() {
+	for _, db := range []struct {
+		path   string
+		target **Reader
+	}{
+		{r.countryPath, &r.country},
+		{r.asnPath, &r.asn},
+	} {
+		if db.path == "" {
+			continue
+		}
+		reader, err := Open(db.path)
+		if err != nil {
+			r.logger.Warn(fmt.Sprintf("Ошибка чтения базы GeoIP %s: %v, используется предыдущая версия", db.path, err))
+			continue
+		}
+		r.mu.Lock()
+		*db.target = reader
+		r.mu.Unlock()
+		r.logger.Info(fmt.Sprintf("База GeoIP %s перечитана (%s)", db.path, reader.DatabaseType()))
+	}
+}
@@ -0,0 +1,210 @@
+package geoip
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/pkg/logger"
+)
+
+// testNode узел дерева поиска тестовой базы
+type testNode struct {
+	children [2]*testNode
+	data     []byte
+	number   int
+}
+
+// buildTestDB собирает базу MaxMind DB для IPv4 с размером записи 24 бита
+func buildTestDB(t *testing.T, databaseType string, records map[string]map[string]interface{}) []byte {
+	t.Helper()
+
+	root := &testNode{}
+	for cidr, record := range records {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, _ := network.Mask.Size()
+		node := root
+		for i := 0; i < ones; i++ {
+			bit := network.IP.To4()[i>>3] >> (7 - uint(i&7)) & 1
+			if node.children[bit] == nil {
+				node.children[bit] = &testNode{}
+			}
+			node = node.children[bit]
+		}
+		node.data = encodeValue(record)
+	}
+
+	// Нумеруем внутренние узлы в порядке обхода в ширину
+	var nodes []*testNode
+	queue := []*testNode{root}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		node.number = len(nodes)
+		nodes = append(nodes, node)
+		for _, child := range node.children {
+			if child != nil && child.data == nil {
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	var tree, data bytes.Buffer
+	for _, node := range nodes {
+		for _, child := range node.children {
+			record := len(nodes)
+			switch {
+			case child == nil:
+			case child.data != nil:
+				record = len(nodes) + 16 + data.Len()
+				data.Write(child.data)
+			default:
+				record = child.number
+			}
+			tree.Write([]byte{byte(record >> 16), byte(record >> 8), byte(record)})
+		}
+	}
+
+	var db bytes.Buffer
+	db.Write(tree.Bytes())
+	db.Write(make([]byte, 16))
+	db.Write(data.Bytes())
+	db.Write(metadataMarker)
+	db.Write(encodeValue(map[string]interface{}{
+		"node_count":    uint32(len(nodes)),
+		"record_size":   uint16(24),
+		"ip_version":    uint16(4),
+		"database_type": databaseType,
+	}))
+	return db.Bytes()
+}
+
+// encodeValue кодирует строки, целые и словари в формат секции данных
+func encodeValue(v interface{}) []byte {
+	var buf bytes.Buffer
+	uintBytes := func(n uint64) []byte {
+		var b []byte
+		for ; n > 0; n >>= 8 {
+			b = append([]byte{byte(n)}, b...)
+		}
+		return b
+	}
+	switch v := v.(type) {
+	case string:
+		if len(v) < 29 {
+			buf.WriteByte(typeString<<5 | byte(len(v)))
+		} else {
+			buf.Write([]byte{typeString<<5 | 29, byte(len(v) - 29)})
+		}
+		buf.WriteString(v)
+	case uint16:
+		b := uintBytes(uint64(v))
+		buf.WriteByte(typeUint16<<5 | byte(len(b)))
+		buf.Write(b)
+	case uint32:
+		b := uintBytes(uint64(v))
+		buf.WriteByte(typeUint32<<5 | byte(len(b)))
+		buf.Write(b)
+	case map[string]interface{}:
+		buf.WriteByte(typeMap<<5 | byte(len(v)))
+		for key, value := range v {
+			buf.Write(encodeValue(key))
+			buf.Write(encodeValue(value))
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestReaderLookup(t *testing.T) {
+	db := buildTestDB(t, "GeoLite2-Country", map[string]map[string]interface{}{
+		"81.2.69.0/24": {"country": map[string]interface{}{"iso_code": "GB"}},
+		"5.255.0.0/16": {"registered_country": map[string]interface{}{"iso_code": "RU"}},
+	})
+	r, err := FromBytes(db)
+	if err != nil {
+		t.Fatalf("база должна читаться: %v", err)
+	}
+	if r.DatabaseType() != "GeoLite2-Country" {
+		t.Errorf("неверный тип базы: %q", r.DatabaseType())
+	}
+
+	record, err := r.Lookup(net.ParseIP("81.2.69.160"))
+	if err != nil || record == nil {
+		t.Fatalf("адрес должен находиться в базе: %v", err)
+	}
+	var info Info
+	fillInfo(&info, record)
+	if info.Country != "GB" {
+		t.Errorf("ожидалась страна GB, получено %q", info.Country)
+	}
+
+	if record, _ := r.Lookup(net.ParseIP("8.8.8.8")); record != nil {
+		t.Errorf("адрес вне базы не должен находиться: %v", record)
+	}
+	if record, _ := r.Lookup(net.ParseIP("2001:db8::1")); record != nil {
+		t.Errorf("IPv6 адрес не должен находиться в базе IPv4")
+	}
+}
+
+func TestResolver(t *testing.T) {
+	dir := t.TempDir()
+	countryPath := filepath.Join(dir, "country.mmdb")
+	asnPath := filepath.Join(dir, "asn.mmdb")
+	writeDB := func(path string, db []byte) {
+		if err := os.WriteFile(path, db, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeDB(countryPath, buildTestDB(t, "GeoLite2-Country", map[string]map[string]interface{}{
+		"5.255.0.0/16": {"country": map[string]interface{}{"iso_code": "RU"}},
+	}))
+	writeDB(asnPath, buildTestDB(t, "GeoLite2-ASN", map[string]map[string]interface{}{
+		"5.255.0.0/16": {"autonomous_system_number": uint32(13238), "autonomous_system_organization": "YANDEX LLC"},
+	}))
+
+	r, err := New(&config.GeoIPConfig{
+		Database:    countryPath,
+		ASNDatabase: asnPath,
+		Zones:       []config.GeoZoneConfig{{Countries: []string{"ru"}, Zone: "ru-central1"}},
+	}, logger.NewNop())
+	if err != nil {
+		t.Fatalf("базы должны открываться: %v", err)
+	}
+
+	info := r.Lookup(net.ParseIP("5.255.255.77"))
+	want := Info{Country: "RU", ASN: 13238, ASOrg: "YANDEX LLC", Zone: "ru-central1"}
+	if info != want {
+		t.Errorf("ожидалось %+v, получено %+v", want, info)
+	}
+	if info := r.Lookup(net.ParseIP("1.1.1.1")); info != (Info{}) {
+		t.Errorf("для неизвестного адреса данные должны быть пустыми: %+v", info)
+	}
+
+	// Поврежденная база при перечитывании не заменяет прежнюю
+	writeDB(countryPath, []byte("broken"))
+	r.reload()
+	if info := r.Lookup(net.ParseIP("5.255.255.77")); info.Country != "RU" {
+		t.Errorf("после ошибки чтения должна использоваться прежняя база: %+v", info)
+	}
+
+	// Обновленная база подхватывается без перезапуска
+	r.Start()
+	defer r.Stop()
+	writeDB(countryPath, buildTestDB(t, "GeoLite2-Country", map[string]map[string]interface{}{
+		"5.255.0.0/16": {"country": map[string]interface{}{"iso_code": "KZ"}},
+	}))
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Lookup(net.ParseIP("5.255.255.77")).Country != "KZ" {
+		if time.Now().After(deadline) {
+			t.Fatalf("обновленная база не подхвачена")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
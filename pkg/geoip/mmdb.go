@@ -0,0 +1,347 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// metadataMarker предшествует метаданным в конце файла MaxMind DB
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// Типы данных формата MaxMind DB
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// Reader читает базу в формате MaxMind DB (.mmdb), например GeoLite2-Country или GeoLite2-ASN
+type Reader struct {
+	data         []byte
+	tree         []byte
+	dataSection  []byte
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	databaseType string
+	ipv4Start    uint
+}
+
+// Open читает базу из файла целиком в память
+func Open(path string) (*Reader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(data)
+}
+
+// FromBytes разбирает базу из памяти
+func FromBytes(data []byte) (*Reader, error) {
+	idx := bytes.LastIndex(data, metadataMarker)
+	if idx < 0 {
+		return nil, errors.New("invalid MaxMind DB: metadata not found")
+	}
+
+	metaDecoder := decoder{buf: data[idx+len(metadataMarker):]}
+	value, _, err := metaDecoder.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MaxMind DB metadata: %w", err)
+	}
+	meta, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid MaxMind DB metadata: not a map")
+	}
+
+	r := &Reader{data: data}
+	r.nodeCount = uint(toUint(meta["node_count"]))
+	r.recordSize = uint(toUint(meta["record_size"]))
+	r.ipVersion = uint(toUint(meta["ip_version"]))
+	r.databaseType, _ = meta["database_type"].(string)
+
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported MaxMind DB record size %d", r.recordSize)
+	}
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+16 > uint(idx) {
+		return nil, errors.New("invalid MaxMind DB: search tree exceeds file size")
+	}
+	r.tree = data[:treeSize]
+	r.dataSection = data[treeSize+16 : idx]
+
+	// В базе IPv6 адреса IPv4 хранятся как ::a.b.c.d, узел их начала вычисляется заранее
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// DatabaseType возвращает тип базы из метаданных, например GeoLite2-Country
+func (r *Reader) DatabaseType() string {
+	return r.databaseType
+}
+
+// Lookup возвращает запись для IP адреса или nil, если адрес в базе не найден
+func (r *Reader) Lookup(ip net.IP) (map[string]interface{}, error) {
+	node := uint(0)
+	bitCount := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bitCount = 32
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.ipVersion == 4 {
+		return nil, nil
+	}
+
+	for i := 0; i < bitCount && node < r.nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-uint(i&7))) & 1
+		node = r.readNode(node, bit)
+	}
+
+	switch {
+	case node == r.nodeCount:
+		return nil, nil
+	case node < r.nodeCount:
+		return nil, errors.New("invalid MaxMind DB: search tree is too deep")
+	}
+
+	offset := node - r.nodeCount - 16
+	if offset >= uint(len(r.dataSection)) {
+		return nil, errors.New("invalid MaxMind DB: data pointer out of range")
+	}
+	d := decoder{buf: r.dataSection}
+	value, _, err := d.decode(offset, 0)
+	if err != nil {
+		return nil, err
+	}
+	record, _ := value.(map[string]interface{})
+	return record, nil
+}
+
+// readNode возвращает левую (bit = 0) или правую запись узла дерева поиска
+func (r *Reader) readNode(node, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		b := r.tree[off : off+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		off := node * 7
+		b := r.tree[off : off+7]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(r.tree[off : off+4]))
+	}
+}
+
+// maxDecodeDepth ограничивает вложенность данных, чтобы поврежденная база не вызвала переполнение стека
+const maxDecodeDepth = 32
+
+// decoder разбирает секцию данных MaxMind DB
+type decoder struct {
+	buf []byte
+}
+
+// decode разбирает значение по смещению offset и возвращает его и смещение следующего значения
+func (d *decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, errors.New("data structure is too deep")
+	}
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, errors.New("unexpected end of data")
+	}
+
+	ctrl := d.buf[offset]
+	offset++
+	typeNum := uint(ctrl >> 5)
+
+	if typeNum == typePointer {
+		pointer, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(pointer, depth+1)
+		return value, next, err
+	}
+
+	if typeNum == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errors.New("unexpected end of data")
+		}
+		typeNum = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size, offset, err := d.size(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch typeNum {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var key, value interface{}
+			if key, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			if value, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			m[k] = value
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			var value interface{}
+			if value, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errors.New("unexpected end of data")
+	}
+	b := d.buf[offset : offset+size]
+	next := offset + size
+
+	switch typeNum {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes, typeUint128:
+		return append([]byte(nil), b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("invalid double size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("invalid float size")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, errors.New("invalid integer size")
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, errors.New("invalid integer size")
+		}
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), next, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", typeNum)
+	}
+}
+
+// size разбирает размер значения из управляющего байта и следующих за ним байтов
+func (d *decoder) size(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1F)
+	if size < 29 {
+		return size, offset, nil
+	}
+
+	n := size - 28
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errors.New("unexpected end of data")
+	}
+	var v uint
+	for _, c := range d.buf[offset : offset+n] {
+		v = v<<8 | uint(c)
+	}
+	switch size {
+	case 29:
+		size = 29 + v
+	case 30:
+		size = 285 + v
+	default:
+		size = 65821 + v
+	}
+	return size, offset + n, nil
+}
+
+// pointer разбирает указатель на значение в секции данных
+func (d *decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint((ctrl>>3)&0x3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errors.New("unexpected end of data")
+	}
+	b := d.buf[offset : offset+n]
+
+	var v uint
+	if n < 4 {
+		v = uint(ctrl & 0x7)
+	}
+	for _, c := range b {
+		v = v<<8 | uint(c)
+	}
+	switch n {
+	case 2:
+		v += 2048
+	case 3:
+		v += 526336
+	}
+	return v, offset + n, nil
+}
+
+// toUint приводит целое значение из базы к uint64
+func toUint(v interface{}) uint64 {
+	switch v := v.(type) {
+	case uint64:
+		return v
+	case int64:
+		if v >= 0 {
+			return uint64(v)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// FromZap - адаптер, записывающий сообщения в готовый логгер zap приложения
+func FromZap(l *zap.Logger) Logger {
+	return &zapLogger{logger: l.WithOptions(zap.AddCallerSkip(1)).Sugar()}
+}
+
+// zapLogger - Logger поверх zap.SugaredLogger
+type zapLogger struct {
+	logger *zap.SugaredLogger
+}
+
+// Debug - запись отладочного сообщения
+func (l *zapLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.logger.Debugw(msg, keysAndValues...)
+}
+
+// Info - запись информационного сообщения
+func (l *zapLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Infow(msg, keysAndValues...)
+}
+
+// Warn - запись предупреждения
+func (l *zapLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.logger.Warnw(msg, keysAndValues...)
+}
+
+// Error - запись ошибки
+func (l *zapLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.logger.Errorw(msg, keysAndValues...)
+}
+
+// DebugEnabled - проверяет, включен ли уровень debug в zap
+func (l *zapLogger) DebugEnabled() bool {
+	return l.logger.Desugar().Core().Enabled(zapcore.DebugLevel)
+}
+
+// FromSlog - адаптер, записывающий сообщения в логгер log/slog
+func FromSlog(l *slog.Logger) Logger {
+	return &slogLogger{logger: l}
+}
+
+// slogLogger - Logger поверх slog.Logger
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// Debug - запись отладочного сообщения
+func (l *slogLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.logger.Debug(msg, keysAndValues...)
+}
+
+// Info - запись информационного сообщения
+func (l *slogLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Info(msg, keysAndValues...)
+}
+
+// Warn - запись предупреждения
+func (l *slogLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.logger.Warn(msg, keysAndValues...)
+}
+
+// Error - запись ошибки
+func (l *slogLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.logger.Error(msg, keysAndValues...)
+}
+
+// DebugEnabled - проверяет, включен ли уровень debug в обработчике slog
+func (l *slogLogger) DebugEnabled() bool {
+	return l.logger.Enabled(context.Background(), slog.LevelDebug)
+}
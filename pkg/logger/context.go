@@ -0,0 +1,70 @@
+package logger
+
+import "context"
+
+// contextKey - ключ логгера в контексте
+type contextKey struct{}
+
+// With - возвращает логгер, добавляющий пары ключ-значение keysAndValues к каждому
+// сообщению. Поля запроса (идентификатор, пользователь, бэкенд) добавляются один раз,
+// а не форматируются в каждое сообщение.
+func With(l Logger, keysAndValues ...interface{}) Logger {
+	if len(keysAndValues) == 0 {
+		return l
+	}
+	if w, ok := l.(*fieldsLogger); ok {
+		fields := make([]interface{}, 0, len(w.fields)+len(keysAndValues))
+		fields = append(append(fields, w.fields...), keysAndValues...)
+		return &fieldsLogger{Logger: w.Logger, fields: fields}
+	}
+	return &fieldsLogger{Logger: l, fields: keysAndValues}
+}
+
+// WithContext - сохраняет логгер в контексте
+func WithContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext - возвращает логгер, сохраненный в контексте WithContext, или fallback,
+// если контекст не содержит логгера
+func FromContext(ctx context.Context, fallback Logger) Logger {
+	if l, ok := ctx.Value(contextKey{}).(Logger); ok {
+		return l
+	}
+	return fallback
+}
+
+// fieldsLogger - логгер с полями, добавляемыми к каждому сообщению
+type fieldsLogger struct {
+	Logger
+	fields []interface{}
+}
+
+// pairs - поля логгера, за которыми следуют поля сообщения
+func (l *fieldsLogger) pairs(keysAndValues []interface{}) []interface{} {
+	if len(keysAndValues) == 0 {
+		return l.fields
+	}
+	pairs := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	return append(append(pairs, l.fields...), keysAndValues...)
+}
+
+// Debug - запись отладочного сообщения с полями логгера
+func (l *fieldsLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.Logger.Debug(msg, l.pairs(keysAndValues)...)
+}
+
+// Info - запись информационного сообщения с полями логгера
+func (l *fieldsLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.Logger.Info(msg, l.pairs(keysAndValues)...)
+}
+
+// Warn - запись предупреждения с полями логгера
+func (l *fieldsLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.Logger.Warn(msg, l.pairs(keysAndValues)...)
+}
+
+// Error - запись ошибки с полями логгера
+func (l *fieldsLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.Logger.Error(msg, l.pairs(keysAndValues)...)
+}
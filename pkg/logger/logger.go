@@ -1,16 +1,54 @@
 package logger
 
 import (
+	"cloud.ru_test/internal/metrics"
 	"fmt"
 	"github.com/fatih/color"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// LogFilePath - путь к файлу, в который пишутся логи приложения
+const LogFilePath = "logs/app.log"
+
+// Logger - интерфейс логгера, который принимают компоненты балансировщика. Кроме
+// CustomZapLogger его реализуют адаптеры FromZap и FromSlog, так что приложение,
+// встраивающее балансировщик, может подключить свой логгер.
+//
+// keysAndValues - необязательные пары ключ-значение, как в log/slog.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+
+	// DebugEnabled - проверяет, записываются ли отладочные сообщения. На горячем пути
+	// сообщение формируется только после этой проверки.
+	DebugEnabled() bool
+}
+
 // CustomZapLogger - структура для логгера
 type CustomZapLogger struct {
-	logger *zap.Logger
+	logger atomic.Pointer[zap.Logger]
+	level  zap.AtomicLevel
+
+	// Выборка отладочных сообщений и сообщений уровней info и warn
+	debugSampler sampler
+	sampler      sampler
+
+	// Вывод сообщений в консоль цветом (только с назначениями записи по умолчанию)
+	console atomic.Bool
+
+	// Поля, добавляемые к сообщениям в формате json
+	serviceName string
+	global      []zapcore.Field
+
+	mu      sync.Mutex
+	outputs *outputs
 }
 
 // LoggerConfig - конфигурация для логгера
@@ -19,114 +57,320 @@ type LoggerConfig struct {
 	NodeIP      string
 	PodIP       string
 	ServiceName string
+
+	// Выборка отладочных сообщений и сообщений уровней info и warn.
+	// Сообщения уровней error и fatal записываются всегда.
+	DebugSampling Sampling
+	Sampling      Sampling
+
+	// Назначения записи сообщений. Пустой список — консоль и файл LogFilePath.
+	Outputs []OutputConfig
 }
 
 // NewCustomZapLogger - конструктор для создания нового логгера
 func NewCustomZapLogger(cfg *LoggerConfig) *CustomZapLogger {
-	// Настройка минимального уровня логирования
-	var level zapcore.Level
-	switch cfg.LogLevel {
-	case "debug":
-		level = zapcore.DebugLevel
-	case "info":
-		level = zapcore.InfoLevel
-	case "warn":
-		level = zapcore.WarnLevel
-	case "error":
-		level = zapcore.ErrorLevel
-	case "fatal":
-		level = zapcore.FatalLevel
-	default:
-		level = zapcore.InfoLevel
+	// Настройка минимального уровня логирования (может меняться без пересоздания логгера)
+	l := &CustomZapLogger{
+		level:       zap.NewAtomicLevelAt(parseLevel(cfg.LogLevel)),
+		serviceName: cfg.ServiceName,
+		global: []zapcore.Field{
+			zap.String("NodeIP", cfg.NodeIP),
+			zap.String("PodIP", cfg.PodIP),
+			zap.String("ServiceName", cfg.ServiceName),
+		},
 	}
-
-	// Проверка существования директории "logs"
-	if _, err := os.Stat("logs"); os.IsNotExist(err) {
-		if err := os.MkdirAll("logs", 0755); err != nil {
-			panic(fmt.Sprintf("unable to create logs directory: %v", err))
-		}
+	if err := l.SetOutputs(cfg.Outputs); err != nil {
+		panic(fmt.Sprintf("unable to open log outputs: %v", err))
 	}
+	l.SetSampling(cfg.DebugSampling, cfg.Sampling)
+	return l
+}
+
+// NewNop - создает логгер, отбрасывающий все сообщения (например, для тестов)
+func NewNop() *CustomZapLogger {
+	l := &CustomZapLogger{level: zap.NewAtomicLevel()}
+	l.logger.Store(zap.NewNop())
+	l.console.Store(true)
+	return l
+}
 
-	// Конфигурация для записи в файл
-	fileEncoderConfig := zap.NewProductionEncoderConfig()
-	fileEncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder // Читаемый формат времени
-	fileEncoder := zapcore.NewJSONEncoder(fileEncoderConfig)
+// SetOutputs - открывает новые назначения записи и переключает на них логгер, после
+// чего закрывает прежние. Вызывается при изменении конфигурации: файлы открываются
+// заново, так что после внешней ротации запись продолжается в новый файл. При ошибке
+// логгер продолжает писать в прежние назначения.
+func (l *CustomZapLogger) SetOutputs(cfgs []OutputConfig) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	file, err := os.OpenFile("logs/app.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	console := len(cfgs) == 0
+	if console {
+		cfgs = defaultOutputs
+	}
+	opened, err := openOutputs(cfgs, l.level, l.serviceName, l.global)
 	if err != nil {
-		panic(fmt.Sprintf("unable to open log file: %v", err))
+		return err
 	}
-	fileWriter := zapcore.AddSync(file)
 
-	// Конфигурация для консоли
-	consoleEncoderConfig := zap.NewDevelopmentEncoderConfig()
-	consoleEncoderConfig.EncodeCaller = zapcore.ShortCallerEncoder // Добавляем источник лога (файл и строка)
-	consoleEncoder := zapcore.NewConsoleEncoder(consoleEncoderConfig)
-	consoleWriter := zapcore.AddSync(os.Stdout)
+	l.logger.Store(zap.New(opened.core, zap.AddCaller(), zap.AddCallerSkip(1)))
+	l.console.Store(console)
+	if previous := l.outputs; previous != nil {
+		// Сообщения, начатые до переключения, дописываются в прежние назначения
+		time.AfterFunc(outputCloseDelay, previous.close)
+	}
+	l.outputs = opened
+	return nil
+}
 
-	// Создаем Core для файла (с глобальными полями)
-	fileCore := zapcore.NewCore(fileEncoder, fileWriter, level).With(
-		[]zapcore.Field{
-			zap.String("NodeIP", cfg.NodeIP),
-			zap.String("PodIP", cfg.PodIP),
-			zap.String("ServiceName", cfg.ServiceName),
-		},
-	)
+// Sync - сбрасывает на диск сообщения, записанные в файлы. Вызывается при остановке
+// приложения, чтобы последние сообщения не потерялись.
+func (l *CustomZapLogger) Sync() error {
+	return l.logger.Load().Sync()
+}
+
+// LogFile - возвращает путь к файлу, в который пишутся сообщения в формате json,
+// или пустую строку, если такого файла нет
+func (l *CustomZapLogger) LogFile() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.outputs == nil {
+		return ""
+	}
+	return l.outputs.jsonFile
+}
+
+// outputCloseDelay - задержка закрытия прежних назначений записи после переключения
+const outputCloseDelay = time.Second
+
+// parseLevel - преобразует уровень логирования из конфигурации, по умолчанию info
+func parseLevel(logLevel string) zapcore.Level {
+	switch logLevel {
+	case "debug":
+		return zapcore.DebugLevel
+	case "info":
+		return zapcore.InfoLevel
+	case "warn":
+		return zapcore.WarnLevel
+	case "error":
+		return zapcore.ErrorLevel
+	case "fatal":
+		return zapcore.FatalLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
 
-	// Создаем Core для консоли (без глобальных полей)
-	consoleCore := zapcore.NewCore(consoleEncoder, consoleWriter, level)
+// SetLevel - изменяет минимальный уровень логирования на лету
+func (l *CustomZapLogger) SetLevel(logLevel string) {
+	l.level.SetLevel(parseLevel(logLevel))
+}
 
-	// Комбинированный логгер
-	logger := zap.New(zapcore.NewTee(fileCore, consoleCore), zap.AddCaller(), zap.AddCallerSkip(1))
+// SetSampling - изменяет выборку отладочных сообщений и сообщений уровней info и warn на лету
+func (l *CustomZapLogger) SetSampling(debug, other Sampling) {
+	l.debugSampler.set(debug)
+	l.sampler.set(other)
+}
 
-	return &CustomZapLogger{logger: logger}
+// DebugEnabled - проверяет, записываются ли отладочные сообщения. На горячем пути
+// сообщение формируется только после этой проверки, чтобы при уровне info
+// не тратить время и память на fmt.Sprintf.
+func (l *CustomZapLogger) DebugEnabled() bool {
+	return l.level.Enabled(zapcore.DebugLevel)
 }
 
 // Debug - обертка для лога уровня Debug
-func (l *CustomZapLogger) Debug(msg string, fields ...zap.Field) {
-	color.Set(color.FgCyan)
-	defer color.Unset()
-	fmt.Println("[DEBUG] " + msg)
-	l.logger.Debug(msg, fields...)
+func (l *CustomZapLogger) Debug(msg string, keysAndValues ...interface{}) {
+	if !l.DebugEnabled() {
+		return
+	}
+	if !l.debugSampler.allow(msg) {
+		droppedLogs.Inc("debug")
+		return
+	}
+	if l.console.Load() {
+		color.Set(color.FgCyan)
+		fmt.Println("[DEBUG] " + msg + formatPairs(keysAndValues))
+		color.Unset()
+	}
+	l.logger.Load().Debug(msg, zapFields(keysAndValues)...)
 }
 
 // Info - обертка для лога уровня Info
-func (l *CustomZapLogger) Info(msg string, fields ...zap.Field) {
-	color.Set(color.FgGreen)
-	defer color.Unset()
-	fmt.Println("[INFO] " + msg)
-	l.logger.Info(msg, fields...)
+func (l *CustomZapLogger) Info(msg string, keysAndValues ...interface{}) {
+	if !l.level.Enabled(zapcore.InfoLevel) {
+		return
+	}
+	if !l.sampler.allow(msg) {
+		droppedLogs.Inc("info")
+		return
+	}
+	if l.console.Load() {
+		color.Set(color.FgGreen)
+		fmt.Println("[INFO] " + msg + formatPairs(keysAndValues))
+		color.Unset()
+	}
+	l.logger.Load().Info(msg, zapFields(keysAndValues)...)
 }
 
 // Warn - обертка для лога уровня Warn
-func (l *CustomZapLogger) Warn(msg string, fields ...zap.Field) {
-	color.Set(color.FgYellow)
-	defer color.Unset()
-	fmt.Println("[WARN] " + msg)
-	l.logger.Warn(msg, fields...)
+func (l *CustomZapLogger) Warn(msg string, keysAndValues ...interface{}) {
+	if !l.level.Enabled(zapcore.WarnLevel) {
+		return
+	}
+	if !l.sampler.allow(msg) {
+		droppedLogs.Inc("warn")
+		return
+	}
+	if l.console.Load() {
+		color.Set(color.FgYellow)
+		fmt.Println("[WARN] " + msg + formatPairs(keysAndValues))
+		color.Unset()
+	}
+	l.logger.Load().Warn(msg, zapFields(keysAndValues)...)
 }
 
 // Error - обертка для лога уровня Error
-func (l *CustomZapLogger) Error(msg string, fields ...zap.Field) {
-	color.Set(color.FgRed)
-	defer color.Unset()
-	fmt.Println("[ERROR] " + msg)
-	l.logger.Error(msg, fields...)
+func (l *CustomZapLogger) Error(msg string, keysAndValues ...interface{}) {
+	if !l.level.Enabled(zapcore.ErrorLevel) {
+		return
+	}
+	if l.console.Load() {
+		color.Set(color.FgRed)
+		fmt.Println("[ERROR] " + msg + formatPairs(keysAndValues))
+		color.Unset()
+	}
+	l.logger.Load().Error(msg, zapFields(keysAndValues)...)
 }
 
 // Fatal - обертка для лога уровня Fatal
-func (l *CustomZapLogger) Fatal(msg string, fields ...zap.Field) {
-	color.Set(color.FgHiRed)
-	defer color.Unset()
-	fmt.Println("[FATAL] " + msg)
-	l.logger.Fatal(msg, fields...)
+func (l *CustomZapLogger) Fatal(msg string, keysAndValues ...interface{}) {
+	if l.console.Load() {
+		color.Set(color.FgHiRed)
+		fmt.Println("[FATAL] " + msg + formatPairs(keysAndValues))
+		color.Unset()
+	}
+	l.logger.Load().Fatal(msg, zapFields(keysAndValues)...)
 }
 
 // Printf - форматированный вывод в консоль и лог
 func (l *CustomZapLogger) Printf(format string, args ...interface{}) {
+	if !l.level.Enabled(zapcore.InfoLevel) {
+		return
+	}
 	msg := fmt.Sprintf(format, args...)
-	color.Set(color.FgMagenta)
-	defer color.Unset()
-	fmt.Println("[INFO] " + msg)
-	l.logger.Info(msg)
-}
\ No newline at end of file
+	if !l.sampler.allow(msg) {
+		droppedLogs.Inc("info")
+		return
+	}
+	if l.console.Load() {
+		color.Set(color.FgMagenta)
+		fmt.Println("[INFO] " + msg)
+		color.Unset()
+	}
+	l.logger.Load().Info(msg)
+}
+
+// zapFields - преобразует пары ключ-значение в поля zap
+func zapFields(keysAndValues []interface{}) []zap.Field {
+	if len(keysAndValues) == 0 {
+		return nil
+	}
+	fields := make([]zap.Field, 0, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		if i+1 == len(keysAndValues) {
+			fields = append(fields, zap.Any("!BADKEY", keysAndValues[i]))
+			break
+		}
+		fields = append(fields, zap.Any(key, keysAndValues[i+1]))
+	}
+	return fields
+}
+
+// formatPairs - форматирует пары ключ-значение для вывода в консоль
+func formatPairs(keysAndValues []interface{}) string {
+	if len(keysAndValues) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 == len(keysAndValues) {
+			fmt.Fprintf(&sb, " %v", keysAndValues[i])
+			break
+		}
+		fmt.Fprintf(&sb, " %v=%v", keysAndValues[i], keysAndValues[i+1])
+	}
+	return sb.String()
+}
+
+// droppedLogs - счетчик сообщений, отброшенных выборкой
+var droppedLogs = metrics.Default.Counter("lb_log_dropped_total", "Log messages dropped by sampling", "level")
+
+// Sampling - выборка сообщений: каждую секунду записываются первые Initial сообщений,
+// затем каждое Thereafter-е (0 — остальные отбрасываются). При Initial = 0 выборка отключена.
+type Sampling struct {
+	Initial    int
+	Thereafter int
+
+	// Сообщения считаются отдельно для каждого текста: частое сообщение
+	// не вытесняет редкие
+	PerMessage bool
+}
+
+// samplerWindows - количество окон выборки по тексту сообщения. Сообщения,
+// тексты которых попали в одно окно, считаются вместе.
+const samplerWindows = 1024
+
+// sampler - выборка сообщений по секундным окнам без блокировок и выделений памяти
+type sampler struct {
+	initial    atomic.Int64
+	thereafter atomic.Int64
+	perMessage atomic.Bool
+
+	// Общее окно и окна по тексту сообщения
+	samplerWindow
+	windows [samplerWindows]samplerWindow
+}
+
+// samplerWindow - секунда текущего окна и число сообщений в нем
+type samplerWindow struct {
+	window atomic.Int64
+	count  atomic.Int64
+}
+
+// set - задает параметры выборки
+func (s *sampler) set(cfg Sampling) {
+	s.initial.Store(int64(cfg.Initial))
+	s.thereafter.Store(int64(cfg.Thereafter))
+	s.perMessage.Store(cfg.PerMessage)
+}
+
+// allow - проверяет, нужно ли записать очередное сообщение msg
+func (s *sampler) allow(msg string) bool {
+	initial := s.initial.Load()
+	if initial <= 0 {
+		return true
+	}
+
+	w := &s.samplerWindow
+	if s.perMessage.Load() {
+		// FNV-1a без выделения памяти
+		hash := uint32(2166136261)
+		for i := 0; i < len(msg); i++ {
+			hash ^= uint32(msg[i])
+			hash *= 16777619
+		}
+		w = &s.windows[hash%samplerWindows]
+	}
+
+	now := time.Now().Unix()
+	if window := w.window.Load(); window != now && w.window.CompareAndSwap(window, now) {
+		w.count.Store(0)
+	}
+	n := w.count.Add(1)
+	if n <= initial {
+		return true
+	}
+	thereafter := s.thereafter.Load()
+	return thereafter > 0 && (n-initial)%thereafter == 0
+}
@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCustomZapLogger_DebugEnabled(t *testing.T) {
+	l := NewNop()
+	if l.DebugEnabled() {
+		t.Error("при уровне info отладочные сообщения не должны записываться")
+	}
+	l.SetLevel("debug")
+	if !l.DebugEnabled() {
+		t.Error("при уровне debug отладочные сообщения должны записываться")
+	}
+}
+
+func TestSampler(t *testing.T) {
+	var s sampler
+	for i := 0; i < 10; i++ {
+		if !s.allow("") {
+			t.Fatal("без выборки должны записываться все сообщения")
+		}
+	}
+
+	s.set(Sampling{Initial: 3, Thereafter: 5})
+	allowed := 0
+	for i := 0; i < 23; i++ {
+		if s.allow("") {
+			allowed++
+		}
+	}
+	// Первые 3 сообщения и каждое 5-е из следующих 20
+	if allowed != 7 {
+		t.Errorf("записано %d сообщений из 23, ожидалось 7", allowed)
+	}
+
+	s.set(Sampling{Initial: 1})
+	s.window.Store(0)
+	s.allow("")
+	if s.allow("") {
+		t.Error("при thereafter = 0 сообщения сверх initial должны отбрасываться")
+	}
+
+	// При выборке по тексту частое сообщение не вытесняет редкие
+	s.set(Sampling{Initial: 2, PerMessage: true})
+	for i := 0; i < 10; i++ {
+		s.allow("frequent")
+	}
+	if !s.allow("rare") || s.allow("frequent") {
+		t.Error("сообщения с разным текстом должны считаться отдельно")
+	}
+}
+
+func TestCustomZapLogger_DroppedCounter(t *testing.T) {
+	l := NewNop()
+	l.SetSampling(Sampling{}, Sampling{Initial: 1})
+	before := droppedLogs.Value("warn")
+	for i := 0; i < 3; i++ {
+		l.Warn("backend is slow")
+	}
+	if dropped := droppedLogs.Value("warn") - before; dropped != 2 {
+		t.Errorf("отброшено %d сообщений, ожидалось 2", dropped)
+	}
+}
+
+func TestFromSlog(t *testing.T) {
+	var buf bytes.Buffer
+	var l Logger = FromSlog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	if l.DebugEnabled() {
+		t.Error("при уровне info отладочные сообщения не должны записываться")
+	}
+	l.Warn("backend ejected", "backend", "backend1", "errors", 5)
+	if out := buf.String(); !strings.Contains(out, "level=WARN") || !strings.Contains(out, "backend=backend1 errors=5") {
+		t.Errorf("сообщение должно записываться с парами ключ-значение: %q", out)
+	}
+}
+
+func TestFromZap(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := FromZap(zap.New(core))
+	if !l.DebugEnabled() {
+		t.Error("при уровне debug отладочные сообщения должны записываться")
+	}
+	l.Error("request failed", "status", 502)
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].Message != "request failed" || entries[0].ContextMap()["status"] != int64(502) {
+		t.Errorf("сообщение должно записываться в zap с полями: %+v", entries)
+	}
+}
+
+func TestWith_Context(t *testing.T) {
+	var buf bytes.Buffer
+	base := FromSlog(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	ctx := context.Background()
+	if FromContext(ctx, base) != base {
+		t.Error("без логгера в контексте должен возвращаться fallback")
+	}
+	ctx = WithContext(ctx, With(With(base, "requestID", "r1"), "backend", "b1"))
+	FromContext(ctx, base).Info("done", "status", 200)
+	if out := buf.String(); !strings.Contains(out, "requestID=r1 backend=b1 status=200") {
+		t.Errorf("сообщение должно содержать поля логгера из контекста: %q", out)
+	}
+}
@@ -0,0 +1,197 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Типы назначений записи логов
+const (
+	OutputStdout   = "stdout"
+	OutputStderr   = "stderr"
+	OutputFile     = "file"
+	OutputSyslog   = "syslog"
+	OutputJournald = "journald"
+)
+
+// Форматы записи логов
+const (
+	EncodingJSON    = "json"
+	EncodingConsole = "console"
+)
+
+// OutputConfig - назначение записи логов
+type OutputConfig struct {
+	// Тип: stdout, stderr, file, syslog, journald
+	Type string
+
+	// Формат: json или console. По умолчанию console для stdout и stderr, json для остальных.
+	Encoding string
+
+	// Путь к файлу (для file)
+	Path string
+
+	// Ротация файла: максимальный размер в мегабайтах (0 — без ротации), срок хранения
+	// и количество архивных файлов (0 — без ограничения), сжатие архивных файлов gzip
+	MaxSizeMB  int
+	MaxAge     time.Duration
+	MaxBackups int
+	Compress   bool
+
+	// Адрес syslog: сеть (udp, tcp; пустая — локальный syslog) и адрес
+	Network string
+	Address string
+
+	// Идентификатор приложения в syslog и journald (по умолчанию имя сервиса)
+	Tag string
+}
+
+// defaultOutputs - назначения записи по умолчанию: консоль и файл LogFilePath
+var defaultOutputs = []OutputConfig{
+	{Type: OutputFile, Path: LogFilePath, Encoding: EncodingJSON},
+	{Type: OutputStdout, Encoding: EncodingConsole},
+}
+
+// outputs - открытые назначения записи логов
+type outputs struct {
+	core    zapcore.Core
+	closers []io.Closer
+
+	// Первый файл с сообщениями в формате json
+	jsonFile string
+}
+
+// close - закрывает файлы и соединения назначений
+func (o *outputs) close() {
+	for _, c := range o.closers {
+		c.Close()
+	}
+}
+
+// openOutputs - открывает назначения записи. Поля global добавляются к сообщениям
+// в формате json: в консоли они только мешают читать.
+func openOutputs(cfgs []OutputConfig, level zapcore.LevelEnabler, serviceName string, global []zapcore.Field) (*outputs, error) {
+	o := &outputs{}
+	cores := make([]zapcore.Core, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		encoding := cfg.Encoding
+		if encoding == "" {
+			encoding = EncodingJSON
+			if cfg.Type == OutputStdout || cfg.Type == OutputStderr {
+				encoding = EncodingConsole
+			}
+		}
+		encoder, err := newEncoder(encoding)
+		if err != nil {
+			o.close()
+			return nil, err
+		}
+
+		var core zapcore.Core
+		switch cfg.Type {
+		case OutputStdout:
+			core = zapcore.NewCore(encoder, zapcore.Lock(os.Stdout), level)
+		case OutputStderr:
+			core = zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), level)
+		case OutputFile:
+			file, err := newRotatingFile(cfg)
+			if err != nil {
+				o.close()
+				return nil, err
+			}
+			o.closers = append(o.closers, file)
+			core = zapcore.NewCore(encoder, file, level)
+			if encoding == EncodingJSON && o.jsonFile == "" {
+				o.jsonFile = cfg.Path
+			}
+		case OutputSyslog, OutputJournald:
+			tag := cfg.Tag
+			if tag == "" {
+				tag = serviceName
+			}
+			sink, err := newSystemSink(cfg.Type, cfg.Network, cfg.Address, tag)
+			if err != nil {
+				o.close()
+				return nil, err
+			}
+			o.closers = append(o.closers, sink)
+			core = &sinkCore{LevelEnabler: level, encoder: encoder, sink: sink}
+		default:
+			o.close()
+			return nil, fmt.Errorf("unsupported log output type: %s", cfg.Type)
+		}
+
+		if encoding == EncodingJSON {
+			core = core.With(global)
+		}
+		cores = append(cores, core)
+	}
+	o.core = zapcore.NewTee(cores...)
+	return o, nil
+}
+
+// newEncoder - создает кодировщик сообщений
+func newEncoder(encoding string) (zapcore.Encoder, error) {
+	switch encoding {
+	case EncodingJSON:
+		cfg := zap.NewProductionEncoderConfig()
+		cfg.EncodeTime = zapcore.ISO8601TimeEncoder // Читаемый формат времени
+		return zapcore.NewJSONEncoder(cfg), nil
+	case EncodingConsole:
+		cfg := zap.NewDevelopmentEncoderConfig()
+		cfg.EncodeCaller = zapcore.ShortCallerEncoder // Добавляем источник лога (файл и строка)
+		return zapcore.NewConsoleEncoder(cfg), nil
+	default:
+		return nil, fmt.Errorf("unsupported log encoding: %s", encoding)
+	}
+}
+
+// systemSink - системный журнал, принимающий сообщения с уровнем
+type systemSink interface {
+	io.Closer
+	write(level zapcore.Level, msg string) error
+}
+
+// sinkCore - zapcore.Core, записывающий сообщения в системный журнал с приоритетом по уровню
+type sinkCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	sink    systemSink
+}
+
+// With - добавляет поля ко всем сообщениям
+func (c *sinkCore) With(fields []zapcore.Field) zapcore.Core {
+	encoder := c.encoder.Clone()
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+	return &sinkCore{LevelEnabler: c.LevelEnabler, encoder: encoder, sink: c.sink}
+}
+
+// Check - добавляет core к записи сообщения, если уровень включен
+func (c *sinkCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write - кодирует сообщение и отправляет его в журнал
+func (c *sinkCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+	return c.sink.write(entry.Level, buf.String())
+}
+
+// Sync - журнал не буферизует сообщения
+func (c *sinkCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,209 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat - формат времени в имени архивного файла лога
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotatingFile - файл лога с ротацией по размеру. Текущий файл переименовывается
+// в <имя>-<время><расширение>, после чего архивные файлы сверх MaxBackups и старше
+// MaxAge удаляются, а оставшиеся при Compress сжимаются gzip.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	// Очистка архивных файлов выполняется в фоне по одной за раз
+	cleanupMu sync.Mutex
+}
+
+// newRotatingFile - открывает файл лога, создавая директорию при необходимости
+func newRotatingFile(cfg OutputConfig) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       cfg.Path,
+		maxSize:    int64(cfg.MaxSizeMB) * 1024 * 1024,
+		maxAge:     cfg.MaxAge,
+		maxBackups: cfg.MaxBackups,
+		compress:   cfg.Compress,
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return nil, fmt.Errorf("unable to create log directory: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open - открывает текущий файл лога на дозапись
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open log file %s: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("unable to stat log file %s: %w", f.path, err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write - записывает сообщение, предварительно выполняя ротацию, если файл превысит MaxSize
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, fmt.Errorf("log file %s is closed", f.path)
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate - переименовывает текущий файл в архивный и открывает новый
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("unable to close log file %s: %w", f.path, err)
+	}
+	f.file = nil
+
+	ext := filepath.Ext(f.path)
+	backup := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(f.path, ext), time.Now().Format(backupTimeFormat), ext)
+	if err := os.Rename(f.path, backup); err != nil {
+		return fmt.Errorf("unable to rotate log file %s: %w", f.path, err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	go f.cleanup()
+	return nil
+}
+
+// backups - возвращает архивные файлы лога, от новых к старым
+func (f *rotatingFile) backups() ([]string, error) {
+	ext := filepath.Ext(f.path)
+	prefix := filepath.Base(strings.TrimSuffix(f.path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			names = append(names, name)
+		}
+	}
+	// Время в имени упорядочивается лексикографически
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, nil
+}
+
+// cleanup - удаляет лишние и устаревшие архивные файлы и сжимает оставшиеся
+func (f *rotatingFile) cleanup() {
+	f.cleanupMu.Lock()
+	defer f.cleanupMu.Unlock()
+
+	names, err := f.backups()
+	if err != nil {
+		return
+	}
+	dir := filepath.Dir(f.path)
+	for i, name := range names {
+		path := filepath.Join(dir, name)
+		expired := f.maxBackups > 0 && i >= f.maxBackups
+		if !expired && f.maxAge > 0 {
+			if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > f.maxAge {
+				expired = true
+			}
+		}
+		switch {
+		case expired:
+			os.Remove(path)
+		case f.compress && !strings.HasSuffix(name, ".gz"):
+			compressFile(path)
+		}
+	}
+}
+
+// compressFile - сжимает файл gzip и удаляет исходный
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Sync - сбрасывает данные файла на диск
+func (f *rotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+// Close - закрывает файл; последующие записи возвращают ошибку
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := newRotatingFile(OutputConfig{Path: path, MaxSizeMB: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	line := []byte(strings.Repeat("x", 1023) + "\n")
+	for i := 0; i < 4*1024+10; i++ {
+		if _, err := f.Write(line); err != nil {
+			t.Fatal(err)
+		}
+		// Имена архивных файлов различаются временем с точностью до миллисекунды
+		if i%1024 == 1023 {
+			time.Sleep(2 * time.Millisecond)
+		}
+	}
+
+	var backups []string
+	deadline := time.Now().Add(time.Second)
+	for {
+		f.cleanupMu.Lock()
+		backups, err = f.backups()
+		f.cleanupMu.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(backups) <= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(backups) != 2 {
+		t.Errorf("должно остаться 2 архивных файла, найдено: %v", backups)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() > 1024*1024 {
+		t.Errorf("текущий файл не должен превышать maxSize: %v", err)
+	}
+}
+
+func TestCustomZapLogger_SetOutputs(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+
+	l := NewCustomZapLogger(&LoggerConfig{
+		LogLevel:    "info",
+		ServiceName: "test",
+		Outputs:     []OutputConfig{{Type: OutputFile, Path: first}},
+	})
+	l.Info("first message", "requestID", "r1")
+	if l.LogFile() != first {
+		t.Errorf("LogFile должен возвращать файл json, получено %q", l.LogFile())
+	}
+
+	if err := l.SetOutputs([]OutputConfig{{Type: OutputFile, Path: second, Encoding: EncodingConsole}}); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("second message")
+	if l.LogFile() != "" {
+		t.Errorf("без файла json LogFile должен возвращать пустую строку, получено %q", l.LogFile())
+	}
+	if err := l.SetOutputs([]OutputConfig{{Type: "unknown"}}); err == nil {
+		t.Error("неизвестный тип назначения должен отклоняться")
+	}
+
+	data, _ := os.ReadFile(first)
+	if !strings.Contains(string(data), `"msg":"first message"`) || !strings.Contains(string(data), `"requestID":"r1"`) ||
+		!strings.Contains(string(data), `"ServiceName":"test"`) || strings.Contains(string(data), "second") {
+		t.Errorf("первый файл должен содержать только первое сообщение в json: %s", data)
+	}
+	data, _ = os.ReadFile(second)
+	if !strings.Contains(string(data), "INFO") || !strings.Contains(string(data), "second message") {
+		t.Errorf("после переключения сообщения должны записываться во второй файл: %s", data)
+	}
+}
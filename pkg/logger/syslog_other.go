@@ -0,0 +1,10 @@
+//go:build !unix
+
+package logger
+
+import "fmt"
+
+// newSystemSink - syslog и journald доступны только на unix системах
+func newSystemSink(kind, network, address, tag string) (systemSink, error) {
+	return nil, fmt.Errorf("log output %s is not supported on this platform", kind)
+}
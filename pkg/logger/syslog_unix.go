@@ -0,0 +1,111 @@
+//go:build unix
+
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/syslog"
+	"net"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// journaldSocket - сокет нативного протокола journald
+const journaldSocket = "/run/systemd/journal/socket"
+
+// newSystemSink - подключается к syslog или journald
+func newSystemSink(kind, network, address, tag string) (systemSink, error) {
+	if kind == OutputJournald {
+		conn, err := net.Dial("unixgram", journaldSocket)
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect to journald: %w", err)
+		}
+		return &journaldSink{conn: conn, tag: tag}, nil
+	}
+
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to syslog: %w", err)
+	}
+	return &syslogSink{w: w}, nil
+}
+
+// syslogSink - запись в syslog
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+func (s *syslogSink) write(level zapcore.Level, msg string) error {
+	msg = strings.TrimSuffix(msg, "\n")
+	switch {
+	case level <= zapcore.DebugLevel:
+		return s.w.Debug(msg)
+	case level == zapcore.InfoLevel:
+		return s.w.Info(msg)
+	case level == zapcore.WarnLevel:
+		return s.w.Warning(msg)
+	case level == zapcore.ErrorLevel:
+		return s.w.Err(msg)
+	default:
+		return s.w.Crit(msg)
+	}
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
+
+// journaldSink - запись в journald по нативному протоколу
+type journaldSink struct {
+	conn net.Conn
+	tag  string
+}
+
+func (s *journaldSink) write(level zapcore.Level, msg string) error {
+	var buf bytes.Buffer
+	journalField(&buf, "MESSAGE", strings.TrimSuffix(msg, "\n"))
+	journalField(&buf, "PRIORITY", strconv.Itoa(syslogPriority(level)))
+	journalField(&buf, "SYSLOG_IDENTIFIER", s.tag)
+	_, err := s.conn.Write(buf.Bytes())
+	return err
+}
+
+func (s *journaldSink) Close() error {
+	return s.conn.Close()
+}
+
+// journalField - добавляет поле в сообщение journald. Значения с переводом строки
+// передаются с явной длиной.
+func journalField(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// syslogPriority - приоритет syslog для уровня сообщения
+func syslogPriority(level zapcore.Level) int {
+	switch {
+	case level <= zapcore.DebugLevel:
+		return 7
+	case level == zapcore.InfoLevel:
+		return 6
+	case level == zapcore.WarnLevel:
+		return 4
+	case level == zapcore.ErrorLevel:
+		return 3
+	default:
+		return 2
+	}
+}
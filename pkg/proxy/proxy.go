@@ -0,0 +1,372 @@
+// Package proxy позволяет встроить балансировщик в другое Go приложение без запуска
+// отдельного процесса. Proxy реализует http.Handler и выполняет тот же конвейер
+// auth → ratelimit → rewrite → balance → proxy, что и приложение:
+//
+//	p, err := proxy.New(
+//		proxy.WithBalancer(proxy.LeastConnections),
+//		proxy.WithBackends("http://10.0.0.1:8080", "http://10.0.0.2:8080"),
+//		proxy.WithRateLimiter(100, 200),
+//		proxy.WithMiddleware("auth", proxy.PhaseAuth, requireToken),
+//		proxy.WithConfiguredMiddleware("headers", map[string]interface{}{"set": map[string]string{"X-Via": "lb"}}),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	defer p.Close()
+//	mux.Handle("/api/", p)
+//
+// С WithListener прокси может сам слушать адрес: Start начинает обслуживать запросы,
+// Close дожидается их завершения.
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/healthcheck"
+	"cloud.ru_test/internal/loadbalancer"
+	"cloud.ru_test/internal/loadbalancer/base"
+	"cloud.ru_test/internal/ratelimit"
+	"cloud.ru_test/internal/transport"
+	"cloud.ru_test/pkg/backend"
+	"cloud.ru_test/pkg/logger"
+)
+
+// Методы балансировки
+const (
+	RoundRobin         = "RoundRobin"
+	WeightedRoundRobin = "WeightedRoundRobin"
+	LeastConnections   = "LeastConnections"
+	LeastLoad          = "LeastLoad"
+)
+
+// Balancer алгоритм балансировки. Собственный алгоритм обычно встраивает *BalancerBase,
+// который хранит бэкенды и их статистику, и реализует только Invoke.
+type Balancer = loadbalancer.LoadBalancer
+
+// BalancerBase общая часть алгоритмов балансировки
+type BalancerBase = base.BaseLoadBalancer
+
+// BackendState бэкенд и его статистика в балансировщике
+type BackendState = base.BackendState
+
+// BalancerFactory создает алгоритм балансировки по настройкам секции loadBalancer
+type BalancerFactory = loadbalancer.Factory
+
+// NewBalancerBase создает общую часть алгоритма балансировки
+func NewBalancerBase(l logger.Logger) *BalancerBase {
+	return base.NewBaseLoadBalancer(l)
+}
+
+// RegisterBalancer регистрирует алгоритм балансировки под именем name. Имя становится
+// допустимым в WithBalancer и в loadBalancer.method конфигурации приложения; параметры
+// алгоритма передаются фабрике в loadBalancer.params. Регистрацию выполняют до создания
+// прокси, например в init() пакета с алгоритмом.
+func RegisterBalancer(name string, factory BalancerFactory) {
+	loadbalancer.Register(name, factory)
+}
+
+// RateLimiter ограничитель частоты запросов клиентов. Требования к реализации описаны
+// в ratelimit.RateLimiter: методы вызываются одновременно из разных горутин, Allow — на каждый запрос.
+type RateLimiter = ratelimit.RateLimiter
+
+// UserLimits лимиты отдельного клиента
+type UserLimits = ratelimit.UserLimits
+
+// RateLimiterFactory создает rate limiter по настройкам секции rateLimiter
+type RateLimiterFactory = ratelimit.Factory
+
+// RegisterRateLimiter регистрирует тип rate limiter под именем name. Имя становится
+// допустимым в WithRateLimiterConfig и в rateLimiter.type конфигурации приложения;
+// параметры передаются фабрике в rateLimiter.params.
+func RegisterRateLimiter(name string, factory RateLimiterFactory) {
+	ratelimit.Register(name, factory)
+}
+
+// Phase этап конвейера, в который встраивается middleware
+type Phase = transport.Phase
+
+// Этапы конвейера
+const (
+	PhaseAuth      = transport.PhaseAuth
+	PhaseRateLimit = transport.PhaseRateLimit
+	PhaseRewrite   = transport.PhaseRewrite
+)
+
+// Middleware оборачивает обработчик запросов прокси
+type Middleware func(next http.Handler) http.Handler
+
+// Option настройка прокси
+type Option func(*options) error
+
+// options настройки, собранные из Option
+type options struct {
+	method      string
+	backends    []config.BackendConfig
+	rateLimiter *config.RateLimiterConfig
+	healthCheck *config.HealthCheckConfig
+	middlewares []middlewareOption
+	listener    string
+	logger      logger.Logger
+}
+
+// middlewareOption middleware, заданный кодом (m) или настройками зарегистрированного (cfg)
+type middlewareOption struct {
+	name  string
+	phase Phase
+	m     Middleware
+	cfg   *config.MiddlewareConfig
+}
+
+// WithBalancer задает метод балансировки: встроенный или зарегистрированный RegisterBalancer
+// (по умолчанию RoundRobin)
+func WithBalancer(method string) Option {
+	return func(o *options) error {
+		o.method = method
+		return nil
+	}
+}
+
+// WithBackend добавляет бэкенд с настройками подключения, как в секции backends конфигурации
+func WithBackend(cfg config.BackendConfig) Option {
+	return func(o *options) error {
+		if cfg.ID == "" || cfg.URL == "" {
+			return fmt.Errorf("backend id and url are required")
+		}
+		o.backends = append(o.backends, cfg)
+		return nil
+	}
+}
+
+// WithBackends добавляет бэкенды с настройками по умолчанию. Идентификатором бэкенда служит его адрес.
+func WithBackends(urls ...string) Option {
+	return func(o *options) error {
+		for _, url := range urls {
+			o.backends = append(o.backends, config.BackendConfig{ID: url, URL: url})
+		}
+		return nil
+	}
+}
+
+// WithRateLimiter ограничивает частоту запросов каждого клиента алгоритмом token bucket:
+// rate запросов в секунду с допустимым всплеском burst
+func WithRateLimiter(rate float64, burst int) Option {
+	return func(o *options) error {
+		if rate <= 0 || burst <= 0 {
+			return fmt.Errorf("rate limiter rate and burst must be positive")
+		}
+		o.rateLimiter = &config.RateLimiterConfig{
+			Enabled:     true,
+			Type:        "TokenBucket",
+			TokenBucket: &config.TokenBucketConfig{Rate: rate, Burst: burst},
+		}
+		return nil
+	}
+}
+
+// WithRateLimiterConfig задает rate limiter настройками секции rateLimiter конфигурации,
+// например зарегистрированного RegisterRateLimiter типа
+func WithRateLimiterConfig(cfg config.RateLimiterConfig) Option {
+	return func(o *options) error {
+		if cfg.Type == "" {
+			return fmt.Errorf("rate limiter type is required")
+		}
+		o.rateLimiter = &cfg
+		return nil
+	}
+}
+
+// WithHealthCheck включает активную проверку здоровья бэкендов; бэкенды, не прошедшие
+// проверку, исключаются из балансировки
+func WithHealthCheck(cfg config.HealthCheckConfig) Option {
+	return func(o *options) error {
+		o.healthCheck = &cfg
+		return nil
+	}
+}
+
+// WithMiddleware встраивает middleware m в этап phase. Внутри этапа middleware
+// выполняются в порядке добавления.
+func WithMiddleware(name string, phase Phase, m Middleware) Option {
+	return func(o *options) error {
+		if m == nil {
+			return fmt.Errorf("middleware %s is nil", name)
+		}
+		o.middlewares = append(o.middlewares, middlewareOption{name: name, phase: phase, m: m})
+		return nil
+	}
+}
+
+// WithConfiguredMiddleware подключает встроенный middleware (headers, waf, oidc, ...) с параметрами,
+// как в секции middlewares конфигурации
+func WithConfiguredMiddleware(name string, params map[string]interface{}) Option {
+	return func(o *options) error {
+		o.middlewares = append(o.middlewares, middlewareOption{name: name, cfg: &config.MiddlewareConfig{Name: name, Params: params}})
+		return nil
+	}
+}
+
+// WithListener задает адрес, который прокси слушает после Start, например ":8080"
+func WithListener(addr string) Option {
+	return func(o *options) error {
+		o.listener = addr
+		return nil
+	}
+}
+
+// WithLogger задает логгер прокси, например logger.FromSlog(slog.Default()) или
+// logger.FromZap(zapLogger) (по умолчанию сообщения не выводятся)
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) error {
+		o.logger = l
+		return nil
+	}
+}
+
+// Proxy встраиваемый балансировщик
+type Proxy struct {
+	lb      loadbalancer.LoadBalancer
+	limiter ratelimit.RateLimiter
+	proxy   *transport.Proxy
+	checker *healthcheck.Checker
+	server  *transport.Server
+	addr    string
+	logger  logger.Logger
+}
+
+// New создает прокси. Проверки здоровья, если они включены, начинаются сразу.
+func New(opts ...Option) (*Proxy, error) {
+	o := &options{method: RoundRobin}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	if o.logger == nil {
+		o.logger = logger.NewNop()
+	}
+
+	lb, err := loadbalancer.New(config.LoadBalancerConfig{Method: o.method}, o.logger)
+	if err != nil {
+		return nil, err
+	}
+	limiter, err := ratelimit.New(o.rateLimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := &transport.Chain{}
+	for _, mo := range o.middlewares {
+		if mo.cfg != nil {
+			m, phase, err := transport.NewMiddleware(*mo.cfg, o.logger)
+			if err != nil {
+				return nil, err
+			}
+			chain.Add(mo.name, phase, m)
+		} else {
+			chain.Add(mo.name, mo.phase, transport.Middleware(mo.m))
+		}
+	}
+
+	p := &Proxy{
+		lb:      lb,
+		limiter: limiter,
+		proxy:   transport.NewProxy(lb, limiter, transport.Options{Middlewares: chain}, o.logger),
+		addr:    o.listener,
+		logger:  o.logger,
+	}
+	for _, cfg := range o.backends {
+		if err := p.AddBackend(cfg); err != nil {
+			p.Close()
+			return nil, err
+		}
+	}
+	if o.healthCheck != nil {
+		p.checker = healthcheck.New(o.healthCheck, lb, o.logger)
+		p.checker.Start()
+	}
+	return p, nil
+}
+
+// ServeHTTP передает запрос выбранному бэкенду
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.proxy.ServeHTTP(w, r)
+}
+
+// AddBackend добавляет бэкенд в балансировку
+func (p *Proxy) AddBackend(cfg config.BackendConfig) error {
+	if cfg.ID == "" || cfg.URL == "" {
+		return fmt.Errorf("backend id and url are required")
+	}
+	if p.lb.GetBackend(cfg.ID) != nil {
+		return fmt.Errorf("backend %s already exists", cfg.ID)
+	}
+	p.lb.AddBackend(backend.NewFromConfig(cfg))
+	return nil
+}
+
+// RemoveBackend исключает бэкенд из балансировки и закрывает его
+func (p *Proxy) RemoveBackend(id string) error {
+	state := p.lb.GetBackend(id)
+	if state == nil {
+		return fmt.Errorf("backend %s not found", id)
+	}
+	p.lb.RemoveBackend(state.Backend)
+	return state.Backend.Close()
+}
+
+// Backends возвращает бэкенды прокси
+func (p *Proxy) Backends() []backend.Backend {
+	states := p.lb.GetBackends()
+	backends := make([]backend.Backend, len(states))
+	for i, state := range states {
+		backends[i] = state.Backend
+	}
+	return backends
+}
+
+// Start занимает адрес WithListener и начинает обслуживать запросы в отдельной горутине
+func (p *Proxy) Start() error {
+	if p.addr == "" {
+		return fmt.Errorf("listener address is not set, use WithListener")
+	}
+	if p.server != nil {
+		return fmt.Errorf("proxy is already started")
+	}
+
+	server := transport.NewServer(p.logger)
+	server.SetProxy(p.proxy)
+	if err := server.Start(p.addr); err != nil {
+		return err
+	}
+	p.server = server
+	return nil
+}
+
+// Addr возвращает адрес, который слушает прокси после Start (с фактическим портом при порте 0)
+func (p *Proxy) Addr() string {
+	if p.server == nil {
+		return ""
+	}
+	return p.server.Addr()
+}
+
+// Close останавливает проверки здоровья и, если прокси запущен Start, дожидается
+// завершения текущих запросов, после чего закрывает бэкенды и rate limiter
+func (p *Proxy) Close() error {
+	if p.checker != nil {
+		p.checker.Stop()
+	}
+	var err error
+	if p.server != nil {
+		err = p.server.Stop()
+	}
+	p.lb.Stop(context.Background())
+	for _, state := range p.lb.GetBackends() {
+		state.Backend.Close()
+	}
+	p.limiter.Close()
+	return err
+}
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"cloud.ru_test/config"
+	"cloud.ru_test/internal/ratelimit"
+)
+
+func TestProxy(t *testing.T) {
+	var headers []string
+	newBackend := func(name string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers = append(headers, r.Header.Get("X-Via")+" "+r.Header.Get("X-Auth"))
+			io.WriteString(w, name)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	first, second := newBackend("first"), newBackend("second")
+
+	var order []string
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			order = append(order, "auth")
+			if r.Header.Get("Authorization") == "" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			r.Header.Set("X-Auth", "ok")
+			next.ServeHTTP(w, r)
+		})
+	}
+	p, err := New(
+		WithBackends(first.URL, second.URL),
+		WithRateLimiter(0.001, 2),
+		WithConfiguredMiddleware("headers", map[string]interface{}{"set": map[string]string{"X-Via": "lb"}}),
+		WithMiddleware("auth", PhaseAuth, auth),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	serve := func(authorized bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		if authorized {
+			req.Header.Set("Authorization", "Bearer token")
+		}
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve(false); rec.Code != http.StatusUnauthorized {
+		t.Errorf("middleware этапа auth должен отклонить запрос, получен %d", rec.Code)
+	}
+	if a, b := serve(true).Body.String(), serve(true).Body.String(); a == b {
+		t.Errorf("запросы должны распределяться между бэкендами: %q, %q", a, b)
+	}
+	if len(headers) != 2 || headers[0] != "lb ok" {
+		t.Errorf("бэкенд должен получить заголовки middleware: %v", headers)
+	}
+	// Отклоненный на этапе auth запрос не расходует лимит: два запроса в пределах burst прошли
+	if rec := serve(true); rec.Code != http.StatusTooManyRequests || len(order) != 4 {
+		t.Errorf("запрос сверх лимита должен получить 429, получен %d", rec.Code)
+	}
+
+	if err := p.RemoveBackend(second.URL); err != nil || len(p.Backends()) != 1 {
+		t.Errorf("бэкенд должен удаляться: %v", err)
+	}
+	if err := p.AddBackend(config.BackendConfig{ID: first.URL, URL: first.URL}); err == nil {
+		t.Error("повторное добавление бэкенда должно возвращать ошибку")
+	}
+}
+
+func TestProxy_Start(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer backend.Close()
+
+	p, err := New(WithBackend(config.BackendConfig{ID: "backend", URL: backend.URL}), WithListener("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	resp, err := http.Get("http://" + p.Addr() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("прокси должен обслуживать запросы на адресе listener'а, получено %q", body)
+	}
+}
+
+// countingLimiter пропускает не больше limit запросов каждого клиента
+type countingLimiter struct {
+	ratelimit.NoopRateLimiter
+	mu     sync.Mutex
+	limit  int
+	counts map[string]int
+}
+
+func (c *countingLimiter) Allow(userID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[userID]++
+	return c.counts[userID] <= c.limit
+}
+
+func TestRegisterRateLimiter(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	RegisterRateLimiter("Counting", func(cfg *config.RateLimiterConfig) (RateLimiter, error) {
+		limit, _ := cfg.Params["limit"].(int)
+		return &countingLimiter{limit: limit, counts: make(map[string]int)}, nil
+	})
+	p, err := New(
+		WithBackends(backend.URL),
+		WithRateLimiterConfig(config.RateLimiterConfig{Enabled: true, Type: "Counting", Params: map[string]interface{}{"limit": 2}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	var codes []int
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		codes = append(codes, rec.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("зарегистрированный rate limiter должен ограничивать запросы: %v", codes)
+	}
+}
+
+func TestNew_InvalidOptions(t *testing.T) {
+	for _, opt := range []Option{
+		WithBalancer("Random"),
+		WithRateLimiter(0, 1),
+		WithRateLimiterConfig(config.RateLimiterConfig{Enabled: true, Type: "Unknown"}),
+		WithBackend(config.BackendConfig{ID: "a"}),
+		WithConfiguredMiddleware("unknown", nil),
+	} {
+		if _, err := New(opt); err == nil {
+			t.Error("ошибочные настройки должны отклоняться")
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package request
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies подсети прокси, которым разрешено передавать адрес клиента
+// в заголовках X-Forwarded-For и X-Real-IP. Пустой список — заголовкам не доверяет никто.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies разбирает список подсетей в нотации CIDR; отдельные адреса
+// (IPv4 или IPv6) считаются подсетями из одного адреса
+func ParseTrustedProxies(cidrs []string) (TrustedProxies, error) {
+	var trusted TrustedProxies
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy address %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			trusted = append(trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy network %q: %w", cidr, err)
+		}
+		trusted = append(trusted, network)
+	}
+	return trusted, nil
+}
+
+// Contains проверяет, что адрес принадлежит доверенному прокси
+func (t TrustedProxies) Contains(ip net.IP) bool {
+	for _, network := range t {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP возвращает IP адрес клиента. Заголовки учитываются, только если запрос пришел
+// от доверенного прокси: цепочка X-Forwarded-For просматривается справа налево, и адресом
+// клиента считается первый адрес, не принадлежащий доверенным прокси. Адреса левее него
+// добавлены самим клиентом и могут быть подделаны.
+func (t TrustedProxies) ClientIP(req *http.Request) string {
+	remote := remoteIP(req)
+	if remote == nil {
+		return req.RemoteAddr
+	}
+	if !t.Contains(remote) {
+		return remote.String()
+	}
+
+	if forwarded := forwardedFor(req.Header); len(forwarded) > 0 {
+		client := remote
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			ip := parseIP(forwarded[i])
+			if ip == nil {
+				// Некорректный адрес в цепочке: дальше ей доверять нельзя
+				break
+			}
+			client = ip
+			if !t.Contains(ip) {
+				break
+			}
+		}
+		return client.String()
+	}
+
+	if ip := parseIP(req.Header.Get("X-Real-IP")); ip != nil {
+		return ip.String()
+	}
+	return remote.String()
+}
+
+// remoteIP возвращает адрес непосредственного отправителя запроса
+func remoteIP(req *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return parseIP(host)
+}
+
+// forwardedFor возвращает адреса из всех заголовков X-Forwarded-For в порядке добавления
+func forwardedFor(header http.Header) []string {
+	var addrs []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, addr := range strings.Split(value, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+// parseIP разбирает адрес из заголовка; допускаются адреса с портом
+// (1.2.3.4:80, [2001:db8::1]:80) и IPv6 в квадратных скобках
+func parseIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return normalizeIP(ip)
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	if ip := net.ParseIP(strings.Trim(s, "[]")); ip != nil {
+		return normalizeIP(ip)
+	}
+	return nil
+}
+
+// normalizeIP приводит IPv4 адрес, в том числе записанный как ::ffff:a.b.c.d, к 4 байтам
+func normalizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
@@ -0,0 +1,49 @@
+package request
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "2001:db8::/32", "192.0.2.10"})
+	if err != nil {
+		t.Fatalf("ошибка разбора доверенных прокси: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		realIP    string
+		want      string
+	}{
+		{"заголовки от недоверенного клиента игнорируются", "203.0.113.5:1234", []string{"1.1.1.1"}, "1.1.1.1", "203.0.113.5"},
+		{"адрес из X-Forwarded-For доверенного прокси", "10.0.0.1:1234", []string{"198.51.100.7"}, "", "198.51.100.7"},
+		{"подделанный клиентом адрес в начале цепочки", "10.0.0.1:1234", []string{"1.1.1.1, 198.51.100.7, 10.0.0.2"}, "", "198.51.100.7"},
+		{"цепочка в нескольких заголовках", "10.0.0.1:1234", []string{"1.1.1.1", "198.51.100.7"}, "", "198.51.100.7"},
+		{"все адреса цепочки доверенные", "10.0.0.1:1234", []string{"10.0.0.3, 10.0.0.2"}, "", "10.0.0.3"},
+		{"некорректный адрес прерывает цепочку", "10.0.0.1:1234", []string{"1.1.1.1, garbage, 10.0.0.2"}, "", "10.0.0.2"},
+		{"X-Real-IP доверенного прокси", "192.0.2.10:1234", nil, "198.51.100.7", "198.51.100.7"},
+		{"IPv6 прокси и клиент", "[2001:db8::1]:1234", []string{"[2001:db8:ffff::1]:443, 2a00::5"}, "", "2a00::5"},
+		{"IPv4 в IPv6 записи", "[::ffff:10.0.0.1]:1234", []string{"198.51.100.7:8080"}, "", "198.51.100.7"},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tc.remote
+		for _, value := range tc.forwarded {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+		if tc.realIP != "" {
+			req.Header.Set("X-Real-IP", tc.realIP)
+		}
+		if got := trusted.ClientIP(req); got != tc.want {
+			t.Errorf("%s: адрес клиента %q, ожидался %q", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := ParseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("некорректная подсеть должна возвращать ошибку")
+	}
+}
@@ -1,142 +1,175 @@
 package request
 
 import (
-	"net"
+	"context"
 	"net/http"
-	"time"
+	"sync"
+
+	"cloud.ru_test/pkg/geoip"
 )
 
-// Request представляет интерфейс для работы с запросом
+// Request данные запроса, общие для всех этапов обработки. Прокси создает Request
+// один раз при получении запроса и сохраняет его в контексте; middleware дополняют
+// его значениями (пользователь, арендатор, утверждения токена), а балансировщик
+// и логирование используют их.
 type Request interface {
-	// GetUserID возвращает идентификатор пользователя (IPv4)
+	// GetUserID возвращает идентификатор пользователя: значение KeyUserID,
+	// если его установил middleware аутентификации, иначе IP адрес клиента
 	GetUserID() string
 
-	// GetResponseTime возвращает время ответа
-	GetResponseTime() time.Duration
+	// GetClientIP возвращает IP адрес клиента с учетом доверенных прокси
+	GetClientIP() string
 
-	// GetOriginalRequest возвращает оригинальный http.Request
+	// GetOriginalRequest возвращает исходный http.Request
 	GetOriginalRequest() *http.Request
 
-	// SetResponseTime устанавливает время ответа
-	SetResponseTime(duration time.Duration)
+	// GetGeo возвращает географические данные клиента (пустые, если GeoIP не настроен)
+	GetGeo() geoip.Info
+
+	// Value возвращает значение по имени ключа
+	Value(name string) (interface{}, bool)
+
+	// SetValue сохраняет значение по имени ключа
+	SetValue(name string, value interface{})
+}
+
+// Key типизированный ключ значения запроса
+type Key[T any] struct {
+	name string
 }
 
-// Wrapper интерфейс для обертки HTTP-обработчиков
-type Wrapper interface {
-	// Wrap оборачивает http.Handler для работы с Request
-	Wrap(handler http.Handler) http.Handler
+// NewKey создает ключ. Имена ключей сторонних middleware стоит начинать с их имени,
+// чтобы избежать пересечений.
+func NewKey[T any](name string) Key[T] {
+	return Key[T]{name: name}
 }
 
-// ResponseWriter расширяет стандартный http.ResponseWriter
-type ResponseWriter interface {
-	http.ResponseWriter
-	// GetStatus возвращает статус ответа
-	GetStatus() int
-	// GetRequest возвращает связанный Request
-	GetRequest() Request
+// Name возвращает имя ключа
+func (k Key[T]) Name() string {
+	return k.name
+}
+
+// Стандартные ключи значений запроса
+var (
+	// KeyUserID идентификатор аутентифицированного пользователя
+	KeyUserID = NewKey[string]("userID")
+
+	// KeyRequestID идентификатор запроса (заголовок X-Request-ID)
+	KeyRequestID = NewKey[string]("requestID")
+
+	// KeyTenant арендатор, к которому относится запрос
+	KeyTenant = NewKey[string]("tenant")
+
+	// KeyRoute имя маршрута, выбранного для запроса
+	KeyRoute = NewKey[string]("route")
+
+	// KeyBackendVersion версия бэкендов, на которые направляется запрос
+	// (канареечная маршрутизация по версии из проверки здоровья)
+	KeyBackendVersion = NewKey[string]("backendVersion")
+
+	// KeyBackends ID бэкендов маршрута запроса; другие бэкенды не участвуют в выборе
+	KeyBackends = NewKey[map[string]bool]("backends")
+
+	// KeyAffinity ID бэкенда, за которым закреплен клиент: если он доступен, запрос
+	// направляется ему. Пустое значение — клиент еще не закреплен. После выбора бэкенда
+	// прокси записывает в ключ выбранный бэкенд, чтобы закрепить за ним клиента.
+	KeyAffinity = NewKey[string]("affinity")
+
+	// KeyGeo географические данные клиента
+	KeyGeo = NewKey[geoip.Info]("geo")
+
+	// KeyClaims утверждения токена или сессии аутентифицированного пользователя
+	KeyClaims = NewKey[map[string]interface{}]("claims")
+
+	// KeyDebug для запроса включена отладка маршрутизации: этапы обработки
+	// сохраняют объяснение своих решений для заголовков ответа
+	KeyDebug = NewKey[bool]("debug")
+)
+
+// Get возвращает значение ключа key. Для nil запроса и отсутствующего значения
+// возвращается нулевое значение и false.
+func Get[T any](r Request, key Key[T]) (T, bool) {
+	var zero T
+	if r == nil {
+		return zero, false
+	}
+	v, ok := r.Value(key.name)
+	if !ok {
+		return zero, false
+	}
+	typed, ok := v.(T)
+	return typed, ok
+}
+
+// Set сохраняет значение ключа key; для nil запроса ничего не делает
+func Set[T any](r Request, key Key[T], value T) {
+	if r != nil {
+		r.SetValue(key.name, value)
+	}
 }
 
 // BaseRequest базовая реализация запроса
 type BaseRequest struct {
 	originalRequest *http.Request
-	responseTime    time.Duration
-	userID          string
+	clientIP        string
+
+	mu     sync.RWMutex
+	values map[string]interface{}
 }
 
-// NewRequest создает новый запрос
-func NewRequest(req *http.Request) *BaseRequest {
+// NewRequest создает новый запрос. Адрес клиента определяется с учетом заголовков
+// от доверенных прокси trusted (nil — по адресу отправителя).
+func NewRequest(req *http.Request, trusted TrustedProxies) *BaseRequest {
 	return &BaseRequest{
 		originalRequest: req,
-		userID:          extractUserID(req),
+		clientIP:        trusted.ClientIP(req),
+		values:          make(map[string]interface{}),
 	}
 }
 
 func (r *BaseRequest) GetUserID() string {
-	return r.userID
+	if userID, ok := Get[string](r, KeyUserID); ok && userID != "" {
+		return userID
+	}
+	return r.clientIP
 }
 
-func (r *BaseRequest) GetResponseTime() time.Duration {
-	return r.responseTime
+func (r *BaseRequest) GetClientIP() string {
+	return r.clientIP
 }
 
 func (r *BaseRequest) GetOriginalRequest() *http.Request {
 	return r.originalRequest
 }
 
-func (r *BaseRequest) SetResponseTime(duration time.Duration) {
-	r.responseTime = duration
+func (r *BaseRequest) GetGeo() geoip.Info {
+	geo, _ := Get(r, KeyGeo)
+	return geo
 }
 
-// extractUserID извлекает IPv4 адрес из запроса
-func extractUserID(req *http.Request) string {
-	// Сначала проверяем X-Forwarded-For
-	forwardedFor := req.Header.Get("X-Forwarded-For")
-	if forwardedFor != "" {
-		// Берем первый IP из списка
-		ips := net.ParseIP(forwardedFor)
-		if ips != nil && ips.To4() != nil {
-			return ips.String()
-		}
-	}
-
-	// Затем проверяем X-Real-IP
-	realIP := req.Header.Get("X-Real-IP")
-	if realIP != "" {
-		ip := net.ParseIP(realIP)
-		if ip != nil && ip.To4() != nil {
-			return ip.String()
-		}
-	}
-
-	// В последнюю очередь берем RemoteAddr
-	host, _, err := net.SplitHostPort(req.RemoteAddr)
-	if err == nil {
-		ip := net.ParseIP(host)
-		if ip != nil && ip.To4() != nil {
-			return ip.String()
-		}
-	}
-
-	return req.RemoteAddr
+func (r *BaseRequest) Value(name string) (interface{}, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.values[name]
+	return v, ok
 }
 
-// RequestWrapper обертка для http.Handler, которая создает Request
-type RequestWrapper struct {
-	handler http.Handler
+func (r *BaseRequest) SetValue(name string, value interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[name] = value
 }
 
-// NewRequestWrapper создает новый wrapper для http.Handler
-func NewRequestWrapper(handler http.Handler) *RequestWrapper {
-	return &RequestWrapper{
-		handler: handler,
-	}
-}
-
-func (w *RequestWrapper) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
-	request := NewRequest(req)
-	start := time.Now()
-
-	// Оборачиваем ResponseWriter для перехвата статуса ответа
-	wrapper := &responseWriterWrapper{
-		ResponseWriter: resp,
-		request:        request,
-	}
-
-	// Вызываем оригинальный handler
-	w.handler.ServeHTTP(wrapper, req)
-
-	// Устанавливаем время ответа
-	request.SetResponseTime(time.Since(start))
-}
+// contextKey ключ Request в контексте http.Request
+type contextKey struct{}
 
-// responseWriterWrapper для перехвата ответа
-type responseWriterWrapper struct {
-	http.ResponseWriter
-	request *BaseRequest
-	status  int
+// NewContext сохраняет Request в контексте
+func NewContext(ctx context.Context, r Request) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
 }
 
-func (w *responseWriterWrapper) WriteHeader(status int) {
-	w.status = status
-	w.ResponseWriter.WriteHeader(status)
+// FromContext возвращает Request, сохраненный прокси в контексте, или nil
+func FromContext(ctx context.Context) Request {
+	r, _ := ctx.Value(contextKey{}).(Request)
+	return r
 }
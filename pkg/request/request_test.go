@@ -0,0 +1,34 @@
+package request
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestValues(t *testing.T) {
+	r := NewRequest(httptest.NewRequest("GET", "/", nil), nil)
+	if r.GetUserID() != "192.0.2.1" {
+		t.Errorf("без аутентификации идентификатор пользователя — IP клиента: %q", r.GetUserID())
+	}
+
+	Set(r, KeyUserID, "alice")
+	Set(r, KeyTenant, "acme")
+	if r.GetUserID() != "alice" {
+		t.Errorf("идентификатор пользователя должен браться из KeyUserID: %q", r.GetUserID())
+	}
+	if tenant, ok := Get(r, KeyTenant); !ok || tenant != "acme" {
+		t.Errorf("значение должно сохраняться: %q %v", tenant, ok)
+	}
+
+	// Значение другого типа под тем же именем не возвращается
+	r.SetValue(KeyRoute.Name(), 42)
+	if _, ok := Get(r, KeyRoute); ok {
+		t.Errorf("значение неверного типа не должно возвращаться")
+	}
+
+	var nilRequest Request
+	Set(nilRequest, KeyTenant, "acme")
+	if _, ok := Get(nilRequest, KeyTenant); ok {
+		t.Errorf("для nil запроса значения нет")
+	}
+}
@@ -1,37 +1,169 @@
+// Package workerpool выполняет задачи на фиксированном числе горутин с ограниченной очередью
 package workerpool
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
 
+// Ошибки Submit
+var (
+	// ErrQueueFull возвращается с политикой Reject, если очередь заполнена
+	ErrQueueFull = errors.New("worker pool queue is full")
+
+	// ErrClosed возвращается после начала Shutdown
+	ErrClosed = errors.New("worker pool is shut down")
+)
+
+// Policy поведение Submit при заполненной очереди
+type Policy int
+
+const (
+	// Block ждет освобождения места в очереди, пока не завершится контекст Submit
+	Block Policy = iota
+
+	// Reject сразу отклоняет задачу с ErrQueueFull
+	Reject
+)
+
+// Options параметры пула
+type Options struct {
+	// Количество рабочих горутин (по умолчанию runtime.GOMAXPROCS(0))
+	Workers int
+
+	// Размер очереди задач, ожидающих свободную горутину (0 — задача передается горутине напрямую)
+	QueueSize int
+
+	// Поведение Submit при заполненной очереди
+	Policy Policy
+
+	// Вызывается с восстановленным значением и стеком, если задача паникует.
+	// Рабочая горутина после паники продолжает выполнять задачи.
+	PanicHandler func(recovered interface{}, stack []byte)
+}
+
+// WorkerPool пул рабочих горутин. Задачи ставятся в очередь Submit и выполняются
+// в порядке поступления; Shutdown дожидается выполнения задач, уже стоящих в очереди.
 type WorkerPool struct {
-	taskQueue chan func()
-	wg        sync.WaitGroup
+	tasks   chan func()
+	policy  Policy
+	onPanic func(recovered interface{}, stack []byte)
+
+	// closed выставляется под блокировкой mu, чтобы после Shutdown не появлялись новые отправители
+	mu     sync.RWMutex
+	closed bool
+
+	// Закрывается в начале Shutdown и будит ожидающие места в очереди Submit
+	quit      chan struct{}
+	closeOnce sync.Once
+
+	senders sync.WaitGroup
+	workers sync.WaitGroup
 }
 
-func NewWorkerPool(workerCount int) *WorkerPool {
-	pool := &WorkerPool{
-		taskQueue: make(chan func()),
+// New создает пул и запускает рабочие горутины
+func New(opts Options) *WorkerPool {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	queueSize := opts.QueueSize
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	wp := &WorkerPool{
+		tasks:   make(chan func(), queueSize),
+		policy:  opts.Policy,
+		onPanic: opts.PanicHandler,
+		quit:    make(chan struct{}),
 	}
-	for i := 0; i < workerCount; i++ {
-		go pool.worker()
+	wp.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go wp.worker()
 	}
-	return pool
+	return wp
 }
 
+// worker выполняет задачи, пока очередь не будет закрыта и опустошена
 func (wp *WorkerPool) worker() {
-	for task := range wp.taskQueue {
-		task()
+	defer wp.workers.Done()
+	for task := range wp.tasks {
+		wp.run(task)
 	}
 }
 
-func (wp *WorkerPool) Submit(task func()) {
-	wp.wg.Add(1)
-	go func() {
-		defer wp.wg.Done()
-		wp.taskQueue <- task
+// run выполняет задачу, восстанавливаясь после паники
+func (wp *WorkerPool) run(task func()) {
+	defer func() {
+		if recovered := recover(); recovered != nil && wp.onPanic != nil {
+			wp.onPanic(recovered, debug.Stack())
+		}
 	}()
+	task()
 }
 
-func (wp *WorkerPool) Wait() {
-	close(wp.taskQueue)
-	wp.wg.Wait()
+// Submit ставит задачу в очередь. При заполненной очереди с политикой Block ждет
+// освобождения места и возвращает ошибку ctx, если он завершится раньше; с политикой
+// Reject сразу возвращает ErrQueueFull. После начала Shutdown возвращает ErrClosed.
+func (wp *WorkerPool) Submit(ctx context.Context, task func()) error {
+	wp.mu.RLock()
+	if wp.closed {
+		wp.mu.RUnlock()
+		return ErrClosed
+	}
+	wp.senders.Add(1)
+	wp.mu.RUnlock()
+	defer wp.senders.Done()
+
+	if wp.policy == Reject {
+		select {
+		case wp.tasks <- task:
+			return nil
+		default:
+			return ErrQueueFull
+		}
+	}
+
+	select {
+	case wp.tasks <- task:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-wp.quit:
+		return ErrClosed
+	}
+}
+
+// Shutdown перестает принимать задачи и дожидается выполнения задач из очереди.
+// Если ctx завершается раньше, возвращает его ошибку; оставшиеся задачи при этом
+// выполняются в фоне. Повторный вызов снова ожидает завершения.
+func (wp *WorkerPool) Shutdown(ctx context.Context) error {
+	wp.closeOnce.Do(func() {
+		wp.mu.Lock()
+		wp.closed = true
+		wp.mu.Unlock()
+
+		// Ожидающие места в очереди отправители получают ErrClosed, после чего
+		// в очередь больше никто не пишет и ее можно закрыть
+		close(wp.quit)
+		wp.senders.Wait()
+		close(wp.tasks)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		wp.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
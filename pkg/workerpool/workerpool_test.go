@@ -0,0 +1,76 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool_Reject(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	wp := New(Options{Workers: 1, QueueSize: 1, Policy: Reject})
+
+	// Первая задача занимает горутину, вторая — единственное место в очереди
+	if err := wp.Submit(context.Background(), func() { close(started); <-release }); err != nil {
+		t.Fatalf("первая задача должна приниматься: %v", err)
+	}
+	<-started
+	if err := wp.Submit(context.Background(), func() {}); err != nil {
+		t.Fatalf("задача должна помещаться в очередь: %v", err)
+	}
+	if err := wp.Submit(context.Background(), func() {}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("при заполненной очереди ожидалась ошибка ErrQueueFull, получено %v", err)
+	}
+
+	close(release)
+	if err := wp.Shutdown(context.Background()); err != nil {
+		t.Fatalf("остановка не должна завершаться ошибкой: %v", err)
+	}
+}
+
+func TestWorkerPool_BlockContext(t *testing.T) {
+	release := make(chan struct{})
+	wp := New(Options{Workers: 1})
+	wp.Submit(context.Background(), func() { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := wp.Submit(ctx, func() {}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ожидание места в очереди должно прерываться контекстом, получено %v", err)
+	}
+
+	close(release)
+	wp.Shutdown(context.Background())
+	if err := wp.Submit(context.Background(), func() {}); !errors.Is(err, ErrClosed) {
+		t.Errorf("после Shutdown ожидалась ошибка ErrClosed, получено %v", err)
+	}
+}
+
+func TestWorkerPool_ShutdownDrainsQueue(t *testing.T) {
+	var done atomic.Int64
+	var panics atomic.Int64
+	wp := New(Options{Workers: 2, QueueSize: 10, PanicHandler: func(recovered interface{}, stack []byte) {
+		panics.Add(1)
+	}})
+
+	wp.Submit(context.Background(), func() { panic("boom") })
+	for i := 0; i < 10; i++ {
+		wp.Submit(context.Background(), func() {
+			time.Sleep(time.Millisecond)
+			done.Add(1)
+		})
+	}
+	if err := wp.Shutdown(context.Background()); err != nil {
+		t.Fatalf("остановка не должна завершаться ошибкой: %v", err)
+	}
+
+	if done.Load() != 10 {
+		t.Errorf("Shutdown должен дожидаться задач из очереди: выполнено %d из 10", done.Load())
+	}
+	if panics.Load() != 1 {
+		t.Errorf("паника задачи должна передаваться обработчику, вызовов: %d", panics.Load())
+	}
+}
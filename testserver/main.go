@@ -1,83 +1,399 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"math/rand/v2"
 	"net/http"
 	"net/http/httputil"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 var (
-	port    = flag.String("port", "8080", "порт для прослушивания")
-	message = flag.String("message", "Hello from test server", "сообщение для ответа")
+	port      = flag.String("port", "8080", "порт для прослушивания (первый порт при -instances > 1)")
+	message   = flag.String("message", "Hello from test server", "сообщение для ответа")
+	instances = flag.Int("instances", 1, "количество экземпляров на портах port, port+1, ...")
+	quiet     = flag.Bool("quiet", false, "не выводить запросы и ответы (для нагрузочного тестирования)")
+	headers   = flag.String("count-headers", "", "заголовки через запятую, по значениям которых /stats считает запросы")
+
+	latency      = flag.Duration("latency", 0, "задержка ответа (среднее для распределений normal и exponential)")
+	jitter       = flag.Duration("jitter", 0, "разброс задержки: ширина для uniform, стандартное отклонение для normal")
+	distribution = flag.String("latency-dist", "fixed", "распределение задержки: fixed, uniform, normal, exponential")
+	errorRate    = flag.Float64("error-rate", 0, "доля ответов ошибкой, от 0 до 1")
+	errorStatus  = flag.Int("error-status", http.StatusInternalServerError, "статус ответов ошибкой")
+	size         = flag.Int("size", 0, "размер тела ответа в байтах (0 — эхо запроса)")
+	chunks       = flag.Int("chunks", 0, "отправлять ответ частями (chunked) указанного количества")
+	chunkDelay   = flag.Duration("chunk-delay", 0, "пауза между частями ответа")
 )
 
+// behavior поведение экземпляра. Задается флагами и меняется на лету запросом к /control,
+// а для одного запроса — параметрами query с теми же именами, что у флагов.
+type behavior struct {
+	Latency      time.Duration
+	Jitter       time.Duration
+	Distribution string
+	ErrorRate    float64
+	ErrorStatus  int
+	Size         int
+	Chunks       int
+	ChunkDelay   time.Duration
+	Unhealthy    bool
+}
+
+// stats счетчики запросов экземпляра, которые возвращает /stats. Запросы к служебным
+// обработчикам (/health, /stats, /reset, /control) не учитываются.
+type stats struct {
+	mu      sync.Mutex
+	Port    string                      `json:"port"`
+	Message string                      `json:"message"`
+	Total   int64                       `json:"total"`
+	Methods map[string]int64            `json:"methods"`
+	Paths   map[string]int64            `json:"paths"`
+	Headers map[string]map[string]int64 `json:"headers"`
+}
+
+// server экземпляр тестового сервера
+type server struct {
+	port     string
+	message  string
+	logger   *log.Logger
+	behavior atomic.Pointer[behavior]
+	stats    stats
+}
+
 func main() {
 	flag.Parse()
 
-	// Настраиваем логгер
-	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
-	log.SetPrefix(fmt.Sprintf("[Echo Server :%s] ", *port))
+	defaults := behavior{
+		Latency:      *latency,
+		Jitter:       *jitter,
+		Distribution: *distribution,
+		ErrorRate:    *errorRate,
+		ErrorStatus:  *errorStatus,
+		Size:         *size,
+		Chunks:       *chunks,
+		ChunkDelay:   *chunkDelay,
+	}
+	if err := defaults.validate(); err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	firstPort, err := strconv.Atoi(*port)
+	if err != nil || *instances < 1 {
+		log.Fatalf("Invalid -port or -instances")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < *instances; i++ {
+		msg := *message
+		if *instances > 1 {
+			msg = fmt.Sprintf("%s (instance %d)", *message, i+1)
+		}
+		s := newServer(strconv.Itoa(firstPort+i), msg, defaults)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.run()
+		}()
+	}
+
+	// Экземпляры работают до Ctrl+C
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+}
 
+// newServer создает экземпляр на порту port
+func newServer(port, message string, defaults behavior) *server {
+	s := &server{
+		port:    port,
+		message: message,
+		// Настраиваем логгер экземпляра
+		logger: log.New(os.Stderr, fmt.Sprintf("[Echo Server :%s] ", port), log.Ldate|log.Ltime|log.Lmicroseconds),
+	}
+	s.behavior.Store(&defaults)
+	s.resetStats()
+	return s
+}
+
+// run запускает сервер экземпляра
+func (s *server) run() {
 	// Регистрируем обработчики
-	http.HandleFunc("/", handleRequest)
-	http.HandleFunc("/health", handleHealth)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleRequest)
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/control", s.handleControl)
+	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("/reset", s.handleReset)
 
-	// Запускаем сервер
-	addr := fmt.Sprintf(":%s", *port)
-	log.Printf("Starting server on %s with message: %s", addr, *message)
-	if err := http.ListenAndServe(addr, nil); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	addr := fmt.Sprintf(":%s", s.port)
+	s.logger.Printf("Starting server on %s with message: %s", addr, s.message)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		s.logger.Fatalf("Failed to start server: %v", err)
 	}
 }
 
-func handleRequest(w http.ResponseWriter, r *http.Request) {
-	// Логируем входящий запрос
-	dump, err := httputil.DumpRequest(r, true)
+func (s *server) handleRequest(w http.ResponseWriter, r *http.Request) {
+	b, err := s.behavior.Load().override(r.URL.Query())
 	if err != nil {
-		log.Printf("Error dumping request: %v", err)
-	} else {
-		log.Printf("Incoming request:\n%s", string(dump))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n := s.count(r)
+
+	// Логируем входящий запрос
+	if !*quiet {
+		dump, err := httputil.DumpRequest(r, true)
+		if err != nil {
+			s.logger.Printf("Error dumping request: %v", err)
+		} else {
+			s.logger.Printf("Incoming request:\n%s", string(dump))
+		}
 	}
 
 	// Читаем тело запроса
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading body: %v", err)
+		s.logger.Printf("Error reading body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
 	defer r.Body.Close()
 
-	// Формируем ответ
-	response := fmt.Sprintf("Server Message: %s\n\nRequest Details:\nMethod: %s\nPath: %s\nHeaders:\n",
-		*message, r.Method, r.URL.Path)
+	// Искусственная задержка
+	if d := b.delay(); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-r.Context().Done():
+			return
+		}
+	}
 
-	// Добавляем заголовки
-	for name, values := range r.Header {
-		for _, value := range values {
-			response += fmt.Sprintf("%s: %s\n", name, value)
+	// Внесение ошибок
+	if b.ErrorRate > 0 && rand.Float64() < b.ErrorRate {
+		http.Error(w, fmt.Sprintf("Injected error from %s", s.message), b.ErrorStatus)
+		if !*quiet {
+			s.logger.Printf("Sent injected error %d", b.ErrorStatus)
 		}
+		return
 	}
 
-	// Добавляем тело запроса, если оно есть
-	if len(body) > 0 {
-		response += fmt.Sprintf("\nRequest Body:\n%s", string(body))
+	// Формируем ответ
+	var response string
+	if b.Size > 0 {
+		response = strings.Repeat("x", b.Size)
+	} else {
+		response = fmt.Sprintf("Server Message: %s\n\nRequest Details:\nMethod: %s\nPath: %s\nRequest: %d\nHeaders:\n",
+			s.message, r.Method, r.URL.Path, n)
+
+		// Добавляем заголовки
+		for name, values := range r.Header {
+			for _, value := range values {
+				response += fmt.Sprintf("%s: %s\n", name, value)
+			}
+		}
+
+		// Добавляем тело запроса, если оно есть
+		if len(body) > 0 {
+			response += fmt.Sprintf("\nRequest Body:\n%s", string(body))
+		}
 	}
 
 	// Отправляем ответ
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprint(w, response)
+	w.Header().Set("X-Server-Port", s.port)
+	if b.Chunks > 1 {
+		s.stream(w, r, response, b)
+	} else {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, response)
+	}
 
 	// Логируем ответ
-	log.Printf("Sent response:\n%s", response)
+	if !*quiet {
+		s.logger.Printf("Sent response:\n%s", response)
+	}
 }
 
-func handleHealth(w http.ResponseWriter, r *http.Request) {
+// stream отправляет ответ частями с паузами, сбрасывая каждую часть клиенту
+func (s *server) stream(w http.ResponseWriter, r *http.Request, response string, b behavior) {
+	controller := http.NewResponseController(w)
+	w.WriteHeader(http.StatusOK)
+
+	chunkSize := (len(response) + b.Chunks - 1) / b.Chunks
+	for i := 0; i < len(response); i += chunkSize {
+		if i > 0 && b.ChunkDelay > 0 {
+			select {
+			case <-time.After(b.ChunkDelay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		io.WriteString(w, response[i:min(i+chunkSize, len(response))])
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// count учитывает запрос в статистике и возвращает его номер
+func (s *server) count(r *http.Request) int64 {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+
+	s.stats.Total++
+	s.stats.Methods[r.Method]++
+	s.stats.Paths[r.URL.Path]++
+	for name, values := range s.stats.Headers {
+		values[r.Header.Get(name)]++
+	}
+	return s.stats.Total
+}
+
+// resetStats обнуляет статистику
+func (s *server) resetStats() {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+
+	s.stats.Port, s.stats.Message = s.port, s.message
+	s.stats.Total = 0
+	s.stats.Methods = make(map[string]int64)
+	s.stats.Paths = make(map[string]int64)
+	s.stats.Headers = make(map[string]map[string]int64)
+	for _, name := range strings.Split(*headers, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			s.stats.Headers[http.CanonicalHeaderKey(name)] = make(map[string]int64)
+		}
+	}
+}
+
+// handleStats возвращает количество полученных запросов: всего, по методам, путям
+// и значениям заголовков -count-headers
+func (s *server) handleStats(w http.ResponseWriter, r *http.Request) {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&s.stats)
+}
+
+// handleReset обнуляет статистику перед очередным сценарием теста
+func (s *server) handleReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.resetStats()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	if s.behavior.Load().Unhealthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"status": "unhealthy"}`)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprint(w, `{"status": "ok"}`)
 }
+
+// handleControl меняет поведение экземпляра: POST /control?latency=200ms&error-rate=0.1&healthy=false.
+// GET возвращает текущее поведение.
+func (s *server) handleControl(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		b, err := s.behavior.Load().override(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.behavior.Store(&b)
+		s.logger.Printf("Behavior changed: %+v", b)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%+v\n", *s.behavior.Load())
+}
+
+// override возвращает поведение с измененными параметрами query
+func (b *behavior) override(query map[string][]string) (behavior, error) {
+	result := *b
+	var err error
+	for name, values := range query {
+		value := values[0]
+		switch name {
+		case "latency":
+			result.Latency, err = time.ParseDuration(value)
+		case "jitter":
+			result.Jitter, err = time.ParseDuration(value)
+		case "latency-dist":
+			result.Distribution = value
+		case "error-rate":
+			result.ErrorRate, err = strconv.ParseFloat(value, 64)
+		case "error-status":
+			result.ErrorStatus, err = strconv.Atoi(value)
+		case "size":
+			result.Size, err = strconv.Atoi(value)
+		case "chunks":
+			result.Chunks, err = strconv.Atoi(value)
+		case "chunk-delay":
+			result.ChunkDelay, err = time.ParseDuration(value)
+		case "healthy":
+			var healthy bool
+			healthy, err = strconv.ParseBool(value)
+			result.Unhealthy = !healthy
+		}
+		if err != nil {
+			return result, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return result, result.validate()
+}
+
+// validate проверяет параметры поведения
+func (b *behavior) validate() error {
+	switch b.Distribution {
+	case "fixed", "uniform", "normal", "exponential":
+	default:
+		return fmt.Errorf("unknown latency distribution %q", b.Distribution)
+	}
+	if b.Latency < 0 || b.Jitter < 0 || b.ChunkDelay < 0 {
+		return fmt.Errorf("durations must not be negative")
+	}
+	if b.ErrorRate < 0 || b.ErrorRate > 1 {
+		return fmt.Errorf("error rate must be between 0 and 1")
+	}
+	if b.ErrorStatus < 100 || b.ErrorStatus > 599 {
+		return fmt.Errorf("error status must be between 100 and 599")
+	}
+	if b.Size < 0 || b.Chunks < 0 {
+		return fmt.Errorf("size and chunks must not be negative")
+	}
+	return nil
+}
+
+// delay возвращает задержку ответа по распределению
+func (b *behavior) delay() time.Duration {
+	var d float64
+	switch b.Distribution {
+	case "uniform":
+		d = float64(b.Latency) + rand.Float64()*float64(b.Jitter)
+	case "normal":
+		d = float64(b.Latency) + rand.NormFloat64()*float64(b.Jitter)
+	case "exponential":
+		d = rand.ExpFloat64() * float64(b.Latency)
+	default:
+		d = float64(b.Latency)
+	}
+	return time.Duration(math.Max(d, 0))
+}